@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/analyze"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/slackintel"
+)
+
+// alertSnippetRunes caps the message text quoted in an alert
+const alertSnippetRunes = 200
+
+// keywordAlerts matches the messages each cycle writes against --keywords,
+// posts new matches to --notify-channel and logs them to alerts.parquet.
+// A message alerts once, however often later cycles re-fetch it.
+type keywordAlerts struct {
+	keywords *analyze.Keywords
+	// notify is the channel ID alerts are posted to; empty to only log them
+	notify string
+	// self is the token's user; its posts (the alerts) are never matched
+	self    string
+	fetcher *slackintel.Fetcher
+	store   *cache.ParquetCache
+
+	// seen holds the keys of the messages already alerted on, from
+	// alerts.parquet and this invocation
+	seen map[string]bool
+	// pending are the alerts not yet written to alerts.parquet
+	pending []*models.Alert
+}
+
+// newKeywordAlerts loads the alert log so earlier runs' alerts are not
+// repeated
+func newKeywordAlerts(ctx context.Context, keywords *analyze.Keywords, notify, self string, fetcher *slackintel.Fetcher, store *cache.ParquetCache) (*keywordAlerts, error) {
+	logged, err := store.LoadAlerts(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load alerts: %w", err)
+	}
+	seen := make(map[string]bool, len(logged))
+	for key := range logged {
+		seen[key] = true
+	}
+	return &keywordAlerts{keywords: keywords, notify: notify, self: self, fetcher: fetcher, store: store, seen: seen}, nil
+}
+
+// check matches a channel's messages and returns the new alerts. Each is
+// posted to the notify channel; the first failed post is returned, and
+// that alert is logged without notified_at rather than retried.
+func (ka *keywordAlerts) check(ctx context.Context, channel *models.SlackChannel, messages []*models.SlackMessage) ([]*models.Alert, error) {
+	var alerts []*models.Alert
+	var postErr error
+	for _, a := range ka.keywords.Alerts(channel, messages, time.Now()) {
+		if ka.seen[a.Key()] || (ka.self != "" && a.UserID == ka.self) {
+			continue
+		}
+		ka.seen[a.Key()] = true
+		if ka.notify != "" {
+			if _, err := ka.fetcher.PostMessage(ctx, ka.notify, alertText(a)); err != nil {
+				if postErr == nil {
+					postErr = err
+				}
+			} else {
+				a.NotifiedAt = time.Now()
+			}
+		}
+		alerts = append(alerts, a)
+	}
+	ka.pending = append(ka.pending, alerts...)
+	return alerts, postErr
+}
+
+// flush appends the pending alerts to alerts.parquet, returning its path
+// ("" when there were none) and how many were written. On failure they
+// stay pending for the next cycle.
+func (ka *keywordAlerts) flush(ctx context.Context) (string, int, error) {
+	if len(ka.pending) == 0 {
+		return "", 0, nil
+	}
+	path, err := ka.store.AppendAlerts(ctx, ka.pending)
+	if err != nil {
+		return "", 0, err
+	}
+	n := len(ka.pending)
+	ka.pending = nil
+	return path, n, nil
+}
+
+// alertText renders the chat.postMessage text of an alert: the keywords,
+// channel, author and permalink, then the start of the message quoted
+func alertText(a *models.Alert) string {
+	var b strings.Builder
+	b.WriteString(":rotating_light: `" + strings.Join(a.Keywords, "`, `") + "` in <#" + a.ChannelID + ">")
+	if a.UserID != "" {
+		b.WriteString(" from <@" + a.UserID + ">")
+	}
+	if a.Permalink != "" {
+		b.WriteString(": <" + a.Permalink + "|view message>")
+	}
+
+	snippet := strings.Join(strings.Fields(a.Text), " ")
+	if utf8.RuneCountInString(snippet) > alertSnippetRunes {
+		snippet = string([]rune(snippet)[:alertSnippetRunes]) + "…"
+	}
+	if snippet != "" {
+		b.WriteString("\n> " + snippet)
+	}
+	return b.String()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// artifactOptions holds the --output-dir and --force flags of the commands
+// that generate reports and exports
+type artifactOptions struct {
+	dir   string
+	force bool
+}
+
+func (a *artifactOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&a.dir, "output-dir", "", "Write the result to a file in this directory instead of stdout")
+	cmd.Flags().BoolVar(&a.force, "force", false, "Overwrite an existing file in --output-dir instead of adding a timestamp")
+}
+
+// artifact is where a command writes its result: stdout, or a file in
+// --output-dir that only appears once Close succeeds
+type artifact struct {
+	io.Writer
+	// Path is the file written, "" for stdout
+	Path string
+	tmp  *os.File
+}
+
+// create opens <dir>/<name>.<ext>, creating the directory if missing, or
+// stdout without --output-dir. An existing file is kept and the new one is
+// named <name>-<UTC timestamp>.<ext> instead, unless --force is set.
+func (a artifactOptions) create(name, ext string, now time.Time) (*artifact, error) {
+	if a.dir == "" {
+		return &artifact{Writer: os.Stdout}, nil
+	}
+	if err := os.MkdirAll(a.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	path := filepath.Join(a.dir, name+"."+ext)
+	if _, err := os.Stat(path); err == nil && !a.force {
+		path = filepath.Join(a.dir, fmt.Sprintf("%s-%s.%s", name, now.UTC().Format("20060102T150405Z"), ext))
+	}
+	if _, err := os.Stat(path); err == nil && !a.force {
+		return nil, fmt.Errorf("%s already exists (pass --force to overwrite it)", path)
+	}
+
+	tmp, err := os.CreateTemp(a.dir, "."+name+"-*.tmp")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	// CreateTemp makes the file private; reports are meant to be shared
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	return &artifact{Writer: tmp, Path: path, tmp: tmp}, nil
+}
+
+// Close publishes the file under its final name
+func (a *artifact) Close() error {
+	if a.tmp == nil {
+		return nil
+	}
+	tmp := a.tmp
+	a.tmp = nil
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", a.Path, err)
+	}
+	if err := os.Rename(tmp.Name(), a.Path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write %s: %w", a.Path, err)
+	}
+	return nil
+}
+
+// Abort discards an unpublished file; it does nothing after Close
+func (a *artifact) Abort() {
+	if a.tmp == nil {
+		return
+	}
+	a.tmp.Close()
+	os.Remove(a.tmp.Name())
+	a.tmp = nil
+}
+
+// writeArtifact runs write against the artifact <name>.<ext> and notes
+// where a file went
+func writeArtifact(opts artifactOptions, name, ext string, write func(io.Writer) error) error {
+	out, err := opts.create(name, ext, time.Now())
+	if err != nil {
+		return err
+	}
+	defer out.Abort()
+	if err := write(out); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if out.Path != "" && !quiet {
+		fmt.Fprintln(os.Stderr, successStyle.Render("✓ Wrote "+out.Path))
+	}
+	return nil
+}
+
+// formatExtension maps an output format to a file extension
+func formatExtension(format string) string {
+	switch format {
+	case "markdown":
+		return "md"
+	case "text":
+		return "txt"
+	}
+	return format
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack/fakeslack"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/config"
+)
+
+// authRecord is the JSON output of auth info
+type authRecord struct {
+	Team      string `json:"team"`
+	TeamID    string `json:"team_id"`
+	User      string `json:"user"`
+	UserID    string `json:"user_id"`
+	URL       string `json:"url"`
+	BotID     string `json:"bot_id,omitempty"`
+	TokenType string `json:"token_type"`
+	// Scopes holds the scope probes; omitted with --no-probe
+	Scopes []slack.ScopeProbe `json:"scopes,omitempty"`
+}
+
+func authCmd() *cobra.Command {
+	var (
+		output         string
+		channel        string
+		noProbe        bool
+		offlineFixture string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "auth",
+		Short: "Inspect the Slack token",
+	}
+
+	infoCmd := &cobra.Command{
+		Use:     "info",
+		Aliases: []string{"whoami"},
+		Short:   "Show the workspace and identity behind SLACK_API_TOKEN",
+		Long: `Call auth.test with SLACK_API_TOKEN and print the team name and ID, the
+authenticated user (the bot user for bot tokens), the workspace URL and
+whether the token is a bot or a user token. Then probe the scopes the
+cache command needs with one cheap call each: conversations.history and
+conversations.replies on --channel (the first configured channel by
+default) and users.info on the token's own user, reporting which scopes
+are available. Exits non-zero if the token is missing or rejected; a
+missing scope is reported, not fatal.
+
+Examples:
+  slack-intel auth info
+  slack-intel auth info --channel C0123456789
+  slack-intel auth whoami -o json --no-probe`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q (want text or json)", output)
+			}
+			return runAuthInfo(output, channel, noProbe, offlineFixture)
+		},
+	}
+	infoCmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	infoCmd.Flags().StringVarP(&channel, "channel", "c", "", "Channel ID or config name to probe the history scopes on (default: first configured channel)")
+	infoCmd.Flags().BoolVar(&noProbe, "no-probe", false, "Only call auth.test; skip the scope probes")
+	infoCmd.Flags().StringVar(&offlineFixture, "offline-fixture", "", "Serve Slack from a fakeslack JSON fixture instead of the API (development)")
+	infoCmd.Flags().MarkHidden("offline-fixture")
+
+	cmd.AddCommand(infoCmd)
+	return cmd
+}
+
+func runAuthInfo(output, channel string, noProbe bool, offlineFixture string) error {
+	clientOpts := []slack.Option{slack.WithLogger(logger)}
+	token, err := slackToken()
+	if offlineFixture != "" {
+		api, err := fakeslack.Load(offlineFixture)
+		if err != nil {
+			return err
+		}
+		clientOpts = append(clientOpts, slack.WithAPI(api))
+		if token == "" {
+			token = "xoxb-offline"
+		}
+	} else if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	slackClient := slack.NewClient(token, clientOpts...)
+	auth, err := slackClient.ValidateAuth(ctx)
+	if err != nil {
+		return tokenRejected(err)
+	}
+
+	var probes []slack.ScopeProbe
+	if !noProbe {
+		channelID, err := probeChannel(channel)
+		if err != nil {
+			return err
+		}
+		probes = slackClient.ProbeScopes(ctx, channelID, auth.UserID)
+	}
+
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(authRecord{
+			Team:      auth.Team,
+			TeamID:    auth.TeamID,
+			User:      auth.User,
+			UserID:    auth.UserID,
+			URL:       auth.URL,
+			BotID:     auth.BotID,
+			TokenType: string(auth.TokenType),
+			Scopes:    probes,
+		})
+	}
+
+	fmt.Println(titleStyle.Render("🔑 Slack Token"))
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Authenticated as %s on %s", auth.User, auth.Team)))
+	fmt.Printf("Team: %s (%s)\n", auth.Team, auth.TeamID)
+	fmt.Printf("User: %s (%s)\n", auth.User, auth.UserID)
+	fmt.Printf("URL: %s\n", auth.URL)
+	fmt.Printf("Token type: %s\n", auth.TokenType)
+	if auth.BotID != "" {
+		fmt.Printf("Bot ID: %s\n", auth.BotID)
+	}
+	if auth.TokenType == slack.TokenTypeBot {
+		fmt.Println(dimStyle.Render("  Bot tokens cannot read direct messages; use a user token (xoxp-) for DMs"))
+	}
+
+	if len(probes) > 0 {
+		fmt.Println()
+		fmt.Println(titleStyle.Render("Scopes"))
+	}
+	for _, p := range probes {
+		line := fmt.Sprintf("%s (%s)", p.Scope, p.Method)
+		switch p.Status {
+		case slack.ProbeOK:
+			fmt.Println(successStyle.Render("✓ " + line))
+		case slack.ProbeMissing:
+			fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %s: missing; add %s to the Slack app and reinstall it", line, p.Scope)))
+		case slack.ProbeFailed:
+			fmt.Println(errorStyle.Render(fmt.Sprintf("✗ %s: %s", line, p.Detail)))
+		default:
+			fmt.Println(dimStyle.Render(fmt.Sprintf("- %s: skipped, %s", line, p.Detail)))
+		}
+	}
+	return nil
+}
+
+// probeChannel resolves auth info --channel, a config name or an ID,
+// defaulting to the first configured channel; "" when there is none
+func probeChannel(channel string) (string, error) {
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrNoConfig) {
+		return strings.TrimPrefix(channel, "#"), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	if channel == "" {
+		for _, ch := range cfg.Channels {
+			if ch.ID != "" {
+				return ch.ID, nil
+			}
+		}
+		return "", nil
+	}
+	if ch, ok := cfg.ChannelByName(channel); ok {
+		return ch.ID, nil
+	}
+	return channel, nil
+}
+
+// tokenRejected wraps an auth.test failure, explaining token errors
+func tokenRejected(err error) error {
+	name := config.DefaultTokenEnv
+	if cfg, cfgErr := loadConfig(); cfgErr == nil {
+		name = cfg.TokenEnvName()
+	}
+	if hint := slack.AuthHint(err); hint != "" {
+		return fmt.Errorf("%s rejected, %s: %w", name, hint, err)
+	}
+	return fmt.Errorf("%s rejected: %w", name, err)
+}
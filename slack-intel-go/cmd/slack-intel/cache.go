@@ -0,0 +1,1683 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/analyze"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/lockfile"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/mrkdwn"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack/fakeslack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/config"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/slackintel"
+)
+
+// cacheOptions holds the cache command flags
+type cacheOptions struct {
+	channels    []string
+	groups      []string
+	exclude     []string
+	days        int
+	hours       int
+	cachePath   string
+	date        time.Time // --date: fetch this day, zero when unset
+	granularity cache.Granularity
+	template    *cache.NameTemplate
+	threadMode  slack.ThreadMode
+	minReplies  int
+	strict      bool // --strict: a thread whose replies failed fails the channel
+	skipUsers   bool // --no-users: no user lookups, user columns left null
+	excludeBots bool
+	redact      bool
+	normalize   mrkdwn.Mode   // empty unless --normalize-text
+	raw         cache.RawMode // where API payloads go, off by default
+	dedup       cache.DedupStrategy
+	dropDeleted bool         // --drop-deleted: drop cached messages a refetch no longer returns
+	format      cache.Format // file format of message partitions
+	partFile    string       // Parquet partition file name, from partFileName
+	output      string
+	quiet       bool // --quiet: errors and the JSON summary only
+	verbose     bool // --verbose: per-request and per-partition detail
+	retries     int
+	watch       bool
+	resume      bool
+	interval    time.Duration
+	workers     int
+	userTTL     time.Duration
+	bulkUsers   int
+	staticRate  bool // --static-rate-limit: ignore rate-limit response headers
+	// streamPartitions fetches and writes one partition at a time
+	streamPartitions bool
+	// resolveEmoji keeps emoji.parquet (custom emoji from emoji.list) fresh
+	resolveEmoji bool
+	// fullReactions refetches the reactions whose users Slack truncated
+	fullReactions bool
+	// pruneConfig removes archived and deleted channels from the config file
+	pruneConfig bool
+	// excludeBotsSet records an explicit --exclude-bots so it overrides config
+	excludeBotsSet bool
+	// offlineFixture serves Slack from a fakeslack JSON fixture (development)
+	offlineFixture string
+	// writer sets the Parquet row group and page sizes
+	writer cache.WriterOptions
+	// wait is how long to wait for another run's lock on the cache path
+	wait time.Duration
+	// jiraIndex rebuilds jira_index.parquet from the whole cache after a run
+	jiraIndex bool
+	// maxMessages caps the timeline messages read per channel, maxAPICalls
+	// the Web API requests of the whole run; 0 disables either
+	maxMessages int
+	maxAPICalls int64
+	// keywords alerts on written messages that match (--keywords), posting
+	// them to notifyChannel when set; nil without --keywords
+	keywords      *analyze.Keywords
+	notifyChannel string
+}
+
+func cacheCmd() *cobra.Command {
+	cmd := cacheFetchCmd()
+
+	cmd.AddCommand(cacheRedactCmd())
+	cmd.AddCommand(cacheReprocessCmd())
+	cmd.AddCommand(cacheMigrateCmd())
+	cmd.AddCommand(cacheGapsCmd())
+	cmd.AddCommand(cacheJiraIndexCmd())
+	cmd.AddCommand(cacheStatsCmd())
+	cmd.AddCommand(cacheEnrichCmd())
+
+	return cmd
+}
+
+// cacheFetchCmd is the cache command without its subcommands; watch
+// reuses it
+func cacheFetchCmd() *cobra.Command {
+	var (
+		opts        cacheOptions
+		granularity string
+		template    string
+		threads     string
+		mrkdwnMode  string
+		normalize   bool
+		raw         string
+		dedup       string
+		date        string
+		noThreads   bool
+		format      string
+		keywords    []string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Fetch messages from Slack and save to Parquet cache",
+		Long: `Fetch messages from Slack channels and cache them in Parquet format.
+
+Examples:
+  # Cache last 7 days from configured channels
+  slack-intel cache --days 7
+
+  # Cache specific channel
+  slack-intel cache --channel C9876543210 --days 3
+
+  # Cache multiple channels
+  slack-intel cache -c C9876543210 -c C1111111111 --days 1
+
+  # Cache configured channels except a noisy one
+  slack-intel cache --days 1 --exclude-channel alerts-noisy
+
+  # Fail channels with threads whose replies could not be fetched
+  slack-intel cache --days 1 --strict
+
+  # Record every reaction giver on popular messages, not only the first 50
+  slack-intel cache --days 1 --full-reactions
+
+  # Refresh cached user profiles (renames, titles) older than a week
+  slack-intel cache --days 1 --user-ttl 168h
+
+  # Timeline text only, fast: no thread replies and no user lookups
+  slack-intel cache --days 1 --no-threads --no-users
+
+  # Cache exactly one UTC calendar day
+  slack-intel cache --date 2024-05-10
+
+  # Cache the "incident" channel group hourly
+  slack-intel cache --group incident --partition-granularity hour --hours 6
+
+  # Keep caching new messages every 15 minutes
+  slack-intel cache --watch --interval 15m
+
+  # Continue a backfill that crashed or was interrupted
+  slack-intel cache --days 365 --stream-partitions --resume
+
+  # Keep the API payloads so later versions can rebuild columns offline
+  slack-intel cache --days 30 --raw=sidecar
+
+  # Let an overlapping cron run finish instead of failing at once
+  slack-intel cache --days 1 --wait 10m
+
+  # Write gzipped JSONL (data.jsonl.gz) for tools that cannot read Parquet
+  slack-intel cache --days 7 --format jsonl
+
+  # Name partition files for a catalog that expects Snappy-suffixed files
+  slack-intel cache --days 7 --part-filename part-0.snappy.parquet
+
+  # Never let a re-fetch replace messages already in the cache
+  slack-intel cache --days 7 --dedup-strategy first-seen
+
+  # Drop cached messages of the last week that were deleted in Slack
+  slack-intel cache --days 7 --drop-deleted
+
+  # Cap a run on a metered workspace
+  slack-intel cache --days 7 --max-messages-per-channel 5000 --max-api-calls 2000
+
+  # Alert in #ops-alerts on cached messages mentioning an outage
+  slack-intel cache --watch --keywords "outage,re:PROD-DOWN" --notify-channel C9999999999`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			partitionBy, err := cache.ParseGranularity(granularity)
+			if err != nil {
+				return err
+			}
+			opts.granularity = partitionBy
+
+			nameTemplate, err := cache.ParseNameTemplate(template)
+			if err != nil {
+				return err
+			}
+			if err := nameTemplate.Validate(partitionBy); err != nil {
+				return err
+			}
+			opts.template = nameTemplate
+
+			threadMode, err := slack.ParseThreadMode(threads)
+			if err != nil {
+				return err
+			}
+			if noThreads {
+				if cmd.Flags().Changed("threads") && threadMode != slack.ThreadModeTopLevel {
+					return fmt.Errorf("--no-threads cannot be combined with --threads %s", threads)
+				}
+				threadMode = slack.ThreadModeTopLevel
+			}
+			opts.threadMode = threadMode
+			opts.excludeBotsSet = cmd.Flags().Changed("exclude-bots")
+
+			mode, err := mrkdwn.ParseMode(mrkdwnMode)
+			if err != nil {
+				return err
+			}
+			if normalize {
+				opts.normalize = mode
+			}
+
+			if opts.raw, err = cache.ParseRawMode(raw); err != nil {
+				return err
+			}
+			if opts.raw != cache.RawOff && opts.redact {
+				return fmt.Errorf("--raw cannot be combined with --redact (payloads hold the unredacted text)")
+			}
+
+			if opts.dedup, err = cache.ParseDedupStrategy(dedup); err != nil {
+				return err
+			}
+			if opts.dropDeleted && threadMode != slack.ThreadModeAll {
+				return fmt.Errorf("--drop-deleted needs --threads all (other modes leave messages out of the fetch)")
+			}
+			if opts.dropDeleted && opts.minReplies > 0 {
+				return fmt.Errorf("--drop-deleted cannot be combined with --min-reply-count (skipped replies would be dropped)")
+			}
+
+			if opts.format, err = cache.ParseFormat(format); err != nil {
+				return err
+			}
+			if opts.format == cache.FormatJSONL {
+				if partFileFlag != "" {
+					return fmt.Errorf("--part-filename names Parquet partitions and cannot be combined with --format jsonl")
+				}
+				if opts.raw == cache.RawColumn {
+					return fmt.Errorf("--raw column needs --format parquet (use --raw=sidecar with jsonl)")
+				}
+				if opts.jiraIndex {
+					return fmt.Errorf("--jira-index reads Parquet partitions and cannot be combined with --format jsonl")
+				}
+			}
+
+			if opts.minReplies < 0 {
+				return fmt.Errorf("--min-reply-count must not be negative")
+			}
+			if opts.minReplies > 0 && threadMode == slack.ThreadModeTopLevel {
+				return fmt.Errorf("--min-reply-count has no effect with --threads none")
+			}
+			if opts.strict && threadMode == slack.ThreadModeTopLevel {
+				return fmt.Errorf("--strict has no effect with --threads none")
+			}
+
+			if opts.workers < 1 {
+				return fmt.Errorf("--workers must be at least 1")
+			}
+			if opts.writer.RowGroupRows < 1 || opts.writer.PageSize < 1 {
+				return fmt.Errorf("--row-group-rows and --page-size must be positive")
+			}
+
+			if opts.wait < 0 {
+				return fmt.Errorf("--wait must not be negative")
+			}
+			if opts.userTTL < 0 {
+				return fmt.Errorf("--user-ttl must not be negative")
+			}
+
+			if opts.maxMessages < 0 || opts.maxAPICalls < 0 {
+				return fmt.Errorf("--max-messages-per-channel and --max-api-calls must not be negative")
+			}
+			if opts.maxMessages > 0 && opts.streamPartitions {
+				return fmt.Errorf("--max-messages-per-channel cannot be combined with --stream-partitions (it would cap each partition, not the channel)")
+			}
+
+			if date != "" {
+				day, err := time.Parse("2006-01-02", date)
+				if err != nil {
+					return fmt.Errorf("invalid --date %q (want YYYY-MM-DD): %w", date, err)
+				}
+				for _, name := range []string{"days", "hours"} {
+					if cmd.Flags().Changed(name) {
+						return fmt.Errorf("--date cannot be combined with --%s (it fetches exactly that day)", name)
+					}
+				}
+				if opts.watch {
+					return fmt.Errorf("--date cannot be combined with --watch")
+				}
+				opts.date = day
+			}
+
+			if opts.watch && opts.interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			if opts.watch && opts.resume {
+				return fmt.Errorf("--resume cannot be combined with --watch")
+			}
+
+			if len(keywords) > 0 {
+				if opts.keywords, err = analyze.ParseKeywords(keywords); err != nil {
+					return fmt.Errorf("invalid --keywords: %w", err)
+				}
+			}
+			if opts.notifyChannel != "" && opts.keywords == nil {
+				return fmt.Errorf("--notify-channel needs --keywords")
+			}
+
+			if opts.output != "text" && opts.output != "json" {
+				return fmt.Errorf("invalid output format %q (expected text or json)", opts.output)
+			}
+			opts.quiet, opts.verbose = quiet, verbose
+
+			return runCache(opts)
+		},
+	}
+
+	cmd.Flags().StringSliceVarP(&opts.channels, "channel", "c", []string{}, "Channel ID(s), or config channel name(s), to cache (overrides config and groups)")
+	cmd.Flags().StringSliceVarP(&opts.groups, "group", "g", []string{}, "Channel group(s) from config to cache")
+	cmd.Flags().StringSliceVar(&opts.exclude, "exclude-channel", []string{}, "Channel ID(s) or name(s) to leave out of this run")
+	cmd.Flags().IntVarP(&opts.days, "days", "d", 2, "Days to look back")
+	cmd.Flags().IntVar(&opts.hours, "hours", 0, "Hours to look back")
+	cmd.Flags().StringVar(&opts.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&date, "date", "", "Fetch exactly this day, YYYY-MM-DD in the partition time zone (instead of --days/--hours)")
+	cmd.Flags().StringVar(&threads, "threads", "all", "Thread handling: all (timeline + replies), none (timeline only), parents (threads only)")
+	cmd.Flags().IntVar(&opts.minReplies, "min-reply-count", 0, "Only fetch replies of threads with at least this many; smaller threads keep the parent and its reply count")
+	cmd.Flags().BoolVar(&noThreads, "no-threads", false, "Skip thread replies (same as --threads none); partitions are marked as missing them")
+	cmd.Flags().BoolVar(&opts.skipUsers, "no-users", false, "Skip user lookups, leaving the user columns null; partitions are marked as missing them")
+	cmd.Flags().BoolVar(&opts.fullReactions, "full-reactions", false, "Refetch with reactions.get (reactions:read scope) the reactions whose users Slack cut short, about 50 per reaction")
+	cmd.Flags().BoolVar(&opts.strict, "strict", false, "Fail a channel when the replies of any thread could not be fetched after a retry (default: cache it and report the threads)")
+	cmd.Flags().BoolVar(&opts.excludeBots, "exclude-bots", false, "Drop bot messages (default: filters.exclude_bots from config)")
+	cmd.Flags().BoolVar(&opts.redact, "redact", false, "Strip emails, secrets and reaction user IDs before writing (emails already in users.parquet are kept)")
+	cmd.Flags().BoolVar(&normalize, "normalize-text", false, "Also store mrkdwn-normalized text in the clean_text column")
+	cmd.Flags().StringVar(&mrkdwnMode, "mrkdwn", string(mrkdwn.ModeStrip), "Formatting in clean_text: strip, markdown or keep")
+	cmd.Flags().StringVar(&raw, "raw", "off", "Keep API payloads for cache reprocess: column (raw_json), sidecar (raw/messages.ndjson) or off")
+	cmd.Flags().Lookup("raw").NoOptDefVal = string(cache.RawColumn)
+	cmd.Flags().StringVar(&dedup, "dedup-strategy", string(cache.DefaultDedupStrategy), "Copy kept when a message is already cached: keep-edited (latest edit, then fetch), last-write-wins or first-seen")
+	cmd.Flags().BoolVar(&opts.dropDeleted, "drop-deleted", false, "Drop cached messages in the fetched window that Slack no longer returns (default: keep them); skipped for truncated fetches and failed threads")
+	cmd.Flags().StringVar(&format, "format", string(cache.FormatParquet), "Message partition format: parquet (data.parquet) or jsonl (gzipped data.jsonl.gz, full messages)")
+	cmd.Flags().IntVar(&opts.retries, "retries", 2, "Extra passes over channels that failed with a transient error")
+	cmd.Flags().IntVar(&opts.workers, "workers", slack.DefaultWorkers, "Concurrent thread-reply and user-info requests")
+	cmd.Flags().DurationVar(&opts.userTTL, "user-ttl", 0, "Look up users again once their users.parquet copy is older than this, e.g. 168h (default: reuse them for ever)")
+	cmd.Flags().IntVar(&opts.bulkUsers, "bulk-users-threshold", slack.DefaultBulkUserThreshold, "Uncached users in one batch that switch lookups to a single users.list (0 disables)")
+	cmd.Flags().BoolVar(&opts.staticRate, "static-rate-limit", false, "Only use the static limiters, ignoring the X-RateLimit-* and Retry-After headers that otherwise slow a method down")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "text", "Summary format: text or json (json prints progress to stderr)")
+	cmd.Flags().BoolVar(&opts.watch, "watch", false, "Keep running, caching new messages every --interval")
+	cmd.Flags().DurationVar(&opts.interval, "interval", 15*time.Minute, "Time between --watch cycles (jittered by ±10%)")
+	cmd.Flags().BoolVar(&opts.streamPartitions, "stream-partitions", false, "Fetch and write one partition at a time to bound memory on long backfills")
+	cmd.Flags().BoolVar(&opts.resume, "resume", false, "Continue the run recorded in checkpoint.json, skipping channels and partitions it completed")
+	cmd.Flags().BoolVar(&opts.pruneConfig, "prune-config", false, "Remove archived and deleted channels from the config file")
+	cmd.Flags().BoolVar(&opts.jiraIndex, "jira-index", false, "Rebuild jira_index.parquet (where each JIRA ticket was mentioned) from the whole cache after the run")
+	cmd.Flags().BoolVar(&opts.resolveEmoji, "resolve-emoji", false, "Cache custom emoji from emoji.list in emoji.parquet (refreshed daily)")
+	cmd.Flags().StringVar(&granularity, "partition-granularity", string(cache.GranularityDay), "Partition size: hour, day or month")
+	cmd.Flags().Int64Var(&opts.writer.RowGroupRows, "row-group-rows", cache.DefaultRowGroupRows, "Most rows per Parquet row group; smaller groups prune better on read")
+	cmd.Flags().Int64Var(&opts.writer.PageSize, "page-size", cache.DefaultPageSize, "Target Parquet data page size in bytes")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout under messages/ ({date}, {channel}, {channel_id}, {year}, {month})")
+	cmd.Flags().IntVar(&opts.maxMessages, "max-messages-per-channel", 0, "Read at most this many timeline messages per channel, keeping the newest; capped channels are reported as truncated (0: no cap)")
+	cmd.Flags().Int64Var(&opts.maxAPICalls, "max-api-calls", 0, "Stop the run once it has made this many Slack API calls; rerun with --resume to continue (0: no limit)")
+	cmd.Flags().DurationVar(&opts.wait, "wait", 0, "Wait up to this long for another run holding the cache path's lock (default: fail at once)")
+	cmd.Flags().StringVar(&opts.offlineFixture, "offline-fixture", "", "Serve Slack from a fakeslack JSON fixture instead of the API (development)")
+	cmd.Flags().StringSliceVar(&keywords, "keywords", []string{}, "Alert on written messages containing any of these keywords (case-insensitive; re: prefix for a regular expression); matches are logged to alerts.parquet")
+	cmd.Flags().StringVar(&opts.notifyChannel, "notify-channel", "", "Channel ID or config name to post --keywords alerts to (needs the chat:write scope)")
+	cmd.Flags().MarkHidden("offline-fixture")
+
+	return cmd
+}
+
+// retryBackoff is the wait before the first retry pass; it doubles each pass
+const retryBackoff = 2 * time.Second
+
+// cacheSummary is the --output json document describing a cache run
+type cacheSummary struct {
+	Status      string           `json:"status"`
+	StartedAt   time.Time        `json:"started_at"`
+	DurationMs  int64            `json:"duration_ms"`
+	Totals      summaryTotals    `json:"totals"`
+	Errors      []string         `json:"errors,omitempty"`
+	Channels    []channelSummary `json:"channels"`
+	UsersCached int              `json:"users_cached"`
+	// Alerts counts the --keywords matches logged to alerts.parquet
+	Alerts int `json:"alerts,omitempty"`
+}
+
+// summaryTotals sums the per-channel results of a cache run
+type summaryTotals struct {
+	Channels      int   `json:"channels"`
+	Failed        int   `json:"failed"`
+	Inaccessible  int   `json:"inaccessible"`
+	Truncated     int   `json:"truncated"`
+	Empty         int   `json:"empty"`
+	Skipped       int   `json:"skipped"`
+	Messages      int   `json:"messages"`
+	ThreadReplies int   `json:"thread_replies"`
+	ThreadsFailed int   `json:"threads_failed"`
+	APICalls      int   `json:"api_calls"`
+	BotsExcluded  int   `json:"bots_excluded"`
+	Partitions    int   `json:"partitions"`
+	Bytes         int64 `json:"bytes"`
+}
+
+// Per-channel outcomes. empty means Slack answered with no messages in the
+// window; truncated means it reported more history without a cursor, or
+// the channel stopped at --max-messages-per-channel (capped).
+const (
+	outcomeOK           = "ok"
+	outcomeEmpty        = "empty"
+	outcomeTruncated    = "truncated"
+	outcomeInaccessible = "inaccessible"
+	outcomeError        = "error"
+	outcomeSkipped      = "skipped"
+)
+
+// channelSummary reports the outcome of caching one channel
+type channelSummary struct {
+	Channel          string `json:"channel"`
+	ChannelID        string `json:"channel_id"`
+	Outcome          string `json:"outcome"`
+	Pages            int    `json:"pages"`
+	Messages         int    `json:"messages"`
+	Timeline         int    `json:"timeline"`
+	ThreadReplies    int    `json:"thread_replies"`
+	ThreadsFailed    int    `json:"threads_failed,omitempty"`
+	Capped           bool   `json:"capped,omitempty"`            // stopped at --max-messages-per-channel
+	TimelineSkipped  int    `json:"timeline_skipped,omitempty"`  // left out by --threads parents
+	ReactionsFetched int    `json:"reactions_fetched,omitempty"` // messages refetched by --full-reactions
+	APICalls         int    `json:"api_calls"`
+	BotsExcluded     int    `json:"bots_excluded,omitempty"`
+	Partitions       int    `json:"partitions"`
+	Bytes            int64  `json:"bytes"`
+	Skipped          string `json:"skipped,omitempty"`
+	Error            string `json:"error,omitempty"`
+	Attempts         int    `json:"attempts"`
+	// FailedThreads are the thread_ts of threads cached without replies
+	FailedThreads []string `json:"failed_threads,omitempty"`
+
+	// stats holds per-user activity in what was cached, for the run-level
+	// user stats file
+	stats map[string]*slackintel.UserStats
+	// gone marks channels skipped as archived or deleted
+	gone bool
+	// files are the partition files written, for the run manifest
+	files []string
+}
+
+// add counts a fetched batch toward the channel's totals
+func (c *channelSummary) add(fetched *slackintel.FetchResult) {
+	c.Messages += len(fetched.Messages)
+	c.Timeline += fetched.Timeline
+	c.ThreadReplies += fetched.ThreadReplies
+	c.TimelineSkipped += fetched.TimelineSkipped
+	c.ReactionsFetched += fetched.ReactionsFetched
+	c.APICalls += fetched.APICalls
+	c.BotsExcluded += fetched.BotsExcluded
+	c.Pages += fetched.Pages
+	c.ThreadsFailed += len(fetched.ThreadsFailed)
+	c.FailedThreads = append(c.FailedThreads, fetched.ThreadsFailed...)
+	if fetched.Truncated || fetched.Capped {
+		c.Outcome = outcomeTruncated
+	}
+	c.Capped = c.Capped || fetched.Capped
+	c.stats = slackintel.MergeUserStats(c.stats, slackintel.AggregateUserStats(fetched.Messages))
+}
+
+// truncation names why the channel's history is incomplete, for the
+// truncated key of its partitions' metadata; "" when it is complete
+func (c *channelSummary) truncation() string {
+	switch {
+	case c.Capped:
+		return "max-messages-per-channel"
+	case c.Outcome == outcomeTruncated:
+		return "no-cursor"
+	}
+	return ""
+}
+
+// status is "ok" when every channel succeeded, "failed" when all of them
+// errored and "partial" otherwise
+func (s *cacheSummary) status() string {
+	failed := 0
+	for _, ch := range s.Channels {
+		if ch.Error != "" {
+			failed++
+		}
+	}
+	switch {
+	case failed == 0:
+		return "ok"
+	case failed == len(s.Channels):
+		return "failed"
+	default:
+		return "partial"
+	}
+}
+
+// finish records timing, totals and per-channel errors once a run or
+// --watch cycle is over
+func (s *cacheSummary) finish(start time.Time) {
+	s.StartedAt = start
+	s.DurationMs = time.Since(start).Milliseconds()
+
+	s.Totals = summaryTotals{Channels: len(s.Channels)}
+	s.Errors = nil
+	for _, ch := range s.Channels {
+		s.Totals.Messages += ch.Messages
+		s.Totals.ThreadReplies += ch.ThreadReplies
+		s.Totals.ThreadsFailed += ch.ThreadsFailed
+		s.Totals.APICalls += ch.APICalls
+		s.Totals.BotsExcluded += ch.BotsExcluded
+		s.Totals.Partitions += ch.Partitions
+		s.Totals.Bytes += ch.Bytes
+		switch ch.Outcome {
+		case outcomeSkipped:
+			s.Totals.Skipped++
+		case outcomeEmpty:
+			s.Totals.Empty++
+		case outcomeTruncated:
+			s.Totals.Truncated++
+		case outcomeInaccessible:
+			s.Totals.Inaccessible++
+		}
+		if ch.Error != "" {
+			s.Totals.Failed++
+			s.Errors = append(s.Errors, fmt.Sprintf("%s: %s", ch.Channel, ch.Error))
+		}
+	}
+}
+
+// keepStoredEmails returns users with the email of each one's stored copy
+// in known filled in where redaction removed it
+func keepStoredEmails(users, known map[string]*models.SlackUser) map[string]*models.SlackUser {
+	for id, u := range users {
+		if k := known[id]; k != nil && k.Email != "" && u.Email == "" {
+			c := *u
+			c.Email = k.Email
+			users[id] = &c
+		}
+	}
+	return users
+}
+
+// configOrigin names where the channels of a run came from, for errors
+func configOrigin(cfg *config.Config, groups []string) string {
+	origin := cfg.Path
+	if origin == "" {
+		origin = "SLACK_INTEL_* environment (no config file)"
+	}
+	if len(groups) > 0 {
+		origin += " (group(s) " + strings.Join(groups, ", ") + ")"
+	}
+	return origin
+}
+
+func runCache(opts cacheOptions) error {
+	startTime := time.Now()
+
+	// Progress goes to stderr in json mode so stdout carries only the
+	// summary; --quiet drops it altogether but keeps errors on stderr
+	var out io.Writer = os.Stdout
+	if opts.output == "json" {
+		out = os.Stderr
+	}
+	errOut := out
+	if opts.quiet {
+		out, errOut = io.Discard, os.Stderr
+	}
+
+	channelIDs := opts.channels
+	days, hours := opts.days, opts.hours
+	cachePath := opts.cachePath
+	granularity := opts.granularity
+
+	// Load config (a missing file is fine when channels come from --channel)
+	cfg, err := loadConfig()
+	switch {
+	case errors.Is(err, config.ErrNoConfig) && len(channelIDs) > 0:
+		cfg = &config.Config{}
+	case errors.Is(err, config.ErrNoConfig):
+		return fmt.Errorf("%w; run `slack-intel config init` to create one or pass --channel", err)
+	case err != nil:
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Partitions are cut in --tz or storage.partition_timezone, UTC by default
+	loc, err := partitionTimezone()
+	if err != nil {
+		return err
+	}
+	activity, err := configActivityHours(cfg)
+	if err != nil {
+		return err
+	}
+	sources, err := configTicketSources(cfg)
+	if err != nil {
+		return err
+	}
+	if !opts.date.IsZero() {
+		opts.date = time.Date(opts.date.Year(), opts.date.Month(), opts.date.Day(), 0, 0, 0, 0, loc)
+		if opts.date.After(time.Now()) {
+			return fmt.Errorf("--date %s is in the future", opts.date.Format("2006-01-02"))
+		}
+	}
+
+	excludeBots := cfg.Filters.ExcludeBots
+	if opts.excludeBotsSet {
+		excludeBots = opts.excludeBots
+	}
+	if excludeBots && opts.dropDeleted {
+		return fmt.Errorf("--drop-deleted cannot be combined with excluding bots (cached bot messages would be dropped)")
+	}
+
+	// Determine channels to process
+	var channelsToProcess []models.SlackChannel
+	if len(channelIDs) > 0 {
+		// Use CLI-provided channels; a config channel's name keeps its
+		// partitions under that name
+		for _, id := range channelIDs {
+			if ch, ok := cfg.ChannelByName(id); ok {
+				channelsToProcess = append(channelsToProcess, models.SlackChannel{Name: ch.Name, ID: ch.ID})
+				continue
+			}
+			channelsToProcess = append(channelsToProcess, models.SlackChannel{
+				Name: fmt.Sprintf("channel_%s", id),
+				ID:   id,
+			})
+		}
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Using %d channel(s) from CLI arguments", len(channelsToProcess))))
+	} else {
+		// Use channel groups from config, or all config channels
+		configured := cfg.Channels
+		source := "config"
+		if len(opts.groups) > 0 {
+			if configured, err = cfg.ResolveGroups(opts.groups); err != nil {
+				return err
+			}
+			source = "group(s) " + strings.Join(opts.groups, ", ")
+		}
+		if err := config.CheckChannels(configured); err != nil {
+			return fmt.Errorf("%s: %w; fix the config or pass --channel", configOrigin(cfg, opts.groups), err)
+		}
+		for _, ch := range configured {
+			channelsToProcess = append(channelsToProcess, models.SlackChannel{
+				Name: ch.Name,
+				ID:   ch.ID,
+			})
+		}
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Using %d channel(s) from %s", len(channelsToProcess), source)))
+	}
+
+	if len(opts.exclude) > 0 {
+		var excluded int
+		channelsToProcess, excluded = models.ExcludeChannels(channelsToProcess, opts.exclude)
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Excluding %d channel(s) matching --exclude-channel", excluded)))
+		if len(channelsToProcess) == 0 {
+			return fmt.Errorf("--exclude-channel left no channels to cache")
+		}
+	}
+
+	// --notify-channel may name a config channel
+	if ch, ok := cfg.ChannelByName(opts.notifyChannel); ok && opts.notifyChannel != "" {
+		opts.notifyChannel = ch.ID
+	}
+
+	progress := newProgressLine(out, !opts.quiet)
+	fetchOpts := []slackintel.Option{
+		slackintel.WithThreadMode(opts.threadMode),
+		slackintel.WithMinReplyCount(opts.minReplies),
+		slackintel.WithWorkers(opts.workers),
+		slackintel.WithBulkUserThreshold(opts.bulkUsers),
+		slackintel.WithUserTTL(opts.userTTL),
+		slackintel.WithProgress(progress.update),
+		slackintel.WithLogger(logger),
+		slackintel.WithExcludeBots(excludeBots),
+		slackintel.WithRedaction(opts.redact),
+		slackintel.WithTextNormalization(opts.normalize),
+		slackintel.WithChannelRateLimits(cfg.ChannelRateLimits()),
+		slackintel.WithTicketSources(sources),
+		slackintel.WithRawPayloads(opts.raw != cache.RawOff),
+		slackintel.WithMaxMessages(opts.maxMessages),
+		slackintel.WithMaxAPICalls(opts.maxAPICalls),
+		slackintel.WithSkipUsers(opts.skipUsers),
+		slackintel.WithFullReactions(opts.fullReactions),
+		slackintel.WithStaticRateLimit(opts.staticRate),
+	}
+
+	// Get Slack token (an offline fixture needs none)
+	token, err := slackToken()
+	if opts.offlineFixture != "" {
+		api, err := fakeslack.Load(opts.offlineFixture)
+		if err != nil {
+			return err
+		}
+		fetchOpts = append(fetchOpts, slackintel.WithAPI(api))
+		if token == "" {
+			token = "xoxb-offline"
+		}
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Serving Slack from fixture %s", opts.offlineFixture)))
+	} else if err != nil {
+		return err
+	}
+
+	// Initialize clients
+	fetcher := slackintel.NewFetcher(token, fetchOpts...)
+	parquetCache := slackintel.NewParquetStore(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	if opts.partFile, err = partFileName(); err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(opts.partFile)
+	parquetCache.SetPartitionTimezone(loc)
+	parquetCache.SetActivityHours(activity)
+	parquetCache.SetRawPayloads(opts.raw)
+	parquetCache.SetWriterOptions(opts.writer)
+	parquetCache.SetDedupStrategy(opts.dedup)
+	if opts.threadMode == slack.ThreadModeTopLevel {
+		parquetCache.MarkThin(cache.ThinThreads)
+	}
+	if opts.skipUsers {
+		parquetCache.MarkThin(cache.ThinUsers)
+	}
+	// SIGINT/SIGTERM cancel ctx; in-flight local partition writes still
+	// complete, bucket uploads are abandoned and leave the old object
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	storage.BindContext(ctx, store)
+
+	// Overlapping runs would race on the same partitions and users file
+	if storageBackend == "local" {
+		lock, err := lockfile.Acquire(ctx, filepath.Join(cachePath, ".lock"), opts.wait)
+		var held *lockfile.HeldError
+		if errors.As(err, &held) {
+			return fmt.Errorf("another cache run is using %s: %w (pass --wait to wait for it)", cachePath, err)
+		}
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+		if lock.Reclaimed != nil {
+			logger.Warn("reclaimed stale cache lock", "holder", lock.Reclaimed.String())
+		}
+	}
+
+	// Validate token and detect whether it is a bot or user token
+	auth, err := fetcher.Auth(ctx)
+	if err != nil {
+		return tokenRejected(err)
+	}
+	parquetCache.SetMetadata("token_type", string(auth.TokenType))
+	if opts.redact {
+		parquetCache.SetMetadata("redacted", "true")
+	}
+	if opts.normalize != "" {
+		parquetCache.SetMetadata("clean_text", string(opts.normalize))
+	}
+	if excludeBots {
+		parquetCache.SetMetadata("exclude_bots", "true")
+	}
+
+	// Prefer the user directory from `users sync` over per-user API calls
+	knownUsers, err := parquetCache.LoadUsers(ctx)
+	usersKnown := len(knownUsers)
+	if err != nil {
+		logger.Warn("ignoring cached users", "error", err)
+	} else {
+		fetcher.SeedUsers(knownUsers)
+	}
+
+	// Channel metadata is merged into channels.parquet like users
+	knownChannels, err := parquetCache.LoadChannels(ctx)
+	if err != nil {
+		logger.Warn("ignoring cached channels", "error", err)
+		knownChannels = make(map[string]*models.SlackChannel)
+	}
+
+	// Calculate time window: --date is that day, up to now if it is today
+	endTime := time.Now()
+	startTimeWindow := endTime.Add(-time.Duration(days)*24*time.Hour - time.Duration(hours)*time.Hour)
+	if !opts.date.IsZero() {
+		startTimeWindow = opts.date
+		if dayEnd := opts.date.AddDate(0, 0, 1); dayEnd.Before(endTime) {
+			endTime = dayEnd
+		}
+	}
+
+	// Runs other than --watch keep a checkpoint so a crashed backfill can
+	// be continued with --resume over the same window
+	var checkpoint *cache.Checkpoint
+	resumed := false
+	if !opts.watch {
+		checkpointPath := filepath.Join(cachePath, cache.CheckpointFile)
+		if opts.resume {
+			checkpoint, err = cache.LoadCheckpoint(checkpointPath)
+			if errors.Is(err, os.ErrNotExist) {
+				// Left by a release that kept it beside the cache directory
+				checkpoint, err = cache.LoadCheckpoint(filepath.Join(filepath.Dir(filepath.Clean(cachePath)), cache.CheckpointFile))
+			}
+			switch {
+			case errors.Is(err, os.ErrNotExist):
+			case err != nil:
+				return err
+			default:
+				if reason := checkpoint.Matches(granularity, opts.template); reason != "" {
+					return fmt.Errorf("cannot resume: %s", reason)
+				}
+				startTimeWindow, endTime = checkpoint.WindowStart, checkpoint.WindowEnd
+				resumed = true
+			}
+		}
+		if checkpoint == nil {
+			checkpoint = cache.NewCheckpoint(checkpointPath, startTimeWindow, endTime, granularity, opts.template)
+		}
+	}
+
+	// Print header
+	fmt.Fprintln(out, titleStyle.Render("📦 Slack to Parquet Cache (Go)"))
+	fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Processing %d channels", len(channelsToProcess))))
+	if resumed {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Resuming %s: %s to %s", checkpoint.Path(),
+			startTimeWindow.Format(time.RFC3339), endTime.Format(time.RFC3339))))
+	} else if !opts.date.IsZero() {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Time window: %s (%s)", opts.date.Format("2006-01-02"), loc)))
+	} else {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Time window: %d days, %d hours", days, hours)))
+		if opts.resume {
+			fmt.Fprintln(out, dimStyle.Render("No checkpoint to resume, starting a new run"))
+		}
+	}
+	fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Cache path: %s (partitioned by %s, %s)", cachePath, granularity, loc)))
+	if opts.template.String() != cache.DefaultNameTemplate || opts.partFile != cache.DefaultPartFileName {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Partition layout: messages/%s/%s", opts.template, opts.partFile)))
+	}
+	fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Workspace: %s (%s token)", auth.Team, auth.TokenType)))
+	if excludeBots {
+		fmt.Fprintln(out, dimStyle.Render("Excluding bot messages"))
+	}
+	if opts.threadMode == slack.ThreadModeTopLevel {
+		fmt.Fprintln(out, dimStyle.Render("Skipping thread replies"))
+	}
+	if opts.skipUsers {
+		fmt.Fprintln(out, dimStyle.Render("Skipping user lookups (user columns left empty)"))
+	}
+	if opts.minReplies > 0 {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Fetching replies of threads with %d+ replies only", opts.minReplies)))
+	}
+	if opts.dedup != cache.DefaultDedupStrategy {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Dedup strategy: %s", opts.dedup)))
+	}
+	if opts.redact {
+		fmt.Fprintln(out, dimStyle.Render("Redacting emails, secrets and reaction users"))
+	}
+	if opts.normalize != "" {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Normalizing text into clean_text (%s)", opts.normalize)))
+	}
+	if opts.raw != cache.RawOff {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Keeping API payloads (%s)", opts.raw)))
+	}
+	if opts.format == cache.FormatJSONL {
+		fmt.Fprintln(out, dimStyle.Render("Writing messages as gzipped JSONL (data.jsonl.gz)"))
+	}
+	if opts.maxMessages > 0 {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Reading at most %d timeline message(s) per channel", opts.maxMessages)))
+	}
+	if opts.maxAPICalls > 0 {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("API call budget: %d", opts.maxAPICalls)))
+	}
+	if limits := cfg.ChannelRateLimits(); len(limits) > 0 {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Rate limit overrides for %d channel(s)", len(limits))))
+	}
+	if opts.keywords != nil {
+		line := fmt.Sprintf("Alerting on keywords: %s (logged to %s)", opts.keywords, filepath.Base(parquetCache.AlertsPath()))
+		if opts.notifyChannel != "" {
+			line = fmt.Sprintf("Alerting on keywords: %s (posted to %s)", opts.keywords, opts.notifyChannel)
+		}
+		fmt.Fprintln(out, dimStyle.Render(line))
+	}
+	if opts.watch {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Watching every %s (Ctrl+C to stop)", opts.interval)))
+	}
+
+	// Best-effort forecast of a run's duration, refined as pages come in
+	var eta *slack.ETA
+	if !opts.watch {
+		runs, err := parquetCache.LoadRuns()
+		if err != nil {
+			logger.Warn("ignoring run manifest for the estimate", "error", err)
+		}
+		rates := cache.MessageRates(runs)
+		estimates := runEstimates(opts, channelsToProcess, endTime.Sub(startTimeWindow), rates, cfg.ChannelRateLimits(), usersKnown)
+		var total slack.Estimate
+		calibrated := 0
+		for _, ch := range channelsToProcess {
+			total = total.Add(estimates[ch.ID])
+			if _, ok := rates[ch.ID]; ok {
+				calibrated++
+			}
+		}
+		basis := "default message rates"
+		if calibrated > 0 {
+			basis = fmt.Sprintf("rates of earlier runs for %d of %d channel(s)", calibrated, len(channelsToProcess))
+		}
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Estimated: ~%s, %d API call(s) (%d history, %d thread, %d user; %s)",
+			formatETA(total.Duration), total.Calls(), total.HistoryCalls, total.ReplyCalls, total.UserCalls, basis)))
+		eta = slack.NewETA(estimates)
+		progress.eta = eta
+	}
+	fmt.Fprintln(out)
+
+	if opts.resolveEmoji {
+		refreshEmoji(ctx, out, errOut, fetcher, parquetCache)
+	}
+
+	run := &cacheRun{
+		opts:        opts,
+		out:         out,
+		errOut:      errOut,
+		fetcher:     fetcher,
+		parquet:     parquetCache,
+		loc:         loc,
+		channels:    channelsToProcess,
+		progress:    progress,
+		watermarks:  make(map[string]time.Time),
+		channelInfo: knownChannels,
+		knownUsers:  knownUsers,
+		described:   make(map[string]bool),
+		checkpoint:  checkpoint,
+		manifest:    parquetCache,
+		eta:         eta,
+	}
+	if opts.jiraIndex {
+		run.jiraIndex = parquetCache
+	}
+	if opts.keywords != nil {
+		if run.alerts, err = newKeywordAlerts(ctx, opts.keywords, opts.notifyChannel, auth.UserID, fetcher, parquetCache); err != nil {
+			return err
+		}
+	}
+	// Only channels read from the config file can be pruned from it
+	if opts.pruneConfig && len(channelIDs) == 0 && cfg.Path != "" {
+		run.configPath = cfg.Path
+	}
+
+	if opts.watch {
+		return run.watch(ctx, startTimeWindow)
+	}
+
+	summary := run.cycle(ctx, startTimeWindow, endTime)
+	elapsed := time.Since(startTime)
+
+	// A finished run needs no checkpoint; an unfinished one keeps it
+	if summary.Status == "ok" && ctx.Err() == nil {
+		if err := checkpoint.Remove(); err != nil {
+			logger.Warn("failed to remove checkpoint", "error", err)
+		}
+	} else {
+		fmt.Fprintf(out, "\n%s\n", dimStyle.Render(fmt.Sprintf("Progress saved to %s; rerun with --resume to continue", checkpoint.Path())))
+	}
+
+	summary.finish(startTime)
+	if opts.output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(summary); err != nil {
+			return err
+		}
+		return run.budgetErr()
+	}
+
+	// Summary
+	totals := summary.Totals
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, titleStyle.Render("✅ Cache Complete"))
+	fmt.Fprintf(out, "Total messages: %d\n", totals.Messages)
+	if excludeBots {
+		fmt.Fprintf(out, "Bot messages excluded: %d\n", totals.BotsExcluded)
+	}
+	fmt.Fprintf(out, "Total size: %.2f MB\n", float64(totals.Bytes)/(1024*1024))
+	if truncated := totals.Truncated; truncated > 0 {
+		fmt.Fprintf(out, "Truncated channels: %d (their history in the window is incomplete)\n", truncated)
+	}
+	if failed := totals.ThreadsFailed; failed > 0 {
+		fmt.Fprintf(out, "Threads missing replies: %d (rerun the window, or pass --strict to fail such channels)\n", failed)
+	}
+	for _, ch := range summary.Channels {
+		if ch.Attempts <= 1 {
+			continue
+		}
+		outcome := "ok"
+		if ch.Error != "" {
+			outcome = "failed"
+		}
+		fmt.Fprintf(out, "Retried %s: %d attempts, %s\n", ch.Channel, ch.Attempts, outcome)
+	}
+	fmt.Fprintf(out, "Time elapsed: %v\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(out, "Speed: %.0f messages/sec\n", float64(totals.Messages)/elapsed.Seconds())
+
+	return run.budgetErr()
+}
+
+// emojiMaxAge is how long emoji.parquet is reused before emoji.list is
+// called again
+const emojiMaxAge = 24 * time.Hour
+
+// refreshEmoji rewrites emoji.parquet from emoji.list unless the cached
+// list is younger than emojiMaxAge. Failures are logged, not fatal.
+func refreshEmoji(ctx context.Context, out, errOut io.Writer, fetcher *slackintel.Fetcher, store *slackintel.ParquetStore) {
+	known, err := store.LoadEmoji(ctx)
+	if err != nil {
+		logger.Warn("ignoring cached emoji", "error", err)
+	}
+	// Every row written by one SaveEmoji shares its cached_at
+	for _, e := range known {
+		if time.Since(e.CachedAt) < emojiMaxAge {
+			fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Using %d cached custom emoji", len(known))))
+			return
+		}
+		break
+	}
+
+	emoji, err := fetcher.ListEmoji(ctx)
+	if err != nil {
+		logger.Warn("failed to refresh custom emoji", "error", err)
+		return
+	}
+	path, err := store.SaveEmoji(emoji)
+	if err != nil {
+		fmt.Fprintf(errOut, "%s\n", errorStyle.Render(fmt.Sprintf("✗ Error saving emoji: %v", err)))
+		return
+	}
+	if path != "" {
+		fmt.Fprintln(out, dimStyle.Render(fmt.Sprintf("Cached %d custom emoji to %s", len(emoji), filepath.Base(path))))
+	}
+}
+
+// cacheRun holds the state shared by every cache cycle of one invocation.
+// The fetcher (and its user cache) is reused across --watch cycles.
+type cacheRun struct {
+	opts    cacheOptions
+	out     io.Writer
+	errOut  io.Writer // out, or stderr when --quiet discards out
+	fetcher *slackintel.Fetcher
+	// parquet writes users, channels and stats; messages go through it or
+	// a JSONL store wrapping it, per --format
+	parquet  *slackintel.ParquetStore
+	channels []models.SlackChannel
+	progress *progressLine
+	// loc is the time zone partitions are cut in
+	loc *time.Location
+
+	// watermarks holds, per channel ID, the end of the last successful fetch
+	watermarks map[string]time.Time
+
+	// channelInfo is the channels.parquet directory; described marks the
+	// channels whose conversations.info was read during this invocation
+	channelInfo      map[string]*models.SlackChannel
+	described        map[string]bool
+	channelsModified bool
+
+	// knownUsers is users.parquet as loaded at the start, whose emails a
+	// --redact run keeps when saving the users it fetched
+	knownUsers map[string]*models.SlackUser
+
+	// configPath is the config file to prune gone channels from with
+	// --prune-config; empty when channels did not come from it
+	configPath string
+
+	// checkpoint records flushed partitions and finished channels for
+	// --resume; nil in --watch mode
+	checkpoint *cache.Checkpoint
+
+	// manifest receives a RunRecord after every cycle
+	manifest *cache.ParquetCache
+
+	// jiraIndex rebuilds jira_index.parquet after every cycle; nil without
+	// --jira-index
+	jiraIndex *cache.ParquetCache
+
+	// alerts matches written messages against --keywords; nil without it
+	alerts *keywordAlerts
+
+	// eta forecasts the time left; nil in --watch mode
+	eta *slack.ETA
+
+	// budgetSpent is set once a channel stopped on the --max-api-calls
+	// budget; the channels after it are skipped
+	budgetSpent bool
+}
+
+// budgetErr reports a run stopped by --max-api-calls
+func (r *cacheRun) budgetErr() error {
+	if !r.budgetSpent {
+		return nil
+	}
+	return fmt.Errorf("%w: stopped after %d Slack API call(s) (--max-api-calls); rerun with --resume to continue", slack.ErrAPIBudget, r.opts.maxAPICalls)
+}
+
+// detail prints a --verbose line
+func (r *cacheRun) detail(format string, args ...any) {
+	if r.opts.verbose {
+		r.progress.clear()
+		fmt.Fprintln(r.out, dimStyle.Render("    "+fmt.Sprintf(format, args...)))
+	}
+}
+
+// describe reads a channel's conversations.info metadata once per
+// invocation. Failures other than channel_not_found are only logged: the
+// history fetch that follows reports inaccessible channels.
+func (r *cacheRun) describe(ctx context.Context, channelID string) (*models.SlackChannel, error) {
+	if r.described[channelID] {
+		return r.channelInfo[channelID], nil
+	}
+	info, err := r.fetcher.ChannelInfo(ctx, channelID)
+	if err != nil {
+		if !slack.IsChannelNotFound(err) {
+			logger.Warn("failed to read channel metadata", "channel", channelID, "error", err)
+		}
+		return nil, err
+	}
+	r.described[channelID] = true
+	r.channelInfo[channelID] = info
+	r.channelsModified = true
+	return info, nil
+}
+
+// since returns where a channel's fetch should start. Once a channel has a
+// watermark the fetch restarts at the beginning of the partition containing
+// it, so edits and late thread replies in the current partition are read
+// again.
+func (r *cacheRun) since(channelID string, windowStart time.Time) time.Time {
+	if w, ok := r.watermarks[channelID]; ok {
+		return r.opts.granularity.Start(w.In(r.loc))
+	}
+	return windowStart
+}
+
+// cycle fetches and caches every channel once. It stops between channels
+// (or between partition writes) when ctx is cancelled.
+func (r *cacheRun) cycle(ctx context.Context, windowStart, endTime time.Time) *cacheSummary {
+	out := r.out
+	summary := &cacheSummary{Channels: []channelSummary{}}
+	started := time.Now()
+	var written []string
+
+	// Process each channel, then retry transient failures with backoff
+	retry := make(map[int]bool)
+	skipped := 0
+	for i, channel := range r.channels {
+		if ctx.Err() != nil {
+			break
+		}
+		if r.budgetSpent {
+			summary.Channels = append(summary.Channels, channelSummary{Channel: channel.Name, ChannelID: channel.ID,
+				Outcome: outcomeSkipped, Skipped: "API call budget spent"})
+			skipped++
+			continue
+		}
+		result, retryable := r.cacheChannel(ctx, channel, windowStart, endTime)
+		result.Attempts = 1
+		summary.Channels = append(summary.Channels, result)
+		retry[i] = retryable
+	}
+	if skipped > 0 {
+		fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("⚠ Skipped %d channel(s): API call budget spent", skipped)))
+	}
+
+	backoff := retryBackoff
+	for attempt := 2; attempt <= r.opts.retries+1 && ctx.Err() == nil && !r.budgetSpent; attempt++ {
+		var pending []int
+		for i := range summary.Channels {
+			if retry[i] {
+				pending = append(pending, i)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		fmt.Fprintf(out, "\n%s\n", dimStyle.Render(fmt.Sprintf("🔁 Retrying %d channel(s) in %v (attempt %d of %d)",
+			len(pending), backoff, attempt, r.opts.retries+1)))
+		select {
+		case <-ctx.Done():
+		case <-time.After(backoff):
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		backoff *= 2
+
+		for _, i := range pending {
+			result, retryable := r.cacheChannel(ctx, r.channels[i], windowStart, endTime)
+			result.Attempts = attempt
+			summary.Channels[i] = result
+			retry[i] = retryable
+		}
+	}
+
+	var stats map[string]*slackintel.UserStats
+	for _, result := range summary.Channels {
+		stats = slackintel.MergeUserStats(stats, result.stats)
+	}
+
+	// Save user cache; redacted users keep the emails already stored
+	userCache := r.fetcher.Users()
+	if r.opts.redact {
+		userCache = keepStoredEmails(userCache, r.knownUsers)
+	}
+	if len(userCache) > 0 {
+		fmt.Fprintf(out, "\n👥 Caching %d users...\n", len(userCache))
+		usersPath, err := r.parquet.SaveUsers(userCache)
+		if err != nil {
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error saving users: %v", err)))
+		} else {
+			written = append(written, usersPath)
+			summary.UsersCached = len(userCache)
+			size, _ := r.parquet.Size(usersPath)
+			sizeMB := float64(size) / (1024 * 1024)
+			fmt.Fprintf(out, "%s (%.2f MB)\n",
+				successStyle.Render(fmt.Sprintf("  ✓ Cached users to %s", filepath.Base(usersPath))),
+				sizeMB)
+		}
+	}
+
+	// Save channel metadata read this run, merged with earlier runs
+	if r.channelsModified {
+		fmt.Fprintf(out, "\n📇 Caching %d channels...\n", len(r.channelInfo))
+		channelsPath, err := r.parquet.SaveChannels(r.channelInfo)
+		if err != nil {
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error saving channels: %v", err)))
+		} else {
+			written = append(written, channelsPath)
+			r.channelsModified = false
+			fmt.Fprintf(out, "%s\n", successStyle.Render(fmt.Sprintf("  ✓ Cached channels to %s", filepath.Base(channelsPath))))
+		}
+	}
+
+	// Save per-user activity for this run
+	if len(stats) > 0 {
+		statsPath, err := r.parquet.SaveUserStats(stats)
+		if err != nil {
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error saving user stats: %v", err)))
+		} else {
+			written = append(written, statsPath)
+			fmt.Fprintf(out, "%s\n", successStyle.Render(fmt.Sprintf("  ✓ Cached activity for %d users to %s", len(stats), filepath.Base(statsPath))))
+		}
+	}
+
+	// Re-index JIRA mentions across every cached channel, not just this run's
+	if r.jiraIndex != nil {
+		if indexPath, tickets, err := rebuildJiraIndex(ctx, r.jiraIndex); err != nil {
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error saving JIRA index: %v", err)))
+		} else if indexPath != "" {
+			written = append(written, indexPath)
+			fmt.Fprintf(out, "%s\n", successStyle.Render(fmt.Sprintf("  ✓ Indexed %d JIRA ticket(s) to %s", tickets, filepath.Base(indexPath))))
+		}
+	}
+
+	// Log this cycle's keyword matches for later review
+	if r.alerts != nil {
+		if alertsPath, n, err := r.alerts.flush(ctx); err != nil {
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error saving alerts: %v", err)))
+		} else if alertsPath != "" {
+			written = append(written, alertsPath)
+			summary.Alerts = n
+			fmt.Fprintf(out, "%s\n", successStyle.Render(fmt.Sprintf("  ✓ Logged %d keyword alert(s) to %s", n, filepath.Base(alertsPath))))
+		}
+	}
+
+	if r.configPath != "" {
+		r.pruneConfig(summary)
+	}
+
+	summary.Status = summary.status()
+	r.recordRun(summary, started, windowStart, endTime, written)
+	return summary
+}
+
+// recordRun appends the cycle to the run manifest; a failure is only logged
+func (r *cacheRun) recordRun(summary *cacheSummary, started, windowStart, endTime time.Time, written []string) {
+	if r.manifest == nil {
+		return
+	}
+	run := cache.RunRecord{
+		ID:          cache.NewRunID(started),
+		StartedAt:   started.UTC(),
+		FinishedAt:  time.Now().UTC(),
+		WindowStart: windowStart.UTC(),
+		WindowEnd:   endTime.UTC(),
+		ToolVersion: cache.ToolVersion,
+		Status:      summary.Status,
+		Channels:    []cache.RunChannel{},
+		Files:       written,
+	}
+	for _, ch := range summary.Channels {
+		run.Channels = append(run.Channels, cache.RunChannel{
+			Channel:       ch.Channel,
+			ChannelID:     ch.ChannelID,
+			Outcome:       ch.Outcome,
+			Messages:      ch.Messages,
+			Skipped:       ch.Skipped,
+			Error:         ch.Error,
+			Files:         ch.files,
+			FailedThreads: ch.FailedThreads,
+		})
+	}
+	path, err := r.manifest.AppendRun(run)
+	if err != nil {
+		logger.Warn("failed to record run", "error", err)
+		return
+	}
+	logger.Debug("recorded run", "id", run.ID, "path", path)
+}
+
+// pruneConfig removes the channels this cycle found archived or deleted
+// from the config file, so later runs stop visiting them
+func (r *cacheRun) pruneConfig(summary *cacheSummary) {
+	var ids []string
+	for _, result := range summary.Channels {
+		if result.gone {
+			ids = append(ids, result.ChannelID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	fmt.Fprintf(r.out, "\n🧹 Pruning %d archived or deleted channel(s) from config...\n", len(ids))
+	removed, err := config.PruneChannels(r.configPath, ids)
+	if err != nil {
+		fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error pruning config: %v", err)))
+		return
+	}
+	for _, ch := range removed {
+		fmt.Fprintf(r.out, "%s\n", successStyle.Render(fmt.Sprintf("  ✓ Removed %s (%s) from %s", ch.Name, ch.ID, r.configPath)))
+	}
+
+	// Stop visiting them in later --watch cycles
+	kept := r.channels[:0]
+	for _, ch := range r.channels {
+		if !slices.Contains(ids, ch.ID) {
+			kept = append(kept, ch)
+		}
+	}
+	r.channels = kept
+}
+
+// cacheChannel fetches and writes one channel. It reports whether a failure
+// is transient and worth retrying.
+func (r *cacheRun) cacheChannel(ctx context.Context, channel models.SlackChannel, windowStart, endTime time.Time) (channelSummary, bool) {
+	out := r.out
+	fmt.Fprintf(out, "📡 Fetching %s...\n", channel.Name)
+	r.progress.start(channel.Name)
+	result := channelSummary{Channel: channel.Name, ChannelID: channel.ID}
+	if r.eta != nil {
+		r.eta.Start(channel.ID, time.Now())
+		defer func() { r.eta.Done(channel.ID, time.Now()) }()
+	}
+
+	if err := r.fetcher.CanFetch(channel.ID); err != nil {
+		r.progress.clear()
+		fmt.Fprintf(out, "%s\n", dimStyle.Render(fmt.Sprintf("  ⚠ Skipped: %v", err)))
+		result.Skipped = err.Error()
+		result.Outcome = outcomeSkipped
+		return result, false
+	}
+
+	// Archived and deleted channels are skipped, not errors
+	if reason := slack.SkipReason(r.describe(ctx, channel.ID)); reason != "" {
+		r.progress.clear()
+		fmt.Fprintf(out, "%s\n", dimStyle.Render(fmt.Sprintf("  ⚠ Skipped: %s", reason)))
+		result.Skipped = reason
+		result.Outcome = outcomeSkipped
+		result.gone = true
+		return result, false
+	}
+
+	since := r.since(channel.ID, windowStart)
+	if r.checkpoint != nil {
+		cursor, done := r.checkpoint.Resume(channel.ID)
+		if done {
+			r.progress.clear()
+			fmt.Fprintf(out, "%s\n", dimStyle.Render("  ⚠ Skipped: completed before restart"))
+			result.Skipped = "completed before restart"
+			result.Outcome = outcomeSkipped
+			return result, false
+		}
+		if cursor.After(since) {
+			since = cursor
+		}
+	}
+	var err error
+	if r.opts.streamPartitions {
+		err = r.streamChannel(ctx, &channel, since, endTime, &result)
+	} else {
+		err = r.fetchChannel(ctx, &channel, since, endTime, &result)
+	}
+	r.progress.clear()
+	if err != nil {
+		retryable := ctx.Err() == nil && (slack.IsRetryable(err) || errors.Is(err, errStrictThreads))
+		result.Outcome = outcomeError
+		// Without the "Fetching" line above it, name the channel
+		prefix := "  ✗ "
+		if r.opts.quiet {
+			prefix = "✗ " + channel.Name + ": "
+		}
+		switch {
+		case retryable:
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("%sError (will retry): %v", prefix, err)))
+		case errors.Is(err, slack.ErrAPIBudget):
+			r.budgetSpent = true
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("%sStopped: all %d API call(s) of --max-api-calls spent; nothing of this channel was written",
+				prefix, r.opts.maxAPICalls)))
+		case slack.IsInaccessible(err):
+			result.Outcome = outcomeInaccessible
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("%sInaccessible: %v", prefix, err)))
+		default:
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("%sError: %v", prefix, err)))
+		}
+		result.Error = err.Error()
+		return result, retryable
+	}
+
+	if r.checkpoint != nil && result.Error == "" {
+		if err := r.checkpoint.Complete(channel.ID); err != nil {
+			logger.Warn("failed to update checkpoint", "channel", channel.ID, "error", err)
+		}
+	}
+
+	switch {
+	case result.Error != "":
+		// The failed partitions were reported as they were saved
+		result.Outcome = outcomeError
+		return result, false
+	case result.Capped:
+		fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ⚠ Truncated: stopped at %d timeline message(s) (--max-messages-per-channel); older messages in the window were not fetched",
+			r.opts.maxMessages)))
+	case result.Outcome == outcomeTruncated:
+		fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render("  ⚠ Truncated: Slack reported more history but returned no cursor"))
+	case result.Messages == 0:
+		result.Outcome = outcomeEmpty
+		fmt.Fprintf(out, "%s\n", dimStyle.Render(fmt.Sprintf("  ⚠ No messages found (%d page(s) read)", result.Pages)))
+		return result, false
+	default:
+		result.Outcome = outcomeOK
+	}
+
+	sizeMB := float64(result.Bytes) / (1024 * 1024)
+	fmt.Fprintf(out, "%s (%d messages, %.2f MB)\n",
+		successStyle.Render(fmt.Sprintf("  ✓ Cached %s", channel.Name)),
+		result.Messages,
+		sizeMB)
+	fmt.Fprintf(out, "%s\n", dimStyle.Render(fmt.Sprintf("    %d timeline, %d thread replies, %d page(s), %d API call(s)",
+		result.Timeline, result.ThreadReplies, result.Pages, result.APICalls)))
+	if result.TimelineSkipped > 0 {
+		fmt.Fprintf(out, "%s\n", dimStyle.Render(fmt.Sprintf("    %d timeline message(s) outside threads skipped", result.TimelineSkipped)))
+	}
+	if result.BotsExcluded > 0 {
+		fmt.Fprintf(out, "%s\n", dimStyle.Render(fmt.Sprintf("    %d bot message(s) excluded", result.BotsExcluded)))
+	}
+	if result.ReactionsFetched > 0 {
+		fmt.Fprintf(out, "%s\n", dimStyle.Render(fmt.Sprintf("    %d message(s) with truncated reactions refetched", result.ReactionsFetched)))
+	}
+	if result.ThreadsFailed > 0 {
+		fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ⚠ Replies missing for %d thread(s): %s", result.ThreadsFailed, threadList(result.FailedThreads))))
+	}
+
+	return result, false
+}
+
+// fetchChannel fetches the whole window at once, then writes each partition.
+// The watermark stays put when Slack truncated the history, so the next
+// --watch cycle fetches the window again.
+func (r *cacheRun) fetchChannel(ctx context.Context, channel *models.SlackChannel, since, endTime time.Time, result *channelSummary) error {
+	fetched, err := r.fetchRange(ctx, channel.ID, since, endTime)
+	if err != nil {
+		return err
+	}
+	result.add(fetched)
+
+	r.savePartitions(ctx, channel, slackintel.PartitionIn(fetched.Messages, r.opts.granularity, r.loc), since, endTime, result)
+	if result.Error == "" && !fetched.Truncated {
+		r.watermarks[channel.ID] = endTime
+	}
+	return nil
+}
+
+// streamChannel fetches one partition-sized window at a time and writes it
+// before moving on, bounding memory on long backfills. Thread replies that
+// fall in a later partition are held until that partition is written, so
+// each partition is written once. The watermark advances per partition
+// so a retry resumes where the failure happened, but never past a window
+// whose held replies are unwritten: a retry starting later would not fetch
+// their parents, and so not the replies either. Nor past a window Slack
+// truncated, which the next --watch cycle fetches again.
+func (r *cacheRun) streamChannel(ctx context.Context, channel *models.SlackChannel, since, endTime time.Time, result *channelSummary) error {
+	g, loc := r.opts.granularity, r.loc
+	pending := make(map[string][]*slackintel.Message)
+	// heldSince is, per pending partition, the start of the earliest window
+	// that fetched some of its messages
+	heldSince := make(map[string]time.Time)
+	// truncated is the start of the first window Slack truncated, if any
+	var truncated time.Time
+
+	for start := since.In(loc); start.Before(endTime); {
+		end := g.Start(start)
+		end = end.Add(g.Duration(end))
+		if end.After(endTime) {
+			end = endTime
+		}
+
+		fetched, err := r.fetchRange(ctx, channel.ID, start, end)
+		if err != nil {
+			return err
+		}
+		result.add(fetched)
+		if fetched.Truncated && truncated.IsZero() {
+			truncated = start
+		}
+
+		for key, msgs := range slackintel.PartitionIn(fetched.Messages, g, loc) {
+			pending[key] = append(pending[key], msgs...)
+			if _, ok := heldSince[key]; !ok {
+				heldSince[key] = start
+			}
+		}
+
+		// Partition keys sort chronologically, so everything up to the
+		// current key is complete
+		current := g.PartitionKey(start)
+		ready := make(map[string][]*slackintel.Message)
+		for key, msgs := range pending {
+			if key <= current || !end.Before(endTime) {
+				ready[key] = msgs
+				delete(pending, key)
+				delete(heldSince, key)
+			}
+		}
+		r.savePartitions(ctx, channel, ready, since, endTime, result)
+		if result.Error != "" {
+			return nil
+		}
+		watermark := end
+		for _, held := range heldSince {
+			if held.Before(watermark) {
+				watermark = held
+			}
+		}
+		if !truncated.IsZero() && truncated.Before(watermark) {
+			watermark = truncated
+		}
+		r.watermarks[channel.ID] = watermark
+
+		start = end
+	}
+
+	return nil
+}
+
+// errStrictThreads fails a channel whose thread replies could not all be
+// fetched under --strict; a retry may fetch them
+var errStrictThreads = errors.New("--strict")
+
+// fetchRange fetches one window, treating a cancellation during the fetch
+// as a failure so partial thread data never overwrites a partition
+func (r *cacheRun) fetchRange(ctx context.Context, channelID string, since, until time.Time) (*slackintel.FetchResult, error) {
+	started := time.Now()
+	fetched, err := r.fetcher.FetchRange(ctx, channelID, since, until)
+	if err == nil && ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	if err == nil && r.opts.strict && len(fetched.ThreadsFailed) > 0 {
+		err = fmt.Errorf("replies of %d thread(s) could not be fetched (%w): %s", len(fetched.ThreadsFailed), errStrictThreads, threadList(fetched.ThreadsFailed))
+	}
+	if err == nil {
+		r.detail("fetched %s to %s: %d message(s) in %d page(s), %s", since.Format("2006-01-02 15:04"), until.Format("2006-01-02 15:04"),
+			len(fetched.Messages), fetched.Pages, time.Since(started).Round(time.Millisecond))
+	}
+	return fetched, err
+}
+
+// alert reports the --keywords matches among a partition's messages
+func (r *cacheRun) alert(ctx context.Context, channel *models.SlackChannel, messages []*slackintel.Message) {
+	alerts, err := r.alerts.check(ctx, channel, messages)
+	for _, a := range alerts {
+		link := a.Permalink
+		if link == "" {
+			link = a.MessageID
+		}
+		r.progress.clear()
+		fmt.Fprintf(r.out, "%s\n", successStyle.Render(fmt.Sprintf("  🔔 Matched %s: %s", strings.Join(a.Keywords, ", "), link)))
+	}
+	if err != nil {
+		fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error posting alert to %s: %v", r.alerts.notify, err)))
+	}
+}
+
+// threadList renders thread timestamps for a message, naming the first few
+func threadList(threads []string) string {
+	const shown = 5
+	if len(threads) <= shown {
+		return strings.Join(threads, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(threads[:shown], ", "), len(threads)-shown)
+}
+
+// savePartitions writes each partition, recording the bytes written and the
+// first error on result. It stops early when ctx is cancelled. since and
+// until bound the fetch, whose deleted messages --drop-deleted drops.
+func (r *cacheRun) savePartitions(ctx context.Context, channel *models.SlackChannel, partitions map[string][]*slackintel.Message, since, until time.Time, result *channelSummary) {
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	// Partitions of a truncated history say so in their metadata. Only a
+	// complete fetch shows which cached messages were deleted in Slack.
+	pc := r.parquet
+	if reason := result.truncation(); reason != "" {
+		pc = pc.WithMetadata("truncated", reason)
+	} else if r.opts.dropDeleted && result.ThreadsFailed == 0 {
+		pc = pc.Refetched(since, until)
+	}
+	var store slackintel.Store = pc
+	if r.opts.format == cache.FormatJSONL {
+		store = cache.NewJSONLCache(pc)
+	}
+
+	for _, key := range keys {
+		if ctx.Err() != nil {
+			result.Error = ctx.Err().Error()
+			return
+		}
+
+		filePath, err := store.SaveMessages(partitions[key], channel, key)
+		if err != nil {
+			fmt.Fprintf(r.errOut, "%s\n", errorStyle.Render(fmt.Sprintf("  ✗ Error saving: %v", err)))
+			result.Error = err.Error()
+			continue
+		}
+
+		// Get file size
+		size, _ := store.Size(filePath)
+		r.detail("wrote %s: %d message(s), %d bytes", filePath, len(partitions[key]), size)
+		result.Bytes += size
+		result.Partitions++
+		result.files = append(result.files, filePath)
+
+		if r.alerts != nil {
+			r.alert(ctx, channel, partitions[key])
+		}
+
+		// Everything up to the end of this partition is on disk, unless an
+		// earlier partition failed
+		if r.checkpoint != nil && result.Error == "" {
+			g := r.opts.granularity
+			start := g.Start(partitions[key][0].Timestamp.In(r.loc))
+			cursor := start.Add(g.Duration(start))
+			if cursor.After(r.checkpoint.WindowEnd) {
+				cursor = r.checkpoint.WindowEnd
+			}
+			if err := r.checkpoint.Flushed(channel.ID, key, cursor); err != nil {
+				logger.Warn("failed to update checkpoint", "channel", channel.ID, "error", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+func channelsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "channels",
+		Short: "Inspect Slack channels",
+	}
+
+	var (
+		cached    bool
+		cachePath string
+	)
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List channels visible to the token",
+		Long: `List the channels visible to the token via conversations.list, or with
+--cached read channels.parquet (written by the cache command) without
+calling the API.
+
+Examples:
+  slack-intel channels list
+  slack-intel channels list --cached --cache-path /data/slack/raw`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runChannelsList(cached, cachePath)
+		},
+	}
+	listCmd.Flags().BoolVar(&cached, "cached", false, "Read channels.parquet instead of calling the API")
+	listCmd.Flags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory (with --cached)")
+
+	cmd.AddCommand(listCmd)
+	return cmd
+}
+
+func runChannelsList(cached bool, cachePath string) error {
+	ctx := context.Background()
+
+	var channels []models.SlackChannel
+	if cached {
+		parquetCache := cache.NewParquetCache(cachePath)
+		parquetCache.SetLogger(logger)
+		store, err := openStorage()
+		if err != nil {
+			return err
+		}
+		parquetCache.SetStorage(store)
+
+		known, err := parquetCache.LoadChannels(ctx)
+		if err != nil {
+			return err
+		}
+		for _, ch := range known {
+			channels = append(channels, *ch)
+		}
+		sort.Slice(channels, func(i, j int) bool {
+			return channels[i].Name < channels[j].Name
+		})
+	} else {
+		token, err := slackToken()
+		if err != nil {
+			return err
+		}
+		slackClient := slack.NewClient(token, slack.WithLogger(logger))
+		if channels, err = slackClient.ListChannels(ctx); err != nil {
+			return err
+		}
+	}
+
+	fmt.Println(titleStyle.Render("📇 Slack Channels"))
+	if len(channels) == 0 {
+		if cached {
+			fmt.Println(dimStyle.Render("⚠ No cached channels; run `slack-intel cache` first"))
+		} else {
+			fmt.Println(dimStyle.Render("⚠ No channels visible to this token"))
+		}
+		return nil
+	}
+
+	for _, ch := range channels {
+		var flags []string
+		if ch.IsPrivate {
+			flags = append(flags, "private")
+		}
+		if ch.IsArchived {
+			flags = append(flags, "archived")
+		}
+		line := fmt.Sprintf("%-12s #%s", ch.ID, ch.Name)
+		if len(flags) > 0 {
+			line += " (" + strings.Join(flags, ", ") + ")"
+		}
+		fmt.Println(line)
+
+		details := fmt.Sprintf("%d member(s)", ch.NumMembers)
+		if topic := strings.ReplaceAll(ch.Topic, "\n", " "); topic != "" {
+			details += " · " + topic
+		}
+		fmt.Println(dimStyle.Render("  " + details))
+	}
+	fmt.Println()
+	fmt.Println(dimStyle.Render(fmt.Sprintf("%d channel(s)", len(channels))))
+
+	return nil
+}
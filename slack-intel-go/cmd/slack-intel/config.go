@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/config"
+)
+
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage the .slack-intel.yaml configuration",
+	}
+
+	cmd.AddCommand(configInitCmd())
+	cmd.AddCommand(configValidateCmd())
+
+	return cmd
+}
+
+func configInitCmd() *cobra.Command {
+	var (
+		path  string
+		force bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a commented .slack-intel.yaml interactively",
+		Long: `Validate the Slack token, optionally pick channels from the workspace,
+and write a commented .slack-intel.yaml. The token is found as for other
+commands: the --profile's token variable (SLACK_API_TOKEN by default),
+--token, then the auth.token_command of an existing config.
+
+Examples:
+  # Scaffold config in the current directory
+  slack-intel config init
+
+  # Write to the home directory, replacing any existing file
+  slack-intel config init --path ~ --force`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigInit(path, force, os.Stdin)
+		},
+	}
+
+	cmd.Flags().StringVar(&path, "path", ".", "Directory or file path to write the config to")
+	cmd.Flags().BoolVar(&force, "force", false, "Overwrite an existing config file")
+
+	return cmd
+}
+
+func runConfigInit(path string, force bool, in io.Reader) error {
+	configPath := path
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		configPath = filepath.Join(path, config.DefaultFileName)
+	}
+
+	if _, err := os.Stat(configPath); err == nil && !force {
+		return fmt.Errorf("%s already exists (use --force to overwrite)", configPath)
+	}
+
+	fmt.Println(titleStyle.Render("🛠  Slack Intel Config Init"))
+
+	reader := bufio.NewReader(in)
+	ctx := context.Background()
+	var channels []config.ChannelConfig
+
+	// The token is found as every other command finds it: the --profile's
+	// token variable, --token, then an existing config's token_command
+	token, err := slackToken()
+	if err != nil {
+		fmt.Println(dimStyle.Render(fmt.Sprintf("⚠ No Slack token (%v), skipping token validation and channel selection", err)))
+	} else {
+		slackClient := slack.NewClient(token, slack.WithLogger(logger))
+
+		auth, err := slackClient.ValidateAuth(ctx)
+		if err != nil {
+			return fmt.Errorf("Slack token is invalid: %w", err)
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("✓ Authenticated as %s on %s (%s)", auth.User, auth.Team, auth.URL)))
+
+		if promptYesNo(reader, "Select channels from the workspace?", true) {
+			available, err := slackClient.ListChannels(ctx)
+			if err != nil {
+				return err
+			}
+
+			selected, err := promptChannels(reader, available)
+			if err != nil {
+				return err
+			}
+			for _, ch := range selected {
+				channels = append(channels, config.ChannelConfig{Name: ch.Name, ID: ch.ID})
+			}
+		}
+	}
+
+	if dir := filepath.Dir(configPath); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+	}
+
+	if err := os.WriteFile(configPath, config.Scaffold(channels), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Wrote %s with %d channel(s)", configPath, len(channels))))
+	return nil
+}
+
+// promptYesNo asks a yes/no question, returning def on empty input
+func promptYesNo(reader *bufio.Reader, question string, def bool) bool {
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	fmt.Printf("%s %s ", question, hint)
+
+	line, _ := reader.ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// promptChannels prints a numbered channel list and reads a selection
+func promptChannels(reader *bufio.Reader, available []models.SlackChannel) ([]models.SlackChannel, error) {
+	if len(available) == 0 {
+		fmt.Println(dimStyle.Render("No channels visible to this token"))
+		return nil, nil
+	}
+
+	for i, ch := range available {
+		fmt.Printf("%4d) #%s %s\n", i+1, ch.Name, dimStyle.Render(ch.ID))
+	}
+	fmt.Print("Channels to cache (e.g. 1,3,5-7, 'all', empty for none): ")
+
+	line, _ := reader.ReadString('\n')
+	indexes, err := parseSelection(line, len(available))
+	if err != nil {
+		return nil, err
+	}
+
+	selected := make([]models.SlackChannel, 0, len(indexes))
+	for _, idx := range indexes {
+		selected = append(selected, available[idx])
+	}
+	return selected, nil
+}
+
+// parseSelection turns "1,3,5-7" or "all" into zero-based indexes
+func parseSelection(input string, max int) ([]int, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, nil
+	}
+	if strings.EqualFold(input, "all") {
+		indexes := make([]int, max)
+		for i := range indexes {
+			indexes[i] = i
+		}
+		return indexes, nil
+	}
+
+	seen := make(map[int]bool)
+	var indexes []int
+	for _, part := range strings.Split(input, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		lo, hi := part, part
+		if from, to, ok := strings.Cut(part, "-"); ok {
+			lo, hi = from, to
+		}
+
+		start, err := strconv.Atoi(strings.TrimSpace(lo))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		end, err := strconv.Atoi(strings.TrimSpace(hi))
+		if err != nil {
+			return nil, fmt.Errorf("invalid selection %q", part)
+		}
+		if start < 1 || end > max || start > end {
+			return nil, fmt.Errorf("selection %q out of range 1-%d", part, max)
+		}
+
+		for n := start; n <= end; n++ {
+			if !seen[n] {
+				seen[n] = true
+				indexes = append(indexes, n-1)
+			}
+		}
+	}
+
+	return indexes, nil
+}
+
+func configValidateCmd() *cobra.Command {
+	var live bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check .slack-intel.yaml for mistakes before running a cache",
+		Long: `Parse the config strictly (unknown keys are errors), check channel IDs,
+storage and jira settings, and optionally confirm channel access via the API.
+
+Examples:
+  # Validate the config found in the current or home directory
+  slack-intel config validate
+
+  # Validate a specific file
+  slack-intel --config /etc/slack-intel.yaml config validate
+
+  # Also confirm every channel is accessible with SLACK_API_TOKEN
+  slack-intel config validate --live`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConfigValidate(configPath, live)
+		},
+	}
+
+	cmd.Flags().BoolVar(&live, "live", false, "Call conversations.info for each channel")
+
+	return cmd
+}
+
+func runConfigValidate(explicitPath string, live bool) error {
+	path, err := config.Resolve(explicitPath)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(titleStyle.Render("🔎 Validating Config"))
+	fmt.Println(dimStyle.Render(fmt.Sprintf("File: %s", path)))
+	fmt.Println()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	cfg, err := config.ParseStrict(data)
+	if err != nil {
+		printCheck(config.Check{Item: "parse", Err: err})
+		return fmt.Errorf("%s is not a valid config", path)
+	}
+	printCheck(config.Check{Item: "parse"})
+	if profileName != "" {
+		if err := cfg.UseProfile(profileName); err != nil {
+			printCheck(config.Check{Item: "profile", Err: err})
+			return fmt.Errorf("%s has no usable profile %q", path, profileName)
+		}
+		fmt.Println(dimStyle.Render(fmt.Sprintf("Profile: %s", profileName)))
+	}
+
+	ctx := context.Background()
+	checks := cfg.Validate()
+
+	if cfg.Storage.Bucket != "" && (cfg.Storage.Region != "" || cfg.Storage.Endpoint != "") {
+		var err error
+		if _, lookupErr := net.DefaultResolver.LookupHost(ctx, cfg.Storage.S3Endpoint()); lookupErr != nil {
+			err = fmt.Errorf("cannot resolve %s: %w", cfg.Storage.S3Endpoint(), lookupErr)
+		}
+		checks = append(checks, config.Check{Item: "storage endpoint", Err: err})
+	}
+
+	if live {
+		liveChecks, err := liveChannelChecks(ctx, cfg.Channels)
+		if err != nil {
+			return err
+		}
+		checks = append(checks, liveChecks...)
+	}
+
+	failed := 0
+	for _, check := range checks {
+		printCheck(check)
+		if !check.OK() {
+			failed++
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%d config check(s) failed", failed)
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ %d checks passed", len(checks)+1)))
+	return nil
+}
+
+// liveChannelChecks confirms each configured channel via conversations.info
+func liveChannelChecks(ctx context.Context, channels []config.ChannelConfig) ([]config.Check, error) {
+	token, err := slackToken()
+	if err != nil {
+		return nil, fmt.Errorf("--live requires a Slack token: %w", err)
+	}
+	slackClient := slack.NewClient(token, slack.WithLogger(logger))
+
+	checks := make([]config.Check, 0, len(channels))
+	for _, ch := range channels {
+		if !config.ValidChannelID(ch.ID) {
+			continue // already reported by the offline checks
+		}
+		_, err := slackClient.GetChannelInfo(ctx, ch.ID)
+		checks = append(checks, config.Check{Item: fmt.Sprintf("access %s (%s)", ch.Name, ch.ID), Err: err})
+	}
+	return checks, nil
+}
+
+func printCheck(check config.Check) {
+	if check.OK() {
+		fmt.Println(successStyle.Render(fmt.Sprintf("  ✓ %s", check.Item)))
+		return
+	}
+	fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ %s: %v", check.Item, check.Err)))
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/analyze"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack/fakeslack"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/config"
+)
+
+// digestOptions holds the digest flags
+type digestOptions struct {
+	report reportOptions
+	// date is the day summarized: yesterday, today or YYYY-MM-DD
+	date string
+	// to is the channel ID or config name digest post posts to
+	to             string
+	dryRun         bool
+	offlineFixture string
+}
+
+// digestPayload is one chat.postMessage call of digest post, as --dry-run
+// prints it
+type digestPayload struct {
+	Channel string       `json:"channel"`
+	Text    string       `json:"text"`
+	Blocks  slack.Blocks `json:"blocks"`
+}
+
+func digestCmd() *cobra.Command {
+	var (
+		opts     digestOptions
+		template string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "digest",
+		Short: "Summarize a day of cached messages",
+		Long: `Summarize one day of cached messages: the threads with the most replies,
+the most reacted messages, the JIRA tickets mentioned and how many people
+took part. The day runs midnight to midnight in the partition timezone.
+
+Examples:
+  slack-intel digest --channels backend,incidents --date yesterday
+  slack-intel digest --date 2024-05-09 -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(analyze.DigestFormats, opts.report.output) {
+				return fmt.Errorf("unknown output format %q (want %s)", opts.report.output, strings.Join(analyze.DigestFormats, ", "))
+			}
+			if err := opts.validate(template); err != nil {
+				return err
+			}
+			return runDigest(opts)
+		},
+	}
+	cmd.PersistentFlags().StringVar(&opts.report.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.PersistentFlags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.PersistentFlags().StringSliceVar(&opts.report.channels, "channels", nil, "Only these channels, by cache name (comma-separated)")
+	cmd.PersistentFlags().StringVar(&opts.date, "date", "yesterday", "Day to summarize: yesterday, today or YYYY-MM-DD")
+	cmd.PersistentFlags().BoolVar(&opts.report.allowMixed, "allow-mixed-schemas", false, "Read partitions written with different schema versions together")
+	cmd.Flags().StringVarP(&opts.report.output, "output", "o", "markdown", "Output format: markdown or json")
+	opts.report.artifacts.addFlags(cmd)
+
+	postCmd := &cobra.Command{
+		Use:   "post",
+		Short: "Post the digest to a Slack channel",
+		Long: `Post the digest to the --to channel with chat.postMessage as Block Kit
+sections. A digest longer than a message's 3000-character section limit
+is split across several messages. The token needs the chat:write scope,
+and a bot must be a member of the channel. --dry-run prints the
+chat.postMessage payloads as JSON instead of posting.
+
+Examples:
+  slack-intel digest post --channels backend,incidents --date yesterday --to C0123456789
+  slack-intel digest post --to digests --dry-run`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.to == "" && !opts.dryRun {
+				return fmt.Errorf("--to is required unless --dry-run is given")
+			}
+			if err := opts.validate(template); err != nil {
+				return err
+			}
+			return runDigestPost(opts)
+		},
+	}
+	postCmd.Flags().StringVar(&opts.to, "to", "", "Channel ID or config name to post the digest to")
+	postCmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Print the Block Kit payloads as JSON instead of posting")
+	postCmd.Flags().StringVar(&opts.offlineFixture, "offline-fixture", "", "Serve Slack from a fakeslack JSON fixture instead of the API (development)")
+	postCmd.Flags().MarkHidden("offline-fixture")
+
+	cmd.AddCommand(postCmd)
+	return cmd
+}
+
+// validate parses the name template
+func (o *digestOptions) validate(template string) error {
+	nameTemplate, err := cache.ParseNameTemplate(template)
+	if err != nil {
+		return err
+	}
+	o.report.template = nameTemplate
+	return nil
+}
+
+// digestDay resolves --date to the day [from, to) in loc
+func digestDay(date string, now time.Time, loc *time.Location) (time.Time, time.Time, error) {
+	now = now.In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	var day time.Time
+	switch date {
+	case "today":
+		day = today
+	case "yesterday":
+		day = today.AddDate(0, 0, -1)
+	default:
+		parsed, err := time.ParseInLocation("2006-01-02", date, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid --date %q (want yesterday, today or YYYY-MM-DD)", date)
+		}
+		day = parsed
+	}
+	return day, day.AddDate(0, 0, 1), nil
+}
+
+// buildDigest summarizes the cached messages of --date
+func buildDigest(ctx context.Context, opts digestOptions) (*analyze.Digest, error) {
+	parquetCache, loc, err := openReportCache(opts.report)
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := digestDay(opts.date, time.Now(), loc)
+	if err != nil {
+		return nil, err
+	}
+	msgs, users, err := cachedMessages(ctx, parquetCache, opts.report.channels, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return analyze.BuildDigest(msgs, users, from, to), nil
+}
+
+func runDigest(opts digestOptions) error {
+	digest, err := buildDigest(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	return writeArtifact(opts.report.artifacts, "digest", formatExtension(opts.report.output), func(w io.Writer) error {
+		return analyze.WriteDigest(w, digest, opts.report.output)
+	})
+}
+
+func runDigestPost(opts digestOptions) error {
+	ctx := context.Background()
+	digest, err := buildDigest(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	// --to may name a config channel
+	cfg, err := loadConfig()
+	if err != nil && !errors.Is(err, config.ErrNoConfig) {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	if cfg != nil {
+		if ch, ok := cfg.ChannelByName(opts.to); ok && opts.to != "" {
+			opts.to = ch.ID
+		}
+	}
+
+	title := digest.Title()
+	messages := slack.SectionMessages(title, digest.Sections())
+	if opts.dryRun {
+		payloads := make([]digestPayload, len(messages))
+		for i, blocks := range messages {
+			payloads[i] = digestPayload{Channel: opts.to, Text: title, Blocks: blocks}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(payloads)
+	}
+
+	clientOpts := []slack.Option{slack.WithLogger(logger)}
+	token, err := slackToken()
+	if opts.offlineFixture != "" {
+		api, err := fakeslack.Load(opts.offlineFixture)
+		if err != nil {
+			return err
+		}
+		clientOpts = append(clientOpts, slack.WithAPI(api))
+		if token == "" {
+			token = "xoxb-offline"
+		}
+	} else if err != nil {
+		return err
+	}
+
+	slackClient := slack.NewClient(token, clientOpts...)
+	if _, err := slackClient.ValidateAuth(ctx); err != nil {
+		return tokenRejected(err)
+	}
+
+	fmt.Println(titleStyle.Render("📰 " + title))
+	fmt.Println(dimStyle.Render(digest.Summary()))
+	for i, blocks := range messages {
+		if _, err := slackClient.PostBlocks(ctx, opts.to, title, blocks); err != nil {
+			if hint := slack.PostHint(err); hint != "" {
+				fmt.Fprintln(os.Stderr, errorStyle.Render(fmt.Sprintf("✗ Cannot post to %s: %s", opts.to, hint)))
+			}
+			return fmt.Errorf("failed to post digest message %d of %d: %w", i+1, len(messages), err)
+		}
+	}
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Posted the digest to %s in %d message(s)", opts.to, len(messages))))
+	return nil
+}
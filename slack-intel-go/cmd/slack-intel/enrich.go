@@ -0,0 +1,595 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/lockfile"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/mrkdwn"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/redact"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack/fakeslack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// enrichOptions holds the cache enrich flags
+type enrichOptions struct {
+	cachePath string
+	template  *cache.NameTemplate
+	channels  []string
+	from      time.Time // inclusive, zero when unset
+	to        time.Time // exclusive, zero when unset
+	dryRun    bool
+	workers   int
+	// offlineFixture serves Slack from a fakeslack JSON fixture (development)
+	offlineFixture string
+}
+
+func cacheEnrichCmd() *cobra.Command {
+	var (
+		opts     enrichOptions
+		from, to string
+		template string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "enrich",
+		Short: "Fill in user info and thread replies missing from cached partitions",
+		Long: `Find what a fast pass (--no-threads, --no-users, --min-reply-count) or a
+failed lookup left out of the cache and fetch just that: rows whose user
+columns are empty get the author's info, from users.parquet when known and
+users.info otherwise, and thread parents with fewer cached replies than their
+reply_count get their thread fetched again. Replies are counted across all
+of a channel's partitions. In a channel cached with exclude_bots, where bot
+replies were dropped, only parents without any cached reply count, and
+fetched threads are stored without bot messages. New partitions and
+fetched rows follow the metadata (redaction, clean_text, raw payloads) of
+the partitions they join.
+
+Changed partitions are rewritten in place, keeping their metadata; thin
+marks (see cache stats --thin) are removed once nothing is missing.
+--dry-run lists what is missing and estimates the API calls without
+contacting Slack.
+
+Examples:
+  # Cheap pass during an incident, complete pass overnight
+  slack-intel cache --hours 6 --no-threads --no-users
+  slack-intel cache enrich
+
+  # Estimate the API calls first
+  slack-intel cache enrich --dry-run --channel incidents --from 2024-05-01`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if opts.template, err = cache.ParseNameTemplate(template); err != nil {
+				return err
+			}
+			if from != "" {
+				if opts.from, err = time.Parse("2006-01-02", from); err != nil {
+					return fmt.Errorf("invalid --from date %q: %w", from, err)
+				}
+			}
+			if to != "" {
+				if opts.to, err = time.Parse("2006-01-02", to); err != nil {
+					return fmt.Errorf("invalid --to date %q: %w", to, err)
+				}
+				opts.to = opts.to.AddDate(0, 0, 1)
+			}
+			if opts.workers < 1 {
+				return fmt.Errorf("--workers must be at least 1")
+			}
+			return runCacheEnrich(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringSliceVarP(&opts.channels, "channel", "c", []string{}, "Only these channels, as named in the partition paths")
+	cmd.Flags().StringVar(&from, "from", "", "First day to enrich (YYYY-MM-DD, UTC)")
+	cmd.Flags().StringVar(&to, "to", "", "Last day to enrich (YYYY-MM-DD, UTC)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Report what is missing and the API calls needed without fetching or writing")
+	cmd.Flags().IntVar(&opts.workers, "workers", slack.DefaultWorkers, "Concurrent user-info requests")
+	cmd.Flags().StringVar(&opts.offlineFixture, "offline-fixture", "", "Serve Slack from a fakeslack JSON fixture instead of the API (development)")
+	cmd.Flags().MarkHidden("offline-fixture")
+
+	return cmd
+}
+
+// enrichChannel is one channel's partitions and the rows read from them
+type enrichChannel struct {
+	name       string
+	partitions []cache.Partition
+	// inScope marks the partitions selected by --channel, --from and --to
+	inScope  map[string]bool
+	messages map[string][]*models.SlackMessage // by partition path
+	metadata map[string]map[string]string      // by partition path
+}
+
+// all returns the messages of every partition of the channel
+func (ec *enrichChannel) all() []*models.SlackMessage {
+	var all []*models.SlackMessage
+	for _, p := range ec.partitions {
+		all = append(all, ec.messages[p.Path]...)
+	}
+	return all
+}
+
+// excludeBots reports whether a partition of the channel was written
+// without bot messages
+func (ec *enrichChannel) excludeBots() bool {
+	for _, p := range ec.partitions {
+		if ec.metadata[p.Path]["exclude_bots"] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+// missing returns the threads whose parent lies in a selected partition
+// and lacks replies, and the authors without user info in selected
+// partitions
+func (ec *enrichChannel) missing() (threads, users []string) {
+	parents := make(map[string]bool)
+	var scoped []*models.SlackMessage
+	for _, p := range ec.partitions {
+		if !ec.inScope[p.Path] {
+			continue
+		}
+		for _, msg := range ec.messages[p.Path] {
+			parents[msg.MessageID] = true
+		}
+		scoped = append(scoped, ec.messages[p.Path]...)
+	}
+	for _, ts := range cache.MissingReplies(ec.all(), ec.excludeBots()) {
+		if parents[ts] {
+			threads = append(threads, ts)
+		}
+	}
+	return threads, cache.MissingUsers(scoped)
+}
+
+// repliesPerCall is the conversations.replies page size used for threads
+const repliesPerCall = 1000
+
+// enrichTotals sums up a cache enrich run
+type enrichTotals struct {
+	threads, users, filled, rewritten, failed int
+	calls                                     int64
+}
+
+func runCacheEnrich(opts enrichOptions) error {
+	parquetCache := cache.NewParquetCache(opts.cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+	loc, err := partitionTimezone()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartitionTimezone(loc)
+	hours, err := activityHours()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetActivityHours(hours)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	storage.BindContext(ctx, store)
+
+	// Rewrites must not race a cache run on the same partitions
+	if !opts.dryRun && storageBackend == "local" {
+		lock, err := lockfile.Acquire(ctx, filepath.Join(opts.cachePath, ".lock"), 0)
+		var held *lockfile.HeldError
+		if errors.As(err, &held) {
+			return fmt.Errorf("a cache run is using %s: %w", opts.cachePath, err)
+		}
+		if err != nil {
+			return err
+		}
+		defer lock.Release()
+	}
+
+	partitions, err := parquetCache.ListPartitions()
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+	filter := cache.MessageFilter{Channels: opts.channels, From: opts.from, To: opts.to}
+	byChannel := make(map[string]*enrichChannel)
+	var names []string
+	for _, p := range partitions {
+		ec := byChannel[p.Channel]
+		if ec == nil {
+			ec = &enrichChannel{name: p.Channel, inScope: make(map[string]bool),
+				messages: make(map[string][]*models.SlackMessage), metadata: make(map[string]map[string]string)}
+			byChannel[p.Channel] = ec
+			names = append(names, p.Channel)
+		}
+		ec.partitions = append(ec.partitions, p)
+		if filter.Partition(p) {
+			ec.inScope[p.Path] = true
+		}
+	}
+	sort.Strings(names)
+
+	known, err := parquetCache.LoadUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	title := "🩹 Enriching cached partitions"
+	if opts.dryRun {
+		title += " (dry run)"
+	}
+	fmt.Println(titleStyle.Render(title))
+
+	var client *slack.Client
+	var channelIDs map[string]string
+	if !opts.dryRun {
+		if client, err = enrichClient(ctx, opts); err != nil {
+			return err
+		}
+		client.SeedUsers(known)
+		if channelIDs, err = enrichChannelIDs(ctx, parquetCache); err != nil {
+			return err
+		}
+	}
+
+	var totals enrichTotals
+	for _, name := range names {
+		ec := byChannel[name]
+		if len(ec.inScope) == 0 {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		for _, p := range ec.partitions {
+			msgs, err := parquetCache.ReadMessages(ctx, p.Path)
+			if err != nil {
+				return err
+			}
+			metadata, err := parquetCache.ReadFileMetadata(p.Path)
+			if err != nil {
+				return err
+			}
+			ec.messages[p.Path], ec.metadata[p.Path] = msgs, metadata
+		}
+
+		threads, users := ec.missing()
+		totals.threads += len(threads)
+		totals.users += len(users)
+		if opts.dryRun {
+			totals.calls += enrichEstimate(ec, threads, users, known)
+			continue
+		}
+		if len(threads) == 0 && len(users) == 0 && !enrichMarked(ec) {
+			continue
+		}
+		if err := enrichOne(ctx, parquetCache, client, ec, channelIDs, loc, threads, users, &totals); err != nil {
+			return err
+		}
+	}
+
+	if opts.dryRun {
+		fmt.Println(dimStyle.Render(fmt.Sprintf("%d thread(s) missing replies, %d user(s) without info; about %d API call(s) to fill them",
+			totals.threads, totals.users, totals.calls)))
+		return nil
+	}
+	fmt.Println(dimStyle.Render(fmt.Sprintf("Rewrote %d partition(s): %d thread(s) fetched, %d row(s) given user info, %d API call(s)",
+		totals.rewritten, totals.threads-totals.failed, totals.filled, client.APICalls())))
+	if totals.failed > 0 {
+		return fmt.Errorf("%d thread(s) could not be fetched; run cache enrich again to retry them", totals.failed)
+	}
+	return nil
+}
+
+// enrichClient creates the Slack client of a cache enrich run
+func enrichClient(ctx context.Context, opts enrichOptions) (*slack.Client, error) {
+	sources, err := ticketSources()
+	if err != nil {
+		return nil, err
+	}
+	// Payloads are kept for partitions written with --raw
+	clientOpts := []slack.Option{slack.WithLogger(logger), slack.WithWorkers(opts.workers), slack.WithTicketSources(sources),
+		slack.WithRawPayloads(true)}
+	token, err := slackToken()
+	if opts.offlineFixture != "" {
+		api, err := fakeslack.Load(opts.offlineFixture)
+		if err != nil {
+			return nil, err
+		}
+		clientOpts = append(clientOpts, slack.WithAPI(api))
+		if token == "" {
+			token = "xoxb-offline"
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	client := slack.NewClient(token, clientOpts...)
+	if _, err := client.ValidateAuth(ctx); err != nil {
+		return nil, tokenRejected(err)
+	}
+	return client, nil
+}
+
+// enrichChannelIDs maps partition channel names to channel IDs from
+// channels.parquet and the config
+func enrichChannelIDs(ctx context.Context, pc *cache.ParquetCache) (map[string]string, error) {
+	ids := make(map[string]string)
+	channels, err := pc.LoadChannels(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for id, ch := range channels {
+		ids[cache.SanitizeChannelName(ch.Name)] = id
+	}
+	if cfg, err := loadConfig(); err == nil {
+		for _, ch := range cfg.Channels {
+			ids[cache.SanitizeChannelName(ch.Name)] = ch.ID
+		}
+	}
+	return ids, nil
+}
+
+// archivesID extracts the channel ID from a message permalink
+var archivesID = regexp.MustCompile(`/archives/([CDG][A-Z0-9]+)/`)
+
+// channelID finds the ID of a partition channel: from its rows'
+// permalinks, then by name, then from a channel_<ID> or ID partition name
+func (ec *enrichChannel) channelID(ids map[string]string) string {
+	for _, msg := range ec.all() {
+		if m := archivesID.FindStringSubmatch(msg.Permalink); m != nil {
+			return m[1]
+		}
+	}
+	if id, ok := ids[ec.name]; ok {
+		return id
+	}
+	id := strings.ToUpper(strings.TrimPrefix(ec.name, "channel_"))
+	if archivesID.MatchString("/archives/" + id + "/") {
+		return id
+	}
+	return ""
+}
+
+// enrichEstimate prints what one channel is missing and returns the API
+// calls needed to fill it: a conversations.replies page per 1000 messages
+// of each thread, and a users.info call per author not in users.parquet,
+// or one users.list pass when that many make it cheaper
+func enrichEstimate(ec *enrichChannel, threads, users []string, known map[string]*models.SlackUser) int64 {
+	if len(threads) == 0 && len(users) == 0 {
+		return 0
+	}
+	replyCounts := make(map[string]int)
+	for _, msg := range ec.all() {
+		if msg.IsThreadParent() {
+			replyCounts[msg.MessageID] = msg.ReplyCount
+		}
+	}
+	var calls int64
+	for _, ts := range threads {
+		calls += int64(replyCounts[ts]/repliesPerCall + 1)
+	}
+	unknown := 0
+	for _, id := range users {
+		if known[id] == nil {
+			unknown++
+		}
+	}
+	switch {
+	case unknown >= slack.DefaultBulkUserThreshold:
+		calls++
+	default:
+		calls += int64(unknown)
+	}
+
+	fmt.Printf("  #%s: %d thread(s) missing replies, %d user(s) without info (%d not in users.parquet); ~%d API call(s)\n",
+		ec.name, len(threads), len(users), unknown, calls)
+	return calls
+}
+
+// enrichMarked reports whether a selected partition of the channel is
+// marked thin, so it is rewritten without its marks even when nothing is
+// missing any more
+func enrichMarked(ec *enrichChannel) bool {
+	for path := range ec.inScope {
+		if len(cache.Thin(ec.metadata[path])) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// enrichOne fetches what one channel is missing and rewrites its changed
+// partitions
+func enrichOne(ctx context.Context, pc *cache.ParquetCache, client *slack.Client, ec *enrichChannel, ids map[string]string,
+	loc *time.Location, threads, users []string, totals *enrichTotals) error {
+	if err := client.LookupUsers(ctx, users); errors.Is(err, slack.ErrUsersMissing) {
+		logger.Warn("failed to fetch some users", "channel", ec.name, "error", err)
+	} else if err != nil {
+		return fmt.Errorf("failed to fetch users: %w", err)
+	}
+
+	// Fetched thread messages, by the partition key they belong in
+	fresh := make(map[string][]*models.SlackMessage)
+	fetched := make(map[string]bool)
+	excludeBots := ec.excludeBots()
+	failed := 0
+	if len(threads) > 0 {
+		channelID := ec.channelID(ids)
+		if channelID == "" {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ #%s: channel ID unknown, thread replies skipped", ec.name)))
+			failed = len(threads)
+		}
+		progress := newProgressLine(os.Stdout, true)
+		progress.start(ec.name)
+		granularity := ec.partitions[0].Granularity
+		for i, ts := range threads {
+			if channelID == "" {
+				break
+			}
+			progress.update(channelID, slack.Progress{ThreadsTotal: len(threads), ThreadsDone: i})
+			thread, err := client.GetThread(ctx, channelID, ts)
+			switch {
+			case err == nil:
+			case errors.Is(err, slack.ErrAPIBudget) || ctx.Err() != nil:
+				progress.clear()
+				return err
+			case slack.IsInaccessible(err):
+				progress.clear()
+				return fmt.Errorf("#%s: %w", ec.name, err)
+			default:
+				logger.Warn("failed to fetch thread", "channel", ec.name, "thread", ts, "error", err)
+				failed++
+				continue
+			}
+			fetched[ts] = true
+			if excludeBots {
+				thread, _ = models.ExcludeBots(thread)
+			}
+			for _, msg := range thread {
+				key := granularity.PartitionKey(msg.Timestamp.In(loc))
+				fresh[key] = append(fresh[key], msg)
+			}
+		}
+		progress.clear()
+	}
+	totals.failed += failed
+
+	// Merge the thread messages and fill users, then decide per partition
+	// what is no longer missing
+	byKey := make(map[string]cache.Partition, len(ec.partitions))
+	for _, p := range ec.partitions {
+		byKey[p.Key] = p
+	}
+	changed := make(map[string]bool)
+	filled := 0
+	var created []string
+	for key, msgs := range fresh {
+		p, ok := byKey[key]
+		if !ok {
+			// A reply posted on a day with no partition yet, written like
+			// the channel's latest partition
+			like := ec.metadata[ec.partitions[len(ec.partitions)-1].Path]
+			msgs = mergeEnriched(nil, msgs, like)
+			path, err := pc.Like(like).MergeMessages(ctx, msgs, &models.SlackChannel{Name: ec.name, ID: ec.channelID(ids)}, key)
+			if err != nil {
+				return err
+			}
+			created = append(created, path)
+			continue
+		}
+		ec.messages[p.Path] = mergeEnriched(ec.messages[p.Path], msgs, ec.metadata[p.Path])
+		changed[p.Path] = true
+	}
+	for _, p := range ec.partitions {
+		if !ec.inScope[p.Path] && !changed[p.Path] {
+			continue
+		}
+		redacted := ec.metadata[p.Path]["redacted"] == "true"
+		for _, msg := range ec.messages[p.Path] {
+			if msg.UserID == "" || msg.UserInfo != nil {
+				continue
+			}
+			if u := client.GetUserInfo(msg.UserID); u != nil {
+				if redacted {
+					u = redact.User(u)
+				}
+				msg.UserInfo = u
+				filled++
+				changed[p.Path] = true
+			}
+		}
+	}
+	totals.filled += filled
+
+	// A thread fetched in full is complete, wherever its replies went
+	stillMissing := make(map[string]bool)
+	for _, ts := range cache.MissingReplies(ec.all(), excludeBots) {
+		stillMissing[ts] = !fetched[ts]
+	}
+	rewritten := len(created)
+	for _, p := range ec.partitions {
+		if !ec.inScope[p.Path] && !changed[p.Path] {
+			continue
+		}
+		var done []string
+		for _, mark := range cache.Thin(ec.metadata[p.Path]) {
+			complete := true
+			for _, msg := range ec.messages[p.Path] {
+				if mark == cache.ThinUsers && msg.UserID != "" && msg.UserInfo == nil ||
+					mark == cache.ThinThreads && stillMissing[msg.MessageID] {
+					complete = false
+					break
+				}
+			}
+			if complete {
+				done = append(done, mark)
+			}
+		}
+		if !changed[p.Path] && len(done) == 0 {
+			continue
+		}
+		if err := pc.EnrichMessages(ctx, p.Path, ec.messages[p.Path], done...); err != nil {
+			return err
+		}
+		rewritten++
+	}
+	totals.rewritten += rewritten
+
+	line := fmt.Sprintf("  ✓ #%s: %d thread(s) fetched, %d row(s) given user info, %d partition(s) rewritten",
+		ec.name, len(threads)-failed, filled, rewritten)
+	if failed > 0 {
+		line += fmt.Sprintf(", %d thread(s) failed", failed)
+	}
+	fmt.Println(successStyle.Render(line))
+	return nil
+}
+
+// mergeEnriched replaces stored rows by the fetched copies of the same
+// messages and adds the others, rendering clean_text and redacting them as
+// the partition's metadata says it was written
+func mergeEnriched(stored, fetched []*models.SlackMessage, metadata map[string]string) []*models.SlackMessage {
+	mode, _ := mrkdwn.ParseMode(metadata["clean_text"])
+	byID := make(map[string]int, len(stored))
+	for i, msg := range stored {
+		byID[msg.MessageID] = i
+	}
+	for _, msg := range fetched {
+		if metadata["redacted"] == "true" {
+			msg = redact.Message(msg)
+		}
+		if metadata["clean_text"] != "" {
+			msg.CleanText = mrkdwn.Normalize(msg.Text, mode)
+		}
+		if i, ok := byID[msg.MessageID]; ok {
+			stored[i] = msg
+			continue
+		}
+		byID[msg.MessageID] = len(stored)
+		stored = append(stored, msg)
+	}
+	return stored
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+// runEstimates forecasts each channel's fetch for the run ETA. A channel's
+// message rate comes from earlier runs (rates, per channel ID) when they
+// cached it, the default rate otherwise; a channel's rate_limit override
+// applies when lower than the global limit.
+func runEstimates(opts cacheOptions, channels []models.SlackChannel, window time.Duration, rates, limits map[string]float64, knownUsers int) map[string]slack.Estimate {
+	newUsers := float64(slack.DefaultNewUsers)
+	if knownUsers > 0 {
+		// users.parquet already holds most authors
+		newUsers = 1
+	}
+
+	estimates := make(map[string]slack.Estimate, len(channels))
+	for _, ch := range channels {
+		w := slack.Workload{
+			Window:         window,
+			MessagesPerDay: slack.DefaultMessagesPerDay,
+			ThreadShare:    slack.DefaultThreadShare,
+			NewUsers:       newUsers,
+			ThreadMode:     opts.threadMode,
+			SkipUsers:      opts.skipUsers,
+			BulkUsers:      opts.bulkUsers,
+			Workers:        opts.workers,
+		}
+		if rate, ok := rates[ch.ID]; ok {
+			w.MessagesPerDay = rate
+		}
+		if days := window.Hours() / 24; opts.maxMessages > 0 && w.MessagesPerDay*days > float64(opts.maxMessages) {
+			w.MessagesPerDay = float64(opts.maxMessages) / days
+		}
+
+		perSecond := float64(slack.DefaultRateLimit)
+		if limit, ok := limits[ch.ID]; ok && limit < perSecond {
+			perSecond = limit
+		}
+		estimates[ch.ID] = slack.EstimateChannel(w, perSecond)
+	}
+	return estimates
+}
+
+// formatETA renders an estimated duration coarsely: seconds under a
+// minute, minutes under an hour, then hours and minutes
+func formatETA(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return fmt.Sprintf("%ds", int(d.Round(time.Second).Seconds()))
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d.Round(time.Minute).Minutes()))
+	default:
+		minutes := int(d.Round(time.Minute).Minutes())
+		return fmt.Sprintf("%dh%02dm", minutes/60, minutes%60)
+	}
+}
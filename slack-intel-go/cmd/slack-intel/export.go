@@ -0,0 +1,323 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/redact"
+)
+
+// exportOptions holds the export flags
+type exportOptions struct {
+	cachePath     string
+	template      *cache.NameTemplate
+	user          string
+	from          time.Time // inclusive, zero when unset
+	to            time.Time // exclusive, zero when unset
+	format        string
+	threadContext bool
+	userDetails   bool
+	resolveEmoji  bool
+	flags         cache.MessageFlags // --has-files, --has-reactions, --has-thread
+	// anonymizeKey keys the pseudonyms of --anonymize; nil when off
+	anonymizeKey []byte
+	mappingOut   string
+	allowMixed   bool
+	artifacts    artifactOptions
+}
+
+// exportRecord is one exported message; ThreadParent is set for replies
+// with --with-thread-context when the parent is cached. Emoji maps the
+// custom emoji in the text and every reaction to an image URL or unicode
+// character with --resolve-emoji.
+type exportRecord struct {
+	Channel string `json:"channel"`
+	*models.SlackMessage
+	ThreadParent *models.SlackMessage `json:"thread_parent,omitempty"`
+	Emoji        map[string]string    `json:"emoji,omitempty"`
+}
+
+func exportCmd() *cobra.Command {
+	var (
+		opts         exportOptions
+		from, to     string
+		template     string
+		anonymize    bool
+		anonymizeKey string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export cached messages, all or one user's",
+		Long: `Scan every cached channel and partition and write the messages to stdout
+oldest first, one JSON object per line (ndjson) or as one JSON array.
+--user limits the export to one user, e.g. for an offboarding or data
+subject access request; it takes a user ID, @name or an email address,
+names and emails being looked up in users.parquet.
+
+With --with-thread-context each reply also carries its thread's parent
+message, even when the parent is older than --from.
+--with-user-details replaces each author's user_info with their record in
+users.parquet, adding title, timezone (tz, tz_offset) and image_192; it
+cannot be combined with --anonymize.
+
+--resolve-emoji turns standard emoji shortcodes in the text (":tada:")
+into unicode characters and adds an "emoji" object mapping custom emoji and
+reactions to their image URL or character, using the custom emoji that
+cache --resolve-emoji keeps in emoji.parquet.
+
+--has-files, --has-reactions and --has-thread keep only messages with
+files, with reactions or starting a thread with replies, all of them when
+combined. They read the cache's boolean columns without scanning text;
+thread context parents are attached whatever their flags.
+
+With --anonymize user IDs and names become pseudonyms (an HMAC of the ID
+keyed with --anonymize-key, so exports made with the same key join up),
+emails and phone numbers in the text are masked and file URLs dropped.
+--mapping-out writes the pseudonym to user ID table to a local file.
+
+--output-dir writes export.ndjson (or export.json) there instead of stdout;
+an earlier export is kept and the new file gets a timestamp unless --force.
+
+Examples:
+  slack-intel export --user U04ABCDE --from 2023-01-01 --to 2024-06-01 > u04abcde.ndjson
+  slack-intel export --user alice@example.com --with-thread-context --format json
+  slack-intel export --from 2024-06-01 --with-user-details > with-timezones.ndjson
+  slack-intel export --from 2024-06-01 --resolve-emoji > readable.ndjson
+  slack-intel export --from 2024-01-01 --anonymize --anonymize-key "$KEY" --mapping-out mapping.json > vendor.ndjson
+  slack-intel export --from 2024-06-01 --output-dir exports
+  slack-intel export --has-files --from 2024-06-01 > shared-files.ndjson`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if opts.template, err = cache.ParseNameTemplate(template); err != nil {
+				return err
+			}
+			if from != "" {
+				if opts.from, err = time.Parse("2006-01-02", from); err != nil {
+					return fmt.Errorf("invalid --from date %q: %w", from, err)
+				}
+			}
+			if to != "" {
+				if opts.to, err = time.Parse("2006-01-02", to); err != nil {
+					return fmt.Errorf("invalid --to date %q: %w", to, err)
+				}
+				opts.to = opts.to.AddDate(0, 0, 1)
+			}
+			if opts.format != "ndjson" && opts.format != "json" {
+				return fmt.Errorf("unknown format %q (want ndjson or json)", opts.format)
+			}
+			switch {
+			case anonymize && opts.userDetails:
+				return fmt.Errorf("--with-user-details cannot be combined with --anonymize")
+			case anonymize && anonymizeKey == "":
+				return fmt.Errorf("--anonymize needs --anonymize-key")
+			case anonymize:
+				opts.anonymizeKey = []byte(anonymizeKey)
+			case anonymizeKey != "" || opts.mappingOut != "":
+				return fmt.Errorf("--anonymize-key and --mapping-out need --anonymize")
+			}
+			return runExport(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringVar(&opts.user, "user", "", "Only this user's messages: user ID, @name or email address")
+	cmd.Flags().StringVar(&from, "from", "", "First day to include (YYYY-MM-DD, UTC)")
+	cmd.Flags().StringVar(&to, "to", "", "Last day to include (YYYY-MM-DD, UTC)")
+	cmd.Flags().StringVar(&opts.format, "format", "ndjson", "Output format: ndjson or json")
+	cmd.Flags().BoolVar(&opts.threadContext, "with-thread-context", false, "Include the parent message of each reply")
+	cmd.Flags().BoolVar(&opts.userDetails, "with-user-details", false, "Include author title, timezone and avatar from users.parquet")
+	cmd.Flags().BoolVar(&opts.resolveEmoji, "resolve-emoji", false, "Translate emoji to unicode or custom emoji image URLs using emoji.parquet")
+	addMessageFlags(cmd, &opts.flags)
+	cmd.Flags().BoolVar(&anonymize, "anonymize", false, "Replace users with pseudonyms and mask emails and phone numbers")
+	cmd.Flags().StringVar(&anonymizeKey, "anonymize-key", "", "Secret keying the --anonymize pseudonyms")
+	cmd.Flags().StringVar(&opts.mappingOut, "mapping-out", "", "Write the pseudonym to user ID mapping to this file")
+	cmd.Flags().BoolVar(&opts.allowMixed, "allow-mixed-schemas", false, "Read partitions written with different schema versions together")
+	opts.artifacts.addFlags(cmd)
+
+	return cmd
+}
+
+func runExport(opts exportOptions) error {
+	ctx := context.Background()
+
+	parquetCache := cache.NewParquetCache(opts.cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+	loc, err := partitionTimezone()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartitionTimezone(loc)
+	parquetCache.SetAllowMixedSchemas(opts.allowMixed)
+
+	users, err := parquetCache.LoadUsers(ctx)
+	switch {
+	case err != nil && opts.userDetails:
+		return fmt.Errorf("failed to load users for --with-user-details: %w", err)
+	case err != nil:
+		logger.Warn("ignoring cached users", "error", err)
+	}
+	var userID string
+	if opts.user != "" {
+		if userID, err = models.FindUser(users, opts.user); err != nil {
+			return err
+		}
+	}
+
+	// Thread parents may predate --from, so context needs everything before it
+	readFrom := opts.from
+	if opts.threadContext {
+		readFrom = time.Time{}
+	}
+	order, byChannel, err := readCached(ctx, parquetCache, cache.MessageFilter{From: readFrom, To: opts.to, Flags: opts.flags})
+	if err != nil {
+		return err
+	}
+	// Parents come from every message, not only those with the flags
+	threads := byChannel
+	if opts.threadContext && opts.flags != (cache.MessageFlags{}) {
+		if _, threads, err = readCached(ctx, parquetCache, cache.MessageFilter{To: opts.to}); err != nil {
+			return err
+		}
+	}
+
+	if opts.userDetails {
+		addUserDetails(byChannel, users)
+		addUserDetails(threads, users)
+	}
+
+	var records []exportRecord
+	channels := 0
+	for _, channel := range order {
+		parents := make(map[string]*models.SlackMessage)
+		if opts.threadContext {
+			for _, msg := range threads[channel] {
+				if msg.ThreadTS != "" && msg.ThreadTS == msg.MessageID {
+					parents[msg.MessageID] = msg
+				}
+			}
+		}
+
+		found := 0
+		for _, msg := range byChannel[channel] {
+			if (userID != "" && msg.UserID != userID) || (!opts.from.IsZero() && msg.Timestamp.Before(opts.from)) {
+				continue
+			}
+			record := exportRecord{Channel: channel, SlackMessage: msg}
+			if msg.IsThreadReply() {
+				record.ThreadParent = parents[msg.ThreadTS]
+			}
+			records = append(records, record)
+			found++
+		}
+		if found > 0 {
+			channels++
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].Timestamp.Before(records[j].Timestamp)
+	})
+
+	if opts.resolveEmoji {
+		emoji, err := parquetCache.LoadEmoji(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load emoji for --resolve-emoji: %w", err)
+		}
+		for i := range records {
+			records[i].Emoji = resolveEmoji(emoji, records[i].SlackMessage)
+			if parent := records[i].ThreadParent; parent != nil {
+				parent.Text, _ = emoji.ExpandText(parent.Text)
+			}
+		}
+	}
+
+	var anonymizer *redact.Anonymizer
+	if opts.anonymizeKey != nil {
+		anonymizer = redact.NewAnonymizer(opts.anonymizeKey)
+		for i := range records {
+			records[i].SlackMessage = anonymizer.Message(records[i].SlackMessage)
+			records[i].ThreadParent = anonymizer.Message(records[i].ThreadParent)
+		}
+	}
+
+	err = writeArtifact(opts.artifacts, "export", opts.format, func(w io.Writer) error {
+		if opts.format == "json" {
+			return writeJSON(w, records)
+		}
+		enc := json.NewEncoder(w)
+		for _, record := range records {
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	who := "everyone"
+	switch {
+	case userID != "" && anonymizer != nil:
+		who = anonymizer.Pseudonym(userID)
+	case userID != "":
+		who = userID
+	}
+	fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("Exported %d message(s) by %s from %d channel(s)", len(records), who, channels)))
+
+	if opts.mappingOut != "" {
+		if err := writeMapping(opts.mappingOut, anonymizer.Mapping()); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, dimStyle.Render(fmt.Sprintf("Wrote %d pseudonym(s) to %s; keep it out of the shared dataset", len(anonymizer.Mapping()), opts.mappingOut)))
+	}
+	return nil
+}
+
+// resolveEmoji expands the standard emoji in msg's text in place and
+// returns the image URL or character of its custom emoji and reactions
+func resolveEmoji(emoji models.EmojiMap, msg *models.SlackMessage) map[string]string {
+	var resolved map[string]string
+	msg.Text, resolved = emoji.ExpandText(msg.Text)
+	for _, reaction := range msg.Reactions {
+		if v, ok := emoji.Resolve(reaction.Emoji); ok {
+			if resolved == nil {
+				resolved = make(map[string]string)
+			}
+			resolved[models.BaseEmoji(reaction.Emoji)] = v
+		}
+	}
+	return resolved
+}
+
+// writeMapping saves pseudonym → user ID as JSON, readable only by the owner
+func writeMapping(path string, mapping map[string]string) error {
+	data, err := json.MarshalIndent(mapping, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode mapping: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write mapping: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/config"
+)
+
+// gapsOptions holds the cache gaps flags
+type gapsOptions struct {
+	cachePath string
+	template  *cache.NameTemplate
+	channels  []string
+	from      time.Time // inclusive, zero when unset
+	to        time.Time // exclusive, zero when unset
+	deep      bool
+	output    string
+}
+
+// gapRecord is one gap in JSON output
+type gapRecord struct {
+	Channel      string `json:"channel"`
+	From         string `json:"from"`
+	To           string `json:"to"`
+	Days         int    `json:"days"`
+	Reason       string `json:"reason"`
+	FirstMessage string `json:"first_message,omitempty"`
+	LastMessage  string `json:"last_message,omitempty"`
+	LastFetched  string `json:"last_fetched,omitempty"`
+	// Explanation is what the run manifest recorded for missing days
+	Explanation string `json:"explanation,omitempty"`
+}
+
+// gapsReport is the JSON output of cache gaps
+type gapsReport struct {
+	Gaps     []gapRecord `json:"gaps"`
+	Commands []string    `json:"commands"`
+}
+
+func cacheGapsCmd() *cobra.Command {
+	var (
+		opts     gapsOptions
+		from, to string
+		template string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "gaps",
+		Short: "List days missing from the cache and the commands to backfill them",
+		Long: `List the days in a range that have no partition, per cached channel,
+and print cache commands that fetch them again. Days on which a channel had
+no messages have no partition either, so quiet channels show up too.
+
+--deep also reads the partitions that exist and reports days whose messages
+were all fetched before the day ended (the run stopped covering the channel
+mid-day), with the first and last message cached for them.
+
+Days are calendar days in storage.partition_timezone (UTC by default).
+Without --from each channel is checked from its first partition; --to
+defaults to yesterday. JSON output lists gaps and commands for cron jobs:
+
+  slack-intel cache gaps -o json | jq -e '.gaps == []'
+
+Examples:
+  slack-intel cache gaps --channel backend --from 2024-01-01
+  slack-intel cache gaps --deep --from 2024-06-01 --to 2024-06-30`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if opts.template, err = cache.ParseNameTemplate(template); err != nil {
+				return err
+			}
+			if from != "" {
+				if opts.from, err = time.Parse("2006-01-02", from); err != nil {
+					return fmt.Errorf("invalid --from date %q: %w", from, err)
+				}
+			}
+			if to != "" {
+				if opts.to, err = time.Parse("2006-01-02", to); err != nil {
+					return fmt.Errorf("invalid --to date %q: %w", to, err)
+				}
+				opts.to = opts.to.AddDate(0, 0, 1)
+			}
+			if opts.output != "text" && opts.output != "json" {
+				return fmt.Errorf("unknown output format %q (want text or json)", opts.output)
+			}
+			return runCacheGaps(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringSliceVarP(&opts.channels, "channel", "c", []string{}, "Only these channels, as named in the partition paths")
+	cmd.Flags().StringVar(&from, "from", "", "First day to check (YYYY-MM-DD)")
+	cmd.Flags().StringVar(&to, "to", "", "Last day to check (YYYY-MM-DD)")
+	cmd.Flags().BoolVar(&opts.deep, "deep", false, "Also report days whose messages were fetched before the day ended")
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "text", "Output format: text or json")
+
+	return cmd
+}
+
+func runCacheGaps(opts gapsOptions) error {
+	ctx := context.Background()
+
+	parquetCache := cache.NewParquetCache(opts.cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+	loc, err := partitionTimezone()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartitionTimezone(loc)
+
+	// --from and --to name days in the partition time zone
+	filter := cache.MessageFilter{Channels: opts.channels, From: inZone(opts.from, loc), To: inZone(opts.to, loc)}
+	gaps, err := parquetCache.FindGaps(ctx, filter, opts.deep)
+	if err != nil {
+		return fmt.Errorf("failed to scan partitions: %w", err)
+	}
+
+	// The run manifest explains why days are missing
+	runs, err := parquetCache.LoadRuns()
+	if err != nil {
+		logger.Warn("ignoring run manifest", "error", err)
+	}
+	explain := func(g cache.Gap) string {
+		if g.Reason != cache.GapMissing {
+			return ""
+		}
+		return cache.ExplainGap(runs, g)
+	}
+
+	// Config names let backfill commands write to the same partitions
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Debug("backfill commands without config", "error", err)
+		cfg = &config.Config{}
+	}
+	commands := backfillCommands(gaps, cfg, opts, time.Now().In(loc))
+
+	if opts.output == "json" {
+		report := gapsReport{Gaps: []gapRecord{}, Commands: commands}
+		for _, g := range gaps {
+			report.Gaps = append(report.Gaps, gapRecord{
+				Channel:      g.Channel,
+				From:         g.From.Format("2006-01-02"),
+				To:           g.To.Format("2006-01-02"),
+				Days:         g.Days(),
+				Reason:       g.Reason,
+				FirstMessage: formatOptionalTime(g.FirstMessage),
+				LastMessage:  formatOptionalTime(g.LastMessage),
+				LastFetched:  formatOptionalTime(g.LastFetched),
+				Explanation:  explain(g),
+			})
+		}
+		if report.Commands == nil {
+			report.Commands = []string{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	fmt.Println(titleStyle.Render("🕳️  Cache gaps"))
+	if len(gaps) == 0 {
+		fmt.Println(successStyle.Render("✓ No gaps"))
+		return nil
+	}
+	days := 0
+	for _, g := range gaps {
+		days += g.Days()
+		span := g.From.Format("2006-01-02")
+		if g.Days() > 1 {
+			span += " → " + g.To.Format("2006-01-02")
+		}
+		if g.Reason == cache.GapPartial {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("  ~ #%s %s: partial, last fetched %s, messages %s to %s", g.Channel, span,
+				g.LastFetched.Format("15:04"), g.FirstMessage.Format("15:04"), g.LastMessage.Format("15:04"))))
+			continue
+		}
+		fmt.Println(errorStyle.Render(fmt.Sprintf("  ✗ #%s %s: %s (%d day(s))", g.Channel, span, g.Reason, g.Days())))
+		fmt.Println(dimStyle.Render("      " + explain(g)))
+	}
+	fmt.Println(dimStyle.Render(fmt.Sprintf("%d gap(s) covering %d day(s)", len(gaps), days)))
+
+	fmt.Println()
+	fmt.Println("Backfill with:")
+	for _, c := range commands {
+		fmt.Println("  " + c)
+	}
+	return nil
+}
+
+// backfillCommands returns one cache command per channel with gaps,
+// reaching back to its oldest gap. Channels cached by ID (channel_<id>)
+// are passed by ID, others by the config name that sanitizes to their
+// directory so the partitions land in the same place.
+func backfillCommands(gaps []cache.Gap, cfg *config.Config, opts gapsOptions, now time.Time) []string {
+	oldest := make(map[string]time.Time)
+	var order []string
+	for _, g := range gaps {
+		at, seen := oldest[g.Channel]
+		if !seen {
+			order = append(order, g.Channel)
+		}
+		if !seen || g.From.Before(at) {
+			oldest[g.Channel] = g.From
+		}
+	}
+
+	today := cache.GranularityDay.Start(now)
+	var commands []string
+	for _, channel := range order {
+		ref := channel
+		if id := strings.ToUpper(strings.TrimPrefix(channel, "channel_")); id != strings.ToUpper(channel) && config.ValidChannelID(id) {
+			ref = id
+		} else if name, ok := configChannelName(cfg, channel); ok {
+			ref = name
+			if name != channel {
+				ref = "'" + strings.ReplaceAll(name, "'", `'\''`) + "'"
+			}
+		} else {
+			logger.Warn("channel is not in the config; its backfill command needs editing", "channel", channel)
+		}
+		days := int(today.Sub(oldest[channel]).Round(24*time.Hour).Hours()/24) + 1
+
+		command := fmt.Sprintf("slack-intel cache --channel %s --days %d --stream-partitions", ref, days)
+		if opts.cachePath != "cache/raw" {
+			command += " --cache-path " + opts.cachePath
+		}
+		if opts.template.String() != cache.DefaultNameTemplate {
+			command += fmt.Sprintf(" --name-template '%s'", opts.template)
+		}
+		commands = append(commands, command)
+	}
+	return commands
+}
+
+// configChannelName finds the configured channel whose sanitized name is
+// the partition directory name channel
+func configChannelName(cfg *config.Config, channel string) (string, bool) {
+	for _, ch := range cfg.Channels {
+		if cache.SanitizeChannelName(ch.Name) == channel {
+			return ch.Name, true
+		}
+	}
+	return "", false
+}
+
+// inZone moves a parsed date to the same wall-clock time in loc; zero
+// stays zero
+func inZone(t time.Time, loc *time.Location) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+}
+
+// formatOptionalTime formats t as RFC 3339, or "" when it is zero
+func formatOptionalTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
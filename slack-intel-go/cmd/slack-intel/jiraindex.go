@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func cacheJiraIndexCmd() *cobra.Command {
+	var (
+		cachePath string
+		template  string
+		tickets   []string
+		artifacts artifactOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "jira-index",
+		Short: "Rebuild the JIRA ticket index from the cached messages",
+		Long: `Aggregate the jira_tickets of every cached message, across all channels
+and schema versions, into jira_index.parquet beside users.parquet in the cache directory: one row
+per ticket with its mention count, the channels, message IDs and users that
+mentioned it and when it was first and last seen. cache --jira-index does
+the same after every run.
+
+--ticket also prints where the given tickets were discussed. --output-dir
+also writes the index as jira-index.json, sorted by ticket, for tools that
+do not read Parquet.
+
+Examples:
+  slack-intel cache jira-index
+  slack-intel cache jira-index --ticket PROJ-123
+  slack-intel cache jira-index --output-dir reports`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nameTemplate, err := cache.ParseNameTemplate(template)
+			if err != nil {
+				return err
+			}
+			return runCacheJiraIndex(cachePath, nameTemplate, tickets, artifacts)
+		},
+	}
+
+	cmd.Flags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringSliceVarP(&tickets, "ticket", "t", []string{}, "Print where these tickets were mentioned")
+	artifacts.addFlags(cmd)
+	cmd.Flags().Lookup("output-dir").Usage = "Also write the index as JSON to this directory"
+
+	return cmd
+}
+
+func runCacheJiraIndex(cachePath string, template *cache.NameTemplate, tickets []string, artifacts artifactOptions) error {
+	ctx := context.Background()
+
+	parquetCache := cache.NewParquetCache(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+
+	fmt.Println(titleStyle.Render("🎫 Indexing JIRA tickets"))
+
+	index, err := parquetCache.BuildJiraIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to scan partitions: %w", err)
+	}
+	if len(index) == 0 {
+		fmt.Println(dimStyle.Render("No JIRA tickets in the cache"))
+	} else {
+		indexPath, err := parquetCache.SaveJiraIndex(index)
+		if err != nil {
+			return err
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("✓ Indexed %d ticket(s) to %s", len(index), filepath.Base(indexPath))))
+	}
+
+	if artifacts.dir != "" {
+		mentions := make([]*models.JiraMentions, 0, len(index))
+		for _, j := range index {
+			mentions = append(mentions, j)
+		}
+		sort.Slice(mentions, func(a, b int) bool { return mentions[a].TicketID < mentions[b].TicketID })
+		err := writeArtifact(artifacts, "jira-index", "json", func(w io.Writer) error {
+			return writeJSON(w, mentions)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ticket := range tickets {
+		fmt.Println()
+		j, ok := index[strings.ToUpper(ticket)]
+		if !ok {
+			fmt.Println(dimStyle.Render(fmt.Sprintf("%s: not mentioned", ticket)))
+			continue
+		}
+		fmt.Printf("%s: %d mention(s) by %d user(s), %s to %s\n", j.TicketID, j.MentionCount, len(j.UserIDs),
+			j.FirstSeen.Format("2006-01-02 15:04"), j.LastSeen.Format("2006-01-02 15:04"))
+		fmt.Printf("  Channels: %s\n", strings.Join(j.Channels, ", "))
+		fmt.Println(dimStyle.Render("  Messages: " + strings.Join(j.MessageIDs, ", ")))
+	}
+	return nil
+}
+
+// rebuildJiraIndex rewrites jira_index.parquet from every cached message,
+// returning its path ("" when the cache mentions no tickets) and the number
+// of tickets indexed
+func rebuildJiraIndex(ctx context.Context, parquetCache *cache.ParquetCache) (string, int, error) {
+	index, err := parquetCache.BuildJiraIndex(ctx)
+	if err != nil {
+		return "", 0, err
+	}
+	path, err := parquetCache.SaveJiraIndex(index)
+	return path, len(index), err
+}
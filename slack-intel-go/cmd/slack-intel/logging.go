@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/redact"
+)
+
+// secrets are masked in all log output; slackToken registers the token
+var secrets = &redact.Secrets{}
+
+// logger is built from --log-level and --log-format before any command runs
+var logger = slog.New(redact.NewLogHandler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}), secrets))
+
+// quiet and verbose are the persistent --quiet and --verbose flags
+var quiet, verbose bool
+
+// effectiveLogLevel is --log-level, or error with --quiet and debug with
+// --verbose when --log-level was not given
+func effectiveLogLevel(level string, explicit bool) string {
+	switch {
+	case explicit:
+		return level
+	case quiet:
+		return "error"
+	case verbose:
+		return "debug"
+	}
+	return level
+}
+
+// newLogger builds a stderr logger for the given level and format that
+// masks the registered secrets
+func newLogger(level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	switch format {
+	case "text":
+		return slog.New(redact.NewLogHandler(slog.NewTextHandler(os.Stderr, opts), secrets)), nil
+	case "json":
+		return slog.New(redact.NewLogHandler(slog.NewJSONHandler(os.Stderr, opts), secrets)), nil
+	}
+	return nil, fmt.Errorf("invalid log format %q (expected text or json)", format)
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+func cacheMigrateCmd() *cobra.Command {
+	var (
+		cachePath string
+		template  string
+		dryRun    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade cached files to the current schema version",
+		Long: `Rewrite partitions and users.parquet written by older versions in the
+current schema, in place and without calling Slack. Columns added since a
+file was written are left empty, apart from urls, which are extracted from
+the stored text; fetched_at becomes the time of migration.
+
+users.parquet, channels.parquet and the other cache-wide files used to
+live beside the cache directory (cache/users.parquet for cache/raw); they
+are read from there until migrate moves them into it.
+
+query, export and report refuse to read partitions of different schema
+versions together unless given --allow-mixed-schemas; migrating the cache
+lifts that. Use cache reprocess to also re-derive the existing columns.
+
+Examples:
+  slack-intel cache migrate --dry-run
+  slack-intel cache migrate --cache-path cache/raw`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nameTemplate, err := cache.ParseNameTemplate(template)
+			if err != nil {
+				return err
+			}
+			return runCacheMigrate(cachePath, nameTemplate, dryRun)
+		},
+	}
+
+	cmd.Flags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "List the files that would be migrated without rewriting them")
+
+	return cmd
+}
+
+func runCacheMigrate(cachePath string, template *cache.NameTemplate, dryRun bool) error {
+	ctx := context.Background()
+
+	parquetCache := cache.NewParquetCache(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+	hours, err := activityHours()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetActivityHours(hours)
+	sources, err := ticketSources()
+	if err != nil {
+		return err
+	}
+	rederive := func(msg *models.SlackMessage) *models.SlackMessage { return slack.Rederive(msg, sources) }
+
+	partitions, err := parquetCache.ListPartitions()
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	title := fmt.Sprintf("🧱 Migrating cache to schema version %s", cache.SchemaVersion)
+	if dryRun {
+		title += " (dry run)"
+	}
+	fmt.Println(titleStyle.Render(title))
+
+	migrated := 0
+	for _, p := range partitions {
+		if dryRun {
+			metadata, err := parquetCache.ReadFileMetadata(p.Path)
+			if err != nil {
+				return err
+			}
+			if from := metadata["schema_version"]; from != cache.SchemaVersion {
+				migrated++
+				fmt.Println(successStyle.Render(fmt.Sprintf("  ✓ %s: schema %s → %s", filepath.Dir(p.Path), versionLabel(from), cache.SchemaVersion)))
+			}
+			continue
+		}
+
+		from, ok, err := parquetCache.MigrateMessages(ctx, p.Path, rederive)
+		if err != nil {
+			return fmt.Errorf("failed to migrate %s: %w", p.Path, err)
+		}
+		if ok {
+			migrated++
+			fmt.Println(successStyle.Render(fmt.Sprintf("  ✓ %s: schema %s → %s", filepath.Dir(p.Path), versionLabel(from), cache.SchemaVersion)))
+		}
+	}
+
+	var legacy []string
+	if dryRun {
+		legacy, err = parquetCache.LegacyFiles()
+	} else {
+		legacy, err = parquetCache.MoveLegacyFiles()
+	}
+	for _, path := range legacy {
+		fmt.Println(successStyle.Render(fmt.Sprintf("  ✓ %s → %s", parquetCache.LegacyPath(path), path)))
+	}
+	if err != nil {
+		return err
+	}
+
+	if !dryRun {
+		from, ok, err := parquetCache.MigrateUsers(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to migrate users: %w", err)
+		}
+		if ok {
+			fmt.Println(successStyle.Render(fmt.Sprintf("  ✓ %s: schema %s → %s", parquetCache.UsersPath(), versionLabel(from), cache.SchemaVersion)))
+		}
+	}
+
+	verb := "Migrated"
+	if dryRun {
+		verb = "Would migrate"
+	}
+	fmt.Println(dimStyle.Render(fmt.Sprintf("%s %d of %d partition(s)", verb, migrated, len(partitions))))
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+func pinsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "pins",
+		Short: "Inspect pinned messages",
+	}
+
+	var channelID string
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the messages pinned in a channel",
+		Long: `List the messages pinned in a channel using pins.list (requires the pins:read scope).
+
+Examples:
+  slack-intel pins list --channel C9876543210`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPinsList(channelID)
+		},
+	}
+	listCmd.Flags().StringVarP(&channelID, "channel", "c", "", "Channel ID")
+	listCmd.MarkFlagRequired("channel")
+
+	cmd.AddCommand(listCmd)
+	return cmd
+}
+
+func runPinsList(channelID string) error {
+	token, err := slackToken()
+	if err != nil {
+		return err
+	}
+
+	slackClient := slack.NewClient(token, slack.WithLogger(logger))
+	pins, err := slackClient.ListPins(context.Background(), channelID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("📌 Pinned in %s", channelID)))
+	if len(pins) == 0 {
+		fmt.Println(dimStyle.Render("⚠ No pinned messages"))
+		return nil
+	}
+
+	for _, msg := range pins {
+		text := strings.ReplaceAll(msg.Text, "\n", " ")
+		if runes := []rune(text); len(runes) > 100 {
+			text = string(runes[:97]) + "..."
+		}
+		fmt.Printf("%s %s\n",
+			dimStyle.Render(msg.Timestamp.Format("2006-01-02 15:04")),
+			text)
+	}
+	fmt.Println()
+	fmt.Println(dimStyle.Render(fmt.Sprintf("%d pinned message(s)", len(pins))))
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"syscall"
+)
+
+// profiler writes the pprof profiles requested with the hidden
+// --cpuprofile and --memprofile flags
+type profiler struct {
+	cpuPath, memPath string
+	cpu              *os.File
+	once             sync.Once
+	signals          chan os.Signal
+}
+
+// startProfiling starts CPU profiling to cpuPath and arranges for a heap
+// profile to be written to memPath when the profiler stops; either path
+// may be empty. A SIGINT or SIGTERM stops the profiler before the signal
+// takes its usual course, so profiles of interrupted runs are complete.
+func startProfiling(cpuPath, memPath string) (*profiler, error) {
+	p := &profiler{cpuPath: cpuPath, memPath: memPath}
+	if cpuPath != "" {
+		f, err := os.Create(cpuPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create CPU profile: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to start CPU profile: %w", err)
+		}
+		p.cpu = f
+	}
+	if cpuPath == "" && memPath == "" {
+		return p, nil
+	}
+
+	p.signals = make(chan os.Signal, 1)
+	signal.Notify(p.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-p.signals
+		p.stop()
+		// Deliver the signal again: commands that handle it shut down
+		// gracefully, the rest get the default behaviour back
+		if self, err := os.FindProcess(os.Getpid()); err == nil {
+			self.Signal(sig)
+		}
+	}()
+	return p, nil
+}
+
+// stop finishes the CPU profile and writes the heap profile; later calls
+// do nothing
+func (p *profiler) stop() {
+	if p == nil {
+		return
+	}
+	p.once.Do(func() {
+		if p.signals != nil {
+			signal.Stop(p.signals)
+		}
+		if p.cpu != nil {
+			pprof.StopCPUProfile()
+			if err := p.cpu.Close(); err != nil {
+				logger.Warn("failed to write CPU profile", "path", p.cpuPath, "error", err)
+			}
+		}
+		if p.memPath != "" {
+			if err := writeHeapProfile(p.memPath); err != nil {
+				logger.Warn("failed to write memory profile", "path", p.memPath, "error", err)
+			}
+		}
+	})
+}
+
+// writeHeapProfile writes a heap profile to path: live objects after a GC
+// and everything allocated during the run
+func writeHeapProfile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
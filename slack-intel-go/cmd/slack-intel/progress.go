@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+// progressLogInterval throttles progress lines when output is not a terminal
+const progressLogInterval = 5 * time.Second
+
+// progressLine renders GetMessages progress for the channel being fetched.
+// On a terminal it rewrites a single line in place; otherwise it prints a
+// plain line at most every progressLogInterval.
+type progressLine struct {
+	mu      sync.Mutex
+	w       io.Writer
+	tty     bool
+	name    string
+	last    time.Time
+	drawn   bool
+	enabled bool
+	// eta, when set, is updated with every step and its time left shown
+	eta *slack.ETA
+}
+
+func newProgressLine(w io.Writer, enabled bool) *progressLine {
+	return &progressLine{w: w, tty: isTerminal(w), enabled: enabled}
+}
+
+// start begins tracking a new channel
+func (p *progressLine) start(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.name = name
+	p.last = time.Now()
+}
+
+// update is a slack.ProgressFunc
+func (p *progressLine) update(_ string, prog slack.Progress) {
+	if !p.enabled {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	status := fmt.Sprintf("%d page(s), %d messages", prog.Pages, prog.Messages)
+	if prog.ThreadsTotal > 0 {
+		status += fmt.Sprintf(", %d thread(s) remaining", prog.ThreadsRemaining())
+	}
+	if p.eta != nil {
+		now := time.Now()
+		p.eta.Update(prog, now)
+		status += fmt.Sprintf(", run ~%s left", formatETA(p.eta.Remaining(now)))
+	}
+
+	if p.tty {
+		fmt.Fprintf(p.w, "\r\033[K%s", dimStyle.Render("  ⏳ "+status))
+		p.drawn = true
+		return
+	}
+
+	if time.Since(p.last) >= progressLogInterval {
+		fmt.Fprintf(p.w, "  … %s: %s\n", p.name, status)
+		p.last = time.Now()
+	}
+}
+
+// clear erases the in-place line before the channel result is printed
+func (p *progressLine) clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.drawn {
+		fmt.Fprint(p.w, "\r\033[K")
+		p.drawn = false
+	}
+}
+
+// isTerminal reports whether w is a character device such as a TTY
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// queryOptions holds the query flags
+type queryOptions struct {
+	cachePath string
+	template  *cache.NameTemplate
+	channels  []string
+	from      time.Time // inclusive, zero when unset
+	to        time.Time // exclusive, zero when unset
+	threads   bool
+	flags     cache.MessageFlags // --has-files, --has-reactions, --has-thread
+	output    string
+	// userDetails attaches each author's users.parquet record
+	userDetails bool
+	// allowMixed reads partitions of differing schema versions together
+	allowMixed bool
+	artifacts  artifactOptions
+}
+
+// queryMessage is one message in flat JSON output
+type queryMessage struct {
+	Channel string `json:"channel"`
+	*models.SlackMessage
+}
+
+// queryThread is one thread in --threads JSON output
+type queryThread struct {
+	Channel string `json:"channel"`
+	*models.Thread
+}
+
+func queryCmd() *cobra.Command {
+	var (
+		opts     queryOptions
+		from, to string
+		template string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "query",
+		Short: "Read cached messages back",
+		Long: `Read messages from the Parquet cache, optionally limited to channels and
+a date range. With --threads replies are grouped under their parent and
+printed indented; replies whose parent is outside the range get a
+placeholder parent. --has-files, --has-reactions and --has-thread keep
+only messages with files, with reactions or starting a thread with
+replies; combined, a message must match all of them. They read the
+cache's boolean columns, skipping row groups without a match.
+
+--with-user-details attaches each author's record from users.parquet,
+including title, timezone and avatar URL, to JSON output and prints the
+title and timezone after the name in text output.
+
+--output-dir saves the result as query.txt or query.json in that directory
+rather than printing it, timestamping the name if the file exists and
+--force is not given.
+
+Examples:
+  slack-intel query --channel general --from 2023-11-01 --to 2023-11-30
+  slack-intel query --threads -o json > threads.json
+  slack-intel query --channel incidents --has-files --has-reactions
+  slack-intel query --channel general --with-user-details -o json
+  slack-intel query --channel general -o json --output-dir reports`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if opts.template, err = cache.ParseNameTemplate(template); err != nil {
+				return err
+			}
+			if from != "" {
+				if opts.from, err = time.Parse("2006-01-02", from); err != nil {
+					return fmt.Errorf("invalid --from date %q: %w", from, err)
+				}
+			}
+			if to != "" {
+				if opts.to, err = time.Parse("2006-01-02", to); err != nil {
+					return fmt.Errorf("invalid --to date %q: %w", to, err)
+				}
+				opts.to = opts.to.AddDate(0, 0, 1)
+			}
+			if opts.output != "text" && opts.output != "json" {
+				return fmt.Errorf("unknown output format %q (want text or json)", opts.output)
+			}
+			return runQuery(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringSliceVar(&opts.channels, "channel", nil, "Only these channels, by cache name (repeatable)")
+	cmd.Flags().StringVar(&from, "from", "", "First day to include (YYYY-MM-DD, UTC)")
+	cmd.Flags().StringVar(&to, "to", "", "Last day to include (YYYY-MM-DD, UTC)")
+	cmd.Flags().BoolVar(&opts.threads, "threads", false, "Group replies under their thread parent")
+	addMessageFlags(cmd, &opts.flags)
+	cmd.Flags().StringVarP(&opts.output, "output", "o", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&opts.userDetails, "with-user-details", false, "Include author title, timezone and avatar from users.parquet")
+	cmd.Flags().BoolVar(&opts.allowMixed, "allow-mixed-schemas", false, "Read partitions written with different schema versions together")
+	opts.artifacts.addFlags(cmd)
+
+	return cmd
+}
+
+func runQuery(opts queryOptions) error {
+	ctx := context.Background()
+
+	parquetCache := cache.NewParquetCache(opts.cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+	loc, err := partitionTimezone()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartitionTimezone(loc)
+	parquetCache.SetAllowMixedSchemas(opts.allowMixed)
+
+	filter := cache.MessageFilter{Channels: opts.channels, From: opts.from, To: opts.to, Flags: opts.flags}
+	order, byChannel, err := readCached(ctx, parquetCache, filter)
+	if err != nil {
+		return err
+	}
+	if opts.userDetails {
+		users, err := parquetCache.LoadUsers(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to load users for --with-user-details: %w", err)
+		}
+		addUserDetails(byChannel, users)
+	}
+
+	return writeArtifact(opts.artifacts, "query", formatExtension(opts.output), func(w io.Writer) error {
+		return writeQuery(w, opts, order, byChannel)
+	})
+}
+
+// writeQuery writes the messages read by runQuery to w
+func writeQuery(w io.Writer, opts queryOptions, order []string, byChannel map[string][]*models.SlackMessage) error {
+	if opts.threads {
+		var threads []queryThread
+		for _, channel := range order {
+			for _, t := range models.BuildThreads(byChannel[channel]) {
+				threads = append(threads, queryThread{Channel: channel, Thread: t})
+			}
+		}
+		if opts.output == "json" {
+			return writeJSON(w, threads)
+		}
+		for _, t := range threads {
+			fmt.Fprintln(w, queryLine(t.Channel, t.Parent, t.ParentMissing))
+			for _, reply := range t.Replies {
+				fmt.Fprintln(w, "    "+queryLine(t.Channel, reply, false))
+			}
+		}
+		return nil
+	}
+
+	var messages []queryMessage
+	for _, channel := range order {
+		for _, msg := range byChannel[channel] {
+			messages = append(messages, queryMessage{Channel: channel, SlackMessage: msg})
+		}
+	}
+	if opts.output == "json" {
+		return writeJSON(w, messages)
+	}
+	for _, m := range messages {
+		fmt.Fprintln(w, queryLine(m.Channel, m.SlackMessage, false))
+	}
+	return nil
+}
+
+// queryLine renders one message as "2023-11-14 22:15 #channel author: text"
+func queryLine(channel string, msg *models.SlackMessage, missing bool) string {
+	when := msg.Timestamp.UTC().Format("2006-01-02 15:04")
+	if missing {
+		return dimStyle.Render(fmt.Sprintf("%s #%s (parent not in range, %d replies)", when, channel, msg.ReplyCount))
+	}
+	text := strings.Join(strings.Fields(msg.Text), " ")
+	return fmt.Sprintf("%s #%s %s%s: %s", when, channel, messageAuthor(msg), userDetails(msg.UserInfo), text)
+}
+
+// userDetails is " (title, timezone)" with whichever of the two the user
+// has, or empty
+func userDetails(user *models.SlackUser) string {
+	if user == nil {
+		return ""
+	}
+	var parts []string
+	for _, s := range []string{user.Title, user.TZ} {
+		if s != "" {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
+
+// addUserDetails replaces the author of each message with their
+// users.parquet record, which unlike the message columns carries title,
+// timezone and avatar. Authors missing from users.parquet are left as read
+func addUserDetails(byChannel map[string][]*models.SlackMessage, users map[string]*models.SlackUser) {
+	for _, msgs := range byChannel {
+		for _, msg := range msgs {
+			if user, ok := users[msg.UserID]; ok {
+				msg.UserInfo = user
+			}
+		}
+	}
+}
+
+// writeJSON writes v to w as indented JSON, with an empty list rather than
+// null
+func writeJSON[T any](w io.Writer, v []T) error {
+	if v == nil {
+		v = []T{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// addMessageFlags registers the --has-files, --has-reactions and
+// --has-thread filters
+func addMessageFlags(cmd *cobra.Command, flags *cache.MessageFlags) {
+	cmd.Flags().BoolVar(&flags.HasFiles, "has-files", false, "Only messages with files attached")
+	cmd.Flags().BoolVar(&flags.HasReactions, "has-reactions", false, "Only messages with reactions")
+	cmd.Flags().BoolVar(&flags.HasThread, "has-thread", false, "Only messages starting a thread with replies")
+}
+
+// readCached reads the messages the filter selects: those of its channels
+// (all when empty) timestamped in [From, To), zero bounds being open, with
+// its flags. Partitions and row groups outside the filter are not read. It
+// returns the channels in the order first seen and each channel's
+// messages. Partitions of mixed schema versions are refused unless the
+// cache allows them.
+func readCached(ctx context.Context, parquetCache *cache.ParquetCache, filter cache.MessageFilter) ([]string, map[string][]*models.SlackMessage, error) {
+	var order []string
+	byChannel := make(map[string][]*models.SlackMessage)
+	err := parquetCache.ScanMessages(ctx, filter, func(p cache.Partition, msgs []*models.SlackMessage) error {
+		if _, seen := byChannel[p.Channel]; !seen {
+			order = append(order, p.Channel)
+		}
+		byChannel[p.Channel] = append(byChannel[p.Channel], msgs...)
+		return nil
+	})
+	if errors.Is(err, cache.ErrMixedSchemas) {
+		return nil, nil, fmt.Errorf("%w; run 'slack-intel cache migrate' or pass --allow-mixed-schemas", err)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return order, byChannel, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func cacheRedactCmd() *cobra.Command {
+	var cachePath, template, user string
+
+	cmd := &cobra.Command{
+		Use:   "redact",
+		Short: "Blank one user's messages in the cache",
+		Long: `Rewrite every cached partition holding messages of one user with their
+text replaced by "[redacted]". clean_text is replaced too and the links
+and JIRA tickets taken from the text are dropped. Rows, message ids,
+timestamps and thread structure stay, so counts and threads still add up.
+Blocks and stored API payloads, which repeat the text, are dropped too.
+--user takes a user ID, @name or an email address. Partitions fetched
+again later get the original text back, so exclude the user's channels
+or re-run this after each cache run as needed.
+
+Examples:
+  slack-intel cache redact --user U04ABCDE
+  slack-intel cache redact --user alice@example.com --cache-path /data/slack/raw`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nameTemplate, err := cache.ParseNameTemplate(template)
+			if err != nil {
+				return err
+			}
+			return runCacheRedact(cachePath, nameTemplate, user)
+		},
+	}
+
+	cmd.Flags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringVar(&user, "user", "", "User ID, @name or email address")
+	cmd.MarkFlagRequired("user")
+
+	return cmd
+}
+
+func runCacheRedact(cachePath string, template *cache.NameTemplate, user string) error {
+	ctx := context.Background()
+
+	parquetCache := cache.NewParquetCache(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+
+	users, err := parquetCache.LoadUsers(ctx)
+	if err != nil {
+		logger.Warn("ignoring cached users", "error", err)
+	}
+	userID, err := models.FindUser(users, user)
+	if err != nil {
+		return err
+	}
+
+	partitions, err := parquetCache.ListPartitions()
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("🕶 Redacting %s in %d partition(s)", userID, len(partitions))))
+	rows, rewritten := 0, 0
+	for _, p := range partitions {
+		n, err := parquetCache.RedactUser(ctx, p.Path, userID)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+		rows += n
+		rewritten++
+		fmt.Println(successStyle.Render(fmt.Sprintf("  ✓ %s: %d message(s)", filepath.Dir(p.Path), n)))
+	}
+
+	fmt.Println(dimStyle.Render(fmt.Sprintf("Redacted %d message(s) in %d partition(s)", rows, rewritten)))
+	return nil
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/analyze"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// reportOptions holds the report activity flags
+type reportOptions struct {
+	cachePath string
+	template  *cache.NameTemplate
+	channels  []string
+	days      int
+	output    string
+	// grace is how long a question may sit unanswered (report unanswered)
+	grace time.Duration
+	// includeInternal keeps Slack archive and localhost links (report links)
+	includeInternal bool
+	// allowMixed reads partitions of differing schema versions together
+	allowMixed bool
+	artifacts  artifactOptions
+}
+
+func reportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Summarize cached messages",
+		Long: `Summarize cached messages. Reports print to stdout; --output-dir writes
+them to report-<name>.<md|json|csv> in that directory instead, adding a
+timestamp to the name when the file exists unless --force is given.`,
+	}
+
+	// Flag defaults are written into the options at registration, so each
+	// subcommand has its own
+	var (
+		activity, unanswered, reactions, links                                 reportOptions
+		activityTemplate, unansweredTemplate, reactionsTemplate, linksTemplate string
+	)
+	activityCmd := &cobra.Command{
+		Use:   "activity",
+		Short: "Top contributors, channel volume and thread participation",
+		Long: `Summarize the cache over the last --days: messages per person (bots
+listed separately), messages per channel per day, threads started and
+joined, and reactions received. Markdown output drops straight into a
+weekly update; csv and json suit spreadsheets and scripts.
+
+Examples:
+  slack-intel report activity --days 30
+  slack-intel report activity --days 7 --channel backend --output csv > week.csv
+  slack-intel report activity --days 7 --output-dir reports`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := activity.validate(activityTemplate); err != nil {
+				return err
+			}
+			return runReportActivity(activity)
+		},
+	}
+	activity.addFlags(activityCmd, &activityTemplate, 30)
+
+	unansweredCmd := &cobra.Command{
+		Use:   "unanswered",
+		Short: "Questions nobody replied or reacted to",
+		Long: `List top-level messages that look like questions (ending in "?" or
+starting with who, what, why, how, can or does) with no replies and no
+reactions once --grace has passed, oldest first.
+
+Examples:
+  slack-intel report unanswered --channel help-platform --days 7
+  slack-intel report unanswered --grace 24h --output csv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := unanswered.validate(unansweredTemplate); err != nil {
+				return err
+			}
+			if unanswered.grace < 0 {
+				return fmt.Errorf("--grace must not be negative")
+			}
+			return runReportUnanswered(unanswered)
+		},
+	}
+	unanswered.addFlags(unansweredCmd, &unansweredTemplate, 7)
+	unansweredCmd.Flags().DurationVar(&unanswered.grace, "grace", analyze.DefaultGrace, "How long a question may go without replies or reactions")
+
+	reactionsCmd := &cobra.Command{
+		Use:   "reactions",
+		Short: "Emoji leaderboard and reaction givers and receivers",
+		Long: `Rank the emoji used in reactions over the last --days, overall and per
+channel, with each emoji's count in the period before for comparison, and
+list who received and who gave the most reactions. Skin-tone variants
+count as their base emoji. Givers are missing from caches written with
+--redact.
+
+Examples:
+  slack-intel report reactions --days 30
+  slack-intel report reactions --days 7 --channel backend --output json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(analyze.ReactionFormats, reactions.output) {
+				return fmt.Errorf("unknown output format %q (want %s)", reactions.output, strings.Join(analyze.ReactionFormats, ", "))
+			}
+			if err := reactions.validate(reactionsTemplate); err != nil {
+				return err
+			}
+			return runReportReactions(reactions)
+		},
+	}
+	reactions.addFlags(reactionsCmd, &reactionsTemplate, 30)
+	reactionsCmd.Flags().Lookup("output").Usage = "Output format: markdown or json"
+
+	linksCmd := &cobra.Command{
+		Use:   "links",
+		Short: "Most shared domains and URLs",
+		Long: `Rank the links shared over the last --days by domain and by full URL,
+with how many messages shared each, how many people and which channels.
+Links to Slack message archives and localhost are left out unless
+--include-internal is given. Links come from the urls column, so
+partitions cached by older versions contribute none.
+
+Examples:
+  slack-intel report links --days 14
+  slack-intel report links --channel backend --output csv > links.csv`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := links.validate(linksTemplate); err != nil {
+				return err
+			}
+			return runReportLinks(links)
+		},
+	}
+	links.addFlags(linksCmd, &linksTemplate, 14)
+	linksCmd.Flags().BoolVar(&links.includeInternal, "include-internal", false, "Keep links to Slack message archives and localhost")
+
+	cmd.AddCommand(activityCmd, unansweredCmd, reactionsCmd, linksCmd)
+	return cmd
+}
+
+// addFlags registers the flags shared by the report subcommands
+func (o *reportOptions) addFlags(cmd *cobra.Command, template *string, days int) {
+	cmd.Flags().StringVar(&o.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringSliceVar(&o.channels, "channel", nil, "Only these channels, by cache name (repeatable)")
+	cmd.Flags().IntVarP(&o.days, "days", "d", days, "Days to look back")
+	cmd.Flags().StringVarP(&o.output, "output", "o", "markdown", "Output format: markdown, json or csv")
+	cmd.Flags().BoolVar(&o.allowMixed, "allow-mixed-schemas", false, "Read partitions written with different schema versions together")
+	o.artifacts.addFlags(cmd)
+}
+
+// validate checks the shared flags and parses the name template
+func (o *reportOptions) validate(template string) error {
+	if o.days < 1 {
+		return fmt.Errorf("--days must be at least 1")
+	}
+	if !slices.Contains(analyze.Formats, o.output) {
+		return fmt.Errorf("unknown output format %q (want %s)", o.output, strings.Join(analyze.Formats, ", "))
+	}
+	nameTemplate, err := cache.ParseNameTemplate(template)
+	if err != nil {
+		return err
+	}
+	o.template = nameTemplate
+	return nil
+}
+
+// reportInput reads the cached messages of the last opts.days and the user
+// directory. It returns the messages, the users and the report window.
+func reportInput(ctx context.Context, opts reportOptions) ([]analyze.Message, map[string]*models.SlackUser, time.Time, time.Time, error) {
+	parquetCache, _, err := openReportCache(opts)
+	if err != nil {
+		return nil, nil, time.Time{}, time.Time{}, err
+	}
+	to := time.Now().UTC().Truncate(time.Second)
+	from := to.AddDate(0, 0, -opts.days)
+	msgs, users, err := cachedMessages(ctx, parquetCache, opts.channels, from, to)
+	return msgs, users, from, to, err
+}
+
+// openReportCache opens the cache for reading as the report flags
+// describe, returning it and the partition timezone
+func openReportCache(opts reportOptions) (*cache.ParquetCache, *time.Location, error) {
+	parquetCache := cache.NewParquetCache(opts.cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return nil, nil, err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	partFile, err := partFileName()
+	if err != nil {
+		return nil, nil, err
+	}
+	parquetCache.SetPartFileName(partFile)
+	loc, err := partitionTimezone()
+	if err != nil {
+		return nil, nil, err
+	}
+	parquetCache.SetPartitionTimezone(loc)
+	parquetCache.SetAllowMixedSchemas(opts.allowMixed)
+	return parquetCache, loc, nil
+}
+
+// cachedMessages reads the channels' messages posted in [from, to) and
+// the user directory
+func cachedMessages(ctx context.Context, parquetCache *cache.ParquetCache, channels []string, from, to time.Time) ([]analyze.Message, map[string]*models.SlackUser, error) {
+	order, byChannel, err := readCached(ctx, parquetCache, cache.MessageFilter{Channels: channels, From: from, To: to})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Names for authors whose rows carry no user info
+	users, err := parquetCache.LoadUsers(ctx)
+	if err != nil {
+		logger.Warn("ignoring cached users", "error", err)
+	}
+
+	var msgs []analyze.Message
+	for _, channel := range order {
+		for _, msg := range byChannel[channel] {
+			msgs = append(msgs, analyze.Message{Channel: channel, SlackMessage: msg})
+		}
+	}
+	return msgs, users, nil
+}
+
+func runReportActivity(opts reportOptions) error {
+	msgs, users, from, to, err := reportInput(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	return writeArtifact(opts.artifacts, "report-activity", formatExtension(opts.output), func(w io.Writer) error {
+		return analyze.Write(w, analyze.Summarize(msgs, users, from, to), opts.output)
+	})
+}
+
+func runReportUnanswered(opts reportOptions) error {
+	msgs, users, _, to, err := reportInput(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	found := analyze.Unanswered(msgs, to, analyze.UnansweredOptions{Grace: opts.grace, Users: users})
+	return writeArtifact(opts.artifacts, "report-unanswered", formatExtension(opts.output), func(w io.Writer) error {
+		return analyze.WriteUnanswered(w, found, opts.output)
+	})
+}
+
+func runReportReactions(opts reportOptions) error {
+	// Read twice the window so the previous period can be compared
+	span := opts
+	span.days *= 2
+	msgs, users, _, to, err := reportInput(context.Background(), span)
+	if err != nil {
+		return err
+	}
+
+	from := to.AddDate(0, 0, -opts.days)
+	var current, previous []analyze.Message
+	for _, m := range msgs {
+		if m.Timestamp.Before(from) {
+			previous = append(previous, m)
+		} else {
+			current = append(current, m)
+		}
+	}
+
+	return writeArtifact(opts.artifacts, "report-reactions", formatExtension(opts.output), func(w io.Writer) error {
+		return analyze.WriteReactions(w, analyze.Reactions(current, previous, users, from, to), opts.output)
+	})
+}
+
+func runReportLinks(opts reportOptions) error {
+	msgs, users, from, to, err := reportInput(context.Background(), opts)
+	if err != nil {
+		return err
+	}
+	report := analyze.Links(msgs, from, to, analyze.LinkOptions{IncludeInternal: opts.includeInternal, Users: users})
+	return writeArtifact(opts.artifacts, "report-links", formatExtension(opts.output), func(w io.Writer) error {
+		return analyze.WriteLinks(w, report, opts.output)
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/mrkdwn"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+// reprocessOptions holds the cache reprocess flags
+type reprocessOptions struct {
+	cachePath string
+	template  *cache.NameTemplate
+	channels  []string
+	from      time.Time // inclusive, zero when unset
+	to        time.Time // exclusive, zero when unset
+	dryRun    bool
+}
+
+func cacheReprocessCmd() *cobra.Command {
+	var (
+		opts     reprocessOptions
+		from, to string
+		template string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "reprocess",
+		Short: "Re-derive columns of cached partitions with this version's parsing",
+		Long: `Rewrite cached partitions with their derived columns rebuilt by this
+version, without calling Slack, and upgrade them to the current schema.
+
+Messages cached with --raw are rebuilt from their API payloads: text,
+threads, reactions, files, pins, JIRA tickets, urls and blocks. Other
+messages have their JIRA tickets extracted again from the stored text and
+blocks, and their urls from the text. Tickets come from the parts of a
+message named in the config's jira.ticket_sources (text, attachments and
+blocks by default). clean_text is rendered again for partitions cached with
+--normalize-text. User info, permalinks and fetch times are kept.
+
+Partitions are only rewritten when a row changed, the file has an older
+schema version or its weekday and business-hours columns were derived with
+another activity setting; --dry-run reports what would change without
+writing.
+
+Examples:
+  slack-intel cache reprocess --dry-run
+  slack-intel cache reprocess --from 2024-01-01 --to 2024-06-01 --channel backend`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if opts.template, err = cache.ParseNameTemplate(template); err != nil {
+				return err
+			}
+			if from != "" {
+				if opts.from, err = time.Parse("2006-01-02", from); err != nil {
+					return fmt.Errorf("invalid --from date %q: %w", from, err)
+				}
+			}
+			if to != "" {
+				if opts.to, err = time.Parse("2006-01-02", to); err != nil {
+					return fmt.Errorf("invalid --to date %q: %w", to, err)
+				}
+				opts.to = opts.to.AddDate(0, 0, 1)
+			}
+			return runCacheReprocess(opts)
+		},
+	}
+
+	cmd.Flags().StringVar(&opts.cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringSliceVarP(&opts.channels, "channel", "c", []string{}, "Only these channels, as named in the partition paths")
+	cmd.Flags().StringVar(&from, "from", "", "First day to reprocess (YYYY-MM-DD, UTC)")
+	cmd.Flags().StringVar(&to, "to", "", "Last day to reprocess (YYYY-MM-DD, UTC)")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "Report changed rows without rewriting any file")
+
+	return cmd
+}
+
+func runCacheReprocess(opts reprocessOptions) error {
+	ctx := context.Background()
+
+	parquetCache := cache.NewParquetCache(opts.cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+	loc, err := partitionTimezone()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartitionTimezone(loc)
+	hours, err := activityHours()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetActivityHours(hours)
+	sources, err := ticketSources()
+	if err != nil {
+		return err
+	}
+
+	partitions, err := parquetCache.ListPartitions()
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	filter := cache.MessageFilter{Channels: opts.channels, From: opts.from, To: opts.to}
+
+	title := "🔄 Reprocessing cached partitions"
+	if opts.dryRun {
+		title += " (dry run)"
+	}
+	fmt.Println(titleStyle.Render(title))
+
+	scanned, rewritten, changedRows := 0, 0, 0
+	for _, p := range partitions {
+		if !filter.Partition(p) {
+			continue
+		}
+		scanned++
+
+		result, err := reprocessPartition(ctx, parquetCache, p.Path, sources, opts.dryRun)
+		if err != nil {
+			return err
+		}
+		if !result.rewrite {
+			continue
+		}
+		rewritten++
+		changedRows += result.changed
+
+		line := fmt.Sprintf("  ✓ %s: %d of %d row(s) changed", filepath.Dir(p.Path), result.changed, result.rows)
+		if result.payloads > 0 {
+			line += fmt.Sprintf(", %d from payloads", result.payloads)
+		}
+		if result.oldVersion != cache.SchemaVersion {
+			line += fmt.Sprintf(", schema %s → %s", versionLabel(result.oldVersion), cache.SchemaVersion)
+		}
+		fmt.Println(successStyle.Render(line))
+	}
+
+	verb := "Rewrote"
+	if opts.dryRun {
+		verb = "Would rewrite"
+	}
+	fmt.Println(dimStyle.Render(fmt.Sprintf("%s %d of %d partition(s); %d row(s) changed", verb, rewritten, scanned, changedRows)))
+	return nil
+}
+
+// reprocessResult describes one reprocessed partition
+type reprocessResult struct {
+	rows, changed, payloads int
+	oldVersion              string
+	// rewrite is set when rows changed, the schema is out of date or the
+	// activity columns were derived with other settings
+	rewrite bool
+}
+
+// reprocessPartition re-derives the messages of one partition, taking JIRA
+// tickets from sources, and unless dryRun is set rewrites it when anything
+// changed
+func reprocessPartition(ctx context.Context, pc *cache.ParquetCache, path string, sources slack.TicketSources, dryRun bool) (reprocessResult, error) {
+	var result reprocessResult
+
+	metadata, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		return result, err
+	}
+	result.oldVersion = metadata["schema_version"]
+
+	msgs, err := pc.ReadMessages(ctx, path)
+	if err != nil {
+		return result, err
+	}
+	if cache.RawMode(metadata["raw"]) == cache.RawSidecar {
+		payloads, err := pc.LoadSidecar(path)
+		if err != nil {
+			return result, err
+		}
+		for _, msg := range msgs {
+			msg.Raw = payloads[msg.MessageID]
+		}
+	}
+	flags, err := pc.ReadStoredFlags(ctx, path)
+	if err != nil {
+		return result, err
+	}
+
+	var normalize mrkdwn.Mode
+	if metadata["clean_text"] != "" {
+		if normalize, err = mrkdwn.ParseMode(metadata["clean_text"]); err != nil {
+			return result, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	rebuilt := make([]*models.SlackMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		fresh := slack.Rederive(msg, sources)
+		if len(msg.Raw) > 0 {
+			if fresh, err = slack.Reparse(msg, sources); err != nil {
+				return result, fmt.Errorf("%s: %w", path, err)
+			}
+			result.payloads++
+		}
+		if normalize != "" {
+			fresh.CleanText = mrkdwn.Normalize(fresh.Text, normalize)
+		}
+		if derivedChanged(msg, fresh, flags[msg.MessageID]) {
+			result.changed++
+		}
+		rebuilt = append(rebuilt, fresh)
+	}
+	result.rows = len(rebuilt)
+	stale := result.oldVersion != cache.SchemaVersion || metadata["activity_hours"] != pc.ActivityHours().String()
+	result.rewrite = len(rebuilt) > 0 && (result.changed > 0 || stale)
+
+	if !result.rewrite || dryRun {
+		return result, nil
+	}
+	return result, pc.RewriteMessages(ctx, path, rebuilt)
+}
+
+// derivedChanged reports whether reprocessing changed any stored column of
+// a message, flags being those of its stored row
+func derivedChanged(stored, fresh *models.SlackMessage, flags cache.StoredFlags) bool {
+	return flags.With(fresh) != flags || stored.Text != fresh.Text || stored.UserID != fresh.UserID || stored.ThreadTS != fresh.ThreadTS ||
+		stored.ReplyCount != fresh.ReplyCount || stored.CleanText != fresh.CleanText ||
+		string(stored.Blocks) != string(fresh.Blocks) ||
+		!slices.Equal(stored.JiraTickets, fresh.JiraTickets) || !slices.Equal(stored.URLs, fresh.URLs) ||
+		!reflect.DeepEqual(stored.Reactions, fresh.Reactions)
+}
+
+// versionLabel names a file's schema_version for display
+func versionLabel(v string) string {
+	if v == "" {
+		return "unversioned"
+	}
+	return v
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+)
+
+func runsCmd() *cobra.Command {
+	var (
+		cachePath string
+		output    string
+		limit     int
+	)
+
+	cmd := &cobra.Command{
+		Use:   "runs",
+		Short: "Inspect the history of cache runs",
+		Long: `Every cache run (every cycle with --watch) records itself in
+_runs/<run id>.json under the cache path: its window, per-channel outcomes,
+message counts and errors, the tool version and the files it wrote.
+cache gaps and verify use it to explain missing or broken partitions.`,
+	}
+	cmd.PersistentFlags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.PersistentFlags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List recorded runs, newest first",
+		Long: `List recorded runs, newest first, with their status, duration, channel
+and message counts and window.
+
+Examples:
+  slack-intel runs list
+  slack-intel runs list --limit 5 -o json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q (want text or json)", output)
+			}
+			return runRunsList(cachePath, output, limit)
+		},
+	}
+	listCmd.Flags().IntVarP(&limit, "limit", "n", 20, "Most runs to list (0 for all)")
+
+	showCmd := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Show one run's channels, errors and files",
+		Long: `Show one recorded run. The ID may be shortened to any unambiguous
+prefix, e.g. its date.
+
+Examples:
+  slack-intel runs show 20240105T101500Z-3f9a
+  slack-intel runs show 20240105T10 -o json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q (want text or json)", output)
+			}
+			return runRunsShow(cachePath, output, args[0])
+		},
+	}
+
+	cmd.AddCommand(listCmd, showCmd)
+	return cmd
+}
+
+// loadRuns reads the run manifest of the cache at cachePath
+func loadRuns(cachePath string) ([]cache.RunRecord, error) {
+	parquetCache := cache.NewParquetCache(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return nil, err
+	}
+	parquetCache.SetStorage(store)
+	return parquetCache.LoadRuns()
+}
+
+func runRunsList(cachePath, output string, limit int) error {
+	runs, err := loadRuns(cachePath)
+	if err != nil {
+		return err
+	}
+
+	newest := make([]cache.RunRecord, 0, len(runs))
+	for i := len(runs) - 1; i >= 0 && (limit <= 0 || len(newest) < limit); i-- {
+		newest = append(newest, runs[i])
+	}
+	if output == "json" {
+		return writeJSON(os.Stdout, newest)
+	}
+
+	fmt.Println(titleStyle.Render("🗂  Cache runs"))
+	if len(newest) == 0 {
+		fmt.Println(dimStyle.Render("No runs recorded"))
+		return nil
+	}
+	for _, run := range newest {
+		messages, failed := 0, 0
+		for _, ch := range run.Channels {
+			messages += ch.Messages
+			if ch.Error != "" {
+				failed++
+			}
+		}
+		line := fmt.Sprintf("%s  %-7s %s, %d channel(s), %d message(s), window %s",
+			run.ID, run.Status, run.FinishedAt.Sub(run.StartedAt).Round(time.Second),
+			len(run.Channels), messages, runWindow(run))
+		switch {
+		case failed > 0:
+			fmt.Println(errorStyle.Render(line + fmt.Sprintf(", %d failed", failed)))
+		default:
+			fmt.Println(line)
+		}
+	}
+	if len(newest) < len(runs) {
+		fmt.Println(dimStyle.Render(fmt.Sprintf("%d of %d run(s); --limit 0 lists all", len(newest), len(runs))))
+	}
+	return nil
+}
+
+func runRunsShow(cachePath, output, id string) error {
+	runs, err := loadRuns(cachePath)
+	if err != nil {
+		return err
+	}
+	run, err := cache.FindRun(runs, id)
+	if err != nil {
+		return err
+	}
+	if output == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(run)
+	}
+
+	fmt.Println(titleStyle.Render("🗂  Run " + run.ID))
+	fmt.Printf("Status: %s\n", run.Status)
+	fmt.Printf("Started: %s (%s)\n", run.StartedAt.Format(time.RFC3339), run.FinishedAt.Sub(run.StartedAt).Round(time.Millisecond))
+	fmt.Printf("Window: %s\n", runWindow(run))
+	fmt.Printf("Tool version: %s\n", run.ToolVersion)
+	fmt.Println()
+
+	for _, ch := range run.Channels {
+		line := fmt.Sprintf("  %s (%s): %s, %d message(s), %d file(s)", ch.Channel, ch.ChannelID, ch.Outcome, ch.Messages, len(ch.Files))
+		switch {
+		case ch.Error != "":
+			fmt.Println(errorStyle.Render(line + ": " + ch.Error))
+		case ch.Skipped != "":
+			fmt.Println(dimStyle.Render(line + ": " + ch.Skipped))
+		default:
+			fmt.Println(successStyle.Render(line))
+		}
+		if len(ch.FailedThreads) > 0 {
+			fmt.Println(errorStyle.Render(fmt.Sprintf("      replies missing for %d thread(s): %s", len(ch.FailedThreads), threadList(ch.FailedThreads))))
+		}
+		for _, f := range ch.Files {
+			fmt.Println(dimStyle.Render("      " + filepath.ToSlash(f)))
+		}
+	}
+	for _, f := range run.Files {
+		fmt.Println(dimStyle.Render("  " + filepath.ToSlash(f)))
+	}
+	return nil
+}
+
+// runWindow renders a run's fetch window
+func runWindow(run cache.RunRecord) string {
+	return run.WindowStart.Format("2006-01-02 15:04") + " to " + run.WindowEnd.Format("2006-01-02 15:04")
+}
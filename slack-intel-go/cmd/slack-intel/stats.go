@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+)
+
+// channelStats sums up one channel's partitions for cache stats
+type channelStats struct {
+	Channel    string `json:"channel"`
+	Partitions int    `json:"partitions"`
+	Rows       int64  `json:"rows"`
+	Bytes      int64  `json:"bytes"`
+	// Thin lists the partitions written without thread replies or users
+	Thin []thinPartition `json:"thin"`
+}
+
+// thinPartition is a partition missing enrichments, as its metadata says
+type thinPartition struct {
+	Partition string   `json:"partition"`
+	Path      string   `json:"path"`
+	Missing   []string `json:"missing"`
+}
+
+func cacheStatsCmd() *cobra.Command {
+	var (
+		cachePath string
+		template  string
+		output    string
+		thin      bool
+		artifacts artifactOptions
+	)
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Summarize cached partitions per channel, including thin ones",
+		Long: `Count the partitions, rows and bytes cached per channel, read from the
+files' footers without loading their rows.
+
+Partitions written with --no-threads (or --threads none) or --no-users are
+"thin": their metadata records what was skipped. stats counts them per
+channel, and --thin lists each one with what it is missing.
+
+--output-dir writes cache-stats.txt (or cache-stats.json) there instead of
+stdout, timestamping the name if the file exists and --force is not given.
+
+Examples:
+  slack-intel cache stats
+  slack-intel cache stats --thin
+  slack-intel cache stats -o json | jq '.[] | select(.thin != [])'
+  slack-intel cache stats --thin --output-dir reports`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nameTemplate, err := cache.ParseNameTemplate(template)
+			if err != nil {
+				return err
+			}
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q (want text or json)", output)
+			}
+			return runCacheStats(cachePath, nameTemplate, output, thin, artifacts)
+		},
+	}
+
+	cmd.Flags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout the cache was written with")
+	cmd.Flags().StringVarP(&output, "output", "o", "text", "Output format: text or json")
+	cmd.Flags().BoolVar(&thin, "thin", false, "List every thin partition and what it is missing")
+	artifacts.addFlags(cmd)
+
+	return cmd
+}
+
+func runCacheStats(cachePath string, template *cache.NameTemplate, output string, listThin bool, artifacts artifactOptions) error {
+	parquetCache := cache.NewParquetCache(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+
+	partitions, err := parquetCache.ListPartitions()
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	byChannel := make(map[string]*channelStats)
+	for _, p := range partitions {
+		stats := byChannel[p.Channel]
+		if stats == nil {
+			stats = &channelStats{Channel: p.Channel, Thin: []thinPartition{}}
+			byChannel[p.Channel] = stats
+		}
+		rows, err := parquetCache.ReadRowCount(p.Path)
+		if err != nil {
+			return err
+		}
+		metadata, err := parquetCache.ReadFileMetadata(p.Path)
+		if err != nil {
+			return err
+		}
+		size, _ := parquetCache.Size(p.Path)
+
+		stats.Partitions++
+		stats.Rows += rows
+		stats.Bytes += size
+		if missing := cache.Thin(metadata); len(missing) > 0 {
+			stats.Thin = append(stats.Thin, thinPartition{Partition: p.Key, Path: p.Path, Missing: missing})
+		}
+	}
+
+	channels := make([]*channelStats, 0, len(byChannel))
+	for _, stats := range byChannel {
+		sort.Slice(stats.Thin, func(i, j int) bool { return stats.Thin[i].Partition < stats.Thin[j].Partition })
+		channels = append(channels, stats)
+	}
+	sort.Slice(channels, func(i, j int) bool { return channels[i].Channel < channels[j].Channel })
+
+	return writeArtifact(artifacts, "cache-stats", formatExtension(output), func(w io.Writer) error {
+		if output == "json" {
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			return enc.Encode(channels)
+		}
+		writeCacheStats(w, channels, listThin)
+		return nil
+	})
+}
+
+// writeCacheStats writes the per-channel stats as text
+func writeCacheStats(w io.Writer, channels []*channelStats, listThin bool) {
+	fmt.Fprintln(w, titleStyle.Render("📊 Cache stats"))
+	if len(channels) == 0 {
+		fmt.Fprintln(w, dimStyle.Render("No partitions cached"))
+		return
+	}
+	var total channelStats
+	thinTotal := 0
+	for _, stats := range channels {
+		line := fmt.Sprintf("  #%s: %d partition(s), %d row(s), %.2f MB", stats.Channel, stats.Partitions, stats.Rows,
+			float64(stats.Bytes)/(1024*1024))
+		if len(stats.Thin) > 0 {
+			line += fmt.Sprintf(", %d thin", len(stats.Thin))
+		}
+		fmt.Fprintln(w, line)
+		if listThin {
+			for _, t := range stats.Thin {
+				fmt.Fprintln(w, dimStyle.Render(fmt.Sprintf("      %s: missing %s", t.Partition, strings.Join(t.Missing, " and "))))
+			}
+		}
+		total.Partitions += stats.Partitions
+		total.Rows += stats.Rows
+		total.Bytes += stats.Bytes
+		thinTotal += len(stats.Thin)
+	}
+	fmt.Fprintln(w, dimStyle.Render(fmt.Sprintf("%d channel(s), %d partition(s), %d row(s), %.2f MB", len(channels), total.Partitions,
+		total.Rows, float64(total.Bytes)/(1024*1024))))
+	if thinTotal > 0 {
+		fmt.Fprintln(w, dimStyle.Render(fmt.Sprintf("%d thin partition(s) lack thread replies or user info", thinTotal)))
+	}
+}
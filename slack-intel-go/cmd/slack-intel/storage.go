@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/config"
+)
+
+// profilePattern limits profile names to ones safe as a directory name
+var profilePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// storageBackend is the persistent --storage flag shared by all commands
+var storageBackend string
+
+// loadConfig loads the config with the --profile overlay
+func loadConfig() (*config.Config, error) {
+	return config.LoadProfile(configPath, profileName)
+}
+
+// resolvedToken is the Slack token once slackToken found it, so a
+// token_command runs at most once
+var resolvedToken string
+
+// slackToken resolves the Slack token from SLACK_API_TOKEN (or the
+// variable the --profile's token_env names), then --token, then the
+// output of the config's token_command. It is masked in the logs.
+func slackToken() (string, error) {
+	if resolvedToken != "" {
+		return resolvedToken, nil
+	}
+
+	name := config.DefaultTokenEnv
+	var cfg *config.Config
+	if profileName != "" {
+		c, err := loadConfig()
+		if err != nil {
+			return "", err
+		}
+		cfg, name = c, c.TokenEnvName()
+	}
+
+	token, envErr := config.GetEnv(name)
+	var command string
+	switch {
+	case envErr == nil:
+	case tokenFlag != "":
+		token = tokenFlag
+	default:
+		if cfg == nil {
+			c, err := loadConfig()
+			if errors.Is(err, config.ErrNoConfig) {
+				return "", fmt.Errorf("%w (or pass --token, or set auth.token_command in the config)", envErr)
+			}
+			if err != nil {
+				return "", fmt.Errorf("failed to load config: %w", err)
+			}
+			cfg = c
+		}
+		if cfg.Auth.TokenCommand == "" {
+			return "", fmt.Errorf("%w (or pass --token, or set auth.token_command in the config)", envErr)
+		}
+		command = cfg.Auth.TokenCommand
+		t, err := config.RunTokenCommand(context.Background(), command)
+		if err != nil {
+			return "", err
+		}
+		token = t
+	}
+	secrets.Add(token)
+	if command != "" {
+		logger.Debug("slack token read from token_command", "command", command)
+	}
+	resolvedToken = token
+	return token, nil
+}
+
+// useProfileCachePath moves the command's default --cache-path into the
+// --profile's own tree: cache/raw becomes cache/<profile>/raw, so its
+// partitions, users.parquet and the other files beside them never mix with
+// another workspace's (user IDs collide across workspaces). A --cache-path
+// given on the command line is used as it is.
+func useProfileCachePath(cmd *cobra.Command) error {
+	if profileName == "" {
+		return nil
+	}
+	if !profilePattern.MatchString(profileName) {
+		return fmt.Errorf("invalid profile name %q (letters, digits, - and _ only)", profileName)
+	}
+	flag := cmd.Flags().Lookup("cache-path")
+	if flag == nil || flag.Changed {
+		return nil
+	}
+	path := filepath.Clean(flag.Value.String())
+	return flag.Value.Set(filepath.Join(filepath.Dir(path), profileName, filepath.Base(path)))
+}
+
+// partitionTimezone returns the zone dt= partitions are cut in: --tz,
+// else the config's storage.partition_timezone, else UTC
+func partitionTimezone() (*time.Location, error) {
+	if tzFlag != "" {
+		return config.LoadZone("--tz", tzFlag)
+	}
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrNoConfig) {
+		return time.UTC, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg.Storage.PartitionLocation()
+}
+
+// partFileName returns the name of each partition's Parquet file: the
+// --part-filename flag, else the config's storage.part_filename, else
+// data.parquet
+func partFileName() (string, error) {
+	if partFileFlag != "" {
+		return cache.ParsePartFileName(partFileFlag)
+	}
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrNoConfig) {
+		return cache.DefaultPartFileName, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	return cache.ParsePartFileName(cfg.Storage.PartFileName)
+}
+
+// activityHours returns the config's activity section, the zone and
+// working day the weekday and business-hours columns are derived with;
+// UTC and DefaultBusinessHours without a config
+func activityHours() (cache.ActivityHours, error) {
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrNoConfig) {
+		return cache.DefaultActivityHours, nil
+	}
+	if err != nil {
+		return cache.ActivityHours{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	return configActivityHours(cfg)
+}
+
+// configActivityHours reads the activity section of cfg
+func configActivityHours(cfg *config.Config) (cache.ActivityHours, error) {
+	loc, err := cfg.Activity.Location()
+	if err != nil {
+		return cache.ActivityHours{}, err
+	}
+	start, end, err := cfg.Activity.Hours()
+	if err != nil {
+		return cache.ActivityHours{}, err
+	}
+	return cache.ActivityHours{Location: loc, Start: start, End: end}, nil
+}
+
+// ticketSources returns the config's jira.ticket_sources, the parts of a
+// message JIRA tickets are extracted from; all of them without a config
+func ticketSources() (slack.TicketSources, error) {
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrNoConfig) {
+		return slack.AllTicketSources, nil
+	}
+	if err != nil {
+		return slack.TicketSources{}, fmt.Errorf("failed to load config: %w", err)
+	}
+	return configTicketSources(cfg)
+}
+
+// configTicketSources reads jira.ticket_sources from cfg
+func configTicketSources(cfg *config.Config) (slack.TicketSources, error) {
+	sources, err := slack.ParseTicketSources(cfg.Jira.TicketSources)
+	if err != nil {
+		return slack.TicketSources{}, fmt.Errorf("jira.ticket_sources: %w", err)
+	}
+	return sources, nil
+}
+
+// openStorage returns the backend picked by --storage. "bucket" uses the
+// config's storage.provider; "s3" and "gcs" name it explicitly and must
+// agree with it. Bucket settings come from the config's storage section and
+// the SLACK_INTEL_* overrides.
+func openStorage() (storage.Storage, error) {
+	switch storageBackend {
+	case "local":
+		return storage.Local{}, nil
+	case "bucket", "s3", "gcs":
+	default:
+		return nil, fmt.Errorf("invalid storage %q (expected local, bucket, s3 or gcs)", storageBackend)
+	}
+
+	cfg, err := loadConfig()
+	if errors.Is(err, config.ErrNoConfig) {
+		return nil, fmt.Errorf("%s storage needs a bucket: set storage.bucket in the config or %s", storageBackend, config.EnvS3Bucket)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	s := cfg.Storage
+	provider := storageBackend
+	switch {
+	case provider == "bucket":
+		provider = s.StorageProvider()
+	case s.Provider != "" && s.Provider != provider:
+		return nil, fmt.Errorf("--storage %s conflicts with storage.provider %s in the config", provider, s.Provider)
+	}
+
+	switch provider {
+	case "s3":
+		return storage.NewS3(storage.S3Options{
+			Bucket:         s.Bucket,
+			Prefix:         s.Prefix,
+			Region:         s.Region,
+			Profile:        s.Profile,
+			Endpoint:       s.Endpoint,
+			ForcePathStyle: s.ForcePathStyle,
+			DisableSSL:     s.DisableSSL,
+			SSE:            s.SSE,
+			KMSKeyID:       s.KMSKeyID,
+			StorageClass:   s.StorageClass,
+		})
+	case "gcs":
+		return storage.NewGCS(storage.GCSOptions{
+			Bucket:          s.Bucket,
+			Prefix:          s.Prefix,
+			CredentialsFile: s.CredentialsFile,
+		})
+	}
+	return nil, fmt.Errorf("invalid storage.provider %q (expected s3 or gcs)", provider)
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack/fakeslack"
+)
+
+// threadOptions holds the thread fetch flags
+type threadOptions struct {
+	save        bool
+	cachePath   string
+	granularity cache.Granularity
+	template    *cache.NameTemplate
+	// offlineFixture serves Slack from a fakeslack JSON fixture (development)
+	offlineFixture string
+}
+
+func threadCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "thread",
+		Short: "Work with a single Slack thread",
+	}
+
+	var (
+		opts        threadOptions
+		granularity string
+		template    string
+	)
+	fetchCmd := &cobra.Command{
+		Use:   "fetch <permalink|channel_id:thread_ts>",
+		Short: "Fetch one thread and print it as markdown",
+		Long: `Fetch a thread's parent and all replies, given a Slack message permalink
+or CHANNEL_ID:THREAD_TS. A permalink to a reply fetches its whole thread.
+The transcript is printed as markdown; --save merges the messages into the
+cache's partitions instead, leaving other messages there untouched.
+
+Examples:
+  slack-intel thread fetch https://acme.slack.com/archives/C0123456789/p1700000100000100
+  slack-intel thread fetch C0123456789:1700000100.000100 --save`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			partitionBy, err := cache.ParseGranularity(granularity)
+			if err != nil {
+				return err
+			}
+			opts.granularity = partitionBy
+			nameTemplate, err := cache.ParseNameTemplate(template)
+			if err != nil {
+				return err
+			}
+			if err := nameTemplate.Validate(partitionBy); err != nil {
+				return err
+			}
+			opts.template = nameTemplate
+			return runThreadFetch(args[0], opts)
+		},
+	}
+	fetchCmd.Flags().BoolVar(&opts.save, "save", false, "Merge the thread into the Parquet cache instead of printing it")
+	fetchCmd.Flags().StringVar(&opts.cachePath, "cache-path", "cache/raw", "Cache directory (with --save)")
+	fetchCmd.Flags().StringVar(&granularity, "partition-granularity", string(cache.GranularityDay), "Partition size with --save: hour, day or month")
+	fetchCmd.Flags().StringVar(&template, "name-template", cache.DefaultNameTemplate, "Partition directory layout with --save (see cache --help)")
+	fetchCmd.Flags().StringVar(&opts.offlineFixture, "offline-fixture", "", "Serve Slack from a fakeslack JSON fixture instead of the API (development)")
+	fetchCmd.Flags().MarkHidden("offline-fixture")
+
+	cmd.AddCommand(fetchCmd)
+	return cmd
+}
+
+func runThreadFetch(arg string, opts threadOptions) error {
+	ref, err := slack.ParseThreadRef(arg)
+	if err != nil {
+		return err
+	}
+
+	clientOpts := []slack.Option{slack.WithLogger(logger)}
+	token, err := slackToken()
+	if opts.offlineFixture != "" {
+		api, err := fakeslack.Load(opts.offlineFixture)
+		if err != nil {
+			return err
+		}
+		clientOpts = append(clientOpts, slack.WithAPI(api))
+		if token == "" {
+			token = "xoxb-offline"
+		}
+	} else if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	slackClient := slack.NewClient(token, clientOpts...)
+	auth, err := slackClient.ValidateAuth(ctx)
+	if err != nil {
+		return tokenRejected(err)
+	}
+	if err := ref.CheckWorkspace(auth.URL); err != nil {
+		return err
+	}
+
+	thread, err := slackClient.GetThread(ctx, ref.ChannelID, ref.ThreadTS)
+	if err != nil {
+		return fmt.Errorf("failed to fetch thread %s in %s: %w", ref.ThreadTS, ref.ChannelID, err)
+	}
+	if len(thread) == 0 {
+		return fmt.Errorf("thread %s in %s has no messages", ref.ThreadTS, ref.ChannelID)
+	}
+
+	channel := threadChannel(ref.ChannelID)
+	if !opts.save {
+		fmt.Print(threadMarkdown(channel, thread))
+		return nil
+	}
+	return saveThread(ctx, channel, thread, opts)
+}
+
+// threadChannel names the channel like the cache command does: the config
+// name when the channel is configured, channel_<id> otherwise
+func threadChannel(channelID string) *models.SlackChannel {
+	if cfg, err := loadConfig(); err == nil {
+		for _, ch := range cfg.Channels {
+			if ch.ID == channelID {
+				return &models.SlackChannel{Name: ch.Name, ID: ch.ID}
+			}
+		}
+	}
+	return &models.SlackChannel{Name: fmt.Sprintf("channel_%s", channelID), ID: channelID}
+}
+
+// saveThread merges the thread into each partition its messages fall in
+func saveThread(ctx context.Context, channel *models.SlackChannel, thread []*models.SlackMessage, opts threadOptions) error {
+	parquetCache := cache.NewParquetCache(opts.cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+	parquetCache.SetNameTemplate(opts.template)
+	partFile, err := partFileName()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartFileName(partFile)
+	loc, err := partitionTimezone()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetPartitionTimezone(loc)
+	hours, err := activityHours()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetActivityHours(hours)
+
+	partitions := make(map[string][]*models.SlackMessage)
+	for _, msg := range thread {
+		key := opts.granularity.PartitionKey(msg.Timestamp.In(loc))
+		partitions[key] = append(partitions[key], msg)
+	}
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Println(titleStyle.Render(fmt.Sprintf("🧵 Saving thread from %s", channel.Name)))
+	for _, key := range keys {
+		path, err := parquetCache.MergeMessages(ctx, partitions[key], channel, key)
+		if err != nil {
+			return err
+		}
+		fmt.Println(successStyle.Render(fmt.Sprintf("✓ Merged %d message(s) into %s", len(partitions[key]), filepath.Dir(path))))
+	}
+
+	return nil
+}
+
+// threadMarkdown renders a thread (parent first) as a markdown transcript
+func threadMarkdown(channel *models.SlackChannel, thread []*models.SlackMessage) string {
+	var b strings.Builder
+	parent := thread[0]
+
+	fmt.Fprintf(&b, "# Thread in #%s\n\n", strings.TrimPrefix(channel.Name, "#"))
+	if parent.Permalink != "" {
+		fmt.Fprintf(&b, "%s\n\n", parent.Permalink)
+	}
+	fmt.Fprintf(&b, "Replies: %d\n\n", len(thread)-1)
+
+	for i, msg := range thread {
+		if i == 1 {
+			b.WriteString("---\n\n")
+		}
+		fmt.Fprintf(&b, "**%s** · %s\n\n", messageAuthor(msg), msg.Timestamp.UTC().Format("2006-01-02 15:04 UTC"))
+		text := strings.TrimSpace(msg.Text)
+		if text == "" {
+			text = "_(no text)_"
+		}
+		fmt.Fprintf(&b, "%s\n\n", text)
+	}
+
+	return b.String()
+}
+
+// messageAuthor returns the best available display name for a message's author
+func messageAuthor(msg *models.SlackMessage) string {
+	switch {
+	case msg.UserInfo != nil && msg.UserInfo.RealName != "":
+		return msg.UserInfo.RealName
+	case msg.UserInfo != nil && msg.UserInfo.Name != "":
+		return msg.UserInfo.Name
+	case msg.UserID != "":
+		return msg.UserID
+	case msg.BotID != "":
+		return "bot " + msg.BotID
+	}
+	return "unknown"
+}
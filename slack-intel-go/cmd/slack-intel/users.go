@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+func usersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "users",
+		Short: "Manage the cached user directory",
+	}
+
+	var cachePath string
+	syncCmd := &cobra.Command{
+		Use:   "sync",
+		Short: "Refresh users.parquet from users.list",
+		Long: `Page through users.list (requires the users:read scope) and merge every
+workspace user, including deactivated ones, into users.parquet. The cache
+command reads this file instead of calling users.info per user.
+
+Examples:
+  slack-intel users sync
+  slack-intel users sync --cache-path /data/slack/raw`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUsersSync(cachePath)
+		},
+	}
+	syncCmd.Flags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory")
+
+	cmd.AddCommand(syncCmd)
+	return cmd
+}
+
+func runUsersSync(cachePath string) error {
+	token, err := slackToken()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	slackClient := slack.NewClient(token, slack.WithLogger(logger))
+	parquetCache := cache.NewParquetCache(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+
+	fmt.Println(titleStyle.Render("👥 Syncing Slack Users"))
+
+	previous, err := parquetCache.LoadUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	fresh, err := slackClient.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	merged, changes := models.MergeUsers(previous, fresh)
+	usersPath, err := parquetCache.SaveUsers(merged)
+	if err != nil {
+		return fmt.Errorf("failed to save users: %w", err)
+	}
+
+	fmt.Println(successStyle.Render(fmt.Sprintf("✓ Synced %d users to %s", len(merged), filepath.Base(usersPath))))
+	fmt.Println(dimStyle.Render(fmt.Sprintf("  %d new, %d changed, %d deactivated since last sync",
+		changes.New, changes.Changed, changes.Deactivated)))
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+)
+
+func verifyCmd() *cobra.Command {
+	var (
+		cachePath string
+		repair    bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Check cached Parquet files for corruption and schema drift",
+		Long: `Open every Parquet file in the cache, read all rows and compare the
+schema with the one this version writes. Exits non-zero if any file fails.
+
+Examples:
+  # Verify the default cache
+  slack-intel verify
+
+  # Move unreadable files into .corrupt/
+  slack-intel verify --repair`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerify(cachePath, repair)
+		},
+	}
+
+	cmd.Flags().StringVar(&cachePath, "cache-path", "cache/raw", "Cache directory")
+	cmd.Flags().BoolVar(&repair, "repair", false, "Quarantine unreadable files into .corrupt/")
+
+	return cmd
+}
+
+func runVerify(cachePath string, repair bool) error {
+	parquetCache := cache.NewParquetCache(cachePath)
+	parquetCache.SetLogger(logger)
+	store, err := openStorage()
+	if err != nil {
+		return err
+	}
+	parquetCache.SetStorage(store)
+
+	fmt.Println(titleStyle.Render("🔍 Verifying Parquet Cache"))
+	fmt.Println(dimStyle.Render(fmt.Sprintf("Cache path: %s", cachePath)))
+	fmt.Println()
+
+	reports, err := parquetCache.Verify(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// The run manifest names the run that wrote a failing file
+	runs, err := parquetCache.LoadRuns()
+	if err != nil {
+		logger.Warn("ignoring run manifest", "error", err)
+	}
+
+	if len(reports) == 0 {
+		fmt.Println(dimStyle.Render("⚠ No Parquet files found"))
+		return nil
+	}
+
+	failed := 0
+	totalRows := int64(0)
+	for _, report := range reports {
+		name := report.Path
+		if rel, err := filepath.Rel(filepath.Dir(cachePath), report.Path); err == nil {
+			name = rel
+		}
+
+		if report.OK() {
+			totalRows += report.Rows
+			fmt.Printf("%s %s\n",
+				successStyle.Render(fmt.Sprintf("  ✓ %s", name)),
+				dimStyle.Render(fmt.Sprintf("(%d rows)", report.Rows)))
+			continue
+		}
+
+		failed++
+		fmt.Printf("%s\n", errorStyle.Render(fmt.Sprintf("  ✗ %s: %v", name, report.Err)))
+		if run, ok := cache.WroteFile(runs, report.Path); ok {
+			fmt.Println(dimStyle.Render(fmt.Sprintf("    written by run %s (%s, %s)", run.ID, run.ToolVersion, run.Status)))
+		}
+
+		if repair && report.Unreadable {
+			dest, err := parquetCache.Quarantine(report.Path)
+			if err != nil {
+				fmt.Printf("%s\n", errorStyle.Render(fmt.Sprintf("    ✗ %v", err)))
+				continue
+			}
+			fmt.Println(dimStyle.Render(fmt.Sprintf("    → moved to %s", dest)))
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("Files checked: %d\n", len(reports))
+	fmt.Printf("Total rows: %d\n", totalRows)
+	fmt.Printf("Failed: %d\n", failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d file(s) failed verification", failed)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func watchCmd() *cobra.Command {
+	cmd := cacheFetchCmd()
+	cmd.Use = "watch"
+	cmd.Short = "Keep caching new messages and alert on keywords"
+	cmd.Long = `Run cache --watch: cache new messages every --interval until stopped,
+with every cache flag available.
+
+With --keywords, each cycle matches the messages it writes against the
+keywords: plain keywords match anywhere in the text ignoring case, and
+keywords prefixed with re: are regular expressions (also ignoring case).
+Every match is appended to alerts.parquet in the cache directory, and with
+--notify-channel a short alert with the message's permalink is posted
+there via chat.postMessage (the token needs chat:write). A message alerts
+once: later cycles that fetch it again, or an edit, do not repeat it, and
+the token's own posts are never matched.
+
+The first cycle covers the --days/--hours window, so pass --hours 1 to
+only alert on recent messages.
+
+Examples:
+  # Post outage, refund and PCI mentions in C1234567890 to C9999999999
+  slack-intel watch --keywords "outage,refund,PCI" --channel C1234567890 --notify-channel C9999999999 --hours 1
+
+  # Log matches of a ticket pattern every 5 minutes, without posting
+  slack-intel watch --keywords 're:PROD-DOWN-\d+' --interval 5m --hours 1`
+	// watch is cache --watch; the flag stays for scripts that pass it
+	watch := cmd.Flags().Lookup("watch")
+	watch.Value.Set("true")
+	watch.DefValue = "true"
+	watch.Hidden = true
+
+	return cmd
+}
+
+// watch runs cache cycles every --interval until ctx is cancelled. The first
+// cycle covers the --days/--hours window; later cycles only fetch from each
+// channel's watermark.
+func (r *cacheRun) watch(ctx context.Context, windowStart time.Time) error {
+	failures := 0
+
+	for n := 1; ; n++ {
+		cycleStart := time.Now()
+		summary := r.cycle(ctx, windowStart, cycleStart)
+		summary.finish(cycleStart)
+
+		if summary.Status == "failed" {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		if r.opts.output == "json" {
+			if err := json.NewEncoder(os.Stdout).Encode(summary); err != nil {
+				return fmt.Errorf("failed to write summary: %w", err)
+			}
+		}
+
+		if ctx.Err() != nil {
+			fmt.Fprintln(r.out, dimStyle.Render(fmt.Sprintf("Stopped after cycle %d", n)))
+			return nil
+		}
+		if err := r.budgetErr(); err != nil {
+			return err
+		}
+
+		wait := jitter(r.opts.interval)
+		line := fmt.Sprintf("Cycle %d %s: %d messages from %d channel(s) in %v, %d consecutive failure(s), next in %v",
+			n, summary.Status, summary.Totals.Messages, summary.Totals.Channels,
+			time.Since(cycleStart).Round(time.Millisecond), failures, wait.Round(time.Second))
+		if summary.Status == "failed" {
+			fmt.Fprintf(r.out, "\n%s\n\n", errorStyle.Render(line))
+		} else {
+			fmt.Fprintf(r.out, "\n%s\n\n", dimStyle.Render(line))
+		}
+
+		select {
+		case <-ctx.Done():
+			fmt.Fprintln(r.out, dimStyle.Render(fmt.Sprintf("Stopped after cycle %d", n)))
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
+
+// jitter spreads d by up to ±10% so several daemons don't hit Slack in lockstep
+func jitter(d time.Duration) time.Duration {
+	spread := int64(d / 5)
+	if spread <= 0 {
+		return d
+	}
+	return d - d/10 + time.Duration(rand.Int63n(spread))
+}
@@ -0,0 +1,145 @@
+// Package analyze computes reports over cached messages. It works on
+// messages already read back from the cache and does no I/O of its own
+// beyond writing rendered reports.
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// Message is a cached message together with the channel it was cached under
+type Message struct {
+	Channel string
+	*models.SlackMessage
+}
+
+// Activity summarizes who posted what, where and when
+type Activity struct {
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Messages int            `json:"messages"`
+	Users    []UserActivity `json:"users"` // humans, most active first
+	Bots     []UserActivity `json:"bots"`
+	Channels []ChannelDay   `json:"channels"` // by channel, then day
+}
+
+// UserActivity counts one author's messages in the report window
+type UserActivity struct {
+	UserID   string `json:"user_id,omitempty"`
+	Name     string `json:"name"`
+	Messages int    `json:"messages"`
+	// ThreadsStarted counts thread parents with replies
+	ThreadsStarted int `json:"threads_started"`
+	// Threads counts distinct threads the author posted in, as parent or reply
+	Threads           int `json:"threads"`
+	ReactionsReceived int `json:"reactions_received"`
+}
+
+// ChannelDay counts one channel's messages on one UTC day
+type ChannelDay struct {
+	Channel  string `json:"channel"`
+	Day      string `json:"day"` // YYYY-MM-DD
+	Messages int    `json:"messages"`
+}
+
+// unknownBot keys bot messages that carry no user ID (the cache does not
+// keep bot IDs)
+const unknownBot = "bot"
+
+// Summarize computes activity for msgs over [from, to). users fills in names
+// for authors whose messages carry no user info; it may be nil.
+func Summarize(msgs []Message, users map[string]*models.SlackUser, from, to time.Time) *Activity {
+	a := &Activity{From: from, To: to}
+
+	authors := make(map[string]*UserActivity)
+	bots := make(map[string]bool)
+	threads := make(map[string]map[string]bool) // author -> channel/thread_ts
+	days := make(map[ChannelDay]int)
+
+	for _, m := range msgs {
+		a.Messages++
+		days[ChannelDay{Channel: m.Channel, Day: m.Timestamp.UTC().Format("2006-01-02")}]++
+
+		key, name, isBot := author(m.SlackMessage, users)
+		u, ok := authors[key]
+		if !ok {
+			u = &UserActivity{UserID: m.UserID, Name: name}
+			authors[key] = u
+			threads[key] = make(map[string]bool)
+		}
+		if isBot {
+			bots[key] = true
+		}
+
+		u.Messages++
+		if m.IsThreadParent() {
+			u.ThreadsStarted++
+		}
+		if m.IsThreadParent() || m.IsThreadReply() {
+			threads[key][m.Channel+"/"+m.ThreadTS] = true
+		}
+		for _, r := range m.Reactions {
+			u.ReactionsReceived += r.Count
+		}
+	}
+
+	for key, u := range authors {
+		u.Threads = len(threads[key])
+		if bots[key] {
+			a.Bots = append(a.Bots, *u)
+		} else {
+			a.Users = append(a.Users, *u)
+		}
+	}
+	sortByActivity(a.Users)
+	sortByActivity(a.Bots)
+
+	for day, n := range days {
+		day.Messages = n
+		a.Channels = append(a.Channels, day)
+	}
+	sort.Slice(a.Channels, func(i, j int) bool {
+		if a.Channels[i].Channel != a.Channels[j].Channel {
+			return a.Channels[i].Channel < a.Channels[j].Channel
+		}
+		return a.Channels[i].Day < a.Channels[j].Day
+	})
+
+	return a
+}
+
+// author returns the grouping key, display name and bot flag for a
+// message's author
+func author(m *models.SlackMessage, users map[string]*models.SlackUser) (key, name string, isBot bool) {
+	info := m.UserInfo
+	if info == nil && m.UserID != "" {
+		info = users[m.UserID]
+	}
+
+	switch {
+	case m.UserID == "" && m.BotID != "":
+		return m.BotID, "bot " + m.BotID, true
+	case m.UserID == "":
+		return unknownBot, "unknown bot", true
+	case info == nil:
+		return m.UserID, m.UserID, m.BotID != ""
+	case info.RealName != "":
+		return m.UserID, info.RealName, info.IsBot || m.BotID != ""
+	case info.Name != "":
+		return m.UserID, info.Name, info.IsBot || m.BotID != ""
+	}
+	return m.UserID, m.UserID, info.IsBot || m.BotID != ""
+}
+
+// sortByActivity orders authors by message count, then name
+func sortByActivity(authors []UserActivity) {
+	sort.Slice(authors, func(i, j int) bool {
+		if authors[i].Messages != authors[j].Messages {
+			return authors[i].Messages > authors[j].Messages
+		}
+		return authors[i].Name < authors[j].Name
+	})
+}
@@ -0,0 +1,101 @@
+package analyze
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// fixture is two days of #backend and one message in #general
+func fixture() []Message {
+	alice := &models.SlackUser{ID: "U1", Name: "alice", RealName: "Alice"}
+	deploy := &models.SlackUser{ID: "U9", Name: "deploybot", IsBot: true}
+	day1 := time.Date(2023, 11, 14, 9, 0, 0, 0, time.UTC)
+	day2 := day1.AddDate(0, 0, 1)
+
+	return []Message{
+		{"backend", &models.SlackMessage{MessageID: "1.0", UserID: "U1", UserInfo: alice, Timestamp: day1, ThreadTS: "1.0", ReplyCount: 2,
+			Reactions: []models.SlackReaction{{Emoji: "eyes", Count: 2}, {Emoji: "+1", Count: 1}}}},
+		{"backend", &models.SlackMessage{MessageID: "1.1", UserID: "U2", Timestamp: day1.Add(time.Minute), ThreadTS: "1.0"}},
+		{"backend", &models.SlackMessage{MessageID: "1.2", UserID: "U1", UserInfo: alice, Timestamp: day1.Add(2 * time.Minute), ThreadTS: "1.0"}},
+		{"backend", &models.SlackMessage{MessageID: "2.0", UserID: "U2", Timestamp: day2,
+			Reactions: []models.SlackReaction{{Emoji: "tada", Count: 3}}}},
+		{"backend", &models.SlackMessage{MessageID: "3.0", UserID: "U9", UserInfo: deploy, Timestamp: day2}},
+		{"general", &models.SlackMessage{MessageID: "4.0", Timestamp: day2}},
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	users := map[string]*models.SlackUser{"U2": {ID: "U2", Name: "bob", RealName: "Bob"}}
+	a := Summarize(fixture(), users, time.Time{}, time.Time{})
+
+	if a.Messages != 6 {
+		t.Errorf("Messages = %d, want 6", a.Messages)
+	}
+
+	wantUsers := []UserActivity{
+		{UserID: "U1", Name: "Alice", Messages: 2, ThreadsStarted: 1, Threads: 1, ReactionsReceived: 3},
+		{UserID: "U2", Name: "Bob", Messages: 2, Threads: 1, ReactionsReceived: 3},
+	}
+	if len(a.Users) != len(wantUsers) {
+		t.Fatalf("Users = %+v, want %+v", a.Users, wantUsers)
+	}
+	for i, want := range wantUsers {
+		if a.Users[i] != want {
+			t.Errorf("Users[%d] = %+v, want %+v", i, a.Users[i], want)
+		}
+	}
+
+	if len(a.Bots) != 2 || a.Bots[0].Name != "deploybot" || a.Bots[1].Name != "unknown bot" {
+		t.Errorf("Bots = %+v, want deploybot and the unknown bot", a.Bots)
+	}
+
+	wantDays := []ChannelDay{
+		{Channel: "backend", Day: "2023-11-14", Messages: 3},
+		{Channel: "backend", Day: "2023-11-15", Messages: 2},
+		{Channel: "general", Day: "2023-11-15", Messages: 1},
+	}
+	if len(a.Channels) != len(wantDays) {
+		t.Fatalf("Channels = %+v, want %+v", a.Channels, wantDays)
+	}
+	for i, want := range wantDays {
+		if a.Channels[i] != want {
+			t.Errorf("Channels[%d] = %+v, want %+v", i, a.Channels[i], want)
+		}
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	a := Summarize(fixture(), nil, time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC), time.Date(2023, 11, 16, 0, 0, 0, 0, time.UTC))
+
+	var md bytes.Buffer
+	if err := Write(&md, a, "markdown"); err != nil {
+		t.Fatalf("markdown: %v", err)
+	}
+	for _, want := range []string{"# Activity 2023-11-14 – 2023-11-16", "| Alice | 2 | 1 | 1 | 3 |", "## Bots", "| #general | 2023-11-15 | 1 |"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown missing %q:\n%s", want, md.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := Write(&out, a, "csv"); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("csv does not parse: %v", err)
+	}
+	// header + 2 users + 2 bots + 3 channel days
+	if len(rows) != 8 || rows[1][0] != "user" || rows[1][2] != "Alice" || rows[7][0] != "channel_day" {
+		t.Errorf("csv rows = %v", rows)
+	}
+
+	if err := Write(&out, a, "xml"); err == nil {
+		t.Error("Write(xml) succeeded, want an error")
+	}
+}
@@ -0,0 +1,237 @@
+package analyze
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// DigestFormats lists the output formats WriteDigest accepts
+var DigestFormats = []string{"markdown", "json"}
+
+// digestSize caps each ranked list of a digest
+const digestSize = 5
+
+// digestPreview is how much of a message's text a digest quotes
+const digestPreview = 120
+
+// Digest summarizes a period of cached messages for a channel update: the
+// busiest threads, the most reacted messages, the JIRA tickets mentioned
+// and how many people took part
+type Digest struct {
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Channels     []string        `json:"channels"` // channels with messages, sorted
+	Messages     int             `json:"messages"`
+	Participants int             `json:"participants"` // distinct human authors
+	Threads      []DigestMessage `json:"threads"`      // most replies first
+	Reacted      []DigestMessage `json:"reacted"`      // most reactions first
+	Tickets      []DigestTicket  `json:"tickets"`      // most mentioned first
+}
+
+// DigestMessage is one message a digest points at
+type DigestMessage struct {
+	Channel   string `json:"channel"`
+	Author    string `json:"author"`
+	Text      string `json:"text"` // whitespace collapsed and cut short
+	Permalink string `json:"permalink,omitempty"`
+	Replies   int    `json:"replies"`
+	Reactions int    `json:"reactions"`
+}
+
+// DigestTicket counts the messages mentioning a JIRA ticket
+type DigestTicket struct {
+	Ticket   string   `json:"ticket"`
+	Mentions int      `json:"mentions"`
+	Channels []string `json:"channels"`
+}
+
+// BuildDigest summarizes the messages posted in [from, to)
+func BuildDigest(msgs []Message, users map[string]*models.SlackUser, from, to time.Time) *Digest {
+	d := &Digest{From: from, To: to, Channels: []string{}, Threads: []DigestMessage{}, Reacted: []DigestMessage{}, Tickets: []DigestTicket{}}
+
+	channels := make(map[string]bool)
+	people := make(map[string]bool)
+	tickets := make(map[string]*DigestTicket)
+	var threads, reacted []DigestMessage
+	for _, m := range msgs {
+		if m.Timestamp.Before(from) || !m.Timestamp.Before(to) {
+			continue
+		}
+		d.Messages++
+		channels[m.Channel] = true
+		key, name, isBot := author(m.SlackMessage, users)
+		if !isBot {
+			people[key] = true
+		}
+
+		reactions := 0
+		for _, r := range m.Reactions {
+			reactions += r.Count
+		}
+		entry := DigestMessage{
+			Channel:   m.Channel,
+			Author:    name,
+			Text:      preview(m.Text, digestPreview),
+			Permalink: m.Permalink,
+			Replies:   m.ReplyCount,
+			Reactions: reactions,
+		}
+		if m.IsThreadParent() {
+			threads = append(threads, entry)
+		}
+		if reactions > 0 {
+			reacted = append(reacted, entry)
+		}
+
+		seen := make(map[string]bool, len(m.JiraTickets))
+		for _, ticket := range m.JiraTickets {
+			if ticket == "" || seen[ticket] {
+				continue
+			}
+			seen[ticket] = true
+			t, ok := tickets[ticket]
+			if !ok {
+				t = &DigestTicket{Ticket: ticket}
+				tickets[ticket] = t
+			}
+			t.Mentions++
+			t.Channels = insertSorted(t.Channels, m.Channel)
+		}
+	}
+
+	for ch := range channels {
+		d.Channels = append(d.Channels, ch)
+	}
+	sort.Strings(d.Channels)
+	d.Participants = len(people)
+
+	sort.SliceStable(threads, func(i, j int) bool { return threads[i].Replies > threads[j].Replies })
+	d.Threads = append(d.Threads, threads[:min(len(threads), digestSize)]...)
+	sort.SliceStable(reacted, func(i, j int) bool { return reacted[i].Reactions > reacted[j].Reactions })
+	d.Reacted = append(d.Reacted, reacted[:min(len(reacted), digestSize)]...)
+
+	for _, t := range tickets {
+		d.Tickets = append(d.Tickets, *t)
+	}
+	sort.Slice(d.Tickets, func(i, j int) bool {
+		if d.Tickets[i].Mentions != d.Tickets[j].Mentions {
+			return d.Tickets[i].Mentions > d.Tickets[j].Mentions
+		}
+		return d.Tickets[i].Ticket < d.Tickets[j].Ticket
+	})
+	return d
+}
+
+// insertSorted adds s to the sorted set values
+func insertSorted(values []string, s string) []string {
+	i := sort.SearchStrings(values, s)
+	if i < len(values) && values[i] == s {
+		return values
+	}
+	return append(values[:i], append([]string{s}, values[i:]...)...)
+}
+
+// Title names the digest's period and channels, e.g.
+// "Digest for 2024-05-09: #backend, #incidents"
+func (d *Digest) Title() string {
+	period := d.From.Format("2006-01-02")
+	if last := d.To.Add(-time.Nanosecond); last.Format("2006-01-02") != period {
+		period += " to " + last.Format("2006-01-02")
+	}
+	channels := make([]string, len(d.Channels))
+	for i, ch := range d.Channels {
+		channels[i] = "#" + ch
+	}
+	if len(channels) == 0 {
+		return "Digest for " + period
+	}
+	return "Digest for " + period + ": " + strings.Join(channels, ", ")
+}
+
+// Summary is the digest's headline, e.g. "120 messages from 9 people in 2 channels"
+func (d *Digest) Summary() string {
+	return fmt.Sprintf("%s from %s in %s", plural(d.Messages, "message"), plural(d.Participants, "person"), plural(len(d.Channels), "channel"))
+}
+
+// Sections renders the digest as Slack mrkdwn, one string per part
+// (summary, threads, reactions, tickets), each line a list item. Parts
+// with nothing to list are left out.
+func (d *Digest) Sections() []string {
+	sections := []string{d.Summary()}
+	link := func(m DigestMessage) string {
+		text := strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(m.Text)
+		if m.Permalink != "" {
+			return fmt.Sprintf("<%s|%s>", m.Permalink, strings.ReplaceAll(text, "|", "¦"))
+		}
+		return text
+	}
+	if len(d.Threads) > 0 {
+		lines := []string{"*Top threads*"}
+		for _, m := range d.Threads {
+			lines = append(lines, fmt.Sprintf("• #%s, %s, %s: %s", m.Channel, plural(m.Replies, "reply"), m.Author, link(m)))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	if len(d.Reacted) > 0 {
+		lines := []string{"*Most reacted*"}
+		for _, m := range d.Reacted {
+			lines = append(lines, fmt.Sprintf("• #%s, %s, %s: %s", m.Channel, plural(m.Reactions, "reaction"), m.Author, link(m)))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	if len(d.Tickets) > 0 {
+		lines := []string{"*JIRA tickets*"}
+		for _, t := range d.Tickets {
+			lines = append(lines, fmt.Sprintf("• %s: %s in #%s", t.Ticket, plural(t.Mentions, "mention"), strings.Join(t.Channels, ", #")))
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+	return sections
+}
+
+// WriteDigest renders a digest as markdown or json
+func WriteDigest(w io.Writer, d *Digest, format string) error {
+	switch format {
+	case "markdown":
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s\n\n%s.\n", d.Title(), d.Summary())
+		message := func(m DigestMessage, count string) {
+			text := m.Text
+			if m.Permalink != "" {
+				text = fmt.Sprintf("[%s](%s)", strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text), m.Permalink)
+			}
+			fmt.Fprintf(&b, "- #%s, %s, %s: %s\n", m.Channel, count, m.Author, text)
+		}
+		if len(d.Threads) > 0 {
+			b.WriteString("\n## Top threads\n\n")
+			for _, m := range d.Threads {
+				message(m, plural(m.Replies, "reply"))
+			}
+		}
+		if len(d.Reacted) > 0 {
+			b.WriteString("\n## Most reacted\n\n")
+			for _, m := range d.Reacted {
+				message(m, plural(m.Reactions, "reaction"))
+			}
+		}
+		if len(d.Tickets) > 0 {
+			b.WriteString("\n## JIRA tickets\n\n")
+			for _, t := range d.Tickets {
+				fmt.Fprintf(&b, "- %s: %s in #%s\n", t.Ticket, plural(t.Mentions, "mention"), strings.Join(t.Channels, ", #"))
+			}
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	}
+	return fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(DigestFormats, ", "))
+}
@@ -0,0 +1,69 @@
+package analyze
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestBuildDigest(t *testing.T) {
+	from := time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 1)
+	users := map[string]*models.SlackUser{"U1": {ID: "U1", RealName: "Alice"}}
+	msgs := []Message{
+		{"backend", &models.SlackMessage{MessageID: "1.0", ThreadTS: "1.0", ReplyCount: 2, UserID: "U1", Text: "deploy <failed> on PROJ-1", Timestamp: from.Add(time.Hour), JiraTickets: []string{"PROJ-1"}}},
+		{"backend", &models.SlackMessage{MessageID: "2.0", ThreadTS: "2.0", ReplyCount: 7, UserID: "U2", Text: "rollback?", Permalink: "https://acme.slack.com/archives/C1/p2", Timestamp: from.Add(2 * time.Hour)}},
+		{"incidents", &models.SlackMessage{MessageID: "3.0", UserID: "U1", Text: "PROJ-1 paged again", Timestamp: from.Add(3 * time.Hour), JiraTickets: []string{"PROJ-1", "PROJ-1"},
+			Reactions: []models.SlackReaction{{Emoji: "eyes", Count: 3}}}},
+		{"incidents", &models.SlackMessage{MessageID: "4.0", BotID: "B1", Text: "PROJ-2 opened", Timestamp: from.Add(4 * time.Hour), JiraTickets: []string{"PROJ-2"}}},
+		{"incidents", &models.SlackMessage{MessageID: "5.0", UserID: "U3", Text: "the day after", Timestamp: to}},
+	}
+
+	d := BuildDigest(msgs, users, from, to)
+
+	if d.Messages != 4 || d.Participants != 2 || strings.Join(d.Channels, ",") != "backend,incidents" {
+		t.Errorf("messages/participants/channels = %d/%d/%v, want 4/2/[backend incidents]", d.Messages, d.Participants, d.Channels)
+	}
+	if len(d.Threads) != 2 || d.Threads[0].Replies != 7 || d.Threads[1].Author != "Alice" {
+		t.Errorf("threads = %+v, want the 7-reply thread first, then Alice's", d.Threads)
+	}
+	if len(d.Reacted) != 1 || d.Reacted[0].Reactions != 3 {
+		t.Errorf("reacted = %+v, want the message with 3 reactions", d.Reacted)
+	}
+	if len(d.Tickets) != 2 || d.Tickets[0].Ticket != "PROJ-1" || d.Tickets[0].Mentions != 2 || strings.Join(d.Tickets[0].Channels, ",") != "backend,incidents" {
+		t.Errorf("tickets = %+v, want PROJ-1 mentioned twice in backend and incidents first", d.Tickets)
+	}
+	if got := d.Title(); got != "Digest for 2024-05-09: #backend, #incidents" {
+		t.Errorf("Title() = %q", got)
+	}
+
+	sections := d.Sections()
+	if len(sections) != 4 || sections[0] != "4 messages from 2 people in 2 channels" {
+		t.Fatalf("sections = %q, want summary, threads, reactions and tickets", sections)
+	}
+	if !strings.Contains(sections[1], "• #backend, 7 replies, U2: <https://acme.slack.com/archives/C1/p2|rollback?>") || !strings.Contains(sections[1], "deploy &lt;failed&gt;") {
+		t.Errorf("threads section = %q, want linked and escaped entries", sections[1])
+	}
+
+	var b bytes.Buffer
+	if err := WriteDigest(&b, d, "markdown"); err != nil {
+		t.Fatalf("WriteDigest: %v", err)
+	}
+	if !strings.Contains(b.String(), "## JIRA tickets\n\n- PROJ-1: 2 mentions in #backend, #incidents\n") {
+		t.Errorf("markdown missing the ticket list:\n%s", b.String())
+	}
+}
+
+func TestBuildDigestEmpty(t *testing.T) {
+	from := time.Date(2024, 5, 9, 0, 0, 0, 0, time.UTC)
+	d := BuildDigest(nil, nil, from, from.AddDate(0, 0, 1))
+	if sections := d.Sections(); len(sections) != 1 || sections[0] != "0 messages from 0 people in 0 channels" {
+		t.Errorf("sections = %q, want only the summary", sections)
+	}
+	if d.Title() != "Digest for 2024-05-09" {
+		t.Errorf("Title() = %q", d.Title())
+	}
+}
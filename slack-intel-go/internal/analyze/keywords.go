@@ -0,0 +1,95 @@
+package analyze
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// Keywords matches message text against watch keywords. A plain keyword
+// matches anywhere in the text, ignoring case; a keyword with the "re:"
+// prefix is a regular expression, also matched ignoring case.
+type Keywords struct {
+	keywords []keyword
+}
+
+type keyword struct {
+	name  string // as given, e.g. "outage" or "re:PROD-\d+"
+	plain string // lower-cased plain keyword; empty for a regexp
+	re    *regexp.Regexp
+}
+
+// ParseKeywords compiles watch keywords, ignoring blank ones
+func ParseKeywords(specs []string) (*Keywords, error) {
+	k := &Keywords{}
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		pattern, isRegexp := strings.CutPrefix(spec, "re:")
+		if !isRegexp {
+			k.keywords = append(k.keywords, keyword{name: spec, plain: strings.ToLower(spec)})
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid keyword %q: %w", spec, err)
+		}
+		k.keywords = append(k.keywords, keyword{name: spec, re: re})
+	}
+	if len(k.keywords) == 0 {
+		return nil, fmt.Errorf("no keywords given")
+	}
+	return k, nil
+}
+
+// String lists the keywords as given, comma-separated
+func (k *Keywords) String() string {
+	names := make([]string, len(k.keywords))
+	for i, kw := range k.keywords {
+		names[i] = kw.name
+	}
+	return strings.Join(names, ", ")
+}
+
+// Match returns the keywords found in text, in the order they were given
+func (k *Keywords) Match(text string) []string {
+	lower := strings.ToLower(text)
+	var matched []string
+	for _, kw := range k.keywords {
+		if kw.re != nil && kw.re.MatchString(text) || kw.re == nil && strings.Contains(lower, kw.plain) {
+			matched = append(matched, kw.name)
+		}
+	}
+	return matched
+}
+
+// Alerts returns an alert, matched at the given time, for each of a
+// channel's messages whose text matches a keyword, oldest first
+func (k *Keywords) Alerts(channel *models.SlackChannel, messages []*models.SlackMessage, at time.Time) []*models.Alert {
+	var alerts []*models.Alert
+	for _, msg := range messages {
+		matched := k.Match(msg.Text)
+		if len(matched) == 0 {
+			continue
+		}
+		alerts = append(alerts, &models.Alert{
+			ChannelID: channel.ID,
+			Channel:   channel.Name,
+			MessageID: msg.MessageID,
+			UserID:    msg.UserID,
+			Text:      msg.Text,
+			Permalink: msg.Permalink,
+			Keywords:  matched,
+			Timestamp: msg.Timestamp,
+			MatchedAt: at,
+		})
+	}
+	sort.SliceStable(alerts, func(i, j int) bool { return alerts[i].Timestamp.Before(alerts[j].Timestamp) })
+	return alerts
+}
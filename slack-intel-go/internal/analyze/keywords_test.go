@@ -0,0 +1,61 @@
+package analyze
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestKeywordsMatch(t *testing.T) {
+	k, err := ParseKeywords([]string{"outage", " refund ", "", `re:PROD-\d+`})
+	if err != nil {
+		t.Fatalf("ParseKeywords: %v", err)
+	}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"Major OUTAGE in eu-west", "outage"},
+		{"refunds are failing, see prod-123", `refund,re:PROD-\d+`},
+		{"PROD-DOWN without a number", ""},
+		{"all good", ""},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(k.Match(tt.text), ","); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+
+	if _, err := ParseKeywords([]string{"re:("}); err == nil {
+		t.Error("ParseKeywords accepted an invalid regexp")
+	}
+	if _, err := ParseKeywords([]string{" ", ""}); err == nil {
+		t.Error("ParseKeywords accepted no keywords")
+	}
+}
+
+func TestKeywordAlerts(t *testing.T) {
+	k, _ := ParseKeywords([]string{"pci"})
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	channel := &models.SlackChannel{ID: "C1", Name: "payments"}
+	msgs := []*models.SlackMessage{
+		{MessageID: "1700000200.000100", UserID: "U2", Text: "PCI scan done", Timestamp: time.Unix(1700000200, 0),
+			Permalink: "https://acme.slack.com/archives/C1/p1700000200000100"},
+		{MessageID: "1700000100.000100", UserID: "U1", Text: "pci audit tomorrow", Timestamp: time.Unix(1700000100, 0)},
+		{MessageID: "1700000300.000100", UserID: "U1", Text: "lunch?", Timestamp: time.Unix(1700000300, 0)},
+	}
+
+	alerts := k.Alerts(channel, msgs, now)
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2", len(alerts))
+	}
+	if a := alerts[0]; a.MessageID != "1700000100.000100" || a.Key() != "C1/1700000100.000100" || !a.MatchedAt.Equal(now) {
+		t.Errorf("first alert = %+v, want the oldest match", a)
+	}
+	if a := alerts[1]; a.Channel != "payments" || a.Permalink == "" || strings.Join(a.Keywords, ",") != "pci" {
+		t.Errorf("second alert = %+v", a)
+	}
+}
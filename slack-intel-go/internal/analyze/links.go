@@ -0,0 +1,132 @@
+package analyze
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// LinkReport ranks the domains and URLs shared in messages
+type LinkReport struct {
+	From     time.Time   `json:"from"`
+	To       time.Time   `json:"to"`
+	Shares   int         `json:"shares"`
+	Excluded int         `json:"excluded"` // Slack archive and localhost links left out
+	Domains  []LinkShare `json:"domains"`  // most shared first
+	URLs     []LinkShare `json:"urls"`
+}
+
+// LinkShare counts the messages sharing one domain or URL, the distinct
+// people who shared it and the channels it appeared in
+type LinkShare struct {
+	Domain   string   `json:"domain"`
+	URL      string   `json:"url,omitempty"` // empty for domain rows
+	Shares   int      `json:"shares"`
+	Sharers  int      `json:"sharers"`
+	Channels []string `json:"channels"`
+}
+
+// LinkOptions configures Links
+type LinkOptions struct {
+	// IncludeInternal keeps Slack archive links and localhost URLs
+	IncludeInternal bool
+	// Users names authors whose messages carry no user info
+	Users map[string]*models.SlackUser
+}
+
+// linkTally accumulates one LinkShare
+type linkTally struct {
+	share    LinkShare
+	sharers  map[string]bool
+	channels map[string]bool
+}
+
+// Links aggregates the urls of messages posted in [from, to) by domain
+// and by full URL. A message counts once per URL it shares.
+func Links(msgs []Message, from, to time.Time, opts LinkOptions) *LinkReport {
+	report := &LinkReport{From: from, To: to}
+	domains := make(map[string]*linkTally)
+	urls := make(map[string]*linkTally)
+
+	for _, m := range msgs {
+		sharer, _, _ := author(m.SlackMessage, opts.Users)
+		for _, raw := range m.URLs {
+			u, err := url.Parse(raw)
+			if err != nil || u.Hostname() == "" {
+				continue
+			}
+			if !opts.IncludeInternal && internalLink(u) {
+				report.Excluded++
+				continue
+			}
+			report.Shares++
+
+			domain := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+			tally(domains, domain, LinkShare{Domain: domain}, sharer, m.Channel)
+			tally(urls, raw, LinkShare{Domain: domain, URL: raw}, sharer, m.Channel)
+		}
+	}
+
+	report.Domains = rankLinks(domains)
+	report.URLs = rankLinks(urls)
+	return report
+}
+
+// tally counts one share of key, creating its entry from share on first use
+func tally(tallies map[string]*linkTally, key string, share LinkShare, sharer, channel string) {
+	t, ok := tallies[key]
+	if !ok {
+		t = &linkTally{share: share, sharers: make(map[string]bool), channels: make(map[string]bool)}
+		tallies[key] = t
+	}
+	t.share.Shares++
+	t.sharers[sharer] = true
+	t.channels[channel] = true
+}
+
+// rankLinks orders by shares, then sharers, then domain and URL
+func rankLinks(tallies map[string]*linkTally) []LinkShare {
+	ranked := make([]LinkShare, 0, len(tallies))
+	for _, t := range tallies {
+		share := t.share
+		share.Sharers = len(t.sharers)
+		for channel := range t.channels {
+			share.Channels = append(share.Channels, channel)
+		}
+		sort.Strings(share.Channels)
+		ranked = append(ranked, share)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		switch {
+		case a.Shares != b.Shares:
+			return a.Shares > b.Shares
+		case a.Sharers != b.Sharers:
+			return a.Sharers > b.Sharers
+		case a.Domain != b.Domain:
+			return a.Domain < b.Domain
+		}
+		return a.URL < b.URL
+	})
+	return ranked
+}
+
+// internalLink reports whether u points back into Slack's message archive
+// or at the sharer's own machine
+func internalLink(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.IsLoopback() || ip.IsUnspecified()
+	}
+	if host == "slack.com" || strings.HasSuffix(host, ".slack.com") {
+		return strings.HasPrefix(u.Path, "/archives/") || host == "app.slack.com"
+	}
+	return false
+}
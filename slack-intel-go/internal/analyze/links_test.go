@@ -0,0 +1,58 @@
+package analyze
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestLinks(t *testing.T) {
+	now := time.Date(2023, 11, 30, 0, 0, 0, 0, time.UTC)
+	users := map[string]*models.SlackUser{"U1": {ID: "U1", RealName: "Alice"}}
+	msgs := []Message{
+		{"backend", &models.SlackMessage{MessageID: "1.0", UserID: "U1", URLs: []string{
+			"https://github.com/org/repo/pull/1",
+			"https://acme.slack.com/archives/C1/p1700000100000100",
+		}}},
+		{"general", &models.SlackMessage{MessageID: "2.0", UserID: "U2", URLs: []string{
+			"https://GitHub.com/org/repo/pull/1",
+			"https://www.github.com/org/other",
+			"http://localhost:8080/debug",
+		}}},
+		{"general", &models.SlackMessage{MessageID: "3.0", UserID: "U1", URLs: []string{
+			"https://github.com/org/repo/pull/1",
+			"https://go.dev/doc",
+		}}},
+	}
+
+	r := Links(msgs, now.AddDate(0, 0, -14), now, LinkOptions{Users: users})
+
+	if r.Shares != 5 || r.Excluded != 2 {
+		t.Errorf("shares/excluded = %d/%d, want 5/2", r.Shares, r.Excluded)
+	}
+	if len(r.Domains) != 2 {
+		t.Fatalf("domains = %+v, want github.com and go.dev", r.Domains)
+	}
+	if d := r.Domains[0]; d.Domain != "github.com" || d.Shares != 4 || d.Sharers != 2 || strings.Join(d.Channels, ",") != "backend,general" {
+		t.Errorf("top domain = %+v, want github.com with 4 shares by 2 people in backend and general", d)
+	}
+	if u := r.URLs[0]; u.URL != "https://github.com/org/repo/pull/1" || u.Shares != 2 || u.Sharers != 1 || u.Domain != "github.com" {
+		t.Errorf("top url = %+v, want the pull request shared twice by Alice", u)
+	}
+
+	all := Links(msgs, now.AddDate(0, 0, -14), now, LinkOptions{IncludeInternal: true})
+	if all.Shares != 7 || all.Excluded != 0 || len(all.Domains) != 4 {
+		t.Errorf("with internal links = %d shares, %d excluded, %d domains, want 7, 0, 4", all.Shares, all.Excluded, len(all.Domains))
+	}
+
+	var b bytes.Buffer
+	if err := WriteLinks(&b, r, "markdown"); err != nil {
+		t.Fatalf("WriteLinks: %v", err)
+	}
+	if !strings.Contains(b.String(), "| github.com | 4 | 2 | #backend, #general |") || !strings.Contains(b.String(), "2 links to Slack archives") {
+		t.Errorf("markdown missing the github.com row or the exclusion note:\n%s", b.String())
+	}
+}
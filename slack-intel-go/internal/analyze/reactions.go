@@ -0,0 +1,154 @@
+package analyze
+
+import (
+	"sort"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// ReactionReport ranks emoji and the people giving and receiving them,
+// with emoji counts compared against the period before
+type ReactionReport struct {
+	From          time.Time        `json:"from"`
+	To            time.Time        `json:"to"`
+	Total         int              `json:"total"`
+	PreviousTotal int              `json:"previous_total"`
+	Emoji         []EmojiCount     `json:"emoji"` // most used first
+	Channels      []ChannelEmoji   `json:"channels"`
+	Receivers     []ReactionPerson `json:"receivers"`
+	Givers        []ReactionPerson `json:"givers"`
+}
+
+// EmojiCount is how often one emoji (skin tones folded in) was used
+type EmojiCount struct {
+	Emoji    string `json:"emoji"`
+	Count    int    `json:"count"`
+	Previous int    `json:"previous"` // in the period before From
+}
+
+// ChannelEmoji lists one channel's emoji, most used first
+type ChannelEmoji struct {
+	Channel string       `json:"channel"`
+	Emoji   []EmojiCount `json:"emoji"`
+}
+
+// ReactionPerson counts reactions a person received on their messages or
+// gave to others
+type ReactionPerson struct {
+	UserID string `json:"user_id,omitempty"`
+	Name   string `json:"name"`
+	Count  int    `json:"count"`
+}
+
+// Reactions builds the leaderboard for messages posted in [from, to);
+// previous holds the messages of the period before, for the trend. Givers
+// come from each reaction's user list, which redacted caches leave empty.
+func Reactions(current, previous []Message, users map[string]*models.SlackUser, from, to time.Time) *ReactionReport {
+	report := &ReactionReport{From: from, To: to}
+
+	overall := make(map[string]*EmojiCount)
+	perChannel := make(map[string]map[string]int)
+	received := make(map[string]*ReactionPerson)
+	given := make(map[string]*ReactionPerson)
+
+	for _, m := range current {
+		for _, r := range m.Reactions {
+			name := models.BaseEmoji(r.Emoji)
+			report.Total += r.Count
+
+			e, ok := overall[name]
+			if !ok {
+				e = &EmojiCount{Emoji: name}
+				overall[name] = e
+			}
+			e.Count += r.Count
+
+			if perChannel[m.Channel] == nil {
+				perChannel[m.Channel] = make(map[string]int)
+			}
+			perChannel[m.Channel][name] += r.Count
+
+			key, authorName, _ := author(m.SlackMessage, users)
+			person(received, key, m.UserID, authorName).Count += r.Count
+
+			for _, id := range r.Users {
+				giver := &models.SlackMessage{UserID: id}
+				_, giverName, _ := author(giver, users)
+				person(given, id, id, giverName).Count++
+			}
+		}
+	}
+
+	for _, m := range previous {
+		for _, r := range m.Reactions {
+			name := models.BaseEmoji(r.Emoji)
+			report.PreviousTotal += r.Count
+			e, ok := overall[name]
+			if !ok {
+				e = &EmojiCount{Emoji: name}
+				overall[name] = e
+			}
+			e.Previous += r.Count
+		}
+	}
+
+	for _, e := range overall {
+		report.Emoji = append(report.Emoji, *e)
+	}
+	sortEmoji(report.Emoji)
+
+	for channel, counts := range perChannel {
+		ce := ChannelEmoji{Channel: channel}
+		for name, n := range counts {
+			ce.Emoji = append(ce.Emoji, EmojiCount{Emoji: name, Count: n})
+		}
+		sortEmoji(ce.Emoji)
+		report.Channels = append(report.Channels, ce)
+	}
+	sort.Slice(report.Channels, func(i, j int) bool {
+		return report.Channels[i].Channel < report.Channels[j].Channel
+	})
+
+	report.Receivers = rankPeople(received)
+	report.Givers = rankPeople(given)
+	return report
+}
+
+// person returns the counter for key, creating it on first use
+func person(people map[string]*ReactionPerson, key, userID, name string) *ReactionPerson {
+	p, ok := people[key]
+	if !ok {
+		p = &ReactionPerson{UserID: userID, Name: name}
+		people[key] = p
+	}
+	return p
+}
+
+// sortEmoji orders by count, then previous count, then name
+func sortEmoji(emoji []EmojiCount) {
+	sort.Slice(emoji, func(i, j int) bool {
+		if emoji[i].Count != emoji[j].Count {
+			return emoji[i].Count > emoji[j].Count
+		}
+		if emoji[i].Previous != emoji[j].Previous {
+			return emoji[i].Previous > emoji[j].Previous
+		}
+		return emoji[i].Emoji < emoji[j].Emoji
+	})
+}
+
+// rankPeople flattens counters, highest count first
+func rankPeople(people map[string]*ReactionPerson) []ReactionPerson {
+	ranked := make([]ReactionPerson, 0, len(people))
+	for _, p := range people {
+		ranked = append(ranked, *p)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Name < ranked[j].Name
+	})
+	return ranked
+}
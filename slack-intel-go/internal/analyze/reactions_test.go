@@ -0,0 +1,86 @@
+package analyze
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestReactions(t *testing.T) {
+	now := time.Date(2023, 11, 30, 0, 0, 0, 0, time.UTC)
+	users := map[string]*models.SlackUser{
+		"U1": {ID: "U1", RealName: "Alice"},
+		"U2": {ID: "U2", RealName: "Bob"},
+		"U3": {ID: "U3", RealName: "Carol"},
+	}
+
+	current := []Message{
+		{"backend", &models.SlackMessage{MessageID: "1.0", UserID: "U1", Reactions: []models.SlackReaction{
+			{Emoji: "+1", Count: 2, Users: []string{"U2", "U3"}},
+			{Emoji: "+1::skin-tone-3", Count: 1, Users: []string{"U3"}},
+			{Emoji: "eyes", Count: 1, Users: []string{"U2"}},
+		}}},
+		{"general", &models.SlackMessage{MessageID: "2.0", UserID: "U2", Reactions: []models.SlackReaction{
+			{Emoji: "tada", Count: 2, Users: []string{"U1", "U3"}},
+		}}},
+		{"general", &models.SlackMessage{MessageID: "3.0", UserID: "U3"}},
+	}
+	previous := []Message{
+		{"backend", &models.SlackMessage{MessageID: "0.1", UserID: "U1", Reactions: []models.SlackReaction{
+			{Emoji: "eyes", Count: 4},
+			{Emoji: "fire", Count: 1},
+		}}},
+	}
+
+	r := Reactions(current, previous, users, now.AddDate(0, 0, -30), now)
+
+	if r.Total != 6 || r.PreviousTotal != 5 {
+		t.Errorf("totals = %d/%d, want 6 now and 5 before", r.Total, r.PreviousTotal)
+	}
+
+	wantEmoji := []EmojiCount{
+		{Emoji: "+1", Count: 3},
+		{Emoji: "tada", Count: 2},
+		{Emoji: "eyes", Count: 1, Previous: 4},
+		{Emoji: "fire", Previous: 1},
+	}
+	if len(r.Emoji) != len(wantEmoji) {
+		t.Fatalf("emoji = %+v, want %+v", r.Emoji, wantEmoji)
+	}
+	for i, want := range wantEmoji {
+		if r.Emoji[i] != want {
+			t.Errorf("emoji[%d] = %+v, want %+v", i, r.Emoji[i], want)
+		}
+	}
+
+	if len(r.Channels) != 2 || r.Channels[0].Channel != "backend" || r.Channels[0].Emoji[0] != (EmojiCount{Emoji: "+1", Count: 3}) {
+		t.Errorf("channels = %+v, want backend led by +1 x3", r.Channels)
+	}
+
+	if len(r.Receivers) != 2 || r.Receivers[0] != (ReactionPerson{UserID: "U1", Name: "Alice", Count: 4}) {
+		t.Errorf("receivers = %+v, want Alice first with 4", r.Receivers)
+	}
+	wantGivers := []ReactionPerson{{"U3", "Carol", 3}, {"U2", "Bob", 2}, {"U1", "Alice", 1}}
+	for i, want := range wantGivers {
+		if i >= len(r.Givers) || r.Givers[i] != want {
+			t.Errorf("givers = %+v, want %+v", r.Givers, wantGivers)
+			break
+		}
+	}
+
+	var md bytes.Buffer
+	if err := WriteReactions(&md, r, "markdown"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"6 reactions (+1 vs. the previous period)", "| :eyes: | 1 | 4 | -3 |", "- #backend: :+1: 3, :eyes: 1", "| Carol | 3 |"} {
+		if !strings.Contains(md.String(), want) {
+			t.Errorf("markdown missing %q:\n%s", want, md.String())
+		}
+	}
+	if err := WriteReactions(&md, r, "csv"); err == nil {
+		t.Error("WriteReactions(csv) succeeded, want an error")
+	}
+}
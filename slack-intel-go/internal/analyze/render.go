@@ -0,0 +1,283 @@
+package analyze
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats lists the output formats Write accepts
+var Formats = []string{"markdown", "json", "csv"}
+
+// Write renders an activity report as markdown, json or csv
+func Write(w io.Writer, a *Activity, format string) error {
+	switch format {
+	case "markdown":
+		return writeMarkdown(w, a)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(a)
+	case "csv":
+		return writeCSV(w, a)
+	}
+	return fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(Formats, ", "))
+}
+
+// writeMarkdown renders the report as tables ready to paste into an update
+func writeMarkdown(w io.Writer, a *Activity) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Activity %s – %s\n\n", a.From.UTC().Format("2006-01-02"), a.To.UTC().Format("2006-01-02"))
+	fmt.Fprintf(&b, "%s from %s and %s.\n\n", plural(a.Messages, "message"), plural(len(a.Users), "person"), plural(len(a.Bots), "bot"))
+
+	authorTable := func(title string, authors []UserActivity) {
+		if len(authors) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		b.WriteString("| Name | Messages | Threads started | Threads joined | Reactions received |\n")
+		b.WriteString("|---|---:|---:|---:|---:|\n")
+		for _, u := range authors {
+			fmt.Fprintf(&b, "| %s | %d | %d | %d | %d |\n", markdownCell(u.Name), u.Messages, u.ThreadsStarted, u.Threads, u.ReactionsReceived)
+		}
+		b.WriteString("\n")
+	}
+	authorTable("People", a.Users)
+	authorTable("Bots", a.Bots)
+
+	if len(a.Channels) > 0 {
+		b.WriteString("## Messages per channel per day\n\n")
+		b.WriteString("| Channel | Day | Messages |\n")
+		b.WriteString("|---|---|---:|\n")
+		for _, d := range a.Channels {
+			fmt.Fprintf(&b, "| #%s | %s | %d |\n", markdownCell(d.Channel), d.Day, d.Messages)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCSV renders the report as one table; section tells user, bot and
+// channel_day rows apart and cells that do not apply are left empty
+func writeCSV(w io.Writer, a *Activity) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"section", "user_id", "name", "channel", "day", "messages", "threads_started", "threads", "reactions_received"})
+
+	authorRows := func(section string, authors []UserActivity) {
+		for _, u := range authors {
+			cw.Write([]string{section, u.UserID, u.Name, "", "", strconv.Itoa(u.Messages),
+				strconv.Itoa(u.ThreadsStarted), strconv.Itoa(u.Threads), strconv.Itoa(u.ReactionsReceived)})
+		}
+	}
+	authorRows("user", a.Users)
+	authorRows("bot", a.Bots)
+	for _, d := range a.Channels {
+		cw.Write([]string{"channel_day", "", "", d.Channel, d.Day, strconv.Itoa(d.Messages), "", "", ""})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteUnanswered renders unanswered questions as markdown, json or csv
+func WriteUnanswered(w io.Writer, found []UnansweredMessage, format string) error {
+	switch format {
+	case "markdown":
+		var b strings.Builder
+		fmt.Fprintf(&b, "# Unanswered questions (%d)\n\n", len(found))
+		if len(found) == 0 {
+			b.WriteString("Nothing fell through the cracks.\n")
+		} else {
+			b.WriteString("| Age | Channel | Author | Question |\n")
+			b.WriteString("|---|---|---|---|\n")
+		}
+		for _, q := range found {
+			text := markdownCell(q.Text)
+			if q.Permalink != "" {
+				text = fmt.Sprintf("[%s](%s)", strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text), q.Permalink)
+			}
+			fmt.Fprintf(&b, "| %s | #%s | %s | %s |\n", humanAge(q.AgeSeconds), markdownCell(q.Channel), markdownCell(q.Author), text)
+		}
+		_, err := io.WriteString(w, b.String())
+		return err
+	case "json":
+		if found == nil {
+			found = []UnansweredMessage{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(found)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"channel", "ts", "author", "age", "permalink", "text"})
+		for _, q := range found {
+			cw.Write([]string{q.Channel, q.TS, q.Author, humanAge(q.AgeSeconds), q.Permalink, q.Text})
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(Formats, ", "))
+}
+
+// ReactionFormats lists the output formats WriteReactions accepts
+var ReactionFormats = []string{"markdown", "json"}
+
+// leaderboardSize caps the markdown tables of WriteReactions and
+// WriteLinks; json and csv have everything
+const leaderboardSize = 10
+
+// WriteReactions renders a reaction report as markdown or json
+func WriteReactions(w io.Writer, r *ReactionReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "markdown":
+	default:
+		return fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(ReactionFormats, ", "))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Reactions %s – %s\n\n", r.From.UTC().Format("2006-01-02"), r.To.UTC().Format("2006-01-02"))
+	fmt.Fprintf(&b, "%s (%s vs. the previous period).\n\n", plural(r.Total, "reaction"), signed(r.Total-r.PreviousTotal))
+
+	if len(r.Emoji) > 0 {
+		b.WriteString("## Top emoji\n\n")
+		b.WriteString("| Emoji | Count | Previous | Change |\n")
+		b.WriteString("|---|---:|---:|---:|\n")
+		for _, e := range top(r.Emoji) {
+			fmt.Fprintf(&b, "| :%s: | %d | %d | %s |\n", markdownCell(e.Emoji), e.Count, e.Previous, signed(e.Count-e.Previous))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(r.Channels) > 0 {
+		b.WriteString("## By channel\n\n")
+		for _, ch := range r.Channels {
+			var used []string
+			for _, e := range ch.Emoji[:min(len(ch.Emoji), 5)] {
+				used = append(used, fmt.Sprintf(":%s: %d", e.Emoji, e.Count))
+			}
+			fmt.Fprintf(&b, "- #%s: %s\n", ch.Channel, strings.Join(used, ", "))
+		}
+		b.WriteString("\n")
+	}
+
+	people := func(title string, ranked []ReactionPerson) {
+		if len(ranked) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		b.WriteString("| Name | Reactions |\n")
+		b.WriteString("|---|---:|\n")
+		for _, p := range top(ranked) {
+			fmt.Fprintf(&b, "| %s | %d |\n", markdownCell(p.Name), p.Count)
+		}
+		b.WriteString("\n")
+	}
+	people("Top receivers", r.Receivers)
+	people("Top givers", r.Givers)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// top returns at most leaderboardSize leading entries
+func top[T any](ranked []T) []T {
+	return ranked[:min(len(ranked), leaderboardSize)]
+}
+
+// signed formats a change with an explicit sign, e.g. "+3" or "-2"
+func signed(n int) string {
+	if n > 0 {
+		return fmt.Sprintf("+%d", n)
+	}
+	return strconv.Itoa(n)
+}
+
+// humanAge formats an age in seconds as "2d 5h" or "3h 20m"
+func humanAge(seconds int64) string {
+	d := time.Duration(seconds) * time.Second
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	if days > 0 {
+		return fmt.Sprintf("%dd %dh", days, hours)
+	}
+	return fmt.Sprintf("%dh %dm", hours, int(d%time.Hour/time.Minute))
+}
+
+// plural formats a count with its noun, e.g. "1 bot" or "3 bots"
+func plural(n int, noun string) string {
+	switch {
+	case n == 1:
+		return "1 " + noun
+	case noun == "person":
+		return fmt.Sprintf("%d people", n)
+	case noun == "reply":
+		return fmt.Sprintf("%d replies", n)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// markdownCell escapes pipes so a value stays inside its table cell
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// WriteLinks renders a link report as markdown, json or csv
+func WriteLinks(w io.Writer, r *LinkReport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"section", "domain", "url", "shares", "sharers", "channels"})
+		rows := func(section string, shares []LinkShare) {
+			for _, s := range shares {
+				cw.Write([]string{section, s.Domain, s.URL, strconv.Itoa(s.Shares), strconv.Itoa(s.Sharers), strings.Join(s.Channels, " ")})
+			}
+		}
+		rows("domain", r.Domains)
+		rows("url", r.URLs)
+		cw.Flush()
+		return cw.Error()
+	case "markdown":
+	default:
+		return fmt.Errorf("unknown output format %q (want %s)", format, strings.Join(Formats, ", "))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Links %s – %s\n\n", r.From.UTC().Format("2006-01-02"), r.To.UTC().Format("2006-01-02"))
+	fmt.Fprintf(&b, "%s to %s", plural(r.Shares, "share"), plural(len(r.Domains), "domain"))
+	if r.Excluded > 0 {
+		fmt.Fprintf(&b, " (%s to Slack archives and localhost left out)", plural(r.Excluded, "link"))
+	}
+	b.WriteString(".\n\n")
+
+	table := func(title, column string, shares []LinkShare, key func(LinkShare) string) {
+		if len(shares) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "## %s\n\n", title)
+		fmt.Fprintf(&b, "| %s | Shares | Sharers | Channels |\n", column)
+		b.WriteString("|---|---:|---:|---|\n")
+		for _, s := range top(shares) {
+			fmt.Fprintf(&b, "| %s | %d | %d | %s |\n", markdownCell(key(s)), s.Shares, s.Sharers, markdownCell("#"+strings.Join(s.Channels, ", #")))
+		}
+		b.WriteString("\n")
+	}
+	table("Top domains", "Domain", r.Domains, func(s LinkShare) string { return s.Domain })
+	table("Top links", "URL", r.URLs, func(s LinkShare) string { return s.URL })
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
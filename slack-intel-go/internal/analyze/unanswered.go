@@ -0,0 +1,109 @@
+package analyze
+
+import (
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// QuestionFunc decides whether a message's text asks something
+type QuestionFunc func(text string) bool
+
+// DefaultGrace is how long a question may go unanswered before it is reported
+const DefaultGrace = 4 * time.Hour
+
+// questionWords start a message that reads as a question without a "?"
+var questionWords = map[string]bool{
+	"who":  true,
+	"what": true,
+	"why":  true,
+	"how":  true,
+	"can":  true,
+	"does": true,
+}
+
+// LooksLikeQuestion is the default QuestionFunc: text that ends with "?"
+// or starts with who, what, why, how, can or does
+func LooksLikeQuestion(text string) bool {
+	text = strings.TrimSpace(text)
+	if strings.HasSuffix(text, "?") {
+		return true
+	}
+	// The first word's leading letters, so "Who's" reads as "who"
+	first := strings.ToLower(text)
+	if end := strings.IndexFunc(first, func(r rune) bool { return !unicode.IsLetter(r) }); end >= 0 {
+		first = first[:end]
+	}
+	return questionWords[first]
+}
+
+// UnansweredOptions tunes Unanswered
+type UnansweredOptions struct {
+	// Grace is how old a message must be before it counts as unanswered
+	Grace time.Duration
+	// IsQuestion defaults to LooksLikeQuestion
+	IsQuestion QuestionFunc
+	// Users fills in author names missing from messages; may be nil
+	Users map[string]*models.SlackUser
+}
+
+// UnansweredMessage is a question nobody replied or reacted to
+type UnansweredMessage struct {
+	Channel    string    `json:"channel"`
+	TS         string    `json:"ts"`
+	Author     string    `json:"author"`
+	Timestamp  time.Time `json:"timestamp"`
+	AgeSeconds int64     `json:"age_seconds"`
+	Permalink  string    `json:"permalink,omitempty"`
+	Text       string    `json:"text"` // first 120 characters
+}
+
+// previewLength caps UnansweredMessage.Text, in characters
+const previewLength = 120
+
+// Unanswered returns top-level questions older than the grace period with
+// no replies and no reactions, oldest first. Ages are measured from now.
+func Unanswered(msgs []Message, now time.Time, opts UnansweredOptions) []UnansweredMessage {
+	isQuestion := opts.IsQuestion
+	if isQuestion == nil {
+		isQuestion = LooksLikeQuestion
+	}
+
+	var found []UnansweredMessage
+	for _, m := range msgs {
+		if m.IsThreadReply() || m.ReplyCount > 0 || len(m.Reactions) > 0 {
+			continue
+		}
+		age := now.Sub(m.Timestamp)
+		if age < opts.Grace || !isQuestion(m.Text) {
+			continue
+		}
+		_, name, _ := author(m.SlackMessage, opts.Users)
+		found = append(found, UnansweredMessage{
+			Channel:    m.Channel,
+			TS:         m.MessageID,
+			Author:     name,
+			Timestamp:  m.Timestamp,
+			AgeSeconds: int64(age / time.Second),
+			Permalink:  m.Permalink,
+			Text:       preview(m.Text, previewLength),
+		})
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].Timestamp.Before(found[j].Timestamp)
+	})
+	return found
+}
+
+// preview collapses whitespace and cuts text to n characters
+func preview(text string, n int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return text
+}
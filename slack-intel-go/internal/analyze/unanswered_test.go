@@ -0,0 +1,87 @@
+package analyze
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestLooksLikeQuestion(t *testing.T) {
+	for text, want := range map[string]bool{
+		"is the deploy stuck?":           true,
+		"  anyone around?  ":             true,
+		"How do I rotate the key":        true,
+		"Does staging use the new DB":    true,
+		"who's on call":                  true,
+		"canary is green":                false,
+		"deployed v2.3":                  false,
+		"however, it works now":          false,
+		"what":                           true,
+		"":                               false,
+		"see https://example.com/?q=a b": false,
+	} {
+		if got := LooksLikeQuestion(text); got != want {
+			t.Errorf("LooksLikeQuestion(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
+
+func TestUnanswered(t *testing.T) {
+	now := time.Date(2023, 11, 15, 12, 0, 0, 0, time.UTC)
+	at := func(ago time.Duration) time.Time { return now.Add(-ago) }
+	msg := func(id, text string, ago time.Duration) *models.SlackMessage {
+		return &models.SlackMessage{MessageID: id, UserID: "U1", Text: text, Timestamp: at(ago)}
+	}
+
+	answered := msg("1.0", "how do I get access?", 30*time.Hour)
+	answered.ThreadTS, answered.ReplyCount = "1.0", 1
+	acked := msg("2.0", "why is CI red?", 20*time.Hour)
+	acked.Reactions = []models.SlackReaction{{Emoji: "eyes", Count: 1}}
+	reply := msg("3.1", "does anyone know?", 10*time.Hour)
+	reply.ThreadTS = "3.0"
+	long := msg("5.0", strings.Repeat("a", 200)+"?", 6*time.Hour)
+	long.Permalink = "https://acme.slack.com/archives/C1/p5"
+
+	msgs := []Message{
+		{"help", answered},
+		{"help", acked},
+		{"help", reply},
+		{"help", long},
+		{"help", msg("4.0", "can someone review my PR", 26*time.Hour)},
+		{"help", msg("6.0", "who owns billing?", time.Hour)}, // inside the grace period
+		{"help", msg("7.0", "shipped the fix", 40*time.Hour)},
+	}
+
+	found := Unanswered(msgs, now, UnansweredOptions{Grace: DefaultGrace})
+	if len(found) != 2 {
+		t.Fatalf("got %d unanswered, want 2: %+v", len(found), found)
+	}
+	if found[0].TS != "4.0" || found[1].TS != "5.0" {
+		t.Errorf("order = %s, %s; want 4.0 then 5.0 (oldest first)", found[0].TS, found[1].TS)
+	}
+	if found[0].AgeSeconds != int64(26*time.Hour/time.Second) || found[0].Author != "U1" {
+		t.Errorf("found[0] = %+v, want a 26h old question by U1", found[0])
+	}
+	if n := len([]rune(found[1].Text)); n != previewLength || found[1].Permalink == "" {
+		t.Errorf("found[1] text is %d characters (permalink %q), want %d with a permalink", n, found[1].Permalink, previewLength)
+	}
+
+	// The heuristic is pluggable
+	shouty := Unanswered(msgs, now, UnansweredOptions{IsQuestion: func(text string) bool {
+		return strings.HasPrefix(text, "shipped")
+	}})
+	if len(shouty) != 1 || shouty[0].TS != "7.0" {
+		t.Errorf("custom heuristic found %+v, want only 7.0", shouty)
+	}
+
+	var md bytes.Buffer
+	if err := WriteUnanswered(&md, found, "markdown"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(md.String(), "| 1d 2h | #help | U1 | can someone review my PR |") {
+		t.Errorf("markdown:\n%s", md.String())
+	}
+}
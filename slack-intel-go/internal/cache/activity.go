@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// ActivityHours sets how the derived iso_week, weekday, hour_of_day and
+// is_business_hours columns read a message's timestamp
+type ActivityHours struct {
+	// Location is the zone the columns are derived in
+	Location *time.Location
+	// Start and End bound the working day as offsets from local midnight;
+	// business hours are [Start, End) on Monday to Friday
+	Start, End time.Duration
+}
+
+// DefaultActivityHours derives the columns in UTC with a 09:00-17:00
+// working day
+var DefaultActivityHours = ActivityHours{Location: time.UTC, Start: 9 * time.Hour, End: 17 * time.Hour}
+
+// String renders the working day and zone, e.g. 09:00-17:00 Europe/Berlin
+func (h ActivityHours) String() string {
+	return fmt.Sprintf("%s-%s %s", clock(h.Start), clock(h.End), h.Location)
+}
+
+// clock renders an offset from midnight as HH:MM
+func clock(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// activity holds the derived columns of one timestamp
+type activity struct {
+	isoWeek  string // e.g. 2024-W19, with the ISO week-numbering year
+	weekday  int64  // ISO weekday, 1 (Monday) to 7 (Sunday)
+	hour     int64  // local hour, 0 to 23
+	business bool
+}
+
+// derive reads t in h.Location. The time of day comes from the local
+// clock, so a day with a DST change still has business hours at the
+// clock times of the working day.
+func (h ActivityHours) derive(t time.Time) activity {
+	local := t.In(h.Location)
+	year, week := local.ISOWeek()
+
+	weekday := int64(local.Weekday())
+	if weekday == 0 {
+		weekday = 7
+	}
+
+	sinceMidnight := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second
+	return activity{
+		isoWeek:  fmt.Sprintf("%d-W%02d", year, week),
+		weekday:  weekday,
+		hour:     int64(local.Hour()),
+		business: weekday <= 5 && sinceMidnight >= h.Start && sinceMidnight < h.End,
+	}
+}
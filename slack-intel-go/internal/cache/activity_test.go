@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestActivityAcrossDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	hours := ActivityHours{Location: berlin, Start: 9 * time.Hour, End: 17 * time.Hour}
+
+	// Berlin moves from CET (UTC+1) to CEST (UTC+2) at 01:00 UTC on Sunday
+	// 2024-03-31 and back at 01:00 UTC on Sunday 2024-10-27
+	tests := []struct {
+		utc      time.Time
+		isoWeek  string
+		weekday  int64
+		hour     int64
+		business bool
+	}{
+		{time.Date(2024, 3, 31, 0, 30, 0, 0, time.UTC), "2024-W13", 7, 1, false},
+		{time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC), "2024-W13", 7, 3, false},
+		// 07:30 UTC is 08:30 before the change but 09:30 after it
+		{time.Date(2024, 3, 29, 7, 30, 0, 0, time.UTC), "2024-W13", 5, 8, false},
+		{time.Date(2024, 4, 1, 7, 30, 0, 0, time.UTC), "2024-W14", 1, 9, true},
+		{time.Date(2024, 4, 1, 15, 0, 0, 0, time.UTC), "2024-W14", 1, 17, false},
+		{time.Date(2024, 10, 27, 0, 30, 0, 0, time.UTC), "2024-W43", 7, 2, false},
+		{time.Date(2024, 10, 27, 1, 30, 0, 0, time.UTC), "2024-W43", 7, 2, false},
+		{time.Date(2024, 10, 28, 15, 30, 0, 0, time.UTC), "2024-W44", 1, 16, true},
+		// ISO week 1 of 2025 starts on Monday 2024-12-30
+		{time.Date(2024, 12, 30, 23, 30, 0, 0, time.UTC), "2025-W01", 2, 0, false},
+	}
+	for _, tt := range tests {
+		got := hours.derive(tt.utc)
+		want := activity{isoWeek: tt.isoWeek, weekday: tt.weekday, hour: tt.hour, business: tt.business}
+		if got != want {
+			t.Errorf("derive(%s) = %+v, want %+v", tt.utc.Format(time.RFC3339), got, want)
+		}
+	}
+
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetActivityHours(hours)
+	msgs := make([]*models.SlackMessage, len(tests))
+	for i, tt := range tests {
+		msgs[i] = &models.SlackMessage{MessageID: tt.utc.Format("20060102T1504"), Text: "hi", Timestamp: tt.utc}
+	}
+	path, err := pc.SaveMessages(msgs, &models.SlackChannel{Name: "general", ID: "C1"}, "2024")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if meta["activity_hours"] != "09:00-17:00 Europe/Berlin" {
+		t.Errorf("activity_hours = %q, want 09:00-17:00 Europe/Berlin", meta["activity_hours"])
+	}
+
+	rows := storedActivity(t, pc, path)
+	for _, tt := range tests {
+		id := tt.utc.Format("20060102T1504")
+		if got := rows[id]; got != hours.derive(tt.utc) {
+			t.Errorf("stored row %s = %+v, want %+v", id, got, hours.derive(tt.utc))
+		}
+	}
+}
+
+// storedActivity reads the derived activity columns of a partition by
+// message_id
+func storedActivity(t *testing.T, pc *ParquetCache, path string) map[string]activity {
+	t.Helper()
+	table, err := pc.readTable(context.Background(), path)
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	defer table.Release()
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+	rows := map[string]activity{}
+	for tr.Next() {
+		cols := columns{rec: tr.Record()}
+		ids, weeks, weekdays, hourCol, business := cols.strings("message_id"), cols.strings("iso_week"),
+			cols.int64s("weekday"), cols.int64s("hour_of_day"), cols.bools("is_business_hours")
+		for i := 0; i < ids.Len(); i++ {
+			rows[ids.Value(i)] = activity{isoWeek: weeks.Value(i), weekday: weekdays.Value(i), hour: hourCol.Value(i), business: business.Value(i)}
+		}
+	}
+	return rows
+}
+
+func TestMergeRederivesActivity(t *testing.T) {
+	tokyo := ActivityHours{Location: time.FixedZone("JST", 9*3600), Start: 9 * time.Hour, End: 17 * time.Hour}
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+	base := filepath.Join(t.TempDir(), "raw")
+
+	// Stored with the UTC default: 06:00 UTC is outside business hours
+	early := &models.SlackMessage{MessageID: "1700028000.000100", Text: "early", Timestamp: time.Unix(1700028000, 0)}
+	if _, err := NewParquetCache(base).SaveMessages([]*models.SlackMessage{early}, channel, "2023-11-15"); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	// Merged under Tokyo hours, where 06:00 UTC is 15:00: the kept row is
+	// derived again to match the file's new activity_hours
+	pc := NewParquetCache(base)
+	pc.SetActivityHours(tokyo)
+	late := &models.SlackMessage{MessageID: "1700031600.000100", Text: "late", Timestamp: time.Unix(1700031600, 0)}
+	path, err := pc.MergeMessages(context.Background(), []*models.SlackMessage{late}, channel, "2023-11-15")
+	if err != nil {
+		t.Fatalf("MergeMessages: %v", err)
+	}
+	rows := storedActivity(t, pc, path)
+	for _, msg := range []*models.SlackMessage{early, late} {
+		if got, want := rows[msg.MessageID], tokyo.derive(msg.Timestamp); got != want {
+			t.Errorf("row %s = %+v, want %+v", msg.MessageID, got, want)
+		}
+	}
+	if !rows[early.MessageID].business {
+		t.Errorf("kept row %+v, want it in Tokyo business hours", rows[early.MessageID])
+	}
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// LoadAlerts reads cache/raw/alerts.parquet, keyed by Alert.Key. A missing
+// file yields no alerts.
+func (pc *ParquetCache) LoadAlerts(ctx context.Context) (map[string]*models.Alert, error) {
+	alerts := make(map[string]*models.Alert)
+
+	path := pc.readPath(pc.AlertsPath())
+	table, err := pc.readTable(ctx, path)
+	if storage.IsNotExist(err) {
+		return alerts, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+
+	for tr.Next() {
+		rec := tr.Record()
+		cols := columns{rec: rec}
+		channelIDs, messageIDs := cols.strings("channel_id"), cols.strings("message_id")
+		if channelIDs == nil || messageIDs == nil {
+			return nil, fmt.Errorf("unexpected schema in %s: missing channel_id or message_id", path)
+		}
+		channels, users, texts := cols.strings("channel"), cols.strings("user_id"), cols.strings("text")
+		permalinks, keywords := cols.strings("permalink"), cols.lists("keywords")
+		timestamps, matchedAts, notifiedAts := cols.strings("timestamp"), cols.strings("matched_at"), cols.strings("notified_at")
+
+		for i := 0; i < int(rec.NumRows()); i++ {
+			a := &models.Alert{
+				ChannelID: channelIDs.Value(i),
+				Channel:   stringValue(channels, i),
+				MessageID: messageIDs.Value(i),
+				UserID:    stringValue(users, i),
+				Text:      stringValue(texts, i),
+				Permalink: stringValue(permalinks, i),
+				Keywords:  listValue(keywords, i),
+			}
+			if a.Timestamp, err = timeValue(timestamps, i); err != nil {
+				return nil, fmt.Errorf("invalid timestamp for alert %s: %w", a.Key(), err)
+			}
+			if a.MatchedAt, err = timeValue(matchedAts, i); err != nil {
+				return nil, fmt.Errorf("invalid matched_at for alert %s: %w", a.Key(), err)
+			}
+			if a.NotifiedAt, err = timeValue(notifiedAts, i); err != nil {
+				return nil, fmt.Errorf("invalid notified_at for alert %s: %w", a.Key(), err)
+			}
+			alerts[a.Key()] = a
+		}
+	}
+	if err := tr.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return alerts, nil
+}
+
+// AppendAlerts adds alerts to cache/raw/alerts.parquet, ordered by match time
+// then channel and message. An alert whose message is already in the file
+// is dropped, so a message is only ever logged once. The file is
+// rewritten whole.
+func (pc *ParquetCache) AppendAlerts(ctx context.Context, alerts []*models.Alert) (string, error) {
+	if len(alerts) == 0 {
+		return "", nil
+	}
+
+	existing, err := pc.LoadAlerts(ctx)
+	if err != nil {
+		return "", err
+	}
+	added := 0
+	for _, a := range alerts {
+		if _, ok := existing[a.Key()]; !ok {
+			existing[a.Key()] = a
+			added++
+		}
+	}
+	if added == 0 {
+		return "", nil
+	}
+
+	rows := make([]*models.Alert, 0, len(existing))
+	for _, a := range existing {
+		rows = append(rows, a)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].MatchedAt.Equal(rows[j].MatchedAt) {
+			return rows[i].MatchedAt.Before(rows[j].MatchedAt)
+		}
+		return rows[i].Key() < rows[j].Key()
+	})
+
+	alertsPath := pc.AlertsPath()
+	schema := createAlertSchema()
+
+	mem := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	for _, a := range rows {
+		builder.Field(0).(*array.StringBuilder).Append(a.ChannelID)
+		builder.Field(1).(*array.StringBuilder).Append(a.Channel)
+		builder.Field(2).(*array.StringBuilder).Append(a.MessageID)
+		appendOptionalString(builder.Field(3).(*array.StringBuilder), a.UserID)
+		builder.Field(4).(*array.StringBuilder).Append(a.Text)
+		appendOptionalString(builder.Field(5).(*array.StringBuilder), a.Permalink)
+		kb := builder.Field(6).(*array.ListBuilder)
+		kb.Append(true)
+		for _, k := range a.Keywords {
+			kb.ValueBuilder().(*array.StringBuilder).Append(k)
+		}
+		builder.Field(7).(*array.StringBuilder).Append(a.Timestamp.UTC().Format(time.RFC3339))
+		builder.Field(8).(*array.StringBuilder).Append(a.MatchedAt.UTC().Format(time.RFC3339))
+		if !a.NotifiedAt.IsZero() {
+			builder.Field(9).(*array.StringBuilder).Append(a.NotifiedAt.UTC().Format(time.RFC3339))
+		} else {
+			builder.Field(9).(*array.StringBuilder).AppendNull()
+		}
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	if err := pc.writeRecord(alertsPath, schema, record); err != nil {
+		return "", fmt.Errorf("failed to write alerts: %w", err)
+	}
+
+	pc.logger.Debug("wrote alerts", "path", alertsPath, "rows", len(rows), "added", added)
+
+	return alertsPath, nil
+}
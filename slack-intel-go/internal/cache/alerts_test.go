@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+func TestAppendAlertsLogsEachMessageOnce(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(storage.NewMemory())
+
+	first := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+	outage := &models.Alert{ChannelID: "C1", Channel: "ops", MessageID: "1715331600.000100", UserID: "U1",
+		Text: "outage in eu", Keywords: []string{"outage"}, Timestamp: first.Add(-time.Minute), MatchedAt: first,
+		NotifiedAt: first, Permalink: "https://acme.slack.com/archives/C1/p1715331600000100"}
+	if _, err := pc.AppendAlerts(ctx, []*models.Alert{outage}); err != nil {
+		t.Fatalf("AppendAlerts: %v", err)
+	}
+
+	// A later cycle sees the same message again, plus a new match
+	again := *outage
+	again.MatchedAt = first.Add(time.Hour)
+	refund := &models.Alert{ChannelID: "C2", Channel: "billing", MessageID: "1715335200.000100",
+		Text: "refund PCI", Keywords: []string{"refund", "re:pci"}, Timestamp: first.Add(time.Hour), MatchedAt: first.Add(time.Hour)}
+	path, err := pc.AppendAlerts(ctx, []*models.Alert{&again, refund})
+	if err != nil {
+		t.Fatalf("AppendAlerts: %v", err)
+	}
+	if path != pc.AlertsPath() {
+		t.Errorf("path = %s, want %s", path, pc.AlertsPath())
+	}
+	if path, _ := pc.AppendAlerts(ctx, []*models.Alert{refund}); path != "" {
+		t.Errorf("AppendAlerts with only logged alerts wrote %s", path)
+	}
+
+	alerts, err := pc.LoadAlerts(ctx)
+	if err != nil {
+		t.Fatalf("LoadAlerts: %v", err)
+	}
+	if len(alerts) != 2 {
+		t.Fatalf("got %d alerts, want 2", len(alerts))
+	}
+	got := alerts[outage.Key()]
+	if !got.MatchedAt.Equal(first) || !got.NotifiedAt.Equal(first) || got.Permalink != outage.Permalink || got.UserID != "U1" {
+		t.Errorf("outage alert = %+v, want the first match kept", got)
+	}
+	if r := alerts[refund.Key()]; r.UserID != "" || !r.NotifiedAt.IsZero() || strings.Join(r.Keywords, ",") != "refund,re:pci" {
+		t.Errorf("refund alert = %+v", r)
+	}
+}
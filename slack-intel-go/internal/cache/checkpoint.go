@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CheckpointFile is the backfill checkpoint's name, next to users.parquet
+// in the base path
+const CheckpointFile = "checkpoint.json"
+
+// Checkpoint records how far a cache run got through each channel so a run
+// that crashed can be resumed. It is rewritten atomically after every
+// partition flush.
+//
+// A channel's Cursor is where its next fetch starts: the end of its last
+// fully written partition. Slack's own history cursor is not kept, since
+// pages fetched for an unfinished partition are lost with the process and
+// the partition has to be fetched again from its start.
+type Checkpoint struct {
+	WindowStart  time.Time                     `json:"window_start"`
+	WindowEnd    time.Time                     `json:"window_end"`
+	Granularity  Granularity                   `json:"granularity"`
+	NameTemplate string                        `json:"name_template"`
+	Channels     map[string]*ChannelCheckpoint `json:"channels"`
+	UpdatedAt    time.Time                     `json:"updated_at"`
+
+	path string
+}
+
+// ChannelCheckpoint is one channel's progress
+type ChannelCheckpoint struct {
+	LastPartition string    `json:"last_partition,omitempty"`
+	Cursor        time.Time `json:"cursor,omitempty"`
+	Done          bool      `json:"done,omitempty"`
+}
+
+// NewCheckpoint starts an empty checkpoint at path for a run over
+// [start, end) written with the given partitioning
+func NewCheckpoint(path string, start, end time.Time, g Granularity, template *NameTemplate) *Checkpoint {
+	return &Checkpoint{
+		WindowStart:  start,
+		WindowEnd:    end,
+		Granularity:  g,
+		NameTemplate: template.String(),
+		Channels:     make(map[string]*ChannelCheckpoint),
+		path:         path,
+	}
+}
+
+// LoadCheckpoint reads the checkpoint at path. A missing file yields an
+// error wrapping os.ErrNotExist.
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	c := &Checkpoint{path: path}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoint %s: %w", path, err)
+	}
+	if c.Channels == nil {
+		c.Channels = make(map[string]*ChannelCheckpoint)
+	}
+	return c, nil
+}
+
+// Path returns the file the checkpoint is saved to
+func (c *Checkpoint) Path() string {
+	return c.path
+}
+
+// Matches reports why a resumed run cannot continue this checkpoint, or ""
+// when its partitioning is the same
+func (c *Checkpoint) Matches(g Granularity, template *NameTemplate) string {
+	switch {
+	case c.Granularity != g:
+		return fmt.Sprintf("checkpoint was written with --partition-granularity %s", c.Granularity)
+	case c.NameTemplate != template.String():
+		return fmt.Sprintf("checkpoint was written with --name-template %s", c.NameTemplate)
+	}
+	return ""
+}
+
+// Resume returns where a channel's fetch should start and whether the
+// channel already finished. Channels the checkpoint has not seen start at
+// the window start.
+func (c *Checkpoint) Resume(channelID string) (time.Time, bool) {
+	ch, ok := c.Channels[channelID]
+	switch {
+	case !ok:
+		return c.WindowStart, false
+	case ch.Done:
+		return c.WindowEnd, true
+	case ch.Cursor.IsZero():
+		return c.WindowStart, false
+	}
+	return ch.Cursor, false
+}
+
+// Flushed records that partition key was written and everything before
+// cursor is on disk, then saves the checkpoint
+func (c *Checkpoint) Flushed(channelID, key string, cursor time.Time) error {
+	ch := c.channel(channelID)
+	ch.LastPartition = key
+	if cursor.After(ch.Cursor) {
+		ch.Cursor = cursor
+	}
+	return c.Save()
+}
+
+// Complete marks a channel finished, then saves the checkpoint
+func (c *Checkpoint) Complete(channelID string) error {
+	c.channel(channelID).Done = true
+	return c.Save()
+}
+
+// channel returns the channel's entry, creating it on first use
+func (c *Checkpoint) channel(channelID string) *ChannelCheckpoint {
+	ch, ok := c.Channels[channelID]
+	if !ok {
+		ch = &ChannelCheckpoint{}
+		c.Channels[channelID] = ch
+	}
+	return ch
+}
+
+// Save writes the checkpoint through a temporary file and a rename, so a
+// crash mid-write leaves the previous checkpoint intact
+func (c *Checkpoint) Save() error {
+	c.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), ".checkpoint-*.json")
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the checkpoint file once its run has finished
+func (c *Checkpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove checkpoint: %w", err)
+	}
+	return nil
+}
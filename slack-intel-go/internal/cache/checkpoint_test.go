@@ -0,0 +1,77 @@
+package cache
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointResumeAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, CheckpointFile)
+	start := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 3)
+	g := GranularityDay
+
+	// First run: C1 finishes, C2 crashes after writing its first day
+	first := NewCheckpoint(path, start, end, g, defaultTemplate)
+	for day := 0; day < 3; day++ {
+		from := start.AddDate(0, 0, day)
+		if err := first.Flushed("C1", g.PartitionKey(from), from.AddDate(0, 0, 1)); err != nil {
+			t.Fatalf("Flushed: %v", err)
+		}
+	}
+	if err := first.Complete("C1"); err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+	if err := first.Flushed("C2", "2023-11-01", start.AddDate(0, 0, 1)); err != nil {
+		t.Fatalf("Flushed: %v", err)
+	}
+
+	// Only the checkpoint is left behind, no temporary files
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("dir holds %d entries, want just %s", len(entries), CheckpointFile)
+	}
+
+	// Restart
+	resumed, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint: %v", err)
+	}
+	if !resumed.WindowStart.Equal(start) || !resumed.WindowEnd.Equal(end) {
+		t.Errorf("window = %s – %s, want the first run's %s – %s", resumed.WindowStart, resumed.WindowEnd, start, end)
+	}
+	if reason := resumed.Matches(g, defaultTemplate); reason != "" {
+		t.Errorf("Matches = %q, want a match", reason)
+	}
+	if reason := resumed.Matches(GranularityHour, defaultTemplate); reason == "" {
+		t.Error("Matches accepted a different granularity")
+	}
+
+	if _, done := resumed.Resume("C1"); !done {
+		t.Error("C1 should be done")
+	}
+	if cursor, done := resumed.Resume("C2"); done || !cursor.Equal(start.AddDate(0, 0, 1)) {
+		t.Errorf("C2 resumes at %s (done %v), want 2023-11-02", cursor, done)
+	}
+	if cursor, done := resumed.Resume("C3"); done || !cursor.Equal(start) {
+		t.Errorf("C3 resumes at %s (done %v), want the window start", cursor, done)
+	}
+	if ch := resumed.Channels["C2"]; ch.LastPartition != "2023-11-01" {
+		t.Errorf("C2 last partition = %q, want 2023-11-01", ch.LastPartition)
+	}
+
+	// Finishing removes the file; removing twice is fine
+	if err := resumed.Remove(); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := resumed.Remove(); err != nil {
+		t.Errorf("second Remove: %v", err)
+	}
+	if _, err := LoadCheckpoint(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("LoadCheckpoint after Remove = %v, want os.ErrNotExist", err)
+	}
+}
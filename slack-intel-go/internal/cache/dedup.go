@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// DedupStrategy picks which copy of a message a partition keeps when a
+// write brings a message_id that is already stored there
+type DedupStrategy string
+
+const (
+	// DedupLastWriteWins keeps the copy fetched last; a message without a
+	// fetch time counts as fetched now
+	DedupLastWriteWins DedupStrategy = "last-write-wins"
+	// DedupKeepEdited keeps the copy edited last, so an older fetch of an
+	// edited message cannot bring back its earlier text. Copies edited at
+	// the same time (or never) fall back to last-write-wins.
+	DedupKeepEdited DedupStrategy = "keep-edited"
+	// DedupFirstSeen keeps the stored copy and only adds new messages
+	DedupFirstSeen DedupStrategy = "first-seen"
+)
+
+// DefaultDedupStrategy keeps the copy with the latest edit, then fetch time
+const DefaultDedupStrategy = DedupKeepEdited
+
+// ParseDedupStrategy validates a --dedup-strategy value
+func ParseDedupStrategy(s string) (DedupStrategy, error) {
+	switch d := DedupStrategy(s); d {
+	case DedupLastWriteWins, DedupKeepEdited, DedupFirstSeen:
+		return d, nil
+	}
+	return "", fmt.Errorf("invalid dedup strategy %q (expected last-write-wins, keep-edited or first-seen)", s)
+}
+
+// storedVersion is what a dedup strategy knows about a stored row, with
+// its posting time and thread for a refetch window
+type storedVersion struct {
+	fetched, edited time.Time
+	posted          time.Time
+	threadTS        string
+}
+
+// keepStored reports whether the stored copy of msg wins over msg
+func (d DedupStrategy) keepStored(stored storedVersion, msg *models.SlackMessage) bool {
+	switch d {
+	case DedupFirstSeen:
+		return true
+	case DedupKeepEdited:
+		if !stored.edited.Equal(msg.EditedAt) {
+			return stored.edited.After(msg.EditedAt)
+		}
+	}
+	return !msg.FetchedAt.IsZero() && stored.fetched.After(msg.FetchedAt)
+}
+
+// storedVersions maps message_id to the fetched_at and edited_at of a
+// partition table's rows
+func storedVersions(table arrow.Table) map[string]storedVersion {
+	versions := make(map[string]storedVersion)
+	if table == nil {
+		return versions
+	}
+
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+	for tr.Next() {
+		cols := columns{rec: tr.Record()}
+		ids, fetched, edited := cols.strings("message_id"), cols.strings("fetched_at"), cols.strings("edited_at")
+		posted, threads := cols.strings("timestamp"), cols.strings("thread_ts")
+		for i := 0; i < ids.Len(); i++ {
+			var v storedVersion
+			v.fetched, _ = timeValue(fetched, i)
+			v.edited, _ = timeValue(edited, i)
+			v.posted, _ = timeValue(posted, i)
+			v.threadTS = stringValue(threads, i)
+			versions[ids.Value(i)] = v
+		}
+	}
+	return versions
+}
+
+// refetchWindow is a span of posting times a fetch returned in full
+type refetchWindow struct {
+	from, to time.Time
+}
+
+// deleted lists the stored rows the window covers but messages lack: the
+// messages posted in it and the replies to threads started in it. Slack
+// deleted them since they were cached.
+func (w refetchWindow) deleted(stored map[string]storedVersion, messages []*models.SlackMessage) []string {
+	if w.from.IsZero() && w.to.IsZero() {
+		return nil
+	}
+	fetched := make(map[string]bool, len(messages))
+	for _, msg := range messages {
+		fetched[msg.MessageID] = true
+	}
+
+	var ids []string
+	for id, v := range stored {
+		if fetched[id] || !w.contains(v.posted) {
+			continue
+		}
+		if v.threadTS != "" && v.threadTS != id && !w.contains(slackTime(v.threadTS)) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (w refetchWindow) contains(t time.Time) bool {
+	return !t.IsZero() && !t.Before(w.from) && t.Before(w.to)
+}
+
+// slackTime converts a Slack ts ("1700000100.000100") to a time, or the
+// zero time when it is malformed
+func slackTime(ts string) time.Time {
+	secs, micros, _ := strings.Cut(ts, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	usec, _ := strconv.ParseInt(micros, 10, 64)
+	return time.Unix(sec, usec*1000)
+}
@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestDedupStrategies(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2023, 11, 14, hour, 0, 0, 0, time.UTC) }
+	msg := func(id, text string, fetched, edited time.Time) *models.SlackMessage {
+		return &models.SlackMessage{MessageID: id, Text: text, Timestamp: at(1), FetchedAt: fetched, EditedAt: edited}
+	}
+	channel := &models.SlackChannel{Name: "incidents", ID: "C1"}
+
+	stored := []*models.SlackMessage{
+		// edited after it was stored
+		msg("1700000000.000100", "stored", at(10), time.Time{}),
+		// stored with its edit, then replayed from an unedited payload
+		msg("1700000000.000200", "stored", at(12), at(11)),
+		// stored by a later fetch than the incoming copy
+		msg("1700000000.000300", "stored", at(12), time.Time{}),
+	}
+	incoming := []*models.SlackMessage{
+		msg("1700000000.000100", "incoming", at(12), at(11)),
+		msg("1700000000.000200", "incoming", at(13), time.Time{}),
+		msg("1700000000.000300", "incoming", at(10), time.Time{}),
+		msg("1700000000.000400", "incoming", at(12), time.Time{}),
+	}
+
+	cases := []struct {
+		strategy DedupStrategy
+		want     []string
+	}{
+		{DedupLastWriteWins, []string{"incoming", "incoming", "stored", "incoming"}},
+		{DedupKeepEdited, []string{"incoming", "stored", "stored", "incoming"}},
+		{DedupFirstSeen, []string{"stored", "stored", "stored", "incoming"}},
+	}
+	for _, c := range cases {
+		t.Run(string(c.strategy), func(t *testing.T) {
+			pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+			pc.SetDedupStrategy(c.strategy)
+			if _, err := pc.SaveMessages(stored, channel, "2023-11-14"); err != nil {
+				t.Fatalf("SaveMessages: %v", err)
+			}
+			path, err := pc.SaveMessages(incoming, channel, "2023-11-14")
+			if err != nil {
+				t.Fatalf("SaveMessages: %v", err)
+			}
+
+			got, err := pc.ReadMessages(context.Background(), path)
+			if err != nil {
+				t.Fatalf("ReadMessages: %v", err)
+			}
+			texts := map[string]*models.SlackMessage{}
+			for _, m := range got {
+				texts[m.MessageID] = m
+			}
+			if len(texts) != len(incoming) {
+				t.Fatalf("got %d messages, want %d", len(texts), len(incoming))
+			}
+			for i, in := range incoming {
+				m := texts[in.MessageID]
+				if m == nil || m.Text != c.want[i] {
+					t.Errorf("message %s = %+v, want the %s copy", in.MessageID, m, c.want[i])
+					continue
+				}
+				want := in
+				if c.want[i] == "stored" {
+					want = stored[i]
+				}
+				if !m.EditedAt.Equal(want.EditedAt) {
+					t.Errorf("message %s edited_at = %v, want %v", in.MessageID, m.EditedAt, want.EditedAt)
+				}
+			}
+		})
+	}
+
+	if _, err := ParseDedupStrategy("newest"); err == nil {
+		t.Error("ParseDedupStrategy accepted an unknown strategy")
+	}
+	if d, err := ParseDedupStrategy("first-seen"); err != nil || d != DedupFirstSeen {
+		t.Errorf("ParseDedupStrategy(first-seen) = %q, %v", d, err)
+	}
+}
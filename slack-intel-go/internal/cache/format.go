@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultPartFileName is the name of a partition's Parquet data file
+const DefaultPartFileName = "data.parquet"
+
+// Format is the file format message partitions are written in. Both
+// formats share the partition layout of the name template; only the data
+// file's name differs.
+type Format string
+
+const (
+	// FormatParquet writes data.parquet, the format every cache command reads
+	FormatParquet Format = "parquet"
+	// FormatJSONL writes data.jsonl.gz: gzipped newline-delimited JSON, one
+	// full SlackMessage per line
+	FormatJSONL Format = "jsonl"
+)
+
+// ParseFormat validates a --format value
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatParquet, FormatJSONL:
+		return f, nil
+	}
+	return "", fmt.Errorf("invalid format %q (expected parquet or jsonl)", s)
+}
+
+// FileName returns the default name of a partition's data file in this
+// format
+func (f Format) FileName() string {
+	if f == FormatJSONL {
+		return "data.jsonl.gz"
+	}
+	return DefaultPartFileName
+}
+
+// ParsePartFileName validates a --part-filename value: a plain file name
+// with a .parquet extension, e.g. part-0.snappy.parquet
+func ParsePartFileName(name string) (string, error) {
+	switch {
+	case name == "":
+		return DefaultPartFileName, nil
+	case strings.ContainsAny(name, `/\`):
+		return "", fmt.Errorf("invalid partition file name %q: must be a file name, not a path", name)
+	case strings.HasPrefix(name, "."):
+		return "", fmt.Errorf("invalid partition file name %q: must not start with a dot", name)
+	case !strings.HasSuffix(name, ".parquet"):
+		return "", fmt.Errorf("invalid partition file name %q: must end in .parquet", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,155 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// Reasons a day counts as a gap in coverage
+const (
+	// GapMissing: no partition covers the day
+	GapMissing = "missing"
+	// GapPartial: the day's messages were last fetched before the day
+	// ended, so anything posted later was never cached (--deep only)
+	GapPartial = "partial"
+)
+
+// Gap is a run of consecutive days, From to To inclusive, that one channel
+// is missing for the same reason
+type Gap struct {
+	Channel string
+	From    time.Time
+	To      time.Time
+	Reason  string
+	// FirstMessage, LastMessage and LastFetched describe the cached
+	// messages of a partial day; zero otherwise
+	FirstMessage time.Time
+	LastMessage  time.Time
+	LastFetched  time.Time
+}
+
+// Days returns how many calendar days the gap spans
+func (g Gap) Days() int {
+	// Days around a DST change are 23 or 25 hours long
+	return int(g.To.Sub(g.From).Round(24*time.Hour).Hours()/24) + 1
+}
+
+// FindGaps lists the days in [f.From, f.To) that the cache has no
+// partition for, per channel the filter selects (every cached channel when
+// it names none). A zero From starts at each channel's first partition; a
+// zero To ends before today. Quiet days without messages have no partition
+// either and are reported too. Days are cut in the partition time zone.
+//
+// With deep set, days that do have partitions are read: a day whose
+// messages were all fetched before it ended is reported as GapPartial.
+// Partial days are reported one by one, with their first and last message.
+func (pc *ParquetCache) FindGaps(ctx context.Context, f MessageFilter, deep bool) ([]Gap, error) {
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		return nil, err
+	}
+
+	end := f.To
+	if end.IsZero() {
+		end = GranularityDay.Start(time.Now().In(pc.location))
+	}
+
+	var channels []string
+	byChannel := make(map[string][]Partition)
+	for _, p := range partitions {
+		if !(MessageFilter{Channels: f.Channels}).Partition(p) {
+			continue
+		}
+		if _, seen := byChannel[p.Channel]; !seen {
+			channels = append(channels, p.Channel)
+		}
+		byChannel[p.Channel] = append(byChannel[p.Channel], p)
+	}
+	// Channels asked for by name but never cached are missing throughout
+	for _, name := range f.Channels {
+		_, seen := byChannel[partitionChannel(name)]
+		if _, seenID := byChannel[strings.TrimPrefix(name, "#")]; !seen && !seenID && !f.From.IsZero() {
+			channels = append(channels, partitionChannel(name))
+		}
+	}
+
+	var gaps []Gap
+	for _, channel := range channels {
+		parts := byChannel[channel]
+		start := f.From
+		if start.IsZero() {
+			start = parts[0].Start
+		}
+		start = GranularityDay.Start(start.In(pc.location))
+
+		for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+			dayEnd := day.AddDate(0, 0, 1)
+			var covering []Partition
+			for _, p := range parts {
+				if p.Start.Before(dayEnd) && p.Start.Add(p.Granularity.Duration(p.Start)).After(day) {
+					covering = append(covering, p)
+				}
+			}
+
+			if len(covering) == 0 {
+				gaps = appendGap(gaps, Gap{Channel: channel, From: day, To: day, Reason: GapMissing})
+				continue
+			}
+			if !deep {
+				continue
+			}
+			gap, partial, err := pc.partialDay(ctx, covering, day, dayEnd)
+			if err != nil {
+				return nil, err
+			}
+			if partial {
+				gap.Channel = channel
+				gaps = append(gaps, gap)
+			}
+		}
+	}
+	return gaps, nil
+}
+
+// partialDay reads the partitions covering [day, dayEnd) and reports
+// whether the day's messages were all fetched before it ended
+func (pc *ParquetCache) partialDay(ctx context.Context, covering []Partition, day, dayEnd time.Time) (Gap, bool, error) {
+	gap := Gap{From: day, To: day, Reason: GapPartial}
+	for _, p := range covering {
+		msgs, err := pc.ReadMessagesInRange(ctx, p.Path, day, dayEnd)
+		if err != nil {
+			return gap, false, err
+		}
+		for _, msg := range msgs {
+			if msg.Timestamp.Before(day) || !msg.Timestamp.Before(dayEnd) {
+				continue
+			}
+			if gap.FirstMessage.IsZero() || msg.Timestamp.Before(gap.FirstMessage) {
+				gap.FirstMessage = msg.Timestamp
+			}
+			if msg.Timestamp.After(gap.LastMessage) {
+				gap.LastMessage = msg.Timestamp
+			}
+			if msg.FetchedAt.After(gap.LastFetched) {
+				gap.LastFetched = msg.FetchedAt
+			}
+		}
+	}
+	// Files older than fetched_at have no fetch times to go by
+	partial := !gap.LastFetched.IsZero() && gap.LastFetched.Before(dayEnd)
+	return gap, partial, nil
+}
+
+// appendGap adds a missing day to gaps, extending the last gap when it is
+// the same channel's previous day
+func appendGap(gaps []Gap, g Gap) []Gap {
+	if n := len(gaps); n > 0 {
+		last := &gaps[n-1]
+		if last.Channel == g.Channel && last.Reason == g.Reason && last.To.AddDate(0, 0, 1).Equal(g.From) {
+			last.To = g.To
+			return gaps
+		}
+	}
+	return append(gaps, g)
+}
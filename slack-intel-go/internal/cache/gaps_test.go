@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+func TestFindGaps(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(storage.NewMemory())
+	channel := &models.SlackChannel{Name: "backend", ID: "C1"}
+
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	save := func(d int, at, fetched time.Duration) {
+		t.Helper()
+		msg := &models.SlackMessage{MessageID: day(d).Add(at).Format("0102.150405"), Text: "hi",
+			Timestamp: day(d).Add(at), FetchedAt: day(d).Add(fetched)}
+		if _, err := pc.SaveMessages([]*models.SlackMessage{msg}, channel, day(d).Format("2006-01-02")); err != nil {
+			t.Fatalf("SaveMessages: %v", err)
+		}
+	}
+	// Jan 2 and 5 fully fetched; Jan 3-4 missing; Jan 6 fetched mid-day
+	save(2, 9*time.Hour, 30*time.Hour)
+	save(5, 9*time.Hour, 26*time.Hour)
+	save(6, 9*time.Hour, 10*time.Hour)
+
+	filter := MessageFilter{Channels: []string{"#backend", "frontend"}, From: day(1), To: day(7)}
+	gaps, err := pc.FindGaps(ctx, filter, false)
+	if err != nil {
+		t.Fatalf("FindGaps: %v", err)
+	}
+	want := []Gap{
+		{Channel: "backend", From: day(1), To: day(1), Reason: GapMissing},
+		{Channel: "backend", From: day(3), To: day(4), Reason: GapMissing},
+		{Channel: "frontend", From: day(1), To: day(6), Reason: GapMissing},
+	}
+	if len(gaps) != len(want) {
+		t.Fatalf("gaps = %+v, want %+v", gaps, want)
+	}
+	for i := range want {
+		if gaps[i] != want[i] {
+			t.Errorf("gap %d = %+v, want %+v", i, gaps[i], want[i])
+		}
+	}
+	if gaps[1].Days() != 2 {
+		t.Errorf("Days() = %d, want 2", gaps[1].Days())
+	}
+
+	deep, err := pc.FindGaps(ctx, MessageFilter{From: day(5), To: day(7)}, true)
+	if err != nil {
+		t.Fatalf("FindGaps deep: %v", err)
+	}
+	if len(deep) != 1 || deep[0].Reason != GapPartial || !deep[0].From.Equal(day(6)) ||
+		!deep[0].LastFetched.Equal(day(6).Add(10*time.Hour)) || !deep[0].LastMessage.Equal(day(6).Add(9*time.Hour)) {
+		t.Errorf("deep gaps = %+v, want Jan 6 partial", deep)
+	}
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// BuildJiraIndex aggregates the JIRA tickets of every cached message into
+// one index, reading all partitions whatever their schema version (every
+// version has the jira_tickets column)
+func (pc *ParquetCache) BuildJiraIndex(ctx context.Context) (map[string]*models.JiraMentions, error) {
+	var index map[string]*models.JiraMentions
+	err := pc.scanMessages(ctx, MessageFilter{}, true, func(p Partition, msgs []*models.SlackMessage) error {
+		index = models.MergeJiraMentions(index, models.AggregateJiraMentions(p.Channel, msgs))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// SaveJiraIndex writes the ticket index to cache/raw/jira_index.parquet,
+// sorted by ticket ID, replacing the previous index
+func (pc *ParquetCache) SaveJiraIndex(index map[string]*models.JiraMentions) (string, error) {
+	if len(index) == 0 {
+		return "", nil
+	}
+
+	indexPath := pc.JiraIndexPath()
+
+	tickets := make([]string, 0, len(index))
+	for ticket := range index {
+		tickets = append(tickets, ticket)
+	}
+	sort.Strings(tickets)
+
+	schema := createJiraIndexSchema()
+
+	mem := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	cachedAt := time.Now().Format(time.RFC3339)
+	appendStrings := func(b *array.ListBuilder, values []string) {
+		b.Append(true)
+		for _, v := range values {
+			b.ValueBuilder().(*array.StringBuilder).Append(v)
+		}
+	}
+
+	for _, ticket := range tickets {
+		j := index[ticket]
+		builder.Field(0).(*array.StringBuilder).Append(j.TicketID)
+		builder.Field(1).(*array.Int64Builder).Append(int64(j.MentionCount))
+		appendStrings(builder.Field(2).(*array.ListBuilder), j.Channels)
+		appendStrings(builder.Field(3).(*array.ListBuilder), j.MessageIDs)
+		appendStrings(builder.Field(4).(*array.ListBuilder), j.UserIDs)
+		builder.Field(5).(*array.StringBuilder).Append(j.FirstSeen.UTC().Format(time.RFC3339))
+		builder.Field(6).(*array.StringBuilder).Append(j.LastSeen.UTC().Format(time.RFC3339))
+		builder.Field(7).(*array.StringBuilder).Append(cachedAt)
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	if err := pc.writeRecord(indexPath, schema, record); err != nil {
+		return "", fmt.Errorf("failed to write JIRA index: %w", err)
+	}
+
+	pc.logger.Debug("wrote JIRA index", "path", indexPath, "tickets", len(index))
+
+	return indexPath, nil
+}
@@ -0,0 +1,71 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+func TestJiraIndex(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(storage.NewMemory())
+
+	at := func(d int) time.Time { return time.Date(2024, 1, d, 9, 0, 0, 0, time.UTC) }
+	save := func(channel string, d int, msgs ...*models.SlackMessage) {
+		t.Helper()
+		ch := &models.SlackChannel{Name: channel, ID: "C" + channel}
+		if _, err := pc.SaveMessages(msgs, ch, at(d).Format("2006-01-02")); err != nil {
+			t.Fatalf("SaveMessages: %v", err)
+		}
+	}
+	save("backend", 1, &models.SlackMessage{MessageID: "1.0", UserID: "U1", Text: "PROJ-1", Timestamp: at(1), JiraTickets: []string{"PROJ-1"}})
+	save("backend", 2, &models.SlackMessage{MessageID: "2.0", UserID: "U2", Text: "PROJ-1 OPS-7", Timestamp: at(2), JiraTickets: []string{"PROJ-1", "OPS-7"}})
+	save("general", 3, &models.SlackMessage{MessageID: "3.0", UserID: "U1", Text: "PROJ-1", Timestamp: at(3), JiraTickets: []string{"PROJ-1"}},
+		&models.SlackMessage{MessageID: "4.0", UserID: "U3", Text: "no tickets", Timestamp: at(3)})
+
+	index, err := pc.BuildJiraIndex(ctx)
+	if err != nil {
+		t.Fatalf("BuildJiraIndex: %v", err)
+	}
+	path, err := pc.SaveJiraIndex(index)
+	if err != nil {
+		t.Fatalf("SaveJiraIndex: %v", err)
+	}
+	if path != pc.JiraIndexPath() {
+		t.Errorf("path = %s, want %s", path, pc.JiraIndexPath())
+	}
+
+	table, err := pc.readTable(ctx, path)
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	defer table.Release()
+	if table.NumRows() != 2 {
+		t.Fatalf("got %d rows, want 2", table.NumRows())
+	}
+
+	ids := table.Column(0).Data().Chunk(0).(*array.String)
+	counts := table.Column(1).Data().Chunk(0).(*array.Int64)
+	channels := table.Column(2).Data().Chunk(0).(*array.List)
+	first := table.Column(5).Data().Chunk(0).(*array.String)
+	last := table.Column(6).Data().Chunk(0).(*array.String)
+
+	// Sorted by ticket ID: OPS-7, PROJ-1
+	if ids.Value(0) != "OPS-7" || counts.Value(0) != 1 {
+		t.Errorf("row 0 = %s x%d, want OPS-7 x1", ids.Value(0), counts.Value(0))
+	}
+	if ids.Value(1) != "PROJ-1" || counts.Value(1) != 3 {
+		t.Errorf("row 1 = %s x%d, want PROJ-1 x3", ids.Value(1), counts.Value(1))
+	}
+	if got := listValue(channels, 1); len(got) != 2 || got[0] != "backend" || got[1] != "general" {
+		t.Errorf("PROJ-1 channels = %v, want [backend general]", got)
+	}
+	if first.Value(1) != "2024-01-01T09:00:00Z" || last.Value(1) != "2024-01-03T09:00:00Z" {
+		t.Errorf("PROJ-1 seen %s to %s, want Jan 1 to Jan 3", first.Value(1), last.Value(1))
+	}
+}
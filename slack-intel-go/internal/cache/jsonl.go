@@ -0,0 +1,226 @@
+package cache
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// JSONLCache writes message partitions as gzipped newline-delimited JSON
+// (data.jsonl.gz) for tooling that cannot read Parquet. It shares the
+// partition layout, time zone, dedup strategy, storage and raw sidecars of
+// the ParquetCache it wraps; users, channels and user stats are still
+// written as Parquet. The key-value metadata a Parquet file would carry
+// goes to _metadata.json beside each file (JSONLMetadataPath). Cache
+// commands that read partitions only see data.parquet.
+type JSONLCache struct {
+	*ParquetCache
+}
+
+// NewJSONLCache writes messages as JSONL with the settings of pc
+func NewJSONLCache(pc *ParquetCache) *JSONLCache {
+	return &JSONLCache{ParquetCache: pc}
+}
+
+// SaveMessages merges messages into a partition's data.jsonl.gz as
+// MergeMessages does
+func (jc *JSONLCache) SaveMessages(messages []*models.SlackMessage, channel *models.SlackChannel, partition string) (string, error) {
+	return jc.MergeMessages(context.Background(), messages, channel, partition)
+}
+
+// MergeMessages writes messages into a partition, keeping the messages
+// already stored there whose message_id is not among messages; when a
+// message is in both, the dedup strategy picks the copy kept. A cache
+// from Refetched drops the stored messages of its window instead. Lines
+// are written in timestamp order.
+func (jc *JSONLCache) MergeMessages(ctx context.Context, messages []*models.SlackMessage, channel *models.SlackChannel, partition string) (string, error) {
+	if len(messages) == 0 {
+		return "", fmt.Errorf("no messages to save")
+	}
+
+	filePath, err := jc.partitionFile(channel, partition, FormatJSONL)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := jc.LoadJSONL(filePath)
+	if err != nil && !storage.IsNotExist(err) {
+		return "", err
+	}
+	byID := make(map[string]*models.SlackMessage, len(existing)+len(messages))
+	stored := make(map[string]storedVersion, len(existing))
+	for _, msg := range existing {
+		byID[msg.MessageID] = msg
+		stored[msg.MessageID] = storedVersion{fetched: msg.FetchedAt, edited: msg.EditedAt, posted: msg.Timestamp, threadTS: msg.ThreadTS}
+	}
+	incoming := make([]*models.SlackMessage, 0, len(messages))
+	for _, msg := range messages {
+		if v, ok := stored[msg.MessageID]; ok && jc.dedup.keepStored(v, msg) {
+			continue
+		}
+		byID[msg.MessageID] = msg
+		incoming = append(incoming, msg)
+	}
+	deleted := jc.refetched.deleted(stored, messages)
+	for _, id := range deleted {
+		delete(byID, id)
+	}
+	if len(incoming) == 0 && len(deleted) == 0 {
+		jc.logger.Debug("partition already up to date", "path", filePath, "rows", len(messages), "dedup", jc.dedup)
+		return filePath, nil
+	}
+
+	merged := make([]*models.SlackMessage, 0, len(byID))
+	for _, msg := range byID {
+		merged = append(merged, msg)
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		if !merged[i].Timestamp.Equal(merged[j].Timestamp) {
+			return merged[i].Timestamp.Before(merged[j].Timestamp)
+		}
+		return merged[i].MessageID < merged[j].MessageID
+	})
+	if err := jc.writeJSONL(filePath, merged); err != nil {
+		return "", err
+	}
+
+	// Messages kept from a thin file leave the merged file thin
+	metadata := jc.metadata
+	if len(merged) > len(incoming) {
+		storedMeta, err := jc.LoadJSONLMetadata(filePath)
+		if err != nil {
+			return "", err
+		}
+		metadata = carryThin(metadata, storedMeta)
+	}
+	if err := jc.writeJSONLMetadata(filePath, metadata); err != nil {
+		return "", err
+	}
+	if jc.raw == RawSidecar {
+		if err := jc.mergeSidecar(filePath, incoming, deleted); err != nil {
+			return "", err
+		}
+	}
+
+	jc.logger.Debug("wrote partition", "path", filePath, "rows", len(incoming), "kept", len(merged)-len(incoming), "deleted", len(deleted))
+
+	return filePath, nil
+}
+
+// LoadJSONL reads the messages of a data.jsonl.gz file. A missing file
+// yields an error for which storage.IsNotExist is true.
+func (jc *JSONLCache) LoadJSONL(path string) ([]*models.SlackMessage, error) {
+	f, err := jc.storage.Reader(path)
+	if err != nil {
+		if storage.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer zr.Close()
+
+	var messages []*models.SlackMessage
+	dec := json.NewDecoder(zr)
+	for line := 1; ; line++ {
+		var msg models.SlackMessage
+		if err := dec.Decode(&msg); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, line, err)
+		}
+		messages = append(messages, &msg)
+	}
+	return messages, nil
+}
+
+// JSONLMetadataPath returns the file holding the key-value metadata of a
+// partition's data.jsonl.gz, which has no room for it
+func JSONLMetadataPath(dataPath string) string {
+	return filepath.Join(filepath.Dir(dataPath), "_metadata.json")
+}
+
+// LoadJSONLMetadata reads the metadata written with a data.jsonl.gz file
+// (token_type, redacted, thin marks, truncated, ...). A file written
+// without it yields nil.
+func (jc *JSONLCache) LoadJSONLMetadata(dataPath string) (map[string]string, error) {
+	path := JSONLMetadataPath(dataPath)
+	f, err := jc.storage.Reader(path)
+	if storage.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	var metadata map[string]string
+	if err := json.NewDecoder(f).Decode(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return metadata, nil
+}
+
+// writeJSONLMetadata replaces the metadata file of dataPath
+func (jc *JSONLCache) writeJSONLMetadata(dataPath string, metadata map[string]string) error {
+	path := JSONLMetadataPath(dataPath)
+	data, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	w, err := jc.storage.Writer(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		storage.Abort(w)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSONL replaces path with messages, one JSON object per line, gzipped
+func (jc *JSONLCache) writeJSONL(path string, messages []*models.SlackMessage) error {
+	w, err := jc.storage.Writer(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	zw := gzip.NewWriter(w)
+	bw := bufio.NewWriter(zw)
+	enc := json.NewEncoder(bw)
+	for _, msg := range messages {
+		if err := enc.Encode(msg); err != nil {
+			storage.Abort(w)
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		storage.Abort(w)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := zw.Close(); err != nil {
+		storage.Abort(w)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish %s: %w", path, err)
+	}
+	return nil
+}
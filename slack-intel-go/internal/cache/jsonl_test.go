@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestJSONLMergesPartitions(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "raw")
+	jc := NewJSONLCache(NewParquetCache(base))
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+	fetched := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	first := []*models.SlackMessage{
+		{MessageID: "1715342400.000200", Text: "second", Timestamp: time.Unix(1715342400, 200000).UTC(), FetchedAt: fetched},
+		{
+			MessageID: "1715342400.000100", Text: "first", Timestamp: time.Unix(1715342400, 100000).UTC(), FetchedAt: fetched,
+			Reactions: []models.SlackReaction{{Emoji: "eyes", Count: 2, Users: []string{"U1", "U2"}}},
+			Files:     []models.SlackFile{{ID: "F1", Name: "plot.png", Mimetype: "image/png", Size: 1024}},
+		},
+	}
+	path, err := jc.SaveMessages(first, channel, "2024-05-10")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if want := filepath.Join(base, "messages", "dt=2024-05-10", "channel=general", "data.jsonl.gz"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+
+	// An edit replaces its stored copy; the other stored message is kept
+	edited := *first[0]
+	edited.Text, edited.EditedAt, edited.FetchedAt = "second (edited)", fetched.Add(time.Hour), fetched.Add(2*time.Hour)
+	added := &models.SlackMessage{MessageID: "1715342500.000100", Text: "third", Timestamp: time.Unix(1715342500, 100000).UTC(), FetchedAt: fetched}
+	if _, err := jc.SaveMessages([]*models.SlackMessage{added, &edited}, channel, "2024-05-10"); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	got, err := jc.LoadJSONL(path)
+	if err != nil {
+		t.Fatalf("LoadJSONL: %v", err)
+	}
+	want := []*models.SlackMessage{first[1], &edited, added}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("stored messages:\n got %+v\nwant %+v", got, want)
+	}
+
+	// No Parquet file is written, so Parquet readers see no partition
+	partitions, err := jc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if len(partitions) != 0 {
+		t.Errorf("ListPartitions = %+v, want none", partitions)
+	}
+}
+
+func TestJSONLWritesMetadata(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetMetadata("redacted", "true")
+	pc.MarkThin(ThinThreads)
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+
+	thin := []*models.SlackMessage{{MessageID: "1715342400.000100", Text: "first", Timestamp: time.Unix(1715342400, 100000).UTC()}}
+	path, err := NewJSONLCache(pc.WithMetadata("truncated", "no-cursor")).SaveMessages(thin, channel, "2024-05-10")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	meta, err := NewJSONLCache(pc).LoadJSONLMetadata(path)
+	if err != nil {
+		t.Fatalf("LoadJSONLMetadata: %v", err)
+	}
+	for key, want := range map[string]string{"redacted": "true", ThinThreads: thinSkipped, "truncated": "no-cursor"} {
+		if meta[key] != want {
+			t.Errorf("metadata %s = %q, want %q", key, meta[key], want)
+		}
+	}
+
+	// Merging a full fetch into the thin file keeps it marked thin
+	full := NewParquetCache(pc.basePath)
+	added := []*models.SlackMessage{{MessageID: "1715342500.000100", Text: "second", Timestamp: time.Unix(1715342500, 100000).UTC()}}
+	if _, err := NewJSONLCache(full).SaveMessages(added, channel, "2024-05-10"); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if meta, err = NewJSONLCache(full).LoadJSONLMetadata(path); err != nil {
+		t.Fatalf("LoadJSONLMetadata: %v", err)
+	}
+	if !reflect.DeepEqual(Thin(meta), []string{ThinThreads}) || meta["truncated"] != "" {
+		t.Errorf("merged metadata = %v, want threads still thin and no truncated mark", meta)
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, s := range []string{"parquet", "jsonl"} {
+		if f, err := ParseFormat(s); err != nil || string(f) != s {
+			t.Errorf("ParseFormat(%q) = %q, %v", s, f, err)
+		}
+	}
+	if _, err := ParseFormat("csv"); err == nil {
+		t.Error("ParseFormat(csv) succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// writeBatchRows is how many messages become one Arrow record before it is
+// encoded into the current row group, so a partition's rows are never all
+// held as Arrow arrays at once
+const writeBatchRows = 10_000
+
+// messageWriter streams rows into one partition file. Messages are turned
+// into Arrow records writeBatchRows at a time and appended to a buffered
+// row group, which is flushed to the file once it holds the writer's
+// RowGroupRows; only Close makes the file visible. Messages must be
+// written before any WriteRecord.
+type messageWriter struct {
+	pc     *ParquetCache
+	path   string
+	w      io.WriteCloser
+	fw     *pqarrow.FileWriter
+	closed bool
+
+	// channelName, rawColumn and stored are passed on to messageRecord
+	channelName string
+	rawColumn   bool
+	stored      map[string]StoredFlags
+}
+
+// newMessageWriter opens path for writing messages in pc.schema with the
+// given file metadata
+func (pc *ParquetCache) newMessageWriter(path string, metadata map[string]string) (*messageWriter, error) {
+	w, err := pc.storage.Writer(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	fw, err := pc.newFileWriter(pc.schema, w, metadata)
+	if err != nil {
+		storage.Abort(w)
+		return nil, err
+	}
+	return &messageWriter{pc: pc, path: path, w: w, fw: fw}, nil
+}
+
+// Write appends messages to the file in batches
+func (mw *messageWriter) Write(messages []*models.SlackMessage) error {
+	for len(messages) > 0 {
+		n := min(len(messages), writeBatchRows)
+		record := mw.pc.messageRecord(messages[:n], mw.channelName, mw.rawColumn, mw.stored)
+		err := mw.fw.WriteBuffered(record)
+		record.Release()
+		if err != nil {
+			mw.Abort()
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+		messages = messages[n:]
+	}
+	return nil
+}
+
+// WriteRecord appends rows already in pc.schema, such as a run of rows
+// kept from the stored partition, as row groups of their own so their
+// statistics stay apart from the written messages
+func (mw *messageWriter) WriteRecord(record arrow.Record) error {
+	if err := mw.fw.Write(record); err != nil {
+		mw.Abort()
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// Close flushes the last row group and writes the footer, publishing the
+// file
+func (mw *messageWriter) Close() error {
+	if mw.closed {
+		return nil
+	}
+	mw.closed = true
+	// Closing the Parquet writer writes the footer and closes w
+	if err := mw.fw.Close(); err != nil {
+		return fmt.Errorf("failed to finish %s: %w", mw.path, err)
+	}
+	return nil
+}
+
+// Abort discards the unpublished file; it does nothing after Close
+func (mw *messageWriter) Abort() {
+	if mw.closed {
+		return
+	}
+	mw.closed = true
+	storage.Abort(mw.w)
+}
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// urlsSince is the first schema version with the urls column
+const urlsSince = 5
+
+// MigrateMessages upgrades the partition at path to SchemaVersion in
+// place and returns the schema_version it had. Files already current are
+// left alone (migrated is false). Columns added since the file was written
+// read back empty and are written as nulls or empty lists, apart from
+// fetched_at, which becomes the time of migration, and urls, which derive
+// recomputes from the stored text when the file predates that column. A
+// nil derive leaves urls empty.
+func (pc *ParquetCache) MigrateMessages(ctx context.Context, path string, derive func(*models.SlackMessage) *models.SlackMessage) (from string, migrated bool, err error) {
+	metadata, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		return "", false, err
+	}
+	from = metadata["schema_version"]
+	if from == SchemaVersion {
+		return from, false, nil
+	}
+
+	msgs, err := pc.ReadMessages(ctx, path)
+	if err != nil {
+		return from, false, err
+	}
+	if version, _ := strconv.Atoi(from); version < urlsSince && derive != nil {
+		for i, msg := range msgs {
+			msgs[i] = derive(msg)
+		}
+	}
+	if err := pc.RewriteMessages(ctx, path, msgs); err != nil {
+		return from, false, err
+	}
+	return from, true, nil
+}
+
+// MigrateUsers rewrites users.parquet in the current schema and returns
+// the schema_version it had. A missing or current file is left alone.
+func (pc *ParquetCache) MigrateUsers(ctx context.Context) (from string, migrated bool, err error) {
+	path := pc.readPath(pc.UsersPath())
+	exists, err := pc.storage.Exists(path)
+	if err != nil || !exists {
+		return "", false, err
+	}
+	metadata, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		return "", false, err
+	}
+	from = metadata["schema_version"]
+	if from == SchemaVersion {
+		return from, false, nil
+	}
+
+	users, err := pc.LoadUsers(ctx)
+	if err != nil {
+		return from, false, err
+	}
+	if _, err := pc.SaveUsers(users); err != nil {
+		return from, false, err
+	}
+	return from, true, nil
+}
@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// writeV1Partition writes a partition the way the first release did: the
+// first 18 message columns, local timestamps and no key-value metadata.
+// Its first row has files and a pin, its second row reactions.
+func writeV1Partition(t *testing.T, pc *ParquetCache, channel *models.SlackChannel, partition string) string {
+	t.Helper()
+	schema := arrow.NewSchema(createMessageSchema().Fields()[:18], nil)
+	b := array.NewRecordBuilder(memory.NewGoAllocator(), schema)
+	defer b.Release()
+
+	row := func(ts, user, text string, reactions, files, pinned bool) {
+		sec, _ := strconv.ParseInt(strings.Split(ts, ".")[0], 10, 64)
+		at := time.Unix(sec, 0).In(time.FixedZone("CET", 3600))
+		b.Field(0).(*array.StringBuilder).Append(ts)
+		b.Field(1).(*array.StringBuilder).Append(user)
+		b.Field(2).(*array.StringBuilder).Append(text)
+		b.Field(3).(*array.StringBuilder).Append(at.Format(time.RFC3339))
+		b.Field(4).(*array.StringBuilder).AppendNull()
+		b.Field(5).(*array.BooleanBuilder).Append(false)
+		b.Field(6).(*array.BooleanBuilder).Append(false)
+		b.Field(7).(*array.Int64Builder).Append(0)
+		b.Field(8).(*array.StringBuilder).Append("alice")
+		b.Field(9).(*array.StringBuilder).Append("Alice")
+		b.Field(10).(*array.StringBuilder).AppendNull()
+		b.Field(11).(*array.BooleanBuilder).Append(false)
+		tickets := b.Field(12).(*array.ListBuilder)
+		tickets.Append(true)
+		if strings.Contains(text, "PROJ-7") {
+			tickets.ValueBuilder().(*array.StringBuilder).Append("PROJ-7")
+		}
+		b.Field(13).(*array.BooleanBuilder).Append(reactions)
+		b.Field(14).(*array.BooleanBuilder).Append(files)
+		b.Field(15).(*array.BooleanBuilder).Append(false)
+		b.Field(16).(*array.BooleanBuilder).Append(pinned)
+		b.Field(17).(*array.StringBuilder).Append("https://acme.slack.com/archives/C1/p" + strings.ReplaceAll(ts, ".", ""))
+	}
+	row("1700000000.000100", "U1", "PROJ-7 is tracked at <https://example.com/proj-7>", false, true, true)
+	row("1700000060.000200", "U1", "thanks", true, false, false)
+
+	record := b.NewRecord()
+	defer record.Release()
+
+	path, err := pc.partitionPath(channel, partition)
+	if err != nil {
+		t.Fatalf("partitionPath: %v", err)
+	}
+	if err := pc.writeFile(path, schema, nil, record); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+	return path
+}
+
+func TestMigrateMessagesFromV1(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	path := writeV1Partition(t, pc, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+
+	derive := func(m *models.SlackMessage) *models.SlackMessage {
+		c := *m
+		c.URLs = []string{"https://example.com/proj-7"}
+		return &c
+	}
+	from, migrated, err := pc.MigrateMessages(ctx, path, derive)
+	if err != nil {
+		t.Fatalf("MigrateMessages: %v", err)
+	}
+	if from != "" || !migrated {
+		t.Errorf("MigrateMessages = %q, %v; want unversioned file migrated", from, migrated)
+	}
+
+	report := VerifyFile(ctx, path, pc.schema)
+	if !report.OK() {
+		t.Fatalf("migrated file fails verification: %+v", report)
+	}
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if meta["schema_version"] != SchemaVersion || meta["tool_version"] != ToolVersion {
+		t.Errorf("metadata = %v, want current schema and tool version", meta)
+	}
+
+	msgs, err := pc.ReadMessages(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	msg := msgs[0]
+	if msg.Timestamp.Location() != time.UTC || !msg.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("timestamp = %v, want 2023-11-14T22:13:20Z", msg.Timestamp)
+	}
+	if msg.UserInfo == nil || msg.UserInfo.Name != "alice" || len(msg.JiraTickets) != 1 || msg.Permalink == "" {
+		t.Errorf("migrated message = %+v, want v1 columns kept", msg)
+	}
+	if len(msg.URLs) != 1 || msg.CleanText != "" || msg.Blocks != nil || len(msg.Reactions) != 0 {
+		t.Errorf("migrated message = %+v, want urls derived and other new columns empty", msg)
+	}
+	flags, err := pc.ReadStoredFlags(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadStoredFlags: %v", err)
+	}
+	if f := flags[msg.MessageID]; !f.Pinned || !f.Files || f.Reactions {
+		t.Errorf("stored flags of %s = %+v, want is_pinned and has_files kept", msg.MessageID, f)
+	}
+	if f := flags[msgs[1].MessageID]; !f.Reactions || f.Files || f.Pinned {
+		t.Errorf("stored flags of %s = %+v, want has_reactions kept", msgs[1].MessageID, f)
+	}
+
+	if _, migrated, err := pc.MigrateMessages(ctx, path, derive); err != nil || migrated {
+		t.Errorf("second MigrateMessages = %v, %v; want a no-op", migrated, err)
+	}
+}
+
+func TestScanMessagesRefusesMixedSchemas(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+	writeV1Partition(t, pc, channel, "2023-11-14")
+	msg := &models.SlackMessage{MessageID: "1700086400.000100", Text: "next day", Timestamp: time.Unix(1700086400, 0)}
+	if _, err := pc.SaveMessages([]*models.SlackMessage{msg}, channel, "2023-11-15"); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	count := func() (int, error) {
+		n := 0
+		err := pc.ScanMessages(ctx, MessageFilter{}, func(p Partition, msgs []*models.SlackMessage) error {
+			n += len(msgs)
+			return nil
+		})
+		return n, err
+	}
+
+	// Refused before fn sees any partition
+	if n, err := count(); !errors.Is(err, ErrMixedSchemas) || !strings.Contains(err.Error(), "unversioned") || n != 0 {
+		t.Errorf("ScanMessages = %d, %v; want ErrMixedSchemas naming the unversioned file and no messages", n, err)
+	}
+
+	// Either allowing the mix or migrating the old file lets it through
+	pc.SetAllowMixedSchemas(true)
+	if n, err := count(); err != nil || n != 3 {
+		t.Errorf("ScanMessages with mixed schemas allowed = %d, %v; want 3 messages", n, err)
+	}
+	pc.SetAllowMixedSchemas(false)
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	for _, p := range partitions {
+		if _, _, err := pc.MigrateMessages(ctx, p.Path, nil); err != nil {
+			t.Fatalf("MigrateMessages: %v", err)
+		}
+	}
+	if n, err := count(); err != nil || n != 3 {
+		t.Errorf("ScanMessages after migrating = %d, %v; want 3 messages", n, err)
+	}
+}
+
+func TestMigrateUsers(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	if _, migrated, err := pc.MigrateUsers(ctx); err != nil || migrated {
+		t.Fatalf("MigrateUsers without users.parquet = %v, %v; want a no-op", migrated, err)
+	}
+
+	old := NewParquetCache(pc.basePath)
+	old.SetMetadata("schema_version", "3")
+	if _, err := old.SaveUsers(map[string]*models.SlackUser{"U1": {ID: "U1", Name: "alice"}}); err != nil {
+		t.Fatalf("SaveUsers: %v", err)
+	}
+
+	from, migrated, err := pc.MigrateUsers(ctx)
+	if err != nil || from != "3" || !migrated {
+		t.Fatalf("MigrateUsers = %q, %v, %v; want version 3 migrated", from, migrated, err)
+	}
+	users, err := pc.LoadUsers(ctx)
+	if err != nil || users["U1"] == nil || users["U1"].Name != "alice" {
+		t.Errorf("LoadUsers after migrating = %v, %v; want alice kept", users, err)
+	}
+}
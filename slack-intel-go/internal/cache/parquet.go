@@ -1,9 +1,13 @@
 package cache
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"io"
+	"log/slog"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"time"
 
 	"github.com/apache/arrow/go/v14/arrow"
@@ -13,20 +17,406 @@ import (
 	"github.com/apache/arrow/go/v14/parquet/compress"
 	"github.com/apache/arrow/go/v14/parquet/pqarrow"
 	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
 )
 
+// SchemaVersion is written to every file's key-value metadata as
+// schema_version and bumped whenever a column is added or changes format.
+// 2: messages gained fetched_at.
+// 3: messages gained reactions.
+// 4: messages gained clean_text.
+// 5: messages gained urls.
+// 6: messages gained blocks.
+// 7: messages gained raw_json.
+// 8: timestamp is written in UTC, so its row group statistics order by time.
+// 9: messages gained channel_name, the channel's name before sanitizing.
+// 10: messages gained edited_at.
+// 11: messages gained iso_week, weekday, hour_of_day and is_business_hours.
+const SchemaVersion = "11"
+
+// ToolVersion is written next to schema_version as tool_version, naming
+// the build that wrote a file; main sets it from its own version
+var ToolVersion = "dev"
+
 // ParquetCache handles writing messages to Parquet files
 type ParquetCache struct {
 	basePath string
 	schema   *arrow.Schema
+	metadata map[string]string
+	logger   *slog.Logger
+	storage  storage.Storage
+	template *NameTemplate
+	// partFile is the name of each partition's Parquet data file
+	partFile string
+	raw      RawMode
+	// allowMixed lets ScanMessages read partitions of differing schema
+	// versions
+	allowMixed bool
+	writer     WriterOptions
+	// location is the time zone partition keys are cut in
+	location *time.Location
+	// dedup picks the copy kept when a write meets a stored message
+	dedup DedupStrategy
+	// activity derives the weekday and business-hours columns
+	activity ActivityHours
+	// refetched is the window whose stored rows a write drops when the
+	// fetch no longer returned them (Refetched)
+	refetched refetchWindow
+}
+
+// Writer defaults: row groups small enough for row-group pruning to skip
+// most of a busy month partition, and Parquet's customary 1 MiB pages
+const (
+	DefaultRowGroupRows = 100_000
+	DefaultPageSize     = 1 << 20
+)
+
+// WriterOptions tunes the layout of the Parquet files the cache writes.
+// Zero fields use DefaultRowGroupRows and DefaultPageSize.
+type WriterOptions struct {
+	// RowGroupRows caps the rows in one row group; larger records are
+	// split into several
+	RowGroupRows int64
+	// PageSize is the target size of a column's data pages, in bytes
+	PageSize int64
 }
 
-// NewParquetCache creates a new Parquet cache
+// NewParquetCache creates a new Parquet cache on the local filesystem
 func NewParquetCache(basePath string) *ParquetCache {
 	return &ParquetCache{
-		basePath: basePath,
+		basePath: filepath.Clean(basePath),
 		schema:   createMessageSchema(),
+		metadata: map[string]string{
+			"schema_version":     SchemaVersion,
+			"tool_version":       ToolVersion,
+			"partition_timezone": "UTC",
+			"activity_hours":     DefaultActivityHours.String(),
+		},
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+		storage:  storage.Local{},
+		template: defaultTemplate,
+		partFile: DefaultPartFileName,
+		location: time.UTC,
+		dedup:    DefaultDedupStrategy,
+		activity: DefaultActivityHours,
+	}
+}
+
+// SetActivityHours sets the zone and working day the iso_week, weekday,
+// hour_of_day and is_business_hours columns are derived with; every file
+// written records them as activity_hours, and rows a merge keeps from a
+// file with other hours are derived again
+func (pc *ParquetCache) SetActivityHours(h ActivityHours) {
+	pc.activity = h
+	pc.metadata["activity_hours"] = h.String()
+}
+
+// ActivityHours returns the zone and working day derived columns use
+func (pc *ParquetCache) ActivityHours() ActivityHours {
+	return pc.activity
+}
+
+// SetDedupStrategy sets which copy of a message SaveMessages and
+// MergeMessages keep when it is already stored in the partition
+func (pc *ParquetCache) SetDedupStrategy(d DedupStrategy) {
+	pc.dedup = d
+}
+
+// SetPartitionTimezone sets the time zone partition keys are cut in (UTC
+// by default): ListPartitions reads dt= values as times in loc, and every
+// file written records its name as partition_timezone
+func (pc *ParquetCache) SetPartitionTimezone(loc *time.Location) {
+	pc.location = loc
+	pc.metadata["partition_timezone"] = loc.String()
+}
+
+// PartitionTimezone returns the time zone partition keys are cut in
+func (pc *ParquetCache) PartitionTimezone() *time.Location {
+	return pc.location
+}
+
+// defaultTemplate is DefaultNameTemplate, parsed once
+var defaultTemplate, _ = ParseNameTemplate(DefaultNameTemplate)
+
+// SetNameTemplate sets how partition directories are named when writing
+// and recognised when listing partitions
+func (pc *ParquetCache) SetNameTemplate(t *NameTemplate) {
+	pc.template = t
+}
+
+// SetPartFileName sets the name of each partition's Parquet data file,
+// written and recognised when listing partitions; see ParsePartFileName
+func (pc *ParquetCache) SetPartFileName(name string) {
+	pc.partFile = name
+}
+
+// SetStorage sets the backend files are written to and read from. Paths
+// stay relative to basePath, so an S3 store holds the same layout.
+func (pc *ParquetCache) SetStorage(s storage.Storage) {
+	pc.storage = s
+}
+
+// Size returns the size in bytes of a file written by this cache
+func (pc *ParquetCache) Size(path string) (int64, error) {
+	return pc.storage.Size(path)
+}
+
+// SetLogger sets the logger used for debug output about written files
+func (pc *ParquetCache) SetLogger(logger *slog.Logger) {
+	pc.logger = logger
+}
+
+// SetWriterOptions sets the row group and page sizes of files written
+// from now on. They are recorded in the files' metadata (row_group_rows,
+// page_size), so commands rewriting a file later keep its layout.
+func (pc *ParquetCache) SetWriterOptions(o WriterOptions) {
+	pc.writer = o
+	if o.RowGroupRows > 0 {
+		pc.metadata["row_group_rows"] = strconv.FormatInt(o.RowGroupRows, 10)
+	}
+	if o.PageSize > 0 {
+		pc.metadata["page_size"] = strconv.FormatInt(o.PageSize, 10)
+	}
+}
+
+// writerOptions returns the sizes a file with the given metadata is
+// written with: the cache's WriterOptions, else the sizes recorded in the
+// metadata (a rewritten file's own), else the defaults
+func (pc *ParquetCache) writerOptions(metadata map[string]string) WriterOptions {
+	size := func(set int64, key string, def int64) int64 {
+		if set > 0 {
+			return set
+		}
+		if n, err := strconv.ParseInt(metadata[key], 10, 64); err == nil && n > 0 {
+			return n
+		}
+		return def
+	}
+	return WriterOptions{
+		RowGroupRows: size(pc.writer.RowGroupRows, "row_group_rows", DefaultRowGroupRows),
+		PageSize:     size(pc.writer.PageSize, "page_size", DefaultPageSize),
+	}
+}
+
+// SetMetadata records a run-level key/value pair (e.g. token_type) that is
+// written into the key-value metadata of every file this cache produces
+func (pc *ParquetCache) SetMetadata(key, value string) {
+	pc.metadata[key] = value
+}
+
+// WithMetadata returns a copy of the cache that also writes key=value into
+// the metadata of its files, e.g. to flag the partitions of one channel;
+// the original is unchanged
+func (pc *ParquetCache) WithMetadata(key, value string) *ParquetCache {
+	c := *pc
+	c.metadata = make(map[string]string, len(pc.metadata)+1)
+	for k, v := range pc.metadata {
+		c.metadata[k] = v
+	}
+	c.metadata[key] = value
+	return &c
+}
+
+// Like returns a copy of the cache that writes new files the way the file
+// with the given metadata was written: with its token_type, redacted,
+// clean_text and exclude_bots metadata, its row group and page sizes and
+// its raw payload mode
+func (pc *ParquetCache) Like(metadata map[string]string) *ParquetCache {
+	c := *pc
+	c.metadata = make(map[string]string, len(pc.metadata)+6)
+	for k, v := range pc.metadata {
+		c.metadata[k] = v
+	}
+	for _, key := range []string{"token_type", "redacted", "clean_text", "exclude_bots", "row_group_rows", "page_size"} {
+		if v, ok := metadata[key]; ok {
+			c.metadata[key] = v
+		}
+	}
+	mode, err := ParseRawMode(metadata["raw"])
+	if err != nil {
+		mode = RawOff
+	}
+	c.SetRawPayloads(mode)
+	return &c
+}
+
+// Refetched returns a copy of the cache whose writes also drop the stored
+// messages posted in [from, to), and the replies to threads started in
+// it, that are not among the written messages. Use it only for a fetch
+// that returned the whole window, so that a missing message means Slack
+// deleted it.
+func (pc *ParquetCache) Refetched(from, to time.Time) *ParquetCache {
+	c := *pc
+	c.refetched = refetchWindow{from: from, to: to}
+	return &c
+}
+
+// newFileWriter creates a Snappy-compressed Parquet writer carrying the
+// given key-value metadata, with the row group and page sizes of
+// writerOptions
+func (pc *ParquetCache) newFileWriter(schema *arrow.Schema, w io.Writer, metadata map[string]string) (*pqarrow.FileWriter, error) {
+	opts := pc.writerOptions(metadata)
+	props := parquet.NewWriterProperties(
+		parquet.WithCompression(compress.Codecs.Snappy),
+		parquet.WithMaxRowGroupLength(opts.RowGroupRows),
+		parquet.WithDataPageSize(opts.PageSize),
+	)
+
+	writer, err := pqarrow.NewFileWriter(schema, w, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create parquet writer: %w", err)
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if err := writer.AppendKeyValueMetadata(k, metadata[k]); err != nil {
+			writer.Close()
+			return nil, fmt.Errorf("failed to write metadata %s: %w", k, err)
+		}
+	}
+
+	return writer, nil
+}
+
+// writeRecord writes records as a Parquet file at path carrying the run
+// metadata. The file only becomes visible once it has been written
+// completely.
+func (pc *ParquetCache) writeRecord(path string, schema *arrow.Schema, records ...arrow.Record) error {
+	return pc.writeFile(path, schema, pc.metadata, records...)
+}
+
+// writeFile is writeRecord with explicit file metadata
+func (pc *ParquetCache) writeFile(path string, schema *arrow.Schema, metadata map[string]string, records ...arrow.Record) error {
+	w, err := pc.storage.Writer(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+
+	writer, err := pc.newFileWriter(schema, w, metadata)
+	if err != nil {
+		storage.Abort(w)
+		return err
+	}
+	for _, record := range records {
+		if err := writer.Write(record); err != nil {
+			storage.Abort(w)
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+	}
+	// Closing the Parquet writer writes the footer and closes w
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish %s: %w", path, err)
+	}
+	return nil
+}
+
+// Cache-wide files, kept in the base path beside messages/
+const (
+	usersFile     = "users.parquet"
+	userStatsFile = "user_stats.parquet"
+	channelsFile  = "channels.parquet"
+	emojiFile     = "emoji.parquet"
+	jiraIndexFile = "jira_index.parquet"
+	alertsFile    = "alerts.parquet"
+)
+
+// sideFiles are the cache-wide files, which earlier releases kept one
+// level above the base path (cache/users.parquet for cache/raw)
+var sideFiles = []string{usersFile, userStatsFile, channelsFile, emojiFile, jiraIndexFile, alertsFile}
+
+// UsersPath returns the location of the global users file (cache/raw/users.parquet)
+func (pc *ParquetCache) UsersPath() string {
+	return filepath.Join(pc.basePath, usersFile)
+}
+
+// UserStatsPath returns the location of the per-user activity file (cache/raw/user_stats.parquet)
+func (pc *ParquetCache) UserStatsPath() string {
+	return filepath.Join(pc.basePath, userStatsFile)
+}
+
+// ChannelsPath returns the location of the channel metadata file (cache/raw/channels.parquet)
+func (pc *ParquetCache) ChannelsPath() string {
+	return filepath.Join(pc.basePath, channelsFile)
+}
+
+// EmojiPath returns the location of the custom emoji file (cache/raw/emoji.parquet)
+func (pc *ParquetCache) EmojiPath() string {
+	return filepath.Join(pc.basePath, emojiFile)
+}
+
+// JiraIndexPath returns the location of the JIRA ticket index (cache/raw/jira_index.parquet)
+func (pc *ParquetCache) JiraIndexPath() string {
+	return filepath.Join(pc.basePath, jiraIndexFile)
+}
+
+// AlertsPath returns the location of the keyword alert log (cache/raw/alerts.parquet)
+func (pc *ParquetCache) AlertsPath() string {
+	return filepath.Join(pc.basePath, alertsFile)
+}
+
+// LegacyPath returns where releases before the side files moved into the
+// base path kept the file at path: beside the base path instead of in it
+func (pc *ParquetCache) LegacyPath(path string) string {
+	return filepath.Join(filepath.Dir(pc.basePath), filepath.Base(path))
+}
+
+// readPath returns the side file path to read: path itself, or its
+// LegacyPath when only that exists, so caches written by earlier releases
+// keep their users, channels and alerts; writes go to path, and cache
+// migrate moves the old files (MoveLegacyFiles)
+func (pc *ParquetCache) readPath(path string) string {
+	if ok, err := pc.storage.Exists(path); err != nil || ok {
+		return path
+	}
+	legacy := pc.LegacyPath(path)
+	if ok, err := pc.storage.Exists(legacy); err == nil && ok {
+		pc.logger.Debug("reading side file from its legacy location", "path", legacy, "new_path", path)
+		return legacy
 	}
+	return path
+}
+
+// LegacyFiles returns the side files found only at their LegacyPath, by
+// their path in the base path
+func (pc *ParquetCache) LegacyFiles() ([]string, error) {
+	var paths []string
+	for _, name := range sideFiles {
+		path := filepath.Join(pc.basePath, name)
+		if ok, err := pc.storage.Exists(path); err != nil || ok {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		ok, err := pc.storage.Exists(pc.LegacyPath(path))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// MoveLegacyFiles moves the LegacyFiles into the base path, returning
+// their new paths. A file already in the base path is left alone, as is
+// its legacy copy.
+func (pc *ParquetCache) MoveLegacyFiles() ([]string, error) {
+	paths, err := pc.LegacyFiles()
+	if err != nil {
+		return nil, err
+	}
+	for i, path := range paths {
+		if err := storage.Move(pc.storage, pc.LegacyPath(path), path); err != nil {
+			return paths[:i], fmt.Errorf("failed to move %s into %s: %w", pc.LegacyPath(path), pc.basePath, err)
+		}
+	}
+	return paths, nil
 }
 
 // createMessageSchema creates Arrow schema for Slack messages
@@ -48,28 +438,353 @@ func createMessageSchema() *arrow.Schema {
 		{Name: "has_reactions", Type: arrow.FixedWidthTypes.Boolean},
 		{Name: "has_files", Type: arrow.FixedWidthTypes.Boolean},
 		{Name: "has_thread", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "is_pinned", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "permalink", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "fetched_at", Type: arrow.BinaryTypes.String},
+		{Name: "reactions", Type: arrow.ListOf(reactionType)},
+		{Name: "clean_text", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "urls", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "blocks", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "raw_json", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "channel_name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "edited_at", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "iso_week", Type: arrow.BinaryTypes.String},
+		{Name: "weekday", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "hour_of_day", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "is_business_hours", Type: arrow.FixedWidthTypes.Boolean},
+	}, nil)
+}
+
+// reactionType is one element of the messages reactions column
+var reactionType = arrow.StructOf(
+	arrow.Field{Name: "name", Type: arrow.BinaryTypes.String},
+	arrow.Field{Name: "count", Type: arrow.PrimitiveTypes.Int64},
+	arrow.Field{Name: "users", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+)
+
+// createUserSchema creates Arrow schema for the global users file
+func createUserSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "user_id", Type: arrow.BinaryTypes.String},
+		{Name: "user_name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "user_real_name", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "user_email", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "is_bot", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "cached_at", Type: arrow.BinaryTypes.String},
+		{Name: "is_deleted", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "updated_at", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "title", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "tz", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "tz_offset", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "image_192", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "is_external", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "team_id", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, nil)
+}
+
+// createUserStatsSchema creates Arrow schema for the per-user activity file
+func createUserStatsSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "user_id", Type: arrow.BinaryTypes.String},
+		{Name: "messages_sent", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "reactions_given", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "threads_started", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "cached_at", Type: arrow.BinaryTypes.String},
+	}, nil)
+}
+
+// createChannelSchema creates Arrow schema for the channel metadata file
+func createChannelSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "channel_id", Type: arrow.BinaryTypes.String},
+		{Name: "name", Type: arrow.BinaryTypes.String},
+		{Name: "topic", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "purpose", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "num_members", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "is_private", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "is_archived", Type: arrow.FixedWidthTypes.Boolean},
+		{Name: "created", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "cached_at", Type: arrow.BinaryTypes.String},
+	}, nil)
+}
+
+// createEmojiSchema creates Arrow schema for the custom emoji file
+func createEmojiSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "name", Type: arrow.BinaryTypes.String},
+		{Name: "url", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "alias_for", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "cached_at", Type: arrow.BinaryTypes.String},
+	}, nil)
+}
+
+// createJiraIndexSchema creates Arrow schema for the JIRA ticket index
+func createJiraIndexSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "ticket_id", Type: arrow.BinaryTypes.String},
+		{Name: "mention_count", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "channels", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "message_ids", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "user_ids", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "first_seen", Type: arrow.BinaryTypes.String},
+		{Name: "last_seen", Type: arrow.BinaryTypes.String},
+		{Name: "cached_at", Type: arrow.BinaryTypes.String},
+	}, nil)
+}
+
+// createAlertSchema creates Arrow schema for the keyword alert log
+func createAlertSchema() *arrow.Schema {
+	return arrow.NewSchema([]arrow.Field{
+		{Name: "channel_id", Type: arrow.BinaryTypes.String},
+		{Name: "channel", Type: arrow.BinaryTypes.String},
+		{Name: "message_id", Type: arrow.BinaryTypes.String},
+		{Name: "user_id", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "text", Type: arrow.BinaryTypes.String},
+		{Name: "permalink", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "keywords", Type: arrow.ListOf(arrow.BinaryTypes.String)},
+		{Name: "timestamp", Type: arrow.BinaryTypes.String},
+		{Name: "matched_at", Type: arrow.BinaryTypes.String},
+		{Name: "notified_at", Type: arrow.BinaryTypes.String, Nullable: true},
 	}, nil)
 }
 
-// SaveMessages writes messages to a partitioned Parquet file
-func (pc *ParquetCache) SaveMessages(messages []*models.SlackMessage, channel *models.SlackChannel, date string) (string, error) {
+// SaveMessages writes messages to a partitioned Parquet file.
+// partition is the partition key ({date} in the name template), formatted
+// by Granularity.PartitionKey. Rows already stored in the partition are
+// merged as MergeMessages does, so a run whose window covers only part of
+// a partition keeps the messages earlier runs cached there, including
+// messages since deleted in Slack unless the cache is Refetched; a
+// partition written in an older schema is replaced instead.
+func (pc *ParquetCache) SaveMessages(messages []*models.SlackMessage, channel *models.SlackChannel, partition string) (string, error) {
+	return pc.mergeMessages(context.Background(), messages, channel, partition, true)
+}
+
+// MergeMessages writes messages into a partition, keeping the rows already
+// stored there whose message_id is not among messages. When a message is
+// in both, the dedup strategy (SetDedupStrategy) picks the copy kept. It
+// adds a single thread without refetching the partition, and refuses
+// partitions written in an older schema.
+func (pc *ParquetCache) MergeMessages(ctx context.Context, messages []*models.SlackMessage, channel *models.SlackChannel, partition string) (string, error) {
+	return pc.mergeMessages(ctx, messages, channel, partition, false)
+}
+
+// mergeMessages merges messages into a partition; replaceOlder replaces a
+// partition in an older schema instead of failing
+func (pc *ParquetCache) mergeMessages(ctx context.Context, messages []*models.SlackMessage, channel *models.SlackChannel, partition string, replaceOlder bool) (string, error) {
 	if len(messages) == 0 {
 		return "", fmt.Errorf("no messages to save")
 	}
 
-	// Create partition directory
-	partitionDir := filepath.Join(pc.basePath, "messages", fmt.Sprintf("dt=%s", date), fmt.Sprintf("channel=%s", channel.Name))
-	if err := os.MkdirAll(partitionDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create partition directory: %w", err)
+	filePath, err := pc.partitionPath(channel, partition)
+	if err != nil {
+		return "", err
+	}
+
+	existing, err := pc.readTable(ctx, filePath)
+	if err != nil && !storage.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read %s: %w", filePath, err)
+	}
+	replacing := false
+	if existing != nil {
+		defer existing.Release()
+		if err := compareSchema(pc.schema, existing.Schema()); err != nil {
+			if !replaceOlder {
+				return "", fmt.Errorf("cannot merge into %s written by an older version (%v); re-cache the partition first", filePath, err)
+			}
+			pc.logger.Debug("replacing partition in an older schema", "path", filePath, "reason", err)
+			replacing = true
+		}
+	}
+
+	// Drop incoming messages whose stored copy the strategy keeps
+	var stored map[string]storedVersion
+	if !replacing {
+		stored = storedVersions(existing)
+	}
+	replaced := make(map[string]bool, len(messages))
+	incoming := make([]*models.SlackMessage, 0, len(messages))
+	for _, msg := range messages {
+		if v, ok := stored[msg.MessageID]; ok && pc.dedup.keepStored(v, msg) {
+			continue
+		}
+		replaced[msg.MessageID] = true
+		incoming = append(incoming, msg)
+	}
+	deleted := pc.refetched.deleted(stored, messages)
+	for _, id := range deleted {
+		replaced[id] = true
+	}
+	if len(incoming) == 0 && len(deleted) == 0 {
+		pc.logger.Debug("partition already up to date", "path", filePath, "rows", len(messages), "dedup", pc.dedup)
+		return filePath, nil
+	}
+
+	// Rows kept from a thin partition leave the merged file thin, and rows
+	// kept from a file with other activity hours get their derived
+	// columns recomputed
+	metadata := pc.metadata
+	rederive := false
+	if keepsRows(stored, replaced) {
+		storedMeta, err := pc.ReadFileMetadata(filePath)
+		if err != nil {
+			return "", err
+		}
+		metadata = carryThin(metadata, storedMeta)
+		rederive = storedMeta["activity_hours"] != pc.activity.String()
+	}
+
+	mw, err := pc.newMessageWriter(filePath, metadata)
+	if err != nil {
+		return "", err
+	}
+	defer mw.Abort()
+	mw.channelName, mw.rawColumn = channel.Name, pc.raw == RawColumn
+	if err := mw.Write(incoming); err != nil {
+		return "", err
+	}
+
+	kept := 0
+	if existing != nil && !replacing {
+		// Copy runs of kept rows as slices, re-labelled with our schema
+		tr := array.NewTableReader(existing, 0)
+		defer tr.Release()
+		for tr.Next() {
+			rec := tr.Record()
+			ids := rec.Column(0).(*array.String)
+			start := -1
+			for i := 0; i <= int(rec.NumRows()); i++ {
+				keep := i < int(rec.NumRows()) && !replaced[ids.Value(i)]
+				if keep && start < 0 {
+					start = i
+				}
+				if !keep && start >= 0 {
+					slice := rec.NewSlice(int64(start), int64(i))
+					var record arrow.Record = array.NewRecord(pc.schema, slice.Columns(), slice.NumRows())
+					slice.Release()
+					if rederive {
+						rederived, err := pc.rederiveActivity(record)
+						record.Release()
+						if err != nil {
+							return "", fmt.Errorf("failed to merge %s: %w", filePath, err)
+						}
+						record = rederived
+					}
+					err := mw.WriteRecord(record)
+					record.Release()
+					if err != nil {
+						return "", err
+					}
+					kept += i - start
+					start = -1
+				}
+			}
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return "", err
+	}
+	if pc.raw == RawSidecar {
+		var err error
+		if replacing {
+			err = pc.writeSidecar(filePath, rawPayloads(incoming))
+		} else {
+			err = pc.mergeSidecar(filePath, incoming, deleted)
+		}
+		if err != nil {
+			return "", err
+		}
 	}
 
-	filePath := filepath.Join(partitionDir, "data.parquet")
+	pc.logger.Debug("wrote partition", "path", filePath, "rows", len(incoming), "kept", kept, "deleted", len(deleted))
+
+	return filePath, nil
+}
 
+// rederiveActivity returns rec, a record in pc.schema, with its iso_week,
+// weekday, hour_of_day and is_business_hours columns derived again from
+// its timestamps with the cache's activity hours
+func (pc *ParquetCache) rederiveActivity(rec arrow.Record) (arrow.Record, error) {
+	mem := memory.NewGoAllocator()
+	weeks, weekdays := array.NewStringBuilder(mem), array.NewInt64Builder(mem)
+	hours, business := array.NewInt64Builder(mem), array.NewBooleanBuilder(mem)
+	defer weeks.Release()
+	defer weekdays.Release()
+	defer hours.Release()
+	defer business.Release()
+
+	timestamps := rec.Column(3).(*array.String)
+	for i := 0; i < timestamps.Len(); i++ {
+		ts, err := time.Parse(time.RFC3339, timestamps.Value(i))
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp %q: %w", timestamps.Value(i), err)
+		}
+		a := pc.activity.derive(ts)
+		weeks.Append(a.isoWeek)
+		weekdays.Append(a.weekday)
+		hours.Append(a.hour)
+		business.Append(a.business)
+	}
+
+	cols := make([]arrow.Array, rec.NumCols())
+	copy(cols, rec.Columns())
+	derived := []arrow.Array{weeks.NewArray(), weekdays.NewArray(), hours.NewArray(), business.NewArray()}
+	for i, col := range derived {
+		defer col.Release()
+		cols[26+i] = col
+	}
+	return array.NewRecord(pc.schema, cols, rec.NumRows()), nil
+}
+
+// partitionPath returns the Parquet data file of a channel's partition,
+// named by the cache's name template
+func (pc *ParquetCache) partitionPath(channel *models.SlackChannel, partition string) (string, error) {
+	return pc.partitionFile(channel, partition, FormatParquet)
+}
+
+// partitionFile returns the data file of a channel's partition in format
+func (pc *ParquetCache) partitionFile(channel *models.SlackChannel, partition string, format Format) (string, error) {
+	dir, err := pc.template.Render(partition, channel)
+	if err != nil {
+		return "", err
+	}
+	name := format.FileName()
+	if format == FormatParquet {
+		name = pc.partFile
+	}
+	return filepath.Join(pc.basePath, "messages", filepath.FromSlash(dir), name), nil
+}
+
+// StoredFlags are the is_pinned, has_files, has_reactions and
+// channel_name values of a stored row, which ReadMessages cannot always
+// turn back into messages
+type StoredFlags struct {
+	Pinned, Files, Reactions bool
+	ChannelName              string
+}
+
+// With returns the flags a rewrite of msg over a row with f stores: a flag
+// set in f stays set
+func (f StoredFlags) With(msg *models.SlackMessage) StoredFlags {
+	f.Pinned = f.Pinned || msg.IsPinned()
+	f.Files = f.Files || len(msg.Files) > 0
+	f.Reactions = f.Reactions || len(msg.Reactions) > 0
+	return f
+}
+
+// messageRecord builds the Arrow record for messages of the channel named
+// channelName in pc.schema; raw_json holds their payloads only when
+// rawColumn is set. Flags found in stored are kept set for the message
+// with that message_id, and its channel_name when channelName is empty.
+func (pc *ParquetCache) messageRecord(messages []*models.SlackMessage, channelName string, rawColumn bool, stored map[string]StoredFlags) arrow.Record {
 	// Build Arrow record
 	mem := memory.NewGoAllocator()
 	builder := array.NewRecordBuilder(mem, pc.schema)
 	defer builder.Release()
 
+	// Rows without a fetch time are stamped with the write time
+	writtenAt := time.Now().UTC().Format(time.RFC3339)
+
 	// Populate columns
 	for _, msg := range messages {
 		builder.Field(0).(*array.StringBuilder).Append(msg.MessageID)
@@ -79,7 +794,7 @@ func (pc *ParquetCache) SaveMessages(messages []*models.SlackMessage, channel *m
 			builder.Field(1).(*array.StringBuilder).AppendNull()
 		}
 		builder.Field(2).(*array.StringBuilder).Append(msg.Text)
-		builder.Field(3).(*array.StringBuilder).Append(msg.Timestamp.Format(time.RFC3339))
+		builder.Field(3).(*array.StringBuilder).Append(msg.Timestamp.UTC().Format(time.RFC3339))
 
 		if msg.ThreadTS != "" {
 			builder.Field(4).(*array.StringBuilder).Append(msg.ThreadTS)
@@ -117,62 +832,104 @@ func (pc *ParquetCache) SaveMessages(messages []*models.SlackMessage, channel *m
 		}
 
 		// Boolean flags
-		builder.Field(13).(*array.BooleanBuilder).Append(len(msg.Reactions) > 0)
-		builder.Field(14).(*array.BooleanBuilder).Append(len(msg.Files) > 0)
+		flags := stored[msg.MessageID].With(msg)
+		builder.Field(13).(*array.BooleanBuilder).Append(flags.Reactions)
+		builder.Field(14).(*array.BooleanBuilder).Append(flags.Files)
 		builder.Field(15).(*array.BooleanBuilder).Append(false) // has_thread (for future)
-	}
+		builder.Field(16).(*array.BooleanBuilder).Append(flags.Pinned)
+		if msg.Permalink != "" {
+			builder.Field(17).(*array.StringBuilder).Append(msg.Permalink)
+		} else {
+			builder.Field(17).(*array.StringBuilder).AppendNull()
+		}
+		if !msg.FetchedAt.IsZero() {
+			builder.Field(18).(*array.StringBuilder).Append(msg.FetchedAt.UTC().Format(time.RFC3339))
+		} else {
+			builder.Field(18).(*array.StringBuilder).Append(writtenAt)
+		}
 
-	record := builder.NewRecord()
-	defer record.Release()
+		// Reactions (list of name, count, users)
+		reactionsBuilder := builder.Field(19).(*array.ListBuilder)
+		reactionsBuilder.Append(true)
+		reactionBuilder := reactionsBuilder.ValueBuilder().(*array.StructBuilder)
+		for _, r := range msg.Reactions {
+			reactionBuilder.Append(true)
+			reactionBuilder.FieldBuilder(0).(*array.StringBuilder).Append(r.Emoji)
+			reactionBuilder.FieldBuilder(1).(*array.Int64Builder).Append(int64(r.Count))
+			usersBuilder := reactionBuilder.FieldBuilder(2).(*array.ListBuilder)
+			usersBuilder.Append(true)
+			for _, u := range r.Users {
+				usersBuilder.ValueBuilder().(*array.StringBuilder).Append(u)
+			}
+		}
 
-	// Write to Parquet with Snappy compression
-	file, err := os.Create(filePath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create file: %w", err)
-	}
-	defer file.Close()
+		// Normalized text, only with --normalize-text
+		if msg.CleanText != "" {
+			builder.Field(20).(*array.StringBuilder).Append(msg.CleanText)
+		} else {
+			builder.Field(20).(*array.StringBuilder).AppendNull()
+		}
 
-	props := parquet.NewWriterProperties(
-		parquet.WithCompression(compress.Codecs.Snappy),
-	)
+		// Shared links (list)
+		urlsBuilder := builder.Field(21).(*array.ListBuilder)
+		urlsBuilder.Append(true)
+		for _, u := range msg.URLs {
+			urlsBuilder.ValueBuilder().(*array.StringBuilder).Append(u)
+		}
 
-	writer, err := pqarrow.NewFileWriter(pc.schema, file, props, pqarrow.DefaultWriterProps())
-	if err != nil {
-		return "", fmt.Errorf("failed to create parquet writer: %w", err)
-	}
-	defer writer.Close()
+		// Block Kit JSON, null for plain messages
+		if len(msg.Blocks) > 0 {
+			builder.Field(22).(*array.StringBuilder).Append(string(msg.Blocks))
+		} else {
+			builder.Field(22).(*array.StringBuilder).AppendNull()
+		}
+
+		// Original API payload, only with --raw=column
+		if rawColumn && len(msg.Raw) > 0 {
+			builder.Field(23).(*array.StringBuilder).Append(string(msg.Raw))
+		} else {
+			builder.Field(23).(*array.StringBuilder).AppendNull()
+		}
 
-	if err := writer.Write(record); err != nil {
-		return "", fmt.Errorf("failed to write record: %w", err)
+		// Channel name as Slack has it, before sanitizing for the path
+		if channelName != "" {
+			builder.Field(24).(*array.StringBuilder).Append(channelName)
+		} else {
+			appendOptionalString(builder.Field(24).(*array.StringBuilder), flags.ChannelName)
+		}
+
+		// Last edit, null for messages never edited
+		if !msg.EditedAt.IsZero() {
+			builder.Field(25).(*array.StringBuilder).Append(msg.EditedAt.UTC().Format(time.RFC3339))
+		} else {
+			builder.Field(25).(*array.StringBuilder).AppendNull()
+		}
+
+		// Derived from timestamp in the activity time zone
+		a := pc.activity.derive(msg.Timestamp)
+		builder.Field(26).(*array.StringBuilder).Append(a.isoWeek)
+		builder.Field(27).(*array.Int64Builder).Append(a.weekday)
+		builder.Field(28).(*array.Int64Builder).Append(a.hour)
+		builder.Field(29).(*array.BooleanBuilder).Append(a.business)
 	}
 
-	return filePath, nil
+	return builder.NewRecord()
 }
 
-// SaveUsers writes user cache to a global Parquet file
+// SaveUsers writes user cache to a global Parquet file, one row per user
+// ID in ID order, so the same users always give the same file. Users
+// loaded by LoadUsers keep their original cached_at; new ones are stamped
+// now.
 func (pc *ParquetCache) SaveUsers(users map[string]*models.SlackUser) (string, error) {
-	if len(users) == 0 {
+	rows := uniqueUsers(users)
+	if len(rows) == 0 {
 		return "", nil
 	}
 
-	// Users file at cache/users.parquet
-	usersDir := filepath.Dir(pc.basePath)
-	usersPath := filepath.Join(usersDir, "users.parquet")
-
-	// Ensure directory exists
-	if err := os.MkdirAll(usersDir, 0755); err != nil {
-		return "", fmt.Errorf("failed to create users directory: %w", err)
-	}
+	// Users file at cache/raw/users.parquet
+	usersPath := pc.UsersPath()
 
-	// Create schema for users
-	schema := arrow.NewSchema([]arrow.Field{
-		{Name: "user_id", Type: arrow.BinaryTypes.String},
-		{Name: "user_name", Type: arrow.BinaryTypes.String, Nullable: true},
-		{Name: "user_real_name", Type: arrow.BinaryTypes.String, Nullable: true},
-		{Name: "user_email", Type: arrow.BinaryTypes.String, Nullable: true},
-		{Name: "is_bot", Type: arrow.FixedWidthTypes.Boolean},
-		{Name: "cached_at", Type: arrow.BinaryTypes.String},
-	}, nil)
+	schema := createUserSchema()
 
 	mem := memory.NewGoAllocator()
 	builder := array.NewRecordBuilder(mem, schema)
@@ -180,7 +937,7 @@ func (pc *ParquetCache) SaveUsers(users map[string]*models.SlackUser) (string, e
 
 	cachedAt := time.Now().Format(time.RFC3339)
 
-	for _, user := range users {
+	for _, user := range rows {
 		builder.Field(0).(*array.StringBuilder).Append(user.ID)
 		if user.Name != "" {
 			builder.Field(1).(*array.StringBuilder).Append(user.Name)
@@ -198,32 +955,229 @@ func (pc *ParquetCache) SaveUsers(users map[string]*models.SlackUser) (string, e
 			builder.Field(3).(*array.StringBuilder).AppendNull()
 		}
 		builder.Field(4).(*array.BooleanBuilder).Append(user.IsBot)
-		builder.Field(5).(*array.StringBuilder).Append(cachedAt)
+		if !user.CachedAt.IsZero() {
+			builder.Field(5).(*array.StringBuilder).Append(user.CachedAt.Format(time.RFC3339))
+		} else {
+			builder.Field(5).(*array.StringBuilder).Append(cachedAt)
+		}
+		builder.Field(6).(*array.BooleanBuilder).Append(user.Deleted)
+		if !user.UpdatedAt.IsZero() {
+			builder.Field(7).(*array.StringBuilder).Append(user.UpdatedAt.Format(time.RFC3339))
+		} else {
+			builder.Field(7).(*array.StringBuilder).AppendNull()
+		}
+		appendOptionalString(builder.Field(8).(*array.StringBuilder), user.Title)
+		appendOptionalString(builder.Field(9).(*array.StringBuilder), user.TZ)
+		if user.TZ != "" {
+			builder.Field(10).(*array.Int64Builder).Append(int64(user.TZOffset))
+		} else {
+			builder.Field(10).(*array.Int64Builder).AppendNull()
+		}
+		appendOptionalString(builder.Field(11).(*array.StringBuilder), user.Image192)
+		builder.Field(12).(*array.BooleanBuilder).Append(user.IsExternal)
+		appendOptionalString(builder.Field(13).(*array.StringBuilder), user.TeamID)
 	}
 
 	record := builder.NewRecord()
 	defer record.Release()
 
-	// Write to file
-	file, err := os.Create(usersPath)
-	if err != nil {
-		return "", fmt.Errorf("failed to create users file: %w", err)
+	if err := pc.writeRecord(usersPath, schema, record); err != nil {
+		return "", err
 	}
-	defer file.Close()
 
-	props := parquet.NewWriterProperties(
-		parquet.WithCompression(compress.Codecs.Snappy),
-	)
+	pc.logger.Debug("wrote users", "path", usersPath, "rows", len(rows))
 
-	writer, err := pqarrow.NewFileWriter(schema, file, props, pqarrow.DefaultWriterProps())
-	if err != nil {
-		return "", fmt.Errorf("failed to create parquet writer: %w", err)
+	return usersPath, nil
+}
+
+// uniqueUsers returns users sorted by ID with one entry per ID; a user
+// under another map key than its ID (or two keys sharing one) keeps the
+// copy updated last, then cached last. Users without an ID take their key.
+func uniqueUsers(users map[string]*models.SlackUser) []*models.SlackUser {
+	byID := make(map[string]*models.SlackUser, len(users))
+	for key, user := range users {
+		if user == nil {
+			continue
+		}
+		if user.ID == "" {
+			u := *user
+			u.ID = key
+			user = &u
+		}
+		if prev, ok := byID[user.ID]; ok && !newerUser(user, prev) {
+			continue
+		}
+		byID[user.ID] = user
 	}
-	defer writer.Close()
 
-	if err := writer.Write(record); err != nil {
-		return "", fmt.Errorf("failed to write record: %w", err)
+	rows := make([]*models.SlackUser, 0, len(byID))
+	for _, user := range byID {
+		rows = append(rows, user)
 	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows
+}
 
-	return usersPath, nil
+// newerUser reports whether a should replace b as the copy of a user
+func newerUser(a, b *models.SlackUser) bool {
+	if !a.UpdatedAt.Equal(b.UpdatedAt) {
+		return a.UpdatedAt.After(b.UpdatedAt)
+	}
+	if !a.CachedAt.Equal(b.CachedAt) {
+		// A user fetched this run has no cached_at yet and is newest
+		return a.CachedAt.IsZero() || !b.CachedAt.IsZero() && a.CachedAt.After(b.CachedAt)
+	}
+	// Identical timestamps: a fixed choice keeps the output stable
+	return a.Name > b.Name
+}
+
+// appendOptionalString appends s, or null when it is empty
+func appendOptionalString(b *array.StringBuilder, s string) {
+	if s != "" {
+		b.Append(s)
+	} else {
+		b.AppendNull()
+	}
+}
+
+// SaveUserStats writes per-user activity counts for the current run to
+// cache/raw/user_stats.parquet, sorted by user ID
+func (pc *ParquetCache) SaveUserStats(stats map[string]*models.UserStats) (string, error) {
+	if len(stats) == 0 {
+		return "", nil
+	}
+
+	statsPath := pc.UserStatsPath()
+
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	schema := createUserStatsSchema()
+
+	mem := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	cachedAt := time.Now().Format(time.RFC3339)
+
+	for _, id := range ids {
+		s := stats[id]
+		builder.Field(0).(*array.StringBuilder).Append(s.UserID)
+		builder.Field(1).(*array.Int64Builder).Append(int64(s.MessagesSent))
+		builder.Field(2).(*array.Int64Builder).Append(int64(s.ReactionsGiven))
+		builder.Field(3).(*array.Int64Builder).Append(int64(s.ThreadsStarted))
+		builder.Field(4).(*array.StringBuilder).Append(cachedAt)
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	if err := pc.writeRecord(statsPath, schema, record); err != nil {
+		return "", err
+	}
+
+	pc.logger.Debug("wrote user stats", "path", statsPath, "rows", len(stats))
+
+	return statsPath, nil
+}
+
+// SaveChannels writes channel metadata to cache/raw/channels.parquet, sorted
+// by channel ID. Callers merge with LoadChannels first since the file is
+// rewritten whole.
+func (pc *ParquetCache) SaveChannels(channels map[string]*models.SlackChannel) (string, error) {
+	if len(channels) == 0 {
+		return "", nil
+	}
+
+	channelsPath := pc.ChannelsPath()
+
+	ids := make([]string, 0, len(channels))
+	for id := range channels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	schema := createChannelSchema()
+
+	mem := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	cachedAt := time.Now().Format(time.RFC3339)
+
+	for _, id := range ids {
+		ch := channels[id]
+		builder.Field(0).(*array.StringBuilder).Append(ch.ID)
+		builder.Field(1).(*array.StringBuilder).Append(ch.Name)
+		appendOptionalString(builder.Field(2).(*array.StringBuilder), ch.Topic)
+		appendOptionalString(builder.Field(3).(*array.StringBuilder), ch.Purpose)
+		builder.Field(4).(*array.Int64Builder).Append(int64(ch.NumMembers))
+		builder.Field(5).(*array.BooleanBuilder).Append(ch.IsPrivate)
+		builder.Field(6).(*array.BooleanBuilder).Append(ch.IsArchived)
+		if !ch.Created.IsZero() {
+			builder.Field(7).(*array.StringBuilder).Append(ch.Created.UTC().Format(time.RFC3339))
+		} else {
+			builder.Field(7).(*array.StringBuilder).AppendNull()
+		}
+		if !ch.CachedAt.IsZero() {
+			builder.Field(8).(*array.StringBuilder).Append(ch.CachedAt.Format(time.RFC3339))
+		} else {
+			builder.Field(8).(*array.StringBuilder).Append(cachedAt)
+		}
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	if err := pc.writeRecord(channelsPath, schema, record); err != nil {
+		return "", err
+	}
+
+	pc.logger.Debug("wrote channels", "path", channelsPath, "rows", len(channels))
+
+	return channelsPath, nil
+}
+
+// SaveEmoji writes the workspace's custom emoji to cache/raw/emoji.parquet,
+// sorted by name, replacing the previous list
+func (pc *ParquetCache) SaveEmoji(emoji []*models.SlackEmoji) (string, error) {
+	if len(emoji) == 0 {
+		return "", nil
+	}
+
+	emojiPath := pc.EmojiPath()
+
+	sorted := append([]*models.SlackEmoji(nil), emoji...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Name < sorted[j].Name
+	})
+
+	schema := createEmojiSchema()
+
+	mem := memory.NewGoAllocator()
+	builder := array.NewRecordBuilder(mem, schema)
+	defer builder.Release()
+
+	cachedAt := time.Now().Format(time.RFC3339)
+
+	for _, e := range sorted {
+		builder.Field(0).(*array.StringBuilder).Append(e.Name)
+		appendOptionalString(builder.Field(1).(*array.StringBuilder), e.URL)
+		appendOptionalString(builder.Field(2).(*array.StringBuilder), e.AliasFor)
+		builder.Field(3).(*array.StringBuilder).Append(cachedAt)
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	if err := pc.writeRecord(emojiPath, schema, record); err != nil {
+		return "", err
+	}
+
+	pc.logger.Debug("wrote emoji", "path", emojiPath, "rows", len(sorted))
+
+	return emojiPath, nil
 }
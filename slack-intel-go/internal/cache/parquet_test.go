@@ -0,0 +1,643 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestRunMetadataWrittenToFiles(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetMetadata("token_type", "user")
+
+	path, err := pc.SaveMessages([]*models.SlackMessage{
+		{MessageID: "1700000000.000100", Text: "hi", Timestamp: time.Unix(1700000000, 0)},
+	}, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if meta["token_type"] != "user" {
+		t.Errorf("token_type = %q, want user", meta["token_type"])
+	}
+	if meta["schema_version"] != SchemaVersion {
+		t.Errorf("schema_version = %q, want %s", meta["schema_version"], SchemaVersion)
+	}
+
+	// A copy flags its own files only
+	flagged, err := pc.WithMetadata("truncated", "max-messages-per-channel").SaveMessages([]*models.SlackMessage{
+		{MessageID: "1700000000.000100", Text: "hi", Timestamp: time.Unix(1700000000, 0)},
+	}, &models.SlackChannel{Name: "random", ID: "C2"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if meta, _ := pc.ReadFileMetadata(flagged); meta["truncated"] != "max-messages-per-channel" || meta["token_type"] != "user" {
+		t.Errorf("flagged file metadata = %v, want truncated and the run metadata", meta)
+	}
+	if _, ok := pc.metadata["truncated"]; ok {
+		t.Error("WithMetadata changed the original cache")
+	}
+}
+
+func TestWriterOptionsSplitRowGroups(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetWriterOptions(WriterOptions{RowGroupRows: 100, PageSize: 4096})
+
+	start := time.Date(2023, 11, 14, 0, 0, 0, 0, time.UTC)
+	var messages []*models.SlackMessage
+	for i := 0; i < 250; i++ {
+		at := start.Add(time.Duration(i) * time.Minute)
+		messages = append(messages, &models.SlackMessage{
+			MessageID: fmt.Sprintf("%d.000100", at.Unix()), Text: "see PROJ-1", Timestamp: at,
+			JiraTickets: []string{"PROJ-1"}, Reactions: []models.SlackReaction{{Emoji: "eyes", Count: 1, Users: []string{"U1"}}},
+		})
+	}
+	path, err := pc.SaveMessages(messages, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		t.Fatalf("openParquet: %v", err)
+	}
+	groups := rdr.NumRowGroups()
+	rdr.Close()
+	if groups != 3 {
+		t.Errorf("row groups = %d, want 3 for 250 rows at 100 per group", groups)
+	}
+
+	// Later groups can be pruned by their timestamp statistics
+	msgs, err := pc.ReadMessagesInRange(ctx, path, start.Add(220*time.Minute), time.Time{})
+	if err != nil {
+		t.Fatalf("ReadMessagesInRange: %v", err)
+	}
+	if len(msgs) != 50 || len(msgs[49].Reactions) != 1 || msgs[49].JiraTickets[0] != "PROJ-1" {
+		t.Errorf("read %d message(s) from the last group, want its 50 intact", len(msgs))
+	}
+
+	// A command rewriting the file without the options keeps its layout
+	rewriter := NewParquetCache(pc.basePath)
+	if err := rewriter.RewriteMessages(ctx, path, messages); err != nil {
+		t.Fatalf("RewriteMessages: %v", err)
+	}
+	if rdr, err = rewriter.openParquet(path); err != nil {
+		t.Fatalf("openParquet: %v", err)
+	}
+	groups = rdr.NumRowGroups()
+	rdr.Close()
+	if groups != 3 {
+		t.Errorf("row groups after rewrite = %d, want the stored 100 per group: 3", groups)
+	}
+}
+
+func TestSaveMessagesStreamsBatchesIntoRowGroups(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetWriterOptions(WriterOptions{RowGroupRows: 12_000})
+
+	// More rows than one write batch, with row groups spanning batches
+	start := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	messages := make([]*models.SlackMessage, 2*writeBatchRows+5_000)
+	for i := range messages {
+		at := start.Add(time.Duration(i) * time.Minute)
+		messages[i] = &models.SlackMessage{MessageID: fmt.Sprintf("%d.%06d", at.Unix(), i), Text: "hi", Timestamp: at}
+	}
+	path, err := pc.SaveMessages(messages, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		t.Fatalf("openParquet: %v", err)
+	}
+	var sizes []int64
+	for i := 0; i < rdr.NumRowGroups(); i++ {
+		sizes = append(sizes, rdr.MetaData().RowGroup(i).NumRows())
+	}
+	rdr.Close()
+	if fmt.Sprint(sizes) != "[12000 12000 1000]" {
+		t.Errorf("row group sizes = %v, want [12000 12000 1000]", sizes)
+	}
+
+	got, err := pc.ReadMessages(context.Background(), path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if len(got) != len(messages) || got[len(got)-1].MessageID != messages[len(messages)-1].MessageID {
+		t.Errorf("read %d message(s), want all %d in order", len(got), len(messages))
+	}
+}
+
+func TestFetchedAtRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+
+	fetched := time.Date(2023, 11, 14, 22, 30, 0, 0, time.UTC)
+	before := time.Now().UTC().Truncate(time.Second)
+	path, err := pc.SaveMessages([]*models.SlackMessage{
+		{MessageID: "1700000000.000100", Text: "fetched", Timestamp: time.Unix(1700000000, 0), FetchedAt: fetched},
+		{MessageID: "1700000001.000100", Text: "unstamped", Timestamp: time.Unix(1700000001, 0)},
+	}, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	table, err := pc.readTable(ctx, path)
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	defer table.Release()
+
+	versions := storedVersions(table)
+	if got := versions["1700000000.000100"].fetched; !got.Equal(fetched) {
+		t.Errorf("fetched_at = %v, want %v", got, fetched)
+	}
+	if got := versions["1700000001.000100"].fetched; got.Before(before) {
+		t.Errorf("fetched_at = %v, want the write time (>= %v)", got, before)
+	}
+}
+
+func TestSaveUserStats(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+
+	path, err := pc.SaveUserStats(map[string]*models.UserStats{
+		"U2": {UserID: "U2", MessagesSent: 1},
+		"U1": {UserID: "U1", MessagesSent: 3, ReactionsGiven: 2, ThreadsStarted: 1},
+	})
+	if err != nil {
+		t.Fatalf("SaveUserStats: %v", err)
+	}
+	if path != pc.UserStatsPath() {
+		t.Errorf("path = %s, want %s", path, pc.UserStatsPath())
+	}
+
+	report := VerifyFile(context.Background(), path, createUserStatsSchema())
+	if !report.OK() || report.Rows != 2 {
+		t.Errorf("report = %+v, want OK with 2 rows", report)
+	}
+}
+
+func TestLoadUsersRoundTrip(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	cachedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	_, err := pc.SaveUsers(map[string]*models.SlackUser{
+		"U1": {ID: "U1", Name: "alice", RealName: "Alice A", Email: "alice@example.com", CachedAt: cachedAt,
+			Title: "SRE", TZ: "Europe/Warsaw", TZOffset: 3600, Image192: "https://avatars.example.com/u1_192.png"},
+		"U2": {ID: "U2", IsBot: true, Deleted: true, UpdatedAt: cachedAt.Add(-time.Hour)},
+		"W3": {ID: "W3", RealName: "Partner P", TeamID: "T0PARTNER", IsExternal: true},
+	})
+	if err != nil {
+		t.Fatalf("SaveUsers: %v", err)
+	}
+
+	users, err := pc.LoadUsers(context.Background())
+	if err != nil {
+		t.Fatalf("LoadUsers: %v", err)
+	}
+	if len(users) != 3 {
+		t.Fatalf("got %d users, want 3", len(users))
+	}
+	if ext := users["W3"]; !ext.IsExternal || ext.TeamID != "T0PARTNER" || ext.RealName != "Partner P" {
+		t.Errorf("W3 = %+v, want an external user of T0PARTNER", ext)
+	}
+	if users["U1"].IsExternal || users["U1"].TeamID != "" {
+		t.Errorf("U1 external/team = %v/%q, want false and null", users["U1"].IsExternal, users["U1"].TeamID)
+	}
+
+	alice := users["U1"]
+	if alice.Name != "alice" || alice.RealName != "Alice A" || alice.Email != "alice@example.com" || alice.IsBot {
+		t.Errorf("U1 = %+v", alice)
+	}
+	if alice.Title != "SRE" || alice.TZ != "Europe/Warsaw" || alice.TZOffset != 3600 || alice.Image192 == "" {
+		t.Errorf("U1 profile = %+v", alice)
+	}
+	if !alice.CachedAt.Equal(cachedAt) {
+		t.Errorf("U1 cached_at = %v, want %v", alice.CachedAt, cachedAt)
+	}
+
+	bot := users["U2"]
+	if bot.Name != "" || bot.RealName != "" || bot.Email != "" || bot.TZ != "" || bot.TZOffset != 0 || !bot.IsBot {
+		t.Errorf("U2 = %+v, want nulls as empty strings", bot)
+	}
+	if !bot.Deleted || !bot.UpdatedAt.Equal(cachedAt.Add(-time.Hour)) {
+		t.Errorf("U2 deleted/updated = %v/%v", bot.Deleted, bot.UpdatedAt)
+	}
+	if !alice.UpdatedAt.IsZero() {
+		t.Errorf("U1 updated = %v, want zero from null", alice.UpdatedAt)
+	}
+	if bot.CachedAt.IsZero() {
+		t.Error("U2 cached_at should be stamped on save")
+	}
+}
+
+func TestSaveUsersDeterministic(t *testing.T) {
+	cachedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	users := []*models.SlackUser{
+		{ID: "U3", Name: "carol", CachedAt: cachedAt},
+		{ID: "U1", Name: "alice", CachedAt: cachedAt},
+		{ID: "U2", Name: "bob", CachedAt: cachedAt, UpdatedAt: cachedAt},
+	}
+	// The same user under a stale key must not add a second U2 row
+	stale := &models.SlackUser{ID: "U2", Name: "bob-old", CachedAt: cachedAt, UpdatedAt: cachedAt.Add(-time.Hour)}
+
+	save := func(order []int) ([]byte, *ParquetCache) {
+		pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+		byKey := make(map[string]*models.SlackUser)
+		for _, i := range order {
+			byKey[users[i].ID] = users[i]
+		}
+		byKey["bob"] = stale
+		path, err := pc.SaveUsers(byKey)
+		if err != nil {
+			t.Fatalf("SaveUsers: %v", err)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data, pc
+	}
+
+	first, pc := save([]int{0, 1, 2})
+	for run := 0; run < 5; run++ {
+		if again, _ := save([]int{2, 1, 0}); !bytes.Equal(first, again) {
+			t.Fatalf("run %d wrote a different users file", run)
+		}
+	}
+
+	loaded, err := pc.LoadUsers(context.Background())
+	if err != nil {
+		t.Fatalf("LoadUsers: %v", err)
+	}
+	if len(loaded) != 3 || loaded["U2"].Name != "bob" {
+		t.Errorf("users = %v, want U1..U3 with the newer U2", loaded)
+	}
+}
+
+func TestLoadUsersMissingFile(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+
+	users, err := pc.LoadUsers(context.Background())
+	if err != nil || len(users) != 0 {
+		t.Errorf("LoadUsers = %v, %v, want empty map", users, err)
+	}
+}
+
+func TestLegacySideFiles(t *testing.T) {
+	dir := t.TempDir()
+	pc := NewParquetCache(filepath.Join(dir, "raw") + string(filepath.Separator))
+	if want := filepath.Join(dir, "raw", "users.parquet"); pc.UsersPath() != want {
+		t.Errorf("UsersPath = %s, want %s", pc.UsersPath(), want)
+	}
+
+	// An older release kept users.parquet beside the cache directory
+	legacy := NewParquetCache(dir)
+	if _, err := legacy.SaveUsers(map[string]*models.SlackUser{"U1": {ID: "U1", Name: "alice"}}); err != nil {
+		t.Fatalf("SaveUsers: %v", err)
+	}
+	if got := pc.LegacyPath(pc.UsersPath()); got != legacy.UsersPath() {
+		t.Fatalf("LegacyPath = %s, want %s", got, legacy.UsersPath())
+	}
+
+	users, err := pc.LoadUsers(context.Background())
+	if err != nil || users["U1"] == nil {
+		t.Fatalf("LoadUsers from the legacy path = %v, %v", users, err)
+	}
+	if files, err := pc.LegacyFiles(); err != nil || len(files) != 1 || files[0] != pc.UsersPath() {
+		t.Errorf("LegacyFiles = %v, %v, want [%s]", files, err, pc.UsersPath())
+	}
+
+	moved, err := pc.MoveLegacyFiles()
+	if err != nil || len(moved) != 1 {
+		t.Fatalf("MoveLegacyFiles = %v, %v, want users.parquet", moved, err)
+	}
+	if _, err := os.Stat(legacy.UsersPath()); !os.IsNotExist(err) {
+		t.Errorf("legacy users.parquet still present: %v", err)
+	}
+	if users, err := pc.LoadUsers(context.Background()); err != nil || users["U1"] == nil {
+		t.Errorf("LoadUsers after the move = %v, %v", users, err)
+	}
+	if moved, err := pc.MoveLegacyFiles(); err != nil || len(moved) != 0 {
+		t.Errorf("second MoveLegacyFiles = %v, %v, want nothing", moved, err)
+	}
+
+	// A relative cache directory has its legacy files in the working directory
+	if got := NewParquetCache("raw").LegacyPath("raw/users.parquet"); got != "users.parquet" {
+		t.Errorf("relative LegacyPath = %s, want users.parquet", got)
+	}
+}
+
+func TestLoadChannelsRoundTrip(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+
+	channels := map[string]*models.SlackChannel{
+		"C1": {
+			ID: "C1", Name: "general", Topic: "Company-wide", Purpose: "Announcements",
+			NumMembers: 120, Created: time.Unix(1600000000, 0).UTC(),
+		},
+		"C2": {ID: "C2", Name: "secret", IsPrivate: true, IsArchived: true},
+	}
+	if _, err := pc.SaveChannels(channels); err != nil {
+		t.Fatalf("SaveChannels: %v", err)
+	}
+
+	loaded, err := pc.LoadChannels(context.Background())
+	if err != nil {
+		t.Fatalf("LoadChannels: %v", err)
+	}
+	if len(loaded) != 2 {
+		t.Fatalf("got %d channels, want 2", len(loaded))
+	}
+
+	general := loaded["C1"]
+	if general.Name != "general" || general.Topic != "Company-wide" || general.Purpose != "Announcements" ||
+		general.NumMembers != 120 || !general.Created.Equal(channels["C1"].Created) {
+		t.Errorf("C1 = %+v, want round-tripped metadata", general)
+	}
+	if secret := loaded["C2"]; !secret.IsPrivate || !secret.IsArchived || secret.Topic != "" || !secret.Created.IsZero() {
+		t.Errorf("C2 = %+v, want private, archived and empty optional fields", secret)
+	}
+	if general.CachedAt.IsZero() {
+		t.Error("cached_at should be stamped on save")
+	}
+}
+
+func TestLoadEmojiRoundTrip(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+
+	emoji := []*models.SlackEmoji{
+		{Name: "squirrel", AliasFor: "shipit"},
+		{Name: "shipit", URL: "https://emoji.slack-edge.com/T1/shipit/abc.png"},
+	}
+	if _, err := pc.SaveEmoji(emoji); err != nil {
+		t.Fatalf("SaveEmoji: %v", err)
+	}
+
+	loaded, err := pc.LoadEmoji(context.Background())
+	if err != nil {
+		t.Fatalf("LoadEmoji: %v", err)
+	}
+	if len(loaded) != 2 || loaded["squirrel"].AliasFor != "shipit" || loaded["shipit"].CachedAt.IsZero() {
+		t.Fatalf("loaded = %+v, want both emoji with cached_at", loaded)
+	}
+	if url, ok := loaded.Resolve("squirrel"); !ok || url != emoji[1].URL {
+		t.Errorf("Resolve(squirrel) = %q, %v; want the shipit URL", url, ok)
+	}
+}
+
+func TestMergeMessagesKeepsOtherRows(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	channel := &models.SlackChannel{Name: "incidents", ID: "C1"}
+
+	day := []*models.SlackMessage{
+		{MessageID: "1700000000.000100", Text: "PROJ-1 broke", Timestamp: time.Unix(1700000000, 0), JiraTickets: []string{"PROJ-1"}},
+		{MessageID: "1700000100.000100", Text: "thread parent", Timestamp: time.Unix(1700000100, 0), ThreadTS: "1700000100.000100", ReplyCount: 1},
+		{MessageID: "1700000200.000100", Text: "unrelated", Timestamp: time.Unix(1700000200, 0)},
+	}
+	if _, err := pc.SaveMessages(day, channel, "2023-11-14"); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	thread := []*models.SlackMessage{
+		{MessageID: "1700000100.000100", Text: "thread parent (edited)", Timestamp: time.Unix(1700000100, 0), ThreadTS: "1700000100.000100", ReplyCount: 2},
+		{MessageID: "1700000150.000100", Text: "late reply", Timestamp: time.Unix(1700000150, 0), ThreadTS: "1700000100.000100"},
+	}
+	stale := &models.SlackMessage{MessageID: "1700000200.000100", Text: "stale copy", Timestamp: time.Unix(1700000200, 0), FetchedAt: time.Unix(1600000000, 0)}
+	thread = append(thread, stale)
+	path, err := pc.MergeMessages(ctx, thread, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("MergeMessages: %v", err)
+	}
+
+	if report := VerifyFile(ctx, path, pc.schema); !report.OK() || report.Rows != 4 {
+		t.Fatalf("report = %+v, want a valid file with 4 rows", report)
+	}
+
+	table, err := pc.readTable(ctx, path)
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	defer table.Release()
+
+	texts := map[string]string{}
+	ids := table.Column(0).Data().Chunks()
+	bodies := table.Column(2).Data().Chunks()
+	for c := range ids {
+		idCol, textCol := ids[c].(*array.String), bodies[c].(*array.String)
+		for i := 0; i < idCol.Len(); i++ {
+			texts[idCol.Value(i)] = textCol.Value(i)
+		}
+	}
+	want := map[string]string{
+		"1700000000.000100": "PROJ-1 broke",
+		"1700000100.000100": "thread parent (edited)",
+		"1700000150.000100": "late reply",
+		"1700000200.000100": "unrelated",
+	}
+	for id, text := range want {
+		if texts[id] != text {
+			t.Errorf("message %s text = %q, want %q", id, texts[id], text)
+		}
+	}
+}
+
+func TestRefetchedDropsDeletedMessages(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	channel := &models.SlackChannel{Name: "incidents", ID: "C1"}
+
+	stored := []*models.SlackMessage{
+		{MessageID: "1700000000.000100", Text: "before the window", Timestamp: time.Unix(1700000000, 0)},
+		{MessageID: "1700000050.000100", Text: "old thread", Timestamp: time.Unix(1700000050, 0), ThreadTS: "1700000050.000100", ReplyCount: 1},
+		{MessageID: "1700000100.000100", Text: "deleted", Timestamp: time.Unix(1700000100, 0)},
+		{MessageID: "1700000200.000100", Text: "thread parent", Timestamp: time.Unix(1700000200, 0), ThreadTS: "1700000200.000100", ReplyCount: 1},
+		{MessageID: "1700000250.000100", Text: "deleted reply", Timestamp: time.Unix(1700000250, 0), ThreadTS: "1700000200.000100"},
+		{MessageID: "1700000300.000100", Text: "reply to the old thread", Timestamp: time.Unix(1700000300, 0), ThreadTS: "1700000050.000100"},
+	}
+	if _, err := pc.SaveMessages(stored, channel, "2023-11-14"); err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	fetched := []*models.SlackMessage{
+		{MessageID: "1700000200.000100", Text: "thread parent", Timestamp: time.Unix(1700000200, 0), ThreadTS: "1700000200.000100"},
+		{MessageID: "1700000350.000100", Text: "new", Timestamp: time.Unix(1700000350, 0)},
+	}
+	path, err := pc.Refetched(time.Unix(1700000100, 0), time.Unix(1700000400, 0)).SaveMessages(fetched, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	table, err := pc.readTable(ctx, path)
+	if err != nil {
+		t.Fatalf("readTable: %v", err)
+	}
+	defer table.Release()
+
+	got := map[string]bool{}
+	for _, chunk := range table.Column(0).Data().Chunks() {
+		ids := chunk.(*array.String)
+		for i := 0; i < ids.Len(); i++ {
+			got[ids.Value(i)] = true
+		}
+	}
+	want := map[string]bool{
+		"1700000000.000100": true,
+		"1700000050.000100": true,
+		"1700000200.000100": true,
+		"1700000300.000100": true,
+		"1700000350.000100": true,
+	}
+	if len(got) != len(want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+	for id := range want {
+		if !got[id] {
+			t.Errorf("message %s was dropped", id)
+		}
+	}
+
+	// Without a window the merge keeps every stored row
+	path, err = pc.SaveMessages(fetched[:1], channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if report := VerifyFile(ctx, path, pc.schema); report.Rows != 5 {
+		t.Errorf("rows after a plain save = %d, want 5", report.Rows)
+	}
+}
+
+func TestReadMessagesRoundTrip(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+
+	saved := []*models.SlackMessage{
+		{
+			MessageID: "1700000100.000100", UserID: "U1", Text: "PROJ-1 and PROJ-2", Timestamp: time.Unix(1700000100, 0).UTC(),
+			ThreadTS: "1700000100.000100", ReplyCount: 1, JiraTickets: []string{"PROJ-1", "PROJ-2"},
+			UserInfo:  &models.SlackUser{ID: "U1", Name: "alice", RealName: "Alice", Email: "alice@example.com"},
+			Permalink: "https://acme.slack.com/archives/C1/p1700000100000100",
+			Reactions: []models.SlackReaction{{Emoji: "eyes", Count: 2, Users: []string{"U2", "U3"}}, {Emoji: "tada", Count: 1}},
+			CleanText: "PROJ-1 and PROJ-2",
+			URLs:      []string{"https://go.dev/doc", "https://example.com"},
+			Blocks:    json.RawMessage(`[{"type":"section","text":{"type":"mrkdwn","text":"PROJ-1 and PROJ-2"}},{"type":"actions","elements":[{"type":"button","action_id":"ack"}]}]`),
+		},
+		{MessageID: "1700000110.000100", BotID: "B1", Text: "reply", Timestamp: time.Unix(1700000110, 0).UTC(), ThreadTS: "1700000100.000100"},
+	}
+	path, err := pc.SaveMessages(saved, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	msgs, err := pc.ReadMessages(context.Background(), path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+
+	parent := msgs[0]
+	if parent.MessageID != saved[0].MessageID || parent.Text != saved[0].Text || !parent.Timestamp.Equal(saved[0].Timestamp) ||
+		!parent.IsThreadParent() || parent.Permalink != saved[0].Permalink || parent.FetchedAt.IsZero() || parent.CleanText != saved[0].CleanText {
+		t.Errorf("parent = %+v, want the saved fields back", parent)
+	}
+	if len(parent.JiraTickets) != 2 || parent.JiraTickets[1] != "PROJ-2" {
+		t.Errorf("jira_tickets = %v, want [PROJ-1 PROJ-2]", parent.JiraTickets)
+	}
+	if len(parent.URLs) != 2 || parent.URLs[1] != "https://example.com" {
+		t.Errorf("urls = %v, want [https://go.dev/doc https://example.com]", parent.URLs)
+	}
+	if string(parent.Blocks) != string(saved[0].Blocks) {
+		t.Errorf("blocks = %s, want %s", parent.Blocks, saved[0].Blocks)
+	}
+	if r := parent.Reactions; len(r) != 2 || r[0].Emoji != "eyes" || r[0].Count != 2 || len(r[0].Users) != 2 || r[1].Emoji != "tada" || r[1].Users != nil {
+		t.Errorf("reactions = %+v, want eyes x2 (U2, U3) and tada x1", r)
+	}
+	if parent.UserInfo == nil || parent.UserInfo.RealName != "Alice" || parent.UserInfo.Email != "alice@example.com" {
+		t.Errorf("user info = %+v, want alice", parent.UserInfo)
+	}
+	if reply := msgs[1]; !reply.IsThreadReply() || reply.UserInfo != nil || reply.JiraTickets != nil || reply.CleanText != "" || reply.URLs != nil || reply.Blocks != nil {
+		t.Errorf("reply = %+v, want a thread reply without user info, tickets, clean text or blocks", reply)
+	}
+}
+
+// BenchmarkSaveMessagesPeakHeap writes a 200k-message partition and reports
+// the highest heap seen while writing, beyond the messages themselves. Run
+// it with a low GOGC (e.g. GOGC=10) so the figure tracks live memory rather
+// than garbage not yet collected.
+func BenchmarkSaveMessagesPeakHeap(b *testing.B) {
+	start := time.Date(2023, 11, 1, 0, 0, 0, 0, time.UTC)
+	messages := make([]*models.SlackMessage, 200_000)
+	for i := range messages {
+		at := start.Add(time.Duration(i) * 10 * time.Second)
+		messages[i] = &models.SlackMessage{
+			MessageID: fmt.Sprintf("%d.%06d", at.Unix(), i), UserID: "U1", Timestamp: at,
+			Text:        fmt.Sprintf("message %d about PROJ-%d with a little more text to store", i, i%500),
+			JiraTickets: []string{fmt.Sprintf("PROJ-%d", i%500)},
+		}
+	}
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+
+	var peak uint64
+	for i := 0; i < b.N; i++ {
+		pc := NewParquetCache(filepath.Join(b.TempDir(), "raw"))
+		runtime.GC()
+		base := liveHeap()
+		stop := sampleHeap(&peak, base)
+		if _, err := pc.SaveMessages(messages, channel, "2023-11"); err != nil {
+			b.Fatalf("SaveMessages: %v", err)
+		}
+		stop()
+	}
+	b.ReportMetric(float64(peak)/(1<<20), "peak-MiB")
+}
+
+// liveHeap returns the bytes held by live and not yet swept heap objects
+func liveHeap() uint64 {
+	s := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(s)
+	return s[0].Value.Uint64()
+}
+
+// sampleHeap records in peak the largest heap growth over base until the
+// returned func is called
+func sampleHeap(peak *uint64, base uint64) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for {
+			if h := liveHeap(); h > base && h-base > *peak {
+				*peak = h - base
+			}
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"fmt"
+	"time"
+)
+
+// Granularity controls how messages are grouped into dt= partitions
+type Granularity string
+
+const (
+	GranularityHour  Granularity = "hour"
+	GranularityDay   Granularity = "day"
+	GranularityMonth Granularity = "month"
+)
+
+// partitionLayouts maps each granularity to its dt= value format.
+// The formats have distinct lengths so the reader can tell them apart.
+var partitionLayouts = map[Granularity]string{
+	GranularityHour:  "2006-01-02T15",
+	GranularityDay:   "2006-01-02",
+	GranularityMonth: "2006-01",
+}
+
+// ParseGranularity validates a --partition-granularity value
+func ParseGranularity(s string) (Granularity, error) {
+	g := Granularity(s)
+	if _, ok := partitionLayouts[g]; !ok {
+		return "", fmt.Errorf("invalid partition granularity %q (expected hour, day or month)", s)
+	}
+	return g, nil
+}
+
+// PartitionKey formats a timestamp as the dt= value for this granularity,
+// in t's location; callers convert t to the partition time zone first
+func (g Granularity) PartitionKey(t time.Time) string {
+	return t.Format(partitionLayouts[g])
+}
+
+// Start returns the beginning of the partition containing t, in t's location
+func (g Granularity) Start(t time.Time) time.Time {
+	switch g {
+	case GranularityHour:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case GranularityMonth:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	}
+}
+
+// Duration returns the span of time covered by a partition starting at t
+func (g Granularity) Duration(start time.Time) time.Duration {
+	switch g {
+	case GranularityHour:
+		return time.Hour
+	case GranularityMonth:
+		return start.AddDate(0, 1, 0).Sub(start)
+	default:
+		return start.AddDate(0, 0, 1).Sub(start)
+	}
+}
+
+// ParsePartitionKey derives the granularity and start time from a dt= value
+// cut in UTC
+func ParsePartitionKey(key string) (Granularity, time.Time, error) {
+	return ParsePartitionKeyIn(key, time.UTC)
+}
+
+// ParsePartitionKeyIn derives the granularity and start time from a dt=
+// value cut in loc
+func ParsePartitionKeyIn(key string, loc *time.Location) (Granularity, time.Time, error) {
+	for g, layout := range partitionLayouts {
+		if len(key) != len(layout) {
+			continue
+		}
+		t, err := time.ParseInLocation(layout, key, loc)
+		if err != nil {
+			return "", time.Time{}, fmt.Errorf("invalid partition key %q: %w", key, err)
+		}
+		return g, t, nil
+	}
+	return "", time.Time{}, fmt.Errorf("unrecognized partition key %q", key)
+}
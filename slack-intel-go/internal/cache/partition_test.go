@@ -0,0 +1,160 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestPartitionKeyRoundTrip(t *testing.T) {
+	ts := time.Date(2024, 5, 10, 14, 35, 0, 0, time.UTC)
+
+	tests := []struct {
+		granularity Granularity
+		key         string
+		start       time.Time
+	}{
+		{GranularityHour, "2024-05-10T14", time.Date(2024, 5, 10, 14, 0, 0, 0, time.UTC)},
+		{GranularityDay, "2024-05-10", time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)},
+		{GranularityMonth, "2024-05", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		key := tt.granularity.PartitionKey(ts)
+		if key != tt.key {
+			t.Errorf("%s: PartitionKey = %q, want %q", tt.granularity, key, tt.key)
+		}
+		if start := tt.granularity.Start(ts); !start.Equal(tt.start) {
+			t.Errorf("%s: Start = %v, want %v", tt.granularity, start, tt.start)
+		}
+
+		g, start, err := ParsePartitionKey(key)
+		if err != nil {
+			t.Errorf("%s: ParsePartitionKey(%q): %v", tt.granularity, key, err)
+			continue
+		}
+		if g != tt.granularity || !start.Equal(tt.start) {
+			t.Errorf("ParsePartitionKey(%q) = %s %v, want %s %v", key, g, start, tt.granularity, tt.start)
+		}
+	}
+}
+
+func TestParseGranularity(t *testing.T) {
+	if _, err := ParseGranularity("week"); err == nil {
+		t.Error("expected error for unsupported granularity")
+	}
+	if g, err := ParseGranularity("month"); err != nil || g != GranularityMonth {
+		t.Errorf("ParseGranularity(month) = %s, %v", g, err)
+	}
+}
+
+func TestListPartitionsDerivesGranularity(t *testing.T) {
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	msgs := []*models.SlackMessage{{MessageID: "1715350000.000100", Text: "hi", Timestamp: time.Unix(1715350000, 0)}}
+	channel := &models.SlackChannel{Name: "incidents", ID: "C1"}
+
+	for _, key := range []string{"2024-05-10T14", "2024-05-11", "2024-06"} {
+		if _, err := pc.SaveMessages(msgs, channel, key); err != nil {
+			t.Fatalf("SaveMessages(%s): %v", key, err)
+		}
+	}
+
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+
+	want := []Granularity{GranularityHour, GranularityDay, GranularityMonth}
+	if len(partitions) != len(want) {
+		t.Fatalf("got %d partitions, want %d", len(partitions), len(want))
+	}
+	for i, p := range partitions {
+		if p.Granularity != want[i] || p.Channel != "incidents" {
+			t.Errorf("partition %d = %+v, want %s for incidents", i, p, want[i])
+		}
+	}
+}
+
+func TestListPartitionsSkipsUnparseableKeys(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(base)
+	msgs := []*models.SlackMessage{{MessageID: "1715350000.000100", Text: "hi", Timestamp: time.Unix(1715350000, 0)}}
+	path, err := pc.SaveMessages(msgs, &models.SlackChannel{Name: "incidents", ID: "C1"}, "2024-05-11")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	// A stray directory, e.g. copied in by hand, must not hide the others
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stray := filepath.Join(base, "messages", "dt=2024-05-11-old", "channel=incidents")
+	if err := os.MkdirAll(stray, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(stray, "data.parquet"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].Key != "2024-05-11" {
+		t.Errorf("partitions = %+v, want only 2024-05-11", partitions)
+	}
+}
+
+func TestPartitionTimezone(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no tzdata: %v", err)
+	}
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetPartitionTimezone(berlin)
+
+	// 22:30 UTC on May 10 is already May 11 in Berlin
+	ts := time.Date(2024, 5, 10, 22, 30, 0, 0, time.UTC)
+	key := GranularityDay.PartitionKey(ts.In(berlin))
+	if key != "2024-05-11" {
+		t.Fatalf("PartitionKey in Berlin = %s, want 2024-05-11", key)
+	}
+	msgs := []*models.SlackMessage{{MessageID: "1715380200.000100", Text: "late", Timestamp: ts}}
+	path, err := pc.SaveMessages(msgs, &models.SlackChannel{Name: "general", ID: "C1"}, key)
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if meta["partition_timezone"] != "Europe/Berlin" {
+		t.Errorf("partition_timezone = %q, want Europe/Berlin", meta["partition_timezone"])
+	}
+
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	want := time.Date(2024, 5, 11, 0, 0, 0, 0, berlin)
+	if len(partitions) != 1 || !partitions[0].Start.Equal(want) {
+		t.Fatalf("partitions = %+v, want one starting %v", partitions, want)
+	}
+	if !(MessageFilter{From: ts, To: ts.Add(time.Minute)}).Partition(partitions[0]) {
+		t.Error("filter around the message skips its partition")
+	}
+
+	gaps, err := pc.FindGaps(context.Background(), MessageFilter{From: time.Date(2024, 5, 10, 0, 0, 0, 0, berlin), To: time.Date(2024, 5, 13, 0, 0, 0, 0, berlin)}, false)
+	if err != nil {
+		t.Fatalf("FindGaps: %v", err)
+	}
+	if len(gaps) != 2 || gaps[0].From.Format("2006-01-02") != "2024-05-10" || gaps[1].From.Format("2006-01-02") != "2024-05-12" {
+		t.Errorf("gaps = %+v, want May 10 and May 12 in Berlin", gaps)
+	}
+}
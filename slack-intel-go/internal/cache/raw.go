@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// RawMode selects where the original API payloads of messages are stored
+type RawMode string
+
+const (
+	// RawOff stores no payloads
+	RawOff RawMode = ""
+	// RawColumn stores each payload in the raw_json column
+	RawColumn RawMode = "column"
+	// RawSidecar stores the payloads of a partition as NDJSON in
+	// raw/messages.ndjson next to its data file, keeping that file small
+	RawSidecar RawMode = "sidecar"
+)
+
+// ParseRawMode maps the --raw flag to a RawMode; "off" and "" disable it
+func ParseRawMode(s string) (RawMode, error) {
+	switch s {
+	case "", "off":
+		return RawOff, nil
+	case string(RawColumn), string(RawSidecar):
+		return RawMode(s), nil
+	}
+	return "", fmt.Errorf("invalid raw mode %q (expected column, sidecar or off)", s)
+}
+
+// SetRawPayloads sets where message payloads (SlackMessage.Raw) are
+// written and records the mode as the raw file metadata, so reprocessing
+// knows where to look
+func (pc *ParquetCache) SetRawPayloads(mode RawMode) {
+	pc.raw = mode
+	if mode == RawOff {
+		delete(pc.metadata, "raw")
+		return
+	}
+	pc.metadata["raw"] = string(mode)
+}
+
+// SidecarPath returns the payload file belonging to a partition's data file
+func SidecarPath(dataPath string) string {
+	return filepath.Join(filepath.Dir(dataPath), "raw", "messages.ndjson")
+}
+
+// LoadSidecar reads the payloads stored next to a partition's data file,
+// keyed by message_id (the payload's ts). A missing sidecar yields nil.
+func (pc *ParquetCache) LoadSidecar(dataPath string) (map[string]json.RawMessage, error) {
+	path := SidecarPath(dataPath)
+	f, err := pc.storage.Reader(path)
+	if storage.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer f.Close()
+
+	payloads := make(map[string]json.RawMessage)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 {
+			continue
+		}
+		var key struct {
+			TS string `json:"ts"`
+		}
+		if err := json.Unmarshal(raw, &key); err != nil || key.TS == "" {
+			return nil, fmt.Errorf("%s line %d: not a message payload", path, line)
+		}
+		payloads[key.TS] = append(json.RawMessage(nil), raw...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return payloads, nil
+}
+
+// writeSidecar writes payloads, one per line in message_id order, as the
+// sidecar of dataPath. Nothing is written when there are none.
+func (pc *ParquetCache) writeSidecar(dataPath string, payloads map[string]json.RawMessage) error {
+	if len(payloads) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(payloads))
+	for id := range payloads {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	path := SidecarPath(dataPath)
+	w, err := pc.storage.Writer(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	bw := bufio.NewWriter(w)
+	for _, id := range ids {
+		bw.Write(payloads[id])
+		bw.WriteByte('\n')
+	}
+	if err := bw.Flush(); err != nil {
+		storage.Abort(w)
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish %s: %w", path, err)
+	}
+	return nil
+}
+
+// mergeSidecar updates the sidecar of dataPath with the payloads of
+// messages, keeping those of other messages already stored there apart
+// from the deleted ones
+func (pc *ParquetCache) mergeSidecar(dataPath string, messages []*models.SlackMessage, deleted []string) error {
+	payloads, err := pc.LoadSidecar(dataPath)
+	if err != nil {
+		return err
+	}
+	if payloads == nil {
+		payloads = make(map[string]json.RawMessage)
+	}
+	for _, id := range deleted {
+		delete(payloads, id)
+	}
+	for id, raw := range rawPayloads(messages) {
+		payloads[id] = raw
+	}
+	return pc.writeSidecar(dataPath, payloads)
+}
+
+// rawPayloads collects the non-empty payloads of messages by message_id
+func rawPayloads(messages []*models.SlackMessage) map[string]json.RawMessage {
+	payloads := make(map[string]json.RawMessage)
+	for _, msg := range messages {
+		if len(msg.Raw) > 0 {
+			payloads[msg.MessageID] = msg.Raw
+		}
+	}
+	return payloads
+}
+
+// RewriteMessages replaces a partition's data file with messages in the
+// current schema, keeping the file's metadata apart from schema_version,
+// tool_version and activity_hours, which are re-derived. is_pinned,
+// has_files, has_reactions and channel_name stay set for rows that had
+// them, since messages read back from the cache carry no pins, files or
+// channel name, and no reactions when the file predates that column.
+// Payloads go to the raw_json column only if the file was written with
+// RawColumn; a sidecar is left as it is. It is how reprocessing upgrades
+// partitions written by older versions.
+func (pc *ParquetCache) RewriteMessages(ctx context.Context, path string, messages []*models.SlackMessage) error {
+	return pc.rewriteMessages(ctx, path, messages, nil)
+}
+
+// rewriteMessages rewrites path as RewriteMessages does, dropping the
+// metadata keys in drop
+func (pc *ParquetCache) rewriteMessages(ctx context.Context, path string, messages []*models.SlackMessage, drop []string) error {
+	metadata, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		return err
+	}
+	for _, key := range drop {
+		delete(metadata, key)
+	}
+	metadata["schema_version"] = SchemaVersion
+	metadata["tool_version"] = ToolVersion
+	metadata["activity_hours"] = pc.activity.String()
+
+	stored, err := pc.ReadStoredFlags(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	mw, err := pc.newMessageWriter(path, metadata)
+	if err != nil {
+		return err
+	}
+	defer mw.Abort()
+	mw.rawColumn, mw.stored = RawMode(metadata["raw"]) == RawColumn, stored
+	if err := mw.Write(messages); err != nil {
+		return err
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+
+	pc.logger.Debug("rewrote partition", "path", path, "rows", len(messages))
+	return nil
+}
+
+// ReadStoredFlags maps message_id to the is_pinned, has_files,
+// has_reactions and channel_name values stored in the partition at path
+func (pc *ParquetCache) ReadStoredFlags(ctx context.Context, path string) (map[string]StoredFlags, error) {
+	table, err := pc.readTable(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	flags := make(map[string]StoredFlags)
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+	for tr.Next() {
+		cols := columns{rec: tr.Record()}
+		ids, pinned, files := cols.strings("message_id"), cols.bools("is_pinned"), cols.bools("has_files")
+		reactions, names := cols.bools("has_reactions"), cols.strings("channel_name")
+		if ids == nil {
+			return nil, fmt.Errorf("unexpected schema in %s: missing message_id", path)
+		}
+		for i := 0; i < ids.Len(); i++ {
+			flags[ids.Value(i)] = StoredFlags{
+				Pinned:      boolValue(pinned, i),
+				Files:       boolValue(files, i),
+				Reactions:   boolValue(reactions, i),
+				ChannelName: stringValue(names, i),
+			}
+		}
+	}
+	return flags, nil
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// payloadMessage returns a message carrying a minimal API payload
+func payloadMessage(ts, user, text string) *models.SlackMessage {
+	raw, _ := json.Marshal(map[string]string{"type": "message", "ts": ts, "user": user, "text": text})
+	return &models.SlackMessage{MessageID: ts, UserID: user, Text: text, Timestamp: time.Unix(1700000100, 0).UTC(), Raw: raw}
+}
+
+func TestRawColumnRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetRawPayloads(RawColumn)
+
+	saved := []*models.SlackMessage{payloadMessage("1700000100.000100", "U1", "hi"), payloadMessage("1700000110.000100", "U2", "yo")}
+	saved[1].Raw = nil
+	path, err := pc.SaveMessages(saved, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	msgs, err := pc.ReadMessages(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if string(msgs[0].Raw) != string(saved[0].Raw) || msgs[1].Raw != nil {
+		t.Errorf("raw = %s / %s, want the first payload back and none for the second", msgs[0].Raw, msgs[1].Raw)
+	}
+	if meta, _ := pc.ReadFileMetadata(path); meta["raw"] != "column" {
+		t.Errorf("metadata raw = %q, want column", meta["raw"])
+	}
+	if exists, _ := pc.storage.Exists(SidecarPath(path)); exists {
+		t.Error("column mode wrote a sidecar")
+	}
+}
+
+func TestRawSidecarSaveMergeAndRedact(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.SetRawPayloads(RawSidecar)
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+
+	first := payloadMessage("1700000100.000100", "U1", "hi")
+	path, err := pc.SaveMessages([]*models.SlackMessage{first}, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	msgs, err := pc.ReadMessages(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if msgs[0].Raw != nil {
+		t.Errorf("raw_json = %s, want it empty in sidecar mode", msgs[0].Raw)
+	}
+
+	second := payloadMessage("1700000110.000100", "U2", "yo")
+	if _, err := pc.MergeMessages(ctx, []*models.SlackMessage{second}, channel, "2023-11-14"); err != nil {
+		t.Fatalf("MergeMessages: %v", err)
+	}
+	payloads, err := pc.LoadSidecar(path)
+	if err != nil {
+		t.Fatalf("LoadSidecar: %v", err)
+	}
+	if len(payloads) != 2 || string(payloads[first.MessageID]) != string(first.Raw) || string(payloads[second.MessageID]) != string(second.Raw) {
+		t.Errorf("sidecar = %v, want both payloads", payloads)
+	}
+
+	if _, err := pc.RedactUser(ctx, path, "U1"); err != nil {
+		t.Fatalf("RedactUser: %v", err)
+	}
+	payloads, err = pc.LoadSidecar(path)
+	if err != nil {
+		t.Fatalf("LoadSidecar: %v", err)
+	}
+	if _, ok := payloads[first.MessageID]; ok || len(payloads) != 1 {
+		t.Errorf("sidecar after redaction = %v, want only U2's payload", payloads)
+	}
+}
+
+func TestRewriteMessagesUpgradesSchemaVersion(t *testing.T) {
+	ctx := context.Background()
+	base := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(base)
+	pc.SetMetadata("schema_version", "6")
+	pc.SetMetadata("token_type", "bot")
+	pc.SetRawPayloads(RawColumn)
+
+	msg := payloadMessage("1700000100.000100", "U1", "hi")
+	msg.PinnedTo = []string{"C1"}
+	msg.Files = []models.SlackFile{{ID: "F1"}}
+	path, err := pc.SaveMessages([]*models.SlackMessage{msg}, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	// As read back from the cache: no pins or files
+	msg.Text, msg.PinnedTo, msg.Files = "hi again", nil, nil
+	if err := NewParquetCache(base).RewriteMessages(ctx, path, []*models.SlackMessage{msg}); err != nil {
+		t.Fatalf("RewriteMessages: %v", err)
+	}
+
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if meta["schema_version"] != SchemaVersion || meta["token_type"] != "bot" || meta["raw"] != "column" {
+		t.Errorf("metadata = %v, want current schema_version and the rest kept", meta)
+	}
+	msgs, err := pc.ReadMessages(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if msgs[0].Text != "hi again" || string(msgs[0].Raw) != string(msg.Raw) {
+		t.Errorf("rewritten = %+v, want new text and the payload kept", msgs[0])
+	}
+	if flags, err := pc.ReadStoredFlags(ctx, path); err != nil || !flags[msg.MessageID].Pinned || !flags[msg.MessageID].Files {
+		t.Errorf("stored flags = %+v, %v; want is_pinned and has_files kept", flags, err)
+	}
+}
+
+func TestRewriteMessagesKeepsHasReactions(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+
+	msg := payloadMessage("1700000100.000100", "U1", "hi")
+	msg.Reactions = []models.SlackReaction{{Emoji: "eyes", Count: 1, Users: []string{"U2"}}}
+	path, err := pc.SaveMessages([]*models.SlackMessage{msg}, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	// As read back from a file predating the reactions column
+	msg.Reactions = nil
+	if err := pc.RewriteMessages(ctx, path, []*models.SlackMessage{msg}); err != nil {
+		t.Fatalf("RewriteMessages: %v", err)
+	}
+	flags, err := pc.ReadStoredFlags(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadStoredFlags: %v", err)
+	}
+	if !flags[msg.MessageID].Reactions {
+		t.Errorf("stored flags = %+v, want has_reactions kept", flags[msg.MessageID])
+	}
+	if got := flags[msg.MessageID].With(msg); got != flags[msg.MessageID] {
+		t.Errorf("With(rewritten) = %+v, want unchanged %+v", got, flags[msg.MessageID])
+	}
+}
+
+func TestParseRawMode(t *testing.T) {
+	for in, want := range map[string]RawMode{"": RawOff, "off": RawOff, "column": RawColumn, "sidecar": RawSidecar} {
+		if got, err := ParseRawMode(in); err != nil || got != want {
+			t.Errorf("ParseRawMode(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseRawMode("s3"); err == nil {
+		t.Error("ParseRawMode(s3) succeeded")
+	}
+}
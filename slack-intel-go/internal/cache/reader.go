@@ -0,0 +1,520 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// Partition is one partition directory in the message cache
+type Partition struct {
+	Key         string // partition key, e.g. 2023-11-14
+	Channel     string
+	Granularity Granularity
+	Start       time.Time
+	Path        string // data file inside the partition
+}
+
+// ListPartitions lists the partition data files (data.parquet unless set
+// with SetPartFileName) under messages/ that match the name template and
+// derives each partition's granularity from the key format and its start
+// from the key in the partition time zone. Partitions whose key does not
+// parse are skipped with a warning.
+func (pc *ParquetCache) ListPartitions() ([]Partition, error) {
+	messagesDir := filepath.Join(pc.basePath, "messages")
+
+	files, err := pc.storage.List(messagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var partitions []Partition
+	for _, path := range files {
+		rel, err := filepath.Rel(messagesDir, path)
+		if err != nil {
+			continue
+		}
+		dir, name := filepath.Split(rel)
+		if name != pc.partFile {
+			continue
+		}
+		key, channel, ok := pc.template.parse(filepath.ToSlash(dir))
+		if !ok {
+			continue
+		}
+		granularity, start, err := ParsePartitionKeyIn(key, pc.location)
+		if err != nil {
+			pc.logger.Warn("skipping partition with an unparseable key", "path", path, "error", err)
+			continue
+		}
+
+		partitions = append(partitions, Partition{
+			Key:         key,
+			Channel:     channel,
+			Granularity: granularity,
+			Start:       start,
+			Path:        path,
+		})
+	}
+
+	sort.Slice(partitions, func(i, j int) bool {
+		if !partitions[i].Start.Equal(partitions[j].Start) {
+			return partitions[i].Start.Before(partitions[j].Start)
+		}
+		return partitions[i].Channel < partitions[j].Channel
+	})
+
+	return partitions, nil
+}
+
+// openParquet opens a Parquet file from the cache's storage. Closing the
+// reader closes the underlying file.
+func (pc *ParquetCache) openParquet(path string) (*file.Reader, error) {
+	f, err := pc.storage.Reader(path)
+	if err != nil {
+		return nil, err
+	}
+	rdr, err := file.NewParquetReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return rdr, nil
+}
+
+// readTable reads a whole Parquet file from the cache's storage. Errors
+// are returned unwrapped so callers can check storage.IsNotExist.
+func (pc *ParquetCache) readTable(ctx context.Context, path string) (arrow.Table, error) {
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rdr.Close()
+
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		return nil, err
+	}
+	return fr.ReadTable(ctx)
+}
+
+// ReadFileMetadata returns the key-value metadata stored in a Parquet file footer
+func (pc *ParquetCache) ReadFileMetadata(path string) (map[string]string, error) {
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer rdr.Close()
+
+	kv := rdr.MetaData().KeyValueMetadata()
+	meta := make(map[string]string, len(kv))
+	for i, key := range kv.Keys() {
+		meta[key] = kv.Values()[i]
+	}
+	return meta, nil
+}
+
+// ReadRowCount returns the number of rows in a Parquet file, from its footer
+func (pc *ParquetCache) ReadRowCount(path string) (int64, error) {
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer rdr.Close()
+	return rdr.NumRows(), nil
+}
+
+// ReadMessages reads a partition's data file back into messages. User
+// fields become a UserInfo; files and pins are not restored because only
+// the has_files and is_pinned flags are stored.
+func (pc *ParquetCache) ReadMessages(ctx context.Context, path string) ([]*models.SlackMessage, error) {
+	table, err := pc.readTable(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	return messagesFromTable(table, path, MessageFlags{})
+}
+
+// messagesFromTable decodes the rows of a messages table read from path
+// that have every column flags requires set
+func messagesFromTable(table arrow.Table, path string, flags MessageFlags) ([]*models.SlackMessage, error) {
+	var err error
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+
+	var messages []*models.SlackMessage
+	for tr.Next() {
+		rec := tr.Record()
+		cols := columns{rec: rec}
+		ids := cols.strings("message_id")
+		if ids == nil {
+			return nil, fmt.Errorf("unexpected schema in %s: missing message_id", path)
+		}
+		userIDs, texts, timestamps := cols.strings("user_id"), cols.strings("text"), cols.strings("timestamp")
+		threadTSs, replyCounts := cols.strings("thread_ts"), cols.int64s("reply_count")
+		names, realNames, emails := cols.strings("user_name"), cols.strings("user_real_name"), cols.strings("user_email")
+		bots := cols.bools("user_is_bot")
+		tickets := cols.lists("jira_tickets")
+		permalinks, fetched := cols.strings("permalink"), cols.strings("fetched_at")
+		reactions, cleanTexts := cols.lists("reactions"), cols.strings("clean_text")
+		urls, blocks, raws := cols.lists("urls"), cols.strings("blocks"), cols.strings("raw_json")
+		edited := cols.strings("edited_at")
+		var required []*array.Boolean
+		for _, name := range flags.columns() {
+			required = append(required, cols.bools(name))
+		}
+
+		for i := 0; i < int(rec.NumRows()); i++ {
+			if !allSet(required, i) {
+				continue
+			}
+			msg := &models.SlackMessage{
+				MessageID:   ids.Value(i),
+				UserID:      stringValue(userIDs, i),
+				Text:        stringValue(texts, i),
+				ThreadTS:    stringValue(threadTSs, i),
+				ReplyCount:  int(int64Value(replyCounts, i)),
+				JiraTickets: listValue(tickets, i),
+				Permalink:   stringValue(permalinks, i),
+				Reactions:   reactionsValue(reactions, i),
+				CleanText:   stringValue(cleanTexts, i),
+				URLs:        listValue(urls, i),
+			}
+			if b := stringValue(blocks, i); b != "" {
+				msg.Blocks = json.RawMessage(b)
+			}
+			if r := stringValue(raws, i); r != "" {
+				msg.Raw = json.RawMessage(r)
+			}
+			if msg.Timestamp, err = timeValue(timestamps, i); err != nil {
+				return nil, fmt.Errorf("invalid timestamp for %s: %w", msg.MessageID, err)
+			}
+			if msg.FetchedAt, err = timeValue(fetched, i); err != nil {
+				return nil, fmt.Errorf("invalid fetched_at for %s: %w", msg.MessageID, err)
+			}
+			if msg.EditedAt, err = timeValue(edited, i); err != nil {
+				return nil, fmt.Errorf("invalid edited_at for %s: %w", msg.MessageID, err)
+			}
+			if names != nil && !names.IsNull(i) {
+				msg.UserInfo = &models.SlackUser{
+					ID:       msg.UserID,
+					Name:     names.Value(i),
+					RealName: stringValue(realNames, i),
+					Email:    stringValue(emails, i),
+					IsBot:    boolValue(bots, i),
+				}
+			}
+			messages = append(messages, msg)
+		}
+	}
+	if err := tr.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return messages, nil
+}
+
+// LoadUsers reads users.parquet back into the map shape the Slack client
+// caches, keyed by user ID. Null names and emails become empty strings.
+// Columns added after the first release (is_deleted, updated_at, title,
+// tz, image_192, is_external, team_id) are optional so older files still
+// load. A missing file yields an empty map.
+func (pc *ParquetCache) LoadUsers(ctx context.Context) (map[string]*models.SlackUser, error) {
+	users := make(map[string]*models.SlackUser)
+
+	path := pc.readPath(pc.UsersPath())
+	table, err := pc.readTable(ctx, path)
+	if storage.IsNotExist(err) {
+		return users, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+
+	for tr.Next() {
+		rec := tr.Record()
+		cols := columns{rec: rec}
+		ids := cols.strings("user_id")
+		if ids == nil {
+			return nil, fmt.Errorf("unexpected schema in %s: missing user_id", path)
+		}
+		names, realNames, emails := cols.strings("user_name"), cols.strings("user_real_name"), cols.strings("user_email")
+		bots, deleted, external := cols.bools("is_bot"), cols.bools("is_deleted"), cols.bools("is_external")
+		cachedAts, updated := cols.strings("cached_at"), cols.strings("updated_at")
+		titles, tzs, images := cols.strings("title"), cols.strings("tz"), cols.strings("image_192")
+		tzOffsets, teams := cols.int64s("tz_offset"), cols.strings("team_id")
+
+		for i := 0; i < int(rec.NumRows()); i++ {
+			user := &models.SlackUser{
+				ID:         ids.Value(i),
+				Name:       stringValue(names, i),
+				RealName:   stringValue(realNames, i),
+				Email:      stringValue(emails, i),
+				IsBot:      boolValue(bots, i),
+				Title:      stringValue(titles, i),
+				TZ:         stringValue(tzs, i),
+				TZOffset:   int(int64Value(tzOffsets, i)),
+				Image192:   stringValue(images, i),
+				Deleted:    boolValue(deleted, i),
+				TeamID:     stringValue(teams, i),
+				IsExternal: boolValue(external, i),
+			}
+			if user.CachedAt, err = timeValue(cachedAts, i); err != nil {
+				return nil, fmt.Errorf("invalid cached_at for %s: %w", user.ID, err)
+			}
+			if user.UpdatedAt, err = timeValue(updated, i); err != nil {
+				return nil, fmt.Errorf("invalid updated_at for %s: %w", user.ID, err)
+			}
+			users[user.ID] = user
+		}
+	}
+	if err := tr.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return users, nil
+}
+
+// LoadChannels reads channels.parquet back into a map keyed by channel
+// ID. A missing file yields an empty map.
+func (pc *ParquetCache) LoadChannels(ctx context.Context) (map[string]*models.SlackChannel, error) {
+	channels := make(map[string]*models.SlackChannel)
+
+	path := pc.readPath(pc.ChannelsPath())
+	table, err := pc.readTable(ctx, path)
+	if storage.IsNotExist(err) {
+		return channels, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+
+	for tr.Next() {
+		rec := tr.Record()
+		cols := columns{rec: rec}
+		ids := cols.strings("channel_id")
+		if ids == nil {
+			return nil, fmt.Errorf("unexpected schema in %s: missing channel_id", path)
+		}
+		names, topics, purposes := cols.strings("name"), cols.strings("topic"), cols.strings("purpose")
+		members := cols.int64s("num_members")
+		private, archived := cols.bools("is_private"), cols.bools("is_archived")
+		created, cachedAts := cols.strings("created"), cols.strings("cached_at")
+
+		for i := 0; i < int(rec.NumRows()); i++ {
+			ch := &models.SlackChannel{
+				ID:         ids.Value(i),
+				Name:       stringValue(names, i),
+				Topic:      stringValue(topics, i),
+				Purpose:    stringValue(purposes, i),
+				NumMembers: int(int64Value(members, i)),
+				IsPrivate:  boolValue(private, i),
+				IsArchived: boolValue(archived, i),
+			}
+			if ch.Created, err = timeValue(created, i); err != nil {
+				return nil, fmt.Errorf("invalid created for %s: %w", ch.ID, err)
+			}
+			if ch.CachedAt, err = timeValue(cachedAts, i); err != nil {
+				return nil, fmt.Errorf("invalid cached_at for %s: %w", ch.ID, err)
+			}
+			channels[ch.ID] = ch
+		}
+	}
+	if err := tr.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return channels, nil
+}
+
+// LoadEmoji reads emoji.parquet back into an EmojiMap keyed by name. A
+// missing file yields an empty map.
+func (pc *ParquetCache) LoadEmoji(ctx context.Context) (models.EmojiMap, error) {
+	emoji := make(models.EmojiMap)
+
+	path := pc.readPath(pc.EmojiPath())
+	table, err := pc.readTable(ctx, path)
+	if storage.IsNotExist(err) {
+		return emoji, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+
+	for tr.Next() {
+		rec := tr.Record()
+		cols := columns{rec: rec}
+		names := cols.strings("name")
+		if names == nil {
+			return nil, fmt.Errorf("unexpected schema in %s: missing name", path)
+		}
+		urls, aliases, cachedAts := cols.strings("url"), cols.strings("alias_for"), cols.strings("cached_at")
+
+		for i := 0; i < int(rec.NumRows()); i++ {
+			e := &models.SlackEmoji{
+				Name:     names.Value(i),
+				URL:      stringValue(urls, i),
+				AliasFor: stringValue(aliases, i),
+			}
+			if e.CachedAt, err = timeValue(cachedAts, i); err != nil {
+				return nil, fmt.Errorf("invalid cached_at for %s: %w", e.Name, err)
+			}
+			emoji[e.Name] = e
+		}
+	}
+	if err := tr.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	return emoji, nil
+}
+
+// columns looks up Parquet columns by name, returning nil for
+// columns that are missing or of an unexpected type
+type columns struct {
+	rec arrow.Record
+}
+
+func (c columns) column(name string) arrow.Array {
+	idx := c.rec.Schema().FieldIndices(name)
+	if len(idx) == 0 {
+		return nil
+	}
+	return c.rec.Column(idx[0])
+}
+
+func (c columns) strings(name string) *array.String {
+	col, _ := c.column(name).(*array.String)
+	return col
+}
+
+func (c columns) bools(name string) *array.Boolean {
+	col, _ := c.column(name).(*array.Boolean)
+	return col
+}
+
+func (c columns) int64s(name string) *array.Int64 {
+	col, _ := c.column(name).(*array.Int64)
+	return col
+}
+
+func (c columns) lists(name string) *array.List {
+	col, _ := c.column(name).(*array.List)
+	return col
+}
+
+// stringValue returns the i-th value of a string column, or "" when null
+// or the column is missing
+func stringValue(col *array.String, i int) string {
+	if col == nil || col.IsNull(i) {
+		return ""
+	}
+	return col.Value(i)
+}
+
+// boolValue returns the i-th value of a boolean column, or false when null
+// or the column is missing
+func boolValue(col *array.Boolean, i int) bool {
+	if col == nil || col.IsNull(i) {
+		return false
+	}
+	return col.Value(i)
+}
+
+// allSet reports whether the i-th value of every boolean column is true
+func allSet(cols []*array.Boolean, i int) bool {
+	for _, col := range cols {
+		if !boolValue(col, i) {
+			return false
+		}
+	}
+	return true
+}
+
+// int64Value returns the i-th value of an int64 column, or 0 when null or
+// the column is missing
+func int64Value(col *array.Int64, i int) int64 {
+	if col == nil || col.IsNull(i) {
+		return 0
+	}
+	return col.Value(i)
+}
+
+// listValue returns the i-th value of a list<string> column, or nil when
+// null, empty or the column is missing
+func listValue(col *array.List, i int) []string {
+	if col == nil || col.IsNull(i) {
+		return nil
+	}
+	values, ok := col.ListValues().(*array.String)
+	if !ok {
+		return nil
+	}
+	start, end := col.ValueOffsets(i)
+	var out []string
+	for j := start; j < end; j++ {
+		out = append(out, values.Value(int(j)))
+	}
+	return out
+}
+
+// reactionsValue returns the i-th value of the reactions column, or nil
+// when empty or the column is missing (files before schema version 3)
+func reactionsValue(col *array.List, i int) []models.SlackReaction {
+	if col == nil || col.IsNull(i) {
+		return nil
+	}
+	values, ok := col.ListValues().(*array.Struct)
+	if !ok || values.NumField() < 3 {
+		return nil
+	}
+	names, _ := values.Field(0).(*array.String)
+	counts, _ := values.Field(1).(*array.Int64)
+	users, _ := values.Field(2).(*array.List)
+
+	start, end := col.ValueOffsets(i)
+	var out []models.SlackReaction
+	for j := int(start); j < int(end); j++ {
+		out = append(out, models.SlackReaction{
+			Emoji: stringValue(names, j),
+			Count: int(int64Value(counts, j)),
+			Users: listValue(users, j),
+		})
+	}
+	return out
+}
+
+// timeValue parses the i-th RFC 3339 value of a string column, returning
+// the zero time when null or the column is missing
+func timeValue(col *array.String, i int) (time.Time, error) {
+	s := stringValue(col, i)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+)
+
+// RedactedText replaces the text of a redacted user's messages
+const RedactedText = "[redacted]"
+
+// RedactUser rewrites the partition at path with the text and clean_text
+// of userID's messages replaced by RedactedText and the urls and
+// jira_tickets taken from that text emptied. Row counts, ids, every other
+// column and the file metadata are kept, so files of older schema versions
+// stay at their version. It returns the number of rows redacted; files
+// without any are not rewritten. The redacted messages' blocks and raw
+// payloads (column or sidecar) are dropped too.
+func (pc *ParquetCache) RedactUser(ctx context.Context, path, userID string) (int, error) {
+	table, err := pc.readTable(ctx, path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	var records []arrow.Record
+	defer func() {
+		for _, r := range records {
+			r.Release()
+		}
+	}()
+
+	redacted := make(map[string]bool)
+	tr := array.NewTableReader(table, 0)
+	defer tr.Release()
+	for tr.Next() {
+		rec := tr.Record()
+		cols := columns{rec: rec}
+		ids, users := cols.strings("message_id"), cols.strings("user_id")
+		if ids == nil || users == nil {
+			return 0, fmt.Errorf("%s has no message_id or user_id column", path)
+		}
+
+		match := make([]bool, rec.NumRows())
+		for i := range match {
+			if !users.IsNull(i) && users.Value(i) == userID {
+				match[i] = true
+				redacted[ids.Value(i)] = true
+			}
+		}
+
+		masked := make([]arrow.Array, rec.NumCols())
+		for j, field := range rec.Schema().Fields() {
+			col, err := redactColumn(field.Name, rec.Column(j), match)
+			if err != nil {
+				return 0, fmt.Errorf("failed to redact %s in %s: %w", field.Name, path, err)
+			}
+			masked[j] = col
+		}
+		records = append(records, array.NewRecord(rec.Schema(), masked, rec.NumRows()))
+		for _, col := range masked {
+			col.Release()
+		}
+	}
+	if len(redacted) == 0 {
+		return 0, nil
+	}
+
+	metadata, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		return 0, err
+	}
+	if err := pc.writeFile(path, table.Schema(), metadata, records...); err != nil {
+		return 0, err
+	}
+
+	// The payloads hold the original text too
+	payloads, err := pc.LoadSidecar(path)
+	if err != nil {
+		return 0, err
+	}
+	if payloads != nil {
+		for id := range redacted {
+			delete(payloads, id)
+		}
+		if len(payloads) == 0 {
+			err = pc.storage.Remove(SidecarPath(path))
+		} else {
+			err = pc.writeSidecar(path, payloads)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("failed to redact payloads of %s: %w", path, err)
+		}
+	}
+
+	pc.logger.Debug("redacted partition", "path", path, "user", userID, "rows", len(redacted))
+	return len(redacted), nil
+}
+
+// redactColumn returns col with the rows in match masked when the column
+// holds message text or values taken from it, or col itself (retained)
+func redactColumn(name string, col arrow.Array, match []bool) (arrow.Array, error) {
+	switch name {
+	case "text", "clean_text", "blocks", "raw_json":
+		strs, ok := col.(*array.String)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %s", col.DataType())
+		}
+		b := array.NewStringBuilder(memory.NewGoAllocator())
+		defer b.Release()
+		for i := 0; i < strs.Len(); i++ {
+			switch {
+			case strs.IsNull(i), match[i] && (name == "blocks" || name == "raw_json"):
+				b.AppendNull()
+			case match[i]:
+				b.Append(RedactedText)
+			default:
+				b.Append(strs.Value(i))
+			}
+		}
+		return b.NewArray(), nil
+
+	case "urls", "jira_tickets":
+		list, ok := col.(*array.List)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %s", col.DataType())
+		}
+		values, ok := list.ListValues().(*array.String)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %s", col.DataType())
+		}
+		b := array.NewListBuilder(memory.NewGoAllocator(), arrow.BinaryTypes.String)
+		defer b.Release()
+		vb := b.ValueBuilder().(*array.StringBuilder)
+		for i := 0; i < list.Len(); i++ {
+			if list.IsNull(i) {
+				b.AppendNull()
+				continue
+			}
+			b.Append(true)
+			if match[i] {
+				continue
+			}
+			start, end := list.ValueOffsets(i)
+			for k := start; k < end; k++ {
+				vb.Append(values.Value(int(k)))
+			}
+		}
+		return b.NewArray(), nil
+	}
+
+	col.Retain()
+	return col, nil
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestRedactUser(t *testing.T) {
+	ctx := context.Background()
+	base := filepath.Join(t.TempDir(), "raw")
+	writer := NewParquetCache(base)
+	writer.SetMetadata("token_type", "user")
+
+	path, err := writer.SaveMessages([]*models.SlackMessage{
+		{MessageID: "1700000100.000100", UserID: "U1", Text: "see PROJ-1 at <https://x.io>", Timestamp: time.Unix(1700000100, 0).UTC(),
+			JiraTickets: []string{"PROJ-1"}, URLs: []string{"https://x.io"}, CleanText: "see PROJ-1 at https://x.io",
+			Blocks:   json.RawMessage(`[{"type":"section","text":{"type":"mrkdwn","text":"see PROJ-1"}}]`),
+			UserInfo: &models.SlackUser{ID: "U1", Name: "alice"}},
+		{MessageID: "1700000110.000100", UserID: "U2", Text: "thanks", Timestamp: time.Unix(1700000110, 0).UTC(), URLs: []string{"https://y.io"}},
+		{MessageID: "1700000120.000100", UserID: "U1", Text: "one more", Timestamp: time.Unix(1700000120, 0).UTC()},
+	}, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	pc := NewParquetCache(base)
+	if n, err := pc.RedactUser(ctx, path, "U9"); err != nil || n != 0 {
+		t.Errorf("RedactUser(U9) = %d, %v; want 0 rows", n, err)
+	}
+	n, err := pc.RedactUser(ctx, path, "U1")
+	if err != nil {
+		t.Fatalf("RedactUser: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("redacted %d rows, want 2", n)
+	}
+
+	msgs, err := pc.ReadMessages(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if len(msgs) != 3 {
+		t.Fatalf("got %d rows, want 3", len(msgs))
+	}
+	first := msgs[0]
+	if first.MessageID != "1700000100.000100" || first.UserID != "U1" || first.Text != RedactedText ||
+		first.CleanText != RedactedText || first.URLs != nil || first.JiraTickets != nil || first.Blocks != nil || first.UserInfo == nil {
+		t.Errorf("redacted row = %+v, want ids and user kept, text, clean text, urls, tickets and blocks masked", first)
+	}
+	if other := msgs[1]; other.Text != "thanks" || len(other.URLs) != 1 || other.CleanText != "" {
+		t.Errorf("other user's row = %+v, want it untouched", other)
+	}
+	if msgs[2].Text != RedactedText {
+		t.Errorf("second row of U1 = %q, want %q", msgs[2].Text, RedactedText)
+	}
+
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if meta["token_type"] != "user" || meta["schema_version"] != SchemaVersion {
+		t.Errorf("metadata = %v, want the original file's kept", meta)
+	}
+}
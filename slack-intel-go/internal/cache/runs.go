@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+	"golang.org/x/sync/errgroup"
+)
+
+// RunRecord is one cache run (one cycle with --watch) in the run manifest
+type RunRecord struct {
+	ID          string       `json:"id"`
+	StartedAt   time.Time    `json:"started_at"`
+	FinishedAt  time.Time    `json:"finished_at"`
+	WindowStart time.Time    `json:"window_start"`
+	WindowEnd   time.Time    `json:"window_end"`
+	ToolVersion string       `json:"tool_version"`
+	Status      string       `json:"status"`
+	Channels    []RunChannel `json:"channels"`
+	// Files are the run-level files written: users, channels, user stats
+	Files []string `json:"files,omitempty"`
+}
+
+// RunChannel is the outcome of one channel in a run
+type RunChannel struct {
+	Channel   string `json:"channel"`
+	ChannelID string `json:"channel_id"`
+	Outcome   string `json:"outcome"`
+	Messages  int    `json:"messages"`
+	Skipped   string `json:"skipped,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Files are the partition data files written for the channel
+	Files []string `json:"files,omitempty"`
+	// FailedThreads are the thread_ts of threads cached without replies,
+	// as in the cache command's JSON summary
+	FailedThreads []string `json:"failed_threads,omitempty"`
+}
+
+// NewRunID returns a run ID that sorts by start time, e.g.
+// 20240105T101500Z-3f9a
+func NewRunID(started time.Time) string {
+	suffix := make([]byte, 2)
+	rand.Read(suffix)
+	return started.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// RunsDir returns the directory of the run manifest (cache/raw/_runs),
+// which holds one JSON file per run named by its ID
+func (pc *ParquetCache) RunsDir() string {
+	return filepath.Join(pc.basePath, "_runs")
+}
+
+// runLoaders bounds the run files LoadRuns reads at once
+const runLoaders = 16
+
+// AppendRun adds a record to the run manifest, returning the file written.
+// Each run gets a file of its own rather than a line in a shared file, so
+// runs finishing at the same time never overwrite each other's records,
+// on object storage too.
+func (pc *ParquetCache) AppendRun(run RunRecord) (string, error) {
+	path := filepath.Join(pc.RunsDir(), run.ID+".json")
+	data, err := json.Marshal(run)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode run: %w", err)
+	}
+	w, err := pc.storage.Writer(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		storage.Abort(w)
+		return "", fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := w.Close(); err != nil {
+		return "", fmt.Errorf("failed to finish %s: %w", path, err)
+	}
+	return path, nil
+}
+
+// LoadRuns reads the run manifest, oldest run first. A missing manifest
+// yields no runs.
+func (pc *ParquetCache) LoadRuns() ([]RunRecord, error) {
+	files, err := pc.storage.List(pc.RunsDir())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", pc.RunsDir(), err)
+	}
+	var paths []string
+	for _, f := range files {
+		if strings.HasSuffix(f, ".json") {
+			paths = append(paths, f)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	// Object storage answers one request at a time per file
+	runs := make([]RunRecord, len(paths))
+	var g errgroup.Group
+	g.SetLimit(runLoaders)
+	for i, path := range paths {
+		g.Go(func() error {
+			return pc.loadRun(path, &runs[i])
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	// IDs start with the UTC start time
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].ID < runs[j].ID })
+	return runs, nil
+}
+
+// loadRun reads the run file at path into run
+func (pc *ParquetCache) loadRun(path string, run *RunRecord) error {
+	r, err := pc.storage.Reader(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(run); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return nil
+}
+
+// MessageRates returns, per channel ID, the messages per day earlier runs
+// cached: the messages of the channel's successful fetches over the days
+// their windows spanned. Channels never fetched successfully are missing.
+func MessageRates(runs []RunRecord) map[string]float64 {
+	messages := make(map[string]int)
+	days := make(map[string]float64)
+	for _, run := range runs {
+		window := run.WindowEnd.Sub(run.WindowStart).Hours() / 24
+		if window <= 0 {
+			continue
+		}
+		for _, ch := range run.Channels {
+			if ch.Error != "" || ch.Skipped != "" || ch.ChannelID == "" {
+				continue
+			}
+			messages[ch.ChannelID] += ch.Messages
+			days[ch.ChannelID] += window
+		}
+	}
+	rates := make(map[string]float64, len(days))
+	for id, d := range days {
+		rates[id] = float64(messages[id]) / d
+	}
+	return rates
+}
+
+// FindRun returns the run whose ID is id or starts with it, which must be
+// unambiguous
+func FindRun(runs []RunRecord, id string) (RunRecord, error) {
+	var found []RunRecord
+	for _, run := range runs {
+		if run.ID == id {
+			return run, nil
+		}
+		if id != "" && strings.HasPrefix(run.ID, id) {
+			found = append(found, run)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return RunRecord{}, fmt.Errorf("no run %q in the manifest", id)
+	case 1:
+		return found[0], nil
+	}
+	return RunRecord{}, fmt.Errorf("run ID %q is ambiguous (%d runs match)", id, len(found))
+}
+
+// WroteFile returns the latest run that wrote path, ok false when none did
+func WroteFile(runs []RunRecord, path string) (RunRecord, bool) {
+	for i := len(runs) - 1; i >= 0; i-- {
+		for _, ch := range runs[i].Channels {
+			for _, f := range ch.Files {
+				if f == path {
+					return runs[i], true
+				}
+			}
+		}
+	}
+	return RunRecord{}, false
+}
+
+// ExplainGap says what the run manifest recorded for a gap's channel and
+// days: the outcome of the latest run whose window overlapped the gap and
+// that processed the channel, or that no recorded run did
+func ExplainGap(runs []RunRecord, g Gap) string {
+	from, to := g.From, g.To.AddDate(0, 0, 1)
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		if !run.WindowStart.Before(to) || !run.WindowEnd.After(from) {
+			continue
+		}
+		for _, ch := range run.Channels {
+			if ch.Channel != g.Channel {
+				continue
+			}
+			what := ch.Outcome
+			switch {
+			case ch.Error != "":
+				what += ": " + ch.Error
+			case ch.Skipped != "":
+				what += ": " + ch.Skipped
+			case ch.Outcome == "ok" || ch.Outcome == "empty":
+				what += ", no messages on these days"
+			}
+			return fmt.Sprintf("run %s: %s", run.ID, what)
+		}
+	}
+	return "no recorded run covered it"
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+func TestRunManifest(t *testing.T) {
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(storage.NewMemory())
+
+	if runs, err := pc.LoadRuns(); err != nil || runs != nil {
+		t.Fatalf("LoadRuns without manifest = %v, %v; want none", runs, err)
+	}
+
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	first := RunRecord{
+		ID: NewRunID(day(3)), StartedAt: day(3), WindowStart: day(1), WindowEnd: day(3), Status: "partial",
+		Channels: []RunChannel{
+			{Channel: "backend", ChannelID: "C1", Outcome: "error", Error: "ratelimited"},
+			{Channel: "general", ChannelID: "C2", Outcome: "ok", Messages: 4, Files: []string{"cache/raw/messages/dt=2024-01-02/channel=general/data.parquet"}},
+		},
+	}
+	second := RunRecord{
+		ID: NewRunID(day(4)), StartedAt: day(4), WindowStart: day(3), WindowEnd: day(4), Status: "ok",
+		Channels: []RunChannel{{Channel: "backend", ChannelID: "C1", Outcome: "empty"}},
+	}
+	for _, run := range []RunRecord{first, second} {
+		if _, err := pc.AppendRun(run); err != nil {
+			t.Fatalf("AppendRun: %v", err)
+		}
+	}
+
+	runs, err := pc.LoadRuns()
+	if err != nil {
+		t.Fatalf("LoadRuns: %v", err)
+	}
+	if len(runs) != 2 || runs[0].ID != first.ID || runs[0].Channels[0].Error != "ratelimited" || runs[1].ID != second.ID {
+		t.Fatalf("runs = %+v, want both in order", runs)
+	}
+	if !strings.HasPrefix(first.ID, "20240103T000000Z-") {
+		t.Errorf("run ID = %q, want it to start with the UTC start time", first.ID)
+	}
+
+	if run, err := FindRun(runs, "20240104"); err != nil || run.ID != second.ID {
+		t.Errorf("FindRun by prefix = %v, %v; want the second run", run.ID, err)
+	}
+	if _, err := FindRun(runs, "2024"); err == nil {
+		t.Error("FindRun with an ambiguous prefix succeeded")
+	}
+
+	if run, ok := WroteFile(runs, first.Channels[1].Files[0]); !ok || run.ID != first.ID {
+		t.Errorf("WroteFile = %v, %v; want the first run", run.ID, ok)
+	}
+
+	cases := []struct {
+		gap  Gap
+		want string
+	}{
+		{Gap{Channel: "backend", From: day(1), To: day(2)}, "run " + first.ID + ": error: ratelimited"},
+		{Gap{Channel: "backend", From: day(3), To: day(3)}, "run " + second.ID + ": empty, no messages on these days"},
+		{Gap{Channel: "random", From: day(1), To: day(1)}, "no recorded run covered it"},
+	}
+	for _, c := range cases {
+		if got := ExplainGap(runs, c.gap); got != c.want {
+			t.Errorf("ExplainGap(%s %s) = %q, want %q", c.gap.Channel, c.gap.From.Format("01-02"), got, c.want)
+		}
+	}
+}
+
+func TestConcurrentRunsKeepTheirRecords(t *testing.T) {
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(storage.NewMemory())
+
+	const runs = 8
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if _, err := pc.AppendRun(RunRecord{ID: fmt.Sprintf("20240103T000000Z-%04x", i), Status: "ok"}); err != nil {
+				t.Errorf("AppendRun: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	loaded, err := pc.LoadRuns()
+	if err != nil {
+		t.Fatalf("LoadRuns: %v", err)
+	}
+	if len(loaded) != runs || loaded[0].ID != "20240103T000000Z-0000" {
+		t.Errorf("loaded %d run(s), want all %d in ID order", len(loaded), runs)
+	}
+}
+
+func TestMessageRates(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	runs := []RunRecord{
+		{WindowStart: day(1), WindowEnd: day(3), Channels: []RunChannel{
+			{ChannelID: "C1", Messages: 100},
+			{ChannelID: "C2", Messages: 7, Error: "rate limited"},
+			{ChannelID: "C3", Skipped: "archived"},
+		}},
+		{WindowStart: day(3), WindowEnd: day(4), Channels: []RunChannel{
+			{ChannelID: "C1", Messages: 50},
+			{ChannelID: "C2", Messages: 0},
+		}},
+		{WindowStart: day(4), WindowEnd: day(4), Channels: []RunChannel{{ChannelID: "C1", Messages: 999}}},
+	}
+
+	rates := MessageRates(runs)
+	if len(rates) != 2 || rates["C1"] != 50 || rates["C2"] != 0 {
+		t.Errorf("rates = %v, want C1 at 50/day and C2 at 0", rates)
+	}
+}
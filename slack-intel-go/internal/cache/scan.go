@@ -0,0 +1,327 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/metadata"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// utcTimestampsSince is the first schema version whose timestamp column
+// is always UTC, so its string statistics order by time
+const utcTimestampsSince = 8
+
+// ErrMixedSchemas is returned by ScanMessages when the partitions it reads
+// were written with different schema versions
+var ErrMixedSchemas = errors.New("cached partitions have mixed schema versions")
+
+// SetAllowMixedSchemas lets ScanMessages read partitions written with
+// different schema versions together; columns missing from older files
+// read back empty
+func (pc *ParquetCache) SetAllowMixedSchemas(allow bool) {
+	pc.allowMixed = allow
+}
+
+// MessageFilter selects cached messages by channel, time and flags
+type MessageFilter struct {
+	// Channels are channel names, as configured or as they appear in
+	// partition paths, or IDs for {channel_id} layouts; a leading # is
+	// ignored. All channels when empty.
+	Channels []string
+	// From and To bound message timestamps to [From, To); zero bounds
+	// are open
+	From, To time.Time
+	// Flags keeps only messages with these boolean columns set
+	Flags MessageFlags
+}
+
+// MessageFlags selects messages by their boolean columns, without decoding
+// the rows of those that fail; every flag set must hold. The zero value
+// selects all messages.
+type MessageFlags struct {
+	HasFiles     bool // has_files: files attached
+	HasReactions bool // has_reactions: at least one reaction
+	// HasThread selects thread parents with replies (is_thread_parent;
+	// the has_thread column is never set)
+	HasThread bool
+}
+
+// columns returns the boolean columns the flags require
+func (f MessageFlags) columns() []string {
+	var cols []string
+	if f.HasFiles {
+		cols = append(cols, "has_files")
+	}
+	if f.HasReactions {
+		cols = append(cols, "has_reactions")
+	}
+	if f.HasThread {
+		cols = append(cols, "is_thread_parent")
+	}
+	return cols
+}
+
+// Partition reports whether p can hold messages selected by the filter,
+// from its path alone
+func (f MessageFilter) Partition(p Partition) bool {
+	if len(f.Channels) > 0 {
+		found := false
+		for _, name := range f.Channels {
+			if partitionChannel(name) == p.Channel || strings.TrimPrefix(name, "#") == p.Channel {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	end := p.Start.Add(p.Granularity.Duration(p.Start))
+	return (f.From.IsZero() || end.After(f.From)) && (f.To.IsZero() || p.Start.Before(f.To))
+}
+
+// partitionChannel is the partition path name of a --channel value
+func partitionChannel(name string) string {
+	return SanitizeChannelName(strings.TrimPrefix(name, "#"))
+}
+
+// Message reports whether a message falls in the filter's time window
+func (f MessageFilter) Message(m *models.SlackMessage) bool {
+	return (f.From.IsZero() || !m.Timestamp.Before(f.From)) && (f.To.IsZero() || m.Timestamp.Before(f.To))
+}
+
+// ScanMessages calls fn, in ListPartitions order, with each partition the
+// filter selects and its messages in the filter's window. Partitions are
+// pruned by their channel and date before any file is opened, and row
+// groups whose timestamp statistics fall outside the window, or whose
+// statistics show no row with the filter's flags, are not read.
+// Partitions without selected messages are skipped. Unless
+// SetAllowMixedSchemas is set, it fails with ErrMixedSchemas, before fn is
+// called, when the selected partitions differ in schema version.
+func (pc *ParquetCache) ScanMessages(ctx context.Context, f MessageFilter, fn func(p Partition, msgs []*models.SlackMessage) error) error {
+	return pc.scanMessages(ctx, f, pc.allowMixed, fn)
+}
+
+// scanMessages is ScanMessages with the mixed schema check chosen by the
+// caller
+func (pc *ParquetCache) scanMessages(ctx context.Context, f MessageFilter, allowMixed bool, fn func(p Partition, msgs []*models.SlackMessage) error) error {
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		return fmt.Errorf("failed to list partitions: %w", err)
+	}
+
+	selected := partitions[:0]
+	for _, p := range partitions {
+		if f.Partition(p) {
+			selected = append(selected, p)
+		}
+	}
+	if !allowMixed {
+		if err := pc.checkSchemas(selected); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range selected {
+		msgs, err := pc.readMessagesInRange(ctx, p.Path, f.From, f.To, f.Flags)
+		if err != nil {
+			return err
+		}
+		kept := msgs[:0]
+		for _, msg := range msgs {
+			if f.Message(msg) {
+				kept = append(kept, msg)
+			}
+		}
+		if len(kept) == 0 {
+			continue
+		}
+		if err := fn(p, kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSchemas fails with ErrMixedSchemas when the partitions differ in
+// schema version, reading only their footers
+func (pc *ParquetCache) checkSchemas(partitions []Partition) error {
+	var firstPath, firstVersion string
+	for _, p := range partitions {
+		metadata, err := pc.ReadFileMetadata(p.Path)
+		if err != nil {
+			return err
+		}
+		version := metadata["schema_version"]
+		switch {
+		case firstPath == "":
+			firstPath, firstVersion = p.Path, version
+		case version != firstVersion:
+			return fmt.Errorf("%w: %s is %s, %s is %s", ErrMixedSchemas,
+				firstPath, schemaLabel(firstVersion), p.Path, schemaLabel(version))
+		}
+	}
+	return nil
+}
+
+// ReadMessagesInRange is ReadMessages reading only the row groups that may
+// hold messages timestamped in [from, to). Files older than schema
+// version 8 have no usable statistics and are read whole. Rows of the
+// groups read are returned unfiltered.
+func (pc *ParquetCache) ReadMessagesInRange(ctx context.Context, path string, from, to time.Time) ([]*models.SlackMessage, error) {
+	return pc.readMessagesInRange(ctx, path, from, to, MessageFlags{})
+}
+
+// readMessagesInRange is ReadMessagesInRange keeping only the rows with
+// flags
+func (pc *ParquetCache) readMessagesInRange(ctx context.Context, path string, from, to time.Time, flags MessageFlags) ([]*models.SlackMessage, error) {
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer rdr.Close()
+
+	groups := rowGroupsWithFlags(rdr, rowGroupsInRange(rdr, from, to), flags)
+	if len(groups) == 0 {
+		pc.logger.Debug("skipped partition by statistics", "path", path)
+		return nil, nil
+	}
+
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	cols := make([]int, rdr.MetaData().Schema.NumColumns())
+	for i := range cols {
+		cols[i] = i
+	}
+	table, err := fr.ReadRowGroups(ctx, cols, groups)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer table.Release()
+
+	if skipped := rdr.NumRowGroups() - len(groups); skipped > 0 {
+		pc.logger.Debug("skipped row groups by statistics", "path", path, "skipped", skipped, "read", len(groups))
+	}
+	return messagesFromTable(table, path, flags)
+}
+
+// schemaLabel names a schema_version in messages
+func schemaLabel(version string) string {
+	if version == "" {
+		return "unversioned"
+	}
+	return "schema version " + version
+}
+
+// rowGroupsInRange returns the row groups of a messages file whose
+// timestamp min/max overlap [from, to); every group when the file's
+// statistics cannot be trusted or are missing
+func rowGroupsInRange(rdr *file.Reader, from, to time.Time) []int {
+	all := make([]int, rdr.NumRowGroups())
+	for i := range all {
+		all[i] = i
+	}
+	if from.IsZero() && to.IsZero() {
+		return all
+	}
+
+	md := rdr.MetaData()
+	version := 0
+	if v := md.KeyValueMetadata().FindValue("schema_version"); v != nil {
+		version, _ = strconv.Atoi(*v)
+	}
+	col := md.Schema.ColumnIndexByName("timestamp")
+	if version < utcTimestampsSince || col < 0 {
+		return all
+	}
+
+	var groups []int
+	for _, i := range all {
+		first, last, ok := timestampBounds(md.RowGroup(i), col)
+		if ok && ((!from.IsZero() && last.Before(from)) || (!to.IsZero() && !first.Before(to))) {
+			continue
+		}
+		groups = append(groups, i)
+	}
+	return groups
+}
+
+// rowGroupsWithFlags drops the groups whose statistics show a flag
+// column false on every row
+func rowGroupsWithFlags(rdr *file.Reader, groups []int, flags MessageFlags) []int {
+	required := flags.columns()
+	if len(required) == 0 {
+		return groups
+	}
+	md := rdr.MetaData()
+	var kept []int
+	for _, i := range groups {
+		keep := true
+		for _, name := range required {
+			col := md.Schema.ColumnIndexByName(name)
+			if col < 0 {
+				continue
+			}
+			if max, ok := boolMax(md.RowGroup(i), col); ok && !max {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			kept = append(kept, i)
+		}
+	}
+	return kept
+}
+
+// boolMax returns the largest value of a boolean column in a row group, ok
+// false when there are no usable statistics
+func boolMax(rg *metadata.RowGroupMetaData, col int) (max, ok bool) {
+	chunk, err := rg.ColumnChunk(col)
+	if err != nil {
+		return false, false
+	}
+	stats, err := chunk.Statistics()
+	if err != nil || stats == nil || !stats.HasMinMax() {
+		return false, false
+	}
+	b, isBool := stats.(*metadata.BooleanStatistics)
+	if !isBool {
+		return false, false
+	}
+	return b.Max(), true
+}
+
+// timestampBounds returns the earliest and latest timestamp recorded for
+// a row group, ok false when there are no usable statistics
+func timestampBounds(rg *metadata.RowGroupMetaData, col int) (first, last time.Time, ok bool) {
+	chunk, err := rg.ColumnChunk(col)
+	if err != nil {
+		return first, last, false
+	}
+	stats, err := chunk.Statistics()
+	if err != nil || stats == nil || !stats.HasMinMax() {
+		return first, last, false
+	}
+	ba, isBytes := stats.(*metadata.ByteArrayStatistics)
+	if !isBytes {
+		return first, last, false
+	}
+	if first, err = time.Parse(time.RFC3339, string(ba.Min())); err != nil {
+		return first, last, false
+	}
+	if last, err = time.Parse(time.RFC3339, string(ba.Max())); err != nil {
+		return first, last, false
+	}
+	return first, last, true
+}
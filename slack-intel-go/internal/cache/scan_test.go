@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// countingStorage records which files were opened for reading
+type countingStorage struct {
+	*storage.Memory
+	mu     sync.Mutex
+	opened map[string]int
+}
+
+func (s *countingStorage) Reader(path string) (storage.File, error) {
+	s.mu.Lock()
+	s.opened[path]++
+	s.mu.Unlock()
+	return s.Memory.Reader(path)
+}
+
+func TestScanMessagesPrunesPartitions(t *testing.T) {
+	store := &countingStorage{Memory: storage.NewMemory(), opened: make(map[string]int)}
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(store)
+
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.UTC) }
+	paths := make(map[string]string)
+	for _, c := range []struct {
+		channel string
+		d       int
+	}{{"general", 1}, {"general", 2}, {"general", 3}, {"random", 2}} {
+		msg := &models.SlackMessage{MessageID: c.channel + "-" + day(c.d).Format("02"), Text: "hi", Timestamp: day(c.d)}
+		path, err := pc.SaveMessages([]*models.SlackMessage{msg}, &models.SlackChannel{Name: c.channel, ID: "C-" + c.channel}, day(c.d).Format("2006-01-02"))
+		if err != nil {
+			t.Fatalf("SaveMessages: %v", err)
+		}
+		paths[msg.MessageID] = path
+	}
+	// SaveMessages reads partitions to merge into them
+	store.opened = make(map[string]int)
+
+	var got []string
+	filter := MessageFilter{Channels: []string{"#general"}, From: day(2).Add(-time.Hour), To: day(2).Add(time.Hour)}
+	err := pc.ScanMessages(context.Background(), filter, func(p Partition, msgs []*models.SlackMessage) error {
+		for _, m := range msgs {
+			got = append(got, m.MessageID)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScanMessages: %v", err)
+	}
+	if len(got) != 1 || got[0] != "general-02" {
+		t.Errorf("scanned %v, want [general-02]", got)
+	}
+
+	var opened []string
+	for path := range store.opened {
+		opened = append(opened, path)
+	}
+	sort.Strings(opened)
+	if len(opened) != 1 || opened[0] != paths["general-02"] {
+		t.Errorf("opened %v, want only %s", opened, paths["general-02"])
+	}
+}
+
+func TestReadMessagesInRangeSkipsRowGroups(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(storage.NewMemory())
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+
+	// A merge writes the new rows and the kept ones as separate row groups
+	morning := &models.SlackMessage{MessageID: "1709280000.000100", Text: "morning", Timestamp: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)}
+	evening := &models.SlackMessage{MessageID: "1709316000.000100", Text: "evening", Timestamp: time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)}
+	path, err := pc.SaveMessages([]*models.SlackMessage{morning}, channel, "2024-03-01")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if _, err := pc.MergeMessages(ctx, []*models.SlackMessage{evening}, channel, "2024-03-01"); err != nil {
+		t.Fatalf("MergeMessages: %v", err)
+	}
+
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		t.Fatalf("openParquet: %v", err)
+	}
+	defer rdr.Close()
+	if n := rdr.NumRowGroups(); n != 2 {
+		t.Fatalf("file has %d row groups, want 2", n)
+	}
+	if groups := rowGroupsInRange(rdr, time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC), time.Time{}); len(groups) != 1 {
+		t.Errorf("row groups after 17:00 = %v, want only the evening one", groups)
+	}
+	if groups := rowGroupsInRange(rdr, time.Time{}, time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)); len(groups) != 0 {
+		t.Errorf("row groups before 06:00 = %v, want none", groups)
+	}
+
+	msgs, err := pc.ReadMessagesInRange(ctx, path, time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC), time.Time{})
+	if err != nil {
+		t.Fatalf("ReadMessagesInRange: %v", err)
+	}
+	if len(msgs) != 1 || msgs[0].Text != "evening" {
+		t.Errorf("read %+v, want only the evening message", msgs)
+	}
+}
+
+func TestScanMessagesFlags(t *testing.T) {
+	ctx := context.Background()
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(storage.NewMemory())
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+	at := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	file := []models.SlackFile{{ID: "F1", Name: "report.pdf"}}
+	reaction := []models.SlackReaction{{Emoji: "eyes", Count: 1}}
+	msgs := []*models.SlackMessage{
+		{MessageID: "1709283600.000100", Text: "plain", Timestamp: at},
+		{MessageID: "1709283601.000100", Text: "file", Timestamp: at, Files: file},
+		{MessageID: "1709283602.000100", Text: "reacted", Timestamp: at, Reactions: reaction},
+		{MessageID: "1709283603.000100", Text: "file+reacted", Timestamp: at, Files: file, Reactions: reaction},
+		{MessageID: "1709283604.000100", Text: "thread", Timestamp: at, ThreadTS: "1709283604.000100", ReplyCount: 2, Reactions: reaction},
+		{MessageID: "1709283605.000100", Text: "reply", Timestamp: at, ThreadTS: "1709283604.000100", Files: file},
+	}
+	path, err := pc.SaveMessages(msgs, channel, "2024-03-01")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	tests := []struct {
+		flags MessageFlags
+		want  []string
+	}{
+		{MessageFlags{}, []string{"plain", "file", "reacted", "file+reacted", "thread", "reply"}},
+		{MessageFlags{HasFiles: true}, []string{"file", "file+reacted", "reply"}},
+		{MessageFlags{HasReactions: true}, []string{"reacted", "file+reacted", "thread"}},
+		{MessageFlags{HasThread: true}, []string{"thread"}},
+		{MessageFlags{HasFiles: true, HasReactions: true}, []string{"file+reacted"}},
+		{MessageFlags{HasThread: true, HasReactions: true}, []string{"thread"}},
+		{MessageFlags{HasThread: true, HasFiles: true}, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		err := pc.ScanMessages(ctx, MessageFilter{Flags: tt.flags}, func(p Partition, msgs []*models.SlackMessage) error {
+			for _, m := range msgs {
+				got = append(got, m.Text)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ScanMessages(%+v): %v", tt.flags, err)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("ScanMessages(%+v) = %v, want %v", tt.flags, got, tt.want)
+		}
+	}
+
+	// A row group without any file is skipped from its statistics
+	later := &models.SlackMessage{MessageID: "1709290800.000100", Text: "later", Timestamp: at.Add(2 * time.Hour)}
+	if _, err := pc.MergeMessages(ctx, []*models.SlackMessage{later}, channel, "2024-03-01"); err != nil {
+		t.Fatalf("MergeMessages: %v", err)
+	}
+	rdr, err := pc.openParquet(path)
+	if err != nil {
+		t.Fatalf("openParquet: %v", err)
+	}
+	defer rdr.Close()
+	all := rowGroupsInRange(rdr, time.Time{}, time.Time{})
+	if len(all) != 2 {
+		t.Fatalf("file has %d row groups, want 2", len(all))
+	}
+	if groups := rowGroupsWithFlags(rdr, all, MessageFlags{HasFiles: true}); len(groups) != 1 {
+		t.Errorf("row groups with files = %v, want one", groups)
+	}
+}
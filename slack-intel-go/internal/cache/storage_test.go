@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+func TestCacheOnMemoryStorage(t *testing.T) {
+	store := storage.NewMemory()
+	pc := NewParquetCache("cache/raw")
+	pc.SetStorage(store)
+
+	messages := []*models.SlackMessage{
+		{MessageID: "1700000000.000100", UserID: "U1", Text: "hello", Timestamp: time.Unix(1700000000, 0)},
+	}
+	path, err := pc.SaveMessages(messages, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if _, err := pc.SaveUsers(map[string]*models.SlackUser{"U1": {ID: "U1", Name: "alice"}}); err != nil {
+		t.Fatalf("SaveUsers: %v", err)
+	}
+
+	if size, err := pc.Size(path); err != nil || size == 0 {
+		t.Errorf("Size = %d, %v; want a non-empty file", size, err)
+	}
+
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].Channel != "general" || partitions[0].Path != path {
+		t.Errorf("partitions = %+v, want general at %s", partitions, path)
+	}
+
+	users, err := pc.LoadUsers(context.Background())
+	if err != nil {
+		t.Fatalf("LoadUsers: %v", err)
+	}
+	if users["U1"] == nil || users["U1"].Name != "alice" {
+		t.Errorf("users = %+v, want U1 alice", users)
+	}
+
+	bad := filepath.Join("cache/raw", "messages", "dt=2023-11-15", "channel=general", "data.parquet")
+	w, err := store.Writer(bad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("not a parquet file"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err := pc.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(reports) != 3 {
+		t.Fatalf("got %d reports, want 3", len(reports))
+	}
+	for _, r := range reports {
+		if (r.Path == bad) == r.OK() {
+			t.Errorf("report %+v has unexpected status", r)
+		}
+	}
+
+	dest, err := pc.Quarantine(bad)
+	if err != nil {
+		t.Fatalf("Quarantine: %v", err)
+	}
+	if ok, _ := store.Exists(bad); ok {
+		t.Errorf("%s still present after quarantine", bad)
+	}
+	if ok, _ := store.Exists(dest); !ok {
+		t.Errorf("quarantined file missing at %s", dest)
+	}
+
+	reports, err = pc.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Errorf("got %d reports after quarantine, want 2", len(reports))
+	}
+}
@@ -0,0 +1,190 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// DefaultNameTemplate is the Hive layout read by the Python tooling
+const DefaultNameTemplate = "dt={date}/channel={channel}"
+
+// templatePlaceholder matches a {name} placeholder in a name template
+var templatePlaceholder = regexp.MustCompile(`\{([a-z_]*)\}`)
+
+// templateFields are the placeholders a name template may use
+var templateFields = map[string]bool{
+	"date":       true, // partition key, e.g. 2023-11-14
+	"channel":    true,
+	"channel_id": true,
+	"year":       true,
+	"month":      true,
+}
+
+// maxChannelDirLen caps a sanitized channel name in bytes
+const maxChannelDirLen = 80
+
+// SanitizeChannelName turns a channel name into the {channel} path segment:
+// every run of characters outside [A-Za-z0-9_-] (slashes, spaces, accented
+// letters) replaced by a single _, keeping the case. Names longer than
+// maxChannelDirLen are cut and end in a hash of the full name so they stay
+// distinct, as do names with nothing left but _. Sanitized names come back
+// unchanged, so directories written before sanitizing, such as
+// channel=channel_C0123ABCD, read as the same channel.
+func SanitizeChannelName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			r = '_'
+		}
+		if r == '_' && strings.HasSuffix(b.String(), "_") {
+			continue
+		}
+		b.WriteRune(r)
+	}
+
+	s := b.String()
+	switch {
+	case name == "":
+		return ""
+	case strings.Trim(s, "_") == "":
+		return "channel_" + nameHash(name)
+	case len(s) > maxChannelDirLen:
+		return s[:maxChannelDirLen-9] + "_" + nameHash(name)
+	}
+	return s
+}
+
+// nameHash is a short hex digest of a channel name
+func nameHash(name string) string {
+	sum := sha256.Sum256([]byte(name))
+	return hex.EncodeToString(sum[:4])
+}
+
+// NameTemplate names partition directories under messages/, e.g.
+// "channel={channel}/dt={date}". Each partition's data file lives in the
+// rendered directory.
+type NameTemplate struct {
+	raw    string
+	fields map[string]bool
+	// match parses a rendered path back; its groups follow names
+	match *regexp.Regexp
+	names []string
+}
+
+// ParseNameTemplate validates a --name-template value. A template needs a
+// channel ({channel} or {channel_id}) and a date ({date}, or {year} with
+// {month}), and may use each placeholder once.
+func ParseNameTemplate(s string) (*NameTemplate, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, fmt.Errorf("name template is empty")
+	}
+	for _, segment := range strings.Split(s, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return nil, fmt.Errorf("invalid name template %q: empty or relative path segment", s)
+		}
+	}
+
+	if rest := templatePlaceholder.ReplaceAllString(s, ""); strings.ContainsAny(rest, "{}") {
+		return nil, fmt.Errorf("invalid name template %q: malformed placeholder", s)
+	}
+
+	t := &NameTemplate{raw: s, fields: make(map[string]bool)}
+	pattern := "^"
+	last := 0
+	for _, loc := range templatePlaceholder.FindAllStringSubmatchIndex(s, -1) {
+		name := s[loc[2]:loc[3]]
+		if !templateFields[name] {
+			return nil, fmt.Errorf("invalid name template %q: unknown placeholder {%s}", s, name)
+		}
+		if t.fields[name] {
+			return nil, fmt.Errorf("invalid name template %q: {%s} used more than once", s, name)
+		}
+		t.fields[name] = true
+		t.names = append(t.names, name)
+		pattern += regexp.QuoteMeta(s[last:loc[0]]) + "([^/]+)"
+		last = loc[1]
+	}
+	pattern += regexp.QuoteMeta(s[last:]) + "$"
+	switch {
+	case !t.fields["channel"] && !t.fields["channel_id"]:
+		return nil, fmt.Errorf("invalid name template %q: needs {channel} or {channel_id}", s)
+	case !t.fields["date"] && !(t.fields["year"] && t.fields["month"]):
+		return nil, fmt.Errorf("invalid name template %q: needs {date}, or {year} and {month}", s)
+	}
+
+	t.match = regexp.MustCompile(pattern)
+	return t, nil
+}
+
+// String returns the template as given
+func (t *NameTemplate) String() string {
+	return t.raw
+}
+
+// Validate checks that partitions of granularity g get distinct names.
+// Without {date} only month partitions are told apart.
+func (t *NameTemplate) Validate(g Granularity) error {
+	if !t.fields["date"] && g != GranularityMonth {
+		return fmt.Errorf("name template %q has no {date}, so %s partitions would overwrite each other (use --partition-granularity month)", t.raw, g)
+	}
+	return nil
+}
+
+// Render returns the partition directory for a channel's partition key,
+// relative to messages/. {channel} is the sanitized channel name.
+func (t *NameTemplate) Render(partition string, channel *models.SlackChannel) (string, error) {
+	values := map[string]string{
+		"date":       partition,
+		"channel":    SanitizeChannelName(channel.Name),
+		"channel_id": channel.ID,
+	}
+	if t.fields["year"] || t.fields["month"] {
+		_, start, err := ParsePartitionKey(partition)
+		if err != nil {
+			return "", err
+		}
+		values["year"] = start.Format("2006")
+		values["month"] = start.Format("01")
+	}
+
+	var missing error
+	dir := templatePlaceholder.ReplaceAllStringFunc(t.raw, func(m string) string {
+		name := m[1 : len(m)-1]
+		v := values[name]
+		if v == "" || strings.Contains(v, "/") {
+			missing = fmt.Errorf("cannot render {%s} of name template %q from %q", name, t.raw, v)
+		}
+		return v
+	})
+	return dir, missing
+}
+
+// parse reads the partition key and channel back from a partition
+// directory relative to messages/. The channel is the sanitized {channel}
+// value when the template has one, the {channel_id} otherwise.
+func (t *NameTemplate) parse(rel string) (key, channel string, ok bool) {
+	m := t.match.FindStringSubmatch(path.Clean(rel))
+	if m == nil {
+		return "", "", false
+	}
+	values := make(map[string]string, len(t.names))
+	for i, name := range t.names {
+		values[name] = m[i+1]
+	}
+
+	key = values["date"]
+	if key == "" {
+		key = values["year"] + "-" + values["month"]
+	}
+	channel = SanitizeChannelName(values["channel"])
+	if channel == "" {
+		channel = values["channel_id"]
+	}
+	return key, channel, true
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestNameTemplateRender(t *testing.T) {
+	channel := &models.SlackChannel{Name: "general", ID: "C0123"}
+
+	tests := []struct {
+		template  string
+		partition string
+		want      string
+	}{
+		{DefaultNameTemplate, "2023-11-14", "dt=2023-11-14/channel=general"},
+		{"channel={channel}/dt={date}", "2023-11-14T15", "channel=general/dt=2023-11-14T15"},
+		{"year={year}/month={month}/channel_id={channel_id}", "2023-11", "year=2023/month=11/channel_id=C0123"},
+		{"{channel_id}/{year}/{date}", "2023-11-14", "C0123/2023/2023-11-14"},
+	}
+	for _, tt := range tests {
+		tmpl, err := ParseNameTemplate(tt.template)
+		if err != nil {
+			t.Fatalf("ParseNameTemplate(%q): %v", tt.template, err)
+		}
+		got, err := tmpl.Render(tt.partition, channel)
+		if err != nil {
+			t.Fatalf("Render(%q, %q): %v", tt.template, tt.partition, err)
+		}
+		if got != tt.want {
+			t.Errorf("Render(%q, %q) = %q, want %q", tt.template, tt.partition, got, tt.want)
+		}
+	}
+}
+
+func TestSanitizeChannelName(t *testing.T) {
+	long := strings.Repeat("incident-", 12)
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"general", "general"},
+		{"Team-Backend_2", "Team-Backend_2"},
+		{"team/réseau & ops", "team_r_seau_ops"},
+		{"../../etc", "_etc"},
+		{"channel_C0123", "channel_C0123"},
+		{"", ""},
+		{"日本語", "channel_" + nameHash("日本語")},
+		{long, long[:71] + "_" + nameHash(long)},
+	}
+	for _, tt := range tests {
+		got := SanitizeChannelName(tt.name)
+		if got != tt.want {
+			t.Errorf("SanitizeChannelName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+		if len(got) > maxChannelDirLen {
+			t.Errorf("SanitizeChannelName(%q) is %d bytes, want at most %d", tt.name, len(got), maxChannelDirLen)
+		}
+		if again := SanitizeChannelName(got); again != got {
+			t.Errorf("SanitizeChannelName(%q) = %q, want it unchanged", got, again)
+		}
+	}
+	if SanitizeChannelName("日本語") == SanitizeChannelName("中文") {
+		t.Error("names with nothing left after sanitizing collide")
+	}
+	if SanitizeChannelName(long+"a") == SanitizeChannelName(long+"b") {
+		t.Error("long names sharing a prefix collide")
+	}
+}
+
+func TestSanitizedChannelPartitions(t *testing.T) {
+	ctx := context.Background()
+	basePath := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(basePath)
+
+	channel := &models.SlackChannel{Name: "Ops/Réseau", ID: "C1"}
+	msgs := []*models.SlackMessage{{MessageID: "1700000000.000100", Text: "hi", Timestamp: time.Unix(1700000000, 0)}}
+	path, err := pc.SaveMessages(msgs, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if want := filepath.Join(basePath, "messages", "dt=2023-11-14", "channel=Ops_R_seau", "data.parquet"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].Channel != "Ops_R_seau" {
+		t.Fatalf("partitions = %+v, want one of Ops_R_seau", partitions)
+	}
+	for _, name := range []string{"Ops/Réseau", "#Ops_R_seau"} {
+		if !(MessageFilter{Channels: []string{name}}).Partition(partitions[0]) {
+			t.Errorf("filter on %q does not match %s", name, partitions[0].Channel)
+		}
+	}
+
+	// The original name survives in channel_name, also across rewrites
+	if err := pc.RewriteMessages(ctx, path, msgs); err != nil {
+		t.Fatalf("RewriteMessages: %v", err)
+	}
+	stored, err := pc.ReadStoredFlags(ctx, path)
+	if err != nil {
+		t.Fatalf("ReadStoredFlags: %v", err)
+	}
+	if got := stored["1700000000.000100"].ChannelName; got != "Ops/Réseau" {
+		t.Errorf("channel_name = %q, want Ops/Réseau", got)
+	}
+}
+
+func TestChannelPartitionsInOldLayout(t *testing.T) {
+	ctx := context.Background()
+	basePath := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(basePath)
+
+	// A partition written before sanitizing, under the unsanitized name
+	channel := &models.SlackChannel{Name: "channel_C0123ABCD", ID: "C0123ABCD"}
+	old := []*models.SlackMessage{{MessageID: "1700000000.000100", Text: "old", Timestamp: time.Unix(1700000000, 0)}}
+	oldPath := filepath.Join(basePath, "messages", "dt=2023-11-14", "channel=channel_C0123ABCD", "data.parquet")
+	record := pc.messageRecord(old, channel.Name, false, nil)
+	defer record.Release()
+	if err := pc.writeFile(oldPath, pc.schema, pc.metadata, record); err != nil {
+		t.Fatalf("writeFile: %v", err)
+	}
+
+	// A later run writes into the same directory, not a sibling
+	msgs := []*models.SlackMessage{{MessageID: "1700000100.000100", Text: "new", Timestamp: time.Unix(1700000100, 0)}}
+	path, err := pc.SaveMessages(msgs, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if path != oldPath {
+		t.Errorf("path = %s, want the old layout's %s", path, oldPath)
+	}
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].Channel != "channel_C0123ABCD" {
+		t.Fatalf("partitions = %+v, want the old directory only", partitions)
+	}
+	n := 0
+	err = pc.ScanMessages(ctx, MessageFilter{}, func(p Partition, msgs []*models.SlackMessage) error {
+		n += len(msgs)
+		return nil
+	})
+	if err != nil || n != 2 {
+		t.Errorf("ScanMessages = %d, %v; want 2 messages without duplicates", n, err)
+	}
+}
+
+func TestParseNameTemplateRejects(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"dt={date}",                     // no channel
+		"channel={channel}",             // no date
+		"channel={channel}/y={year}",    // year without month
+		"dt={date}/channel={team}",      // unknown placeholder
+		"dt={date}/{channel}/{channel}", // repeated placeholder
+		"dt={date}//channel={channel}",  // empty segment
+		"../dt={date}/channel={channel}",
+		"dt={Date}/channel={channel}",
+	} {
+		if _, err := ParseNameTemplate(s); err == nil {
+			t.Errorf("ParseNameTemplate(%q) succeeded, want an error", s)
+		}
+	}
+}
+
+func TestNameTemplateValidateGranularity(t *testing.T) {
+	tmpl, err := ParseNameTemplate("year={year}/month={month}/channel={channel}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Validate(GranularityMonth); err != nil {
+		t.Errorf("Validate(month) = %v, want nil", err)
+	}
+	if err := tmpl.Validate(GranularityDay); err == nil {
+		t.Error("Validate(day) succeeded, want an error: days would share a file")
+	}
+}
+
+func TestListPartitionsWithNameTemplate(t *testing.T) {
+	basePath := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(basePath)
+	tmpl, err := ParseNameTemplate("channel={channel}/dt={date}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc.SetNameTemplate(tmpl)
+
+	msgs := []*models.SlackMessage{{MessageID: "1700000000.000100", Text: "hi", Timestamp: time.Unix(1700000000, 0)}}
+	path, err := pc.SaveMessages(msgs, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if want := filepath.Join(basePath, "messages", "channel=general", "dt=2023-11-14", "data.parquet"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].Key != "2023-11-14" || partitions[0].Channel != "general" || partitions[0].Granularity != GranularityDay {
+		t.Errorf("partitions = %+v, want one day partition of general", partitions)
+	}
+
+	// The default layout does not see files written under another template
+	if others, err := NewParquetCache(basePath).ListPartitions(); err != nil || len(others) != 0 {
+		t.Errorf("default ListPartitions = %+v, %v; want none", others, err)
+	}
+}
+
+func TestPartFileName(t *testing.T) {
+	ctx := context.Background()
+	basePath := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(basePath)
+	pc.SetPartFileName("part-0.snappy.parquet")
+
+	msgs := []*models.SlackMessage{{MessageID: "1700000000.000100", Text: "hi", Timestamp: time.Unix(1700000000, 0)}}
+	path, err := pc.SaveMessages(msgs, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	if want := filepath.Join(basePath, "messages", "dt=2023-11-14", "channel=general", "part-0.snappy.parquet"); path != want {
+		t.Errorf("path = %s, want %s", path, want)
+	}
+
+	partitions, err := pc.ListPartitions()
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if len(partitions) != 1 || partitions[0].Path != path {
+		t.Fatalf("partitions = %+v, want the one written", partitions)
+	}
+	got, err := pc.ReadMessages(ctx, partitions[0].Path)
+	if err != nil || len(got) != 1 {
+		t.Errorf("ReadMessages = %d messages, %v; want 1", len(got), err)
+	}
+
+	// Files under another name are not partitions of this cache
+	if others, err := NewParquetCache(basePath).ListPartitions(); err != nil || len(others) != 0 {
+		t.Errorf("default ListPartitions = %+v, %v; want none", others, err)
+	}
+}
+
+func TestParsePartFileName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr bool
+	}{
+		{"", DefaultPartFileName, false},
+		{"part-0.snappy.parquet", "part-0.snappy.parquet", false},
+		{"data.csv", "", true},
+		{"data.parquet.tmp", "", true},
+		{"out/data.parquet", "", true},
+		{`out\data.parquet`, "", true},
+		{".parquet", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePartFileName(tt.name)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParsePartFileName(%q) = %q, %v; want %q, error %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
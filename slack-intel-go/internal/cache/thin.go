@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sort"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// Enrichments a cache run can skip. A partition written without one is
+// "thin": its metadata holds the enrichment's key with the value skipped,
+// so cache stats can list it and cache enrich can top it up.
+const (
+	// ThinThreads marks partitions written without thread replies
+	ThinThreads = "threads"
+	// ThinUsers marks partitions written without user info
+	ThinUsers = "users"
+)
+
+// thinSkipped is the metadata value of a skipped enrichment
+const thinSkipped = "skipped"
+
+// MarkThin records on every file written from now on that it lacks the
+// given enrichments (ThinThreads, ThinUsers)
+func (pc *ParquetCache) MarkThin(missing ...string) {
+	for _, m := range missing {
+		pc.metadata[m] = thinSkipped
+	}
+}
+
+// Thin returns the enrichments a file's metadata says it lacks, in the
+// order ThinThreads, ThinUsers
+func Thin(metadata map[string]string) []string {
+	var missing []string
+	for _, m := range []string{ThinThreads, ThinUsers} {
+		if metadata[m] == thinSkipped {
+			missing = append(missing, m)
+		}
+	}
+	return missing
+}
+
+// carryThin returns metadata plus the thin marks of stored, for a file
+// that keeps rows of the stored one
+func carryThin(metadata, stored map[string]string) map[string]string {
+	missing := Thin(stored)
+	if len(missing) == 0 {
+		return metadata
+	}
+	merged := maps.Clone(metadata)
+	for _, m := range missing {
+		merged[m] = thinSkipped
+	}
+	return merged
+}
+
+// keepsRows reports whether a merge keeps any stored row, i.e. not every
+// stored message_id is replaced
+func keepsRows(stored map[string]storedVersion, replaced map[string]bool) bool {
+	for id := range stored {
+		if !replaced[id] {
+			return true
+		}
+	}
+	return false
+}
+
+// EnrichMessages rewrites a partition like RewriteMessages with messages
+// whose missing enrichments were filled in, removing the thin marks of
+// those in filled (ThinThreads, ThinUsers). The payloads of messages go to
+// the sidecar too when the file was written with RawSidecar.
+func (pc *ParquetCache) EnrichMessages(ctx context.Context, path string, messages []*models.SlackMessage, filled ...string) error {
+	metadata, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		return err
+	}
+	if err := pc.rewriteMessages(ctx, path, messages, filled); err != nil {
+		return err
+	}
+	if RawMode(metadata["raw"]) == RawSidecar && len(rawPayloads(messages)) > 0 {
+		return pc.mergeSidecar(path, messages, nil)
+	}
+	return nil
+}
+
+// MissingReplies returns the thread_ts of the thread parents among
+// messages with a reply_count above the replies found among messages,
+// sorted. With excludeBots, for messages cached without bot replies, a
+// reply_count cannot be checked and a parent is missing replies only when
+// none of its replies is found.
+func MissingReplies(messages []*models.SlackMessage, excludeBots bool) []string {
+	replies := make(map[string]int)
+	for _, msg := range messages {
+		if msg.IsThreadReply() {
+			replies[msg.ThreadTS]++
+		}
+	}
+	var missing []string
+	for _, msg := range messages {
+		if !msg.IsThreadParent() || msg.ReplyCount <= replies[msg.MessageID] {
+			continue
+		}
+		if !excludeBots || replies[msg.MessageID] == 0 {
+			missing = append(missing, msg.MessageID)
+		}
+	}
+	sort.Strings(missing)
+	return slices.Compact(missing)
+}
+
+// MissingUsers returns the IDs of the authors of messages stored without
+// user info, once each and sorted
+func MissingUsers(messages []*models.SlackMessage) []string {
+	var missing []string
+	for _, msg := range messages {
+		if msg.UserID != "" && msg.UserInfo == nil {
+			missing = append(missing, msg.UserID)
+		}
+	}
+	sort.Strings(missing)
+	return slices.Compact(missing)
+}
@@ -0,0 +1,127 @@
+package cache
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestThinMarksSurviveMerges(t *testing.T) {
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+	msg := func(id string) *models.SlackMessage {
+		return &models.SlackMessage{MessageID: id, Text: "hi", Timestamp: time.Unix(1700000000, 0)}
+	}
+	base := filepath.Join(t.TempDir(), "raw")
+
+	thin := NewParquetCache(base)
+	thin.MarkThin(ThinThreads, ThinUsers)
+	path, err := thin.SaveMessages([]*models.SlackMessage{msg("1700000000.000100"), msg("1700000000.000200")}, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	full := NewParquetCache(base)
+	steps := []struct {
+		name     string
+		messages []*models.SlackMessage
+		want     []string
+	}{
+		// One thin row is kept, so the file stays thin
+		{"partial", []*models.SlackMessage{msg("1700000000.000100")}, []string{ThinThreads, ThinUsers}},
+		// Every stored row is replaced by an enriched copy
+		{"complete", []*models.SlackMessage{msg("1700000000.000100"), msg("1700000000.000200")}, nil},
+	}
+	for _, step := range steps {
+		if _, err := full.SaveMessages(step.messages, channel, "2023-11-14"); err != nil {
+			t.Fatalf("%s: SaveMessages: %v", step.name, err)
+		}
+		meta, err := full.ReadFileMetadata(path)
+		if err != nil {
+			t.Fatalf("%s: ReadFileMetadata: %v", step.name, err)
+		}
+		if got := Thin(meta); !reflect.DeepEqual(got, step.want) {
+			t.Errorf("%s: Thin = %v, want %v", step.name, got, step.want)
+		}
+	}
+}
+
+func TestLikeWritesAsStoredPartition(t *testing.T) {
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	stored := map[string]string{"redacted": "true", "exclude_bots": "true", "raw": "sidecar", "schema_version": "1"}
+
+	msg := &models.SlackMessage{MessageID: "1700000000.000100", Text: "hi", Timestamp: time.Unix(1700000000, 0),
+		Raw: []byte(`{"ts":"1700000000.000100"}`)}
+	path, err := pc.Like(stored).SaveMessages([]*models.SlackMessage{msg}, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if meta["redacted"] != "true" || meta["exclude_bots"] != "true" || meta["raw"] != "sidecar" || meta["schema_version"] != SchemaVersion {
+		t.Errorf("metadata = %v, want the stored partition's settings in the current schema", meta)
+	}
+	if payloads, err := pc.LoadSidecar(path); err != nil || len(payloads) != 1 {
+		t.Errorf("sidecar = %v, %v; want the payload", payloads, err)
+	}
+	if pc.metadata["redacted"] != "" || pc.raw != RawOff {
+		t.Errorf("original cache changed: %v, %q", pc.metadata, pc.raw)
+	}
+}
+
+func TestEnrichMessagesClearsFilledMarks(t *testing.T) {
+	channel := &models.SlackChannel{Name: "general", ID: "C1"}
+	pc := NewParquetCache(filepath.Join(t.TempDir(), "raw"))
+	pc.MarkThin(ThinThreads, ThinUsers)
+	parent := &models.SlackMessage{MessageID: "1700000000.000100", UserID: "U1", Text: "incident", ThreadTS: "1700000000.000100",
+		ReplyCount: 2, Timestamp: time.Unix(1700000000, 0)}
+	reply := &models.SlackMessage{MessageID: "1700000050.000100", UserID: "U2", Text: "on it", ThreadTS: "1700000000.000100",
+		Timestamp: time.Unix(1700000050, 0)}
+	path, err := pc.SaveMessages([]*models.SlackMessage{parent, reply}, channel, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	msgs, err := pc.ReadMessages(context.Background(), path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if got := MissingReplies(msgs, false); !reflect.DeepEqual(got, []string{"1700000000.000100"}) {
+		t.Errorf("MissingReplies = %v, want the parent", got)
+	}
+	// The other replies may have been bots dropped by exclude_bots
+	if got := MissingReplies(msgs, true); got != nil {
+		t.Errorf("MissingReplies with bots excluded = %v, want none", got)
+	}
+	if got := MissingUsers(msgs); !reflect.DeepEqual(got, []string{"U1", "U2"}) {
+		t.Errorf("MissingUsers = %v, want U1 and U2", got)
+	}
+
+	// Users filled in, one reply still missing: only the users mark goes
+	for _, msg := range msgs {
+		msg.UserInfo = &models.SlackUser{ID: msg.UserID, Name: "user-" + msg.UserID}
+	}
+	if err := pc.EnrichMessages(context.Background(), path, msgs, ThinUsers); err != nil {
+		t.Fatalf("EnrichMessages: %v", err)
+	}
+	meta, err := pc.ReadFileMetadata(path)
+	if err != nil {
+		t.Fatalf("ReadFileMetadata: %v", err)
+	}
+	if got := Thin(meta); !reflect.DeepEqual(got, []string{ThinThreads}) {
+		t.Errorf("Thin = %v, want threads only", got)
+	}
+	stored, err := pc.ReadMessages(context.Background(), path)
+	if err != nil {
+		t.Fatalf("ReadMessages: %v", err)
+	}
+	if got := MissingUsers(stored); got != nil {
+		t.Errorf("MissingUsers after enrich = %v, want none", got)
+	}
+}
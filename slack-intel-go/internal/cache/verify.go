@@ -0,0 +1,179 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/apache/arrow/go/v14/parquet/file"
+	"github.com/apache/arrow/go/v14/parquet/pqarrow"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/storage"
+)
+
+// CorruptDir is the folder (under the cache base path) unreadable files are moved to
+const CorruptDir = ".corrupt"
+
+// FileReport describes the integrity check of a single Parquet file
+type FileReport struct {
+	Path       string
+	Rows       int64
+	Err        error
+	Unreadable bool // true when the file could not be decoded at all
+}
+
+// OK reports whether the file passed verification
+func (r FileReport) OK() bool {
+	return r.Err == nil
+}
+
+// Verify checks every Parquet file in the cache (the partitions and the
+// users, stats, channels, emoji, JIRA index and alerts files) for
+// readability and schema drift. Files in CorruptDir are skipped.
+func (pc *ParquetCache) Verify(ctx context.Context) ([]FileReport, error) {
+	var reports []FileReport
+
+	files, err := pc.storage.List(pc.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk cache: %w", err)
+	}
+	sides := map[string]*arrow.Schema{
+		pc.UsersPath():     createUserSchema(),
+		pc.UserStatsPath(): createUserStatsSchema(),
+		pc.ChannelsPath():  createChannelSchema(),
+		pc.EmojiPath():     createEmojiSchema(),
+		pc.JiraIndexPath(): createJiraIndexSchema(),
+		pc.AlertsPath():    createAlertSchema(),
+	}
+	corrupt := filepath.ToSlash(filepath.Join(pc.basePath, CorruptDir)) + "/"
+	for _, path := range files {
+		if _, side := sides[filepath.FromSlash(path)]; side || strings.HasPrefix(filepath.ToSlash(path), corrupt) {
+			continue
+		}
+		if strings.HasSuffix(path, ".parquet") {
+			reports = append(reports, verifyFile(ctx, path, pc.schema, pc.openParquet))
+		}
+	}
+
+	for path, schema := range sides {
+		path = pc.readPath(path)
+		if ok, err := pc.storage.Exists(path); err == nil && ok {
+			reports = append(reports, verifyFile(ctx, path, schema, pc.openParquet))
+		}
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Path < reports[j].Path
+	})
+
+	return reports, nil
+}
+
+// VerifyFile opens a local Parquet file, reads every row group and compares
+// its schema against the expected one
+func VerifyFile(ctx context.Context, path string, expected *arrow.Schema) FileReport {
+	return verifyFile(ctx, path, expected, func(path string) (*file.Reader, error) {
+		return file.OpenParquetFile(path, false)
+	})
+}
+
+func verifyFile(ctx context.Context, path string, expected *arrow.Schema, open func(string) (*file.Reader, error)) FileReport {
+	report := FileReport{Path: path}
+
+	rdr, err := open(path)
+	if err != nil {
+		report.Err = fmt.Errorf("unreadable: %w", err)
+		report.Unreadable = true
+		return report
+	}
+	defer rdr.Close()
+
+	report.Rows = rdr.NumRows()
+
+	fr, err := pqarrow.NewFileReader(rdr, pqarrow.ArrowReadProperties{}, memory.NewGoAllocator())
+	if err != nil {
+		report.Err = fmt.Errorf("unreadable: %w", err)
+		report.Unreadable = true
+		return report
+	}
+
+	actual, err := fr.Schema()
+	if err != nil {
+		report.Err = fmt.Errorf("unreadable schema: %w", err)
+		report.Unreadable = true
+		return report
+	}
+	if err := compareSchema(expected, actual); err != nil {
+		report.Err = fmt.Errorf("schema mismatch: %w", err)
+		return report
+	}
+
+	table, err := fr.ReadTable(ctx)
+	if err != nil {
+		report.Err = fmt.Errorf("unreadable data: %w", err)
+		report.Unreadable = true
+		return report
+	}
+	defer table.Release()
+
+	if table.NumRows() != report.Rows {
+		report.Err = fmt.Errorf("row count mismatch: metadata %d, data %d", report.Rows, table.NumRows())
+	}
+
+	return report
+}
+
+// compareSchema checks field names and types, ignoring nested field names
+// that Parquet renames on round-trip (e.g. list "item" vs "element")
+func compareSchema(expected, actual *arrow.Schema) error {
+	if len(expected.Fields()) != len(actual.Fields()) {
+		return fmt.Errorf("expected %d columns, found %d", len(expected.Fields()), len(actual.Fields()))
+	}
+
+	for i, want := range expected.Fields() {
+		got := actual.Field(i)
+		if want.Name != got.Name {
+			return fmt.Errorf("column %d: expected %q, found %q", i, want.Name, got.Name)
+		}
+		if !sameType(want.Type, got.Type) {
+			return fmt.Errorf("column %q: expected %s, found %s", want.Name, want.Type, got.Type)
+		}
+	}
+
+	return nil
+}
+
+func sameType(want, got arrow.DataType) bool {
+	if want.ID() != got.ID() {
+		return false
+	}
+	if wl, ok := want.(*arrow.ListType); ok {
+		return sameType(wl.Elem(), got.(*arrow.ListType).Elem())
+	}
+	return true
+}
+
+// Quarantine moves a file into CorruptDir, keeping its path relative to
+// the cache root so the original partition can be identified
+func (pc *ParquetCache) Quarantine(path string) (string, error) {
+	root := filepath.Dir(pc.basePath)
+	if rel, err := filepath.Rel(pc.basePath, path); err == nil && !strings.HasPrefix(rel, "..") {
+		root = pc.basePath
+	}
+
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+
+	dest := filepath.Join(pc.basePath, CorruptDir, rel)
+	if err := storage.Move(pc.storage, path, dest); err != nil {
+		return "", fmt.Errorf("failed to quarantine %s: %w", path, err)
+	}
+	pc.logger.Warn("quarantined file", "path", path, "dest", dest)
+
+	return dest, nil
+}
@@ -0,0 +1,87 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestVerifyDetectsCorruptFiles(t *testing.T) {
+	basePath := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(basePath)
+
+	messages := []*models.SlackMessage{
+		{MessageID: "1700000000.000100", UserID: "U1", Text: "PROJ-1 is done", Timestamp: time.Unix(1700000000, 0), JiraTickets: []string{"PROJ-1"}},
+		{MessageID: "1700000001.000100", Text: "hello", Timestamp: time.Unix(1700000001, 0)},
+	}
+	good, err := pc.SaveMessages(messages, &models.SlackChannel{Name: "general", ID: "C1"}, "2023-11-14")
+	if err != nil {
+		t.Fatalf("SaveMessages: %v", err)
+	}
+
+	bad := filepath.Join(basePath, "messages", "dt=2023-11-15", "channel=general", "data.parquet")
+	if err := os.MkdirAll(filepath.Dir(bad), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(bad, []byte("not a parquet file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	reports, err := pc.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("got %d reports, want 2", len(reports))
+	}
+
+	byPath := map[string]FileReport{}
+	for _, r := range reports {
+		byPath[r.Path] = r
+	}
+	if r := byPath[good]; !r.OK() || r.Rows != 2 {
+		t.Errorf("good file report = %+v, want OK with 2 rows", r)
+	}
+	if r := byPath[bad]; r.OK() || !r.Unreadable {
+		t.Errorf("bad file report = %+v, want unreadable", r)
+	}
+
+	dest, err := pc.Quarantine(bad)
+	if err != nil {
+		t.Fatalf("Quarantine: %v", err)
+	}
+	if !strings.Contains(dest, CorruptDir) {
+		t.Errorf("quarantined to %s, want under %s", dest, CorruptDir)
+	}
+	if _, err := os.Stat(bad); !os.IsNotExist(err) {
+		t.Errorf("original file still present")
+	}
+
+	reports, err = pc.Verify(context.Background())
+	if err != nil {
+		t.Fatalf("Verify after quarantine: %v", err)
+	}
+	if len(reports) != 1 || !reports[0].OK() {
+		t.Errorf("after quarantine got %+v, want one healthy file", reports)
+	}
+}
+
+func TestVerifyDetectsSchemaDrift(t *testing.T) {
+	basePath := filepath.Join(t.TempDir(), "raw")
+	pc := NewParquetCache(basePath)
+
+	usersPath, err := pc.SaveUsers(map[string]*models.SlackUser{"U1": {ID: "U1", Name: "alice"}})
+	if err != nil {
+		t.Fatalf("SaveUsers: %v", err)
+	}
+
+	report := VerifyFile(context.Background(), usersPath, pc.schema)
+	if report.OK() || report.Unreadable {
+		t.Errorf("users file checked against message schema = %+v, want schema mismatch", report)
+	}
+}
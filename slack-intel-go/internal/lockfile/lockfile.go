@@ -0,0 +1,147 @@
+// Package lockfile guards a cache directory against concurrent runs with
+// an advisory lock: flock on Unix, LockFileEx on Windows. The operating
+// system drops the lock when its holder exits, however it exits, so a lock
+// left behind by a crashed run is reclaimed by the next one.
+package lockfile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often Acquire retries a held lock while waiting
+const pollInterval = 200 * time.Millisecond
+
+// errBusy is returned by tryLock when another process holds the lock
+var errBusy = errors.New("lock is held")
+
+// Holder is the process recorded in a lock file
+type Holder struct {
+	PID       int       `json:"pid"`
+	Host      string    `json:"host"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+func (h Holder) String() string {
+	s := fmt.Sprintf("PID %d", h.PID)
+	if h.Host != "" {
+		s += " on " + h.Host
+	}
+	if !h.StartedAt.IsZero() {
+		s += fmt.Sprintf(" (since %s)", h.StartedAt.Format(time.RFC3339))
+	}
+	return s
+}
+
+// HeldError reports a lock held by another process
+type HeldError struct {
+	Path string
+	// Holder is nil when the holder has not recorded itself yet
+	Holder *Holder
+}
+
+func (e *HeldError) Error() string {
+	if e.Holder == nil {
+		return fmt.Sprintf("%s is held by another process", e.Path)
+	}
+	return fmt.Sprintf("%s is held by %s", e.Path, e.Holder)
+}
+
+// Lock is an acquired lock file
+type Lock struct {
+	file *os.File
+	path string
+	// Reclaimed is the holder a crashed process left in the file, nil when
+	// the previous holder released the lock cleanly
+	Reclaimed *Holder
+}
+
+// Acquire takes the lock at path, creating the file and its directory.
+// When another process holds it, Acquire retries for up to wait (zero fails
+// at once) and then returns a *HeldError naming the holder.
+func Acquire(ctx context.Context, path string, wait time.Duration) (*Lock, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(wait)
+	for {
+		err := tryLock(f)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errBusy) {
+			f.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if !time.Now().Before(deadline) {
+			f.Close()
+			return nil, &HeldError{Path: path, Holder: readHolder(path)}
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+
+	// A clean Release empties the file, so a recorded holder crashed
+	lock := &Lock{file: f, path: path, Reclaimed: readHolder(path)}
+	host, _ := os.Hostname()
+	record, _ := json.Marshal(Holder{PID: os.Getpid(), Host: host, StartedAt: time.Now().UTC()})
+	if err := writeHolder(f, record); err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("failed to record lock holder: %w", err)
+	}
+	return lock, nil
+}
+
+// Release empties the lock file and unlocks it. The file is kept: removing
+// it could let two processes lock different files at the same path.
+func (l *Lock) Release() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	truncErr := l.file.Truncate(0)
+	unlockErr := unlock(l.file)
+	closeErr := l.file.Close()
+	l.file = nil
+	if err := errors.Join(truncErr, unlockErr, closeErr); err != nil {
+		return fmt.Errorf("failed to release %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// readHolder returns the holder recorded at path, nil when none is
+func readHolder(path string) *Holder {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+	var h Holder
+	if err := json.Unmarshal(data, &h); err != nil || h.PID == 0 {
+		return nil
+	}
+	return &h
+}
+
+// writeHolder replaces the lock file's content with record
+func writeHolder(f *os.File, record []byte) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(record, 0); err != nil {
+		return err
+	}
+	return f.Sync()
+}
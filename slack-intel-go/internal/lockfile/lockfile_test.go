@@ -0,0 +1,103 @@
+package lockfile
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireAndRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "raw", ".lock")
+	ctx := context.Background()
+
+	first, err := Acquire(ctx, path, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	if first.Reclaimed != nil {
+		t.Errorf("fresh lock reclaimed %v", first.Reclaimed)
+	}
+
+	_, err = Acquire(ctx, path, 0)
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second Acquire = %v, want a HeldError", err)
+	}
+	if held.Holder == nil || held.Holder.PID != os.Getpid() {
+		t.Errorf("holder = %v, want this process", held.Holder)
+	}
+
+	start := time.Now()
+	if _, err := Acquire(ctx, path, 3*pollInterval); !errors.As(err, &held) {
+		t.Fatalf("Acquire with wait = %v, want a HeldError", err)
+	}
+	if waited := time.Since(start); waited < 3*pollInterval {
+		t.Errorf("Acquire gave up after %s, want at least %s", waited, 3*pollInterval)
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	second, err := Acquire(ctx, path, 0)
+	if err != nil {
+		t.Fatalf("Acquire after Release: %v", err)
+	}
+	defer second.Release()
+	if second.Reclaimed != nil {
+		t.Errorf("lock released cleanly but reclaimed %v", second.Reclaimed)
+	}
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".lock")
+	first, err := Acquire(context.Background(), path, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	time.AfterFunc(2*pollInterval, func() { first.Release() })
+
+	second, err := Acquire(context.Background(), path, time.Minute)
+	if err != nil {
+		t.Fatalf("Acquire with wait: %v", err)
+	}
+	second.Release()
+}
+
+func TestAcquireReclaimsStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".lock")
+	// A crashed run leaves its record behind but no OS lock
+	stale := `{"pid":4242,"host":"cron-1","started_at":"2024-01-05T10:15:00Z"}`
+	if err := os.WriteFile(path, []byte(stale), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err := Acquire(context.Background(), path, 0)
+	if err != nil {
+		t.Fatalf("Acquire over stale lock: %v", err)
+	}
+	defer lock.Release()
+	if lock.Reclaimed == nil || lock.Reclaimed.PID != 4242 || lock.Reclaimed.Host != "cron-1" {
+		t.Fatalf("Reclaimed = %v, want PID 4242 on cron-1", lock.Reclaimed)
+	}
+	if h := readHolder(path); h == nil || h.PID != os.Getpid() {
+		t.Errorf("lock file records %v, want this process", h)
+	}
+}
+
+func TestAcquireHonoursContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".lock")
+	first, err := Acquire(context.Background(), path, 0)
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+	defer first.Release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*pollInterval)
+	defer cancel()
+	if _, err := Acquire(ctx, path, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire = %v, want the context's error", err)
+	}
+}
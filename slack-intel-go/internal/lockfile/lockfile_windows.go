@@ -0,0 +1,33 @@
+//go:build windows
+
+package lockfile
+
+import (
+	"errors"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// The lock covers one byte far past the holder record, so other processes
+// can still read who holds it
+const (
+	lockOffset     = ^uint32(0)
+	lockOffsetHigh = ^uint32(0) >> 1
+)
+
+// tryLock takes an exclusive LockFileEx lock on f without blocking
+func tryLock(f *os.File) error {
+	ol := windows.Overlapped{Offset: lockOffset, OffsetHigh: lockOffsetHigh}
+	err := windows.LockFileEx(windows.Handle(f.Fd()),
+		windows.LOCKFILE_EXCLUSIVE_LOCK|windows.LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, &ol)
+	if errors.Is(err, windows.ERROR_LOCK_VIOLATION) {
+		return errBusy
+	}
+	return err
+}
+
+func unlock(f *os.File) error {
+	ol := windows.Overlapped{Offset: lockOffset, OffsetHigh: lockOffsetHigh}
+	return windows.UnlockFileEx(windows.Handle(f.Fd()), 0, 1, 0, &ol)
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Alert is a cached message that matched a watch keyword, as recorded in
+// alerts.parquet
+type Alert struct {
+	ChannelID string    `json:"channel_id"`
+	Channel   string    `json:"channel"`
+	MessageID string    `json:"message_id"`
+	UserID    string    `json:"user_id,omitempty"`
+	Text      string    `json:"text"`
+	Permalink string    `json:"permalink,omitempty"`
+	Keywords  []string  `json:"keywords"` // the keywords that matched, in watch order
+	Timestamp time.Time `json:"timestamp"`
+	MatchedAt time.Time `json:"matched_at"`
+	// NotifiedAt is when the alert was posted to the notify channel; zero
+	// when no notify channel was set or posting failed
+	NotifiedAt time.Time `json:"notified_at,omitempty"`
+}
+
+// Key identifies the alerted message: a message alerts at most once
+func (a *Alert) Key() string {
+	return a.ChannelID + "/" + a.MessageID
+}
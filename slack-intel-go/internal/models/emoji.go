@@ -0,0 +1,134 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// SlackEmoji is a custom workspace emoji from emoji.list. Aliases carry
+// the name of the emoji they point at instead of an image URL.
+type SlackEmoji struct {
+	Name     string    `json:"name"`
+	URL      string    `json:"url,omitempty"`
+	AliasFor string    `json:"alias_for,omitempty"`
+	CachedAt time.Time `json:"-"`
+}
+
+// standardEmoji maps the most common standard reaction names to unicode
+var standardEmoji = map[string]string{
+	"+1":                     "👍",
+	"thumbsup":               "👍",
+	"-1":                     "👎",
+	"thumbsdown":             "👎",
+	"heart":                  "❤️",
+	"smile":                  "😄",
+	"slightly_smiling_face":  "🙂",
+	"joy":                    "😂",
+	"laughing":               "😆",
+	"wink":                   "😉",
+	"thinking_face":          "🤔",
+	"eyes":                   "👀",
+	"tada":                   "🎉",
+	"raised_hands":           "🙌",
+	"clap":                   "👏",
+	"pray":                   "🙏",
+	"muscle":                 "💪",
+	"wave":                   "👋",
+	"ok_hand":                "👌",
+	"fire":                   "🔥",
+	"rocket":                 "🚀",
+	"100":                    "💯",
+	"star":                   "⭐",
+	"sparkles":               "✨",
+	"white_check_mark":       "✅",
+	"heavy_check_mark":       "✔️",
+	"x":                      "❌",
+	"warning":                "⚠️",
+	"rotating_light":         "🚨",
+	"bug":                    "🐛",
+	"memo":                   "📝",
+	"point_up":               "☝️",
+	"raising_hand":           "🙋",
+	"sob":                    "😭",
+	"cry":                    "😢",
+	"sweat_smile":            "😅",
+	"scream":                 "😱",
+	"face_palm":              "🤦",
+	"facepalm":               "🤦",
+	"heavy_plus_sign":        "➕",
+	"question":               "❓",
+	"exclamation":            "❗",
+	"hourglass":              "⌛",
+	"hourglass_flowing_sand": "⏳",
+	"see_no_evil":            "🙈",
+	"bulb":                   "💡",
+	"lock":                   "🔒",
+	"coffee":                 "☕",
+}
+
+// EmojiMap resolves reaction names using the workspace's custom emoji,
+// keyed by name
+type EmojiMap map[string]*SlackEmoji
+
+// Resolve returns the unicode character for a standard emoji or the image
+// URL of a custom one, following aliases. Skin-tone modifiers
+// ("+1::skin-tone-2") are ignored. ok is false for unknown names.
+func (m EmojiMap) Resolve(name string) (string, bool) {
+	url, char := m.lookup(name)
+	if url != "" {
+		return url, true
+	}
+	return char, char != ""
+}
+
+// lookup follows aliases from name to a custom emoji's image URL or a
+// standard emoji's unicode character, both empty for unknown names
+func (m EmojiMap) lookup(name string) (url, char string) {
+	name = BaseEmoji(name)
+
+	// Alias chains are short; the bound only guards against cycles
+	for range 8 {
+		if e, ok := m[name]; ok {
+			if e.AliasFor == "" {
+				return e.URL, ""
+			}
+			name = e.AliasFor
+			continue
+		}
+		return "", standardEmoji[name]
+	}
+	return "", ""
+}
+
+// emojiCode matches a shortcode in message text, skin tone included:
+// ":tada:", ":+1::skin-tone-2:"
+var emojiCode = regexp.MustCompile(`:[a-z0-9_+'-]+:(?::skin-tone-[2-6]:)?`)
+
+// ExpandText replaces the shortcodes in text that resolve to a unicode
+// character, standard emoji and aliases of them. Custom emoji keep their
+// :name: and are returned in custom, name to image URL; unknown names are
+// left alone.
+func (m EmojiMap) ExpandText(text string) (expanded string, custom map[string]string) {
+	expanded = emojiCode.ReplaceAllStringFunc(text, func(code string) string {
+		url, char := m.lookup(code)
+		switch {
+		case url != "":
+			if custom == nil {
+				custom = make(map[string]string)
+			}
+			custom[BaseEmoji(code)] = url
+		case char != "":
+			return char
+		}
+		return code
+	})
+	return expanded, custom
+}
+
+// BaseEmoji strips surrounding colons and any skin-tone modifier from a
+// reaction name, so "+1::skin-tone-3" and ":+1:" both become "+1"
+func BaseEmoji(name string) string {
+	name, _, _ = strings.Cut(strings.Trim(name, ":"), "::")
+	return name
+}
@@ -0,0 +1,64 @@
+package models
+
+import "testing"
+
+func TestEmojiMapResolve(t *testing.T) {
+	m := EmojiMap{
+		"shipit":   {Name: "shipit", URL: "https://emoji.slack-edge.com/T1/shipit/abc.png"},
+		"squirrel": {Name: "squirrel", AliasFor: "shipit"},
+		"yes":      {Name: "yes", AliasFor: "white_check_mark"},
+		"loop":     {Name: "loop", AliasFor: "loop"},
+	}
+
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"shipit", "https://emoji.slack-edge.com/T1/shipit/abc.png", true},
+		{":squirrel:", "https://emoji.slack-edge.com/T1/shipit/abc.png", true},
+		{"yes", "✅", true},
+		{"+1::skin-tone-3", "👍", true},
+		{"unknown", "", false},
+		{"loop", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := m.Resolve(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("Resolve(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestEmojiMapExpandText(t *testing.T) {
+	m := EmojiMap{
+		"shipit":   {Name: "shipit", URL: "https://emoji.slack-edge.com/T1/shipit/abc.png"},
+		"squirrel": {Name: "squirrel", AliasFor: "shipit"},
+		"yes":      {Name: "yes", AliasFor: "white_check_mark"},
+	}
+
+	got, custom := m.ExpandText("deployed :tada: :+1::skin-tone-2: :yes: :squirrel: at 10:30:45 :nope:")
+	if want := "deployed 🎉 👍 ✅ :squirrel: at 10:30:45 :nope:"; got != want {
+		t.Errorf("ExpandText() = %q, want %q", got, want)
+	}
+	if len(custom) != 1 || custom["squirrel"] != "https://emoji.slack-edge.com/T1/shipit/abc.png" {
+		t.Errorf("custom = %v, want squirrel's image URL", custom)
+	}
+
+	if got, custom := m.ExpandText("no emoji here"); got != "no emoji here" || custom != nil {
+		t.Errorf("ExpandText() = %q, %v; want the text unchanged and no custom emoji", got, custom)
+	}
+}
+
+func TestBaseEmoji(t *testing.T) {
+	for in, want := range map[string]string{
+		"+1":                  "+1",
+		"+1::skin-tone-3":     "+1",
+		":wave::skin-tone-6:": "wave",
+		":tada:":              "tada",
+	} {
+		if got := BaseEmoji(in); got != want {
+			t.Errorf("BaseEmoji(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
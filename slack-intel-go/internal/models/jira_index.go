@@ -0,0 +1,116 @@
+package models
+
+import (
+	"sort"
+	"time"
+)
+
+// JiraMentions records where a JIRA ticket was mentioned: in how many
+// messages, in which channels, by whom and when
+type JiraMentions struct {
+	TicketID     string    `json:"ticket_id"`
+	MentionCount int       `json:"mention_count"`
+	Channels     []string  `json:"channels"`    // sorted
+	MessageIDs   []string  `json:"message_ids"` // sorted
+	UserIDs      []string  `json:"user_ids"`    // sorted, authors of the mentions
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+
+	// mentioned holds the channel/message ID of every counted message, so a
+	// message read twice is counted once
+	mentioned map[string]bool
+}
+
+// AggregateJiraMentions indexes the tickets in messages' JiraTickets by
+// ticket ID. A message counts once per ticket however often it names it,
+// in its text and attachments alike, and however often it is read.
+func AggregateJiraMentions(channel string, messages []*SlackMessage) map[string]*JiraMentions {
+	index := make(map[string]*JiraMentions)
+	for _, m := range messages {
+		key := channel + "/" + m.MessageID
+		for _, ticket := range m.JiraTickets {
+			if ticket == "" {
+				continue
+			}
+			j, ok := index[ticket]
+			if !ok {
+				j = &JiraMentions{TicketID: ticket, mentioned: make(map[string]bool)}
+				index[ticket] = j
+			}
+			if j.mentioned[key] {
+				continue
+			}
+			j.mentioned[key] = true
+			j.MentionCount++
+			j.Channels = insertSorted(j.Channels, channel)
+			j.MessageIDs = insertSorted(j.MessageIDs, m.MessageID)
+			if m.UserID != "" {
+				j.UserIDs = insertSorted(j.UserIDs, m.UserID)
+			}
+			j.seen(m.Timestamp)
+		}
+	}
+	return index
+}
+
+// MergeJiraMentions adds the mentions in src to dst, allocating dst if
+// nil, so the index can be built partition by partition. Messages already
+// counted in dst, e.g. cached in two partitions, are not counted again.
+func MergeJiraMentions(dst, src map[string]*JiraMentions) map[string]*JiraMentions {
+	if dst == nil {
+		dst = make(map[string]*JiraMentions, len(src))
+	}
+	for ticket, s := range src {
+		d, ok := dst[ticket]
+		if !ok {
+			d = &JiraMentions{TicketID: ticket, mentioned: make(map[string]bool)}
+			dst[ticket] = d
+		}
+		if s.mentioned == nil {
+			d.MentionCount += s.MentionCount
+		}
+		for key := range s.mentioned {
+			if !d.mentioned[key] {
+				d.mentioned[key] = true
+				d.MentionCount++
+			}
+		}
+		for _, ch := range s.Channels {
+			d.Channels = insertSorted(d.Channels, ch)
+		}
+		for _, id := range s.MessageIDs {
+			d.MessageIDs = insertSorted(d.MessageIDs, id)
+		}
+		for _, id := range s.UserIDs {
+			d.UserIDs = insertSorted(d.UserIDs, id)
+		}
+		d.seen(s.FirstSeen)
+		d.seen(s.LastSeen)
+	}
+	return dst
+}
+
+// seen widens the first/last seen window to include t
+func (j *JiraMentions) seen(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if j.FirstSeen.IsZero() || t.Before(j.FirstSeen) {
+		j.FirstSeen = t
+	}
+	if t.After(j.LastSeen) {
+		j.LastSeen = t
+	}
+}
+
+// insertSorted adds s to the sorted set values
+func insertSorted(values []string, s string) []string {
+	i := sort.SearchStrings(values, s)
+	if i < len(values) && values[i] == s {
+		return values
+	}
+	values = append(values, "")
+	copy(values[i+1:], values[i:])
+	values[i] = s
+	return values
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAggregateJiraMentions(t *testing.T) {
+	at := func(h int) time.Time { return time.Date(2024, 1, 5, h, 0, 0, 0, time.UTC) }
+	backend := []*SlackMessage{
+		{MessageID: "3.0", UserID: "U2", Timestamp: at(12), JiraTickets: []string{"PROJ-1"}},
+		{MessageID: "1.0", UserID: "U1", Timestamp: at(9), JiraTickets: []string{"PROJ-1", "OPS-7", "PROJ-1"}},
+		{MessageID: "2.0", BotID: "B1", Timestamp: at(10), JiraTickets: []string{"OPS-7"}},
+		{MessageID: "4.0", UserID: "U1", Timestamp: at(11)},
+	}
+	general := []*SlackMessage{
+		{MessageID: "5.0", UserID: "U3", Timestamp: at(8), JiraTickets: []string{"PROJ-1"}},
+		// The same message read twice, e.g. with rows predating ticket dedup
+		{MessageID: "5.0", UserID: "U3", Timestamp: at(8), JiraTickets: []string{"PROJ-1", "PROJ-1"}},
+	}
+	// backend's 3.0 again, as when a message is cached in two partitions
+	stray := []*SlackMessage{
+		{MessageID: "3.0", UserID: "U2", Timestamp: at(12), JiraTickets: []string{"PROJ-1"}},
+	}
+
+	index := MergeJiraMentions(MergeJiraMentions(nil, AggregateJiraMentions("backend", backend)),
+		AggregateJiraMentions("general", general))
+	index = MergeJiraMentions(index, AggregateJiraMentions("backend", stray))
+
+	want := map[string]JiraMentions{
+		"PROJ-1": {
+			TicketID: "PROJ-1", MentionCount: 3,
+			Channels:   []string{"backend", "general"},
+			MessageIDs: []string{"1.0", "3.0", "5.0"},
+			UserIDs:    []string{"U1", "U2", "U3"},
+			FirstSeen:  at(8), LastSeen: at(12),
+		},
+		"OPS-7": {
+			TicketID: "OPS-7", MentionCount: 2,
+			Channels:   []string{"backend"},
+			MessageIDs: []string{"1.0", "2.0"},
+			UserIDs:    []string{"U1"},
+			FirstSeen:  at(9), LastSeen: at(10),
+		},
+	}
+	if len(index) != len(want) {
+		t.Fatalf("got %d tickets, want %d: %+v", len(index), len(want), index)
+	}
+	for ticket, w := range want {
+		got := index[ticket]
+		if got == nil {
+			t.Errorf("index[%s] missing", ticket)
+			continue
+		}
+		g := *got
+		g.mentioned = nil
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("index[%s] = %+v, want %+v", ticket, g, w)
+		}
+	}
+}
@@ -1,15 +1,28 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
 
 // SlackUser represents a Slack user
 type SlackUser struct {
-	ID          string `json:"id"`
-	Name        string `json:"name,omitempty"`
-	RealName    string `json:"real_name,omitempty"`
-	DisplayName string `json:"display_name,omitempty"`
-	Email       string `json:"email,omitempty"`
-	IsBot       bool   `json:"is_bot"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name,omitempty"`
+	RealName    string    `json:"real_name,omitempty"`
+	DisplayName string    `json:"display_name,omitempty"`
+	Email       string    `json:"email,omitempty"`
+	IsBot       bool      `json:"is_bot"`
+	Title       string    `json:"title,omitempty"`
+	TZ          string    `json:"tz,omitempty"`        // IANA zone, e.g. Europe/Warsaw
+	TZOffset    int       `json:"tz_offset,omitempty"` // seconds east of UTC
+	Image192    string    `json:"image_192,omitempty"`
+	Deleted     bool      `json:"deleted,omitempty"`     // deactivated; kept so old messages still resolve
+	TeamID      string    `json:"team_id,omitempty"`     // home workspace; differs from ours for Slack Connect users
+	IsExternal  bool      `json:"is_external,omitempty"` // Slack Connect user, guest, or not visible to users.info
+	UpdatedAt   time.Time `json:"updated_at"`            // last profile change reported by Slack
+	CachedAt    time.Time `json:"-"`                     // when written to users.parquet; zero if fetched this run
 }
 
 // SlackReaction represents a reaction on a message
@@ -32,6 +45,7 @@ type SlackFile struct {
 type SlackMessage struct {
 	MessageID   string          `json:"message_id"`
 	UserID      string          `json:"user_id,omitempty"`
+	BotID       string          `json:"bot_id,omitempty"`
 	Text        string          `json:"text"`
 	Timestamp   time.Time       `json:"timestamp"`
 	ThreadTS    string          `json:"thread_ts,omitempty"`
@@ -40,6 +54,14 @@ type SlackMessage struct {
 	Reactions   []SlackReaction `json:"reactions,omitempty"`
 	Files       []SlackFile     `json:"files,omitempty"`
 	JiraTickets []string        `json:"jira_tickets,omitempty"`
+	PinnedTo    []string        `json:"pinned_to,omitempty"`
+	Permalink   string          `json:"permalink,omitempty"`
+	FetchedAt   time.Time       `json:"fetched_at,omitempty"` // when the message was read from Slack
+	EditedAt    time.Time       `json:"edited_at,omitempty"`  // when the text was last edited, zero if never
+	CleanText   string          `json:"clean_text,omitempty"` // Text normalized by --normalize-text
+	URLs        []string        `json:"urls,omitempty"`       // links shared in Text, in order
+	Blocks      json.RawMessage `json:"blocks,omitempty"`     // Block Kit blocks as Slack sent them
+	Raw         json.RawMessage `json:"-"`                    // original API payload, kept with --raw
 }
 
 // IsThreadParent checks if message is a thread parent
@@ -52,10 +74,74 @@ func (m *SlackMessage) IsThreadReply() bool {
 	return m.ThreadTS != "" && m.ThreadTS != m.MessageID
 }
 
-// SlackChannel represents a Slack channel configuration
+// IsPinned reports whether the message is pinned in any conversation
+func (m *SlackMessage) IsPinned() bool {
+	return len(m.PinnedTo) > 0
+}
+
+// IsBot reports whether the message was posted by a bot integration or bot user
+func (m *SlackMessage) IsBot() bool {
+	return m.BotID != "" || (m.UserInfo != nil && m.UserInfo.IsBot)
+}
+
+// ExcludeBots drops bot messages, keeping bot-posted thread parents that
+// have human replies so those replies don't become orphans. It returns the
+// kept messages and how many were dropped.
+func ExcludeBots(messages []*SlackMessage) ([]*SlackMessage, int) {
+	humanThreads := make(map[string]bool)
+	for _, m := range messages {
+		if m.IsThreadReply() && !m.IsBot() {
+			humanThreads[m.ThreadTS] = true
+		}
+	}
+
+	kept := make([]*SlackMessage, 0, len(messages))
+	for _, m := range messages {
+		if !m.IsBot() || (m.IsThreadParent() && humanThreads[m.MessageID]) {
+			kept = append(kept, m)
+		}
+	}
+
+	return kept, len(messages) - len(kept)
+}
+
+// SlackChannel represents a Slack channel configuration. The metadata
+// fields are filled from conversations.info when available.
 type SlackChannel struct {
-	Name string `json:"name"`
-	ID   string `json:"id"`
+	Name       string    `json:"name"`
+	ID         string    `json:"id"`
+	Topic      string    `json:"topic,omitempty"`
+	Purpose    string    `json:"purpose,omitempty"`
+	NumMembers int       `json:"num_members,omitempty"`
+	IsPrivate  bool      `json:"is_private,omitempty"`
+	IsArchived bool      `json:"is_archived,omitempty"`
+	Created    time.Time `json:"created,omitempty"`
+	CachedAt   time.Time `json:"-"`
+}
+
+// ExcludeChannels drops the channels matching any ref, by ID or by name
+// (case-insensitive, with or without a leading #). It returns the kept
+// channels and how many were dropped.
+func ExcludeChannels(channels []SlackChannel, refs []string) ([]SlackChannel, int) {
+	if len(refs) == 0 {
+		return channels, 0
+	}
+
+	kept := make([]SlackChannel, 0, len(channels))
+	for _, ch := range channels {
+		excluded := false
+		for _, ref := range refs {
+			if ref == ch.ID || strings.EqualFold(strings.TrimPrefix(ref, "#"), ch.Name) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			kept = append(kept, ch)
+		}
+	}
+
+	return kept, len(channels) - len(kept)
 }
 
 // JiraTicket represents JIRA ticket metadata
@@ -0,0 +1,53 @@
+package models
+
+import "testing"
+
+func TestExcludeBots(t *testing.T) {
+	messages := []*SlackMessage{
+		{MessageID: "1.0", UserID: "U1", Text: "human"},
+		{MessageID: "2.0", BotID: "B1", Text: "deploy finished"},
+		{MessageID: "3.0", UserID: "U2", UserInfo: &SlackUser{ID: "U2", IsBot: true}, Text: "bot user"},
+		{MessageID: "4.0", BotID: "B1", ThreadTS: "4.0", ReplyCount: 2, Text: "alert fired"},
+		{MessageID: "4.1", UserID: "U1", ThreadTS: "4.0", Text: "looking"},
+		{MessageID: "4.2", BotID: "B1", ThreadTS: "4.0", Text: "alert resolved"},
+		{MessageID: "5.0", BotID: "B1", ThreadTS: "5.0", ReplyCount: 1, Text: "bot-only thread"},
+		{MessageID: "5.1", BotID: "B1", ThreadTS: "5.0", Text: "bot reply"},
+	}
+
+	kept, dropped := ExcludeBots(messages)
+
+	var ids []string
+	for _, m := range kept {
+		ids = append(ids, m.MessageID)
+	}
+	want := []string{"1.0", "4.0", "4.1"}
+	if len(ids) != len(want) {
+		t.Fatalf("kept %v, want %v", ids, want)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("kept %v, want %v", ids, want)
+		}
+	}
+	if dropped != 5 {
+		t.Errorf("dropped = %d, want 5", dropped)
+	}
+}
+
+func TestExcludeChannels(t *testing.T) {
+	channels := []SlackChannel{
+		{Name: "general", ID: "C1"},
+		{Name: "alerts-noisy", ID: "C2"},
+		{Name: "channel_C3", ID: "C3"}, // as passed with --channel
+		{Name: "backend", ID: "C4"},
+	}
+
+	kept, excluded := ExcludeChannels(channels, []string{"#Alerts-Noisy", "C3", "missing"})
+	if excluded != 2 || len(kept) != 2 || kept[0].ID != "C1" || kept[1].ID != "C4" {
+		t.Errorf("ExcludeChannels = %+v (%d excluded), want general and backend kept", kept, excluded)
+	}
+
+	if kept, excluded := ExcludeChannels(channels, nil); excluded != 0 || len(kept) != 4 {
+		t.Errorf("no refs: %d kept, %d excluded; want all kept", len(kept), excluded)
+	}
+}
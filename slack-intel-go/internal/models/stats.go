@@ -0,0 +1,60 @@
+package models
+
+// UserStats counts a user's activity across a set of messages
+type UserStats struct {
+	UserID         string `json:"user_id"`
+	MessagesSent   int    `json:"messages_sent"`
+	ReactionsGiven int    `json:"reactions_given"`
+	ThreadsStarted int    `json:"threads_started"`
+}
+
+// AggregateUserStats computes per-user activity counts keyed by user ID.
+// Reactions are credited to every user listed on them, so users who only
+// react still get a row.
+func AggregateUserStats(messages []*SlackMessage) map[string]*UserStats {
+	stats := make(map[string]*UserStats)
+	get := func(id string) *UserStats {
+		s, ok := stats[id]
+		if !ok {
+			s = &UserStats{UserID: id}
+			stats[id] = s
+		}
+		return s
+	}
+
+	for _, m := range messages {
+		if m.UserID != "" {
+			s := get(m.UserID)
+			s.MessagesSent++
+			if m.IsThreadParent() {
+				s.ThreadsStarted++
+			}
+		}
+		for _, r := range m.Reactions {
+			for _, id := range r.Users {
+				get(id).ReactionsGiven++
+			}
+		}
+	}
+
+	return stats
+}
+
+// MergeUserStats adds the counts in src to dst, allocating dst if nil, so
+// stats can be aggregated batch by batch
+func MergeUserStats(dst, src map[string]*UserStats) map[string]*UserStats {
+	if dst == nil {
+		dst = make(map[string]*UserStats, len(src))
+	}
+	for id, s := range src {
+		d, ok := dst[id]
+		if !ok {
+			d = &UserStats{UserID: id}
+			dst[id] = d
+		}
+		d.MessagesSent += s.MessagesSent
+		d.ReactionsGiven += s.ReactionsGiven
+		d.ThreadsStarted += s.ThreadsStarted
+	}
+	return dst
+}
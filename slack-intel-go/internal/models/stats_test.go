@@ -0,0 +1,47 @@
+package models
+
+import "testing"
+
+func TestAggregateUserStats(t *testing.T) {
+	messages := []*SlackMessage{
+		{MessageID: "1.0", UserID: "U1", ThreadTS: "1.0", ReplyCount: 1},
+		{MessageID: "1.1", UserID: "U2", ThreadTS: "1.0", Reactions: []SlackReaction{
+			{Emoji: "eyes", Count: 2, Users: []string{"U1", "U3"}},
+		}},
+		{MessageID: "2.0", UserID: "U1", Reactions: []SlackReaction{
+			{Emoji: "+1", Count: 1, Users: []string{"U2"}},
+			{Emoji: "tada", Count: 1, Users: []string{"U2"}},
+		}},
+		{MessageID: "3.0", BotID: "B1"},
+	}
+
+	stats := AggregateUserStats(messages)
+
+	want := map[string]UserStats{
+		"U1": {UserID: "U1", MessagesSent: 2, ReactionsGiven: 1, ThreadsStarted: 1},
+		"U2": {UserID: "U2", MessagesSent: 1, ReactionsGiven: 2},
+		"U3": {UserID: "U3", ReactionsGiven: 1},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("got %d users, want %d: %+v", len(stats), len(want), stats)
+	}
+	for id, w := range want {
+		if got := stats[id]; got == nil || *got != w {
+			t.Errorf("stats[%s] = %+v, want %+v", id, got, w)
+		}
+	}
+}
+
+func TestMergeUserStats(t *testing.T) {
+	first := AggregateUserStats([]*SlackMessage{{MessageID: "1.0", UserID: "U1", ThreadTS: "1.0", ReplyCount: 1}})
+	second := AggregateUserStats([]*SlackMessage{{MessageID: "2.0", UserID: "U1"}, {MessageID: "3.0", UserID: "U2"}})
+
+	merged := MergeUserStats(MergeUserStats(nil, first), second)
+
+	if got := *merged["U1"]; got != (UserStats{UserID: "U1", MessagesSent: 2, ThreadsStarted: 1}) {
+		t.Errorf("U1 = %+v", got)
+	}
+	if got := *merged["U2"]; got != (UserStats{UserID: "U2", MessagesSent: 1}) {
+		t.Errorf("U2 = %+v", got)
+	}
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Thread groups a parent message with its replies, oldest first. A
+// message that started no thread is a Thread without replies.
+type Thread struct {
+	Parent  *SlackMessage   `json:"parent"`
+	Replies []*SlackMessage `json:"replies"`
+	// ParentMissing is set when the parent was not among the messages and
+	// Parent is a stub built from the replies' thread_ts
+	ParentMissing bool `json:"parent_missing,omitempty"`
+}
+
+// BuildThreads groups a flat message list into threads ordered by the
+// parent's timestamp. Replies whose parent is not in msgs get a stub
+// parent carrying only the thread timestamp.
+func BuildThreads(msgs []*SlackMessage) []*Thread {
+	byTS := make(map[string]*Thread)
+	var threads []*Thread
+
+	for _, m := range msgs {
+		if m.IsThreadReply() {
+			continue
+		}
+		t := &Thread{Parent: m, Replies: []*SlackMessage{}}
+		byTS[m.MessageID] = t
+		threads = append(threads, t)
+	}
+
+	for _, m := range msgs {
+		if !m.IsThreadReply() {
+			continue
+		}
+		t, ok := byTS[m.ThreadTS]
+		if !ok {
+			t = &Thread{
+				Parent: &SlackMessage{
+					MessageID: m.ThreadTS,
+					ThreadTS:  m.ThreadTS,
+					Timestamp: tsTime(m.ThreadTS),
+				},
+				Replies:       []*SlackMessage{},
+				ParentMissing: true,
+			}
+			byTS[m.ThreadTS] = t
+			threads = append(threads, t)
+		}
+		t.Replies = append(t.Replies, m)
+	}
+
+	for _, t := range threads {
+		sortMessages(t.Replies)
+		if t.ParentMissing {
+			t.Parent.ReplyCount = len(t.Replies)
+		}
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		return lessMessage(threads[i].Parent, threads[j].Parent)
+	})
+
+	return threads
+}
+
+// sortMessages orders messages by timestamp, then Slack ts
+func sortMessages(msgs []*SlackMessage) {
+	sort.SliceStable(msgs, func(i, j int) bool {
+		return lessMessage(msgs[i], msgs[j])
+	})
+}
+
+func lessMessage(a, b *SlackMessage) bool {
+	if !a.Timestamp.Equal(b.Timestamp) {
+		return a.Timestamp.Before(b.Timestamp)
+	}
+	return a.MessageID < b.MessageID
+}
+
+// tsTime converts a Slack ts ("1700000100.000100") to a time, or the zero
+// time when it is malformed
+func tsTime(ts string) time.Time {
+	secs, micros, _ := strings.Cut(ts, ".")
+	sec, err := strconv.ParseInt(secs, 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	usec, _ := strconv.ParseInt(micros, 10, 64)
+	return time.Unix(sec, usec*1000)
+}
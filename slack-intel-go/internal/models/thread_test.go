@@ -0,0 +1,45 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildThreads(t *testing.T) {
+	msg := func(ts, threadTS string, replies int) *SlackMessage {
+		return &SlackMessage{MessageID: ts, ThreadTS: threadTS, ReplyCount: replies, Timestamp: tsTime(ts)}
+	}
+	msgs := []*SlackMessage{
+		msg("1700000300.000100", "1700000100.000100", 0), // reply, out of order
+		msg("1700000200.000100", "", 0),
+		msg("1700000100.000100", "1700000100.000100", 2),
+		msg("1700000150.000100", "1700000100.000100", 0),
+		msg("1700000060.000100", "1700000050.000100", 0), // orphan reply
+	}
+
+	threads := BuildThreads(msgs)
+
+	if len(threads) != 3 {
+		t.Fatalf("got %d threads, want 3", len(threads))
+	}
+
+	orphan := threads[0]
+	if !orphan.ParentMissing || orphan.Parent.MessageID != "1700000050.000100" || len(orphan.Replies) != 1 {
+		t.Errorf("threads[0] = %+v, want a stub parent for the orphan reply", orphan)
+	}
+	if !orphan.Parent.Timestamp.Equal(time.Unix(1700000050, 100000)) || !orphan.Parent.IsThreadParent() {
+		t.Errorf("stub parent = %+v, want timestamp from thread_ts and reply count", orphan.Parent)
+	}
+
+	incident := threads[1]
+	if incident.ParentMissing || incident.Parent.MessageID != "1700000100.000100" {
+		t.Fatalf("threads[1] parent = %+v, want 1700000100.000100", incident.Parent)
+	}
+	if len(incident.Replies) != 2 || incident.Replies[0].MessageID != "1700000150.000100" {
+		t.Errorf("threads[1] replies = %+v, want 2 sorted by ts", incident.Replies)
+	}
+
+	if standalone := threads[2]; standalone.Parent.MessageID != "1700000200.000100" || len(standalone.Replies) != 0 {
+		t.Errorf("threads[2] = %+v, want the standalone message", standalone)
+	}
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UserChanges counts how a users.list sync differs from the previous
+// user directory
+type UserChanges struct {
+	New         int `json:"new"`
+	Changed     int `json:"changed"`
+	Deactivated int `json:"deactivated"`
+}
+
+// MergeUsers overlays a fresh users.list result onto the previous directory.
+// Users missing from fresh (e.g. external Slack Connect members seen in
+// messages) are kept. Changes compare names, email, title and timezone;
+// a user who was deactivated counts as deactivated rather than changed.
+func MergeUsers(previous map[string]*SlackUser, fresh []*SlackUser) (map[string]*SlackUser, UserChanges) {
+	merged := make(map[string]*SlackUser, len(previous)+len(fresh))
+	for id, user := range previous {
+		merged[id] = user
+	}
+
+	var changes UserChanges
+	for _, user := range fresh {
+		old, ok := previous[user.ID]
+		switch {
+		case !ok:
+			changes.New++
+		case user.Deleted && !old.Deleted:
+			changes.Deactivated++
+		case user.Deleted != old.Deleted || user.Name != old.Name || user.RealName != old.RealName ||
+			user.Email != old.Email || user.IsBot != old.IsBot || user.Title != old.Title || user.TZ != old.TZ:
+			changes.Changed++
+		}
+		merged[user.ID] = user
+	}
+
+	return merged, changes
+}
+
+// FindUser resolves a user reference against the user directory: a user ID
+// (U04ABCDE), "@name" (handle or display name) or an email address, the
+// last two case-insensitively. IDs are returned as given even when the
+// directory does not know them, so messages of unknown users can be found.
+func FindUser(users map[string]*SlackUser, ref string) (string, error) {
+	var matches []string
+	switch {
+	case strings.HasPrefix(ref, "@"):
+		name := ref[1:]
+		for id, u := range users {
+			if strings.EqualFold(u.Name, name) || strings.EqualFold(u.DisplayName, name) {
+				matches = append(matches, id)
+			}
+		}
+	case strings.Contains(ref, "@"):
+		for id, u := range users {
+			if strings.EqualFold(u.Email, ref) {
+				matches = append(matches, id)
+			}
+		}
+	default:
+		return ref, nil
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no cached user matches %s; run `slack-intel users sync` or pass a user ID", ref)
+	case 1:
+		return matches[0], nil
+	}
+	sort.Strings(matches)
+	return "", fmt.Errorf("%s matches several users (%s); pass a user ID", ref, strings.Join(matches, ", "))
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeUsers(t *testing.T) {
+	previous := map[string]*SlackUser{
+		"U1": {ID: "U1", Name: "alice"},
+		"U2": {ID: "U2", Name: "bob"},
+		"U3": {ID: "U3", Name: "carol"},
+		"X1": {ID: "X1", Name: "external"},
+	}
+	fresh := []*SlackUser{
+		{ID: "U1", Name: "alice"},
+		{ID: "U2", Name: "robert"},
+		{ID: "U3", Name: "carol", Deleted: true},
+		{ID: "U4", Name: "dave"},
+	}
+
+	merged, changes := MergeUsers(previous, fresh)
+
+	if want := (UserChanges{New: 1, Changed: 1, Deactivated: 1}); changes != want {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+	if len(merged) != 5 {
+		t.Fatalf("got %d users, want 5", len(merged))
+	}
+	if merged["U2"].Name != "robert" || !merged["U3"].Deleted {
+		t.Errorf("merged = %+v, want fresh values to win", merged)
+	}
+	if merged["X1"] == nil {
+		t.Error("user missing from users.list should be kept")
+	}
+}
+
+func TestFindUser(t *testing.T) {
+	users := map[string]*SlackUser{
+		"U1": {ID: "U1", Name: "alice", Email: "Alice@Example.com"},
+		"U2": {ID: "U2", Name: "bob", DisplayName: "Bobby"},
+		"U3": {ID: "U3", Name: "bobby"},
+	}
+
+	tests := []struct {
+		ref, want, err string
+	}{
+		{"U1", "U1", ""},
+		{"U404", "U404", ""},
+		{"@alice", "U1", ""},
+		{"@ALICE", "U1", ""},
+		{"alice@example.com", "U1", ""},
+		{"@bobby", "", "several users (U2, U3)"},
+		{"@carol", "", "no cached user"},
+		{"carol@example.com", "", "no cached user"},
+	}
+	for _, tt := range tests {
+		got, err := FindUser(users, tt.ref)
+		if got != tt.want || (err == nil) != (tt.err == "") || (err != nil && !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("FindUser(%q) = %q, %v; want %q, error containing %q", tt.ref, got, err, tt.want, tt.err)
+		}
+	}
+}
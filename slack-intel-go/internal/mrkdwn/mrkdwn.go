@@ -0,0 +1,153 @@
+// Package mrkdwn turns the mrkdwn text of Slack messages into clean text
+// for downstream processing: HTML entities unescaped, link and mention
+// tokens resolved, formatting markers stripped or converted to markdown,
+// and whitespace collapsed.
+package mrkdwn
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Mode says what Normalize does with formatting markers
+type Mode string
+
+const (
+	// ModeStrip drops *bold*, _italic_, ~strike~, `code`, bullets and quotes
+	ModeStrip Mode = "strip"
+	// ModeMarkdown converts the markers to their CommonMark equivalents
+	ModeMarkdown Mode = "markdown"
+	// ModeKeep leaves the markers as Slack wrote them
+	ModeKeep Mode = "keep"
+)
+
+// ParseMode validates a --mrkdwn value
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(s); m {
+	case ModeStrip, ModeMarkdown, ModeKeep:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid mrkdwn mode %q (expected strip, markdown or keep)", s)
+}
+
+// entities are the only escapes Slack applies to message text
+var entities = strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">")
+
+// Unescape decodes &amp;, &lt; and &gt; in a single pass, so "&amp;lt;"
+// (a literal "&lt;" typed by the user) stays "&lt;"
+func Unescape(s string) string {
+	return entities.Replace(s)
+}
+
+var (
+	// code matches ``` blocks and `inline` spans, whose content is literal
+	code = regexp.MustCompile("```[\\s\\S]*?```|`[^`\n]+`")
+
+	// token matches <...> links, mentions and special commands
+	token = regexp.MustCompile(`<([^<>\n]+)>`)
+
+	// markers match *bold*, _italic_ and ~strike~ that start at a word
+	// boundary, as Slack renders them
+	bold   = regexp.MustCompile(`(^|[^\w*])\*([^\s*](?:[^*\n]*[^\s*])?)\*`)
+	italic = regexp.MustCompile(`(^|[^\w_])_([^\s_](?:[^_\n]*[^\s_])?)_`)
+	strike = regexp.MustCompile(`(^|[^\w~])~([^\s~](?:[^~\n]*[^\s~])?)~`)
+
+	bullet = regexp.MustCompile(`(?m)^[ \t]*[•◦▪‣][ \t]+`)
+	quote  = regexp.MustCompile(`(?m)^&gt;[ \t]?`)
+
+	spaces     = regexp.MustCompile(`[ \t\r\f\v]+`)
+	lineSpaces = regexp.MustCompile(` *\n *`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// Normalize returns the clean form of a message's text
+func Normalize(text string, mode Mode) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range code.FindAllStringIndex(text, -1) {
+		b.WriteString(prose(text[last:loc[0]], mode))
+		b.WriteString(codeSpan(text[loc[0]:loc[1]], mode))
+		last = loc[1]
+	}
+	b.WriteString(prose(text[last:], mode))
+
+	return strings.TrimSpace(blankLines.ReplaceAllString(b.String(), "\n\n"))
+}
+
+// codeSpan unescapes a code span and drops its backticks in ModeStrip
+func codeSpan(s string, mode Mode) string {
+	if mode == ModeStrip {
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "```"), "```")
+		s = strings.Trim(s, "`")
+	}
+	return Unescape(s)
+}
+
+// prose normalizes text outside code spans
+func prose(s string, mode Mode) string {
+	s = token.ReplaceAllStringFunc(s, func(m string) string {
+		return resolveToken(m[1:len(m)-1], mode)
+	})
+
+	switch mode {
+	case ModeStrip:
+		s = bullet.ReplaceAllString(s, "")
+		s = quote.ReplaceAllString(s, "")
+		s = replaceMarker(bold, s, "$1$2")
+		s = replaceMarker(italic, s, "$1$2")
+		s = replaceMarker(strike, s, "$1$2")
+	case ModeMarkdown:
+		s = bullet.ReplaceAllString(s, "- ")
+		s = quote.ReplaceAllString(s, "> ")
+		s = replaceMarker(bold, s, "$1**$2**")
+		s = replaceMarker(strike, s, "$1~~$2~~")
+	}
+
+	s = Unescape(s)
+	s = spaces.ReplaceAllString(s, " ")
+	return lineSpaces.ReplaceAllString(s, "\n")
+}
+
+// replaceMarker applies a marker pattern twice: a match consumes the
+// character before the marker, so "*a* *b*" needs a second pass for "b"
+func replaceMarker(re *regexp.Regexp, s, repl string) string {
+	s = re.ReplaceAllString(s, repl)
+	return re.ReplaceAllString(s, repl)
+}
+
+// resolveToken renders the inside of a <...> token: <@U123|name>,
+// <#C123|channel>, <!here>, <!subteam^S1|@team> or <url|label>
+func resolveToken(inner string, mode Mode) string {
+	target, label, hasLabel := strings.Cut(inner, "|")
+
+	switch {
+	case strings.HasPrefix(target, "@"):
+		if hasLabel {
+			return "@" + strings.TrimPrefix(label, "@")
+		}
+		return target
+	case strings.HasPrefix(target, "#"):
+		if hasLabel {
+			return "#" + strings.TrimPrefix(label, "#")
+		}
+		return target
+	case strings.HasPrefix(target, "!"):
+		if hasLabel {
+			return label
+		}
+		command, _, _ := strings.Cut(target[1:], "^")
+		return "@" + command
+	}
+
+	if mode == ModeKeep {
+		return "<" + inner + ">"
+	}
+	if !hasLabel || label == target {
+		return strings.TrimPrefix(target, "mailto:")
+	}
+	if mode == ModeMarkdown {
+		return fmt.Sprintf("[%s](%s)", label, target)
+	}
+	return label
+}
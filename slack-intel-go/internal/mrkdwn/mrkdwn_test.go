@@ -0,0 +1,56 @@
+package mrkdwn
+
+import "testing"
+
+func TestUnescape(t *testing.T) {
+	tests := map[string]string{
+		"a &amp; b":              "a & b",
+		"&lt;div&gt;":            "<div>",
+		"x &gt;= 3 &amp;&amp; y": "x >= 3 && y",
+		// A user typing "&lt;" is sent as "&amp;lt;" and must stay "&lt;"
+		"&amp;lt;":  "&lt;",
+		"&amp;amp;": "&amp;",
+		// Slack never sends other entities, so they are left alone
+		"&quot;hi&quot; &nbsp;": "&quot;hi&quot; &nbsp;",
+		"AT&amp;T":              "AT&T",
+		"no entities":           "no entities",
+	}
+	for in, want := range tests {
+		if got := Unescape(in); got != want {
+			t.Errorf("Unescape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in       string
+		strip    string
+		markdown string
+	}{
+		{"*deploy* is _done_ ~not~", "deploy is done not", "**deploy** is _done_ ~~not~~"},
+		{"*a* *b*", "a b", "**a** **b**"},
+		{"snake_case_name and 2*3*4", "snake_case_name and 2*3*4", "snake_case_name and 2*3*4"},
+		{"run `rm -rf *tmp*` &amp; wait", "run rm -rf *tmp* & wait", "run `rm -rf *tmp*` & wait"},
+		{"```\nif a &lt; b {\n    *x*\n}\n```", "if a < b {\n    *x*\n}", "```\nif a < b {\n    *x*\n}\n```"},
+		{"&gt; quoted &amp; *bold*\nreply", "quoted & bold\nreply", "> quoted & **bold**\nreply"},
+		{"todo:\n• one\n  ◦ two", "todo:\none\ntwo", "todo:\n- one\n- two"},
+		{"see <https://example.com/a?b=1&amp;c=2|the docs>", "see the docs", "see [the docs](https://example.com/a?b=1&c=2)"},
+		{"<https://example.com> <mailto:a@b.co|a@b.co>", "https://example.com a@b.co", "https://example.com [a@b.co](mailto:a@b.co)"},
+		{"<@U123> <@U456|bob> in <#C1|general>, <!here> <!subteam^S1|@oncall>", "@U123 @bob in #general, @here @oncall", "@U123 @bob in #general, @here @oncall"},
+		{"  lots   of\t\tspace \n\n\n\n next  ", "lots of space\n\nnext", "lots of space\n\nnext"},
+		{"1 &lt; 2", "1 < 2", "1 < 2"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.in, ModeStrip); got != tt.strip {
+			t.Errorf("Normalize(%q, strip) = %q, want %q", tt.in, got, tt.strip)
+		}
+		if got := Normalize(tt.in, ModeMarkdown); got != tt.markdown {
+			t.Errorf("Normalize(%q, markdown) = %q, want %q", tt.in, got, tt.markdown)
+		}
+	}
+
+	if got := Normalize("*keep* <https://x.io|x> &amp;  me", ModeKeep); got != "*keep* <https://x.io|x> & me" {
+		t.Errorf("Normalize(keep) = %q", got)
+	}
+}
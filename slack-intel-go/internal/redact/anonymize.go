@@ -0,0 +1,117 @@
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// PhoneMask replaces phone numbers in anonymized text
+const PhoneMask = "[REDACTED_PHONE]"
+
+var (
+	// phonePattern matches numbers written with separators, e.g.
+	// +48 601 234 567 or (555) 123-4567, so Slack timestamps and ticket
+	// numbers are left alone
+	phonePattern = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{2,4}\)[\s.-]?|\d{2,4}[\s.-])\d{3,4}[\s.-]\d{3,4}\b`)
+	// mentionPattern matches a user mention, <@U123> or <@U123|name>
+	mentionPattern = regexp.MustCompile(`<@([A-Z0-9]+)(?:\|[^>]*)?>`)
+)
+
+// Anonymizer replaces user IDs and names with pseudonyms derived from an
+// HMAC of the ID, so the same key gives the same pseudonym in every export
+// and datasets can still be joined. It remembers which ID each pseudonym
+// stands for.
+type Anonymizer struct {
+	key     []byte
+	mapping map[string]string
+}
+
+// NewAnonymizer creates an Anonymizer keyed with key
+func NewAnonymizer(key []byte) *Anonymizer {
+	return &Anonymizer{key: key, mapping: make(map[string]string)}
+}
+
+// Pseudonym returns the stable pseudonym for a user ID, e.g.
+// anon_3f9a1c2b7d4e; the empty ID stays empty
+func (a *Anonymizer) Pseudonym(id string) string {
+	if id == "" {
+		return ""
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(id))
+	p := "anon_" + hex.EncodeToString(mac.Sum(nil))[:12]
+	a.mapping[p] = id
+	return p
+}
+
+// Mapping returns pseudonym → user ID for every user seen so far
+func (a *Anonymizer) Mapping() map[string]string {
+	out := make(map[string]string, len(a.mapping))
+	for p, id := range a.mapping {
+		out[p] = id
+	}
+	return out
+}
+
+// Text masks emails, phone numbers and known secrets and replaces user
+// mentions with pseudonyms
+func (a *Anonymizer) Text(s string) string {
+	s = mentionPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return "<@" + a.Pseudonym(mentionPattern.FindStringSubmatch(m)[1]) + ">"
+	})
+	return phonePattern.ReplaceAllString(Text(s), PhoneMask)
+}
+
+// Message returns a copy of m with the author, reaction users and mentions
+// pseudonymized, emails and phone numbers masked in the text and file URLs
+// dropped. Blocks and the raw payload are dropped too, as they carry user
+// IDs in many shapes.
+// A nil message stays nil.
+func (a *Anonymizer) Message(m *models.SlackMessage) *models.SlackMessage {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	c.UserID = a.Pseudonym(m.UserID)
+	c.Text = a.Text(m.Text)
+	c.Blocks, c.Raw = nil, nil
+	if m.CleanText != "" {
+		c.CleanText = a.Text(m.CleanText)
+	}
+
+	if m.UserInfo != nil {
+		p := a.Pseudonym(m.UserInfo.ID)
+		c.UserInfo = &models.SlackUser{ID: p, Name: p, RealName: p, IsBot: m.UserInfo.IsBot}
+	}
+
+	if len(m.Reactions) > 0 {
+		c.Reactions = make([]models.SlackReaction, len(m.Reactions))
+		for i, r := range m.Reactions {
+			c.Reactions[i] = models.SlackReaction{Emoji: r.Emoji, Count: r.Count}
+			for _, id := range r.Users {
+				c.Reactions[i].Users = append(c.Reactions[i].Users, a.Pseudonym(id))
+			}
+		}
+	}
+
+	if len(m.Files) > 0 {
+		c.Files = make([]models.SlackFile, len(m.Files))
+		for i, f := range m.Files {
+			f.URL = ""
+			c.Files[i] = f
+		}
+	}
+
+	if len(m.URLs) > 0 {
+		c.URLs = make([]string, len(m.URLs))
+		for i, u := range m.URLs {
+			c.URLs[i] = a.Text(u)
+		}
+	}
+
+	return &c
+}
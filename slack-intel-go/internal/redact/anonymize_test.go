@@ -0,0 +1,77 @@
+package redact
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+func TestPseudonymIsStablePerKey(t *testing.T) {
+	a, b := NewAnonymizer([]byte("k1")), NewAnonymizer([]byte("k1"))
+	p := a.Pseudonym("U04ABCDE")
+	if !strings.HasPrefix(p, "anon_") || len(p) != len("anon_")+12 {
+		t.Errorf("pseudonym = %q, want anon_ and 12 hex digits", p)
+	}
+	if b.Pseudonym("U04ABCDE") != p {
+		t.Error("same key and id gave different pseudonyms")
+	}
+	if NewAnonymizer([]byte("k2")).Pseudonym("U04ABCDE") == p {
+		t.Error("different keys gave the same pseudonym")
+	}
+	if a.Pseudonym("U2") == p || a.Pseudonym("") != "" {
+		t.Error("pseudonyms should differ per id and stay empty for no id")
+	}
+	if m := a.Mapping(); m[p] != "U04ABCDE" || len(m) != 2 {
+		t.Errorf("mapping = %v, want %s → U04ABCDE and U2's entry", m, p)
+	}
+}
+
+func TestAnonymizeText(t *testing.T) {
+	a := NewAnonymizer([]byte("k"))
+	tests := []struct {
+		in, want string
+	}{
+		{"call +48 601 234 567 or (555) 123-4567", "call " + PhoneMask + " or " + PhoneMask},
+		{"mail bob@example.com", "mail " + EmailMask},
+		{"cc <@U2|bob> and <@U3>", "cc <@" + a.Pseudonym("U2") + "> and <@" + a.Pseudonym("U3") + ">"},
+		{"ts 1700000100.000100, PROJ-1234, v1.2.3", "ts 1700000100.000100, PROJ-1234, v1.2.3"},
+	}
+	for _, tt := range tests {
+		if got := a.Text(tt.in); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymizeMessage(t *testing.T) {
+	a := NewAnonymizer([]byte("k"))
+	orig := &models.SlackMessage{
+		MessageID: "1.0",
+		UserID:    "U1",
+		Text:      "reach me at alice@example.com",
+		UserInfo:  &models.SlackUser{ID: "U1", Name: "alice", RealName: "Alice Smith", Email: "alice@example.com"},
+		Reactions: []models.SlackReaction{{Emoji: "eyes", Count: 1, Users: []string{"U2"}}},
+		Files:     []models.SlackFile{{ID: "F1", Name: "notes.txt", URL: "https://files.slack.com/F1"}},
+		Blocks:    json.RawMessage(`[{"type":"rich_text","elements":[{"type":"user","user_id":"U2"}]}]`),
+	}
+
+	got := a.Message(orig)
+	p := a.Pseudonym("U1")
+	if got.UserID != p || *got.UserInfo != (models.SlackUser{ID: p, Name: p, RealName: p}) {
+		t.Errorf("author = %s %+v, want pseudonym %s without email", got.UserID, got.UserInfo, p)
+	}
+	if got.Text != "reach me at "+EmailMask {
+		t.Errorf("Text = %q", got.Text)
+	}
+	if got.Reactions[0].Users[0] != a.Pseudonym("U2") || got.Files[0].URL != "" || got.Files[0].Name != "notes.txt" {
+		t.Errorf("reactions %+v / files %+v, want pseudonymized users and no file URL", got.Reactions, got.Files)
+	}
+	if got.Blocks != nil {
+		t.Errorf("Blocks = %s, want them dropped", got.Blocks)
+	}
+	if orig.UserID != "U1" || orig.UserInfo.Email == "" || orig.Reactions[0].Users[0] != "U2" || orig.Files[0].URL == "" {
+		t.Error("Message modified its input")
+	}
+}
@@ -0,0 +1,129 @@
+// Package redact strips personal data and credentials from messages and
+// users before they are written, for caches that will be shared.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+const (
+	EmailMask  = "[REDACTED_EMAIL]"
+	SecretMask = "[REDACTED_SECRET]"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// secretPatterns match credentials that commonly get pasted into Slack
+	secretPatterns = []*regexp.Regexp{
+		regexp.MustCompile(`xox[abposre]-[A-Za-z0-9-]{10,}`),          // Slack tokens
+		regexp.MustCompile(`\bAKIA[0-9A-Z]{16}\b`),                    // AWS access key IDs
+		regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),          // GitHub tokens
+		regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`), // Authorization headers
+		regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`),
+	}
+)
+
+// Text masks email addresses and known secret formats
+func Text(s string) string {
+	for _, re := range secretPatterns {
+		s = re.ReplaceAllString(s, SecretMask)
+	}
+	return emailPattern.ReplaceAllString(s, EmailMask)
+}
+
+// User returns a copy of u without an email address
+func User(u *models.SlackUser) *models.SlackUser {
+	if u == nil {
+		return nil
+	}
+	c := *u
+	c.Email = ""
+	return &c
+}
+
+// Users returns redacted copies of every user
+func Users(users map[string]*models.SlackUser) map[string]*models.SlackUser {
+	out := make(map[string]*models.SlackUser, len(users))
+	for id, u := range users {
+		out[id] = User(u)
+	}
+	return out
+}
+
+// Blocks masks every string in Block Kit JSON. Blocks that do not parse
+// are dropped rather than passed on unmasked.
+func Blocks(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil
+	}
+	// Keep <, > and & as Slack wrote them instead of \u003c escapes
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(maskStrings(v)); err != nil {
+		return nil
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// maskStrings applies Text to the string values of decoded JSON
+func maskStrings(v interface{}) interface{} {
+	switch v := v.(type) {
+	case string:
+		return Text(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = maskStrings(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = maskStrings(v[k])
+		}
+	}
+	return v
+}
+
+// Message returns a copy of m with masked text, URLs and blocks, no author
+// email, no reaction user IDs and no raw payload. Reaction emoji and
+// counts are kept.
+func Message(m *models.SlackMessage) *models.SlackMessage {
+	c := *m
+	c.Text = Text(m.Text)
+	c.UserInfo = User(m.UserInfo)
+	c.Blocks = Blocks(m.Blocks)
+	c.Raw = nil
+
+	if len(m.URLs) > 0 {
+		c.URLs = make([]string, len(m.URLs))
+		for i, u := range m.URLs {
+			c.URLs[i] = Text(u)
+		}
+	}
+
+	if len(m.Reactions) > 0 {
+		c.Reactions = make([]models.SlackReaction, len(m.Reactions))
+		for i, r := range m.Reactions {
+			c.Reactions[i] = models.SlackReaction{Emoji: r.Emoji, Count: r.Count}
+		}
+	}
+
+	return &c
+}
+
+// Messages returns redacted copies of messages
+func Messages(messages []*models.SlackMessage) []*models.SlackMessage {
+	out := make([]*models.SlackMessage, len(messages))
+	for i, m := range messages {
+		out[i] = Message(m)
+	}
+	return out
+}
@@ -0,0 +1,121 @@
+package redact
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// Secrets is a set of values, such as API tokens, that must never appear
+// in logs. Safe for concurrent use.
+type Secrets struct {
+	mu     sync.RWMutex
+	values []string
+}
+
+// Add registers a secret; empty values are ignored
+func (s *Secrets) Add(secret string) {
+	if secret == "" {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, v := range s.values {
+		if v == secret {
+			return
+		}
+	}
+	s.values = append(s.values, secret)
+}
+
+// Mask replaces every registered secret in text with SecretMask
+func (s *Secrets) Mask(text string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, v := range s.values {
+		text = strings.ReplaceAll(text, v, SecretMask)
+	}
+	return text
+}
+
+// logHandler masks secrets in the message and attributes of each record.
+// Attributes and groups bound with WithAttrs and WithGroup are replayed
+// onto inner as each record is written, so secrets registered after a
+// logger.With are masked too.
+type logHandler struct {
+	inner   slog.Handler
+	secrets *Secrets
+	bound   []func(slog.Handler) slog.Handler
+}
+
+// NewLogHandler wraps h so no secret registered in secrets, now or later,
+// reaches its output: messages, string attributes and the text of other
+// values (errors, structs) are masked
+func NewLogHandler(h slog.Handler, secrets *Secrets) slog.Handler {
+	return &logHandler{inner: h, secrets: secrets}
+}
+
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.inner.Enabled(ctx, level)
+}
+
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	masked := slog.NewRecord(r.Time, r.Level, h.secrets.Mask(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(h.mask(a))
+		return true
+	})
+	inner := h.inner
+	for _, bind := range h.bound {
+		inner = bind(inner)
+	}
+	return inner.Handle(ctx, masked)
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.bind(func(inner slog.Handler) slog.Handler {
+		masked := make([]slog.Attr, len(attrs))
+		for i, a := range attrs {
+			masked[i] = h.mask(a)
+		}
+		return inner.WithAttrs(masked)
+	})
+}
+
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	return h.bind(func(inner slog.Handler) slog.Handler {
+		return inner.WithGroup(name)
+	})
+}
+
+// bind returns a copy of h that applies one more WithAttrs or WithGroup
+func (h *logHandler) bind(fn func(slog.Handler) slog.Handler) *logHandler {
+	bound := append(h.bound[:len(h.bound):len(h.bound)], fn)
+	return &logHandler{inner: h.inner, secrets: h.secrets, bound: bound}
+}
+
+// mask masks the secrets in an attribute's value
+func (h *logHandler) mask(a slog.Attr) slog.Attr {
+	a.Value = a.Value.Resolve()
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(h.secrets.Mask(a.Value.String()))
+	case slog.KindGroup:
+		group := a.Value.Group()
+		masked := make([]slog.Attr, len(group))
+		for i, g := range group {
+			masked[i] = h.mask(g)
+		}
+		a.Value = slog.GroupValue(masked...)
+	case slog.KindAny:
+		// Only rewrite values that would print a secret, keeping the
+		// rest (e.g. errors) as they are
+		text := fmt.Sprint(a.Value.Any())
+		if masked := h.secrets.Mask(text); masked != text {
+			a.Value = slog.StringValue(masked)
+		}
+	}
+	return a
+}
@@ -0,0 +1,32 @@
+package slack
+
+import (
+	"context"
+
+	"github.com/slack-go/slack"
+)
+
+// SlackAPI is the subset of the slack-go Web API client used by Client.
+// *slack.Client satisfies it; fakeslack provides an in-memory version.
+type SlackAPI interface {
+	AuthTestContext(ctx context.Context) (*slack.AuthTestResponse, error)
+	GetConversationsContext(ctx context.Context, params *slack.GetConversationsParameters) ([]slack.Channel, string, error)
+	GetConversationInfoContext(ctx context.Context, input *slack.GetConversationInfoInput) (*slack.Channel, error)
+	GetConversationHistoryContext(ctx context.Context, params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error)
+	GetConversationRepliesContext(ctx context.Context, params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error)
+	GetUserInfoContext(ctx context.Context, user string) (*slack.User, error)
+	GetUsersContext(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error)
+	ListPinsContext(ctx context.Context, channel string) ([]slack.Item, *slack.Paging, error)
+	GetEmojiContext(ctx context.Context) (map[string]string, error)
+	GetReactionsContext(ctx context.Context, item slack.ItemRef, params slack.GetReactionsParameters) ([]slack.ItemReaction, error)
+	PostMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (string, string, error)
+}
+
+var _ SlackAPI = (*slack.Client)(nil)
+
+// WithAPI replaces the Web API client, e.g. with a fakeslack.Fake
+func WithAPI(api SlackAPI) Option {
+	return func(c *Client) {
+		c.api = api
+	}
+}
@@ -0,0 +1,87 @@
+package slack
+
+import (
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// MaxSectionText is the most characters Slack accepts in the text of a
+// section block
+const MaxSectionText = 3000
+
+// Blocks are the Block Kit blocks of one message
+type Blocks = []slack.Block
+
+// maxHeaderText is the most characters of a header block
+const maxHeaderText = 150
+
+// SectionMessages lays out a title and mrkdwn parts as Block Kit messages:
+// a header block, then a section block per part. A message carries at
+// most MaxSectionText characters of section text, so a long digest goes
+// out as several messages, each headed by the title. A part that does not
+// fit is cut at a line break, or mid-line when a single line is too long.
+func SectionMessages(title string, parts []string) []Blocks {
+	var messages []Blocks
+	var current Blocks
+	size := 0
+	for _, part := range parts {
+		for rest := part; rest != ""; {
+			var head string
+			head, rest = cutLines(rest, MaxSectionText-size, size == 0)
+			if head == "" {
+				messages = append(messages, current)
+				current, size = nil, 0
+				continue
+			}
+			if len(current) == 0 {
+				heading := title
+				if len(messages) > 0 {
+					heading += " (continued)"
+				}
+				current = append(current, slack.NewHeaderBlock(slack.NewTextBlockObject(slack.PlainTextType, cut(heading, maxHeaderText), false, false)))
+			}
+			current = append(current, slack.NewSectionBlock(slack.NewTextBlockObject(slack.MarkdownType, head, false, false), nil, nil))
+			size += utf8.RuneCountInString(head)
+		}
+	}
+	if len(current) > 0 {
+		messages = append(messages, current)
+	}
+	return messages
+}
+
+// cutLines splits text after the most whole lines that fit in limit
+// characters. When not even the first line fits it returns "" and text,
+// or with hard set cuts that line at the limit.
+func cutLines(text string, limit int, hard bool) (head, rest string) {
+	if utf8.RuneCountInString(text) <= limit {
+		return text, ""
+	}
+	n, end := 0, 0
+	for i, r := range text {
+		if r == '\n' {
+			end = i
+		}
+		if n == limit {
+			break
+		}
+		n++
+	}
+	if end > 0 {
+		return text[:end], text[end+1:]
+	}
+	if !hard {
+		return "", text
+	}
+	runes := []rune(text)
+	return string(runes[:limit]), string(runes[limit:])
+}
+
+// cut shortens s to at most n characters, ending it with … when cut
+func cut(s string, n int) string {
+	if runes := []rune(s); len(runes) > n {
+		return string(runes[:n-1]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,51 @@
+package slack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestSectionMessages(t *testing.T) {
+	line := strings.Repeat("x", 99)
+	long := strings.TrimSuffix(strings.Repeat(line+"\n", 45), "\n") // 4499 characters
+
+	messages := SectionMessages("Digest", []string{"summary", long, "*JIRA tickets*"})
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(messages))
+	}
+	for i, blocks := range messages {
+		header, ok := blocks[0].(*slack.HeaderBlock)
+		if !ok {
+			t.Fatalf("message %d starts with %T, want a header", i, blocks[0])
+		}
+		size := 0
+		for _, b := range blocks[1:] {
+			text := b.(*slack.SectionBlock).Text.Text
+			if strings.HasPrefix(text, "\n") || strings.HasSuffix(text, "\n") {
+				t.Errorf("section %q not cut at a line break", text[:10])
+			}
+			size += len(text)
+		}
+		if size > MaxSectionText {
+			t.Errorf("message %d carries %d characters, want at most %d", i, size, MaxSectionText)
+		}
+		if i == 1 && header.Text.Text != "Digest (continued)" {
+			t.Errorf("second header = %q, want it marked continued", header.Text.Text)
+		}
+	}
+	if last := messages[1][len(messages[1])-1].(*slack.SectionBlock); last.Text.Text != "*JIRA tickets*" {
+		t.Errorf("last section = %q, want the tickets part", last.Text.Text)
+	}
+
+	if head, rest := cutLines("abc\ndef", 5, false); head != "abc" || rest != "def" {
+		t.Errorf("cutLines = %q, %q, want the first line", head, rest)
+	}
+	if head, _ := cutLines("abcdef", 5, false); head != "" {
+		t.Errorf("cutLines of a long line without hard = %q, want nothing", head)
+	}
+	if head, rest := cutLines(strings.Repeat("é", 7), 3, true); head != "ééé" || rest != "éééé" {
+		t.Errorf("cutLines with hard = %q, %q, want it cut at 3 characters", head, rest)
+	}
+}
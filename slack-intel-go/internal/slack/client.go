@@ -2,46 +2,610 @@ package slack
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
+	"net/http"
 	"regexp"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/slack-go/slack"
 	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/mrkdwn"
+	"golang.org/x/sync/errgroup"
 	"golang.org/x/time/rate"
 )
 
 // Client wraps Slack API with rate limiting and caching
 type Client struct {
-	api        *slack.Client
+	api         SlackAPI
+	token       string
+	tokenType   TokenType
+	teamURL     string
+	teamID      string
+	threadMode  ThreadMode
+	minReplies  int
+	progress    ProgressFunc
+	logger      *slog.Logger
+	workers     int
 	rateLimiter *rate.Limiter
-	userCache  map[string]*models.SlackUser
-	userMu     sync.RWMutex
+	userCache   map[string]*models.SlackUser
+	userMu      sync.RWMutex
+
+	// bulkUsers is how many uncached users make one users.list cheaper
+	// than per-user users.info calls; rosterLoaded is set once it ran
+	bulkUsers    int
+	rosterLoaded bool
+
+	// userTTL is how long a seeded user is reused, 0 for ever; staleUsers
+	// are the seeded users older than that, looked up again when next seen
+	// (guarded by userMu)
+	userTTL    time.Duration
+	staleUsers map[string]bool
+
+	// keepRaw stores each message's API payload in SlackMessage.Raw
+	keepRaw bool
+
+	// skipUsers leaves messages without UserInfo and makes no user lookups
+	skipUsers bool
+
+	// fullReactions refetches reactions whose users Slack truncated
+	fullReactions bool
+
+	// ticketSources are the parts of a message JIRA tickets come from
+	ticketSources TicketSources
+
+	// channelLimiters replace rateLimiter for the calls of channels with a
+	// rate limit override; filled by WithChannelRateLimits, read-only after
+	channelLimiters map[string]*rate.Limiter
+
+	// apiURL overrides the Web API endpoint; staticRate turns off the
+	// per-method limits tuned from rate-limit headers (adaptive)
+	apiURL     string
+	staticRate bool
+	adaptive   *adaptiveTransport
+
+	// disabled holds methods that failed with missing_scope/not_authed
+	disabled   map[string]error
+	disabledMu sync.Mutex
+
+	// calls counts Web API requests made, failed ones included
+	calls atomic.Int64
+
+	// maxMessages caps the timeline messages one GetHistory reads; 0 is
+	// no cap
+	maxMessages int
+	// maxCalls is the API call budget, 0 for none; reserved counts the
+	// calls taken from it, refused ones included
+	maxCalls int64
+	reserved atomic.Int64
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// ThreadMode controls which parts of a conversation GetMessages fetches
+type ThreadMode string
+
+const (
+	// ThreadModeAll fetches the timeline and every thread's replies
+	ThreadModeAll ThreadMode = "all"
+	// ThreadModeTopLevel fetches the timeline only, skipping thread replies
+	ThreadModeTopLevel ThreadMode = "top-level"
+	// ThreadModeThreadsOnly keeps thread parents and their replies only
+	ThreadModeThreadsOnly ThreadMode = "threads-only"
+)
+
+// ParseThreadMode maps the --threads flag (all|none|parents) to a ThreadMode
+func ParseThreadMode(s string) (ThreadMode, error) {
+	switch s {
+	case string(ThreadModeAll):
+		return ThreadModeAll, nil
+	case "none", string(ThreadModeTopLevel):
+		return ThreadModeTopLevel, nil
+	case "parents", string(ThreadModeThreadsOnly):
+		return ThreadModeThreadsOnly, nil
+	}
+	return "", fmt.Errorf("invalid thread mode %q (expected all, none or parents)", s)
+}
+
+// WithThreadMode sets which messages GetMessages returns
+func WithThreadMode(mode ThreadMode) Option {
+	return func(c *Client) {
+		c.threadMode = mode
+	}
+}
+
+// WithMinReplyCount expands only threads with at least n replies; smaller
+// threads keep just their parent and its reply_count. Values below 2
+// expand every thread.
+func WithMinReplyCount(n int) Option {
+	return func(c *Client) {
+		c.minReplies = n
+	}
+}
+
+// expandThread reports whether a message's replies are fetched
+func (c *Client) expandThread(msg *models.SlackMessage) bool {
+	return msg.IsThreadParent() && msg.ReplyCount >= c.minReplies
+}
+
+// Progress describes how far GetMessages has got for one channel
+type Progress struct {
+	Pages        int
+	Messages     int
+	ThreadsTotal int
+	ThreadsDone  int
 }
 
+// ThreadsRemaining returns how many thread reply fetches are outstanding
+func (p Progress) ThreadsRemaining() int {
+	return p.ThreadsTotal - p.ThreadsDone
+}
+
+// ProgressFunc receives progress updates from GetMessages. While thread
+// replies are fetched it is called from worker goroutines, one at a time.
+type ProgressFunc func(channelID string, p Progress)
+
+// WithProgress registers a callback for GetMessages progress
+func WithProgress(fn ProgressFunc) Option {
+	return func(c *Client) {
+		c.progress = fn
+	}
+}
+
+// reportProgress invokes the progress callback if one is registered
+func (c *Client) reportProgress(channelID string, p Progress) {
+	if c.progress != nil {
+		c.progress(channelID, p)
+	}
+}
+
+// DefaultWorkers is the default cap on concurrent thread and user fetches
+const DefaultWorkers = 10
+
+// WithWorkers caps how many thread-reply and user-info requests run at once.
+// Values below 1 keep the default.
+func WithWorkers(n int) Option {
+	return func(c *Client) {
+		if n > 0 {
+			c.workers = n
+		}
+	}
+}
+
+// DefaultBulkUserThreshold is how many uncached users in one batch switch
+// user lookups from users.info to a single paged users.list
+const DefaultBulkUserThreshold = 50
+
+// WithBulkUserThreshold sets how many uncached users in one batch trigger a
+// users.list roster fetch instead of per-user users.info calls. Values
+// below 1 always use users.info.
+func WithBulkUserThreshold(n int) Option {
+	return func(c *Client) {
+		c.bulkUsers = n
+	}
+}
+
+// WithUserTTL makes SeedUsers treat users cached (CachedAt) longer than
+// ttl ago, or at an unknown time, as stale: they are looked up again the
+// next time a message needs them, keeping the stale copy if that fails.
+// Fresh users are reused. 0 reuses every seeded user.
+func WithUserTTL(ttl time.Duration) Option {
+	return func(c *Client) {
+		c.userTTL = max(ttl, 0)
+	}
+}
+
+// WithChannelRateLimits gives each listed channel a limiter of its own
+// allowing that many requests per second, without bursts. Its history,
+// replies, info and pins calls wait on that limiter instead of the global
+// one, so a slow channel is throttled independently. Limits of 0 or less
+// are ignored.
+func WithChannelRateLimits(limits map[string]float64) Option {
+	return func(c *Client) {
+		for channelID, perSecond := range limits {
+			if perSecond <= 0 {
+				continue
+			}
+			if c.channelLimiters == nil {
+				c.channelLimiters = make(map[string]*rate.Limiter)
+			}
+			c.channelLimiters[channelID] = rate.NewLimiter(rate.Limit(perSecond), 1)
+		}
+	}
+}
+
+// WithRawPayloads keeps each message's original API payload as JSON in
+// SlackMessage.Raw, so derived fields can be rebuilt later with Reparse
+func WithRawPayloads(keep bool) Option {
+	return func(c *Client) {
+		c.keepRaw = keep
+	}
+}
+
+// WithMaxMessages caps the timeline messages GetHistory reads per call at
+// n, keeping the newest; paging stops there and the history is marked
+// Capped. Replies of the kept threads are still fetched. Values below 1
+// disable the cap.
+func WithMaxMessages(n int) Option {
+	return func(c *Client) {
+		c.maxMessages = max(n, 0)
+	}
+}
+
+// WithMaxAPICalls gives the client a budget of n Web API requests, shared
+// by every fetch; once it is spent further calls fail with ErrAPIBudget
+// without reaching Slack. Values below 1 disable the budget.
+func WithMaxAPICalls(n int64) Option {
+	return func(c *Client) {
+		c.maxCalls = max(n, 0)
+	}
+}
+
+// wait takes a call from the API budget, then waits for the rate limiter
+func (c *Client) wait(ctx context.Context, l *rate.Limiter) error {
+	if c.maxCalls > 0 && c.reserved.Add(1) > c.maxCalls {
+		return fmt.Errorf("%w (%d calls)", ErrAPIBudget, c.maxCalls)
+	}
+	return l.Wait(ctx)
+}
+
+// budgetErr returns ErrAPIBudget once a call was refused for lack of budget
+func (c *Client) budgetErr() error {
+	if c.maxCalls > 0 && c.reserved.Load() > c.maxCalls {
+		return fmt.Errorf("%w (%d calls)", ErrAPIBudget, c.maxCalls)
+	}
+	return nil
+}
+
+// WithSkipUsers skips user lookups, leaving each message's UserInfo nil;
+// its UserID is still set, so the users can be filled in later
+func WithSkipUsers(skip bool) Option {
+	return func(c *Client) {
+		c.skipUsers = skip
+	}
+}
+
+// WithFullReactions refetches with reactions.get the reactions of messages
+// whose users lists the history payload cut short (Slack lists about 50
+// users per reaction), so every giver is recorded. It needs the
+// reactions:read scope and one extra call per such message.
+func WithFullReactions(full bool) Option {
+	return func(c *Client) {
+		c.fullReactions = full
+	}
+}
+
+// limiter returns the rate limiter for a channel's calls: its own when it
+// has an override, the global one otherwise
+func (c *Client) limiter(channelID string) *rate.Limiter {
+	if l, ok := c.channelLimiters[channelID]; ok {
+		return l
+	}
+	return c.rateLimiter
+}
+
+// WithLogger sets the logger for warnings and per-call debug output
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// logCall records a Web API call at debug level with its latency
+func (c *Client) logCall(method string, start time.Time, err error, attrs ...any) {
+	c.calls.Add(1)
+	attrs = append([]any{"method", method, "latency", time.Since(start)}, attrs...)
+	if err != nil {
+		attrs = append(attrs, "error", err)
+	}
+	c.logger.Debug("slack api call", attrs...)
+}
+
+// APICalls returns how many Web API requests the client has made
+func (c *Client) APICalls() int64 {
+	return c.calls.Load()
+}
+
+// WithAPIURL points the client at a different Slack API endpoint (used by tests)
+func WithAPIURL(url string) Option {
+	return func(c *Client) {
+		c.api, c.apiURL = nil, url
+	}
+}
+
+// WithStaticRateLimit ignores the rate-limit headers of responses, so
+// calls only wait for the static global and per-channel limiters
+func WithStaticRateLimit(static bool) Option {
+	return func(c *Client) {
+		c.staticRate = static
+	}
+}
+
+// TunedRateLimits returns the calls per second of each API method whose
+// rate-limit headers currently lower its pace (0 while it is held back by
+// a Retry-After or a spent budget); empty when no response carried them
+func (c *Client) TunedRateLimits() map[string]float64 {
+	if c.adaptive == nil {
+		return map[string]float64{}
+	}
+	return c.adaptive.Limits(time.Now())
+}
+
+// TokenType identifies whether a token acts as a bot or as a user
+type TokenType string
+
+const (
+	TokenTypeBot     TokenType = "bot"
+	TokenTypeUser    TokenType = "user"
+	TokenTypeUnknown TokenType = "unknown"
+)
+
 // NewClient creates a new Slack client with rate limiting
-func NewClient(token string) *Client {
+func NewClient(token string, opts ...Option) *Client {
 	// Slack API rate limit: ~1 request per second per method
 	// Set to 20 requests/second with burst of 50 for safety
-	limiter := rate.NewLimiter(20, 50)
+	limiter := rate.NewLimiter(DefaultRateLimit, DefaultRateBurst)
+
+	c := &Client{
+		token:         token,
+		tokenType:     TokenTypeUnknown,
+		threadMode:    ThreadModeAll,
+		rateLimiter:   limiter,
+		logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+		workers:       DefaultWorkers,
+		userCache:     make(map[string]*models.SlackUser),
+		staleUsers:    make(map[string]bool),
+		bulkUsers:     DefaultBulkUserThreshold,
+		disabled:      make(map[string]error),
+		ticketSources: AllTicketSources,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.api == nil {
+		var apiOpts []slack.Option
+		if c.apiURL != "" {
+			apiOpts = append(apiOpts, slack.OptionAPIURL(c.apiURL))
+		}
+		if !c.staticRate {
+			c.adaptive = newAdaptiveTransport(http.DefaultTransport, func(msg string, args ...any) {
+				c.logger.Debug(msg, args...)
+			})
+			apiOpts = append(apiOpts, slack.OptionHTTPClient(&http.Client{Transport: c.adaptive}))
+		}
+		c.api = slack.New(token, apiOpts...)
+	}
+
+	return c
+}
 
-	return &Client{
-		api:         slack.New(token),
-		rateLimiter: limiter,
-		userCache:   make(map[string]*models.SlackUser),
+// DetectTokenType classifies a token from its prefix and the bot_id
+// returned by auth.test (only bot tokens report one)
+func DetectTokenType(token, botID string) TokenType {
+	switch {
+	case botID != "" || strings.HasPrefix(token, "xoxb-"):
+		return TokenTypeBot
+	case strings.HasPrefix(token, "xoxp-"):
+		return TokenTypeUser
+	case token == "":
+		return TokenTypeUnknown
+	default:
+		// Without a bot_id auth.test is describing a user
+		return TokenTypeUser
 	}
 }
 
+// AuthInfo describes the workspace identity behind an API token
+type AuthInfo struct {
+	Team      string
+	TeamID    string
+	User      string
+	UserID    string
+	URL       string
+	BotID     string
+	TokenType TokenType
+}
+
+// ValidateAuth calls auth.test to confirm the token is valid
+func (c *Client) ValidateAuth(ctx context.Context) (*AuthInfo, error) {
+	if err := c.wait(ctx, c.rateLimiter); err != nil {
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+
+	start := time.Now()
+	resp, err := c.api.AuthTestContext(ctx)
+	c.logCall("auth.test", start, err)
+	if err != nil {
+		return nil, fmt.Errorf("auth.test failed: %w", err)
+	}
+
+	c.tokenType = DetectTokenType(c.token, resp.BotID)
+	c.teamURL = resp.URL
+	c.teamID = resp.TeamID
+
+	return &AuthInfo{
+		Team:      resp.Team,
+		TeamID:    resp.TeamID,
+		User:      resp.User,
+		UserID:    resp.UserID,
+		URL:       resp.URL,
+		BotID:     resp.BotID,
+		TokenType: c.tokenType,
+	}, nil
+}
+
+// TokenType returns the token type detected by ValidateAuth
+func (c *Client) TokenType() TokenType {
+	return c.tokenType
+}
+
+// CanFetch reports whether the token type allows reading the channel.
+// Bot tokens cannot read direct message history, so DMs need a user token.
+func (c *Client) CanFetch(channelID string) error {
+	if c.tokenType == TokenTypeBot && IsDirectMessage(channelID) {
+		return fmt.Errorf("%s is a direct message; bot tokens cannot read DM history, use a user token (xoxp-)", channelID)
+	}
+	return nil
+}
+
+// IsDirectMessage reports whether the ID refers to a 1:1 DM conversation
+func IsDirectMessage(channelID string) bool {
+	return strings.HasPrefix(channelID, "D")
+}
+
+// ListChannels returns all non-archived channels visible to the token
+func (c *Client) ListChannels(ctx context.Context) ([]models.SlackChannel, error) {
+	var channels []models.SlackChannel
+
+	params := slack.GetConversationsParameters{
+		ExcludeArchived: true,
+		Limit:           200,
+		Types:           []string{"public_channel", "private_channel"},
+	}
+
+	for {
+		if err := c.wait(ctx, c.rateLimiter); err != nil {
+			return nil, fmt.Errorf("rate limiter: %w", err)
+		}
+
+		start := time.Now()
+		page, cursor, err := c.api.GetConversationsContext(ctx, &params)
+		c.logCall("conversations.list", start, err, "cursor", params.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list conversations: %w", err)
+		}
+
+		for _, ch := range page {
+			channels = append(channels, *convertChannel(&ch))
+		}
+
+		if cursor == "" {
+			break
+		}
+		params.Cursor = cursor
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Name < channels[j].Name
+	})
+
+	return channels, nil
+}
+
+// GetChannelInfo calls conversations.info to confirm the channel is
+// accessible and read its topic, purpose, member count and flags
+func (c *Client) GetChannelInfo(ctx context.Context, channelID string) (*models.SlackChannel, error) {
+	if err := c.wait(ctx, c.limiter(channelID)); err != nil {
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+
+	start := time.Now()
+	ch, err := c.api.GetConversationInfoContext(ctx, &slack.GetConversationInfoInput{ChannelID: channelID, IncludeNumMembers: true})
+	c.logCall("conversations.info", start, err, "channel", channelID)
+	if err != nil {
+		return nil, fmt.Errorf("conversations.info failed: %w", err)
+	}
+
+	return convertChannel(ch), nil
+}
+
+// convertChannel converts a conversations.list/info channel to our model
+func convertChannel(ch *slack.Channel) *models.SlackChannel {
+	channel := &models.SlackChannel{
+		Name:       ch.Name,
+		ID:         ch.ID,
+		Topic:      ch.Topic.Value,
+		Purpose:    ch.Purpose.Value,
+		NumMembers: ch.NumMembers,
+		IsPrivate:  ch.IsPrivate,
+		IsArchived: ch.IsArchived,
+	}
+	if ch.Created != 0 {
+		channel.Created = ch.Created.Time()
+	}
+	return channel
+}
+
+// ListPins returns the messages pinned in a channel via pins.list.
+// Pinned files and comments are skipped.
+func (c *Client) ListPins(ctx context.Context, channelID string) ([]*models.SlackMessage, error) {
+	if err := c.wait(ctx, c.limiter(channelID)); err != nil {
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+
+	start := time.Now()
+	items, _, err := c.api.ListPinsContext(ctx, channelID)
+	c.logCall("pins.list", start, err, "channel", channelID)
+	if err != nil {
+		return nil, fmt.Errorf("pins.list failed: %w", err)
+	}
+
+	var pins []*models.SlackMessage
+	for _, item := range items {
+		if item.Type != slack.TYPE_MESSAGE || item.Message == nil {
+			continue
+		}
+		pins = append(pins, c.convertMessage(channelID, item.Message))
+	}
+
+	return pins, nil
+}
+
+// History is what GetHistory read from one channel
+type History struct {
+	Messages []*models.SlackMessage
+	// Pages counts conversations.history responses; an empty channel still
+	// returns one page
+	Pages int
+	// Truncated is set when Slack reported has_more without a next cursor,
+	// so the window may not have been read completely
+	Truncated bool
+	// Capped is set when paging stopped at the WithMaxMessages cap, so
+	// older messages in the window were not read
+	Capped bool
+	// ThreadsFailed are the thread_ts of threads whose replies could not be
+	// fetched, even after a retry
+	ThreadsFailed []string
+	// Timeline and ThreadReplies split Messages into messages read from
+	// the channel timeline and replies fetched from their threads
+	Timeline, ThreadReplies int
+	// TimelineSkipped counts timeline messages left out by
+	// ThreadModeThreadsOnly because they start no thread
+	TimelineSkipped int
+	// ReactionsFetched counts messages whose truncated reactions were
+	// replaced from a successful reactions.get call (WithFullReactions)
+	ReactionsFetched int
+	// APICalls counts the Web API requests made for this history, user
+	// lookups and retries included. Concurrent fetches on one client
+	// count each other's requests too.
+	APICalls int
+}
+
 // GetMessages fetches messages from a channel within a time window
 func (c *Client) GetMessages(ctx context.Context, channelID string, startTime, endTime time.Time) ([]*models.SlackMessage, error) {
-	// Wait for rate limiter
-	if err := c.rateLimiter.Wait(ctx); err != nil {
-		return nil, fmt.Errorf("rate limiter: %w", err)
+	history, err := c.GetHistory(ctx, channelID, startTime, endTime)
+	if err != nil {
+		return nil, err
 	}
+	return history.Messages, nil
+}
 
-	log.Printf("Fetching messages for channel %s from %s to %s", channelID, startTime.Format(time.RFC3339), endTime.Format(time.RFC3339))
+// GetHistory fetches messages from a channel within a time window and
+// reports how completely the history was paged
+func (c *Client) GetHistory(ctx context.Context, channelID string, startTime, endTime time.Time) (*History, error) {
+	c.logger.Info("fetching messages", "channel", channelID, "oldest", startTime.Format(time.RFC3339), "latest", endTime.Format(time.RFC3339))
+	fetchedAt := time.Now()
+	callsBefore := c.APICalls()
 
 	params := slack.GetConversationHistoryParameters{
 		ChannelID: channelID,
@@ -50,84 +614,313 @@ func (c *Client) GetMessages(ctx context.Context, channelID string, startTime, e
 		Limit:     1000,
 	}
 
-	history, err := c.api.GetConversationHistoryContext(ctx, &params)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get conversation history: %w", err)
+	// Follow next_cursor until the window is exhausted
+	var history []slack.Message
+	var progress Progress
+	truncated, capped := false, false
+	for {
+		// Wait for rate limiter
+		if err := c.wait(ctx, c.limiter(channelID)); err != nil {
+			return nil, fmt.Errorf("rate limiter: %w", err)
+		}
+
+		start := time.Now()
+		page, err := c.api.GetConversationHistoryContext(ctx, &params)
+		c.logCall("conversations.history", start, err, "channel", channelID, "cursor", params.Cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get conversation history: %w", err)
+		}
+		history = append(history, page.Messages...)
+		c.logger.Debug("history page", "channel", channelID, "messages", len(page.Messages),
+			"has_more", page.HasMore, "pin_count", page.PinCount)
+
+		progress.Pages++
+		progress.Messages = len(history)
+		c.reportProgress(channelID, progress)
+
+		if page.HasMore && page.ResponseMetaData.NextCursor == "" {
+			truncated = true
+			c.logger.Warn("history has more messages but no cursor; results are truncated",
+				"channel", channelID, "pages", progress.Pages)
+		}
+		// Pages run newest first, so the cap keeps the newest messages
+		if c.maxMessages > 0 && (len(history) > c.maxMessages || len(history) == c.maxMessages && page.HasMore) {
+			history, capped = history[:c.maxMessages], true
+			c.logger.Warn("history capped; older messages in the window are not fetched",
+				"channel", channelID, "max_messages", c.maxMessages, "pages", progress.Pages)
+			break
+		}
+		if !page.HasMore || page.ResponseMetaData.NextCursor == "" {
+			break
+		}
+		params.Cursor = page.ResponseMetaData.NextCursor
+	}
+
+	// Threads-only mode keeps just the thread parents from the timeline
+	timeline := history
+	if c.threadMode == ThreadModeThreadsOnly {
+		timeline = make([]slack.Message, 0, len(history))
+		for _, msg := range history {
+			if msg.ThreadTimestamp == msg.Timestamp && msg.ReplyCount > 0 {
+				timeline = append(timeline, msg)
+			}
+		}
 	}
 
-	messages := make([]*models.SlackMessage, 0, len(history.Messages))
+	messages := make([]*models.SlackMessage, 0, len(timeline))
 	userIDs := make(map[string]bool)
 
 	// First pass: collect user IDs
-	for _, msg := range history.Messages {
+	for _, msg := range timeline {
 		if msg.User != "" {
 			userIDs[msg.User] = true
 		}
 	}
 
 	// Fetch user info in parallel (with concurrency limit)
-	if err := c.fetchUsersParallel(ctx, userIDs); err != nil {
-		log.Printf("Warning: failed to fetch some users: %v", err)
+	if err := c.fetchUsersParallel(ctx, userIDs); errors.Is(err, ErrUsersMissing) {
+		c.logger.Warn("failed to fetch some users", "channel", channelID, "error", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
 	}
 
 	// Second pass: convert messages and enrich with user info
-	for _, msg := range history.Messages {
-		message := c.convertMessage(&msg)
+	for _, msg := range timeline {
+		message := c.convertMessage(channelID, &msg)
 		messages = append(messages, message)
 	}
 
-	// Fetch thread replies for thread parents
-	threadMessages, err := c.fetchThreadReplies(ctx, channelID, messages)
-	if err != nil {
-		log.Printf("Warning: failed to fetch some thread replies: %v", err)
+	// Fetch thread replies for thread parents (skipped in top-level mode)
+	var threadMessages []*models.SlackMessage
+	var threadsFailed []string
+	if c.threadMode != ThreadModeTopLevel {
+		progress.Messages = len(messages)
+		threadMessages, threadsFailed = c.fetchThreadReplies(ctx, channelID, messages, progress)
 	}
 
 	// Merge thread replies with main messages
 	allMessages := append(messages, threadMessages...)
 
-	log.Printf("Fetched %d total messages (%d timeline, %d thread replies)",
-		len(allMessages), len(messages), len(threadMessages))
+	var reactionsFetched int
+	if c.fullReactions {
+		reactionsFetched = c.fetchFullReactions(ctx, channelID, allMessages)
+	}
+	// Lookups, threads and reactions refused for lack of budget leave the
+	// history incomplete
+	if err := c.budgetErr(); err != nil {
+		return nil, err
+	}
+
+	for _, msg := range allMessages {
+		msg.FetchedAt = fetchedAt
+	}
 
-	return allMessages, nil
+	c.logger.Info("fetched messages", "channel", channelID, "total", len(allMessages),
+		"timeline", len(messages), "thread_replies", len(threadMessages), "pages", progress.Pages)
+
+	return &History{
+		Messages:         allMessages,
+		Pages:            progress.Pages,
+		Truncated:        truncated,
+		Capped:           capped,
+		ThreadsFailed:    threadsFailed,
+		Timeline:         len(messages),
+		ThreadReplies:    len(threadMessages),
+		TimelineSkipped:  len(history) - len(timeline),
+		ReactionsFetched: reactionsFetched,
+		APICalls:         int(c.APICalls() - callsBefore),
+	}, nil
 }
 
-// fetchThreadReplies fetches all replies for thread parent messages
-func (c *Client) fetchThreadReplies(ctx context.Context, channelID string, messages []*models.SlackMessage) ([]*models.SlackMessage, error) {
-	var threadReplies []*models.SlackMessage
-	var mu sync.Mutex
-	var wg sync.WaitGroup
+// fetchThreadReplies fetches the replies of thread parent messages with
+// at least the minimum reply count. Threads that fail are retried once
+// after the others; it returns the replies and the thread_ts of threads
+// that failed both times.
+func (c *Client) fetchThreadReplies(ctx context.Context, channelID string, messages []*models.SlackMessage, progress Progress) ([]*models.SlackMessage, []string) {
+	var threads []string
+	skipped := 0
+	for _, msg := range messages {
+		switch {
+		case c.expandThread(msg):
+			threads = append(threads, msg.ThreadTS)
+		case msg.IsThreadParent():
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		c.logger.Debug("skipped small threads", "channel", channelID, "threads", skipped, "min_reply_count", c.minReplies)
+	}
+	if len(threads) == 0 {
+		return nil, nil
+	}
+	progress.ThreadsTotal = len(threads)
+	c.reportProgress(channelID, progress)
 
-	// Limit concurrent thread fetches
-	sem := make(chan struct{}, 10)
+	replies, failed, err := c.fetchThreads(ctx, channelID, threads, &progress, true)
+	// Retrying is pointless once cancelled or after an auth error
+	if len(failed) > 0 && ctx.Err() == nil && c.methodDisabled("conversations.replies") == nil && c.budgetErr() == nil {
+		c.logger.Debug("retrying failed threads", "channel", channelID, "threads", len(failed))
+		var retried []*models.SlackMessage
+		retried, failed, err = c.fetchThreads(ctx, channelID, failed, &progress, false)
+		replies = append(replies, retried...)
+	}
+	if len(failed) > 0 && !errors.Is(err, errMethodDisabled) && !errors.Is(err, ErrAPIBudget) {
+		c.logger.Warn("failed to fetch thread replies", "channel", channelID, "threads", len(failed), "error", err)
+	}
+	sort.Strings(failed)
+	return replies, failed
+}
+
+// fetchThreads fetches the replies of threads with up to c.workers requests
+// at once, returning the replies, the threads that failed and the last
+// error. Each thread counts toward progress.ThreadsDone when count is set.
+func (c *Client) fetchThreads(ctx context.Context, channelID string, threads []string, progress *Progress, count bool) ([]*models.SlackMessage, []string, error) {
+	var (
+		replies []*models.SlackMessage
+		failed  []string
+		lastErr error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
 
+	// Limit concurrent thread fetches; acquiring before spawning keeps at
+	// most c.workers goroutines alive instead of one per thread
+	sem := make(chan struct{}, c.workers)
+
+	for _, threadTS := range threads {
+		sem <- struct{}{} // Acquire
+		wg.Add(1)
+		go func(threadTS string) {
+			defer wg.Done()
+			defer func() { <-sem }() // Release
+
+			msgs, err := c.getThreadReplies(ctx, channelID, threadTS)
+			if err != nil {
+				c.logger.Debug("failed to fetch thread", "channel", channelID, "thread_ts", threadTS, "error", err)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failed = append(failed, threadTS)
+				lastErr = err
+			}
+			replies = append(replies, msgs...)
+			progress.Messages += len(msgs)
+			if count {
+				progress.ThreadsDone++
+			}
+			c.reportProgress(channelID, *progress)
+		}(threadTS)
+	}
+
+	wg.Wait()
+	return replies, failed, lastErr
+}
+
+// fetchFullReactions replaces the reactions of messages whose users lists
+// were truncated with the complete ones from reactions.get, with up to
+// c.workers requests at once. It returns how many messages got their
+// complete reactions; those whose call failed keep the truncated lists.
+func (c *Client) fetchFullReactions(ctx context.Context, channelID string, messages []*models.SlackMessage) int {
+	var truncated []*models.SlackMessage
 	for _, msg := range messages {
-		if msg.IsThreadParent() {
-			wg.Add(1)
-			go func(threadTS string) {
-				defer wg.Done()
-				sem <- struct{}{}        // Acquire
-				defer func() { <-sem }() // Release
-
-				replies, err := c.getThreadReplies(ctx, channelID, threadTS)
-				if err != nil {
-					log.Printf("Warning: failed to fetch thread %s: %v", threadTS, err)
-					return
-				}
+		if reactionsTruncated(msg.Reactions) {
+			truncated = append(truncated, msg)
+		}
+	}
+	if len(truncated) == 0 {
+		return 0
+	}
 
+	var (
+		failed  int
+		lastErr error
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+	)
+	sem := make(chan struct{}, c.workers)
+	for _, msg := range truncated {
+		sem <- struct{}{} // Acquire
+		wg.Add(1)
+		go func(msg *models.SlackMessage) {
+			defer wg.Done()
+			defer func() { <-sem }() // Release
+
+			reactions, err := c.getReactions(ctx, channelID, msg.MessageID)
+			if err != nil {
+				c.logger.Debug("failed to fetch reactions", "channel", channelID, "ts", msg.MessageID, "error", err)
 				mu.Lock()
-				threadReplies = append(threadReplies, replies...)
+				failed++
+				lastErr = err
 				mu.Unlock()
-			}(msg.ThreadTS)
+				return
+			}
+			msg.Reactions = reactions
+		}(msg)
+	}
+	wg.Wait()
+
+	if failed > 0 && !errors.Is(lastErr, errMethodDisabled) && !errors.Is(lastErr, ErrAPIBudget) {
+		c.logger.Warn("failed to fetch full reactions; keeping the truncated users", "channel", channelID, "messages", failed, "error", lastErr)
+	}
+	return len(truncated) - failed
+}
+
+// reactionsTruncated reports whether a reaction lists fewer users than
+// its count
+func reactionsTruncated(reactions []models.SlackReaction) bool {
+	for _, r := range reactions {
+		if r.Count > len(r.Users) {
+			return true
 		}
 	}
+	return false
+}
 
-	wg.Wait()
-	return threadReplies, nil
+// getReactions reads a message's reactions, listing every user, from
+// reactions.get
+func (c *Client) getReactions(ctx context.Context, channelID, ts string) ([]models.SlackReaction, error) {
+	if err := c.methodDisabled("reactions.get"); err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx, c.limiter(channelID)); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	reactions, err := c.api.GetReactionsContext(ctx, slack.NewRefToMessage(channelID, ts), slack.GetReactionsParameters{Full: true})
+	c.logCall("reactions.get", start, err, "channel", channelID, "ts", ts)
+	if err != nil {
+		return nil, c.checkAuthError("reactions.get", err)
+	}
+	return convertReactions(reactions), nil
 }
 
 // getThreadReplies fetches replies for a single thread
 func (c *Client) getThreadReplies(ctx context.Context, channelID, threadTS string) ([]*models.SlackMessage, error) {
-	if err := c.rateLimiter.Wait(ctx); err != nil {
+	msgs, err := c.threadMessages(ctx, channelID, threadTS)
+	if err != nil {
+		return nil, err
+	}
+
+	// Skip the parent and convert replies
+	replies := make([]*models.SlackMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		if msg.Timestamp == threadTS {
+			continue
+		}
+		replies = append(replies, c.convertMessage(channelID, &msg))
+	}
+
+	return replies, nil
+}
+
+// threadMessages pages conversations.replies for one thread, returning the
+// parent followed by its replies. Messages repeated across pages (Slack
+// resends the parent) are dropped.
+func (c *Client) threadMessages(ctx context.Context, channelID, threadTS string) ([]slack.Message, error) {
+	if err := c.methodDisabled("conversations.replies"); err != nil {
 		return nil, err
 	}
 
@@ -137,64 +930,363 @@ func (c *Client) getThreadReplies(ctx context.Context, channelID, threadTS strin
 		Limit:     1000,
 	}
 
-	msgs, _, _, err := c.api.GetConversationRepliesContext(ctx, &params)
+	var msgs []slack.Message
+	seen := make(map[string]bool)
+	for {
+		if err := c.wait(ctx, c.limiter(channelID)); err != nil {
+			return nil, err
+		}
+
+		start := time.Now()
+		page, hasMore, cursor, err := c.api.GetConversationRepliesContext(ctx, &params)
+		c.logCall("conversations.replies", start, err, "channel", channelID, "thread_ts", threadTS, "cursor", params.Cursor)
+		if err != nil {
+			return nil, c.checkAuthError("conversations.replies", err)
+		}
+
+		for _, msg := range page {
+			if !seen[msg.Timestamp] {
+				seen[msg.Timestamp] = true
+				msgs = append(msgs, msg)
+			}
+		}
+
+		if hasMore && cursor == "" {
+			c.logger.Warn("thread has more replies but no cursor; replies are truncated",
+				"channel", channelID, "thread_ts", threadTS, "replies", len(msgs)-1)
+		}
+		if !hasMore || cursor == "" {
+			break
+		}
+		params.Cursor = cursor
+	}
+
+	return msgs, nil
+}
+
+// GetThread fetches one thread, parent first, with user info attached.
+// A threadTS naming a reply is resolved to its parent's thread.
+func (c *Client) GetThread(ctx context.Context, channelID, threadTS string) ([]*models.SlackMessage, error) {
+	fetchedAt := time.Now()
+	msgs, err := c.threadMessages(ctx, channelID, threadTS)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("conversations.replies failed: %w", err)
+	}
+	if len(msgs) > 0 && msgs[0].ThreadTimestamp != "" && msgs[0].ThreadTimestamp != threadTS {
+		return c.GetThread(ctx, channelID, msgs[0].ThreadTimestamp)
 	}
 
-	// Skip first message (parent) and convert replies
-	replies := make([]*models.SlackMessage, 0, len(msgs)-1)
-	for i, msg := range msgs {
-		if i == 0 {
-			continue // Skip parent
+	userIDs := make(map[string]bool)
+	for _, msg := range msgs {
+		if msg.User != "" {
+			userIDs[msg.User] = true
 		}
-		replies = append(replies, c.convertMessage(&msg))
+	}
+	if err := c.fetchUsersParallel(ctx, userIDs); errors.Is(err, ErrUsersMissing) {
+		c.logger.Warn("failed to fetch some users", "channel", channelID, "error", err)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch users: %w", err)
 	}
 
-	return replies, nil
+	thread := make([]*models.SlackMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		message := c.convertMessage(channelID, &msg)
+		message.FetchedAt = fetchedAt
+		thread = append(thread, message)
+	}
+	return thread, nil
+}
+
+// LookupUsers fetches the users among userIDs that are not cached yet, the
+// way GetMessages does for message authors; GetUserInfo returns them
+// afterwards. Lookups that failed are summed up in an ErrUsersMissing error.
+func (c *Client) LookupUsers(ctx context.Context, userIDs []string) error {
+	ids := make(map[string]bool, len(userIDs))
+	for _, id := range userIDs {
+		ids[id] = true
+	}
+	return c.fetchUsersParallel(ctx, ids)
 }
 
-// fetchUsersParallel fetches multiple users in parallel with rate limiting
+// fetchUsersParallel fetches multiple users in parallel with rate limiting.
+// When at least bulkUsers of them are uncached the whole roster is loaded
+// from users.list first (once per client) and only stragglers, such as
+// users from other workspaces, go through users.info.
+//
+// Lookups stop being scheduled once ctx is cancelled or users.info fails
+// for good. A rejected token returns an ErrAuthFailed error and a
+// cancelled ctx its error; otherwise failed lookups (including a missing
+// users:read scope) are summed up in one ErrUsersMissing error, since the
+// messages are still usable without those users' names. A spent API
+// budget returns ErrAPIBudget. With WithSkipUsers nothing is looked up.
 func (c *Client) fetchUsersParallel(ctx context.Context, userIDs map[string]bool) error {
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, 10) // Limit to 10 concurrent requests
+	if c.skipUsers {
+		return nil
+	}
+	missing := c.uncachedUsers(userIDs)
 
-	for userID := range userIDs {
-		// Skip if already cached
-		c.userMu.RLock()
-		_, exists := c.userCache[userID]
-		c.userMu.RUnlock()
-		if exists {
-			continue
+	c.userMu.RLock()
+	loadRoster := c.bulkUsers > 0 && len(missing) >= c.bulkUsers && !c.rosterLoaded
+	c.userMu.RUnlock()
+	if loadRoster {
+		if err := c.loadRoster(ctx); isTokenError(err) {
+			return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+		} else if errors.Is(err, ErrAPIBudget) {
+			return err
+		} else if err != nil {
+			c.logger.Warn("users.list failed, falling back to users.info", "error", err)
 		}
+		missing = c.uncachedUsers(userIDs)
+	}
 
-		wg.Add(1)
-		go func(uid string) {
-			defer wg.Done()
-			sem <- struct{}{}        // Acquire
-			defer func() { <-sem }() // Release
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(c.workers)
+
+	var mu sync.Mutex
+	var failed int
+	var firstErr error
+	fail := func(n int, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		failed += n
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
 
-			if err := c.fetchUserInfo(ctx, uid); err != nil {
-				log.Printf("Warning: failed to fetch user %s: %v", uid, err)
+	for i, userID := range missing {
+		if gctx.Err() != nil {
+			break
+		}
+		if err := c.methodDisabled("users.info"); err != nil {
+			// Every remaining lookup would fail the same way
+			if isTokenError(err) {
+				return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+			}
+			fail(len(missing)-i, err)
+			break
+		}
+
+		uid := userID
+		g.Go(func() error {
+			err := c.fetchUserInfo(gctx, uid)
+			switch {
+			case err == nil:
+				return nil
+			case isTokenError(err):
+				// Cancels gctx, stopping the lookups still queued
+				return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+			case errors.Is(err, ErrAPIBudget):
+				return err
+			case gctx.Err() != nil:
+				return gctx.Err()
 			}
-		}(userID)
+			c.logger.Debug("failed to fetch user", "user", uid, "error", err)
+			fail(1, err)
+			return nil
+		})
 	}
 
-	wg.Wait()
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if failed > 0 {
+		return fmt.Errorf("%w: %d of %d users: %w", ErrUsersMissing, failed, len(missing), firstErr)
+	}
+	return nil
+}
+
+// uncachedUsers returns the IDs in userIDs missing from the user cache
+func (c *Client) uncachedUsers(userIDs map[string]bool) []string {
+	c.userMu.RLock()
+	defer c.userMu.RUnlock()
+
+	var missing []string
+	for userID := range userIDs {
+		if _, exists := c.userCache[userID]; !exists || c.staleUsers[userID] {
+			missing = append(missing, userID)
+		}
+	}
+	return missing
+}
+
+// loadRoster fills the user cache from users.list. It is only attempted
+// once per client, whether or not it succeeds.
+func (c *Client) loadRoster(ctx context.Context) error {
+	c.userMu.Lock()
+	c.rosterLoaded = true
+	c.userMu.Unlock()
+
+	users, err := c.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.userMu.Lock()
+	defer c.userMu.Unlock()
+	for _, user := range users {
+		c.userCache[user.ID] = user
+		delete(c.staleUsers, user.ID)
+	}
+	c.logger.Debug("loaded user roster", "users", len(users))
 	return nil
 }
 
 // fetchUserInfo fetches and caches a single user's info
 func (c *Client) fetchUserInfo(ctx context.Context, userID string) error {
-	if err := c.rateLimiter.Wait(ctx); err != nil {
+	if err := c.methodDisabled("users.info"); err != nil {
 		return err
 	}
+	if err := c.wait(ctx, c.rateLimiter); err != nil {
+		return err
+	}
+	// A stale user is refreshed at most once a run; on failure its old
+	// copy stays
+	c.userMu.Lock()
+	_, known := c.userCache[userID]
+	delete(c.staleUsers, userID)
+	c.userMu.Unlock()
 
+	start := time.Now()
 	user, err := c.api.GetUserInfoContext(ctx, userID)
+	c.logCall("users.info", start, err, "user", userID)
+	if isUnknownUser(err) {
+		if known {
+			return nil
+		}
+		// Slack Connect members of other workspaces are often invisible to
+		// users.info; remember them so the lookup is not repeated
+		c.logger.Debug("user not visible, recording as external", "user", userID, "error", err)
+		c.userMu.Lock()
+		c.userCache[userID] = &models.SlackUser{ID: userID, IsExternal: true}
+		c.userMu.Unlock()
+		return nil
+	}
 	if err != nil {
-		return err
+		return c.checkAuthError("users.info", err)
+	}
+
+	c.userMu.Lock()
+	c.userCache[userID] = c.convertUser(user)
+	c.userMu.Unlock()
+
+	return nil
+}
+
+// ListUsers pages users.list and returns every user in the workspace,
+// including deactivated ones
+func (c *Client) ListUsers(ctx context.Context) ([]*models.SlackUser, error) {
+	if err := c.methodDisabled("users.list"); err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx, c.rateLimiter); err != nil {
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+
+	start := time.Now()
+	members, err := c.api.GetUsersContext(ctx, slack.GetUsersOptionLimit(200))
+	c.logCall("users.list", start, err, "users", len(members))
+	if err != nil {
+		return nil, fmt.Errorf("users.list failed: %w", c.checkAuthError("users.list", err))
+	}
+
+	users := make([]*models.SlackUser, 0, len(members))
+	for i := range members {
+		users = append(users, c.convertUser(&members[i]))
+	}
+	return users, nil
+}
+
+// PostMessage posts text to a channel with chat.postMessage and returns
+// the new message's timestamp. Links are not unfurled.
+func (c *Client) PostMessage(ctx context.Context, channelID, text string) (string, error) {
+	return c.postMessage(ctx, channelID, slack.MsgOptionText(text, false))
+}
+
+// PostBlocks posts Block Kit blocks to a channel with chat.postMessage;
+// text is the notification and fallback text
+func (c *Client) PostBlocks(ctx context.Context, channelID, text string, blocks Blocks) (string, error) {
+	return c.postMessage(ctx, channelID, slack.MsgOptionText(text, false), slack.MsgOptionBlocks(blocks...))
+}
+
+func (c *Client) postMessage(ctx context.Context, channelID string, options ...slack.MsgOption) (string, error) {
+	if err := c.methodDisabled("chat.postMessage"); err != nil {
+		return "", err
+	}
+	if err := c.wait(ctx, c.rateLimiter); err != nil {
+		return "", fmt.Errorf("rate limiter: %w", err)
 	}
 
+	start := time.Now()
+	options = append(options, slack.MsgOptionDisableLinkUnfurl())
+	_, ts, err := c.api.PostMessageContext(ctx, channelID, options...)
+	c.logCall("chat.postMessage", start, err, "channel", channelID)
+	if err != nil {
+		return "", fmt.Errorf("chat.postMessage failed: %w", c.checkAuthError("chat.postMessage", err))
+	}
+	return ts, nil
+}
+
+// ListEmoji returns the workspace's custom emoji from emoji.list, sorted
+// by name. Values of the form "alias:<name>" become aliases.
+func (c *Client) ListEmoji(ctx context.Context) ([]*models.SlackEmoji, error) {
+	if err := c.methodDisabled("emoji.list"); err != nil {
+		return nil, err
+	}
+	if err := c.wait(ctx, c.rateLimiter); err != nil {
+		return nil, fmt.Errorf("rate limiter: %w", err)
+	}
+
+	start := time.Now()
+	emoji, err := c.api.GetEmojiContext(ctx)
+	c.logCall("emoji.list", start, err, "emoji", len(emoji))
+	if err != nil {
+		return nil, fmt.Errorf("emoji.list failed: %w", c.checkAuthError("emoji.list", err))
+	}
+
+	list := make([]*models.SlackEmoji, 0, len(emoji))
+	for name, value := range emoji {
+		e := &models.SlackEmoji{Name: name}
+		if alias, ok := strings.CutPrefix(value, "alias:"); ok {
+			e.AliasFor = alias
+		} else {
+			e.URL = value
+		}
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list, nil
+}
+
+// SeedUsers preloads the user cache (e.g. from users.parquet) so
+// GetMessages only calls users.info for users it has not seen, or whose
+// copy is older than the WithUserTTL
+func (c *Client) SeedUsers(users map[string]*models.SlackUser) {
+	c.userMu.Lock()
+	defer c.userMu.Unlock()
+	now := time.Now()
+	stale := 0
+	for id, user := range users {
+		c.userCache[id] = user
+		if c.userTTL > 0 && (user.CachedAt.IsZero() || now.Sub(user.CachedAt) > c.userTTL) {
+			c.staleUsers[id] = true
+			stale++
+		}
+	}
+	if stale > 0 {
+		c.logger.Debug("seeded users past their TTL", "users", stale, "ttl", c.userTTL)
+	}
+}
+
+// convertUser converts slack.User to models.SlackUser. Users of other
+// workspaces and guests are marked external; partial profiles, as returned
+// for Slack Connect users, fall back to the profile's names.
+func (c *Client) convertUser(user *slack.User) *models.SlackUser {
 	slackUser := &models.SlackUser{
 		ID:          user.ID,
 		Name:        user.Name,
@@ -202,13 +1294,28 @@ func (c *Client) fetchUserInfo(ctx context.Context, userID string) error {
 		DisplayName: user.Profile.DisplayName,
 		Email:       user.Profile.Email,
 		IsBot:       user.IsBot,
+		Title:       user.Profile.Title,
+		TZ:          user.TZ,
+		TZOffset:    user.TZOffset,
+		Image192:    user.Profile.Image192,
+		Deleted:     user.Deleted,
+		TeamID:      user.TeamID,
 	}
-
-	c.userMu.Lock()
-	c.userCache[userID] = slackUser
-	c.userMu.Unlock()
-
-	return nil
+	if user.Updated != 0 {
+		slackUser.UpdatedAt = user.Updated.Time()
+	}
+	if slackUser.TeamID == "" {
+		slackUser.TeamID = user.Profile.Team
+	}
+	if slackUser.RealName == "" {
+		slackUser.RealName = user.Profile.RealName
+	}
+	if slackUser.Name == "" {
+		slackUser.Name = user.Profile.DisplayName
+	}
+	slackUser.IsExternal = user.IsStranger || user.IsRestricted || user.IsUltraRestricted ||
+		(c.teamID != "" && slackUser.TeamID != "" && slackUser.TeamID != c.teamID)
+	return slackUser
 }
 
 // GetUserInfo retrieves cached user info
@@ -232,31 +1339,95 @@ func (c *Client) GetUserCache() map[string]*models.SlackUser {
 }
 
 // convertMessage converts slack.Message to models.SlackMessage
-func (c *Client) convertMessage(msg *slack.Message) *models.SlackMessage {
+func (c *Client) convertMessage(channelID string, msg *slack.Message) *models.SlackMessage {
+	message, err := messageFromPayload(msg, c.ticketSources)
+	if err != nil {
+		c.logger.Warn("dropping unencodable blocks", "channel", channelID, "ts", msg.Timestamp, "error", err)
+	}
+	message.Permalink = Permalink(c.teamURL, channelID, msg.Timestamp, msg.ThreadTimestamp)
+
+	// Attach cached user info
+	if msg.User != "" && !c.skipUsers {
+		message.UserInfo = c.GetUserInfo(msg.User)
+	}
+	// The message names the author's workspace when users.info could not
+	if info := message.UserInfo; info != nil && info.IsExternal && info.TeamID == "" && msg.Team != "" {
+		known := *info
+		known.TeamID = msg.Team
+		c.userMu.Lock()
+		c.userCache[msg.User] = &known
+		c.userMu.Unlock()
+		message.UserInfo = &known
+	}
+
+	if c.keepRaw {
+		raw, err := json.Marshal(msg)
+		if err != nil {
+			c.logger.Warn("dropping unencodable payload", "channel", channelID, "ts", msg.Timestamp, "error", err)
+		} else {
+			message.Raw = raw
+		}
+	}
+
+	return message
+}
+
+// Reparse rebuilds a stored message from its raw API payload, re-deriving
+// every field taken from the payload (text, threads, reactions, files,
+// pins, tickets, links, blocks) without calling Slack. User info,
+// permalink, fetch time and the payload itself are kept from stored.
+// JIRA tickets come from the given sources.
+func Reparse(stored *models.SlackMessage, sources TicketSources) (*models.SlackMessage, error) {
+	if len(stored.Raw) == 0 {
+		return nil, fmt.Errorf("message %s has no raw payload", stored.MessageID)
+	}
+	var msg slack.Message
+	if err := json.Unmarshal(stored.Raw, &msg); err != nil {
+		return nil, fmt.Errorf("failed to decode payload of %s: %w", stored.MessageID, err)
+	}
+
+	message, err := messageFromPayload(&msg, sources)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encode blocks of %s: %w", stored.MessageID, err)
+	}
+	message.UserInfo = stored.UserInfo
+	message.Permalink = stored.Permalink
+	message.FetchedAt = stored.FetchedAt
+	message.Raw = stored.Raw
+	return message, nil
+}
+
+// Rederive recomputes the fields derived from a stored message's text and
+// blocks (JIRA tickets and links) for messages cached without a raw
+// payload; attachments are not stored, so only payloads yield their tickets
+func Rederive(stored *models.SlackMessage, sources TicketSources) *models.SlackMessage {
+	c := *stored
+	c.JiraTickets = sources.tickets(stored.Text, nil, stored.Blocks)
+	c.URLs = extractURLs(stored.Text)
+	return &c
+}
+
+// messageFromPayload derives a message from its API payload alone, taking
+// JIRA tickets from sources. An error means the blocks could not be
+// encoded; the message is still returned without them.
+func messageFromPayload(msg *slack.Message, sources TicketSources) (*models.SlackMessage, error) {
 	ts, _ := parseSlackTimestamp(msg.Timestamp)
 
 	message := &models.SlackMessage{
 		MessageID:  msg.Timestamp,
 		UserID:     msg.User,
+		BotID:      msg.BotID,
 		Text:       msg.Text,
+		PinnedTo:   msg.PinnedTo,
 		Timestamp:  ts,
 		ThreadTS:   msg.ThreadTimestamp,
 		ReplyCount: msg.ReplyCount,
 	}
-
-	// Attach cached user info
-	if msg.User != "" {
-		message.UserInfo = c.GetUserInfo(msg.User)
+	if msg.Edited != nil {
+		message.EditedAt, _ = parseSlackTimestamp(msg.Edited.Timestamp)
 	}
 
-	// Convert reactions
-	for _, r := range msg.Reactions {
-		message.Reactions = append(message.Reactions, models.SlackReaction{
-			Emoji: r.Name,
-			Count: r.Count,
-			Users: r.Users,
-		})
-	}
+	message.Reactions = convertReactions(msg.Reactions)
 
 	// Convert files
 	for _, f := range msg.Files {
@@ -269,10 +1440,37 @@ func (c *Client) convertMessage(msg *slack.Message) *models.SlackMessage {
 		})
 	}
 
-	// Extract JIRA tickets
-	message.JiraTickets = extractJiraTickets(msg.Text)
+	// Extract shared links
+	message.URLs = extractURLs(msg.Text)
 
-	return message
+	// Keep Block Kit content (bot cards, approvals) that Text only summarizes
+	var err error
+	if len(msg.Blocks.BlockSet) > 0 {
+		var blocks []byte
+		if blocks, err = json.Marshal(msg.Blocks); err == nil {
+			message.Blocks = blocks
+		}
+	}
+
+	// Extract JIRA tickets, including those integrations post in
+	// attachments or blocks only
+	message.JiraTickets = sources.tickets(msg.Text, msg.Attachments, message.Blocks)
+
+	return message, err
+}
+
+// convertReactions converts reactions from a message payload or
+// reactions.get
+func convertReactions(reactions []slack.ItemReaction) []models.SlackReaction {
+	var converted []models.SlackReaction
+	for _, r := range reactions {
+		converted = append(converted, models.SlackReaction{
+			Emoji: r.Name,
+			Count: r.Count,
+			Users: r.Users,
+		})
+	}
+	return converted
 }
 
 // parseSlackTimestamp converts Slack timestamp string to time.Time
@@ -285,20 +1483,68 @@ func parseSlackTimestamp(ts string) (time.Time, error) {
 	return time.Unix(sec, nsec), nil
 }
 
-// extractJiraTickets extracts JIRA ticket IDs from text
-func extractJiraTickets(text string) []string {
-	re := regexp.MustCompile(`\b[A-Z]+-\d+\b`)
-	matches := re.FindAllString(text, -1)
+var (
+	// jiraRefPattern matches, in order of precedence, a Slack link
+	// (<url> or <url|label>), a plain URL, or a bare ticket key
+	jiraRefPattern = regexp.MustCompile(`<([^>|]+)(?:\|([^>]*))?>|https?://[^\s<>|]+|\b[A-Z]+-\d+\b`)
+	jiraKeyPattern = regexp.MustCompile(`\b[A-Z]+-\d+\b`)
+	// jiraBrowsePattern extracts the key from a .../browse/KEY-123 URL
+	jiraBrowsePattern = regexp.MustCompile(`/browse/([A-Z][A-Z0-9_]*-\d+)`)
+	// urlPattern matches a Slack link to a web URL (<url> or <url|label>)
+	// or a plain URL
+	urlPattern = regexp.MustCompile(`<(https?://[^>|]+)(?:\|[^>]*)?>|https?://[^\s<>|]+`)
+)
 
-	// Deduplicate
+// extractJiraTickets extracts JIRA ticket IDs from text, including keys in
+// Slack-formatted links and /browse/ URLs. Other URLs are ignored so path
+// segments are not mistaken for keys.
+func extractJiraTickets(text string) []string {
 	seen := make(map[string]bool)
 	var tickets []string
-	for _, match := range matches {
-		if !seen[match] {
-			tickets = append(tickets, match)
-			seen[match] = true
+	add := func(key string) {
+		if !seen[key] {
+			tickets = append(tickets, key)
+			seen[key] = true
+		}
+	}
+
+	for _, m := range jiraRefPattern.FindAllStringSubmatch(text, -1) {
+		switch {
+		case strings.HasPrefix(m[0], "<"):
+			if b := jiraBrowsePattern.FindStringSubmatch(m[1]); b != nil {
+				add(b[1])
+			}
+			for _, key := range jiraKeyPattern.FindAllString(m[2], -1) {
+				add(key)
+			}
+		case strings.HasPrefix(m[0], "http"):
+			if b := jiraBrowsePattern.FindStringSubmatch(m[0]); b != nil {
+				add(b[1])
+			}
+		default:
+			add(m[0])
 		}
 	}
 
 	return tickets
 }
+
+// extractURLs extracts the http(s) URLs shared in text, once each and in
+// order. Links in Slack syntax yield their target, not their label; plain
+// URLs lose trailing punctuation. Entities Slack escapes (&amp;) are decoded.
+func extractURLs(text string) []string {
+	seen := make(map[string]bool)
+	var urls []string
+	for _, m := range urlPattern.FindAllStringSubmatch(text, -1) {
+		u := m[1]
+		if u == "" {
+			u = strings.TrimRight(m[0], ".,;:!?)'\"")
+		}
+		u = mrkdwn.Unescape(u)
+		if !seen[u] {
+			urls = append(urls, u)
+			seen[u] = true
+		}
+	}
+	return urls
+}
@@ -0,0 +1,1564 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/slack-go/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack/fakeslack"
+)
+
+// fakeSlack serves canned Web API responses keyed by method name
+type fakeSlack struct {
+	mu       sync.Mutex
+	calls    map[string]int
+	handlers map[string]func(form url.Values) interface{}
+}
+
+// newFakeSlack starts an httptest server and returns a client pointed at it
+func newFakeSlack(t *testing.T, opts ...Option) (*fakeSlack, *Client) {
+	t.Helper()
+	fake := &fakeSlack{
+		calls:    make(map[string]int),
+		handlers: make(map[string]func(url.Values) interface{}),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		method := strings.TrimPrefix(r.URL.Path, "/")
+
+		fake.mu.Lock()
+		fake.calls[method]++
+		handler := fake.handlers[method]
+		fake.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		if handler == nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"ok": false, "error": "unknown_method"})
+			return
+		}
+		json.NewEncoder(w).Encode(handler(r.Form))
+	}))
+	t.Cleanup(srv.Close)
+
+	return fake, NewClient("xoxb-test", append([]Option{WithAPIURL(srv.URL + "/")}, opts...)...)
+}
+
+func (f *fakeSlack) handle(method string, handler func(form url.Values) interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.handlers[method] = handler
+}
+
+func (f *fakeSlack) callCount(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// msg builds a conversations.history/replies message payload
+func msg(ts, user, text, threadTS string, replies int) map[string]interface{} {
+	m := map[string]interface{}{"type": "message", "ts": ts, "user": user, "text": text}
+	if threadTS != "" {
+		m["thread_ts"] = threadTS
+	}
+	if replies > 0 {
+		m["reply_count"] = replies
+	}
+	return m
+}
+
+// seedChannel installs a timeline with one two-reply thread and one plain message
+func seedChannel(f *fakeSlack) {
+	f.handle("conversations.history", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg("1700000100.000100", "U1", "thread parent", "1700000100.000100", 2),
+			msg("1700000200.000100", "U2", "standalone", "", 0),
+		}}
+	})
+	f.handle("conversations.replies", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg("1700000100.000100", "U1", "thread parent", "1700000100.000100", 2),
+			msg("1700000101.000100", "U2", "reply one", "1700000100.000100", 0),
+			msg("1700000102.000100", "U1", "reply two", "1700000100.000100", 0),
+		}}
+	})
+	f.handle("users.info", func(form url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user"), "name": "user-" + form.Get("user")}}
+	})
+}
+
+func TestDetectTokenType(t *testing.T) {
+	tests := []struct {
+		token string
+		botID string
+		want  TokenType
+	}{
+		{"xoxb-123", "", TokenTypeBot},
+		{"xoxp-123", "", TokenTypeUser},
+		{"xoxe.xoxp-123", "B123", TokenTypeBot},
+		{"xoxe.xoxp-123", "", TokenTypeUser},
+		{"", "", TokenTypeUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := DetectTokenType(tt.token, tt.botID); got != tt.want {
+			t.Errorf("DetectTokenType(%q, %q) = %s, want %s", tt.token, tt.botID, got, tt.want)
+		}
+	}
+}
+
+func TestCanFetchSkipsDMsForBotTokens(t *testing.T) {
+	c := NewClient("xoxb-test")
+	c.tokenType = TokenTypeBot
+
+	if err := c.CanFetch("D0123456789"); err == nil {
+		t.Error("bot token should not fetch DMs")
+	}
+	if err := c.CanFetch("C0123456789"); err != nil {
+		t.Errorf("bot token should fetch channels: %v", err)
+	}
+
+	c.tokenType = TokenTypeUser
+	if err := c.CanFetch("D0123456789"); err != nil {
+		t.Errorf("user token should fetch DMs: %v", err)
+	}
+}
+
+func TestGetMessagesThreadModes(t *testing.T) {
+	tests := []struct {
+		mode        ThreadMode
+		wantTexts   []string
+		wantReplies int
+	}{
+		{ThreadModeAll, []string{"thread parent", "standalone", "reply one", "reply two"}, 1},
+		{ThreadModeTopLevel, []string{"thread parent", "standalone"}, 0},
+		{ThreadModeThreadsOnly, []string{"thread parent", "reply one", "reply two"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			fake, client := newFakeSlack(t, WithThreadMode(tt.mode))
+			seedChannel(fake)
+
+			msgs, err := client.GetMessages(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+			if err != nil {
+				t.Fatalf("GetMessages: %v", err)
+			}
+
+			var texts []string
+			for _, m := range msgs {
+				texts = append(texts, m.Text)
+			}
+			if strings.Join(texts, "|") != strings.Join(tt.wantTexts, "|") {
+				t.Errorf("got %v, want %v", texts, tt.wantTexts)
+			}
+			if got := fake.callCount("conversations.replies"); got != tt.wantReplies {
+				t.Errorf("conversations.replies called %d times, want %d", got, tt.wantReplies)
+			}
+		})
+	}
+}
+
+func TestGetHistoryStats(t *testing.T) {
+	tests := []struct {
+		mode ThreadMode
+		want History
+	}{
+		// history, replies and users.info for U1 and U2
+		{ThreadModeAll, History{Pages: 1, Timeline: 2, ThreadReplies: 2, APICalls: 4}},
+		{ThreadModeTopLevel, History{Pages: 1, Timeline: 2, APICalls: 3}},
+		{ThreadModeThreadsOnly, History{Pages: 1, Timeline: 1, ThreadReplies: 2, TimelineSkipped: 1, APICalls: 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			fake, client := newFakeSlack(t, WithThreadMode(tt.mode))
+			seedChannel(fake)
+
+			h, err := client.GetHistory(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+			if err != nil {
+				t.Fatalf("GetHistory: %v", err)
+			}
+			got := History{Pages: h.Pages, Timeline: h.Timeline, ThreadReplies: h.ThreadReplies, TimelineSkipped: h.TimelineSkipped, APICalls: h.APICalls}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("stats = %+v, want %+v", got, tt.want)
+			}
+			if len(h.Messages) != h.Timeline+h.ThreadReplies {
+				t.Errorf("%d messages, want timeline + thread replies = %d", len(h.Messages), h.Timeline+h.ThreadReplies)
+			}
+			if int64(h.APICalls) != client.APICalls() {
+				t.Errorf("APICalls = %d, client made %d", h.APICalls, client.APICalls())
+			}
+		})
+	}
+}
+
+func TestSkipUsers(t *testing.T) {
+	fake, client := newFakeSlack(t, WithSkipUsers(true))
+	seedChannel(fake)
+
+	msgs, err := client.GetMessages(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(msgs) != 4 {
+		t.Fatalf("got %d messages, want 4", len(msgs))
+	}
+	for _, m := range msgs {
+		if m.UserID == "" || m.UserInfo != nil {
+			t.Errorf("message %s: user %q, info %+v; want the ID without info", m.MessageID, m.UserID, m.UserInfo)
+		}
+	}
+	if n := fake.callCount("users.info") + fake.callCount("users.list"); n != 0 {
+		t.Errorf("made %d user lookup(s), want none", n)
+	}
+}
+
+func TestMinReplyCountSkipsSmallThreads(t *testing.T) {
+	fake, client := newFakeSlack(t, WithMinReplyCount(3))
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg("1700000100.000100", "U1", "small thread", "1700000100.000100", 2),
+			msg("1700000200.000100", "U1", "big thread", "1700000200.000100", 3),
+		}}
+	})
+	var requested []string
+	var mu sync.Mutex
+	fake.handle("conversations.replies", func(form url.Values) interface{} {
+		mu.Lock()
+		requested = append(requested, form.Get("ts"))
+		mu.Unlock()
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg("1700000200.000100", "U1", "big thread", "1700000200.000100", 3),
+			msg("1700000201.000100", "U1", "reply", "1700000200.000100", 0),
+		}}
+	})
+	fake.handle("users.info", func(form url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user"), "name": "u"}}
+	})
+
+	msgs, err := client.GetMessages(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(requested) != 1 || requested[0] != "1700000200.000100" {
+		t.Errorf("conversations.replies requested for %v, want only the big thread", requested)
+	}
+	if len(msgs) != 3 || msgs[0].Text != "small thread" || msgs[0].ReplyCount != 2 {
+		t.Errorf("got %d messages, first %+v; want the small thread parent with its reply count", len(msgs), msgs[0])
+	}
+}
+
+func TestThreadFailuresRetriedAndReported(t *testing.T) {
+	const flaky, broken, fine = "1700000100.000100", "1700000200.000100", "1700000300.000100"
+	fake, client := newFakeSlack(t)
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg(flaky, "U1", "flaky", flaky, 1),
+			msg(broken, "U1", "broken", broken, 1),
+			msg(fine, "U1", "fine", fine, 1),
+		}}
+	})
+	var mu sync.Mutex
+	calls := map[string]int{}
+	fake.handle("conversations.replies", func(form url.Values) interface{} {
+		ts := form.Get("ts")
+		mu.Lock()
+		calls[ts]++
+		n := calls[ts]
+		mu.Unlock()
+		if ts == broken || (ts == flaky && n == 1) {
+			return map[string]interface{}{"ok": false, "error": "internal_error"}
+		}
+		reply := strings.Replace(ts, ".000100", ".000200", 1)
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg(ts, "U1", "parent", ts, 1),
+			msg(reply, "U1", "reply", ts, 0),
+		}}
+	})
+	fake.handle("users.info", func(form url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user")}}
+	})
+
+	h, err := client.GetHistory(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+	if err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if len(h.ThreadsFailed) != 1 || h.ThreadsFailed[0] != broken {
+		t.Errorf("ThreadsFailed = %v, want only %s", h.ThreadsFailed, broken)
+	}
+	if len(h.Messages) != 5 {
+		t.Errorf("got %d messages, want 3 parents and the replies of the flaky and fine threads", len(h.Messages))
+	}
+	if calls[flaky] != 2 || calls[broken] != 2 || calls[fine] != 1 {
+		t.Errorf("conversations.replies calls = %v, want failed threads retried once", calls)
+	}
+}
+
+func TestConvertMessageKeepsBlocks(t *testing.T) {
+	fake, client := newFakeSlack(t, WithThreadMode(ThreadModeTopLevel))
+	card := msg("1700000100.000100", "U1", "Deploy approval requested", "", 0)
+	card["blocks"] = []interface{}{
+		map[string]interface{}{"type": "section", "block_id": "summary",
+			"text": map[string]interface{}{"type": "mrkdwn", "text": "*api* v1.4.2 to production"}},
+		map[string]interface{}{"type": "actions", "block_id": "decision", "elements": []interface{}{
+			map[string]interface{}{"type": "button", "action_id": "approve", "value": "yes",
+				"text": map[string]interface{}{"type": "plain_text", "text": "Approve"}},
+		}},
+	}
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "messages": []interface{}{card, msg("1700000200.000100", "U2", "plain", "", 0)}}
+	})
+	fake.handle("users.info", func(form url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user")}}
+	})
+
+	msgs, err := client.GetMessages(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+	if msgs[1].Blocks != nil {
+		t.Errorf("plain message blocks = %s, want none", msgs[1].Blocks)
+	}
+
+	var blocks slack.Blocks
+	if err := json.Unmarshal(msgs[0].Blocks, &blocks); err != nil {
+		t.Fatalf("blocks %s do not parse: %v", msgs[0].Blocks, err)
+	}
+	if len(blocks.BlockSet) != 2 {
+		t.Fatalf("got %d blocks, want section and actions", len(blocks.BlockSet))
+	}
+	section, ok := blocks.BlockSet[0].(*slack.SectionBlock)
+	if !ok || section.BlockID != "summary" || section.Text.Text != "*api* v1.4.2 to production" {
+		t.Errorf("block 0 = %+v, want the summary section", blocks.BlockSet[0])
+	}
+	actions, ok := blocks.BlockSet[1].(*slack.ActionBlock)
+	if !ok || len(actions.Elements.ElementSet) != 1 {
+		t.Fatalf("block 1 = %+v, want an actions block with one button", blocks.BlockSet[1])
+	}
+	if button, ok := actions.Elements.ElementSet[0].(*slack.ButtonBlockElement); !ok || button.ActionID != "approve" || button.Value != "yes" {
+		t.Errorf("element = %+v, want the approve button", actions.Elements.ElementSet[0])
+	}
+}
+
+func TestTicketsFromAttachmentsAndBlocks(t *testing.T) {
+	card := msg("1700000100.000100", "UBOT", "New issue created", "", 0)
+	card["attachments"] = []interface{}{map[string]interface{}{
+		"pretext":    "Created by alice",
+		"title":      "OPS-12: Disk full on db-1",
+		"title_link": "https://jira.example.com/browse/OPS-12",
+		"text":       "Blocks OPS-9",
+	}}
+	card["blocks"] = []interface{}{
+		map[string]interface{}{"type": "section", "text": map[string]interface{}{"type": "mrkdwn", "text": "PR links *OPS-12*"}},
+		map[string]interface{}{"type": "actions", "elements": []interface{}{map[string]interface{}{
+			"type": "button", "action_id": "open", "text": map[string]interface{}{"type": "plain_text", "text": "Open"},
+			"url": "https://jira.example.com/browse/PLAT-3",
+		}}},
+	}
+	plain := msg("1700000200.000100", "U1", "chasing ENG-4", "", 0)
+
+	tests := []struct {
+		name    string
+		sources TicketSources
+		want    []string
+	}{
+		{"all", AllTicketSources, []string{"OPS-12", "OPS-9", "PLAT-3"}},
+		{"text only", TicketSources{Text: true}, nil},
+		{"attachments", TicketSources{Attachments: true}, []string{"OPS-12", "OPS-9"}},
+		{"blocks", TicketSources{Blocks: true}, []string{"OPS-12", "PLAT-3"}},
+	}
+	for _, tt := range tests {
+		fake, client := newFakeSlack(t, WithThreadMode(ThreadModeTopLevel), WithSkipUsers(true), WithTicketSources(tt.sources))
+		fake.handle("conversations.history", func(url.Values) interface{} {
+			return map[string]interface{}{"ok": true, "messages": []interface{}{plain, card}}
+		})
+
+		msgs, err := client.GetMessages(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+		if err != nil {
+			t.Fatalf("%s: GetMessages: %v", tt.name, err)
+		}
+		var wantPlain []string
+		if tt.sources.Text {
+			wantPlain = []string{"ENG-4"}
+		}
+		for _, m := range msgs {
+			want := tt.want
+			if m.MessageID == "1700000200.000100" {
+				want = wantPlain
+			}
+			if !reflect.DeepEqual(m.JiraTickets, want) {
+				t.Errorf("%s: tickets of %s = %v, want %v", tt.name, m.MessageID, m.JiraTickets, want)
+			}
+		}
+	}
+}
+
+func TestReparseRebuildsFromRawPayload(t *testing.T) {
+	fake, client := newFakeSlack(t, WithThreadMode(ThreadModeTopLevel), WithRawPayloads(true))
+	card := msg("1700000100.000100", "U1", "PROJ-7 is live, see <https://status.example.com|status>", "", 0)
+	card["reactions"] = []interface{}{map[string]interface{}{"name": "tada", "count": 1, "users": []string{"U2"}}}
+	card["pinned_to"] = []string{"C1"}
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "messages": []interface{}{card}}
+	})
+	fake.handle("users.info", func(form url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user"), "name": "alice"}}
+	})
+
+	msgs, err := client.GetMessages(context.Background(), "C1", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(msgs) != 1 || len(msgs[0].Raw) == 0 {
+		t.Fatalf("messages = %+v, want one with its payload", msgs)
+	}
+
+	// What a cache written before tickets, links and reactions were parsed holds
+	stored := &models.SlackMessage{
+		MessageID: msgs[0].MessageID, Text: "stale", UserInfo: msgs[0].UserInfo,
+		Permalink: "https://acme.slack.com/archives/C1/p1700000100000100", FetchedAt: time.Unix(1700000500, 0), Raw: msgs[0].Raw,
+	}
+	got, err := Reparse(stored, AllTicketSources)
+	if err != nil {
+		t.Fatalf("Reparse: %v", err)
+	}
+	if got.Text != msgs[0].Text || got.UserID != "U1" || !got.Timestamp.Equal(msgs[0].Timestamp) || !got.IsPinned() {
+		t.Errorf("reparsed = %+v, want text, author, time and pin from the payload", got)
+	}
+	if len(got.JiraTickets) != 1 || got.JiraTickets[0] != "PROJ-7" || len(got.URLs) != 1 || len(got.Reactions) != 1 || got.Reactions[0].Users[0] != "U2" {
+		t.Errorf("derived = %v %v %+v, want PROJ-7, the status link and tada by U2", got.JiraTickets, got.URLs, got.Reactions)
+	}
+	if got.UserInfo == nil || got.UserInfo.Name != "alice" || got.Permalink != stored.Permalink || !got.FetchedAt.Equal(stored.FetchedAt) {
+		t.Errorf("reparsed = %+v, want user info, permalink and fetch time kept", got)
+	}
+
+	if _, err := Reparse(&models.SlackMessage{MessageID: "1.0"}, AllTicketSources); err == nil {
+		t.Error("Reparse without payload succeeded")
+	}
+}
+
+func TestRederiveFromStoredText(t *testing.T) {
+	stored := &models.SlackMessage{MessageID: "1.0", Text: "PROJ-3 via <https://jira.example.com/browse/OPS-9|ticket>", JiraTickets: []string{"PROJ-3"}}
+	got := Rederive(stored, AllTicketSources)
+	if strings.Join(got.JiraTickets, ",") != "PROJ-3,OPS-9" || len(got.URLs) != 1 {
+		t.Errorf("Rederive = %v %v, want PROJ-3, OPS-9 and the JIRA link", got.JiraTickets, got.URLs)
+	}
+	if len(stored.JiraTickets) != 1 || stored.URLs != nil {
+		t.Error("Rederive modified its input")
+	}
+
+	// Stored blocks are scanned too, unless the sources leave them out
+	stored.Blocks = json.RawMessage(`[{"type":"section","text":{"type":"mrkdwn","text":"deploys PLAT-2"}}]`)
+	if got := Rederive(stored, AllTicketSources); strings.Join(got.JiraTickets, ",") != "PROJ-3,OPS-9,PLAT-2" {
+		t.Errorf("Rederive with blocks = %v, want PROJ-3, OPS-9 and PLAT-2", got.JiraTickets)
+	}
+	if got := Rederive(stored, TicketSources{Text: true}); strings.Join(got.JiraTickets, ",") != "PROJ-3,OPS-9" {
+		t.Errorf("Rederive of text only = %v, want PROJ-3 and OPS-9", got.JiraTickets)
+	}
+}
+
+func TestParseThreadMode(t *testing.T) {
+	for flag, want := range map[string]ThreadMode{"all": ThreadModeAll, "none": ThreadModeTopLevel, "parents": ThreadModeThreadsOnly} {
+		if got, err := ParseThreadMode(flag); err != nil || got != want {
+			t.Errorf("ParseThreadMode(%q) = %s, %v; want %s", flag, got, err, want)
+		}
+	}
+	if _, err := ParseThreadMode("some"); err == nil {
+		t.Error("expected error for invalid mode")
+	}
+}
+
+func TestScopeErrorWarnsOnce(t *testing.T) {
+	var buf bytes.Buffer
+	fake, c := newFakeSlack(t, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		var messages []interface{}
+		for i := 0; i < 20; i++ {
+			messages = append(messages, msg(fmt.Sprintf("17000001%02d.000100", i), fmt.Sprintf("U%02d", i), "hi", "", 0))
+		}
+		return map[string]interface{}{"ok": true, "messages": messages}
+	})
+	fake.handle("users.info", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": false, "error": "missing_scope"}
+	})
+
+	end := time.Unix(1700001000, 0)
+	for i := 0; i < 2; i++ {
+		if _, err := c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end); err != nil {
+			t.Fatalf("GetMessages: %v", err)
+		}
+	}
+
+	if n := strings.Count(buf.String(), "users:read"); n != 1 {
+		t.Errorf("got %d scope warnings, want 1:\n%s", n, buf.String())
+	}
+	if strings.Contains(buf.String(), `msg="failed to fetch user"`) {
+		t.Errorf("per-user warnings logged after scope failure:\n%s", buf.String())
+	}
+
+	calls := fake.callCount("users.info")
+	if _, err := c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end); err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if fake.callCount("users.info") != calls {
+		t.Error("users.info called again after being disabled")
+	}
+}
+
+func TestTokenErrorHintsAtToken(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewClient("xoxb-test", WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+
+	c.checkAuthError("users.info", slack.SlackErrorResponse{Err: "token_revoked"})
+	if strings.Contains(buf.String(), "users:read") || !strings.Contains(buf.String(), "SLACK_API_TOKEN is valid") {
+		t.Errorf("revoked token warning should point at the token, not a scope:\n%s", buf.String())
+	}
+}
+
+func TestFetchUsersErrors(t *testing.T) {
+	const workers = 2
+	users := make(map[string]bool)
+	for i := 0; i < 20; i++ {
+		users[fmt.Sprintf("U%02d", i)] = true
+	}
+
+	t.Run("auth failed", func(t *testing.T) {
+		fake, c := newFakeSlack(t, WithWorkers(workers), WithBulkUserThreshold(0))
+		fake.handle("users.info", func(url.Values) interface{} {
+			return map[string]interface{}{"ok": false, "error": "token_revoked"}
+		})
+
+		err := c.fetchUsersParallel(context.Background(), users)
+		if !errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrUsersMissing) {
+			t.Fatalf("err = %v, want ErrAuthFailed", err)
+		}
+		if n := fake.callCount("users.info"); n > workers {
+			t.Errorf("users.info called %d times after the token was rejected, want at most %d", n, workers)
+		}
+	})
+
+	t.Run("auth failure fails GetMessages", func(t *testing.T) {
+		fake, c := newFakeSlack(t)
+		seedChannel(fake)
+		fake.handle("users.info", func(url.Values) interface{} {
+			return map[string]interface{}{"ok": false, "error": "invalid_auth"}
+		})
+
+		end := time.Unix(1700001000, 0)
+		if _, err := c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end); !errors.Is(err, ErrAuthFailed) {
+			t.Errorf("GetMessages err = %v, want ErrAuthFailed", err)
+		}
+	})
+
+	t.Run("some users missing", func(t *testing.T) {
+		fake, c := newFakeSlack(t, WithWorkers(workers), WithBulkUserThreshold(0))
+		fake.handle("users.info", func(form url.Values) interface{} {
+			if form.Get("user") == "U07" {
+				return map[string]interface{}{"ok": false, "error": "internal_error"}
+			}
+			return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user")}}
+		})
+
+		err := c.fetchUsersParallel(context.Background(), users)
+		if !errors.Is(err, ErrUsersMissing) || errors.Is(err, ErrAuthFailed) {
+			t.Fatalf("err = %v, want ErrUsersMissing", err)
+		}
+		if !strings.Contains(err.Error(), "1 of 20 users") {
+			t.Errorf("err = %v, want it to count the one missing user", err)
+		}
+		if n := len(c.GetUserCache()); n != 19 {
+			t.Errorf("cached %d users, want 19", n)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		fake, c := newFakeSlack(t, WithWorkers(workers), WithBulkUserThreshold(0))
+		ctx, cancel := context.WithCancel(context.Background())
+		fake.handle("users.info", func(form url.Values) interface{} {
+			cancel()
+			return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user")}}
+		})
+
+		if err := c.fetchUsersParallel(ctx, users); !errors.Is(err, context.Canceled) {
+			t.Fatalf("err = %v, want context.Canceled", err)
+		}
+		if n := fake.callCount("users.info"); n > workers {
+			t.Errorf("users.info called %d times after cancellation, want at most %d", n, workers)
+		}
+	})
+}
+
+func TestGetMessagesPaginatesAndReportsProgress(t *testing.T) {
+	var updates []Progress
+	fake, c := newFakeSlack(t, WithProgress(func(_ string, p Progress) {
+		updates = append(updates, p)
+	}))
+	seedChannel(fake)
+	fake.handle("conversations.history", func(form url.Values) interface{} {
+		if form.Get("cursor") == "" {
+			return map[string]interface{}{"ok": true, "has_more": true,
+				"response_metadata": map[string]interface{}{"next_cursor": "page2"},
+				"messages": []interface{}{
+					msg("1700000200.000100", "U2", "standalone", "", 0),
+				}}
+		}
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg("1700000100.000100", "U1", "thread parent", "1700000100.000100", 2),
+		}}
+	})
+
+	end := time.Unix(1700001000, 0)
+	got, err := c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if len(got) != 4 {
+		t.Errorf("got %d messages, want 4 (2 timeline across pages + 2 replies)", len(got))
+	}
+	if n := fake.callCount("conversations.history"); n != 2 {
+		t.Errorf("conversations.history called %d times, want 2", n)
+	}
+
+	last := updates[len(updates)-1]
+	if last.Pages != 2 || last.ThreadsTotal != 1 || last.ThreadsRemaining() != 0 || last.Messages != 4 {
+		t.Errorf("final progress = %+v, want 2 pages, 1 thread done, 4 messages", last)
+	}
+}
+
+func TestThreadRepliesPaginate(t *testing.T) {
+	fake, c := newFakeSlack(t)
+	seedChannel(fake)
+	var cursors []string
+	fake.handle("conversations.replies", func(form url.Values) interface{} {
+		cursors = append(cursors, form.Get("cursor"))
+		// Each page repeats the parent, as Slack does
+		parent := msg("1700000100.000100", "U1", "thread parent", "1700000100.000100", 3)
+		switch form.Get("cursor") {
+		case "":
+			return map[string]interface{}{"ok": true, "has_more": true,
+				"response_metadata": map[string]interface{}{"next_cursor": "page2"},
+				"messages":          []interface{}{parent, msg("1700000101.000100", "U2", "reply one", "1700000100.000100", 0)}}
+		case "page2":
+			return map[string]interface{}{"ok": true, "has_more": true,
+				"response_metadata": map[string]interface{}{"next_cursor": "page3"},
+				"messages":          []interface{}{parent, msg("1700000102.000100", "U1", "reply two", "1700000100.000100", 0)}}
+		}
+		return map[string]interface{}{"ok": true,
+			"messages": []interface{}{parent, msg("1700000103.000100", "U2", "reply three", "1700000100.000100", 0)}}
+	})
+
+	end := time.Unix(1700001000, 0)
+	got, err := c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	var texts []string
+	for _, m := range got {
+		texts = append(texts, m.Text)
+	}
+	want := "thread parent|standalone|reply one|reply two|reply three"
+	if strings.Join(texts, "|") != want {
+		t.Errorf("got %v, want %s", texts, want)
+	}
+	if strings.Join(cursors, ",") != ",page2,page3" {
+		t.Errorf("conversations.replies cursors = %q, want the first page then page2 and page3", cursors)
+	}
+}
+
+func TestMessageCapAndCallBudget(t *testing.T) {
+	twoPages := func(fake *fakeSlack) {
+		seedChannel(fake)
+		fake.handle("conversations.history", func(form url.Values) interface{} {
+			if form.Get("cursor") == "" {
+				return map[string]interface{}{"ok": true, "has_more": true,
+					"response_metadata": map[string]interface{}{"next_cursor": "page2"},
+					"messages": []interface{}{
+						msg("1700000200.000100", "U2", "standalone", "", 0),
+					}}
+			}
+			return map[string]interface{}{"ok": true, "messages": []interface{}{
+				msg("1700000100.000100", "U1", "thread parent", "1700000100.000100", 2),
+			}}
+		})
+	}
+	end := time.Unix(1700001000, 0)
+
+	t.Run("message cap", func(t *testing.T) {
+		fake, c := newFakeSlack(t, WithMaxMessages(1))
+		twoPages(fake)
+
+		h, err := c.GetHistory(context.Background(), "C1", end.Add(-time.Hour), end)
+		if err != nil {
+			t.Fatalf("GetHistory: %v", err)
+		}
+		if !h.Capped || h.Truncated || len(h.Messages) != 1 || h.Messages[0].Text != "standalone" {
+			t.Errorf("history = capped %v, truncated %v, %d message(s); want capped at the newest one", h.Capped, h.Truncated, len(h.Messages))
+		}
+		if n := fake.callCount("conversations.history"); n != 1 {
+			t.Errorf("conversations.history called %d times, want paging to stop at the cap", n)
+		}
+	})
+
+	t.Run("call budget", func(t *testing.T) {
+		// Two history pages and the first users.info lookup; one worker so
+		// the refused lookup cannot cancel the allowed one
+		fake, c := newFakeSlack(t, WithMaxAPICalls(3), WithWorkers(1))
+		twoPages(fake)
+
+		_, err := c.GetHistory(context.Background(), "C1", end.Add(-time.Hour), end)
+		if !errors.Is(err, ErrAPIBudget) || IsRetryable(err) {
+			t.Fatalf("GetHistory error = %v, want a permanent ErrAPIBudget", err)
+		}
+		if _, err := c.GetHistory(context.Background(), "C1", end.Add(-time.Hour), end); !errors.Is(err, ErrAPIBudget) {
+			t.Errorf("second GetHistory error = %v, want ErrAPIBudget", err)
+		}
+		calls := fake.callCount("conversations.history") + fake.callCount("users.info") + fake.callCount("conversations.replies")
+		if calls != 3 || c.APICalls() != 3 {
+			t.Errorf("Slack saw %d call(s), client counted %d; want the budget of 3", calls, c.APICalls())
+		}
+	})
+}
+
+func TestExtractJiraTickets(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"bare", "PROJ-1 and OPS-22 are blocked by PROJ-1", []string{"PROJ-1", "OPS-22"}},
+		{"slack link", "see <https://jira.example.com/browse/PROJ-123|PROJ-123>", []string{"PROJ-123"}},
+		{"slack link with summary label", "<https://jira.example.com/browse/PROJ-9|Fix login>", []string{"PROJ-9"}},
+		{"unlabelled slack link", "<https://jira.example.com/browse/AB2-7>", []string{"AB2-7"}},
+		{"plain url", "https://acme.atlassian.net/browse/OPS-4?focusedCommentId=1 fixed", []string{"OPS-4"}},
+		{"non-jira url", "<https://github.com/org/repo/tree/RELEASE-2|branch> and https://x.io/UTF-8", nil},
+		{"mixed", "OPS-1 dupes <https://jira.example.com/browse/OPS-2|OPS-2>, OPS-1 again, https://jira.example.com/browse/OPS-3", []string{"OPS-1", "OPS-2", "OPS-3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractJiraTickets(tt.text)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("extractJiraTickets(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractURLs(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"slack link", "see <https://go.dev/doc|the docs>", []string{"https://go.dev/doc"}},
+		{"unlabelled slack link", "<http://example.com/a?x=1&amp;y=2>", []string{"http://example.com/a?x=1&y=2"}},
+		{"plain url", "read https://example.com/post. Then (https://example.org/x)", []string{"https://example.com/post", "https://example.org/x"}},
+		{"repeated", "<https://a.io|a> and <https://a.io>", []string{"https://a.io"}},
+		{"not web links", "<@U123> in <#C1|general>, <mailto:a@b.io|a@b.io> and <!here>", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractURLs(tt.text)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("extractURLs(%q) = %v, want %v", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkersCapConcurrentRequests(t *testing.T) {
+	const workers = 3
+	var inFlight, peak int32
+
+	fake, c := newFakeSlack(t, WithWorkers(workers))
+	track := func() {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}
+
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		var messages []interface{}
+		for i := 0; i < 12; i++ {
+			ts := fmt.Sprintf("17000001%02d.000100", i)
+			messages = append(messages, msg(ts, fmt.Sprintf("U%02d", i), "parent", ts, 1))
+		}
+		return map[string]interface{}{"ok": true, "messages": messages}
+	})
+	fake.handle("users.info", func(form url.Values) interface{} {
+		track()
+		return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user")}}
+	})
+	fake.handle("conversations.replies", func(form url.Values) interface{} {
+		track()
+		ts := form.Get("ts")
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg(ts, "U00", "parent", ts, 1),
+		}}
+	})
+
+	end := time.Unix(1700001000, 0)
+	if _, err := c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end); err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+
+	if p := atomic.LoadInt32(&peak); p > workers {
+		t.Errorf("peak concurrency %d exceeds %d workers", p, workers)
+	}
+	if fake.callCount("conversations.replies") != 12 {
+		t.Errorf("replies fetched for %d threads, want 12", fake.callCount("conversations.replies"))
+	}
+}
+
+func TestPinnedMessages(t *testing.T) {
+	fake, c := newFakeSlack(t)
+	seedChannel(fake)
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		pinned := msg("1700000200.000100", "U2", "runbook", "", 0)
+		pinned["pinned_to"] = []string{"C1"}
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			pinned,
+			msg("1700000300.000100", "U1", "chatter", "", 0),
+		}}
+	})
+	fake.handle("pins.list", func(url.Values) interface{} {
+		pinned := msg("1700000200.000100", "U2", "runbook", "", 0)
+		pinned["pinned_to"] = []string{"C1"}
+		return map[string]interface{}{"ok": true, "items": []interface{}{
+			map[string]interface{}{"type": "message", "channel": "C1", "message": pinned},
+			map[string]interface{}{"type": "file", "channel": "C1"},
+		}}
+	})
+
+	end := time.Unix(1700001000, 0)
+	got, err := c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	pinned := map[string]bool{}
+	for _, m := range got {
+		pinned[m.MessageID] = m.IsPinned()
+	}
+	if !pinned["1700000200.000100"] || pinned["1700000300.000100"] {
+		t.Errorf("pinned flags = %v, want only 1700000200.000100", pinned)
+	}
+
+	pins, err := c.ListPins(context.Background(), "C1")
+	if err != nil {
+		t.Fatalf("ListPins: %v", err)
+	}
+	if len(pins) != 1 || pins[0].Text != "runbook" {
+		t.Errorf("ListPins = %+v, want the runbook message", pins)
+	}
+}
+
+func TestGetMessagesAgainstFixture(t *testing.T) {
+	api, err := fakeslack.Load("fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	api.PageSize = 1
+	c := NewClient("xoxb-test", WithAPI(api))
+	if _, err := c.ValidateAuth(context.Background()); err != nil {
+		t.Fatalf("ValidateAuth: %v", err)
+	}
+
+	end := time.Unix(1700001000, 0)
+	got, err := c.GetMessages(context.Background(), "C0000000001", end.Add(-time.Hour), end)
+	if err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+
+	if len(got) != 5 {
+		t.Fatalf("got %d messages, want 5 (3 timeline + 2 replies)", len(got))
+	}
+	if n := api.Calls("conversations.history"); n != 3 {
+		t.Errorf("history called %d times, want 3 pages", n)
+	}
+
+	byID := map[string]*models.SlackMessage{}
+	for _, m := range got {
+		byID[m.MessageID] = m
+	}
+	if r := byID["1700000110.000100"]; r == nil || !r.IsThreadReply() || r.UserInfo == nil || r.UserInfo.Name != "bob" {
+		t.Errorf("reply = %+v, want thread reply enriched with bob", r)
+	}
+	if p := byID["1700000100.000100"]; p == nil || !p.IsPinned() || len(p.JiraTickets) != 1 {
+		t.Errorf("parent = %+v, want pinned with PROJ-1", p)
+	}
+	if want := "https://acme.slack.com/archives/C0000000001/p1700000100000100"; byID["1700000100.000100"].Permalink != want {
+		t.Errorf("parent permalink = %q, want %q", byID["1700000100.000100"].Permalink, want)
+	}
+}
+
+func TestListUsersAndSeed(t *testing.T) {
+	api, err := fakeslack.Load("fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	c := NewClient("xoxb-test", WithAPI(api))
+
+	users, err := c.ListUsers(context.Background())
+	if err != nil {
+		t.Fatalf("ListUsers: %v", err)
+	}
+	if len(users) != 3 || users[0].ID != "U1" {
+		t.Fatalf("users = %+v, want the 3 fixture users", users)
+	}
+	if carol := users[2]; !carol.Deleted || !carol.UpdatedAt.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("U3 = %+v, want deactivated with updated_at", carol)
+	}
+
+	known := map[string]*models.SlackUser{}
+	for _, u := range users {
+		known[u.ID] = u
+	}
+	c.SeedUsers(known)
+
+	end := time.Unix(1700001000, 0)
+	if _, err := c.GetMessages(context.Background(), "C0000000001", end.Add(-time.Hour), end); err != nil {
+		t.Fatalf("GetMessages: %v", err)
+	}
+	if n := api.Calls("users.info"); n != 0 {
+		t.Errorf("users.info called %d times, want 0 with a seeded directory", n)
+	}
+}
+
+func TestGetChannelInfoMetadata(t *testing.T) {
+	api, err := fakeslack.Load("fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	c := NewClient("xoxb-test", WithAPI(api))
+
+	ch, err := c.GetChannelInfo(context.Background(), "C0000000001")
+	if err != nil {
+		t.Fatalf("GetChannelInfo: %v", err)
+	}
+	want := models.SlackChannel{
+		Name:       "incidents",
+		ID:         "C0000000001",
+		Topic:      "Active incidents only",
+		Purpose:    "Pager escalations and follow-ups",
+		NumMembers: 42,
+		Created:    time.Unix(1600000000, 0),
+	}
+	if *ch != want {
+		t.Errorf("channel = %+v, want %+v", *ch, want)
+	}
+}
+
+func TestChannelRateLimitThrottlesIndependently(t *testing.T) {
+	api := fakeslack.New(fakeslack.Fixture{Channels: []fakeslack.Channel{
+		{ID: "C0000000001", Name: "shared"},
+		{ID: "C0000000002", Name: "general"},
+	}})
+	c := NewClient("xoxb-test", WithAPI(api), WithChannelRateLimits(map[string]float64{"C0000000001": 20, "C0000000002": 0}))
+	ctx := context.Background()
+
+	// 20/s without bursts: five calls wait out four 50ms intervals
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := c.GetChannelInfo(ctx, "C0000000001"); err != nil {
+			t.Fatalf("GetChannelInfo: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 180*time.Millisecond {
+		t.Errorf("5 calls on the throttled channel took %v, want at least 200ms", elapsed)
+	}
+
+	// The override took nothing from the global limiter, so the other
+	// channel still has its whole burst
+	if tokens := c.rateLimiter.Tokens(); tokens < 49 {
+		t.Errorf("global limiter has %.1f tokens left, want its burst of 50 untouched", tokens)
+	}
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		if _, err := c.GetChannelInfo(ctx, "C0000000002"); err != nil {
+			t.Fatalf("GetChannelInfo: %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("5 calls on the unthrottled channel took %v, want no waiting", elapsed)
+	}
+}
+
+func TestFullReactions(t *testing.T) {
+	givers := make([]string, 60)
+	for i := range givers {
+		givers[i] = fmt.Sprintf("U%03d", i)
+	}
+	const launch = "1700000100.000100"
+	fixture := fakeslack.Fixture{Channels: []fakeslack.Channel{{ID: "C0000000001", Name: "all-hands", Messages: []slack.Message{
+		{Msg: slack.Msg{Timestamp: launch, Text: "launch", Reactions: []slack.ItemReaction{
+			{Name: "tada", Count: 60, Users: givers},
+			{Name: "eyes", Count: 2, Users: givers[:2]},
+		}}},
+		{Msg: slack.Msg{Timestamp: "1700000200.000100", Text: "quiet", Reactions: []slack.ItemReaction{{Name: "+1", Count: 1, Users: givers[:1]}}}},
+	}}}}
+
+	fetch := func(t *testing.T, api *fakeslack.Fake, full bool) (*History, []models.SlackReaction) {
+		t.Helper()
+		c := NewClient("xoxb-test", WithAPI(api), WithSkipUsers(true), WithFullReactions(full))
+		h, err := c.GetHistory(context.Background(), "C0000000001", time.Unix(1700000000, 0), time.Unix(1700001000, 0))
+		if err != nil {
+			t.Fatalf("GetHistory: %v", err)
+		}
+		for _, m := range h.Messages {
+			if m.MessageID == launch {
+				return h, m.Reactions
+			}
+		}
+		t.Fatalf("message %s not fetched", launch)
+		return nil, nil
+	}
+
+	// History lists the first 50 givers only
+	api := fakeslack.New(fixture)
+	h, reactions := fetch(t, api, false)
+	if len(reactions[0].Users) != fakeslack.DefaultReactionUsers || h.ReactionsFetched != 0 || api.Calls("reactions.get") != 0 {
+		t.Errorf("default: %d tada users, %d refetched, %d calls; want %d, none and none",
+			len(reactions[0].Users), h.ReactionsFetched, api.Calls("reactions.get"), fakeslack.DefaultReactionUsers)
+	}
+
+	// Only the truncated message is refetched, and gets every giver
+	api = fakeslack.New(fixture)
+	h, reactions = fetch(t, api, true)
+	if h.ReactionsFetched != 1 || api.Calls("reactions.get") != 1 {
+		t.Errorf("full: %d refetched with %d calls, want 1 and 1", h.ReactionsFetched, api.Calls("reactions.get"))
+	}
+	if len(reactions) != 2 || len(reactions[0].Users) != 60 || reactions[0].Users[59] != "U059" || len(reactions[1].Users) != 2 {
+		t.Errorf("full reactions = %+v, want tada by all 60 and eyes by 2", reactions)
+	}
+
+	// Without reactions:read the truncated users are kept
+	api = fakeslack.New(fixture)
+	api.FailNext("reactions.get", slack.SlackErrorResponse{Err: "missing_scope"})
+	h, reactions = fetch(t, api, true)
+	if h.ReactionsFetched != 0 || len(reactions[0].Users) != fakeslack.DefaultReactionUsers {
+		t.Errorf("missing scope: %d refetched, %d tada users; want 0 and the truncated %d",
+			h.ReactionsFetched, len(reactions[0].Users), fakeslack.DefaultReactionUsers)
+	}
+}
+
+func TestUserTTL(t *testing.T) {
+	fixture := fakeslack.Fixture{
+		Channels: []fakeslack.Channel{{ID: "C0000000001", Name: "general", Messages: []slack.Message{
+			{Msg: slack.Msg{Timestamp: "1700000100.000100", User: "U1", Text: "renamed"}},
+			{Msg: slack.Msg{Timestamp: "1700000200.000100", User: "U2", Text: "unchanged"}},
+			{Msg: slack.Msg{Timestamp: "1700000300.000100", User: "U3", Text: "gone"}},
+		}}},
+		Users: []slack.User{{ID: "U1", Name: "alice.new"}, {ID: "U2", Name: "bob.new"}},
+	}
+	now := time.Now()
+	seed := func() map[string]*models.SlackUser {
+		return map[string]*models.SlackUser{
+			"U1": {ID: "U1", Name: "alice", CachedAt: now.Add(-48 * time.Hour)},
+			"U2": {ID: "U2", Name: "bob", CachedAt: now.Add(-time.Hour)},
+			"U3": {ID: "U3", Name: "carol", CachedAt: now.Add(-48 * time.Hour)},
+		}
+	}
+	fetch := func(t *testing.T, ttl time.Duration) (*fakeslack.Fake, *Client) {
+		t.Helper()
+		api := fakeslack.New(fixture)
+		c := NewClient("xoxb-test", WithAPI(api), WithUserTTL(ttl), WithBulkUserThreshold(0))
+		c.SeedUsers(seed())
+		if _, err := c.GetHistory(context.Background(), "C0000000001", time.Unix(1700000000, 0), time.Unix(1700001000, 0)); err != nil {
+			t.Fatalf("GetHistory: %v", err)
+		}
+		return api, c
+	}
+
+	// Without a TTL every seeded user is reused
+	api, c := fetch(t, 0)
+	if calls := api.Calls("users.info"); calls != 0 || c.GetUserInfo("U1").Name != "alice" {
+		t.Errorf("no TTL: %d users.info calls, U1 %q; want none and the cached alice", calls, c.GetUserInfo("U1").Name)
+	}
+
+	// A day's TTL refreshes the expired users only
+	api, c = fetch(t, 24*time.Hour)
+	if calls := api.Calls("users.info"); calls != 2 {
+		t.Errorf("users.info calls = %d, want 2 for the expired U1 and U3", calls)
+	}
+	if u1 := c.GetUserInfo("U1"); u1.Name != "alice.new" || !u1.CachedAt.IsZero() {
+		t.Errorf("expired U1 = %+v, want alice.new fetched this run", u1)
+	}
+	if u2 := c.GetUserInfo("U2"); u2.Name != "bob" {
+		t.Errorf("fresh U2 = %q, want the cached bob", u2.Name)
+	}
+	// users.info no longer knows U3: the stale copy is kept, not replaced
+	// with an external placeholder
+	if u3 := c.GetUserInfo("U3"); u3.Name != "carol" || u3.IsExternal {
+		t.Errorf("vanished U3 = %+v, want the cached carol", u3)
+	}
+
+	// Refreshed once a run
+	if err := c.LookupUsers(context.Background(), []string{"U1", "U3"}); err != nil {
+		t.Fatalf("LookupUsers: %v", err)
+	}
+	if calls := api.Calls("users.info"); calls != 2 {
+		t.Errorf("users.info calls after a second lookup = %d, want still 2", calls)
+	}
+}
+
+func TestSkipReasonClassifiesGoneChannels(t *testing.T) {
+	api := fakeslack.New(fakeslack.Fixture{Channels: []fakeslack.Channel{
+		{ID: "C0000000001", Name: "live"},
+		{ID: "C0000000002", Name: "old-project", IsArchived: true},
+	}})
+	c := NewClient("xoxb-test", WithAPI(api))
+	api.FailNext("conversations.info", nil, nil, nil, slack.SlackErrorResponse{Err: "ratelimited"})
+
+	tests := []struct {
+		channel string
+		want    string
+	}{
+		{"C0000000001", ""},
+		{"C0000000002", "archived"},
+		{"C0000000099", "channel not found"},
+		{"C0000000001", ""}, // ratelimited: an error, but not a reason to skip
+	}
+	for _, tt := range tests {
+		info, err := c.GetChannelInfo(context.Background(), tt.channel)
+		if got := SkipReason(info, err); got != tt.want {
+			t.Errorf("SkipReason(%s) = %q (err %v), want %q", tt.channel, got, err, tt.want)
+		}
+	}
+}
+
+func TestListEmoji(t *testing.T) {
+	api, err := fakeslack.Load("fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	c := NewClient("xoxb-test", WithAPI(api))
+
+	emoji, err := c.ListEmoji(context.Background())
+	if err != nil {
+		t.Fatalf("ListEmoji: %v", err)
+	}
+	if len(emoji) != 2 {
+		t.Fatalf("got %d emoji, want 2", len(emoji))
+	}
+	if shipit := emoji[0]; shipit.Name != "shipit" || shipit.URL == "" || shipit.AliasFor != "" {
+		t.Errorf("emoji[0] = %+v, want shipit with a URL", shipit)
+	}
+	if squirrel := emoji[1]; squirrel.Name != "squirrel" || squirrel.AliasFor != "shipit" || squirrel.URL != "" {
+		t.Errorf("emoji[1] = %+v, want squirrel aliased to shipit", squirrel)
+	}
+}
+
+func TestPostMessage(t *testing.T) {
+	api, err := fakeslack.Load("fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	c := NewClient("xoxb-test", WithAPI(api))
+
+	ts, err := c.PostMessage(context.Background(), "C0000000001", "outage <https://acme.slack.com/archives/C1/p1|view>")
+	if err != nil {
+		t.Fatalf("PostMessage: %v", err)
+	}
+	if ts == "" {
+		t.Error("PostMessage returned no timestamp")
+	}
+	if posted := api.Posted("C0000000001"); len(posted) != 1 || !strings.HasPrefix(posted[0], "outage <https://") {
+		t.Errorf("posted = %q, want the alert text unescaped", posted)
+	}
+
+	if _, err := c.PostBlocks(context.Background(), "C0000000001", "Digest", SectionMessages("Digest", []string{"summary"})[0]); err != nil {
+		t.Fatalf("PostBlocks: %v", err)
+	}
+
+	api.FailNext("chat.postMessage", slack.SlackErrorResponse{Err: "missing_scope"})
+	_, err = c.PostMessage(context.Background(), "C0000000001", "again")
+	if err == nil {
+		t.Fatal("PostMessage succeeded despite missing_scope")
+	}
+	if hint := PostHint(err); !strings.Contains(hint, "chat:write") {
+		t.Errorf("PostHint = %q, want it to name chat:write", hint)
+	}
+	if _, err := c.PostMessage(context.Background(), "C0000000001", "again"); !errors.Is(err, errMethodDisabled) {
+		t.Errorf("after missing_scope err = %v, want chat.postMessage disabled", err)
+	}
+}
+
+func TestProbeScopes(t *testing.T) {
+	api, err := fakeslack.Load("fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	c := NewClient("xoxb-test", WithAPI(api))
+	ctx := context.Background()
+
+	status := func(probes []ScopeProbe) string {
+		var s []string
+		for _, p := range probes {
+			s = append(s, p.Method+"="+string(p.Status))
+		}
+		return strings.Join(s, ",")
+	}
+
+	if got := status(c.ProbeScopes(ctx, "C0000000001", "U1")); got != "conversations.history=ok,conversations.replies=ok,users.info=ok" {
+		t.Errorf("probes = %s, want all ok", got)
+	}
+
+	api.FailNext("users.info", slack.SlackErrorResponse{Err: "missing_scope"})
+	probes := c.ProbeScopes(ctx, "", "U1")
+	if got := status(probes); got != "conversations.history=skipped,conversations.replies=skipped,users.info=missing" {
+		t.Errorf("probes = %s, want channel probes skipped and users.info missing", got)
+	}
+	if probes[2].Scope != "users:read" {
+		t.Errorf("users.info scope = %q, want users:read", probes[2].Scope)
+	}
+	// A probe does not disable the method for the run
+	if err := c.methodDisabled("users.info"); err != nil {
+		t.Errorf("users.info disabled after a probe: %v", err)
+	}
+
+	api.FailNext("conversations.history", slack.SlackErrorResponse{Err: "not_in_channel"})
+	if got := status(c.ProbeScopes(ctx, "C0000000001", "")); got != "conversations.history=failed,conversations.replies=skipped,users.info=skipped" {
+		t.Errorf("probes = %s, want history failed and the rest skipped", got)
+	}
+}
+
+func TestAuthHint(t *testing.T) {
+	if hint := AuthHint(fmt.Errorf("auth.test failed: %w", slack.SlackErrorResponse{Err: "invalid_auth"})); !strings.Contains(hint, "not valid") {
+		t.Errorf("AuthHint(invalid_auth) = %q", hint)
+	}
+	if hint := AuthHint(errors.New("connection refused")); hint != "" {
+		t.Errorf("AuthHint(network error) = %q, want none", hint)
+	}
+}
+
+func TestPermalink(t *testing.T) {
+	tests := []struct {
+		url, ts, threadTS string
+		want              string
+	}{
+		{"https://acme.slack.com/", "1700000100.000100", "", "https://acme.slack.com/archives/C1/p1700000100000100"},
+		{"https://acme.slack.com", "1700000100.000100", "1700000100.000100", "https://acme.slack.com/archives/C1/p1700000100000100"},
+		{"https://acme.slack.com/", "1700000110.000100", "1700000100.000100", "https://acme.slack.com/archives/C1/p1700000110000100?cid=C1&thread_ts=1700000100.000100"},
+		{"", "1700000100.000100", "", ""},
+	}
+	for _, tt := range tests {
+		if got := Permalink(tt.url, "C1", tt.ts, tt.threadTS); got != tt.want {
+			t.Errorf("Permalink(%q, C1, %q, %q) = %q, want %q", tt.url, tt.ts, tt.threadTS, got, tt.want)
+		}
+	}
+}
+
+func TestParseThreadRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		want    ThreadRef
+		wantErr bool
+	}{
+		{ref: "https://acme.slack.com/archives/C0000000001/p1700000100000100",
+			want: ThreadRef{Host: "acme.slack.com", ChannelID: "C0000000001", ThreadTS: "1700000100.000100"}},
+		{ref: "https://acme.slack.com/archives/C0000000001/p1700000110000100?thread_ts=1700000100.000100&cid=C0000000001",
+			want: ThreadRef{Host: "acme.slack.com", ChannelID: "C0000000001", ThreadTS: "1700000100.000100"}},
+		{ref: "C0000000001:1700000100.000100",
+			want: ThreadRef{ChannelID: "C0000000001", ThreadTS: "1700000100.000100"}},
+		{ref: "https://example.com/archives/C0000000001/p1700000100000100", wantErr: true},
+		{ref: "https://acme.slack.com/client/T1/C0000000001", wantErr: true},
+		{ref: "https://acme.slack.com/archives/C0000000001/p17000001", wantErr: true},
+		{ref: "C0000000001:1700000100", wantErr: true},
+		{ref: "general:1700000100.000100", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseThreadRef(tt.ref)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseThreadRef(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseThreadRef(%q) = %+v, want %+v", tt.ref, got, tt.want)
+		}
+	}
+
+	ref := ThreadRef{Host: "other.slack.com", ChannelID: "C1", ThreadTS: "1700000100.000100"}
+	if err := ref.CheckWorkspace("https://acme.slack.com/"); err == nil {
+		t.Error("CheckWorkspace accepted a permalink from another workspace")
+	}
+	if err := ref.CheckWorkspace("https://OTHER.slack.com/"); err != nil {
+		t.Errorf("CheckWorkspace: %v", err)
+	}
+}
+
+func TestGetThread(t *testing.T) {
+	api, err := fakeslack.Load("fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	api.PageSize = 1
+	c := NewClient("xoxb-test", WithAPI(api))
+
+	// A reply's timestamp resolves to the whole thread
+	thread, err := c.GetThread(context.Background(), "C0000000001", "1700000110.000100")
+	if err != nil {
+		t.Fatalf("GetThread: %v", err)
+	}
+	if len(thread) != 3 || thread[0].MessageID != "1700000100.000100" || !thread[0].IsThreadParent() {
+		t.Fatalf("thread = %+v, want parent and 2 replies", thread)
+	}
+	for _, msg := range thread {
+		if msg.UserInfo == nil {
+			t.Errorf("message %s has no user info", msg.MessageID)
+		}
+	}
+
+	if _, err := c.GetThread(context.Background(), "C0000000001", "1600000000.000100"); err == nil {
+		t.Error("GetThread succeeded for a missing thread")
+	}
+}
+
+func TestGetHistoryEmptyTruncatedInaccessible(t *testing.T) {
+	end := time.Unix(1700001000, 0)
+	start := end.Add(-time.Hour)
+
+	t.Run("empty", func(t *testing.T) {
+		fake, c := newFakeSlack(t)
+		fake.handle("conversations.history", func(url.Values) interface{} {
+			return map[string]interface{}{"ok": true, "messages": []interface{}{}, "has_more": false}
+		})
+
+		h, err := c.GetHistory(context.Background(), "C1", start, end)
+		if err != nil {
+			t.Fatalf("GetHistory: %v", err)
+		}
+		if len(h.Messages) != 0 || h.Pages != 1 || h.Truncated {
+			t.Errorf("history = %+v, want one empty page", h)
+		}
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		fake, c := newFakeSlack(t, WithThreadMode(ThreadModeTopLevel))
+		fake.handle("conversations.history", func(url.Values) interface{} {
+			return map[string]interface{}{"ok": true, "has_more": true, "messages": []interface{}{
+				msg("1700000200.000100", "U2", "standalone", "", 0),
+			}}
+		})
+		fake.handle("users.info", func(form url.Values) interface{} {
+			return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": form.Get("user")}}
+		})
+
+		h, err := c.GetHistory(context.Background(), "C1", start, end)
+		if err != nil {
+			t.Fatalf("GetHistory: %v", err)
+		}
+		if len(h.Messages) != 1 || !h.Truncated {
+			t.Errorf("history = %+v, want truncated with 1 message", h)
+		}
+	})
+
+	t.Run("inaccessible", func(t *testing.T) {
+		fake, c := newFakeSlack(t)
+		fake.handle("conversations.history", func(url.Values) interface{} {
+			return map[string]interface{}{"ok": false, "error": "not_in_channel"}
+		})
+
+		_, err := c.GetHistory(context.Background(), "C1", start, end)
+		if err == nil || !IsInaccessible(err) || IsRetryable(err) {
+			t.Errorf("err = %v, want permanent inaccessible error", err)
+		}
+	})
+}
+
+func TestIsInaccessible(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{slack.SlackErrorResponse{Err: "channel_not_found"}, true},
+		{fmt.Errorf("history: %w", slack.SlackErrorResponse{Err: "missing_scope"}), true},
+		{fmt.Errorf("users.info %w: x", errMethodDisabled), true},
+		{slack.SlackErrorResponse{Err: "internal_error"}, false},
+		{&slack.RateLimitedError{RetryAfter: time.Second}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsInaccessible(tt.err); got != tt.want {
+			t.Errorf("IsInaccessible(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&slack.RateLimitedError{RetryAfter: time.Second}, true},
+		{fmt.Errorf("history: %w", slack.StatusCodeError{Code: 503, Status: "503 Service Unavailable"}), true},
+		{slack.StatusCodeError{Code: 404, Status: "404 Not Found"}, false},
+		{fmt.Errorf("history: %w", slack.SlackErrorResponse{Err: "channel_not_found"}), false},
+		{slack.SlackErrorResponse{Err: "missing_scope"}, false},
+		{slack.SlackErrorResponse{Err: "internal_error"}, true},
+		{context.DeadlineExceeded, true},
+		{fmt.Errorf("history: %w", io.ErrUnexpectedEOF), true},
+		{&url.Error{Op: "Post", URL: "https://slack.com/api/conversations.history", Err: syscall.ECONNRESET}, true},
+		{context.Canceled, false},
+		{errors.New("json: cannot unmarshal string into Go value"), false},
+		{nil, false},
+	}
+
+	for _, tt := range tests {
+		if got := IsRetryable(tt.err); got != tt.want {
+			t.Errorf("IsRetryable(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+// rosterFixture is a channel where each of n roster users posts once, plus
+// one message from a user users.list does not return
+func rosterFixture(n int) fakeslack.Fixture {
+	fixture := fakeslack.Fixture{Channels: []fakeslack.Channel{{ID: "C0000000001", Name: "all-hands"}}}
+	ch := &fixture.Channels[0]
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("U%04d", i)
+		fixture.Users = append(fixture.Users, slack.User{ID: id, Name: "user" + id})
+		ch.Messages = append(ch.Messages, slack.Message{Msg: slack.Msg{
+			User: id, Text: "hi", Timestamp: fmt.Sprintf("1700000%03d.000100", i),
+		}})
+	}
+	ch.Messages = append(ch.Messages, slack.Message{Msg: slack.Msg{User: "UEXTERNAL", Text: "hello from a shared channel", Timestamp: "1700000999.000100"}})
+	return fixture
+}
+
+func TestBulkUserFetchReducesCalls(t *testing.T) {
+	const users = 60
+	window := func(c *Client) ([]*models.SlackMessage, error) {
+		return c.GetMessages(context.Background(), "C0000000001", time.Unix(1699999999, 0), time.Unix(1700001000, 0))
+	}
+
+	perUser := fakeslack.New(rosterFixture(users))
+	if _, err := window(NewClient("xoxb-test", WithAPI(perUser), WithBulkUserThreshold(0))); err != nil {
+		t.Fatalf("GetMessages (per user): %v", err)
+	}
+
+	bulk := fakeslack.New(rosterFixture(users))
+	c := NewClient("xoxb-test", WithAPI(bulk), WithBulkUserThreshold(20))
+	msgs, err := window(c)
+	if err != nil {
+		t.Fatalf("GetMessages (bulk): %v", err)
+	}
+
+	perUserCalls := perUser.Calls("users.info") + perUser.Calls("users.list")
+	bulkCalls := bulk.Calls("users.info") + bulk.Calls("users.list")
+	t.Logf("user lookups for %d users: %d per-user calls, %d with users.list", users+1, perUserCalls, bulkCalls)
+
+	if perUser.Calls("users.info") != users+1 || perUser.Calls("users.list") != 0 {
+		t.Errorf("per-user strategy made %d users.info and %d users.list calls, want %d and 0",
+			perUser.Calls("users.info"), perUser.Calls("users.list"), users+1)
+	}
+	// One roster call, plus users.info for the straggler only
+	if bulk.Calls("users.list") != 1 || bulk.Calls("users.info") != 1 {
+		t.Errorf("bulk strategy made %d users.list and %d users.info calls, want 1 and 1",
+			bulk.Calls("users.list"), bulk.Calls("users.info"))
+	}
+	for _, m := range msgs {
+		if m.UserID != "UEXTERNAL" && (m.UserInfo == nil || m.UserInfo.Name != "user"+m.UserID) {
+			t.Errorf("message from %s has user info %+v, want it from the roster", m.UserID, m.UserInfo)
+		}
+	}
+
+	// The roster is loaded once per client
+	if _, err := window(c); err != nil {
+		t.Fatal(err)
+	}
+	if bulk.Calls("users.list") != 1 {
+		t.Errorf("users.list called %d times, want once per client", bulk.Calls("users.list"))
+	}
+}
+
+func TestExternalUsers(t *testing.T) {
+	var buf bytes.Buffer
+	fake, c := newFakeSlack(t, WithLogger(slog.New(slog.NewTextHandler(&buf, nil))))
+	fake.handle("auth.test", func(url.Values) interface{} {
+		return map[string]interface{}{"ok": true, "team": "Home", "team_id": "T0HOME", "user_id": "U0BOT"}
+	})
+	fake.handle("conversations.history", func(url.Values) interface{} {
+		hidden := msg("1700000300.000100", "U0HIDDEN", "from a partner we cannot see", "", 0)
+		hidden["team"] = "T0OTHER"
+		return map[string]interface{}{"ok": true, "messages": []interface{}{
+			msg("1700000100.000100", "U0LOCAL", "hi", "", 0),
+			msg("1700000200.000100", "W0FOREIGN", "hello from a shared channel", "", 0),
+			hidden,
+		}}
+	})
+	fake.handle("users.info", func(form url.Values) interface{} {
+		switch form.Get("user") {
+		case "U0LOCAL":
+			return map[string]interface{}{"ok": true, "user": map[string]interface{}{"id": "U0LOCAL", "name": "local", "team_id": "T0HOME"}}
+		case "W0FOREIGN":
+			// Partial profile: no handle, names only under profile
+			return map[string]interface{}{"ok": true, "user": map[string]interface{}{
+				"id": "W0FOREIGN", "team_id": "T0PARTNER",
+				"profile": map[string]interface{}{"real_name": "Partner P", "display_name": "pp", "team": "T0PARTNER"},
+			}}
+		}
+		return map[string]interface{}{"ok": false, "error": "user_not_found"}
+	})
+
+	if _, err := c.ValidateAuth(context.Background()); err != nil {
+		t.Fatalf("ValidateAuth: %v", err)
+	}
+	end := time.Unix(1700001000, 0)
+	var msgs []*models.SlackMessage
+	for i := 0; i < 2; i++ {
+		var err error
+		if msgs, err = c.GetMessages(context.Background(), "C1", end.Add(-time.Hour), end); err != nil {
+			t.Fatalf("GetMessages: %v", err)
+		}
+	}
+
+	byUser := make(map[string]*models.SlackUser)
+	for _, m := range msgs {
+		byUser[m.UserID] = m.UserInfo
+	}
+	if u := byUser["U0LOCAL"]; u == nil || u.IsExternal || u.TeamID != "T0HOME" {
+		t.Errorf("local user = %+v, want an internal member of T0HOME", u)
+	}
+	if u := byUser["W0FOREIGN"]; u == nil || !u.IsExternal || u.TeamID != "T0PARTNER" || u.Name != "pp" || u.RealName != "Partner P" {
+		t.Errorf("foreign user = %+v, want external with the profile's names", u)
+	}
+	if u := byUser["U0HIDDEN"]; u == nil || !u.IsExternal || u.TeamID != "T0OTHER" {
+		t.Errorf("hidden user = %+v, want external with the message's team", u)
+	}
+
+	if n := fake.callCount("users.info"); n != 3 {
+		t.Errorf("users.info called %d times over two fetches, want 3 (once per user)", n)
+	}
+	if strings.Contains(buf.String(), `msg="failed to fetch user"`) {
+		t.Errorf("warned about a user users.info cannot see:\n%s", buf.String())
+	}
+}
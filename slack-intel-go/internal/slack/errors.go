@@ -0,0 +1,184 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/slack-go/slack"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// transientErrors are Web API error codes worth retrying later
+var transientErrors = map[string]bool{
+	"ratelimited":         true,
+	"internal_error":      true,
+	"fatal_error":         true,
+	"service_unavailable": true,
+	"request_timeout":     true,
+}
+
+// inaccessibleErrors are Web API error codes meaning the token cannot read
+// the channel at all, as opposed to the channel being empty
+var inaccessibleErrors = map[string]bool{
+	"channel_not_found":       true,
+	"not_in_channel":          true,
+	"missing_scope":           true,
+	"not_authed":              true,
+	"invalid_auth":            true,
+	"access_denied":           true,
+	"account_inactive":        true,
+	"team_access_not_granted": true,
+	"ekm_access_denied":       true,
+}
+
+// tokenErrors are Web API error codes meaning the token itself was
+// rejected, so every further call fails the same way
+var tokenErrors = map[string]bool{
+	"not_authed":       true,
+	"invalid_auth":     true,
+	"token_revoked":    true,
+	"token_expired":    true,
+	"account_inactive": true,
+}
+
+// ErrAuthFailed is returned when user lookups stop because the token was
+// rejected (revoked, expired or invalid)
+var ErrAuthFailed = errors.New("auth failed")
+
+// ErrUsersMissing is returned when some user lookups failed; messages by
+// those users carry no user info
+var ErrUsersMissing = errors.New("some users missing")
+
+// ErrAPIBudget is returned by every call made after the WithMaxAPICalls
+// budget is spent
+var ErrAPIBudget = errors.New("API call budget spent")
+
+// isTokenError reports whether err means the token was rejected
+func isTokenError(err error) bool {
+	var resp slack.SlackErrorResponse
+	return errors.As(err, &resp) && tokenErrors[resp.Err]
+}
+
+// unknownUserErrors are users.info error codes for users the token cannot
+// see, typically members of another workspace in a Slack Connect channel
+var unknownUserErrors = map[string]bool{
+	"user_not_found":   true,
+	"user_not_visible": true,
+	"users_not_found":  true,
+}
+
+// isUnknownUser reports whether err means users.info will never resolve
+// the user with this token
+func isUnknownUser(err error) bool {
+	var resp slack.SlackErrorResponse
+	return errors.As(err, &resp) && unknownUserErrors[resp.Err]
+}
+
+// IsInaccessible reports whether err means the channel cannot be read with
+// this token (not a member, missing scope, revoked access)
+func IsInaccessible(err error) bool {
+	if errors.Is(err, errMethodDisabled) {
+		return true
+	}
+	var resp slack.SlackErrorResponse
+	if errors.As(err, &resp) {
+		return inaccessibleErrors[resp.Err]
+	}
+	return false
+}
+
+// IsChannelNotFound reports whether err is channel_not_found, which Slack
+// returns for deleted channels and private channels the token cannot see
+func IsChannelNotFound(err error) bool {
+	var resp slack.SlackErrorResponse
+	return errors.As(err, &resp) && resp.Err == "channel_not_found"
+}
+
+// SkipReason classifies a conversations.info result for a configured
+// channel: "archived" or "channel not found" when the channel should be
+// skipped rather than fetched, "" when it is live or the lookup failed
+// for another reason.
+func SkipReason(info *models.SlackChannel, err error) string {
+	switch {
+	case IsChannelNotFound(err):
+		return "channel not found"
+	case err == nil && info != nil && info.IsArchived:
+		return "archived"
+	}
+	return ""
+}
+
+// AuthHint explains why auth.test rejected the token and what to do
+// about it; it returns "" for errors that are not about the token
+func AuthHint(err error) string {
+	var resp slack.SlackErrorResponse
+	if !errors.As(err, &resp) {
+		return ""
+	}
+	switch resp.Err {
+	case "not_authed":
+		return "no token was sent; set the token variable"
+	case "invalid_auth":
+		return "the token is not valid; check it for typos or a token from another workspace"
+	case "token_revoked", "account_inactive":
+		return "the token was revoked or its app uninstalled; reinstall the Slack app and use the new token"
+	case "token_expired":
+		return "the token expired; refresh it or reinstall the Slack app"
+	}
+	return ""
+}
+
+// PostHint explains a chat.postMessage failure the user can fix: a token
+// without the chat:write scope, or a bot that is not in the channel. It
+// returns "" for other errors.
+func PostHint(err error) string {
+	var resp slack.SlackErrorResponse
+	if !errors.As(err, &resp) {
+		return ""
+	}
+	switch resp.Err {
+	case "missing_scope":
+		return "the token lacks the chat:write scope; add it to the Slack app and reinstall it"
+	case "not_in_channel":
+		return "the bot is not a member of the channel; invite it with /invite"
+	case "channel_not_found":
+		return "the channel does not exist or the token cannot see it"
+	}
+	return ""
+}
+
+// IsRetryable reports whether err is transient (rate limits, 5xx, timeouts,
+// network failures and cut-off responses). Slack API errors such as
+// channel_not_found or missing_scope are permanent and return false, as
+// does any error not known to be transient.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, errMethodDisabled) || errors.Is(err, ErrAPIBudget) {
+		return false
+	}
+
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		return true
+	}
+
+	var status slack.StatusCodeError
+	if errors.As(err, &status) {
+		return status.Code >= 500 || status.Code == 429
+	}
+
+	var resp slack.SlackErrorResponse
+	if errors.As(err, &resp) {
+		return transientErrors[resp.Err]
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	// A connection reset or closed mid-response
+	return errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,197 @@
+package slack
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultRateLimit and DefaultRateBurst configure the client's global rate
+// limiter, in requests per second
+const (
+	DefaultRateLimit = 20
+	DefaultRateBurst = 50
+)
+
+// Rates used by EstimateChannel when no earlier run calibrates them
+const (
+	// DefaultMessagesPerDay is the timeline messages per channel and day
+	DefaultMessagesPerDay = 50
+	// DefaultThreadShare is the fraction of messages that start a thread
+	DefaultThreadShare = 0.2
+	// DefaultNewUsers is the authors per channel not yet in the user cache
+	DefaultNewUsers = 5
+)
+
+// callLatency is the assumed round trip of one Web API request; calls
+// made one after another cannot go faster than this whatever the limit
+const callLatency = 300 * time.Millisecond
+
+// pageSize is the conversations.history and conversations.replies limit
+const pageSize = 1000
+
+// Workload describes what a cache run is expected to fetch from one
+// channel, for EstimateChannel
+type Workload struct {
+	Window time.Duration
+	// MessagesPerDay is the expected messages per day in the channel
+	MessagesPerDay float64
+	// ThreadShare is the fraction of messages with replies to fetch
+	ThreadShare float64
+	// NewUsers is the expected authors missing from the user cache
+	NewUsers float64
+	// ThreadMode, SkipUsers, BulkUsers and Workers mirror the client options
+	ThreadMode ThreadMode
+	SkipUsers  bool
+	BulkUsers  int
+	Workers    int
+}
+
+// Estimate is a best-effort forecast of Web API calls and how long the
+// rate limiter and request latency make them take
+type Estimate struct {
+	HistoryCalls int64 // conversations.info and conversations.history
+	ReplyCalls   int64 // conversations.replies
+	UserCalls    int64 // users.info, or one users.list
+	Duration     time.Duration
+}
+
+// Calls returns the total API calls
+func (e Estimate) Calls() int64 {
+	return e.HistoryCalls + e.ReplyCalls + e.UserCalls
+}
+
+// Add sums two estimates
+func (e Estimate) Add(o Estimate) Estimate {
+	return Estimate{
+		HistoryCalls: e.HistoryCalls + o.HistoryCalls,
+		ReplyCalls:   e.ReplyCalls + o.ReplyCalls,
+		UserCalls:    e.UserCalls + o.UserCalls,
+		Duration:     e.Duration + o.Duration,
+	}
+}
+
+// EstimateChannel forecasts one channel's fetch when its calls are limited
+// to perSecond requests per second (the global limit, or the channel's
+// rate_limit when lower). History pages are read one after another;
+// thread replies and user lookups fan out over the workers. The limiter's
+// burst is ignored.
+func EstimateChannel(w Workload, perSecond float64) Estimate {
+	if perSecond <= 0 {
+		perSecond = DefaultRateLimit
+	}
+	workers := float64(max(w.Workers, 1))
+	messages := w.MessagesPerDay * w.Window.Hours() / 24
+
+	var e Estimate
+	e.HistoryCalls = 1 + max(int64(math.Ceil(messages/pageSize)), 1)
+	if w.ThreadMode != ThreadModeTopLevel {
+		e.ReplyCalls = int64(math.Round(messages * w.ThreadShare))
+	}
+	if !w.SkipUsers {
+		e.UserCalls = int64(math.Round(w.NewUsers))
+		if w.BulkUsers > 0 && e.UserCalls >= int64(w.BulkUsers) {
+			e.UserCalls = 1
+		}
+	}
+
+	sequential := max(time.Duration(float64(time.Second)/perSecond), callLatency)
+	parallel := max(time.Duration(float64(time.Second)/perSecond), time.Duration(float64(callLatency)/workers))
+	e.Duration = time.Duration(e.HistoryCalls)*sequential + time.Duration(e.ReplyCalls+e.UserCalls)*parallel
+	return e
+}
+
+// ETA refines a run's estimate as it goes: channels already fetched
+// count with their real duration, and the ratio of real to estimated time
+// so far scales the estimate of the channels left. While a channel's
+// thread replies are fetched, its remaining threads are timed at the pace
+// of the replies fetched so far. Safe for concurrent use.
+type ETA struct {
+	mu      sync.Mutex
+	planned map[string]Estimate
+	// done holds the real duration of each channel's last attempt
+	done map[string]time.Duration
+
+	// spent and plannedDone are the real and estimated durations of the
+	// channels done
+	spent, plannedDone time.Duration
+
+	current      string
+	currentStart time.Time
+	progress     Progress
+	threadsStart time.Time
+	threadsDone  int
+}
+
+// NewETA tracks a run fetching the channels with the given estimates
+func NewETA(planned map[string]Estimate) *ETA {
+	return &ETA{planned: planned, done: make(map[string]time.Duration)}
+}
+
+// Start marks a channel's fetch as begun at now
+func (e *ETA) Start(channelID string, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.current, e.currentStart = channelID, now
+	e.progress, e.threadsStart, e.threadsDone = Progress{}, time.Time{}, 0
+}
+
+// Update records the current channel's progress at now; it fits a
+// ProgressFunc
+func (e *ETA) Update(p Progress, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if p.ThreadsTotal > 0 && e.threadsStart.IsZero() {
+		e.threadsStart, e.threadsDone = now, p.ThreadsDone
+	}
+	e.progress = p
+}
+
+// Done marks a channel finished at now. A channel retried later counts
+// once, with its last attempt.
+func (e *ETA) Done(channelID string, now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if channelID == e.current {
+		e.current = ""
+	}
+	if took, ok := e.done[channelID]; ok {
+		e.spent -= took
+	} else {
+		e.plannedDone += e.planned[channelID].Duration
+	}
+	took := now.Sub(e.currentStart)
+	e.done[channelID] = took
+	e.spent += took
+}
+
+// Remaining returns the expected time left at now
+func (e *ETA) Remaining(now time.Time) time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	scale := 1.0
+	if e.plannedDone > 0 {
+		scale = float64(e.spent) / float64(e.plannedDone)
+	}
+
+	var left time.Duration
+	for id, est := range e.planned {
+		if _, done := e.done[id]; !done && id != e.current {
+			left += time.Duration(float64(est.Duration) * scale)
+		}
+	}
+	if e.current == "" {
+		return left
+	}
+
+	// The current channel: time its threads once some are done, otherwise
+	// take the estimate less the time already spent on it
+	p := e.progress
+	if fetched := p.ThreadsDone - e.threadsDone; p.ThreadsTotal > 0 && fetched > 0 {
+		perThread := now.Sub(e.threadsStart) / time.Duration(fetched)
+		return left + time.Duration(p.ThreadsRemaining())*perThread
+	}
+	current := time.Duration(float64(e.planned[e.current].Duration)*scale) - now.Sub(e.currentStart)
+	return left + max(current, 0)
+}
@@ -0,0 +1,81 @@
+package slack
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEstimateChannel(t *testing.T) {
+	w := Workload{
+		Window:         7 * 24 * time.Hour,
+		MessagesPerDay: 300,
+		ThreadShare:    0.1,
+		NewUsers:       8,
+		BulkUsers:      DefaultBulkUserThreshold,
+		Workers:        10,
+	}
+
+	e := EstimateChannel(w, DefaultRateLimit)
+	// 2100 messages: conversations.info and 3 history pages, 210 threads, 8 users
+	if e.HistoryCalls != 4 || e.ReplyCalls != 210 || e.UserCalls != 8 || e.Calls() != 222 {
+		t.Errorf("calls = %+v, want 4 history, 210 replies, 8 users", e)
+	}
+	// History pages are latency bound; fanned-out calls go at the limit
+	if want := 4*callLatency + 218*50*time.Millisecond; e.Duration != want {
+		t.Errorf("duration = %v, want %v", e.Duration, want)
+	}
+
+	// A channel rate_limit of 1/s makes every call take a second
+	if slow := EstimateChannel(w, 1); slow.Duration != 222*time.Second {
+		t.Errorf("duration at 1/s = %v, want 222s", slow.Duration)
+	}
+
+	w.ThreadMode, w.SkipUsers = ThreadModeTopLevel, true
+	if fast := EstimateChannel(w, DefaultRateLimit); fast.Calls() != 4 {
+		t.Errorf("calls without threads and users = %+v, want history only", fast)
+	}
+
+	w.ThreadMode, w.SkipUsers, w.NewUsers = ThreadModeAll, false, float64(DefaultBulkUserThreshold)
+	if bulk := EstimateChannel(w, DefaultRateLimit); bulk.UserCalls != 1 {
+		t.Errorf("user calls = %d, want one users.list", bulk.UserCalls)
+	}
+}
+
+func TestETARefinesWithProgress(t *testing.T) {
+	t0 := time.Date(2024, 5, 10, 9, 0, 0, 0, time.UTC)
+	eta := NewETA(map[string]Estimate{
+		"C1": {Duration: time.Minute},
+		"C2": {Duration: time.Minute},
+		"C3": {Duration: 2 * time.Minute},
+	})
+	if got := eta.Remaining(t0); got != 4*time.Minute {
+		t.Errorf("before start = %v, want the 4m estimate", got)
+	}
+
+	// C1 takes twice as long as estimated, so the rest is scaled by 2
+	eta.Start("C1", t0)
+	if got := eta.Remaining(t0.Add(20 * time.Second)); got != 3*time.Minute+40*time.Second {
+		t.Errorf("during C1 = %v, want 3m40s", got)
+	}
+	eta.Done("C1", t0.Add(2*time.Minute))
+	if got := eta.Remaining(t0.Add(2 * time.Minute)); got != 6*time.Minute {
+		t.Errorf("after C1 = %v, want 6m", got)
+	}
+
+	// C2's threads: 10 of 40 done in 30s leaves 30 at 3s each
+	start := t0.Add(2 * time.Minute)
+	eta.Start("C2", start)
+	eta.Update(Progress{Pages: 1, Messages: 500, ThreadsTotal: 40}, start.Add(10*time.Second))
+	eta.Update(Progress{Pages: 1, Messages: 500, ThreadsTotal: 40, ThreadsDone: 10}, start.Add(40*time.Second))
+	if got := eta.Remaining(start.Add(40 * time.Second)); got != 4*time.Minute+90*time.Second {
+		t.Errorf("during C2 threads = %v, want 5m30s", got)
+	}
+
+	// A retried channel counts once, with its last attempt
+	eta.Done("C2", start.Add(time.Minute))
+	eta.Start("C1", start.Add(time.Minute))
+	eta.Done("C1", start.Add(5*time.Minute))
+	if got := eta.Remaining(start.Add(5 * time.Minute)); got != 5*time.Minute {
+		t.Errorf("after a retry = %v, want C3 at the 5m/2m scale: 5m", got)
+	}
+}
@@ -0,0 +1,425 @@
+// Package fakeslack is an in-memory implementation of the Slack Web API
+// methods used by internal/slack, seeded from a JSON fixture. It backs
+// integration tests and the cache command's --offline-fixture flag.
+package fakeslack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/slack-go/slack"
+)
+
+// DefaultPageSize is how many messages a history page holds unless the
+// request asks for fewer
+const DefaultPageSize = 100
+
+// DefaultReactionUsers is how many users Slack lists per reaction in
+// history and replies payloads
+const DefaultReactionUsers = 50
+
+// Fixture is the JSON document a Fake is seeded from. Messages use the Web
+// API's own JSON shape (ts, user, text, thread_ts, reply_count, ...); thread
+// replies live in the same list as the timeline and are told apart by
+// thread_ts.
+type Fixture struct {
+	Team     string            `json:"team"`
+	TeamID   string            `json:"team_id"`
+	URL      string            `json:"url,omitempty"`
+	BotID    string            `json:"bot_id,omitempty"`
+	Channels []Channel         `json:"channels"`
+	Users    []slack.User      `json:"users"`
+	Emoji    map[string]string `json:"emoji,omitempty"`
+}
+
+// Channel is one conversation in a Fixture
+type Channel struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Topic      string          `json:"topic,omitempty"`
+	Purpose    string          `json:"purpose,omitempty"`
+	NumMembers int             `json:"num_members,omitempty"`
+	IsPrivate  bool            `json:"is_private,omitempty"`
+	IsArchived bool            `json:"is_archived,omitempty"`
+	Created    int64           `json:"created,omitempty"`
+	Messages   []slack.Message `json:"messages"`
+}
+
+// Fake serves a Fixture through the internal/slack SlackAPI interface
+type Fake struct {
+	// PageSize caps conversations.history and conversations.replies pages so
+	// tests can force pagination
+	PageSize int
+	// ReactionUsers caps the users listed per reaction in history and
+	// replies, as Slack does; reactions.get with full lists them all. 0
+	// lists every user.
+	ReactionUsers int
+
+	fixture  Fixture
+	channels map[string]*Channel
+	users    map[string]*slack.User
+
+	mu     sync.Mutex
+	calls  map[string]int
+	errors map[string][]error
+	posted map[string][]string
+}
+
+// New returns a Fake serving the given fixture
+func New(fixture Fixture) *Fake {
+	f := &Fake{
+		PageSize:      DefaultPageSize,
+		ReactionUsers: DefaultReactionUsers,
+		fixture:       fixture,
+		channels:      make(map[string]*Channel),
+		users:         make(map[string]*slack.User),
+		calls:         make(map[string]int),
+		errors:        make(map[string][]error),
+		posted:        make(map[string][]string),
+	}
+	for i := range fixture.Channels {
+		f.channels[fixture.Channels[i].ID] = &fixture.Channels[i]
+	}
+	for i := range fixture.Users {
+		f.users[fixture.Users[i].ID] = &fixture.Users[i]
+	}
+	return f
+}
+
+// Load reads a JSON fixture file
+func Load(path string) (*Fake, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	var fixture Fixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse fixture %s: %w", path, err)
+	}
+
+	return New(fixture), nil
+}
+
+// FailNext makes the next calls to method return errs, one per call, before
+// normal responses resume. Use it to simulate rate limits or scope errors.
+func (f *Fake) FailNext(method string, errs ...error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.errors[method] = append(f.errors[method], errs...)
+}
+
+// Calls returns how many times method has been called
+func (f *Fake) Calls(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// call records a call and pops any queued error for method
+func (f *Fake) call(method string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls[method]++
+	if queued := f.errors[method]; len(queued) > 0 {
+		f.errors[method] = queued[1:]
+		return queued[0]
+	}
+	return nil
+}
+
+func (f *Fake) channel(id string) (*Channel, error) {
+	ch, ok := f.channels[id]
+	if !ok {
+		return nil, slack.SlackErrorResponse{Err: "channel_not_found"}
+	}
+	return ch, nil
+}
+
+// AuthTestContext implements auth.test
+func (f *Fake) AuthTestContext(ctx context.Context) (*slack.AuthTestResponse, error) {
+	if err := f.call("auth.test"); err != nil {
+		return nil, err
+	}
+	return &slack.AuthTestResponse{
+		Team:   f.fixture.Team,
+		TeamID: f.fixture.TeamID,
+		URL:    f.fixture.URL,
+		BotID:  f.fixture.BotID,
+	}, nil
+}
+
+// GetConversationsContext implements conversations.list (single page)
+func (f *Fake) GetConversationsContext(ctx context.Context, params *slack.GetConversationsParameters) ([]slack.Channel, string, error) {
+	if err := f.call("conversations.list"); err != nil {
+		return nil, "", err
+	}
+	channels := make([]slack.Channel, 0, len(f.fixture.Channels))
+	for _, c := range f.fixture.Channels {
+		channels = append(channels, toChannel(c))
+	}
+	return channels, "", nil
+}
+
+// GetConversationInfoContext implements conversations.info
+func (f *Fake) GetConversationInfoContext(ctx context.Context, input *slack.GetConversationInfoInput) (*slack.Channel, error) {
+	if err := f.call("conversations.info"); err != nil {
+		return nil, err
+	}
+	c, err := f.channel(input.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+	ch := toChannel(*c)
+	return &ch, nil
+}
+
+// GetConversationHistoryContext implements conversations.history: top-level
+// messages within (oldest, latest), newest first, paged by PageSize
+func (f *Fake) GetConversationHistoryContext(ctx context.Context, params *slack.GetConversationHistoryParameters) (*slack.GetConversationHistoryResponse, error) {
+	if err := f.call("conversations.history"); err != nil {
+		return nil, err
+	}
+	c, err := f.channel(params.ChannelID)
+	if err != nil {
+		return nil, err
+	}
+
+	var timeline []slack.Message
+	for _, m := range c.Messages {
+		if m.ThreadTimestamp != "" && m.ThreadTimestamp != m.Timestamp {
+			continue
+		}
+		if !inWindow(m.Timestamp, params.Oldest, params.Latest) {
+			continue
+		}
+		timeline = append(timeline, m)
+	}
+	sort.Slice(timeline, func(i, j int) bool {
+		return tsValue(timeline[i].Timestamp) > tsValue(timeline[j].Timestamp)
+	})
+
+	offset := 0
+	if params.Cursor != "" {
+		if offset, err = strconv.Atoi(params.Cursor); err != nil {
+			return nil, slack.SlackErrorResponse{Err: "invalid_cursor"}
+		}
+	}
+	size := f.PageSize
+	if params.Limit > 0 && params.Limit < size {
+		size = params.Limit
+	}
+
+	resp := &slack.GetConversationHistoryResponse{}
+	resp.Ok = true
+	end := offset + size
+	if end >= len(timeline) {
+		end = len(timeline)
+	} else {
+		resp.HasMore = true
+		resp.ResponseMetaData.NextCursor = strconv.Itoa(end)
+	}
+	if offset < end {
+		resp.Messages = f.truncateReactions(timeline[offset:end])
+	}
+	return resp, nil
+}
+
+// GetConversationRepliesContext implements conversations.replies: the parent
+// followed by its replies, oldest first, paged by PageSize
+func (f *Fake) GetConversationRepliesContext(ctx context.Context, params *slack.GetConversationRepliesParameters) ([]slack.Message, bool, string, error) {
+	if err := f.call("conversations.replies"); err != nil {
+		return nil, false, "", err
+	}
+	c, err := f.channel(params.ChannelID)
+	if err != nil {
+		return nil, false, "", err
+	}
+
+	var thread []slack.Message
+	for _, m := range c.Messages {
+		if m.Timestamp == params.Timestamp || m.ThreadTimestamp == params.Timestamp {
+			thread = append(thread, m)
+		}
+	}
+	if len(thread) == 0 {
+		return nil, false, "", slack.SlackErrorResponse{Err: "thread_not_found"}
+	}
+	sort.Slice(thread, func(i, j int) bool {
+		return tsValue(thread[i].Timestamp) < tsValue(thread[j].Timestamp)
+	})
+
+	offset := 0
+	if params.Cursor != "" {
+		if offset, err = strconv.Atoi(params.Cursor); err != nil {
+			return nil, false, "", slack.SlackErrorResponse{Err: "invalid_cursor"}
+		}
+	}
+	end := offset + f.PageSize
+	if end >= len(thread) {
+		return f.truncateReactions(thread[min(offset, len(thread)):]), false, "", nil
+	}
+	return f.truncateReactions(thread[offset:end]), true, strconv.Itoa(end), nil
+}
+
+// GetReactionsContext implements reactions.get for a message; without
+// full, reactions list at most ReactionUsers users
+func (f *Fake) GetReactionsContext(ctx context.Context, item slack.ItemRef, params slack.GetReactionsParameters) ([]slack.ItemReaction, error) {
+	if err := f.call("reactions.get"); err != nil {
+		return nil, err
+	}
+	c, err := f.channel(item.Channel)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range c.Messages {
+		if m.Timestamp != item.Timestamp {
+			continue
+		}
+		if !params.Full {
+			m = f.truncateReactions([]slack.Message{m})[0]
+		}
+		return append([]slack.ItemReaction(nil), m.Reactions...), nil
+	}
+	return nil, slack.SlackErrorResponse{Err: "message_not_found"}
+}
+
+// truncateReactions copies msgs with each reaction listing at most
+// ReactionUsers users
+func (f *Fake) truncateReactions(msgs []slack.Message) []slack.Message {
+	if f.ReactionUsers <= 0 {
+		return msgs
+	}
+	out := make([]slack.Message, len(msgs))
+	for i, m := range msgs {
+		reactions := make([]slack.ItemReaction, len(m.Reactions))
+		for j, r := range m.Reactions {
+			if len(r.Users) > f.ReactionUsers {
+				r.Users = r.Users[:f.ReactionUsers:f.ReactionUsers]
+			}
+			reactions[j] = r
+		}
+		if m.Reactions == nil {
+			reactions = nil
+		}
+		m.Reactions = reactions
+		out[i] = m
+	}
+	return out
+}
+
+// GetUserInfoContext implements users.info
+func (f *Fake) GetUserInfoContext(ctx context.Context, user string) (*slack.User, error) {
+	if err := f.call("users.info"); err != nil {
+		return nil, err
+	}
+	u, ok := f.users[user]
+	if !ok {
+		return nil, slack.SlackErrorResponse{Err: "user_not_found"}
+	}
+	return u, nil
+}
+
+// GetUsersContext implements users.list, returning every fixture user in
+// fixture order as one page
+func (f *Fake) GetUsersContext(ctx context.Context, options ...slack.GetUsersOption) ([]slack.User, error) {
+	if err := f.call("users.list"); err != nil {
+		return nil, err
+	}
+	return append([]slack.User(nil), f.fixture.Users...), nil
+}
+
+// GetEmojiContext implements emoji.list
+func (f *Fake) GetEmojiContext(ctx context.Context) (map[string]string, error) {
+	if err := f.call("emoji.list"); err != nil {
+		return nil, err
+	}
+	emoji := make(map[string]string, len(f.fixture.Emoji))
+	for name, value := range f.fixture.Emoji {
+		emoji[name] = value
+	}
+	return emoji, nil
+}
+
+// ListPinsContext implements pins.list from messages with pinned_to set
+func (f *Fake) ListPinsContext(ctx context.Context, channel string) ([]slack.Item, *slack.Paging, error) {
+	if err := f.call("pins.list"); err != nil {
+		return nil, nil, err
+	}
+	c, err := f.channel(channel)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var items []slack.Item
+	for i := range c.Messages {
+		if len(c.Messages[i].PinnedTo) > 0 {
+			items = append(items, slack.NewMessageItem(channel, &c.Messages[i]))
+		}
+	}
+	return items, &slack.Paging{Count: len(items), Total: len(items)}, nil
+}
+
+// PostMessageContext implements chat.postMessage by recording the text;
+// the message is not added to the channel's history
+func (f *Fake) PostMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (string, string, error) {
+	if err := f.call("chat.postMessage"); err != nil {
+		return "", "", err
+	}
+	if _, err := f.channel(channelID); err != nil {
+		return "", "", err
+	}
+	_, values, err := slack.UnsafeApplyMsgOptions("", channelID, "", options...)
+	if err != nil {
+		return "", "", err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.posted[channelID] = append(f.posted[channelID], values.Get("text"))
+	ts := fmt.Sprintf("%d.%06d", 1700000000+f.calls["chat.postMessage"], 0)
+	return channelID, ts, nil
+}
+
+// Posted returns the texts posted to a channel with chat.postMessage
+func (f *Fake) Posted(channelID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.posted[channelID]...)
+}
+
+func toChannel(c Channel) slack.Channel {
+	var ch slack.Channel
+	ch.ID = c.ID
+	ch.Name = c.Name
+	ch.Topic.Value = c.Topic
+	ch.Purpose.Value = c.Purpose
+	ch.NumMembers = c.NumMembers
+	ch.IsPrivate = c.IsPrivate
+	ch.IsArchived = c.IsArchived
+	ch.Created = slack.JSONTime(c.Created)
+	return ch
+}
+
+// inWindow reports whether ts lies in the exclusive (oldest, latest) range;
+// empty bounds are open
+func inWindow(ts, oldest, latest string) bool {
+	v := tsValue(ts)
+	if oldest != "" && v <= tsValue(oldest) {
+		return false
+	}
+	if latest != "" && v >= tsValue(latest) {
+		return false
+	}
+	return true
+}
+
+func tsValue(ts string) float64 {
+	v, _ := strconv.ParseFloat(ts, 64)
+	return v
+}
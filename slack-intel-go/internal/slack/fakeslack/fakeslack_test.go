@@ -0,0 +1,57 @@
+package fakeslack
+
+import (
+	"context"
+	"testing"
+
+	"github.com/slack-go/slack"
+)
+
+func TestHistoryPagesTopLevelMessages(t *testing.T) {
+	f, err := Load("testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	f.PageSize = 2
+
+	params := &slack.GetConversationHistoryParameters{ChannelID: "C0000000001"}
+	var got []string
+	for {
+		resp, err := f.GetConversationHistoryContext(context.Background(), params)
+		if err != nil {
+			t.Fatalf("history: %v", err)
+		}
+		for _, m := range resp.Messages {
+			got = append(got, m.Timestamp)
+		}
+		if !resp.HasMore {
+			break
+		}
+		params.Cursor = resp.ResponseMetaData.NextCursor
+	}
+
+	want := []string{"1700000300.000100", "1700000200.000100", "1700000100.000100"}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+	if n := f.Calls("conversations.history"); n != 2 {
+		t.Errorf("history called %d times, want 2", n)
+	}
+}
+
+func TestFailNext(t *testing.T) {
+	f := New(Fixture{Users: []slack.User{{ID: "U1"}}})
+	f.FailNext("users.info", &slack.RateLimitedError{})
+
+	if _, err := f.GetUserInfoContext(context.Background(), "U1"); err == nil {
+		t.Error("first call should fail")
+	}
+	if _, err := f.GetUserInfoContext(context.Background(), "U1"); err != nil {
+		t.Errorf("second call: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package slack
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Permalink builds a message's Slack deep link locally, avoiding a
+// chat.getPermalink call per message. workspaceURL is the auth.test URL
+// (https://<workspace>.slack.com/); replies link into their thread.
+// Returns "" when the workspace URL is unknown.
+func Permalink(workspaceURL, channelID, ts, threadTS string) string {
+	if workspaceURL == "" || channelID == "" || ts == "" {
+		return ""
+	}
+
+	link := strings.TrimSuffix(workspaceURL, "/") + "/archives/" + channelID + "/p" + strings.Replace(ts, ".", "", 1)
+	if threadTS != "" && threadTS != ts {
+		query := url.Values{"thread_ts": {threadTS}, "cid": {channelID}}
+		link += "?" + query.Encode()
+	}
+	return link
+}
+
+// ThreadRef identifies one thread to fetch
+type ThreadRef struct {
+	Host      string // workspace host from a permalink (e.g. acme.slack.com); empty for channel:ts refs
+	ChannelID string
+	ThreadTS  string
+}
+
+var (
+	channelIDPattern = regexp.MustCompile(`^[CGD][A-Z0-9]{2,}$`)
+	tsPattern        = regexp.MustCompile(`^\d{10}\.\d{6}$`)
+	permalinkTS      = regexp.MustCompile(`^p(\d{10})(\d{6})$`)
+)
+
+// ParseThreadRef accepts a message permalink
+// (https://<workspace>.slack.com/archives/<channel>/p<ts>, optionally with
+// ?thread_ts= when it points at a reply) or "<channel_id>:<thread_ts>"
+func ParseThreadRef(ref string) (ThreadRef, error) {
+	ref = strings.TrimSpace(ref)
+	if !strings.Contains(ref, "://") {
+		channelID, ts, ok := strings.Cut(ref, ":")
+		if !ok || !channelIDPattern.MatchString(channelID) || !tsPattern.MatchString(ts) {
+			return ThreadRef{}, fmt.Errorf("invalid thread reference %q (expected a Slack permalink or CHANNEL_ID:THREAD_TS, e.g. C0123456789:1700000000.000100)", ref)
+		}
+		return ThreadRef{ChannelID: channelID, ThreadTS: ts}, nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ThreadRef{}, fmt.Errorf("invalid permalink %q: %w", ref, err)
+	}
+	if !strings.HasSuffix(u.Hostname(), ".slack.com") {
+		return ThreadRef{}, fmt.Errorf("invalid permalink %q: not a slack.com URL", ref)
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "archives" || !channelIDPattern.MatchString(parts[1]) {
+		return ThreadRef{}, fmt.Errorf("invalid permalink %q: expected /archives/<channel>/p<timestamp>", ref)
+	}
+	m := permalinkTS.FindStringSubmatch(parts[2])
+	if m == nil {
+		return ThreadRef{}, fmt.Errorf("invalid permalink %q: malformed message timestamp %q", ref, parts[2])
+	}
+
+	thread := ThreadRef{Host: u.Hostname(), ChannelID: parts[1], ThreadTS: m[1] + "." + m[2]}
+	if ts := u.Query().Get("thread_ts"); ts != "" {
+		if !tsPattern.MatchString(ts) {
+			return ThreadRef{}, fmt.Errorf("invalid permalink %q: malformed thread_ts %q", ref, ts)
+		}
+		thread.ThreadTS = ts
+	}
+	return thread, nil
+}
+
+// CheckWorkspace returns an error when the ref came from a permalink to a
+// different workspace than workspaceURL (the auth.test URL)
+func (r ThreadRef) CheckWorkspace(workspaceURL string) error {
+	if r.Host == "" || workspaceURL == "" {
+		return nil
+	}
+	u, err := url.Parse(workspaceURL)
+	if err != nil {
+		return nil
+	}
+	if !strings.EqualFold(u.Hostname(), r.Host) {
+		return fmt.Errorf("permalink is for %s but SLACK_API_TOKEN belongs to %s", r.Host, u.Hostname())
+	}
+	return nil
+}
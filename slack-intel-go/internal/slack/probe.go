@@ -0,0 +1,100 @@
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/slack-go/slack"
+)
+
+// ProbeStatus is the outcome of one scope probe
+type ProbeStatus string
+
+const (
+	ProbeOK      ProbeStatus = "ok"
+	ProbeMissing ProbeStatus = "missing" // the token lacks the scope
+	ProbeFailed  ProbeStatus = "failed"  // the call failed for another reason
+	ProbeSkipped ProbeStatus = "skipped" // nothing to call the method on
+)
+
+// ScopeProbe reports whether the token could call a method we depend on
+type ScopeProbe struct {
+	Method string      `json:"method"`
+	Scope  string      `json:"scope"`
+	Status ProbeStatus `json:"status"`
+	// Detail names what was probed, or why the probe failed or was skipped
+	Detail string `json:"detail,omitempty"`
+}
+
+// ProbeScopes makes one cheap call to each method the cache command needs
+// (conversations.history and conversations.replies on channelID, users.info
+// on userID) and reports which succeeded. A probe's failure does not
+// disable the method for the rest of the run. Without channelID the
+// channel probes are skipped.
+func (c *Client) ProbeScopes(ctx context.Context, channelID, userID string) []ScopeProbe {
+	probe := func(method string, call func() error, detail string) ScopeProbe {
+		p := ScopeProbe{Method: method, Scope: requiredScopes[method], Status: ProbeOK, Detail: detail}
+		if err := c.wait(ctx, c.rateLimiter); err != nil {
+			p.Status, p.Detail = ProbeFailed, err.Error()
+			return p
+		}
+		start := time.Now()
+		err := call()
+		c.logCall(method, start, err, "probe", true)
+		if err != nil {
+			p.Status, p.Detail = probeStatus(err), err.Error()
+		}
+		return p
+	}
+
+	var probes []ScopeProbe
+	if channelID == "" {
+		probes = append(probes,
+			ScopeProbe{Method: "conversations.history", Scope: requiredScopes["conversations.history"], Status: ProbeSkipped, Detail: "no channel to probe"},
+			ScopeProbe{Method: "conversations.replies", Scope: requiredScopes["conversations.replies"], Status: ProbeSkipped, Detail: "no channel to probe"})
+	} else {
+		var latest string
+		probes = append(probes, probe("conversations.history", func() error {
+			resp, err := c.api.GetConversationHistoryContext(ctx, &slack.GetConversationHistoryParameters{ChannelID: channelID, Limit: 1})
+			if err == nil && len(resp.Messages) > 0 {
+				latest = resp.Messages[0].Timestamp
+				if resp.Messages[0].ThreadTimestamp != "" {
+					latest = resp.Messages[0].ThreadTimestamp
+				}
+			}
+			return err
+		}, channelID))
+
+		// conversations.replies needs a message; any message works, thread or not
+		if latest == "" {
+			probes = append(probes, ScopeProbe{Method: "conversations.replies", Scope: requiredScopes["conversations.replies"], Status: ProbeSkipped,
+				Detail: fmt.Sprintf("no message in %s to probe", channelID)})
+		} else {
+			probes = append(probes, probe("conversations.replies", func() error {
+				_, _, _, err := c.api.GetConversationRepliesContext(ctx, &slack.GetConversationRepliesParameters{ChannelID: channelID, Timestamp: latest, Limit: 1})
+				return err
+			}, channelID+" "+latest))
+		}
+	}
+
+	if userID == "" {
+		probes = append(probes, ScopeProbe{Method: "users.info", Scope: requiredScopes["users.info"], Status: ProbeSkipped, Detail: "no user to probe"})
+	} else {
+		probes = append(probes, probe("users.info", func() error {
+			_, err := c.api.GetUserInfoContext(ctx, userID)
+			return err
+		}, userID))
+	}
+	return probes
+}
+
+// probeStatus classifies a failed probe
+func probeStatus(err error) ProbeStatus {
+	var resp slack.SlackErrorResponse
+	if errors.As(err, &resp) && resp.Err == "missing_scope" {
+		return ProbeMissing
+	}
+	return ProbeFailed
+}
@@ -0,0 +1,192 @@
+package slack
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Rate-limit headers Slack sends with some Web API responses
+const (
+	headerLimit      = "X-RateLimit-Limit"
+	headerRemaining  = "X-RateLimit-Remaining"
+	headerReset      = "X-RateLimit-Reset"
+	headerRetryAfter = "Retry-After"
+)
+
+// rateHeadroom is the share of a reported budget the client spends, so it
+// stays just under the cap
+const rateHeadroom = 0.9
+
+// adaptiveTransport tunes a limiter per API method from the rate-limit
+// headers of its responses: with X-RateLimit-Remaining calls left until
+// X-RateLimit-Reset, the method's calls are spread over that window, and a
+// 429's Retry-After holds them all until it passes. A method whose
+// responses carry no such headers, or whose window has reset, only waits
+// for the client's static limiters.
+type adaptiveTransport struct {
+	base http.RoundTripper
+	now  func() time.Time
+	// log reports a tuned limit; called with the transport's lock held
+	log func(msg string, args ...any)
+
+	mu      sync.Mutex
+	methods map[string]*methodLimit
+}
+
+// methodLimit is the header-derived limit of one API method
+type methodLimit struct {
+	limiter *rate.Limiter
+	// until is when the headers stop applying: the window's reset, or the
+	// end of a Retry-After pause
+	until time.Time
+	// paused holds calls back until then
+	paused time.Time
+}
+
+func newAdaptiveTransport(base http.RoundTripper, log func(msg string, args ...any)) *adaptiveTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &adaptiveTransport{base: base, now: time.Now, log: log, methods: make(map[string]*methodLimit)}
+}
+
+// RoundTrip waits for the method's tuned limit, if any, then retunes it
+// from the response headers
+func (t *adaptiveTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+	if err := t.wait(req.Context(), method); err != nil {
+		return nil, err
+	}
+	resp, err := t.base.RoundTrip(req)
+	if err == nil {
+		t.observe(method, resp.StatusCode, resp.Header)
+	}
+	return resp, err
+}
+
+// wait blocks until a call of method may go out. The pause and limiter are
+// copied under the lock, since observe updates them concurrently.
+func (t *adaptiveTransport) wait(ctx context.Context, method string) error {
+	t.mu.Lock()
+	m, ok := t.methods[method]
+	now := t.now()
+	if ok && !now.Before(m.until) {
+		delete(t.methods, method)
+		ok = false
+	}
+	var paused time.Time
+	var limiter *rate.Limiter
+	if ok {
+		paused, limiter = m.paused, m.limiter
+	}
+	t.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	if d := paused.Sub(now); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return limiter.Wait(ctx)
+}
+
+// observe retunes method's limit from one response's headers
+func (t *adaptiveTransport) observe(method string, status int, h http.Header) {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if status == http.StatusTooManyRequests {
+		if secs, err := strconv.ParseFloat(h.Get(headerRetryAfter), 64); err == nil && secs > 0 {
+			m := t.method(method, now)
+			m.paused = now.Add(time.Duration(secs * float64(time.Second)))
+			m.until = later(m.until, m.paused)
+			t.log("slack rate limited, pausing method", "method", method, "retry_after", m.paused.Sub(now))
+			return
+		}
+	}
+
+	remaining, err := strconv.Atoi(h.Get(headerRemaining))
+	if err != nil {
+		return
+	}
+	reset, ok := parseReset(h.Get(headerReset), now)
+	if !ok || !reset.After(now) {
+		return
+	}
+
+	m := t.method(method, now)
+	m.until = reset
+	window := reset.Sub(now).Seconds()
+	if remaining <= 0 {
+		// Nothing left: hold calls until the window resets
+		m.paused = reset
+		m.limiter.SetLimitAt(now, rate.Inf)
+	} else {
+		m.limiter.SetLimitAt(now, rate.Limit(float64(remaining)*rateHeadroom/window))
+	}
+	t.log("slack rate limit tuned", "method", method, "limit", h.Get(headerLimit), "remaining", remaining, "reset_in", reset.Sub(now).Round(time.Second), "per_second", float64(m.limiter.Limit()))
+}
+
+// method returns method's limit, creating an unlimited one; the caller
+// holds t.mu
+func (t *adaptiveTransport) method(method string, now time.Time) *methodLimit {
+	m, ok := t.methods[method]
+	if !ok || !now.Before(m.until) {
+		m = &methodLimit{limiter: rate.NewLimiter(rate.Inf, 1)}
+		t.methods[method] = m
+	}
+	return m
+}
+
+// Limits returns the tuned calls per second of the methods whose headers
+// still apply at now; methods held back entirely report 0
+func (t *adaptiveTransport) Limits(now time.Time) map[string]float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	limits := make(map[string]float64)
+	for method, m := range t.methods {
+		switch {
+		case !now.Before(m.until):
+		case now.Before(m.paused):
+			limits[method] = 0
+		default:
+			limits[method] = float64(m.limiter.Limit())
+		}
+	}
+	return limits
+}
+
+// parseReset reads X-RateLimit-Reset as Unix seconds, or as seconds from
+// now when too small to be a timestamp
+func parseReset(s string, now time.Time) (time.Time, bool) {
+	secs, err := strconv.ParseFloat(s, 64)
+	if err != nil || secs <= 0 {
+		return time.Time{}, false
+	}
+	d := time.Duration(secs * float64(time.Second))
+	if secs < 1e9 {
+		return now.Add(d), true
+	}
+	return time.Unix(0, 0).Add(d), true
+}
+
+// later returns the later of two times
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
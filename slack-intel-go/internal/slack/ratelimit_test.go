@@ -0,0 +1,158 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubTransport answers every request with a fixed status and headers
+type stubTransport struct {
+	status int
+	header http.Header
+}
+
+func (s *stubTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		StatusCode: s.status,
+		Header:     s.header.Clone(),
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true}`)),
+		Request:    req,
+	}, nil
+}
+
+func TestAdaptiveTransport(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	stub := &stubTransport{status: http.StatusOK, header: http.Header{}}
+	tr := newAdaptiveTransport(stub, func(string, ...any) {})
+	tr.now = func() time.Time { return now }
+
+	call := func(method string) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodPost, "https://slack.com/api/"+method, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		resp.Body.Close()
+	}
+
+	// No headers: only the static limiters apply
+	call("conversations.history")
+	if limits := tr.Limits(now); len(limits) != 0 {
+		t.Fatalf("without headers Limits = %v, want none", limits)
+	}
+
+	// 50 calls left for the next 60s (reset as a Unix timestamp): spread
+	// 90% of them over the window
+	stub.header.Set(headerLimit, "50")
+	stub.header.Set(headerRemaining, "50")
+	stub.header.Set(headerReset, strconv.FormatInt(now.Add(time.Minute).Unix(), 10))
+	call("conversations.history")
+	if got := tr.Limits(now)["conversations.history"]; got != 0.75 {
+		t.Errorf("history limit = %v, want 0.75/s", got)
+	}
+	if _, ok := tr.Limits(now)["users.info"]; ok {
+		t.Error("users.info tuned from another method's headers")
+	}
+
+	// Reset as seconds from now, budget spent: held back until then
+	stub.header.Set(headerRemaining, "0")
+	stub.header.Set(headerReset, "30")
+	call("conversations.replies")
+	if got, ok := tr.Limits(now)["conversations.replies"]; !ok || got != 0 {
+		t.Errorf("spent replies limit = %v, %v; want held back (0)", got, ok)
+	}
+
+	// A 429 pauses the method for Retry-After
+	stub.status, stub.header = http.StatusTooManyRequests, http.Header{headerRetryAfter: {"5"}}
+	call("users.info")
+	if got, ok := tr.Limits(now)["users.info"]; !ok || got != 0 {
+		t.Errorf("rate limited users.info = %v, %v; want held back (0)", got, ok)
+	}
+	if _, ok := tr.Limits(now.Add(6 * time.Second))["users.info"]; ok {
+		t.Error("users.info still limited after Retry-After passed")
+	}
+
+	// Past the window's reset the headers no longer apply
+	if limits := tr.Limits(now.Add(2 * time.Minute)); len(limits) != 0 {
+		t.Errorf("after reset Limits = %v, want none", limits)
+	}
+}
+
+func TestAdaptiveTransportWaits(t *testing.T) {
+	stub := &stubTransport{status: http.StatusTooManyRequests, header: http.Header{headerRetryAfter: {"60"}}}
+	tr := newAdaptiveTransport(stub, func(string, ...any) {})
+
+	req, _ := http.NewRequest(http.MethodPost, "https://slack.com/api/users.info", nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// The next call waits out the pause, here until the context ends
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := tr.RoundTrip(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("paused call err = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestAdaptiveTransportConcurrent(t *testing.T) {
+	// Every response pauses the method briefly, so calls read the pause
+	// while others set it (run with -race)
+	stub := &stubTransport{status: http.StatusTooManyRequests, header: http.Header{headerRetryAfter: {"0.001"}}}
+	tr := newAdaptiveTransport(stub, func(string, ...any) {})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				req, _ := http.NewRequest(http.MethodPost, "https://slack.com/api/users.info", nil)
+				resp, err := tr.RoundTrip(req)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClientTunesFromHeaders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(headerRemaining, "100")
+		w.Header().Set(headerReset, "60")
+		json.NewEncoder(w).Encode(map[string]interface{}{"ok": true, "messages": []interface{}{}})
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewClient("xoxb-test", WithAPIURL(srv.URL+"/"))
+	if _, err := c.GetHistory(context.Background(), "C123", time.Unix(0, 0), time.Now()); err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if got := c.TunedRateLimits()["conversations.history"]; got < 1.4 || got > 1.6 {
+		t.Errorf("tuned history limit = %v, want ~1.5/s", got)
+	}
+
+	static := NewClient("xoxb-test", WithAPIURL(srv.URL+"/"), WithStaticRateLimit(true))
+	if _, err := static.GetHistory(context.Background(), "C123", time.Unix(0, 0), time.Now()); err != nil {
+		t.Fatalf("GetHistory: %v", err)
+	}
+	if limits := static.TunedRateLimits(); len(limits) != 0 {
+		t.Errorf("--static-rate-limit tuned %v", limits)
+	}
+}
@@ -0,0 +1,70 @@
+package slack
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/slack-go/slack"
+)
+
+// requiredScopes maps the Web API methods we call to the scope a bot or
+// user token needs for them
+var requiredScopes = map[string]string{
+	"conversations.history": "channels:history",
+	"conversations.replies": "channels:history",
+	"users.info":            "users:read",
+	"users.list":            "users:read",
+	"emoji.list":            "emoji:read",
+	"reactions.get":         "reactions:read",
+	"chat.postMessage":      "chat:write",
+}
+
+// errMethodDisabled is returned for calls to a method that already failed
+// with an auth error during this run
+var errMethodDisabled = errors.New("disabled after auth error")
+
+// isAuthError reports whether err means the token can never call the method
+func isAuthError(err error) bool {
+	var resp slack.SlackErrorResponse
+	if !errors.As(err, &resp) {
+		return false
+	}
+	return resp.Err == "missing_scope" || tokenErrors[resp.Err]
+}
+
+// methodDisabled returns an error if method has been short-circuited
+func (c *Client) methodDisabled(method string) error {
+	c.disabledMu.Lock()
+	defer c.disabledMu.Unlock()
+	if cause, ok := c.disabled[method]; ok {
+		return fmt.Errorf("%s %w: %w", method, errMethodDisabled, cause)
+	}
+	return nil
+}
+
+// checkAuthError disables method on a scope/auth failure, logging the
+// remediation once. Other errors are returned unchanged.
+func (c *Client) checkAuthError(method string, err error) error {
+	if !isAuthError(err) {
+		return err
+	}
+
+	c.disabledMu.Lock()
+	_, seen := c.disabled[method]
+	if !seen {
+		c.disabled[method] = err
+	}
+	c.disabledMu.Unlock()
+
+	if !seen {
+		// Only a missing scope is fixed by granting one; a revoked or
+		// invalid token needs a new token
+		hint := "check that SLACK_API_TOKEN is valid"
+		if scope, ok := requiredScopes[method]; ok && !isTokenError(err) {
+			hint = fmt.Sprintf("add the %s scope to the Slack app and reinstall it", scope)
+		}
+		c.logger.Warn(fmt.Sprintf("skipping further %s calls this run; %s", method, hint), "method", method, "error", err)
+	}
+
+	return fmt.Errorf("%s %w: %w", method, errMethodDisabled, err)
+}
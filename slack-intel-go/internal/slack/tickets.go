@@ -0,0 +1,110 @@
+package slack
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/slack-go/slack"
+)
+
+// TicketSources selects the parts of a message scanned for JIRA ticket
+// keys. Integrations (JIRA and GitHub cards) often carry the key only in an
+// attachment or in Block Kit elements, outside the message text.
+type TicketSources struct {
+	// Text scans the message text
+	Text bool
+	// Attachments scans each attachment's pretext, title, title link and text
+	Attachments bool
+	// Blocks scans the text and url values of the Block Kit blocks
+	Blocks bool
+}
+
+// AllTicketSources scans text, attachments and blocks
+var AllTicketSources = TicketSources{Text: true, Attachments: true, Blocks: true}
+
+// ParseTicketSources maps the names in jira.ticket_sources (text,
+// attachments, blocks) to TicketSources; no names select them all
+func ParseTicketSources(names []string) (TicketSources, error) {
+	if len(names) == 0 {
+		return AllTicketSources, nil
+	}
+	var s TicketSources
+	for _, name := range names {
+		switch name {
+		case "text":
+			s.Text = true
+		case "attachments":
+			s.Attachments = true
+		case "blocks":
+			s.Blocks = true
+		default:
+			return TicketSources{}, fmt.Errorf("invalid ticket source %q (expected text, attachments or blocks)", name)
+		}
+	}
+	return s, nil
+}
+
+// WithTicketSources sets the parts of a message JIRA tickets are
+// extracted from; the default is AllTicketSources
+func WithTicketSources(s TicketSources) Option {
+	return func(c *Client) {
+		c.ticketSources = s
+	}
+}
+
+// tickets extracts the JIRA tickets of a message from the selected
+// sources, once each: those in the text first, then attachments, then
+// blocks
+func (s TicketSources) tickets(text string, attachments []slack.Attachment, blocks json.RawMessage) []string {
+	var parts []string
+	if s.Text {
+		parts = append(parts, text)
+	}
+	if s.Attachments {
+		for _, a := range attachments {
+			parts = append(parts, a.Pretext, a.Title, a.TitleLink, a.Text)
+		}
+	}
+	if s.Blocks && len(blocks) > 0 {
+		parts = append(parts, blockText(blocks)...)
+	}
+	return extractJiraTickets(strings.Join(parts, "\n"))
+}
+
+// blockText returns the text and url strings found anywhere in Block Kit
+// JSON, array elements in order and object members by key. Blocks that
+// fail to decode yield nothing.
+func blockText(blocks json.RawMessage) []string {
+	var tree interface{}
+	if err := json.Unmarshal(blocks, &tree); err != nil {
+		return nil
+	}
+	var texts []string
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case []interface{}:
+			for _, e := range v {
+				walk(e)
+			}
+		case map[string]interface{}:
+			for _, key := range []string{"text", "url"} {
+				if s, ok := v[key].(string); ok {
+					texts = append(texts, s)
+				}
+			}
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				walk(v[key])
+			}
+		}
+	}
+	walk(tree)
+	return texts
+}
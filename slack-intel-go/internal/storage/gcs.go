@@ -0,0 +1,268 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultGCSEndpoint is the Cloud Storage JSON API
+const DefaultGCSEndpoint = "https://storage.googleapis.com"
+
+// GCS stores files as objects in a Google Cloud Storage bucket under an
+// optional prefix, with the same object keys as S3. It talks to the JSON
+// API with OAuth2 access tokens.
+type GCS struct {
+	Bucket string
+	Prefix string
+	// Endpoint overrides DefaultGCSEndpoint, e.g. for an emulator
+	Endpoint string
+	// Tokens supplies access tokens; nil sends requests unauthenticated,
+	// as emulators expect
+	Tokens TokenSource
+	Client *http.Client
+
+	// ctx bounds every request; nil until BindContext
+	ctx context.Context
+}
+
+var _ Storage = (*GCS)(nil)
+
+// GCSOptions configures NewGCS
+type GCSOptions struct {
+	Bucket string
+	Prefix string
+	// CredentialsFile is a service account or authorized user JSON key;
+	// empty uses Application Default Credentials
+	CredentialsFile string
+}
+
+// NewGCS creates a GCS store. Without a credentials file, credentials come
+// from GOOGLE_APPLICATION_CREDENTIALS, the gcloud application default
+// credentials file or, failing both, the GCE metadata server.
+// STORAGE_EMULATOR_HOST points the store at an emulator without auth.
+func NewGCS(opts GCSOptions) (*GCS, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("gcs storage needs a bucket")
+	}
+	client := &http.Client{Timeout: 5 * time.Minute}
+	g := &GCS{
+		Bucket:   opts.Bucket,
+		Prefix:   strings.Trim(opts.Prefix, "/"),
+		Endpoint: DefaultGCSEndpoint,
+		Client:   client,
+	}
+
+	if host := os.Getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		g.Endpoint = strings.TrimSuffix(host, "/")
+		return g, nil
+	}
+
+	tokens, err := googleCredentials(opts.CredentialsFile, client)
+	if err != nil {
+		return nil, err
+	}
+	g.Tokens = tokens
+	return g, nil
+}
+
+// objectURL returns the JSON API URL of key, or of the bucket's object
+// collection when key is empty
+func (g *GCS) objectURL(key string, query url.Values) string {
+	u := strings.TrimSuffix(g.Endpoint, "/") + "/storage/v1/b/" + url.PathEscape(g.Bucket) + "/o"
+	if key != "" {
+		u += "/" + url.PathEscape(key)
+	}
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+func (g *GCS) bindContext(ctx context.Context) {
+	g.ctx = ctx
+}
+
+// do sends an authorized request and returns the response for 2xx
+// statuses. 404 becomes an error wrapping fs.ErrNotExist.
+func (g *GCS) do(method, rawURL, key string, body []byte) (*http.Response, error) {
+	ctx := g.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	}
+	if g.Tokens != nil {
+		token, err := g.Tokens.Token(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("gcs %s %s: %w", method, key, err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := g.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gcs %s %s: %w", method, key, err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: key, Err: fs.ErrNotExist}
+	}
+	return nil, fmt.Errorf("gcs %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(msg))
+}
+
+// Writer buffers the object and uploads it in one media upload on Close
+func (g *GCS) Writer(p string) (io.WriteCloser, error) {
+	return &gcsWriter{g: g, key: objectKey(g.Prefix, p)}, nil
+}
+
+type gcsWriter struct {
+	bytes.Buffer
+	g    *GCS
+	key  string
+	done bool
+}
+
+func (w *gcsWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	u := strings.TrimSuffix(w.g.Endpoint, "/") + "/upload/storage/v1/b/" + url.PathEscape(w.g.Bucket) +
+		"/o?" + url.Values{"uploadType": {"media"}, "name": {w.key}}.Encode()
+	resp, err := w.g.do(http.MethodPost, u, w.key, w.Bytes())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (w *gcsWriter) Abort() error {
+	w.done = true
+	return nil
+}
+
+// Reader downloads the object into memory
+func (g *GCS) Reader(p string) (File, error) {
+	key := objectKey(g.Prefix, p)
+	resp, err := g.do(http.MethodGet, g.objectURL(key, url.Values{"alt": {"media"}}), key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", p, err)
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+// gcsList is the part of an objects.list response we use
+type gcsList struct {
+	Items []struct {
+		Name string `json:"name"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// List pages objects.list over the directory prefix
+func (g *GCS) List(prefix string) ([]string, error) {
+	keyPrefix := objectKey(g.Prefix, prefix) + "/"
+	query := url.Values{"prefix": {keyPrefix}, "fields": {"items(name),nextPageToken"}}
+
+	var files []string
+	for {
+		resp, err := g.do(http.MethodGet, g.objectURL("", query), prefix, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		var result gcsList
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode listing of %s: %w", prefix, err)
+		}
+
+		for _, obj := range result.Items {
+			files = append(files, listedPath(prefix, keyPrefix, obj.Name))
+		}
+		if result.NextPageToken == "" {
+			break
+		}
+		query.Set("pageToken", result.NextPageToken)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Exists reads the object's metadata
+func (g *GCS) Exists(p string) (bool, error) {
+	_, err := g.Size(p)
+	if IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Size returns the size from the object's metadata
+func (g *GCS) Size(p string) (int64, error) {
+	key := objectKey(g.Prefix, p)
+	resp, err := g.do(http.MethodGet, g.objectURL(key, url.Values{"fields": {"size"}}), key, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// The JSON API encodes uint64 fields as strings
+	var meta struct {
+		Size string `json:"size"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
+		return 0, fmt.Errorf("failed to decode metadata of %s: %w", p, err)
+	}
+	size, err := strconv.ParseInt(meta.Size, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q for %s", meta.Size, p)
+	}
+	return size, nil
+}
+
+// Remove deletes the object
+func (g *GCS) Remove(p string) error {
+	key := objectKey(g.Prefix, p)
+	resp, err := g.do(http.MethodDelete, g.objectURL(key, nil), key, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
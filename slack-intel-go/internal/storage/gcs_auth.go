@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// gcsScope is the OAuth2 scope for reading and writing objects
+const gcsScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// googleTokenURL is where refresh tokens are exchanged
+const googleTokenURL = "https://oauth2.googleapis.com/token"
+
+// metadataTokenURL serves the attached service account's token on GCE,
+// GKE and Cloud Run
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// TokenSource supplies OAuth2 access tokens
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// googleKey is a service account or authorized user credentials file
+type googleKey struct {
+	Type         string `json:"type"`
+	ClientEmail  string `json:"client_email"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// googleCredentials loads credentialsFile, or finds Application Default
+// Credentials when it is empty
+func googleCredentials(credentialsFile string, client *http.Client) (TokenSource, error) {
+	if credentialsFile == "" {
+		credentialsFile = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	if credentialsFile == "" {
+		if wellKnown := gcloudCredentialsPath(); wellKnown != "" {
+			if _, err := os.Stat(wellKnown); err == nil {
+				credentialsFile = wellKnown
+			}
+		}
+	}
+	if credentialsFile == "" {
+		return &cachedToken{fetch: func(ctx context.Context) (*oauthToken, error) {
+			return metadataToken(ctx, client)
+		}}, nil
+	}
+
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS credentials: %w", err)
+	}
+	return parseGoogleKey(data, client)
+}
+
+// gcloudCredentialsPath is where `gcloud auth application-default login`
+// writes credentials
+func gcloudCredentialsPath() string {
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud", "application_default_credentials.json")
+		}
+		return ""
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// parseGoogleKey builds a token source from a credentials file
+func parseGoogleKey(data []byte, client *http.Client) (TokenSource, error) {
+	var key googleKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, fmt.Errorf("invalid GCS credentials: %w", err)
+	}
+
+	switch key.Type {
+	case "service_account":
+		block, _ := pem.Decode([]byte(key.PrivateKey))
+		if block == nil {
+			return nil, fmt.Errorf("invalid GCS credentials: no PEM private key")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid GCS credentials: %w", err)
+		}
+		rsaKey, ok := parsed.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("invalid GCS credentials: private key is not RSA")
+		}
+		tokenURL := key.TokenURI
+		if tokenURL == "" {
+			tokenURL = googleTokenURL
+		}
+		return &cachedToken{fetch: func(ctx context.Context) (*oauthToken, error) {
+			assertion, err := signJWT(rsaKey, key.ClientEmail, tokenURL, time.Now())
+			if err != nil {
+				return nil, err
+			}
+			return exchangeToken(ctx, client, tokenURL, url.Values{
+				"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+				"assertion":  {assertion},
+			})
+		}}, nil
+
+	case "authorized_user":
+		return &cachedToken{fetch: func(ctx context.Context) (*oauthToken, error) {
+			return exchangeToken(ctx, client, googleTokenURL, url.Values{
+				"grant_type":    {"refresh_token"},
+				"client_id":     {key.ClientID},
+				"client_secret": {key.ClientSecret},
+				"refresh_token": {key.RefreshToken},
+			})
+		}}, nil
+	}
+	return nil, fmt.Errorf("unsupported GCS credentials type %q (expected service_account or authorized_user)", key.Type)
+}
+
+// signJWT returns the RS256-signed assertion a service account trades for
+// an access token
+func signJWT(key *rsa.PrivateKey, email, audience string, now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]any{
+		"iss":   email,
+		"scope": gcsScope,
+		"aud":   audience,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + enc.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token request: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// oauthToken is an access token and when it expires
+type oauthToken struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	expiry      time.Time
+}
+
+// exchangeToken posts form to a token endpoint
+func exchangeToken(ctx context.Context, client *http.Client, tokenURL string, form url.Values) (*oauthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return doTokenRequest(client, req)
+}
+
+// metadataToken asks the GCE metadata server for a token
+func metadataToken(ctx context.Context, client *http.Client) (*oauthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	token, err := doTokenRequest(client, req)
+	if err != nil {
+		return nil, fmt.Errorf("no GCS credentials: set storage.credentials_file or GOOGLE_APPLICATION_CREDENTIALS, or run `gcloud auth application-default login` (%w)", err)
+	}
+	return token, nil
+}
+
+func doTokenRequest(client *http.Client, req *http.Request) (*oauthToken, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("token request failed: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token oauthToken
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return nil, fmt.Errorf("token request returned no access token")
+	}
+	token.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	return &token, nil
+}
+
+// cachedToken reuses a token until a minute before it expires
+type cachedToken struct {
+	mu    sync.Mutex
+	fetch func(ctx context.Context) (*oauthToken, error)
+	token *oauthToken
+}
+
+func (c *cachedToken) Token(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == nil || time.Until(c.token.expiry) < time.Minute {
+		token, err := c.fetch(ctx)
+		if err != nil {
+			return "", err
+		}
+		c.token = token
+	}
+	return c.token.AccessToken, nil
+}
@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCS serves the JSON API object, upload and list calls GCS uses
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+// staticToken is a TokenSource that always returns the same token
+type staticToken string
+
+func (s staticToken) Token(ctx context.Context) (string, error) { return string(s), nil }
+
+func newFakeGCS(t *testing.T) *GCS {
+	t.Helper()
+	fake := &fakeGCS{objects: make(map[string][]byte)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	return &GCS{Bucket: "bucket", Prefix: "slack", Endpoint: srv.URL, Tokens: staticToken("ya29.test")}
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer ya29.test" {
+		http.Error(w, "unauthenticated", http.StatusUnauthorized)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o" {
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Query().Get("name")] = data
+		json.NewEncoder(w).Encode(map[string]string{"name": r.URL.Query().Get("name")})
+		return
+	}
+
+	rest, ok := strings.CutPrefix(r.URL.EscapedPath(), "/storage/v1/b/bucket/o")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if rest == "" {
+		f.list(w, r)
+		return
+	}
+	key, _ := url.PathUnescape(strings.TrimPrefix(rest, "/"))
+	data, ok := f.objects[key]
+	if !ok {
+		http.Error(w, "No such object", http.StatusNotFound)
+		return
+	}
+	switch {
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.URL.Query().Get("alt") == "media":
+		w.Write(data)
+	default:
+		json.NewEncoder(w).Encode(map[string]string{"name": key, "size": fmt.Sprint(len(data))})
+	}
+}
+
+// list returns one object per page to exercise page tokens
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && k > r.URL.Query().Get("pageToken") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var result gcsList
+	if len(keys) > 0 {
+		result.Items = append(result.Items, struct {
+			Name string `json:"name"`
+		}{keys[0]})
+	}
+	if len(keys) > 1 {
+		result.NextPageToken = keys[0]
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func TestGCS(t *testing.T) {
+	testStorage(t, newFakeGCS(t), "cache/raw")
+}
+
+func TestGCSAbsoluteCachePath(t *testing.T) {
+	testStorage(t, newFakeGCS(t), "/data/slack/raw")
+}
+
+func TestGCSKeyEncoding(t *testing.T) {
+	g := &GCS{Bucket: "b", Prefix: "slack", Endpoint: DefaultGCSEndpoint}
+	got := g.objectURL(objectKey(g.Prefix, "cache/raw/messages/dt=2024-01-01/channel=general/data.parquet"), nil)
+	want := "https://storage.googleapis.com/storage/v1/b/b/o/slack%2Fcache%2Fraw%2Fmessages%2Fdt=2024-01-01%2Fchannel=general%2Fdata.parquet"
+	if got != want {
+		t.Errorf("objectURL = %s, want %s", got, want)
+	}
+}
+
+func TestGCSServiceAccountToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		r.ParseForm()
+		if got := r.Form.Get("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("grant_type = %q", got)
+		}
+		parts := strings.Split(r.Form.Get("assertion"), ".")
+		if len(parts) != 3 {
+			t.Fatalf("assertion has %d parts, want 3", len(parts))
+		}
+		claimsJSON, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]any
+		json.Unmarshal(claimsJSON, &claims)
+		if claims["iss"] != "intel@project.iam.gserviceaccount.com" || claims["scope"] != gcsScope || claims["aud"] != "http://"+r.Host+"/token" {
+			t.Errorf("claims = %v", claims)
+		}
+		json.NewEncoder(w).Encode(map[string]any{"access_token": "ya29.sa", "expires_in": 3600})
+	}))
+	t.Cleanup(srv.Close)
+
+	credentials, _ := json.Marshal(googleKey{
+		Type:        "service_account",
+		ClientEmail: "intel@project.iam.gserviceaccount.com",
+		PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		TokenURI:    srv.URL + "/token",
+	})
+	tokens, err := parseGoogleKey(credentials, srv.Client())
+	if err != nil {
+		t.Fatalf("parseGoogleKey: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if token, err := tokens.Token(context.Background()); err != nil || token != "ya29.sa" {
+			t.Fatalf("Token = %q, %v; want ya29.sa", token, err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("token endpoint called %d times, want 1 (cached)", requests)
+	}
+
+	if _, err := parseGoogleKey([]byte(`{"type":"external_account"}`), nil); err == nil {
+		t.Error("parseGoogleKey accepted an unsupported credentials type")
+	}
+}
+
+func TestNewGCSEmulator(t *testing.T) {
+	t.Setenv("STORAGE_EMULATOR_HOST", "localhost:4443")
+	g, err := NewGCS(GCSOptions{Bucket: "bucket"})
+	if err != nil {
+		t.Fatalf("NewGCS: %v", err)
+	}
+	if g.Endpoint != "http://localhost:4443" || g.Tokens != nil {
+		t.Errorf("emulator store = %s with tokens %v, want unauthenticated http://localhost:4443", g.Endpoint, g.Tokens)
+	}
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// Local stores files on the local filesystem. Paths are used as given,
+// relative to the working directory unless absolute; on Windows, paths
+// past MAX_PATH are opened through the \\?\ long-path prefix.
+type Local struct{}
+
+var (
+	_ Storage = Local{}
+	_ Renamer = Local{}
+)
+
+// Writer writes to a temporary file next to path and renames it into
+// place on Close, so readers never see a half-written file
+func (Local) Writer(path string) (io.WriteCloser, error) {
+	path = filepath.FromSlash(path)
+	target := osPath(path)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create directory for %s%s: %w", path, longPathHint(path), err)
+	}
+	tmp, err := createTemp(filepath.Dir(target), "."+filepath.Base(target)+"-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s%s: %w", path, longPathHint(path), err)
+	}
+	return &localWriter{File: tmp, path: path, target: target}, nil
+}
+
+// createTemp creates a new file in dir named prefix, a random number and
+// ".tmp". Unlike os.CreateTemp it uses the 0666 mode (less the umask) of
+// os.Create, since the file is renamed into place rather than kept private.
+func createTemp(dir, prefix string) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10)+".tmp")
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+		if errors.Is(err, fs.ErrExist) && try < 100 {
+			continue
+		}
+		return f, err
+	}
+}
+
+type localWriter struct {
+	*os.File
+	path string
+	// target is path as the os package opens it
+	target string
+	done   bool
+}
+
+func (w *localWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	if err := w.File.Close(); err != nil {
+		os.Remove(w.File.Name())
+		return fmt.Errorf("failed to write %s: %w", w.path, err)
+	}
+	if err := os.Rename(w.File.Name(), w.target); err != nil {
+		os.Remove(w.File.Name())
+		return fmt.Errorf("failed to write %s: %w", w.path, err)
+	}
+	return nil
+}
+
+func (w *localWriter) Abort() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.File.Close()
+	return os.Remove(w.File.Name())
+}
+
+// Reader opens the file at path
+func (Local) Reader(path string) (File, error) {
+	f, err := os.Open(osPath(path))
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// List walks the directory prefix; a missing directory yields no files
+func (Local) List(prefix string) ([]string, error) {
+	root := filepath.FromSlash(prefix)
+	walkRoot := osPath(prefix)
+	var files []string
+	err := filepath.WalkDir(walkRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == walkRoot && errors.Is(err, fs.ErrNotExist) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if walkRoot != root {
+			// Report paths under prefix as given, not the long-path form
+			rel, err := filepath.Rel(walkRoot, path)
+			if err != nil {
+				return err
+			}
+			path = filepath.Join(root, rel)
+		}
+		files = append(files, filepath.ToSlash(path))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Exists reports whether a regular file is stored at path
+func (l Local) Exists(path string) (bool, error) {
+	info, err := os.Stat(osPath(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.IsDir(), nil
+}
+
+// Size returns the file's length in bytes
+func (Local) Size(path string) (int64, error) {
+	info, err := os.Stat(osPath(path))
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Remove deletes the file at path
+func (Local) Remove(path string) error {
+	return os.Remove(osPath(path))
+}
+
+// Rename moves a file, creating the destination directory
+func (Local) Rename(from, to string) error {
+	target := osPath(to)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s%s: %w", filepath.FromSlash(to), longPathHint(to), err)
+	}
+	return os.Rename(osPath(from), target)
+}
@@ -0,0 +1,117 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Memory keeps files in memory. It is meant for tests.
+type Memory struct {
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+var _ Storage = (*Memory)(nil)
+
+// NewMemory creates an empty in-memory store
+func NewMemory() *Memory {
+	return &Memory{files: make(map[string][]byte)}
+}
+
+// Writer buffers writes and stores them on Close
+func (m *Memory) Writer(p string) (io.WriteCloser, error) {
+	return &memoryWriter{m: m, path: path.Clean(p)}, nil
+}
+
+type memoryWriter struct {
+	bytes.Buffer
+	m    *Memory
+	path string
+	done bool
+}
+
+func (w *memoryWriter) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	w.m.files[w.path] = append([]byte(nil), w.Bytes()...)
+	return nil
+}
+
+func (w *memoryWriter) Abort() error {
+	w.done = true
+	return nil
+}
+
+// Reader returns a reader over a copy of the file
+func (m *Memory) Reader(p string) (File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[path.Clean(p)]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: p, Err: fs.ErrNotExist}
+	}
+	return nopCloser{bytes.NewReader(append([]byte(nil), data...))}, nil
+}
+
+// List returns the files under the directory prefix
+func (m *Memory) List(prefix string) ([]string, error) {
+	dir := path.Clean(prefix) + "/"
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var files []string
+	for p := range m.files {
+		if strings.HasPrefix(p, dir) {
+			files = append(files, p)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Exists reports whether a file is stored at path
+func (m *Memory) Exists(p string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.files[path.Clean(p)]
+	return ok, nil
+}
+
+// Size returns the file's length in bytes
+func (m *Memory) Size(p string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[path.Clean(p)]
+	if !ok {
+		return 0, &fs.PathError{Op: "stat", Path: p, Err: fs.ErrNotExist}
+	}
+	return int64(len(data)), nil
+}
+
+// Remove deletes the file at path
+func (m *Memory) Remove(p string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[path.Clean(p)]; !ok {
+		return fmt.Errorf("failed to remove %s: %w", p, fs.ErrNotExist)
+	}
+	delete(m.files, path.Clean(p))
+	return nil
+}
+
+// nopCloser adds a no-op Close to an in-memory reader
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
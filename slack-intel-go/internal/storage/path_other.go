@@ -0,0 +1,16 @@
+//go:build !windows
+
+package storage
+
+import "path/filepath"
+
+// osPath converts a slash-separated path for the os package
+func osPath(path string) string {
+	return filepath.FromSlash(path)
+}
+
+// longPathHint explains a failure on a long path; only Windows has a limit
+// worth naming
+func longPathHint(string) string {
+	return ""
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package storage
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the longest path Win32 calls accept without the \\?\ prefix:
+// MAX_PATH less room for an 8.3 file name, which CreateDirectory needs
+const maxPath = 248
+
+// osPath converts a slash-separated path for the os package. Paths whose
+// absolute form is maxPath or more, as a short relative path under a deep
+// working directory can be, are made absolute and given the \\?\ (or
+// \\?\UNC\) prefix so they open without the LongPathsEnabled policy.
+func osPath(path string) string {
+	path = filepath.FromSlash(path)
+	if strings.HasPrefix(path, `\\?\`) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil || len(abs) < maxPath {
+		return path
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
+
+// longPathHint explains a failure on a path past MAX_PATH, measured in
+// its absolute form
+func longPathHint(path string) string {
+	if abs, err := filepath.Abs(filepath.FromSlash(path)); err == nil {
+		path = abs
+	}
+	if len(path) < maxPath {
+		return ""
+	}
+	return fmt.Sprintf(" (the path is %d characters, past Windows' MAX_PATH; use a shorter --cache-path or enable LongPathsEnabled)", len(path))
+}
@@ -0,0 +1,60 @@
+//go:build windows
+
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOSPath(t *testing.T) {
+	if got := osPath("cache/raw/users.parquet"); got != `cache\raw\users.parquet` {
+		t.Errorf("short path = %q, want it converted only", got)
+	}
+
+	long := `C:\` + strings.Repeat(`d\`, maxPath/2) + "users.parquet"
+	if got := osPath(filepath.ToSlash(long)); got != `\\?\`+long {
+		t.Errorf("long path = %q, want the \\\\?\\ prefix", got)
+	}
+	if got := osPath(`\\?\` + long); got != `\\?\`+long {
+		t.Errorf("prefixed path = %q, want it unchanged", got)
+	}
+
+	unc := `\\server\share\` + strings.Repeat(`d\`, maxPath/2) + "users.parquet"
+	if got := osPath(unc); got != `\\?\UNC\server\share\`+unc[len(`\\server\share\`):] {
+		t.Errorf("long UNC path = %q, want the \\\\?\\UNC\\ prefix", got)
+	}
+
+	rel := strings.Repeat("d/", maxPath/2) + "users.parquet"
+	if got := osPath(rel); !strings.HasPrefix(got, `\\?\`) || !filepath.IsAbs(got[len(`\\?\`):]) {
+		t.Errorf("long relative path = %q, want it made absolute", got)
+	}
+
+	// A short relative path is long once resolved under a deep directory
+	deep := filepath.Join(t.TempDir(), strings.Repeat("d", maxPath))
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(filepath.Dir(deep)); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if got := osPath(filepath.Base(deep) + "/users.parquet"); got != `\\?\`+filepath.Join(deep, "users.parquet") {
+		t.Errorf("short path under a deep directory = %q, want it made absolute with the prefix", got)
+	}
+	if hint := longPathHint(filepath.Base(deep)); !strings.Contains(hint, "MAX_PATH") {
+		t.Errorf("hint for a short path under a deep directory = %q, want MAX_PATH named", hint)
+	}
+}
+
+func TestLongPathHint(t *testing.T) {
+	if hint := longPathHint("cache/raw"); hint != "" {
+		t.Errorf("short path hint = %q, want none", hint)
+	}
+	if hint := longPathHint(strings.Repeat("d", maxPath)); !strings.Contains(hint, "MAX_PATH") {
+		t.Errorf("long path hint = %q, want MAX_PATH named", hint)
+	}
+}
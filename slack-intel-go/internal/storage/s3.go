@@ -0,0 +1,517 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 stores files as objects in an S3 bucket under an optional key prefix.
+// Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	Bucket string
+	Prefix string
+	Region string
+	// Endpoint overrides https://s3.<region>.amazonaws.com, e.g. for MinIO
+	// (scheme and host, like http://minio.internal:9000)
+	Endpoint string
+	// ForcePathStyle addresses objects as <endpoint>/<bucket>/<key> rather
+	// than <bucket>.<endpoint>/<key>; MinIO usually needs it
+	ForcePathStyle bool
+	// SSE (aws:kms or AES256), KMSKeyID and StorageClass are sent with
+	// every PUT
+	SSE          string
+	KMSKeyID     string
+	StorageClass string
+	Credentials  Credentials
+	Client       *http.Client
+
+	now func() time.Time
+	// ctx bounds every request; nil until BindContext
+	ctx context.Context
+}
+
+// Credentials are the AWS access keys used to sign requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+var _ Storage = (*S3)(nil)
+
+// S3Options configures NewS3
+type S3Options struct {
+	Bucket  string
+	Prefix  string
+	Region  string
+	Profile string
+	// Endpoint is a host[:port] or URL of an S3-compatible server
+	Endpoint       string
+	ForcePathStyle bool
+	// DisableSSL talks plain HTTP to a scheme-less Endpoint (or to AWS)
+	DisableSSL   bool
+	SSE          string
+	KMSKeyID     string
+	StorageClass string
+}
+
+// NewS3 creates an S3 store. Credentials come from AWS_ACCESS_KEY_ID /
+// AWS_SECRET_ACCESS_KEY (/ AWS_SESSION_TOKEN) or, failing that, the named
+// profile in ~/.aws/credentials. An empty region falls back to AWS_REGION.
+// Without an Endpoint, AWS_ENDPOINT_URL sets one, always path-style.
+func NewS3(opts S3Options) (*S3, error) {
+	if opts.Bucket == "" {
+		return nil, fmt.Errorf("s3 storage needs a bucket")
+	}
+	region := opts.Region
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint, pathStyle := opts.Endpoint, opts.ForcePathStyle
+	if endpoint == "" {
+		if env := os.Getenv("AWS_ENDPOINT_URL"); env != "" {
+			endpoint, pathStyle = env, true
+		}
+	}
+	scheme := "https"
+	if opts.DisableSSL {
+		scheme = "http"
+	}
+	switch {
+	case endpoint == "" && opts.DisableSSL:
+		endpoint = fmt.Sprintf("http://s3.%s.amazonaws.com", region)
+	case endpoint != "" && !strings.Contains(endpoint, "://"):
+		endpoint = scheme + "://" + endpoint
+	}
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %q", endpoint)
+		}
+		if opts.DisableSSL && u.Scheme == "https" {
+			return nil, fmt.Errorf("disable_ssl conflicts with the https endpoint %q", endpoint)
+		}
+	}
+
+	creds, err := loadCredentials(opts.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{
+		Bucket:         opts.Bucket,
+		Prefix:         strings.Trim(opts.Prefix, "/"),
+		Region:         region,
+		Endpoint:       strings.TrimSuffix(endpoint, "/"),
+		ForcePathStyle: pathStyle,
+		SSE:            opts.SSE,
+		KMSKeyID:       opts.KMSKeyID,
+		StorageClass:   opts.StorageClass,
+		Credentials:    creds,
+		Client:         &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+// loadCredentials reads keys from the environment, then the shared
+// credentials file
+func loadCredentials(profile string) (Credentials, error) {
+	creds := Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID != "" && creds.SecretAccessKey != "" {
+		return creds, nil
+	}
+
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	credsPath := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if credsPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return creds, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		credsPath = filepath.Join(home, ".aws", "credentials")
+	}
+
+	f, err := os.Open(credsPath)
+	if err != nil {
+		return creds, fmt.Errorf("no AWS credentials: set AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY or create %s", credsPath)
+	}
+	defer f.Close()
+
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return creds, fmt.Errorf("failed to read %s: %w", credsPath, err)
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, fmt.Errorf("no AWS credentials for profile %q in %s", profile, credsPath)
+	}
+	return creds, nil
+}
+
+func (s *S3) bindContext(ctx context.Context) {
+	s.ctx = ctx
+}
+
+// key maps a cache path to an object key
+func (s *S3) key(p string) string {
+	return objectKey(s.Prefix, p)
+}
+
+// objectKey maps a cache path to an object key under prefix. Every bucket
+// backend uses it, so a cache has the same keys whichever provider holds it.
+func objectKey(prefix, p string) string {
+	p = strings.TrimPrefix(path.Clean("/"+p), "/")
+	if prefix == "" {
+		return p
+	}
+	return prefix + "/" + p
+}
+
+// listedPath maps a key found by listing keyPrefix, the object key of the
+// cache directory dir, back to a path under dir as the caller spelled it.
+// objectKey drops a leading slash, so an absolute cache path could not be
+// recovered from the key alone.
+func listedPath(dir, keyPrefix, key string) string {
+	return strings.TrimSuffix(dir, "/") + "/" + strings.TrimPrefix(key, keyPrefix)
+}
+
+// objectURL returns the URL for key (or the bucket when key is empty)
+func (s *S3) objectURL(key string, query url.Values) string {
+	endpoint := strings.TrimSuffix(s.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.Region)
+	}
+	var base string
+	if s.ForcePathStyle {
+		base = endpoint + "/" + uriEncode(s.Bucket, false)
+	} else {
+		scheme, host, _ := strings.Cut(endpoint, "://")
+		base = scheme + "://" + s.Bucket + "." + host
+	}
+	u := base + "/" + uriEncode(key, false)
+	if len(query) > 0 {
+		u += "?" + canonicalQuery(query)
+	}
+	return u
+}
+
+// do sends a signed request and returns the response for 2xx statuses.
+// 404 becomes an error wrapping fs.ErrNotExist.
+func (s *S3) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	ctx := s.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if method == http.MethodPut {
+		s.setUploadHeaders(req)
+	}
+	s.sign(req, body)
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s: %w", method, key, err)
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: key, Err: fs.ErrNotExist}
+	}
+	return nil, fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(msg))
+}
+
+// setUploadHeaders adds the encryption and storage class headers to a PUT
+func (s *S3) setUploadHeaders(req *http.Request) {
+	if s.SSE != "" {
+		req.Header.Set("x-amz-server-side-encryption", s.SSE)
+	}
+	if s.KMSKeyID != "" {
+		req.Header.Set("x-amz-server-side-encryption-aws-kms-key-id", s.KMSKeyID)
+	}
+	if s.StorageClass != "" {
+		req.Header.Set("x-amz-storage-class", s.StorageClass)
+	}
+}
+
+// Writer buffers the object and uploads it with a single PUT on Close
+func (s *S3) Writer(p string) (io.WriteCloser, error) {
+	return &s3Writer{s: s, key: s.key(p)}, nil
+}
+
+type s3Writer struct {
+	bytes.Buffer
+	s    *S3
+	key  string
+	done bool
+}
+
+func (w *s3Writer) Close() error {
+	if w.done {
+		return nil
+	}
+	w.done = true
+	resp, err := w.s.do(http.MethodPut, w.key, nil, w.Bytes())
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+func (w *s3Writer) Abort() error {
+	w.done = true
+	return nil
+}
+
+// Reader downloads the object into memory
+func (s *S3) Reader(p string) (File, error) {
+	resp, err := s.do(http.MethodGet, s.key(p), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", p, err)
+	}
+	return nopCloser{bytes.NewReader(data)}, nil
+}
+
+// listResult is the part of a ListObjectsV2 response we use
+type listResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// List pages ListObjectsV2 over the directory prefix
+func (s *S3) List(prefix string) ([]string, error) {
+	keyPrefix := s.key(prefix) + "/"
+	query := url.Values{
+		"list-type": {"2"},
+		"prefix":    {keyPrefix},
+	}
+
+	var files []string
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", prefix, err)
+		}
+		var result listResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode listing of %s: %w", prefix, err)
+		}
+
+		for _, obj := range result.Contents {
+			files = append(files, listedPath(prefix, keyPrefix, obj.Key))
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Exists issues a HEAD request for the object
+func (s *S3) Exists(p string) (bool, error) {
+	_, err := s.Size(p)
+	if IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Size returns the object's Content-Length
+func (s *S3) Size(p string) (int64, error) {
+	resp, err := s.do(http.MethodHead, s.key(p), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.ContentLength, nil
+}
+
+// Remove deletes the object
+func (s *S3) Remove(p string) error {
+	resp, err := s.do(http.MethodDelete, s.key(p), nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// sign adds SigV4 headers for the s3 service
+func (s *S3) sign(req *http.Request, body []byte) {
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	day := t.Format("20060102")
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.Credentials.SessionToken != "" {
+		req.Header.Set("x-amz-security-token", s.Credentials.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.Credentials.SecretAccessKey), day)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.Credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalQuery sorts and encodes query parameters as SigV4 requires
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters, keeping
+// '/' unless encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -0,0 +1,102 @@
+// Package storage abstracts where the Parquet cache lives so the same code
+// can write to the local filesystem or an S3 bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// File is an open cache file. Parquet readers need random access.
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Seeker
+	io.Closer
+}
+
+// Storage reads and writes cache files. Paths are slash-separated; a
+// missing file is reported with an error wrapping fs.ErrNotExist.
+type Storage interface {
+	// Writer returns a writer whose contents become visible at path only
+	// once Close succeeds. Use Abort to discard a partial write.
+	Writer(path string) (io.WriteCloser, error)
+	// Reader opens the file at path
+	Reader(path string) (File, error)
+	// List returns every file under the directory prefix, sorted
+	List(prefix string) ([]string, error)
+	// Exists reports whether a file is stored at path
+	Exists(path string) (bool, error)
+	// Size returns the length in bytes of the file at path
+	Size(path string) (int64, error)
+	// Remove deletes the file at path
+	Remove(path string) error
+}
+
+// contextBinder is implemented by backends that send network requests
+type contextBinder interface {
+	bindContext(ctx context.Context)
+}
+
+// BindContext makes every request s sends use ctx, so cancelling it
+// aborts uploads, downloads and listings in flight. An aborted upload
+// leaves the object as it was. Local storage ignores it.
+func BindContext(ctx context.Context, s Storage) {
+	if b, ok := s.(contextBinder); ok {
+		b.bindContext(ctx)
+	}
+}
+
+// Renamer is implemented by backends that can move a file cheaply
+type Renamer interface {
+	Rename(from, to string) error
+}
+
+// aborter is implemented by writers that can drop buffered data instead
+// of publishing it on Close
+type aborter interface {
+	Abort() error
+}
+
+// Abort discards a Writer without publishing what was written
+func Abort(w io.WriteCloser) error {
+	if a, ok := w.(aborter); ok {
+		return a.Abort()
+	}
+	return w.Close()
+}
+
+// Move relocates a file, renaming in place when the backend supports it
+// and copying then removing otherwise
+func Move(s Storage, from, to string) error {
+	if r, ok := s.(Renamer); ok {
+		return r.Rename(from, to)
+	}
+
+	src, err := s.Reader(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := s.Writer(to)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		Abort(dst)
+		return fmt.Errorf("failed to copy %s: %w", from, err)
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return s.Remove(from)
+}
+
+// IsNotExist reports whether err means the file does not exist
+func IsNotExist(err error) bool {
+	return errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testStorage exercises a backend rooted at dir
+func testStorage(t *testing.T, s Storage, dir string) {
+	t.Helper()
+	write := func(p, data string) {
+		t.Helper()
+		w, err := s.Writer(p)
+		if err != nil {
+			t.Fatalf("Writer(%s): %v", p, err)
+		}
+		io.WriteString(w, data)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close(%s): %v", p, err)
+		}
+	}
+
+	a := path.Join(dir, "messages/dt=2024-01-01/channel=general/data.parquet")
+	b := path.Join(dir, "messages/dt=2024-01-02/channel=general/data.parquet")
+	write(b, "second")
+	write(a, "first")
+
+	if ok, err := s.Exists(a); err != nil || !ok {
+		t.Errorf("Exists(a) = %v, %v", ok, err)
+	}
+	if n, err := s.Size(a); err != nil || n != 5 {
+		t.Errorf("Size(a) = %d, %v, want 5", n, err)
+	}
+
+	r, err := s.Reader(a)
+	if err != nil {
+		t.Fatalf("Reader: %v", err)
+	}
+	buf := make([]byte, 3)
+	if _, err := r.ReadAt(buf, 2); err != nil || string(buf) != "rst" {
+		t.Errorf("ReadAt = %q, %v", buf, err)
+	}
+	r.Close()
+
+	files, err := s.List(path.Join(dir, "messages"))
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{a, b}; !reflect.DeepEqual(files, want) {
+		t.Errorf("List = %v, want %v", files, want)
+	}
+	if files, err := s.List(path.Join(dir, "missing")); err != nil || len(files) != 0 {
+		t.Errorf("List(missing) = %v, %v, want empty", files, err)
+	}
+
+	aborted := path.Join(dir, "aborted.parquet")
+	w, err := s.Writer(aborted)
+	if err != nil {
+		t.Fatalf("Writer: %v", err)
+	}
+	io.WriteString(w, "partial")
+	if err := Abort(w); err != nil {
+		t.Errorf("Abort: %v", err)
+	}
+	if ok, _ := s.Exists(aborted); ok {
+		t.Error("aborted write should not be visible")
+	}
+
+	moved := path.Join(dir, ".corrupt/data.parquet")
+	if err := Move(s, b, moved); err != nil {
+		t.Fatalf("Move: %v", err)
+	}
+	if ok, _ := s.Exists(b); ok {
+		t.Error("moved file still at source")
+	}
+	if ok, _ := s.Exists(moved); !ok {
+		t.Error("moved file missing at destination")
+	}
+
+	if err := s.Remove(a); err != nil {
+		t.Errorf("Remove: %v", err)
+	}
+	if _, err := s.Reader(a); !IsNotExist(err) {
+		t.Errorf("Reader after Remove = %v, want not-exist", err)
+	}
+	if _, err := s.Size(a); !IsNotExist(err) {
+		t.Errorf("Size after Remove = %v, want not-exist", err)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	testStorage(t, Local{}, t.TempDir())
+}
+
+// TestLocalFileMode checks written files get the mode os.Create gives, not
+// the owner-only mode of os.CreateTemp
+func TestLocalFileMode(t *testing.T) {
+	dir := t.TempDir()
+	ref, err := os.Create(filepath.Join(dir, "ref"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref.Close()
+
+	w, err := Local{}.Writer(filepath.ToSlash(filepath.Join(dir, "data.parquet")))
+	if err != nil {
+		t.Fatalf("Writer: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	want, err := os.Stat(filepath.Join(dir, "ref"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.Stat(filepath.Join(dir, "data.parquet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Mode() != want.Mode() {
+		t.Errorf("mode = %v, want %v", got.Mode(), want.Mode())
+	}
+}
+
+// TestLocalLongPath runs the backend past Windows' 260-character MAX_PATH
+func TestLocalLongPath(t *testing.T) {
+	dir := t.TempDir()
+	for len(dir) < 300 {
+		dir = path.Join(dir, strings.Repeat("d", 50))
+	}
+	testStorage(t, Local{}, filepath.ToSlash(dir))
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, NewMemory(), "cache/raw")
+}
@@ -1,69 +1,378 @@
 package config
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config represents the .slack-intel.yaml configuration
 type Config struct {
-	Channels []ChannelConfig `yaml:"channels"`
-	Storage  StorageConfig   `yaml:"storage,omitempty"`
-	Jira     JiraConfig      `yaml:"jira,omitempty"`
+	Channels []ChannelConfig     `yaml:"channels"`
+	Groups   map[string][]string `yaml:"groups,omitempty"`
+	Storage  StorageConfig       `yaml:"storage,omitempty"`
+	Jira     JiraConfig          `yaml:"jira,omitempty"`
+	Filters  FiltersConfig       `yaml:"filters,omitempty"`
+	Activity ActivityConfig      `yaml:"activity,omitempty"`
+	Auth     AuthConfig          `yaml:"auth,omitempty"`
+	// Profiles are alternative workspaces, selected with --profile
+	Profiles map[string]ProfileConfig `yaml:"profiles,omitempty"`
+
+	// Sections only used by the Python CLI, kept so strict parsing
+	// accepts a shared config file
+	Organization map[string]interface{} `yaml:"organization,omitempty"`
+	Analysis     map[string]interface{} `yaml:"analysis,omitempty"`
+
+	// Path is the file the config was loaded from
+	Path string `yaml:"-"`
+	// Profile is the profile applied by LoadProfile, and TokenEnv its
+	// token_env
+	Profile  string `yaml:"-"`
+	TokenEnv string `yaml:"-"`
 }
 
 // ChannelConfig represents a channel configuration
 type ChannelConfig struct {
-	Name string `yaml:"name"`
-	ID   string `yaml:"id"`
+	Name        string `yaml:"name"`
+	ID          string `yaml:"id"`
+	Description string `yaml:"description,omitempty"`
+	SignalType  string `yaml:"signal_type,omitempty"`
+	// RateLimit caps this channel's API calls at this many requests per
+	// second on a limiter of their own; 0 shares the global limiter
+	RateLimit float64 `yaml:"rate_limit,omitempty"`
+}
+
+// ChannelRateLimits maps the IDs of channels with a rate_limit override
+// to their limit
+func (c *Config) ChannelRateLimits() map[string]float64 {
+	limits := make(map[string]float64)
+	for _, ch := range c.Channels {
+		if ch.RateLimit > 0 {
+			limits[ch.ID] = ch.RateLimit
+		}
+	}
+	return limits
 }
 
-// StorageConfig represents S3 storage configuration
+// StorageConfig represents bucket storage configuration. Provider is s3
+// (the default) or gcs. Endpoint points at an S3-compatible server such as
+// MinIO, which usually also needs ForcePathStyle (<endpoint>/<bucket>/<key>
+// instead of <bucket>.<endpoint>).
 type StorageConfig struct {
-	Bucket  string `yaml:"bucket,omitempty"`
-	Prefix  string `yaml:"prefix,omitempty"`
-	Region  string `yaml:"region,omitempty"`
-	Profile string `yaml:"profile,omitempty"`
+	Provider       string `yaml:"provider,omitempty"` // s3 or gcs
+	Bucket         string `yaml:"bucket,omitempty"`
+	Prefix         string `yaml:"prefix,omitempty"`
+	Region         string `yaml:"region,omitempty"`
+	Profile        string `yaml:"profile,omitempty"`
+	Endpoint       string `yaml:"endpoint,omitempty"`
+	ForcePathStyle bool   `yaml:"force_path_style,omitempty"`
+	DisableSSL     bool   `yaml:"disable_ssl,omitempty"`
+	// SSE, KMSKeyID and StorageClass are set on every upload
+	SSE          string `yaml:"sse,omitempty"` // aws:kms or AES256
+	KMSKeyID     string `yaml:"kms_key_id,omitempty"`
+	StorageClass string `yaml:"storage_class,omitempty"` // e.g. STANDARD_IA
+	// CredentialsFile is a GCS service account or authorized user key;
+	// empty uses Application Default Credentials
+	CredentialsFile string `yaml:"credentials_file,omitempty"`
+	// PartitionTimezone is the IANA zone dt= partitions are cut in, for
+	// local and bucket storage alike; empty means UTC
+	PartitionTimezone string `yaml:"partition_timezone,omitempty"`
+	// PartFileName names each partition's Parquet data file, e.g.
+	// part-0.snappy.parquet; empty means data.parquet
+	PartFileName string `yaml:"part_filename,omitempty"`
+}
+
+// StorageProvider returns Provider, defaulting to s3
+func (s StorageConfig) StorageProvider() string {
+	if s.Provider == "" {
+		return "s3"
+	}
+	return s.Provider
+}
+
+// PartitionLocation returns the time zone of PartitionTimezone, UTC when
+// it is empty
+func (s StorageConfig) PartitionLocation() (*time.Location, error) {
+	return LoadZone("partition_timezone", s.PartitionTimezone)
+}
+
+// LoadZone loads the IANA zone name set as key (a config key or flag),
+// UTC when it is empty
+func LoadZone(key, name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	// "Local" would derive different values on every machine
+	if name == "Local" {
+		return nil, fmt.Errorf("invalid %s Local (name a zone such as Europe/Berlin)", key)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s %q: %w", key, name, err)
+	}
+	return loc, nil
+}
+
+// AuthConfig says where the Slack token comes from when its environment
+// variable is unset
+type AuthConfig struct {
+	// TokenCommand is a shell command printing the token on stdout, e.g.
+	// "op read op://infra/slack/token"; surrounding whitespace is trimmed
+	TokenCommand string `yaml:"token_command,omitempty"`
 }
 
 // JiraConfig represents JIRA configuration
 type JiraConfig struct {
 	Server string `yaml:"server,omitempty"`
+	// TicketSources are the parts of a message ticket keys are extracted
+	// from: text, attachments and blocks; empty means all three
+	TicketSources []string `yaml:"ticket_sources,omitempty"`
+}
+
+// FiltersConfig controls which fetched messages are kept in the cache
+type FiltersConfig struct {
+	ExcludeBots bool `yaml:"exclude_bots,omitempty"`
+}
+
+// ActivityConfig sets how the derived iso_week, weekday, hour_of_day and
+// is_business_hours columns read message timestamps
+type ActivityConfig struct {
+	// Timezone is the IANA zone the columns are derived in; empty means UTC
+	Timezone string `yaml:"timezone,omitempty"`
+	// BusinessHours is the local working day as HH:MM-HH:MM, Monday to
+	// Friday; empty means DefaultBusinessHours
+	BusinessHours string `yaml:"business_hours,omitempty"`
 }
 
-// Load reads configuration from .slack-intel.yaml
-// Looks in current directory first, then home directory
-func Load() (*Config, error) {
-	configPaths := []string{
-		".slack-intel.yaml",
-		filepath.Join(os.Getenv("HOME"), ".slack-intel.yaml"),
+// DefaultBusinessHours is the working day used without activity.business_hours
+const DefaultBusinessHours = "09:00-17:00"
+
+// Location returns the time zone of Timezone, UTC when it is empty
+func (a ActivityConfig) Location() (*time.Location, error) {
+	return LoadZone("activity.timezone", a.Timezone)
+}
+
+// Hours returns the start and end of BusinessHours as offsets from local
+// midnight
+func (a ActivityConfig) Hours() (start, end time.Duration, err error) {
+	hours := a.BusinessHours
+	if hours == "" {
+		hours = DefaultBusinessHours
+	}
+	from, to, ok := strings.Cut(hours, "-")
+	if ok {
+		if start, err = clockOffset(from); err == nil {
+			end, err = clockOffset(to)
+		}
+	}
+	if !ok || err != nil || start >= end {
+		return 0, 0, fmt.Errorf("invalid activity.business_hours %q (expected HH:MM-HH:MM, e.g. %s)", a.BusinessHours, DefaultBusinessHours)
 	}
+	return start, end, nil
+}
 
-	for _, path := range configPaths {
-		if _, err := os.Stat(path); err == nil {
-			data, err := os.ReadFile(path)
-			if err != nil {
-				continue
-			}
+// clockOffset parses HH:MM (24:00 allowed) as an offset from midnight
+func clockOffset(s string) (time.Duration, error) {
+	var h, m int
+	if _, err := fmt.Sscanf(strings.TrimSpace(s), "%d:%d", &h, &m); err != nil {
+		return 0, err
+	}
+	if h < 0 || m < 0 || m > 59 || h*60+m > 24*60 {
+		return 0, fmt.Errorf("%s is not a time of day", s)
+	}
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+}
+
+// EnvConfigPath names the environment variable that points at a config file
+const EnvConfigPath = "SLACK_INTEL_CONFIG"
+
+// ErrNoConfig is returned by Resolve when no config file exists in the search paths
+var ErrNoConfig = errors.New("no config file found")
+
+// searchPaths returns the config locations checked when no path is given, in order
+func searchPaths() []string {
+	return []string{
+		DefaultFileName,
+		filepath.Join(os.Getenv("HOME"), DefaultFileName),
+	}
+}
+
+// Resolve picks the config file to use. Precedence is the explicit path
+// (the --config flag), then $SLACK_INTEL_CONFIG, then the current
+// directory, then the home directory. An explicit path or env value that
+// does not exist is an error rather than a silent fallback.
+func Resolve(path string) (string, error) {
+	source := "--config"
+	if path == "" {
+		path = os.Getenv(EnvConfigPath)
+		source = EnvConfigPath
+	}
+
+	if path != "" {
+		if _, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("config file %s (from %s): %w", path, source, err)
+		}
+		return path, nil
+	}
+
+	paths := searchPaths()
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("%w (tried %s)", ErrNoConfig, strings.Join(paths, ", "))
+}
+
+// Load reads configuration from the file picked by Resolve and overlays the
+// SLACK_INTEL_* environment overrides (see ApplyEnv).
+// An empty path searches $SLACK_INTEL_CONFIG, the current directory
+// and the home directory. When no file exists the config comes from the
+// environment alone, or the returned error wraps ErrNoConfig if no
+// overrides are set; a file that exists but is empty yields a zero Config.
+func Load(path string) (*Config, error) {
+	return LoadProfile(path, "")
+}
+
+// LoadProfile is Load with the named profile overlaid on the file's
+// top-level settings before the environment overrides; see UseProfile.
+// A profile needs a config file.
+func LoadProfile(path, profile string) (*Config, error) {
+	resolved, err := Resolve(path)
+	if errors.Is(err, ErrNoConfig) && profile != "" {
+		return nil, fmt.Errorf("profile %q: %w", profile, err)
+	}
+	if errors.Is(err, ErrNoConfig) {
+		cfg, applied, envErr := loadEnvOnly()
+		if envErr != nil {
+			return nil, envErr
+		}
+		if applied {
+			return cfg, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", resolved, err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", resolved, err)
+	}
+	cfg.Path = resolved
+	if err := cfg.UseProfile(profile); err != nil {
+		return nil, err
+	}
+
+	if _, err := cfg.ApplyEnv(os.Getenv); err != nil {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// ParseStrict decodes config YAML, treating unknown keys as errors
+func ParseStrict(data []byte) (*Config, error) {
+	var cfg Config
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// PlaceholderChannelID is the example channel ID in scaffolded configs and
+// docs; it never names a real channel
+const PlaceholderChannelID = "C0123456789"
+
+// ErrNoChannels is returned by CheckChannels for an empty channel list
+var ErrNoChannels = errors.New("no channels to fetch")
+
+// CheckChannels rejects a resolved channel list that would fetch nothing
+// real: an empty list, or an entry without an ID or with the scaffold's
+// placeholder ID
+func CheckChannels(channels []ChannelConfig) error {
+	if len(channels) == 0 {
+		return ErrNoChannels
+	}
+	for _, ch := range channels {
+		switch ch.ID {
+		case "":
+			return fmt.Errorf("channel %q has no id", ch.Name)
+		case PlaceholderChannelID:
+			return fmt.Errorf("channel %q has the example id %s", ch.Name, ch.ID)
+		}
+	}
+	return nil
+}
+
+// ResolveGroups returns the union of channels in the named groups, in
+// config order. Group members may be channel names or IDs but must be
+// defined under channels:, and a group must have at least one.
+func (c *Config) ResolveGroups(names []string) ([]ChannelConfig, error) {
+	selected := make(map[int]bool)
 
-			var cfg Config
-			if err := yaml.Unmarshal(data, &cfg); err != nil {
-				return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	for _, name := range names {
+		members, ok := c.Groups[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown channel group %q", name)
+		}
+		if len(members) == 0 {
+			return nil, fmt.Errorf("group %q lists no channels", name)
+		}
+		for _, member := range members {
+			idx := c.channelIndex(member)
+			if idx < 0 {
+				return nil, fmt.Errorf("group %q references %q, which is not defined in channels", name, member)
 			}
+			selected[idx] = true
+		}
+	}
 
-			return &cfg, nil
+	var channels []ChannelConfig
+	for i, ch := range c.Channels {
+		if selected[i] {
+			channels = append(channels, ch)
 		}
 	}
+	return channels, nil
+}
 
-	// Return default config if no file found
-	return &Config{
-		Channels: []ChannelConfig{
-			{Name: "general", ID: "C0123456789"},
-		},
-	}, nil
+// channelIndex finds a configured channel by ID or name, returning -1 if absent
+func (c *Config) channelIndex(ref string) int {
+	for i, ch := range c.Channels {
+		if ch.ID == ref || ch.Name == ref {
+			return i
+		}
+	}
+	return -1
+}
+
+// ChannelByName finds a configured channel by name, with or without a
+// leading #
+func (c *Config) ChannelByName(name string) (ChannelConfig, bool) {
+	name = strings.TrimPrefix(name, "#")
+	for _, ch := range c.Channels {
+		if ch.Name != "" && ch.Name == name {
+			return ch, true
+		}
+	}
+	return ChannelConfig{}, false
 }
 
 // GetEnv reads required environment variables
@@ -0,0 +1,215 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// chdir switches into dir for the duration of the test
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func writeConfig(t *testing.T, path, channelID string) {
+	t.Helper()
+	data := "channels:\n  - name: test\n    id: " + channelID + "\n"
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolvePrecedence(t *testing.T) {
+	home := t.TempDir()
+	cwd := t.TempDir()
+	other := t.TempDir()
+	t.Setenv("HOME", home)
+	chdir(t, cwd)
+
+	homeFile := filepath.Join(home, DefaultFileName)
+	envFile := filepath.Join(other, "env.yaml")
+	flagFile := filepath.Join(other, "flag.yaml")
+	writeConfig(t, homeFile, "C0000000001")
+	writeConfig(t, envFile, "C0000000002")
+	writeConfig(t, flagFile, "C0000000003")
+
+	t.Setenv(EnvConfigPath, "")
+	if got, _ := Resolve(""); got != homeFile {
+		t.Errorf("home fallback: got %q, want %q", got, homeFile)
+	}
+
+	writeConfig(t, filepath.Join(cwd, DefaultFileName), "C0000000004")
+	if got, _ := Resolve(""); got != DefaultFileName {
+		t.Errorf("cwd over home: got %q, want %q", got, DefaultFileName)
+	}
+
+	t.Setenv(EnvConfigPath, envFile)
+	if got, _ := Resolve(""); got != envFile {
+		t.Errorf("env over cwd: got %q, want %q", got, envFile)
+	}
+
+	if got, _ := Resolve(flagFile); got != flagFile {
+		t.Errorf("flag over env: got %q, want %q", got, flagFile)
+	}
+
+	cfg, err := Load(flagFile)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Channels[0].ID != "C0000000003" {
+		t.Errorf("Load read %+v, want flag file", cfg.Channels)
+	}
+}
+
+func TestResolveExplicitPathMustExist(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "nope.yaml")
+
+	if _, err := Load(missing); err == nil || errors.Is(err, ErrNoConfig) {
+		t.Errorf("explicit missing path: got %v, want hard error", err)
+	}
+
+	t.Setenv(EnvConfigPath, missing)
+	_, err := Load("")
+	if err == nil || !strings.Contains(err.Error(), EnvConfigPath) {
+		t.Errorf("missing env path: got %v, want error naming %s", err, EnvConfigPath)
+	}
+}
+
+func TestResolveReportsTriedPaths(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(EnvConfigPath, "")
+	chdir(t, t.TempDir())
+
+	_, err := Resolve("")
+	if !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("got %v, want ErrNoConfig", err)
+	}
+	for _, p := range searchPaths() {
+		if !strings.Contains(err.Error(), p) {
+			t.Errorf("error %q does not mention %s", err, p)
+		}
+	}
+}
+
+func TestLoadWithoutConfigFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(EnvConfigPath, "")
+	t.Setenv(EnvChannels, "")
+	chdir(t, t.TempDir())
+
+	cfg, err := Load("")
+	if !errors.Is(err, ErrNoConfig) {
+		t.Fatalf("got %v, want ErrNoConfig", err)
+	}
+	if cfg != nil {
+		t.Errorf("expected no config, got %+v (the placeholder default channel must not leak)", cfg)
+	}
+}
+
+func TestLoadEmptyConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Channels) != 0 {
+		t.Errorf("expected no channels, got %+v", cfg.Channels)
+	}
+	if cfg.Path != path {
+		t.Errorf("Path = %q, want %q", cfg.Path, path)
+	}
+}
+
+func TestCheckChannels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	if err := os.WriteFile(path, []byte("channels: []\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if err := CheckChannels(cfg.Channels); !errors.Is(err, ErrNoChannels) {
+		t.Errorf("CheckChannels(empty config) = %v, want ErrNoChannels", err)
+	}
+
+	tests := []struct {
+		channels []ChannelConfig
+		wantErr  bool
+	}{
+		{[]ChannelConfig{{Name: "general", ID: "C0000000001"}}, false},
+		{[]ChannelConfig{{Name: "general", ID: "C0000000001"}, {Name: "example", ID: PlaceholderChannelID}}, true},
+		{[]ChannelConfig{{Name: "general"}}, true},
+	}
+	for _, tt := range tests {
+		if err := CheckChannels(tt.channels); (err != nil) != tt.wantErr {
+			t.Errorf("CheckChannels(%+v) = %v, want error %v", tt.channels, err, tt.wantErr)
+		}
+	}
+}
+
+func TestResolveGroups(t *testing.T) {
+	cfg := &Config{
+		Channels: []ChannelConfig{
+			{Name: "general", ID: "C0000000001"},
+			{Name: "incidents", ID: "C0000000002"},
+			{Name: "backend", ID: "C0000000003"},
+		},
+		Groups: map[string][]string{
+			"incident": {"incidents", "C0000000003"},
+			"team":     {"backend", "general"},
+			"broken":   {"nope"},
+			"empty":    {},
+		},
+	}
+
+	channels, err := cfg.ResolveGroups([]string{"incident", "team"})
+	if err != nil {
+		t.Fatalf("ResolveGroups: %v", err)
+	}
+	if len(channels) != 3 {
+		t.Fatalf("got %d channels, want union of 3: %+v", len(channels), channels)
+	}
+	for i, want := range []string{"general", "incidents", "backend"} {
+		if channels[i].Name != want {
+			t.Errorf("channel %d = %s, want %s (config order)", i, channels[i].Name, want)
+		}
+	}
+
+	if _, err := cfg.ResolveGroups([]string{"missing"}); err == nil {
+		t.Error("expected error for unknown group")
+	}
+	if _, err := cfg.ResolveGroups([]string{"broken"}); err == nil {
+		t.Error("expected error for group member not in channels")
+	}
+	if _, err := cfg.ResolveGroups([]string{"empty"}); err == nil {
+		t.Error("expected error for group without channels")
+	}
+}
+
+func TestChannelByName(t *testing.T) {
+	cfg := &Config{Channels: []ChannelConfig{{Name: "backend", ID: "C0000000003"}}}
+
+	for _, name := range []string{"backend", "#backend"} {
+		if ch, ok := cfg.ChannelByName(name); !ok || ch.ID != "C0000000003" {
+			t.Errorf("ChannelByName(%q) = %+v, %v; want backend", name, ch, ok)
+		}
+	}
+	if _, ok := cfg.ChannelByName("C0000000003"); ok {
+		t.Error("ChannelByName matched a channel ID")
+	}
+}
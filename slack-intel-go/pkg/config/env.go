@@ -0,0 +1,87 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Environment variables layered over the config file for containerized
+// deploys. Precedence, highest first: CLI flags (applied by each command),
+// these variables, the config file, then built-in defaults.
+const (
+	// EnvChannels replaces the channel list: "C0123456789:general,C0987654321:eng"
+	EnvChannels        = "SLACK_INTEL_CHANNELS"
+	EnvStorageProvider = "SLACK_INTEL_STORAGE_PROVIDER"
+	EnvS3Bucket        = "SLACK_INTEL_S3_BUCKET"
+	EnvS3Prefix        = "SLACK_INTEL_S3_PREFIX"
+	EnvS3Region        = "SLACK_INTEL_S3_REGION"
+	EnvS3Endpoint      = "SLACK_INTEL_S3_ENDPOINT"
+	EnvJiraServer      = "SLACK_INTEL_JIRA_SERVER"
+)
+
+// ApplyEnv overlays the SLACK_INTEL_* overrides read through getenv onto c
+// and reports whether any were set
+func (c *Config) ApplyEnv(getenv func(string) string) (bool, error) {
+	applied := false
+
+	if v := getenv(EnvChannels); v != "" {
+		channels, err := ParseChannelList(v)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", EnvChannels, err)
+		}
+		c.Channels = channels
+		applied = true
+	}
+
+	for key, field := range map[string]*string{
+		EnvStorageProvider: &c.Storage.Provider,
+		EnvS3Bucket:        &c.Storage.Bucket,
+		EnvS3Prefix:        &c.Storage.Prefix,
+		EnvS3Region:        &c.Storage.Region,
+		EnvS3Endpoint:      &c.Storage.Endpoint,
+		EnvJiraServer:      &c.Jira.Server,
+	} {
+		if v := getenv(key); v != "" {
+			*field = v
+			applied = true
+		}
+	}
+
+	return applied, nil
+}
+
+// ParseChannelList parses comma-separated id:name pairs. The name is
+// optional and defaults to channel_<id>, matching --channel.
+func ParseChannelList(s string) ([]ChannelConfig, error) {
+	var channels []ChannelConfig
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		id, name, _ := strings.Cut(part, ":")
+		id, name = strings.TrimSpace(id), strings.TrimSpace(name)
+		if id == "" {
+			return nil, fmt.Errorf("missing channel ID in %q", part)
+		}
+		if name == "" {
+			name = fmt.Sprintf("channel_%s", id)
+		}
+		channels = append(channels, ChannelConfig{Name: name, ID: id})
+	}
+
+	if len(channels) == 0 {
+		return nil, fmt.Errorf("no channels in %q", s)
+	}
+	return channels, nil
+}
+
+// loadEnvOnly builds a config purely from environment overrides, used
+// when no config file exists
+func loadEnvOnly() (*Config, bool, error) {
+	var cfg Config
+	applied, err := cfg.ApplyEnv(os.Getenv)
+	return &cfg, applied, err
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestApplyEnvOverridesFile(t *testing.T) {
+	cfg := &Config{
+		Channels: []ChannelConfig{{Name: "file", ID: "C0000000001"}},
+		Storage:  StorageConfig{Bucket: "file-bucket", Region: "us-east-1"},
+	}
+	env := map[string]string{
+		EnvChannels:   "C0000000002:general, C0000000003",
+		EnvS3Bucket:   "env-bucket",
+		EnvJiraServer: "https://jira.example.com",
+	}
+
+	applied, err := cfg.ApplyEnv(func(k string) string { return env[k] })
+	if err != nil || !applied {
+		t.Fatalf("ApplyEnv = %v, %v", applied, err)
+	}
+
+	want := []ChannelConfig{{Name: "general", ID: "C0000000002"}, {Name: "channel_C0000000003", ID: "C0000000003"}}
+	if len(cfg.Channels) != len(want) || cfg.Channels[0] != want[0] || cfg.Channels[1] != want[1] {
+		t.Errorf("Channels = %+v, want %+v", cfg.Channels, want)
+	}
+	if cfg.Storage.Bucket != "env-bucket" || cfg.Storage.Region != "us-east-1" {
+		t.Errorf("Storage = %+v, want env bucket over file region", cfg.Storage)
+	}
+	if cfg.Jira.Server != "https://jira.example.com" {
+		t.Errorf("Jira.Server = %q", cfg.Jira.Server)
+	}
+}
+
+func TestApplyEnvRejectsBadChannels(t *testing.T) {
+	cfg := &Config{}
+	if _, err := cfg.ApplyEnv(func(k string) string {
+		if k == EnvChannels {
+			return ":general"
+		}
+		return ""
+	}); err == nil {
+		t.Error("expected error for channel without ID")
+	}
+}
+
+func TestLoadFromEnvWithoutFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(EnvConfigPath, "")
+	t.Setenv(EnvChannels, "C0000000002:general")
+	chdir(t, t.TempDir())
+
+	cfg, err := Load("")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Channels) != 1 || cfg.Channels[0].ID != "C0000000002" || cfg.Path != "" {
+		t.Errorf("cfg = %+v, want env channel and no path", cfg)
+	}
+}
+
+func TestLoadAppliesEnvOverFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	writeConfig(t, path, "C0000000001")
+	t.Setenv(EnvChannels, "C0000000002:general")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Channels) != 1 || cfg.Channels[0].ID != "C0000000002" {
+		t.Errorf("Channels = %+v, want env override", cfg.Channels)
+	}
+}
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultTokenEnv is the environment variable holding the Slack token
+// unless a profile names another
+const DefaultTokenEnv = "SLACK_API_TOKEN"
+
+// EnvProfile selects a profile when --profile is not given
+const EnvProfile = "SLACK_INTEL_PROFILE"
+
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ProfileConfig is one Slack workspace under profiles:. Its settings
+// replace the top-level ones when the profile is selected: channels and
+// groups as a whole, storage and jira key by key.
+type ProfileConfig struct {
+	// TokenEnv names the environment variable holding the workspace's
+	// token; empty means SLACK_API_TOKEN. TokenCommand prints it when
+	// that variable is unset, like auth.token_command.
+	TokenEnv     string              `yaml:"token_env,omitempty"`
+	TokenCommand string              `yaml:"token_command,omitempty"`
+	Channels     []ChannelConfig     `yaml:"channels,omitempty"`
+	Groups       map[string][]string `yaml:"groups,omitempty"`
+	Storage      StorageConfig       `yaml:"storage,omitempty"`
+	Jira         JiraConfig          `yaml:"jira,omitempty"`
+}
+
+// ProfileNames returns the configured profile names, sorted
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// UseProfile overlays the named profile onto the top-level settings and
+// records it in Profile; an empty name leaves c unchanged
+func (c *Config) UseProfile(name string) error {
+	if name == "" {
+		return nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("unknown profile %q: the config defines no profiles", name)
+		}
+		return fmt.Errorf("unknown profile %q (expected %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	c.Profile = name
+	c.TokenEnv = p.TokenEnv
+	if p.TokenEnv != "" || p.TokenCommand != "" {
+		// Another workspace's token: the top-level command would fetch
+		// the wrong one
+		c.Auth.TokenCommand = p.TokenCommand
+	}
+	if len(p.Channels) > 0 {
+		c.Channels = p.Channels
+	}
+	if len(p.Groups) > 0 {
+		c.Groups = p.Groups
+	}
+	c.Storage = c.Storage.overlay(p.Storage)
+	if p.Jira.Server != "" {
+		c.Jira.Server = p.Jira.Server
+	}
+	if len(p.Jira.TicketSources) > 0 {
+		c.Jira.TicketSources = p.Jira.TicketSources
+	}
+	return nil
+}
+
+// TokenEnvName returns the environment variable holding the Slack token:
+// the selected profile's token_env, or SLACK_API_TOKEN
+func (c *Config) TokenEnvName() string {
+	if c.TokenEnv != "" {
+		return c.TokenEnv
+	}
+	return DefaultTokenEnv
+}
+
+// overlay returns s with the fields set in o replacing its own
+func (s StorageConfig) overlay(o StorageConfig) StorageConfig {
+	for _, f := range []struct{ dst, src *string }{
+		{&s.Provider, &o.Provider},
+		{&s.Bucket, &o.Bucket},
+		{&s.Prefix, &o.Prefix},
+		{&s.Region, &o.Region},
+		{&s.Profile, &o.Profile},
+		{&s.Endpoint, &o.Endpoint},
+		{&s.SSE, &o.SSE},
+		{&s.KMSKeyID, &o.KMSKeyID},
+		{&s.StorageClass, &o.StorageClass},
+		{&s.CredentialsFile, &o.CredentialsFile},
+		{&s.PartitionTimezone, &o.PartitionTimezone},
+		{&s.PartFileName, &o.PartFileName},
+	} {
+		if *f.src != "" {
+			*f.dst = *f.src
+		}
+	}
+	s.ForcePathStyle = s.ForcePathStyle || o.ForcePathStyle
+	s.DisableSSL = s.DisableSSL || o.DisableSSL
+	return s
+}
+
+// validateProfile checks a profile's token variable and channels
+func validateProfile(p ProfileConfig) error {
+	if p.TokenEnv != "" && !envNamePattern.MatchString(p.TokenEnv) {
+		return fmt.Errorf("token_env %q is not an environment variable name", p.TokenEnv)
+	}
+	seen := make(map[string]bool)
+	for _, ch := range p.Channels {
+		switch {
+		case ch.Name == "":
+			return fmt.Errorf("channel %s: missing name", ch.ID)
+		case !ValidChannelID(ch.ID):
+			return fmt.Errorf("channel %s: id %q does not look like a channel ID", ch.Name, ch.ID)
+		case seen[ch.ID]:
+			return fmt.Errorf("channel %s: duplicate channel id", ch.Name)
+		}
+		seen[ch.ID] = true
+	}
+	return nil
+}
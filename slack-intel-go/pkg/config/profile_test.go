@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const profilesYAML = `channels:
+  - name: general
+    id: C0000000001
+storage:
+  bucket: team-lake
+  prefix: slack/raw
+  region: eu-west-1
+jira:
+  server: https://corp.atlassian.net
+profiles:
+  corp: {}
+  community:
+    token_env: SLACK_COMMUNITY_TOKEN
+    channels:
+      - name: help
+        id: C0000000009
+    storage:
+      prefix: slack/community
+    jira:
+      server: https://community.atlassian.net
+`
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	if err := os.WriteFile(path, []byte(profilesYAML), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadProfile(path, "community")
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if cfg.Profile != "community" || cfg.TokenEnvName() != "SLACK_COMMUNITY_TOKEN" {
+		t.Errorf("profile/token env = %q/%q, want community/SLACK_COMMUNITY_TOKEN", cfg.Profile, cfg.TokenEnvName())
+	}
+	if len(cfg.Channels) != 1 || cfg.Channels[0].ID != "C0000000009" {
+		t.Errorf("Channels = %+v, want the profile's", cfg.Channels)
+	}
+	if cfg.Storage.Prefix != "slack/community" || cfg.Storage.Bucket != "team-lake" || cfg.Storage.Region != "eu-west-1" {
+		t.Errorf("Storage = %+v, want the profile prefix over the shared bucket", cfg.Storage)
+	}
+	if cfg.Jira.Server != "https://community.atlassian.net" {
+		t.Errorf("Jira.Server = %q, want the profile's", cfg.Jira.Server)
+	}
+
+	// An empty profile keeps the top-level settings and token variable
+	corp, err := LoadProfile(path, "corp")
+	if err != nil {
+		t.Fatalf("LoadProfile(corp): %v", err)
+	}
+	if corp.TokenEnvName() != DefaultTokenEnv || len(corp.Channels) != 1 || corp.Channels[0].ID != "C0000000001" {
+		t.Errorf("corp = %+v, want the top-level channels and SLACK_API_TOKEN", corp)
+	}
+
+	// Environment overrides still win over the profile
+	t.Setenv(EnvChannels, "C0000000002:general")
+	if cfg, err := LoadProfile(path, "community"); err != nil || cfg.Channels[0].ID != "C0000000002" {
+		t.Errorf("with %s set, channels = %+v, %v; want the env override", EnvChannels, cfg.Channels, err)
+	}
+
+	if _, err := LoadProfile(path, "nope"); err == nil || !strings.Contains(err.Error(), "community, corp") {
+		t.Errorf("unknown profile err = %v, want the known profiles listed", err)
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	cfg, err := ParseStrict([]byte(profilesYAML))
+	if err != nil {
+		t.Fatalf("ParseStrict: %v", err)
+	}
+	cfg.Channels = nil
+	cfg.Profiles["bad"] = ProfileConfig{TokenEnv: "SLACK-TOKEN", Channels: []ChannelConfig{{Name: "x", ID: "nope"}}}
+
+	failed := map[string]bool{}
+	for _, check := range cfg.Validate() {
+		if strings.HasPrefix(check.Item, "profile") || check.Item == "channels" {
+			failed[check.Item] = !check.OK()
+		}
+	}
+	want := map[string]bool{"profile bad": true, "profile community": false, "profile corp": false}
+	if len(failed) != len(want) {
+		t.Fatalf("checks = %v, want %v (no top-level channels needed with profiles)", failed, want)
+	}
+	for item, wantFailed := range want {
+		if failed[item] != wantFailed {
+			t.Errorf("%s failed = %v, want %v", item, failed[item], wantFailed)
+		}
+	}
+}
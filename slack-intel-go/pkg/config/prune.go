@@ -0,0 +1,118 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PruneChannels removes the channels with the given IDs from the config
+// file at path, along with group entries naming them by ID or name.
+// Comments and the order of everything else are kept. It returns the
+// removed channels.
+func PruneChannels(path string, ids []string) ([]ChannelConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	root := doc.Content[0]
+
+	prune := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		prune[id] = true
+	}
+
+	// refs holds the IDs and names of removed channels, for groups
+	refs := make(map[string]bool)
+	var removed []ChannelConfig
+	if channels := mappingValue(root, "channels"); channels != nil && channels.Kind == yaml.SequenceNode {
+		kept := channels.Content[:0]
+		for _, item := range channels.Content {
+			var ch ChannelConfig
+			if err := item.Decode(&ch); err == nil && prune[ch.ID] {
+				removed = append(removed, ch)
+				refs[ch.ID] = true
+				refs[ch.Name] = true
+				continue
+			}
+			kept = append(kept, item)
+		}
+		channels.Content = kept
+	}
+	if len(removed) == 0 {
+		return nil, nil
+	}
+
+	if groups := mappingValue(root, "groups"); groups != nil && groups.Kind == yaml.MappingNode {
+		for i := 1; i < len(groups.Content); i += 2 {
+			members := groups.Content[i]
+			if members.Kind != yaml.SequenceNode {
+				continue
+			}
+			kept := members.Content[:0]
+			for _, m := range members.Content {
+				if !refs[m.Value] {
+					kept = append(kept, m)
+				}
+			}
+			members.Content = kept
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", path, err)
+	}
+
+	// Write beside the original and rename so a crash never leaves half a config
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".slack-intel-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", path, err)
+	}
+
+	return removed, nil
+}
+
+// mappingValue returns the value node for key in a mapping node, or nil
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
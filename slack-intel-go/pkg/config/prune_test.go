@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPruneChannels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), DefaultFileName)
+	data := `# Channels cached every night
+channels:
+  - name: backend
+    id: C0000000001
+  - name: old-project # archived in March
+    id: C0000000002
+  - name: incidents
+    id: C0000000003
+groups:
+  eng: [backend, old-project]
+  all:
+    - C0000000001
+    - C0000000002
+    - incidents
+filters:
+  exclude_bots: true
+`
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := PruneChannels(path, []string{"C0000000002", "C0000000099"})
+	if err != nil {
+		t.Fatalf("PruneChannels: %v", err)
+	}
+	if len(removed) != 1 || removed[0].Name != "old-project" {
+		t.Errorf("removed = %+v, want old-project", removed)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(written), "# Channels cached every night") {
+		t.Errorf("comments were dropped:\n%s", written)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	cfg, err := ParseStrict(written)
+	if err != nil {
+		t.Fatalf("pruned config does not parse: %v\n%s", err, written)
+	}
+	if len(cfg.Channels) != 2 || cfg.Channels[0].Name != "backend" || cfg.Channels[1].Name != "incidents" {
+		t.Errorf("channels = %+v, want backend and incidents", cfg.Channels)
+	}
+	if got := cfg.Groups["eng"]; len(got) != 1 || got[0] != "backend" {
+		t.Errorf("groups.eng = %v, want [backend]", got)
+	}
+	if got := cfg.Groups["all"]; len(got) != 2 || got[1] != "incidents" {
+		t.Errorf("groups.all = %v, want [C0000000001 incidents]", got)
+	}
+	if !cfg.Filters.ExcludeBots {
+		t.Error("filters were lost")
+	}
+
+	// Nothing to prune leaves the file alone
+	if removed, err := PruneChannels(path, []string{"C0000000099"}); err != nil || removed != nil {
+		t.Errorf("second prune = %+v, %v; want nothing removed", removed, err)
+	}
+}
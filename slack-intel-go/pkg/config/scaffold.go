@@ -0,0 +1,83 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileName is the config file name searched for by Load
+const DefaultFileName = ".slack-intel.yaml"
+
+// Scaffold renders a commented .slack-intel.yaml for the given channels.
+// Storage and JIRA sections are emitted as commented placeholders.
+func Scaffold(channels []ChannelConfig) []byte {
+	var b bytes.Buffer
+
+	b.WriteString("# Slack Intelligence Configuration\n")
+	fmt.Fprintf(&b, "# Generated by `slack-intel config init` on %s\n", time.Now().Format("2006-01-02"))
+	b.WriteString("#\n")
+	b.WriteString("# CLI arguments (--channel) override the channels listed here.\n\n")
+
+	b.WriteString("channels:\n")
+	if len(channels) == 0 {
+		b.WriteString("  # - name: general\n")
+		b.WriteString("  #   id: " + PlaceholderChannelID + "\n")
+	}
+	for _, ch := range channels {
+		fmt.Fprintf(&b, "  - name: %s\n", yamlScalar(ch.Name))
+		fmt.Fprintf(&b, "    id: %s\n", yamlScalar(ch.ID))
+	}
+
+	b.WriteString("\n# Channel groups for `slack-intel cache --group <name>` (optional)\n")
+	b.WriteString("# groups:\n")
+	b.WriteString("#   incident: [incidents, " + PlaceholderChannelID + "]\n")
+
+	b.WriteString("\n# Command printing the Slack token when SLACK_API_TOKEN is unset (optional)\n")
+	b.WriteString("# auth:\n")
+	b.WriteString("#   token_command: op read op://infra/slack/token\n")
+
+	b.WriteString("\n# Message filters applied before caching (optional)\n")
+	b.WriteString("# filters:\n")
+	b.WriteString("#   exclude_bots: true\n")
+
+	b.WriteString("\n# Bucket storage for syncing the Parquet cache (optional)\n")
+	b.WriteString("storage:\n")
+	b.WriteString("  # provider: s3  # or gcs (bucket, prefix and credentials_file only)\n")
+	b.WriteString("  # bucket: my-data-lake\n")
+	b.WriteString("  # prefix: slack/raw\n")
+	b.WriteString("  # region: us-east-1\n")
+	b.WriteString("  # profile: default\n")
+	b.WriteString("  # endpoint: minio.internal:9000  # S3-compatible server such as MinIO\n")
+	b.WriteString("  # force_path_style: true\n")
+	b.WriteString("  # disable_ssl: true\n")
+	b.WriteString("  # sse: aws:kms  # or AES256\n")
+	b.WriteString("  # kms_key_id: alias/data-lake\n")
+	b.WriteString("  # storage_class: STANDARD_IA\n")
+	b.WriteString("  # credentials_file: service-account.json  # gcs; default: Application Default Credentials\n")
+	b.WriteString("  # partition_timezone: Europe/Berlin  # zone dt= partitions are cut in; default: UTC\n")
+	b.WriteString("  # part_filename: part-0.snappy.parquet  # each partition's Parquet file; default: data.parquet\n")
+
+	b.WriteString("\n# Zone and working day behind the weekday, hour_of_day and is_business_hours columns (optional)\n")
+	b.WriteString("# activity:\n")
+	b.WriteString("#   timezone: Europe/Berlin  # default: UTC\n")
+	b.WriteString("#   business_hours: 09:00-17:00  # Monday to Friday\n")
+
+	b.WriteString("\n# JIRA enrichment (optional, credentials come from JIRA_API_TOKEN / JIRA_USER_NAME)\n")
+	b.WriteString("jira:\n")
+	b.WriteString("  # server: https://your-domain.atlassian.net\n")
+
+	return b.Bytes()
+}
+
+// yamlScalar quotes a value only when YAML requires it
+func yamlScalar(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("%q", s)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
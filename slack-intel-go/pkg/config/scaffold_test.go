@@ -0,0 +1,41 @@
+package config
+
+import (
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestScaffoldRoundTrip(t *testing.T) {
+	channels := []ChannelConfig{
+		{Name: "general", ID: "C0123456789"},
+		{Name: "yes", ID: "C9876543210"},
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(Scaffold(channels), &cfg); err != nil {
+		t.Fatalf("scaffold is not valid YAML: %v", err)
+	}
+
+	if len(cfg.Channels) != len(channels) {
+		t.Fatalf("got %d channels, want %d", len(cfg.Channels), len(channels))
+	}
+	for i, ch := range channels {
+		if cfg.Channels[i] != ch {
+			t.Errorf("channel %d = %+v, want %+v", i, cfg.Channels[i], ch)
+		}
+	}
+	if cfg.Storage.Bucket != "" || cfg.Jira.Server != "" {
+		t.Errorf("placeholders should stay commented out, got %+v %+v", cfg.Storage, cfg.Jira)
+	}
+}
+
+func TestScaffoldWithoutChannels(t *testing.T) {
+	var cfg Config
+	if err := yaml.Unmarshal(Scaffold(nil), &cfg); err != nil {
+		t.Fatalf("scaffold is not valid YAML: %v", err)
+	}
+	if len(cfg.Channels) != 0 {
+		t.Errorf("expected no channels, got %+v", cfg.Channels)
+	}
+}
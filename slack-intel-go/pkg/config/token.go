@@ -0,0 +1,45 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// TokenCommandTimeout bounds a token_command, long enough for a password
+// manager's unlock prompt
+const TokenCommandTimeout = 2 * time.Minute
+
+// RunTokenCommand runs a token_command with the system shell and returns
+// its stdout with surrounding whitespace trimmed. Stdin stays attached so
+// the command can prompt; a failure carries its stderr.
+func RunTokenCommand(ctx context.Context, command string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, TokenCommandTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &stdout, &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("token_command %q failed: %w: %s", command, err, msg)
+		}
+		return "", fmt.Errorf("token_command %q failed: %w", command, err)
+	}
+	token := strings.TrimSpace(stdout.String())
+	if token == "" {
+		return "", fmt.Errorf("token_command %q printed no token", command)
+	}
+	return token, nil
+}
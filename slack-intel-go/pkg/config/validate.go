@@ -0,0 +1,285 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+var (
+	channelIDPattern = regexp.MustCompile(`^[CGD][A-Z0-9]{8,}$`)
+	bucketPattern    = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+	// GCS also allows underscores
+	gcsBucketPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{1,61}[a-z0-9]$`)
+	regionPattern    = regexp.MustCompile(`^[a-z]{2}(-gov|-iso[a-z]*)?-[a-z]+-\d+$`)
+)
+
+// Check is the outcome of validating a single config item
+type Check struct {
+	Item string
+	Err  error
+}
+
+// OK reports whether the check passed
+func (c Check) OK() bool {
+	return c.Err == nil
+}
+
+// ValidChannelID reports whether id looks like a Slack conversation ID
+func ValidChannelID(id string) bool {
+	return channelIDPattern.MatchString(id)
+}
+
+// Validate runs offline checks over channels, storage and jira settings
+func (c *Config) Validate() []Check {
+	var checks []Check
+
+	if len(c.Channels) == 0 && len(c.Profiles) == 0 {
+		checks = append(checks, Check{Item: "channels", Err: errors.New("no channels configured")})
+	}
+
+	seen := make(map[string]bool)
+	for _, ch := range c.Channels {
+		item := fmt.Sprintf("channel %s (%s)", ch.Name, ch.ID)
+		var err error
+		switch {
+		case ch.Name == "":
+			err = errors.New("missing name")
+		case !ValidChannelID(ch.ID):
+			err = fmt.Errorf("id %q does not look like a channel ID (expected ^[CGD][A-Z0-9]{8,}$)", ch.ID)
+		case seen[ch.ID]:
+			err = errors.New("duplicate channel id")
+		case ch.RateLimit < 0:
+			err = fmt.Errorf("rate_limit %g must not be negative", ch.RateLimit)
+		}
+		seen[ch.ID] = true
+		checks = append(checks, Check{Item: item, Err: err})
+	}
+
+	groupNames := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		_, err := c.ResolveGroups([]string{name})
+		checks = append(checks, Check{Item: fmt.Sprintf("group %s", name), Err: err})
+	}
+
+	for _, name := range c.ProfileNames() {
+		checks = append(checks, Check{Item: fmt.Sprintf("profile %s", name), Err: validateProfile(c.Profiles[name])})
+	}
+
+	// partition_timezone and part_filename apply without a bucket too
+	if c.Storage.PartitionTimezone != "" {
+		_, err := c.Storage.PartitionLocation()
+		checks = append(checks, Check{Item: "storage.partition_timezone", Err: err})
+	}
+	if c.Storage.PartFileName != "" {
+		checks = append(checks, Check{Item: "storage.part_filename", Err: partFileNameError(c.Storage.PartFileName)})
+	}
+	bucket := c.Storage
+	bucket.PartitionTimezone = ""
+	bucket.PartFileName = ""
+	if bucket != (StorageConfig{}) {
+		checks = append(checks, Check{Item: "storage", Err: bucket.validate()})
+	}
+
+	if c.Activity != (ActivityConfig{}) {
+		_, err := c.Activity.Location()
+		if err == nil {
+			_, _, err = c.Activity.Hours()
+		}
+		checks = append(checks, Check{Item: "activity", Err: err})
+	}
+
+	if c.Jira.Server != "" {
+		checks = append(checks, Check{Item: "jira.server", Err: validateServerURL(c.Jira.Server)})
+	}
+	if len(c.Jira.TicketSources) > 0 {
+		checks = append(checks, Check{Item: "jira.ticket_sources", Err: ticketSourcesError(c.Jira.TicketSources)})
+	}
+
+	return checks
+}
+
+func (s StorageConfig) validate() error {
+	switch s.StorageProvider() {
+	case "s3":
+		if s.CredentialsFile != "" {
+			return errors.New("credentials_file is only used with provider: gcs")
+		}
+	case "gcs":
+		return s.validateGCS()
+	default:
+		return fmt.Errorf("invalid provider %q (expected s3 or gcs)", s.Provider)
+	}
+	if err := s.validateLocation(); err != nil {
+		return err
+	}
+	return s.validateUploads()
+}
+
+// validateLocation checks the bucket, region and endpoint
+func (s StorageConfig) validateLocation() error {
+	switch {
+	case s.Bucket == "" && s.Endpoint != "":
+		return errors.New("endpoint set without bucket")
+	case s.Bucket == "" && s.Region != "":
+		return errors.New("region set without bucket")
+	case s.Bucket == "":
+		return errors.New("storage options set without bucket")
+	case !bucketPattern.MatchString(s.Bucket):
+		return fmt.Errorf("invalid bucket name %q", s.Bucket)
+	case s.Endpoint != "":
+		// S3-compatible servers name their regions freely
+		return s.validateEndpoint()
+	case s.Region == "":
+		return errors.New("bucket set without region")
+	case !regionPattern.MatchString(s.Region):
+		return fmt.Errorf("invalid region %q", s.Region)
+	}
+	return nil
+}
+
+// validateGCS checks a gcs section, which only uses the bucket, prefix and
+// credentials file
+func (s StorageConfig) validateGCS() error {
+	switch {
+	case s.Bucket == "":
+		return errors.New("provider gcs set without bucket")
+	case !gcsBucketPattern.MatchString(s.Bucket):
+		return fmt.Errorf("invalid bucket name %q", s.Bucket)
+	}
+	s3Options := []struct {
+		name string
+		set  bool
+	}{
+		{"region", s.Region != ""},
+		{"profile", s.Profile != ""},
+		{"endpoint", s.Endpoint != ""},
+		{"force_path_style", s.ForcePathStyle},
+		{"disable_ssl", s.DisableSSL},
+		{"sse", s.SSE != ""},
+		{"kms_key_id", s.KMSKeyID != ""},
+		{"storage_class", s.StorageClass != ""},
+	}
+	for _, option := range s3Options {
+		if option.set {
+			return fmt.Errorf("%s is an S3 option and is not used with provider: gcs", option.name)
+		}
+	}
+	return nil
+}
+
+// storageClasses are the S3 storage classes objects can be read back from
+// without a restore, so the cache can still open them
+var storageClasses = []string{
+	"STANDARD", "REDUCED_REDUNDANCY", "STANDARD_IA", "ONEZONE_IA",
+	"INTELLIGENT_TIERING", "GLACIER_IR", "OUTPOSTS", "EXPRESS_ONEZONE",
+}
+
+// validateUploads checks the encryption and storage class set on uploads
+func (s StorageConfig) validateUploads() error {
+	switch s.SSE {
+	case "", "AES256", "aws:kms":
+	default:
+		return fmt.Errorf("invalid sse %q (expected aws:kms or AES256)", s.SSE)
+	}
+	if s.KMSKeyID != "" && s.SSE != "aws:kms" {
+		return errors.New("kms_key_id set without sse: aws:kms")
+	}
+	switch {
+	case s.StorageClass == "":
+	case s.StorageClass == "GLACIER" || s.StorageClass == "DEEP_ARCHIVE":
+		return fmt.Errorf("storage_class %s needs a restore before objects can be read back", s.StorageClass)
+	case !slices.Contains(storageClasses, s.StorageClass):
+		return fmt.Errorf("invalid storage_class %q (expected one of %s)", s.StorageClass, strings.Join(storageClasses, ", "))
+	}
+	return nil
+}
+
+// validateEndpoint accepts a host[:port] or an http(s) URL without a path
+func (s StorageConfig) validateEndpoint() error {
+	u, err := s.endpointURL()
+	if err != nil {
+		return err
+	}
+	if err := validateServerURL(u.String()); err != nil {
+		return fmt.Errorf("endpoint: %w", err)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return fmt.Errorf("endpoint %q must not have a path", s.Endpoint)
+	}
+	if s.DisableSSL && u.Scheme == "https" {
+		return fmt.Errorf("disable_ssl conflicts with the https endpoint %q", s.Endpoint)
+	}
+	return nil
+}
+
+// endpointURL parses Endpoint, defaulting the scheme to https (http with
+// DisableSSL)
+func (s StorageConfig) endpointURL() (*url.URL, error) {
+	raw := s.Endpoint
+	if !strings.Contains(raw, "://") {
+		scheme := "https"
+		if s.DisableSSL {
+			scheme = "http"
+		}
+		raw = scheme + "://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint: %w", err)
+	}
+	return u, nil
+}
+
+// S3Endpoint returns the hostname S3 requests go to: the custom endpoint's
+// host, or the bucket's virtual-hosted AWS hostname (the regional one with
+// ForcePathStyle)
+func (s StorageConfig) S3Endpoint() string {
+	if s.Endpoint != "" {
+		if u, err := s.endpointURL(); err == nil {
+			return u.Hostname()
+		}
+		return s.Endpoint
+	}
+	if s.ForcePathStyle {
+		return fmt.Sprintf("s3.%s.amazonaws.com", s.Region)
+	}
+	return fmt.Sprintf("%s.s3.%s.amazonaws.com", s.Bucket, s.Region)
+}
+
+// ticketSourcesError checks jira.ticket_sources as the client will parse it
+func ticketSourcesError(sources []string) error {
+	_, err := slack.ParseTicketSources(sources)
+	return err
+}
+
+// partFileNameError checks part_filename as the cache will parse it
+func partFileNameError(name string) error {
+	_, err := cache.ParsePartFileName(name)
+	return err
+}
+
+func validateServerURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("URL %q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL %q has no host", raw)
+	}
+	return nil
+}
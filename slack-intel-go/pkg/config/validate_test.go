@@ -0,0 +1,177 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStrictRejectsUnknownKeys(t *testing.T) {
+	_, err := ParseStrict([]byte("channels:\n  - name: general\n    id: C0123456789\nchanels: []\n"))
+	if err == nil || !strings.Contains(err.Error(), "chanels") {
+		t.Fatalf("expected unknown key error, got %v", err)
+	}
+}
+
+func TestParseStrictAcceptsSharedSections(t *testing.T) {
+	data := `
+organization:
+  name: Acme
+channels:
+  - name: general
+    id: C0123456789
+    description: announcements
+    signal_type: high
+`
+	cfg, err := ParseStrict([]byte(data))
+	if err != nil {
+		t.Fatalf("ParseStrict: %v", err)
+	}
+	if len(cfg.Channels) != 1 || cfg.Channels[0].SignalType != "high" {
+		t.Errorf("unexpected channels %+v", cfg.Channels)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := &Config{
+		Channels: []ChannelConfig{
+			{Name: "general", ID: "C0123456789"},
+			{Name: "typo", ID: "c012345"},
+			{Name: "dup", ID: "C0123456789"},
+			{Name: "shared", ID: "C0000000002", RateLimit: -1},
+		},
+		Storage: StorageConfig{Bucket: "my-lake", Region: "us-east-1"},
+		Jira:    JiraConfig{Server: "your-domain.atlassian.net", TicketSources: []string{"text", "fields"}},
+	}
+
+	failed := map[string]bool{}
+	for _, check := range cfg.Validate() {
+		failed[check.Item] = !check.OK()
+	}
+
+	want := map[string]bool{
+		"channel general (C0123456789)": false,
+		"channel typo (c012345)":        true,
+		"channel dup (C0123456789)":     true,
+		"channel shared (C0000000002)":  true,
+		"storage":                       false,
+		"jira.server":                   true,
+		"jira.ticket_sources":           true,
+	}
+	for item, wantFailed := range want {
+		got, ok := failed[item]
+		if !ok {
+			t.Errorf("missing check %q", item)
+			continue
+		}
+		if got != wantFailed {
+			t.Errorf("check %q failed=%v, want %v", item, got, wantFailed)
+		}
+	}
+}
+
+func TestValidateStorage(t *testing.T) {
+	tests := []struct {
+		storage StorageConfig
+		wantErr bool
+	}{
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1"}, false},
+		{StorageConfig{Bucket: "my-lake"}, true},
+		{StorageConfig{Region: "eu-west-1"}, true},
+		{StorageConfig{Bucket: "My_Lake", Region: "eu-west-1"}, true},
+		{StorageConfig{Bucket: "my-lake", Region: "Europe"}, true},
+		{StorageConfig{Bucket: "my-lake", Endpoint: "minio.internal:9000", ForcePathStyle: true, DisableSSL: true}, false},
+		{StorageConfig{Bucket: "my-lake", Region: "minio", Endpoint: "https://minio.internal"}, false},
+		{StorageConfig{Bucket: "my-lake", Endpoint: "https://minio.internal", DisableSSL: true}, true},
+		{StorageConfig{Bucket: "my-lake", Endpoint: "ftp://minio.internal"}, true},
+		{StorageConfig{Bucket: "my-lake", Endpoint: "minio.internal/bucket"}, true},
+		{StorageConfig{Endpoint: "minio.internal:9000"}, true},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", SSE: "aws:kms", KMSKeyID: "alias/lake", StorageClass: "STANDARD_IA"}, false},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", SSE: "AES256"}, false},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", SSE: "AES256", KMSKeyID: "alias/lake"}, true},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", KMSKeyID: "alias/lake"}, true},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", SSE: "kms"}, true},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", StorageClass: "standard_ia"}, true},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", StorageClass: "DEEP_ARCHIVE"}, true},
+		{StorageConfig{SSE: "aws:kms"}, true},
+		{StorageConfig{Provider: "gcs", Bucket: "team_lake", Prefix: "slack/raw"}, false},
+		{StorageConfig{Provider: "gcs", Bucket: "team-lake", CredentialsFile: "sa.json"}, false},
+		{StorageConfig{Provider: "gcs", Prefix: "slack/raw"}, true},
+		{StorageConfig{Provider: "gcs", Bucket: "team-lake", Region: "us-east-1"}, true},
+		{StorageConfig{Provider: "gcs", Bucket: "team-lake", SSE: "aws:kms"}, true},
+		{StorageConfig{Bucket: "my-lake", Region: "eu-west-1", CredentialsFile: "sa.json"}, true},
+		{StorageConfig{Provider: "azure", Bucket: "my-lake"}, true},
+	}
+
+	for _, tt := range tests {
+		if err := tt.storage.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("validate(%+v) = %v, wantErr %v", tt.storage, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidatePartitionTimezone(t *testing.T) {
+	tests := []struct {
+		storage StorageConfig
+		want    map[string]bool // check item to failed
+	}{
+		{StorageConfig{PartitionTimezone: "Europe/Berlin"}, map[string]bool{"storage.partition_timezone": false}},
+		{StorageConfig{PartitionTimezone: "Mars/Olympus"}, map[string]bool{"storage.partition_timezone": true}},
+		{StorageConfig{PartitionTimezone: "Local"}, map[string]bool{"storage.partition_timezone": true}},
+		{StorageConfig{PartitionTimezone: "UTC", Bucket: "my-lake"}, map[string]bool{"storage.partition_timezone": false, "storage": true}},
+		{StorageConfig{PartFileName: "part-0.snappy.parquet"}, map[string]bool{"storage.part_filename": false}},
+		{StorageConfig{PartFileName: "data.csv"}, map[string]bool{"storage.part_filename": true}},
+		{StorageConfig{PartFileName: "out/data.parquet"}, map[string]bool{"storage.part_filename": true}},
+	}
+
+	for _, tt := range tests {
+		failed := map[string]bool{}
+		for _, check := range (&Config{Storage: tt.storage}).Validate() {
+			if strings.HasPrefix(check.Item, "storage") {
+				failed[check.Item] = !check.OK()
+			}
+		}
+		if len(failed) != len(tt.want) {
+			t.Errorf("checks for %+v = %v, want %v", tt.storage, failed, tt.want)
+			continue
+		}
+		for item, wantFailed := range tt.want {
+			if got, ok := failed[item]; !ok || got != wantFailed {
+				t.Errorf("%+v: check %q failed=%v (present %v), want %v", tt.storage, item, got, ok, wantFailed)
+			}
+		}
+	}
+}
+
+func TestActivityConfig(t *testing.T) {
+	start, end, err := (ActivityConfig{}).Hours()
+	if err != nil || start != 9*time.Hour || end != 17*time.Hour {
+		t.Errorf("default Hours() = %v, %v, %v; want 09:00-17:00", start, end, err)
+	}
+	start, end, err = (ActivityConfig{BusinessHours: "08:30-24:00"}).Hours()
+	if err != nil || start != 8*time.Hour+30*time.Minute || end != 24*time.Hour {
+		t.Errorf("Hours(08:30-24:00) = %v, %v, %v", start, end, err)
+	}
+
+	tests := []struct {
+		activity   ActivityConfig
+		wantFailed bool
+	}{
+		{ActivityConfig{Timezone: "America/New_York", BusinessHours: "09:00-18:00"}, false},
+		{ActivityConfig{BusinessHours: "17:00-09:00"}, true},
+		{ActivityConfig{BusinessHours: "9-5"}, true},
+		{ActivityConfig{BusinessHours: "09:00-25:00"}, true},
+		{ActivityConfig{Timezone: "Local"}, true},
+	}
+	for _, tt := range tests {
+		var failed, found bool
+		for _, check := range (&Config{Activity: tt.activity}).Validate() {
+			if check.Item == "activity" {
+				failed, found = !check.OK(), true
+			}
+		}
+		if !found || failed != tt.wantFailed {
+			t.Errorf("activity check for %+v: failed=%v (present %v), want %v", tt.activity, failed, found, tt.wantFailed)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package slackintel_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/pkg/slackintel"
+)
+
+// Fetch a day of #general and persist it as daily Parquet partitions
+func Example() {
+	ctx := context.Background()
+
+	fetcher := slackintel.NewFetcher(os.Getenv("SLACK_API_TOKEN"),
+		slackintel.WithWindow(24*time.Hour),
+		slackintel.WithExcludeBots(true))
+	if _, err := fetcher.Auth(ctx); err != nil {
+		log.Fatal(err)
+	}
+
+	channel := &slackintel.Channel{Name: "general", ID: "C0123456789"}
+	result, err := fetcher.Fetch(ctx, channel.ID)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store := slackintel.NewParquetStore("cache/raw")
+	if _, err := slackintel.SaveChannel(store, channel, result.Messages, slackintel.GranularityDay); err != nil {
+		log.Fatal(err)
+	}
+	if _, err := store.SaveUsers(fetcher.Users()); err != nil {
+		log.Fatal(err)
+	}
+}
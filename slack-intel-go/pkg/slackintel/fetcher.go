@@ -0,0 +1,330 @@
+package slackintel
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/mrkdwn"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/redact"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+// DefaultWindow is how far back Fetch looks unless WithWindow is given
+const DefaultWindow = 48 * time.Hour
+
+// Fetcher reads channel history from Slack, applying the configured filters
+type Fetcher struct {
+	client      *slack.Client
+	window      time.Duration
+	excludeBots bool
+	redact      bool
+	normalize   TextMode
+}
+
+// Option configures a Fetcher
+type Option func(*fetcherConfig)
+
+type fetcherConfig struct {
+	window      time.Duration
+	excludeBots bool
+	redact      bool
+	normalize   TextMode
+	client      []slack.Option
+}
+
+// WithWindow sets how far back Fetch looks
+func WithWindow(d time.Duration) Option {
+	return func(c *fetcherConfig) {
+		c.window = d
+	}
+}
+
+// WithExcludeBots drops bot messages, keeping bot thread parents that have
+// human replies
+func WithExcludeBots(exclude bool) Option {
+	return func(c *fetcherConfig) {
+		c.excludeBots = exclude
+	}
+}
+
+// WithRedaction strips emails, secrets and reaction user IDs from fetched
+// messages and users
+func WithRedaction(redact bool) Option {
+	return func(c *fetcherConfig) {
+		c.redact = redact
+	}
+}
+
+// WithTextNormalization fills each message's CleanText from its mrkdwn
+// Text, handling formatting markers as mode says. Text is left as is. The
+// zero mode disables normalization.
+func WithTextNormalization(mode TextMode) Option {
+	return func(c *fetcherConfig) {
+		c.normalize = mode
+	}
+}
+
+// WithThreadMode selects which parts of a conversation are fetched
+func WithThreadMode(mode ThreadMode) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithThreadMode(mode))
+	}
+}
+
+// WithMinReplyCount fetches replies only for threads with at least n of
+// them; smaller threads keep just the parent with its reply count
+func WithMinReplyCount(n int) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithMinReplyCount(n))
+	}
+}
+
+// WithWorkers caps concurrent thread-reply and user-info requests
+func WithWorkers(n int) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithWorkers(n))
+	}
+}
+
+// WithBulkUserThreshold sets how many uncached users in one batch make the
+// fetcher load the whole roster with users.list instead of calling
+// users.info per user; values below 1 disable the roster
+func WithBulkUserThreshold(n int) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithBulkUserThreshold(n))
+	}
+}
+
+// WithUserTTL makes SeedUsers reuse only users cached less than ttl ago;
+// older ones are looked up again when a fetch needs them. 0 reuses every
+// seeded user.
+func WithUserTTL(ttl time.Duration) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithUserTTL(ttl))
+	}
+}
+
+// WithChannelRateLimits gives channels their own limit in requests per
+// second, e.g. shared channels with a tighter quota; other channels use
+// the global limiter
+func WithChannelRateLimits(limits map[string]float64) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithChannelRateLimits(limits))
+	}
+}
+
+// WithRawPayloads keeps each message's original API payload in Message.Raw
+// for ParquetStore.SetRawPayloads to store. Redaction drops it.
+func WithRawPayloads(keep bool) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithRawPayloads(keep))
+	}
+}
+
+// WithMaxMessages caps the timeline messages one fetch reads at n, keeping
+// the newest; a capped fetch is marked FetchResult.Capped. Values below 1
+// disable the cap.
+func WithMaxMessages(n int) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithMaxMessages(n))
+	}
+}
+
+// WithMaxAPICalls limits the Fetcher to n Web API requests in total; once
+// they are spent every call fails with ErrAPIBudget. Values below 1 disable
+// the limit.
+func WithMaxAPICalls(n int64) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithMaxAPICalls(n))
+	}
+}
+
+// WithSkipUsers skips user lookups: messages keep their user ID but no
+// user info, and no users.info or users.list calls are made
+func WithSkipUsers(skip bool) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithSkipUsers(skip))
+	}
+}
+
+// WithStaticRateLimit ignores the rate-limit headers of Slack responses;
+// by default a method whose headers report a nearly spent budget, or a
+// 429 with Retry-After, is slowed to stay under the cap, while calls
+// always wait for the static limiters
+func WithStaticRateLimit(static bool) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithStaticRateLimit(static))
+	}
+}
+
+// WithFullReactions refetches with reactions.get the reactions whose users
+// list the history payload truncated (Slack lists about 50 users per
+// reaction); FetchResult.ReactionsFetched counts the messages refetched.
+// The token needs the reactions:read scope.
+func WithFullReactions(full bool) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithFullReactions(full))
+	}
+}
+
+// WithTicketSources sets the parts of a message (text, attachments,
+// blocks) JIRA tickets are extracted from; all of them by default
+func WithTicketSources(s TicketSources) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithTicketSources(s))
+	}
+}
+
+// WithLogger sets the logger for warnings and per-call debug output
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithLogger(logger))
+	}
+}
+
+// WithProgress registers a callback for per-channel fetch progress
+func WithProgress(fn func(channelID string, p Progress)) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithProgress(fn))
+	}
+}
+
+// WithAPI replaces the Slack Web API client (e.g. with a fake in tests)
+func WithAPI(api SlackAPI) Option {
+	return func(c *fetcherConfig) {
+		c.client = append(c.client, slack.WithAPI(api))
+	}
+}
+
+// NewFetcher creates a Fetcher for a bot (xoxb-) or user (xoxp-) token
+func NewFetcher(token string, opts ...Option) *Fetcher {
+	cfg := fetcherConfig{window: DefaultWindow}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return &Fetcher{
+		client:      slack.NewClient(token, cfg.client...),
+		window:      cfg.window,
+		excludeBots: cfg.excludeBots,
+		redact:      cfg.redact,
+		normalize:   cfg.normalize,
+	}
+}
+
+// FetchResult is the outcome of fetching one channel
+type FetchResult struct {
+	Messages     []*Message
+	BotsExcluded int
+	// Pages counts history responses read; Truncated is set when Slack
+	// reported more history without a cursor to fetch it
+	Pages     int
+	Truncated bool
+	// Capped is set when the fetch stopped at the WithMaxMessages cap
+	Capped bool
+	// ThreadsFailed are the thread_ts of threads whose replies could not
+	// be fetched, even after a retry
+	ThreadsFailed []string
+	// Timeline and ThreadReplies split Messages into timeline messages and
+	// thread replies, after the filters
+	Timeline, ThreadReplies int
+	// TimelineSkipped counts timeline messages left out by
+	// ThreadModeThreadsOnly
+	TimelineSkipped int
+	// ReactionsFetched counts messages whose truncated reactions were
+	// refetched with reactions.get (WithFullReactions)
+	ReactionsFetched int
+	// APICalls counts the Web API requests the fetch made
+	APICalls int
+}
+
+// Auth validates the token and detects whether it is a bot or user token
+func (f *Fetcher) Auth(ctx context.Context) (*AuthInfo, error) {
+	return f.client.ValidateAuth(ctx)
+}
+
+// CanFetch reports whether the token may read the channel (bot tokens
+// cannot read DMs). Call Auth first so the token type is known.
+func (f *Fetcher) CanFetch(channelID string) error {
+	return f.client.CanFetch(channelID)
+}
+
+// ChannelInfo reads the channel's name, topic, purpose, member count and
+// flags from conversations.info
+func (f *Fetcher) ChannelInfo(ctx context.Context, channelID string) (*Channel, error) {
+	return f.client.GetChannelInfo(ctx, channelID)
+}
+
+// PostMessage posts text to a channel with chat.postMessage (the token
+// needs chat:write) and returns the message's timestamp
+func (f *Fetcher) PostMessage(ctx context.Context, channelID, text string) (string, error) {
+	return f.client.PostMessage(ctx, channelID, text)
+}
+
+// ListEmoji returns the workspace's custom emoji from emoji.list
+func (f *Fetcher) ListEmoji(ctx context.Context) ([]*Emoji, error) {
+	return f.client.ListEmoji(ctx)
+}
+
+// Fetch returns the channel's messages from the configured window up to now
+func (f *Fetcher) Fetch(ctx context.Context, channelID string) (*FetchResult, error) {
+	until := time.Now()
+	return f.FetchRange(ctx, channelID, until.Add(-f.window), until)
+}
+
+// FetchRange returns the channel's messages posted between since and until,
+// with thread replies, user info and the configured filters applied
+func (f *Fetcher) FetchRange(ctx context.Context, channelID string, since, until time.Time) (*FetchResult, error) {
+	history, err := f.client.GetHistory(ctx, channelID, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &FetchResult{
+		Messages:         history.Messages,
+		Pages:            history.Pages,
+		Truncated:        history.Truncated,
+		Capped:           history.Capped,
+		ThreadsFailed:    history.ThreadsFailed,
+		TimelineSkipped:  history.TimelineSkipped,
+		ReactionsFetched: history.ReactionsFetched,
+		APICalls:         history.APICalls,
+	}
+	if f.excludeBots {
+		result.Messages, result.BotsExcluded = models.ExcludeBots(result.Messages)
+	}
+	for _, msg := range result.Messages {
+		if msg.IsThreadReply() {
+			result.ThreadReplies++
+		}
+	}
+	result.Timeline = len(result.Messages) - result.ThreadReplies
+	if f.redact {
+		result.Messages = redact.Messages(result.Messages)
+	}
+	// After redaction, so clean_text never holds what text had masked
+	if f.normalize != "" {
+		for _, msg := range result.Messages {
+			msg.CleanText = mrkdwn.Normalize(msg.Text, f.normalize)
+		}
+	}
+
+	return result, nil
+}
+
+// SeedUsers preloads known users (e.g. from ParquetStore.LoadUsers) so
+// fetches only look up users missing from them, or past the WithUserTTL
+func (f *Fetcher) SeedUsers(users map[string]*User) {
+	f.client.SeedUsers(users)
+}
+
+// Users returns every user seen so far, redacted if WithRedaction is set
+func (f *Fetcher) Users() map[string]*User {
+	users := f.client.GetUserCache()
+	if f.redact {
+		users = redact.Users(users)
+	}
+	return users
+}
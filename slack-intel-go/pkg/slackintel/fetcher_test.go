@@ -0,0 +1,45 @@
+package slackintel
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack/fakeslack"
+)
+
+func TestFetchAndSaveChannel(t *testing.T) {
+	api, err := fakeslack.Load("../../internal/slack/fakeslack/testdata/workspace.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	fetcher := NewFetcher("xoxb-test", WithAPI(api), WithExcludeBots(true), WithRedaction(true))
+	if _, err := fetcher.Auth(context.Background()); err != nil {
+		t.Fatalf("Auth: %v", err)
+	}
+
+	until := time.Unix(1700001000, 0)
+	result, err := fetcher.FetchRange(context.Background(), "C0000000001", until.Add(-time.Hour), until)
+	if err != nil {
+		t.Fatalf("FetchRange: %v", err)
+	}
+	if len(result.Messages) != 4 || result.BotsExcluded != 1 {
+		t.Errorf("got %d messages, %d bots excluded; want 4 and 1", len(result.Messages), result.BotsExcluded)
+	}
+	for _, u := range fetcher.Users() {
+		if u.Email != "" {
+			t.Errorf("user %s email not redacted", u.ID)
+		}
+	}
+
+	store := NewParquetStore(filepath.Join(t.TempDir(), "raw"))
+	paths, err := SaveChannel(store, &Channel{Name: "incidents", ID: "C0000000001"}, result.Messages, GranularityMonth)
+	if err != nil {
+		t.Fatalf("SaveChannel: %v", err)
+	}
+	if len(paths) != 1 {
+		t.Errorf("wrote %d partitions, want 1", len(paths))
+	}
+}
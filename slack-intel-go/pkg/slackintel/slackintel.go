@@ -0,0 +1,80 @@
+// Package slackintel is the embeddable API behind the slack-intel CLI: a
+// Fetcher that reads channel history from Slack and a Store that persists it
+// as partitioned Parquet.
+package slackintel
+
+import (
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/mrkdwn"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/slack"
+)
+
+// Models shared with the CLI and the Parquet files
+type (
+	Message      = models.SlackMessage
+	User         = models.SlackUser
+	Channel      = models.SlackChannel
+	Reaction     = models.SlackReaction
+	File         = models.SlackFile
+	UserStats    = models.UserStats
+	JiraMentions = models.JiraMentions
+	Emoji        = models.SlackEmoji
+	EmojiMap     = models.EmojiMap
+)
+
+// Slack client types
+type (
+	AuthInfo   = slack.AuthInfo
+	TokenType  = slack.TokenType
+	ThreadMode = slack.ThreadMode
+	Progress   = slack.Progress
+	SlackAPI   = slack.SlackAPI
+
+	TicketSources = slack.TicketSources
+)
+
+const (
+	ThreadModeAll         = slack.ThreadModeAll
+	ThreadModeTopLevel    = slack.ThreadModeTopLevel
+	ThreadModeThreadsOnly = slack.ThreadModeThreadsOnly
+)
+
+// AllTicketSources extracts JIRA tickets from text, attachments and blocks
+var AllTicketSources = slack.AllTicketSources
+
+// ErrAPIBudget is returned by fetches made after the WithMaxAPICalls
+// budget is spent
+var ErrAPIBudget = slack.ErrAPIBudget
+
+// Granularity controls how messages are grouped into dt= partitions
+type Granularity = cache.Granularity
+
+const (
+	GranularityHour  = cache.GranularityHour
+	GranularityDay   = cache.GranularityDay
+	GranularityMonth = cache.GranularityMonth
+)
+
+// ActivityHours sets the zone and working day of the derived weekday and
+// business-hours columns (ParquetStore.SetActivityHours)
+type ActivityHours = cache.ActivityHours
+
+// DedupStrategy picks which copy of a message a partition keeps when
+// overlapping writes store it twice (ParquetStore.SetDedupStrategy)
+type DedupStrategy = cache.DedupStrategy
+
+const (
+	DedupLastWriteWins = cache.DedupLastWriteWins
+	DedupKeepEdited    = cache.DedupKeepEdited
+	DedupFirstSeen     = cache.DedupFirstSeen
+)
+
+// TextMode says how WithTextNormalization handles mrkdwn formatting
+type TextMode = mrkdwn.Mode
+
+const (
+	TextModeStrip    = mrkdwn.ModeStrip
+	TextModeMarkdown = mrkdwn.ModeMarkdown
+	TextModeKeep     = mrkdwn.ModeKeep
+)
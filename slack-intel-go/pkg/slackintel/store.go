@@ -0,0 +1,103 @@
+package slackintel
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/cache"
+	"github.com/zbigniewsiwiec/slack-intel-go/internal/models"
+)
+
+// Store persists fetched data. Each method returns the path it wrote.
+type Store interface {
+	SaveMessages(messages []*Message, channel *Channel, partition string) (string, error)
+	SaveUsers(users map[string]*User) (string, error)
+	SaveUserStats(stats map[string]*UserStats) (string, error)
+	SaveChannels(channels map[string]*Channel) (string, error)
+	Size(path string) (int64, error)
+}
+
+// ParquetStore writes the Hive-partitioned Parquet layout read by the
+// Python tooling: messages/dt=<partition>/channel=<name>/data.parquet
+// unless SetNameTemplate chooses another layout
+type ParquetStore = cache.ParquetCache
+
+var _ Store = (*ParquetStore)(nil)
+
+// JSONLStore writes message partitions as gzipped newline-delimited JSON
+// (data.jsonl.gz) in the same layout as a ParquetStore, one full Message
+// per line; users, channels and user stats stay Parquet
+type JSONLStore = cache.JSONLCache
+
+var _ Store = (*JSONLStore)(nil)
+
+// NewJSONLStore creates a JSONL store rooted at basePath (e.g. cache/raw).
+// Its embedded ParquetStore holds the layout and time zone settings.
+func NewJSONLStore(basePath string) *JSONLStore {
+	return cache.NewJSONLCache(cache.NewParquetCache(basePath))
+}
+
+// WriterOptions sets the row group and page sizes of a ParquetStore
+// (ParquetStore.SetWriterOptions)
+type WriterOptions = cache.WriterOptions
+
+// NewParquetStore creates a Parquet store rooted at basePath (e.g. cache/raw)
+func NewParquetStore(basePath string) *ParquetStore {
+	return cache.NewParquetCache(basePath)
+}
+
+// Partition groups messages by the partition key of their timestamp in UTC
+func Partition(messages []*Message, g Granularity) map[string][]*Message {
+	return PartitionIn(messages, g, time.UTC)
+}
+
+// PartitionIn groups messages by the partition key of their timestamp in
+// loc, e.g. dt=2024-05-10 holds that day's messages in loc
+func PartitionIn(messages []*Message, g Granularity, loc *time.Location) map[string][]*Message {
+	partitions := make(map[string][]*Message)
+	for _, msg := range messages {
+		key := g.PartitionKey(msg.Timestamp.In(loc))
+		partitions[key] = append(partitions[key], msg)
+	}
+	return partitions
+}
+
+// SaveChannel partitions messages and writes each partition to store,
+// returning the written paths in partition order. Partitions are cut in
+// the store's partition time zone (ParquetStore.SetPartitionTimezone),
+// UTC for stores without one.
+func SaveChannel(store Store, channel *Channel, messages []*Message, g Granularity) ([]string, error) {
+	loc := time.UTC
+	if zoned, ok := store.(interface{ PartitionTimezone() *time.Location }); ok {
+		loc = zoned.PartitionTimezone()
+	}
+	partitions := PartitionIn(messages, g, loc)
+
+	keys := make([]string, 0, len(partitions))
+	for key := range partitions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	paths := make([]string, 0, len(keys))
+	for _, key := range keys {
+		path, err := store.SaveMessages(partitions[key], channel, key)
+		if err != nil {
+			return paths, fmt.Errorf("failed to save partition %s: %w", key, err)
+		}
+		paths = append(paths, path)
+	}
+
+	return paths, nil
+}
+
+// AggregateUserStats computes per-user activity counts for messages
+func AggregateUserStats(messages []*Message) map[string]*UserStats {
+	return models.AggregateUserStats(messages)
+}
+
+// MergeUserStats adds src's counts into dst (allocated if nil) and returns it
+func MergeUserStats(dst, src map[string]*UserStats) map[string]*UserStats {
+	return models.MergeUserStats(dst, src)
+}
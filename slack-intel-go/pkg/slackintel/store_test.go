@@ -0,0 +1,37 @@
+package slackintel
+
+import (
+	"testing"
+	"time"
+)
+
+// TestPartitionInHostZone checks a near-midnight message lands in the same
+// partition whatever the host's local zone
+func TestPartitionInHostZone(t *testing.T) {
+	hostZone := time.Local
+	t.Cleanup(func() { time.Local = hostZone })
+
+	// 23:30 UTC on May 10 is May 11 at UTC+2
+	ts := time.Date(2024, 5, 10, 23, 30, 0, 0, time.UTC).Unix()
+	berlin := time.FixedZone("CEST", 2*60*60)
+	for _, host := range []*time.Location{time.UTC, time.FixedZone("UTC-8", -8*60*60), time.FixedZone("UTC+14", 14*60*60)} {
+		time.Local = host
+		// Timestamps parsed from Slack carry the host's zone
+		msgs := []*Message{{MessageID: "1715383800.000100", Timestamp: time.Unix(ts, 0)}}
+
+		if got := Partition(msgs, GranularityDay); len(got["2024-05-10"]) != 1 {
+			t.Errorf("host %s: UTC partitions = %v, want dt=2024-05-10", host, keys(got))
+		}
+		if got := PartitionIn(msgs, GranularityDay, berlin); len(got["2024-05-11"]) != 1 {
+			t.Errorf("host %s: UTC+2 partitions = %v, want dt=2024-05-11", host, keys(got))
+		}
+	}
+}
+
+func keys(partitions map[string][]*Message) []string {
+	var out []string
+	for key := range partitions {
+		out = append(out, key)
+	}
+	return out
+}